@@ -0,0 +1,231 @@
+package ezdb
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sync flushes the environment's data file to disk, which LMDB otherwise
+// leaves to the operating system between commits if it was opened with
+// asynchronous writes.
+func (db *Client) Sync() error {
+	env, release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = env.Sync(true)
+	if err != nil {
+		return fmt.Errorf("failed to sync environment: %w", err)
+	}
+
+	return nil
+}
+
+// AdminOptions configure AdminHandler.
+type AdminOptions struct {
+	// Auth authorizes every request; if it returns an error, the request is
+	// refused with 401 Unauthorized. It is required: a handler without it
+	// refuses every request. See BearerToken.
+	Auth func(r *http.Request) error
+	// Dir is the directory backups and compacted copies are written into;
+	// requests only name the file or directory within it. If empty, backup
+	// and compact are disabled.
+	Dir string
+}
+
+// BearerToken returns an AdminOptions.Auth accepting requests with the header
+// "Authorization: Bearer <token>".
+func BearerToken(token string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return errors.New("invalid token")
+		}
+		return nil
+	}
+}
+
+// AdminHandler returns an http.Handler for running maintenance on the
+// Client at runtime, so that operators don't have to restart the process:
+//
+//	GET  /status               the environment's Stats
+//	POST /sync                 Sync
+//	POST /compact?name={dir}   CompactTo the directory dir in opts.Dir
+//	POST /backup?name={file}   Backup to the new file file in opts.Dir
+//	POST /reader-check         ReaderCheck
+//	POST /resize?size={bytes}  ResizeMap to size bytes
+//
+// Every response is a JSON object with the operation, whether it succeeded,
+// its duration, its result and any error; resize reports the new map size.
+// Mount the handler under a prefix with http.StripPrefix.
+func (db *Client) AdminHandler(opts AdminOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.Trim(r.URL.Path, "/")
+		if opts.Auth == nil {
+			writeAdminResult(w, http.StatusUnauthorized, &adminResult{Op: op, Error: "no authorization configured"})
+			return
+		}
+		err := opts.Auth(r)
+		if err != nil {
+			writeAdminResult(w, http.StatusUnauthorized, &adminResult{Op: op, Error: err.Error()})
+			return
+		}
+
+		method := http.MethodPost
+		if op == "status" {
+			method = http.MethodGet
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeAdminResult(w, http.StatusMethodNotAllowed, &adminResult{Op: op, Error: "method not allowed"})
+			return
+		}
+
+		start := time.Now()
+		res := &adminResult{Op: op}
+		status := http.StatusOK
+		switch op {
+		case "status":
+			var stats Stats
+			stats, err = db.Stats()
+			res.Result = stats
+		case "sync":
+			err = db.Sync()
+		case "compact":
+			var path string
+			path, status, err = adminPath(opts.Dir, r)
+			if err == nil {
+				err = db.CompactTo(path)
+				res.Result = map[string]string{"path": path}
+			}
+		case "backup":
+			var path string
+			path, status, err = adminPath(opts.Dir, r)
+			if err == nil {
+				err = db.backupToFile(r.Context(), path)
+				res.Result = map[string]string{"path": path}
+			}
+		case "reader-check":
+			var n int
+			n, err = db.ReaderCheck()
+			res.Result = map[string]int{"cleared": n}
+		case "resize":
+			size, perr := strconv.ParseUint(r.URL.Query().Get("size"), 10, 63)
+			if perr != nil {
+				status, err = http.StatusBadRequest, errors.New("invalid size")
+				break
+			}
+			err = db.ResizeMap(size)
+			if err == nil {
+				var stats Stats
+				stats, err = db.Stats()
+				res.Result = map[string]uint64{"map_size": stats.MapSize}
+			}
+		default:
+			status, err = http.StatusNotFound, fmt.Errorf("unknown operation %q", op)
+		}
+
+		res.OK = err == nil
+		if err != nil {
+			res.Result = nil
+		}
+		res.Duration = time.Since(start).String()
+		if err != nil {
+			res.Error = err.Error()
+			if status == http.StatusOK {
+				status = http.StatusInternalServerError
+			}
+			db.options.log.Error().Err(err).Str("op", op).Msg("admin operation failed")
+		} else {
+			db.options.log.Info().Str("op", op).Str("duration", res.Duration).Msg("admin operation done")
+		}
+		writeAdminResult(w, status, res)
+	})
+}
+
+// adminResult is the response of AdminHandler.
+type adminResult struct {
+	Op       string `json:"op"`
+	OK       bool   `json:"ok"`
+	Duration string `json:"duration,omitempty"`
+	Result   any    `json:"result,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func writeAdminResult(w http.ResponseWriter, status int, res *adminResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// adminPath returns the path in dir of the name a request gives, and the
+// status to fail the request with if it is invalid.
+func adminPath(dir string, r *http.Request) (string, int, error) {
+	if dir == "" {
+		return "", http.StatusForbidden, errors.New("no directory configured for copies")
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return "", http.StatusBadRequest, fmt.Errorf("invalid name %q", name)
+	}
+
+	return filepath.Join(dir, name), http.StatusOK, nil
+}
+
+// backupToFile writes a Backup to the new file path.
+func (db *Client) backupToFile(ctx context.Context, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	err = db.Backup(ctx, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// ResizeMap sets the size of LMDB's memory map to size bytes, or to the
+// smallest size that holds the data if size is smaller than that, e.g. to
+// make room ahead of a bulk load rather than grow the map as it fills up. It
+// waits for the transactions in flight and holds off new ones while the map
+// is resized. Other storage engines return an error matching ErrUnsupported.
+func (db *Client) ResizeMap(size uint64) error {
+	if size > math.MaxInt64 {
+		return errors.New("map size is too large")
+	}
+
+	env, release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	resizer, ok := backendAs[mapResizer](env)
+	if !ok {
+		return fmt.Errorf("failed to resize map of %s engine: %w", db.options.engine, ErrUnsupported)
+	}
+
+	return resizer.ResizeMap(int64(size))
+}
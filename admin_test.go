@@ -0,0 +1,206 @@
+package ezdb_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// adminResponse is a response of the admin handler.
+type adminResponse struct {
+	Op       string          `json:"op"`
+	OK       bool            `json:"ok"`
+	Duration string          `json:"duration"`
+	Result   json.RawMessage `json:"result"`
+	Error    string          `json:"error"`
+}
+
+// admin sends a request to h with the bearer token "secret" and returns the
+// status and response.
+func admin(t *testing.T, h http.Handler, method, target string) (int, adminResponse) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var res adminResponse
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body, err)
+	}
+	return w.Code, res
+}
+
+func TestAdminHandler(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+	dir := t.TempDir()
+	h := db.AdminHandler(ezdb.AdminOptions{Auth: ezdb.BearerToken("secret"), Dir: dir})
+
+	code, res := admin(t, h, http.MethodGet, "/status")
+	var stats ezdb.Stats
+	err = json.Unmarshal(res.Result, &stats)
+	if code != http.StatusOK || !res.OK || res.Op != "status" || res.Duration == "" || err != nil || stats.PageSize == 0 {
+		t.Fatalf("status = %d %+v", code, res)
+	}
+
+	for _, op := range []string{"sync", "reader-check"} {
+		code, res = admin(t, h, http.MethodPost, "/"+op)
+		if code != http.StatusOK || !res.OK || res.Op != op {
+			t.Errorf("%s = %d %+v", op, code, res)
+		}
+	}
+	if string(res.Result) != `{"cleared":0}` {
+		t.Errorf("reader-check result = %s", res.Result)
+	}
+
+	code, res = admin(t, h, http.MethodPost, "/resize?size=134217728")
+	if code != http.StatusOK || string(res.Result) != `{"map_size":134217728}` {
+		t.Fatalf("resize = %d %+v", code, res)
+	}
+	wantN(t, ref, 10)
+
+	code, res = admin(t, h, http.MethodPost, "/backup?name=backup")
+	if code != http.StatusOK || !res.OK {
+		t.Fatalf("backup = %d %+v", code, res)
+	}
+	f, err := os.Open(filepath.Join(dir, "backup"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	restored := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(restored, f)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restoredRef, err := ezdb.NewRef[string, string]("ref", openClient(t, restored))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, restoredRef, 10)
+
+	// Backups never overwrite a file.
+	code, res = admin(t, h, http.MethodPost, "/backup?name=backup")
+	if code != http.StatusInternalServerError || res.OK || res.Error == "" || res.Result != nil {
+		t.Fatalf("backup to an existing file = %d %+v", code, res)
+	}
+
+	code, res = admin(t, h, http.MethodPost, "/compact?name=compacted")
+	if code != http.StatusOK || !res.OK {
+		t.Fatalf("compact = %d %+v", code, res)
+	}
+	compactedRef, err := ezdb.NewRef[string, string]("ref", openClient(t, filepath.Join(dir, "compacted")))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, compactedRef, 10)
+}
+
+func TestAdminHandlerErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	h := db.AdminHandler(ezdb.AdminOptions{Auth: ezdb.BearerToken("secret"), Dir: t.TempDir()})
+
+	for _, test := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodPost, "/status", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/sync", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/defrag", http.StatusNotFound},
+		{http.MethodPost, "/resize", http.StatusBadRequest},
+		{http.MethodPost, "/resize?size=-1", http.StatusBadRequest},
+		{http.MethodPost, "/backup", http.StatusBadRequest},
+		{http.MethodPost, "/backup?name=..", http.StatusBadRequest},
+		{http.MethodPost, "/backup?name=../escape", http.StatusBadRequest},
+		{http.MethodPost, "/compact?name=a/b", http.StatusBadRequest},
+	} {
+		code, res := admin(t, h, test.method, test.target)
+		if code != test.code || res.OK || res.Error == "" {
+			t.Errorf("%s %s = %d %+v, want %d", test.method, test.target, code, res, test.code)
+		}
+	}
+
+	// Copies need a directory to go into.
+	h = db.AdminHandler(ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")})
+	code, _ := admin(t, h, http.MethodPost, "/backup?name=backup")
+	if code != http.StatusForbidden {
+		t.Fatalf("backup without a directory = %d, want %d", code, http.StatusForbidden)
+	}
+
+	// Memory clients have no map to resize.
+	h = testutil.NewMemoryClient(t).AdminHandler(ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")})
+	code, res := admin(t, h, http.MethodPost, "/resize?size=1000000")
+	if code != http.StatusInternalServerError || res.OK {
+		t.Fatalf("resize of a memory client = %d %+v", code, res)
+	}
+}
+
+func TestAdminHandlerAuth(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	for _, test := range []struct {
+		name   string
+		opts   ezdb.AdminOptions
+		header string
+		code   int
+	}{
+		{"no auth", ezdb.AdminOptions{}, "Bearer secret", http.StatusUnauthorized},
+		{"no header", ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")}, "", http.StatusUnauthorized},
+		{"wrong token", ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")}, "Bearer nope", http.StatusUnauthorized},
+		{"basic", ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")}, "Basic secret", http.StatusUnauthorized},
+		{"empty token", ezdb.AdminOptions{Auth: ezdb.BearerToken("")}, "Bearer ", http.StatusUnauthorized},
+		{"token", ezdb.AdminOptions{Auth: ezdb.BearerToken("secret")}, "Bearer secret", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		db.AdminHandler(test.opts).ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: status = %d, want %d", test.name, w.Code, test.code)
+		}
+	}
+}
+
+func TestResizeMap(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	// Sizes smaller than the data need are raised to what it needs.
+	err = db.ResizeMap(1)
+	if err != nil {
+		t.Fatalf("ResizeMap: %v", err)
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.MapSize < stats.UsedPages*stats.PageSize {
+		t.Fatalf("map of %d bytes is smaller than the %d pages used", stats.MapSize, stats.UsedPages)
+	}
+	wantN(t, ref, 100)
+	putN(t, ref, 200)
+
+	err = db.ResizeMap(1 << 63)
+	if err == nil {
+		t.Fatal("ResizeMap of a huge size succeeded")
+	}
+}
@@ -0,0 +1,200 @@
+package ezdb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// auditDB is the named database the audit log of a Client is stored in.
+const auditDB = "ezdb.audit"
+
+// internalPrefix starts the names of the databases ezdb keeps its own
+// bookkeeping in.
+const internalPrefix = "ezdb."
+
+// WithAuditLog records every write to the Client's DBRefs in an append-only
+// audit log, in the same transaction as the write, so the log always matches
+// the data. This includes the writes of queues, time series, sets,
+// MultiRefs, SliceRefs, TTL sweeps and LRU evictions, but not dropping or
+// clearing whole databases, copying them by name with CopyDB or loading them
+// with Load, nor writes to blob stores or to DBRefs whose names start with
+// "ezdb.", which are reserved for ezdb's own bookkeeping. The log is stored
+// in its own named database, which counts towards WithNumDBs, and is read
+// with ReadAuditLog.
+func WithAuditLog() Option {
+	return func(option *options) error {
+		option.audit = true
+		return nil
+	}
+}
+
+// AuditRecord is an entry of the audit log.
+type AuditRecord struct {
+	// Seq is the position of the record in the log, starting at 1.
+	Seq uint64
+	// DBRef is the name of the DBRef written to.
+	DBRef string
+	// Key is the key written, as stored, i.e. encoded and including the
+	// namespace prefix of DBRefs made by Sub. For SliceRefs, it is the key of
+	// the chunk written.
+	Key []byte
+	// Op is "put" or "delete".
+	Op string
+	// Time is when the write was made.
+	Time time.Time
+	// Actor is who made the write, as set with ContextWithActor on the
+	// context of the DBRef, or empty.
+	Actor string
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying actor, which the audit log
+// records for writes made through a DBRef bound to the context with
+// WithContext.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set on ctx with ContextWithActor, if any.
+func ActorFromContext(ctx context.Context) (actor string, ok bool) {
+	actor, ok = ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// Name returns the name of the named database backing ref, which audit
+// records refer to it by.
+func (ref *DBRef[K, V]) Name() string {
+	return ref.id
+}
+
+// DecodeKey decodes a key as stored, such as the Key of an AuditRecord. It
+// fails for keys outside of ref's namespace, see Sub.
+func (ref *DBRef[K, V]) DecodeKey(data []byte) (*K, error) {
+	return ref.decodeKey(data)
+}
+
+// openAuditLog creates the audit log's database, unless the environment is
+// read-only.
+func (db *Client) openAuditLog(env backend) error {
+	if db.readOnly() {
+		return nil
+	}
+
+	err := env.Update(func(txn writeTxn) error {
+		_, err := txn.DBRef(auditDB, dbCreate)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// auditInTxn appends a record of a write of keyBytes to the audit log, if it
+// is enabled.
+func (ref *DBRef[K, V]) auditInTxn(txn writeTxn, op string, keyBytes []byte) error {
+	if !ref.ownerDB.options.audit || strings.HasPrefix(ref.id, internalPrefix) {
+		return nil
+	}
+
+	auditRef, err := txn.DBRef(auditDB, dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get audit log db ref: %w", err)
+	}
+
+	seq, err := lastSeq(txn, auditRef)
+	if err != nil {
+		return err
+	}
+
+	rec := AuditRecord{
+		Seq:   seq + 1,
+		DBRef: ref.id,
+		Key:   keyBytes,
+		Op:    op,
+		Time:  time.Now().UTC(),
+	}
+	if ref.ctx != nil {
+		rec.Actor, _ = ActorFromContext(ref.ctx)
+	}
+	recBytes, err := GobCodec{}.Marshal(&rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	err = txn.Put(auditRef, binary.BigEndian.AppendUint64(nil, rec.Seq), recBytes, putNoOverwrite)
+	if err != nil {
+		return fmt.Errorf("failed to append audit record: %w", err)
+	}
+
+	return nil
+}
+
+// lastSeq returns the last key of a database keyed by big-endian sequence
+// numbers, such as the audit log, or 0 if it is empty.
+func lastSeq(txn readTxn, dbRef dbi) (uint64, error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	key, _, err := cursor.Last()
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read last sequence number: %w", err)
+	}
+	if len(key) != 8 {
+		return 0, errors.New("corrupt sequence number")
+	}
+
+	return binary.BigEndian.Uint64(key), nil
+}
+
+// ReadAuditLog calls fn with the records of the audit log after the one with
+// sequence number after, in order. Pass 0 to start at the beginning, and the
+// Seq of the last record seen to resume.
+func (db *Client) ReadAuditLog(after uint64, fn func(rec *AuditRecord) error) error {
+	if !db.options.audit {
+		return errors.New("audit log is not enabled, see WithAuditLog")
+	}
+
+	return db.view(func(txn readTxn) error {
+		auditRef, err := txn.DBRef(auditDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get audit log db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(auditRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		_, recBytes, err := cursor.SeekGreaterThanOrEqualKey(binary.BigEndian.AppendUint64(nil, after+1))
+		for ; err == nil; _, recBytes, err = cursor.Next() {
+			rec := &AuditRecord{}
+			err = GobCodec{}.Unmarshal(recBytes, rec)
+			if err != nil {
+				return fmt.Errorf("failed to decode audit record: %w", err)
+			}
+			err = fn(rec)
+			if err != nil {
+				return err
+			}
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,166 @@
+package ezdb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// auditLog returns the records of the audit log of db after after.
+func auditLog(t *testing.T, db *ezdb.Client, after uint64) []ezdb.AuditRecord {
+	t.Helper()
+
+	var recs []ezdb.AuditRecord
+	err := db.ReadAuditLog(after, func(rec *ezdb.AuditRecord) error {
+		recs = append(recs, *rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+
+	return recs
+}
+
+func TestAuditLog(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	q, err := ezdb.NewQueue[string](db, "queue")
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+
+	start := time.Now()
+	key, val := "a", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	key = "b"
+	err = ref.WithContext(ezdb.ContextWithActor(context.Background(), "alice")).Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	key = "a"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	err = q.Enqueue(&val)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	_, _, err = q.Dequeue()
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	// Writes to ezdb's own databases aren't recorded.
+	seq, err := db.Sequence("ids")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+	_, err = seq.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+
+	recs := auditLog(t, db, 0)
+	var got []string
+	for i, rec := range recs {
+		if rec.Seq != uint64(i+1) || rec.Time.Before(start.Add(-time.Second)) {
+			t.Errorf("record %d = %+v", i, rec)
+		}
+		var k any
+		switch rec.DBRef {
+		case "ref":
+			decoded, err := ref.DecodeKey(rec.Key)
+			if err != nil {
+				t.Fatalf("DecodeKey: %v", err)
+			}
+			k = *decoded
+		case "queue":
+			var n uint64
+			err = ezdb.OrderedCodec{}.Unmarshal(rec.Key, &n)
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			k = n
+		}
+		got = append(got, fmt.Sprintf("%s %s %v %s", rec.Op, rec.DBRef, k, rec.Actor))
+	}
+	want := fmt.Sprint([]string{"put ref a ", "put ref b alice", "delete ref a ", "put queue 1 ", "delete queue 1 "})
+	if fmt.Sprint(got) != want {
+		t.Fatalf("audit log = %q, want %q", got, want)
+	}
+
+	// Reading resumes after a given record.
+	recs = auditLog(t, db, 2)
+	if len(recs) != 3 || recs[0].Seq != 3 {
+		t.Fatalf("audit log after 2 = %+v", recs)
+	}
+	if recs := auditLog(t, db, 5); len(recs) != 0 {
+		t.Fatalf("audit log after the last record = %+v", recs)
+	}
+
+	err = testutil.NewTempClient(t).ReadAuditLog(0, func(*ezdb.AuditRecord) error { return nil })
+	if err == nil {
+		t.Fatal("ReadAuditLog without WithAuditLog succeeded")
+	}
+}
+
+func TestAuditLogMultiValued(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	multi, err := ezdb.NewMultiRef[string, string](db, "multi", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewMultiRef: %v", err)
+	}
+	list, err := ezdb.NewSliceRef[string, string](db, "list", 0, ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewSliceRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	for i := 0; i < 2; i++ {
+		// Adding a value the key already has writes nothing.
+		err = multi.Add(&key, &val)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	err = multi.RemoveValue(&key, &val)
+	if err != nil {
+		t.Fatalf("RemoveValue: %v", err)
+	}
+	err = multi.Add(&key, &val)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	err = multi.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	err = list.Append(&key, val)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	err = list.RemoveAt(&key, 0)
+	if err != nil {
+		t.Fatalf("RemoveAt: %v", err)
+	}
+
+	var got []string
+	for _, rec := range auditLog(t, db, 0) {
+		got = append(got, rec.DBRef+" "+rec.Op)
+	}
+	want := "[multi put multi delete multi put multi delete list put list delete]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("audit log = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package ezdb
+
+import (
+	"context"
+	"io"
+)
+
+// backend is the storage engine a Client keeps its named databases in. The
+// typed layer only talks to the engine through backend and the transactions
+// and cursors it hands out, so engines other than LMDB can be swapped in.
+//
+// Its semantics are LMDB's: a single writer at a time, readers seeing a
+// consistent snapshot, named databases of keys in bytewise order, and
+// dbDupSort databases holding several sorted values per key.
+type backend interface {
+	// View runs fn in a read transaction.
+	View(fn func(txn readTxn) error) error
+	// Update runs fn in a write transaction, committing it if fn returns nil
+	// and aborting it otherwise. fn may be run more than once.
+	Update(fn func(txn writeTxn) error) error
+	// Sync flushes written data to disk.
+	Sync(force bool) error
+	// Copy writes a consistent copy of the environment into the directory
+	// path, compacting it if compact is set.
+	Copy(path string, compact bool) error
+	// Close syncs and closes the environment.
+	Close()
+}
+
+// readerChecker is a backend with a reader table, which processes that exit
+// without ending their read transactions leave slots behind in.
+type readerChecker interface {
+	// ReaderCheck clears the slots of dead processes and returns how many it
+	// cleared.
+	ReaderCheck() (int, error)
+}
+
+// envStater is a backend that reports the state of its environment, as
+// LMDB's mdb_env_info and mdb_env_stat do.
+type envStater interface {
+	EnvStat() (envStat, error)
+}
+
+// envStat is the state of an environment.
+type envStat struct {
+	// Version is the version of the engine's library, and VersionMajor,
+	// VersionMinor and VersionPatch its parts.
+	Version                                  string
+	VersionMajor, VersionMinor, VersionPatch int
+	PageSize                                 uint64
+	MapSize                                  uint64
+	LastPage                                 uint64
+	LastTxnID                                uint64
+	// FreePages is the number of pages on the free list.
+	FreePages uint64
+	// Readers are the slots of the reader table in use.
+	Readers []readerSlot
+}
+
+// statTxn is a readTxn that reports the size of a named database.
+type statTxn interface {
+	Stat(db dbi) (DBStat, error)
+}
+
+// streamCopier is a backend that can stream a copy of its environment, as
+// Copy writes it, to w. It stops once ctx is done.
+type streamCopier interface {
+	CopyTo(ctx context.Context, w io.Writer, compact bool) error
+}
+
+// mapResizer is a backend whose memory map can be resized on demand.
+type mapResizer interface {
+	// ResizeMap sets the size of the map to size bytes, or to the smallest
+	// size that holds the data if size is smaller than that.
+	ResizeMap(size int64) error
+}
+
+// backendAs returns env, or the backend it wraps, as a T.
+func backendAs[T any](env backend) (T, bool) {
+	if f, ok := env.(*faultBackend); ok {
+		env = f.backend
+	}
+	t, ok := env.(T)
+	return t, ok
+}
+
+// dbi identifies a named database within a transaction.
+type dbi uint32
+
+// envFlag, dbFlag and putFlag are the flags of environments, named databases
+// and writes, with the values LMDB gives them.
+type (
+	envFlag uint
+	dbFlag  uint
+	putFlag uint
+)
+
+// readTxn is a read transaction. Lookups of missing keys, and cursor moves
+// past either end, fail with an error matching ErrNotFound. Returned bytes are
+// only valid until the transaction ends.
+type readTxn interface {
+	// DBRef returns the named database name, creating it if flags includes
+	// dbCreate and the transaction can write.
+	DBRef(name string, flags dbFlag) (dbi, error)
+	Get(db dbi, key []byte) ([]byte, error)
+	NewCursor(db dbi) (dbCursor, error)
+	// ListDBs returns the named databases, in the order of their names.
+	ListDBs() ([]namedDB, error)
+}
+
+// namedDB is a named database and the flags it was created with.
+type namedDB struct {
+	name  string
+	flags dbFlag
+}
+
+// writeTxn is a write transaction. Its reads see its own writes.
+type writeTxn interface {
+	readTxn
+	// Put stores val under key. With putNoOverwrite it fails with an error
+	// matching ErrKeyExists if key is present; with putNoDupData, for
+	// dbDupSort databases, if the pair is.
+	Put(db dbi, key, val []byte, flags putFlag) error
+	// Delete deletes key, or only the pair of key and val if val is not nil
+	// in a dbDupSort database.
+	Delete(db dbi, key, val []byte) error
+	// Empty deletes every entry of db.
+	Empty(db dbi) error
+	// Drop deletes db itself.
+	Drop(db dbi) error
+}
+
+// reserveTxn is a writeTxn that can reserve the space of a value, so that the
+// value can be encoded in place instead of into a buffer that Put copies.
+// LMDB implements it with MDB_RESERVE, which reserves the space in the map.
+type reserveTxn interface {
+	writeTxn
+	// PutReserve stores a value of size bytes under key like Put, and
+	// returns it for the caller to fill in. It must be filled in before the
+	// next write of the transaction, and is only valid until then: LMDB may
+	// move it. dbDupSort databases don't support it.
+	PutReserve(db dbi, key []byte, size int, flags putFlag) ([]byte, error)
+}
+
+// dbCursor iterates over a named database in key order, and over the values
+// of each key in value order for dbDupSort databases.
+type dbCursor interface {
+	First() (key, val []byte, err error)
+	Last() (key, val []byte, err error)
+	Next() (key, val []byte, err error)
+	Prev() (key, val []byte, err error)
+	// NextInSameKey moves to the next value of the current key.
+	NextInSameKey() (key, val []byte, err error)
+	// Count returns the number of values of the current key.
+	Count() (uint64, error)
+	// SeekExactKey moves to key and returns its first value.
+	SeekExactKey(key []byte) (val []byte, err error)
+	// SeekGreaterThanOrEqualKey moves to the first key not less than key.
+	SeekGreaterThanOrEqualKey(key []byte) (k, val []byte, err error)
+	Close()
+}
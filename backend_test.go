@@ -0,0 +1,513 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBackend runs the checks every backend has to pass on the backends of
+// Clients from open, which initializes a Client with its environment in dir.
+func testBackend(t *testing.T, open func(t *testing.T, dir string) *Client) {
+	// openEnv opens a Client in dir and returns its backend.
+	openEnv := func(t *testing.T, dir string) (*Client, backend) {
+		t.Helper()
+
+		db := open(t, dir)
+		env, release, err := db.acquire()
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		release()
+		return db, env
+	}
+
+	t.Run("DBRef", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+
+		err := env.View(func(txn readTxn) error {
+			_, err := txn.DBRef("db", dbCreate)
+			return err
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("DBRef of a missing database in a read transaction returned %v, want ErrNotFound", err)
+		}
+		err = env.Update(func(txn writeTxn) error {
+			_, err := txn.DBRef("db", 0)
+			return err
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("DBRef of a missing database without dbCreate returned %v, want ErrNotFound", err)
+		}
+
+		var created dbi
+		err = env.Update(func(txn writeTxn) (err error) {
+			created, err = txn.DBRef("db", dbCreate)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("DBRef with dbCreate: %v", err)
+		}
+		err = env.View(func(txn readTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err == nil && db != created {
+				err = fmt.Errorf("got handle %d, created %d", db, created)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("DBRef of a created database: %v", err)
+		}
+	})
+
+	t.Run("Put", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+
+		err := env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", dbCreate)
+			if err != nil {
+				return err
+			}
+			for _, key := range []string{"b", "a", "c"} {
+				err = txn.Put(db, []byte(key), []byte("v"+key), 0)
+				if err != nil {
+					return err
+				}
+			}
+			err = txn.Put(db, []byte("a"), []byte("va2"), 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(db, []byte("a"), []byte("va3"), putNoOverwrite)
+			if !errors.Is(err, ErrKeyExists) {
+				return fmt.Errorf("Put with putNoOverwrite of a present key returned %v, want ErrKeyExists", err)
+			}
+
+			// Transactions read their own writes.
+			val, err := txn.Get(db, []byte("a"))
+			if err != nil || string(val) != "va2" {
+				return fmt.Errorf("Get in the transaction = %q, %v", val, err)
+			}
+
+			// Empty values are values like any other, as Sets store them.
+			return txn.Put(db, []byte("e"), nil, 0)
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		err = env.View(func(txn readTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			val, err := txn.Get(db, []byte("a"))
+			if err != nil || string(val) != "va2" {
+				return fmt.Errorf("Get = %q, %v", val, err)
+			}
+			_, err = txn.Get(db, []byte("d"))
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Get of a missing key returned %v, want ErrNotFound", err)
+			}
+			val, err = txn.Get(db, []byte("e"))
+			if err != nil || len(val) != 0 {
+				return fmt.Errorf("Get of an empty value = %q, %v", val, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("View: %v", err)
+		}
+		wantEntries(t, env, "db", "a=va2", "b=vb", "c=vc", "e=")
+
+		err = env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			return txn.Delete(db, []byte("e"), nil)
+		})
+		if err != nil {
+			t.Fatalf("Delete of an empty value: %v", err)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "db", 0, "a", "1")
+
+		errAbort := errors.New("abort")
+		err := env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(db, []byte("b"), []byte("2"), 0)
+			if err == nil {
+				err = txn.Delete(db, []byte("a"), nil)
+			}
+			if err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Update returned %v, want the error of fn", err)
+		}
+		wantEntries(t, env, "db", "a=1")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "db", 0, "a", "1", "b", "2")
+
+		err := env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(db, []byte("c"), nil)
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Delete of a missing key returned %v, want ErrNotFound", err)
+			}
+			return txn.Delete(db, []byte("a"), nil)
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		wantEntries(t, env, "db", "b=2")
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "db", 0, "b", "2", "d", "4", "f", "6")
+
+		err := env.View(func(txn readTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			c, err := txn.NewCursor(db)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			for _, step := range []struct {
+				name string
+				move func() ([]byte, []byte, error)
+				want string
+			}{
+				{"First", c.First, "b=2"},
+				{"Prev", c.Prev, ""},
+				{"Last", c.Last, "f=6"},
+				{"Next", c.Next, ""},
+				{"Seek c", func() ([]byte, []byte, error) { return c.SeekGreaterThanOrEqualKey([]byte("c")) }, "d=4"},
+				{"Next", c.Next, "f=6"},
+				{"Prev", c.Prev, "d=4"},
+				{"Seek d", func() ([]byte, []byte, error) { return c.SeekGreaterThanOrEqualKey([]byte("d")) }, "d=4"},
+				{"Seek g", func() ([]byte, []byte, error) { return c.SeekGreaterThanOrEqualKey([]byte("g")) }, ""},
+				{"Seek exact b", func() ([]byte, []byte, error) {
+					val, err := c.SeekExactKey([]byte("b"))
+					return []byte("b"), val, err
+				}, "b=2"},
+				{"Next", c.Next, "d=4"},
+				{"Seek exact c", func() ([]byte, []byte, error) {
+					val, err := c.SeekExactKey([]byte("c"))
+					return []byte("c"), val, err
+				}, ""},
+			} {
+				key, val, err := step.move()
+				if step.want == "" {
+					if !errors.Is(err, ErrNotFound) {
+						return fmt.Errorf("%s returned %q=%q, %v, want ErrNotFound", step.name, key, val, err)
+					}
+					continue
+				}
+				if err != nil || string(key)+"="+string(val) != step.want {
+					return fmt.Errorf("%s = %q=%q, %v, want %s", step.name, key, val, err, step.want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Cursors of empty databases have nothing to move to.
+		put(t, env, "empty", 0)
+		err = env.View(func(txn readTxn) error {
+			db, err := txn.DBRef("empty", 0)
+			if err != nil {
+				return err
+			}
+			c, err := txn.NewCursor(db)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			_, _, err = c.First()
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("First of an empty database returned %v, want ErrNotFound", err)
+			}
+			_, _, err = c.Last()
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Last of an empty database returned %v, want ErrNotFound", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("dupsort", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "db", dbDupSort, "a", "3", "a", "1", "a", "2", "b", "1", "a", "2")
+		wantEntries(t, env, "db", "a=1", "a=2", "a=3", "b=1")
+
+		err := env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", dbDupSort)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(db, []byte("a"), []byte("2"), putNoDupData)
+			if !errors.Is(err, ErrKeyExists) {
+				return fmt.Errorf("Put with putNoDupData of a present pair returned %v, want ErrKeyExists", err)
+			}
+			err = txn.Put(db, []byte("a"), []byte("4"), putNoDupData)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(db, []byte("b"), []byte("2"), putNoOverwrite)
+			if !errors.Is(err, ErrKeyExists) {
+				return fmt.Errorf("Put with putNoOverwrite of a present key returned %v, want ErrKeyExists", err)
+			}
+
+			c, err := txn.NewCursor(db)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			val, err := c.SeekExactKey([]byte("a"))
+			if err != nil || string(val) != "1" {
+				return fmt.Errorf("SeekExactKey = %q, %v", val, err)
+			}
+			n, err := c.Count()
+			if err != nil || n != 4 {
+				return fmt.Errorf("Count = %d, %v", n, err)
+			}
+			for _, want := range []string{"2", "3", "4"} {
+				_, val, err = c.NextInSameKey()
+				if err != nil || string(val) != want {
+					return fmt.Errorf("NextInSameKey = %q, %v, want %s", val, err, want)
+				}
+			}
+			_, _, err = c.NextInSameKey()
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("NextInSameKey past the last value returned %v, want ErrNotFound", err)
+			}
+
+			// Deleting a pair leaves the other values of its key.
+			err = txn.Delete(db, []byte("a"), []byte("3"))
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(db, []byte("a"), []byte("5"))
+			if !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Delete of a missing pair returned %v, want ErrNotFound", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantEntries(t, env, "db", "a=1", "a=2", "a=4", "b=1")
+
+		// Deleting a key deletes all of its values.
+		err = env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", dbDupSort)
+			if err != nil {
+				return err
+			}
+			return txn.Delete(db, []byte("a"), nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantEntries(t, env, "db", "b=1")
+	})
+
+	t.Run("Empty and Drop", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "emptied", 0, "a", "1", "b", "2")
+		put(t, env, "dropped", 0, "a", "1")
+		put(t, env, "kept", 0, "a", "1")
+
+		err := env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("emptied", 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Empty(db)
+			if err != nil {
+				return err
+			}
+			db, err = txn.DBRef("dropped", 0)
+			if err != nil {
+				return err
+			}
+			return txn.Drop(db)
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		wantEntries(t, env, "emptied")
+		wantEntries(t, env, "kept", "a=1")
+		err = env.View(func(txn readTxn) error {
+			_, err := txn.DBRef("dropped", 0)
+			return err
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("DBRef of a dropped database returned %v, want ErrNotFound", err)
+		}
+
+		// A database created again under the name of a dropped one starts out
+		// empty.
+		put(t, env, "dropped", 0)
+		wantEntries(t, env, "dropped")
+	})
+
+	t.Run("PutReserve", func(t *testing.T) {
+		_, env := openEnv(t, t.TempDir())
+		put(t, env, "db", 0, "a", "1")
+
+		err := env.Update(func(txn writeTxn) error {
+			rtxn, ok := txn.(reserveTxn)
+			if !ok {
+				t.Skip("backend doesn't reserve values")
+			}
+			db, err := rtxn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			buf, err := rtxn.PutReserve(db, []byte("b"), 3, 0)
+			if err != nil {
+				return err
+			}
+			copy(buf, "two")
+			_, err = rtxn.PutReserve(db, []byte("a"), 3, putNoOverwrite)
+			if !errors.Is(err, ErrKeyExists) {
+				return fmt.Errorf("PutReserve with putNoOverwrite of a present key returned %v, want ErrKeyExists", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantEntries(t, env, "db", "a=1", "b=two")
+	})
+
+	t.Run("persistence", func(t *testing.T) {
+		dir := t.TempDir()
+		db, env := openEnv(t, dir)
+		if !db.Capabilities().Persistent {
+			t.Skip("backend isn't persistent")
+		}
+		put(t, env, "db", dbDupSort, "a", "1", "a", "2")
+		err := env.Sync(true)
+		if err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		err = db.Close()
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		_, env = openEnv(t, dir)
+		wantEntries(t, env, "db", "a=1", "a=2")
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		db, env := openEnv(t, t.TempDir())
+		put(t, env, "db", 0, "a", "1")
+
+		for _, compact := range []bool{false, true} {
+			dst := filepath.Join(t.TempDir(), "copy")
+			err := os.Mkdir(dst, 0o755)
+			if err != nil {
+				t.Fatalf("Mkdir: %v", err)
+			}
+			err = env.Copy(dst, compact)
+			if !db.Capabilities().Copy {
+				if !errors.Is(err, ErrUnsupported) {
+					t.Fatalf("Copy of an engine without copies returned %v, want ErrUnsupported", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Copy: %v", err)
+			}
+			_, copied := openEnv(t, dst)
+			wantEntries(t, copied, "db", "a=1")
+		}
+	})
+}
+
+// put creates the named database name in env with flags and stores the
+// pairs of keys and values kv in it.
+func put(t *testing.T, env backend, name string, flags dbFlag, kv ...string) {
+	t.Helper()
+
+	err := env.Update(func(txn writeTxn) error {
+		db, err := txn.DBRef(name, flags|dbCreate)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(kv); i += 2 {
+			err = txn.Put(db, []byte(kv[i]), []byte(kv[i+1]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to put %v into %s: %v", kv, name, err)
+	}
+}
+
+// wantEntries fails t unless the entries of the named database name in env,
+// formatted as key=value, are want in order, both walking forwards and
+// backwards.
+func wantEntries(t *testing.T, env backend, name string, want ...string) {
+	t.Helper()
+
+	var forward, backward []string
+	err := env.View(func(txn readTxn) error {
+		db, err := txn.DBRef(name, 0)
+		if err != nil {
+			return err
+		}
+		c, err := txn.NewCursor(db)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		for key, val, err := c.First(); err == nil; key, val, err = c.Next() {
+			forward = append(forward, string(key)+"="+string(val))
+		}
+		for key, val, err := c.Last(); err == nil; key, val, err = c.Prev() {
+			backward = append([]string{string(key) + "=" + string(val)}, backward...)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	if fmt.Sprint(forward) != fmt.Sprint(want) || fmt.Sprint(backward) != fmt.Sprint(want) {
+		t.Fatalf("%s holds %v, and %v backwards, want %v", name, forward, backward, want)
+	}
+}
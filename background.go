@@ -0,0 +1,37 @@
+package ezdb
+
+// A background task runs in its own goroutine while the Client is open. It
+// must return once stop is closed, which happens when the Client is closed;
+// Reopen starts it again.
+type backgroundTask func(stop <-chan struct{})
+
+// startBackground registers task and starts it right away if the Client is
+// open.
+func (db *Client) startBackground(task backgroundTask) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.tasks = append(db.tasks, task)
+	if db.db != nil {
+		db.runTask(task)
+	}
+}
+
+// runTasks starts all registered tasks. The caller must hold db.mu.
+func (db *Client) runTasks() {
+	db.stopTasks = make(chan struct{})
+	for _, task := range db.tasks {
+		db.runTask(task)
+	}
+}
+
+// runTask starts task. The caller must hold db.mu, and db.stopTasks must be
+// open.
+func (db *Client) runTask(task backgroundTask) {
+	stop := db.stopTasks
+	db.taskWG.Add(1)
+	go func() {
+		defer db.taskWG.Done()
+		task(stop)
+	}()
+}
@@ -0,0 +1,111 @@
+package ezdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Backup writes a consistent copy of the environment to w, such as a file, a
+// pipe or a network connection, while the Client stays online: readers and
+// writers aren't blocked, and the copy reflects the last write committed when
+// it starts. The stream is an LMDB data file, which Restore turns back into an
+// environment. LMDB writes the copy into a pipe that is streamed to w, so it
+// needs no disk space. Once ctx is done, the copy stops and Backup returns
+// ctx's error.
+func (db *Client) Backup(ctx context.Context, w io.Writer) error {
+	return db.copyTo(ctx, w, false)
+}
+
+// CompactTo writes a compacted copy of the environment into the directory
+// path, creating it if needed, like Backup but leaving out free pages and
+// renumbering the remaining ones. Long-lived environments with heavy delete
+// churn never shrink by themselves, so compacting into a new directory and
+// switching over to it is the way to reclaim their disk space. The directory
+// must not already hold an environment.
+func (db *Client) CompactTo(path string) error {
+	_, err := os.Stat(filepath.Join(path, dataFileName))
+	if err == nil {
+		return fmt.Errorf("failed to compact: %s already holds an environment", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check for an environment: %w", err)
+	}
+
+	err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create db directory: %w", err)
+	}
+
+	return db.copyEnv(path, true)
+}
+
+// CompactToWriter streams a compacted copy of the environment to w, like
+// Backup. See CompactTo.
+func (db *Client) CompactToWriter(ctx context.Context, w io.Writer) error {
+	return db.copyTo(ctx, w, true)
+}
+
+// copyTo streams a copy of the environment to w, compacted if compact is set.
+func (db *Client) copyTo(ctx context.Context, w io.Writer, compact bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !db.Capabilities().Copy {
+		return fmt.Errorf("failed to copy %s environment: %w", db.options.engine, ErrUnsupported)
+	}
+
+	env, release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	copier, ok := backendAs[streamCopier](env)
+	if !ok {
+		return fmt.Errorf("failed to stream copy of %s environment: %w", db.options.engine, ErrUnsupported)
+	}
+	err = copier.CopyTo(ctx, w, compact)
+	if err != nil {
+		return fmt.Errorf("failed to copy environment: %w", err)
+	}
+
+	return nil
+}
+
+// copyEnv copies the environment into the existing, empty directory dir.
+func (db *Client) copyEnv(dir string, compact bool) error {
+	if !db.Capabilities().Copy {
+		return fmt.Errorf("failed to copy %s environment: %w", db.options.engine, ErrUnsupported)
+	}
+
+	env, release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = env.Copy(dir, compact)
+	if err != nil {
+		return fmt.Errorf("failed to copy environment: %w", err)
+	}
+
+	return nil
+}
+
+// ctxReader is an io.Reader that fails once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,61 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestBackup(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 1000)
+
+	var buf bytes.Buffer
+	err = db.Backup(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	// Writes after the backup aren't in it.
+	key, val := "later", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(dir, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored := openClient(t, dir)
+	ref, err = ezdb.NewRef[string, string]("ref", restored)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, ref, 1000)
+}
+
+func TestBackupCanceled(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := db.Backup(ctx, io.Discard)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Backup with a canceled context returned %v", err)
+	}
+	err = testutil.NewMemoryClient(t).Backup(context.Background(), io.Discard)
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("Backup of a memory Client returned %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,206 @@
+package ezdb
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Hash is the SHA-256 hash of the content of a blob in a BlobStore.
+type Hash [sha256.Size]byte
+
+// String returns h in hex.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// ParseHash parses a Hash from its String form.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Hash{}, fmt.Errorf("invalid hash %q: %w", s, err)
+	}
+	if len(b) != len(h) {
+		return Hash{}, fmt.Errorf("invalid hash %q: wrong length", s)
+	}
+	copy(h[:], b)
+
+	return h, nil
+}
+
+// The database of a BlobStore holds the content of every blob under
+// blobDataPrefix+hash and its reference count, a big-endian uint64, under
+// blobRefsPrefix+hash, so that counting references doesn't rewrite the
+// content.
+var (
+	blobDataPrefix = []byte("d")
+	blobRefsPrefix = []byte("r")
+)
+
+// BlobStore stores blobs under the hash of their content, so every distinct
+// content is stored once however often it is put. Blobs are reference
+// counted: every Put of a content takes a reference to it, every Release
+// gives one up, and the blob is deleted with its last reference.
+type BlobStore struct {
+	name    string
+	ownerDB *Client
+}
+
+// BlobStore opens the blob store in the named database name of db, creating
+// it if it doesn't exist.
+func (db *Client) BlobStore(name string) (*BlobStore, error) {
+	err := db.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	if db.readOnly() {
+		err = db.view(func(txn readTxn) error {
+			_, err := txn.DBRef(name, dbFlag(0))
+			return err
+		})
+	} else {
+		err = db.update(func(txn writeTxn) error {
+			_, err := txn.DBRef(name, dbCreate)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob store %q: %w", name, err)
+	}
+
+	return &BlobStore{name: name, ownerDB: db}, nil
+}
+
+// blobKey returns the key of hash under prefix.
+func blobKey(prefix []byte, hash Hash) []byte {
+	return append(append([]byte(nil), prefix...), hash[:]...)
+}
+
+// refsInTxn returns the reference count of hash, which is zero if the blob
+// doesn't exist.
+func refsInTxn(txn readTxn, dbRef dbi, hash Hash) (uint64, error) {
+	data, err := txn.Get(dbRef, blobKey(blobRefsPrefix, hash))
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get reference count: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid reference count of %s", hash)
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// Put stores data, unless a blob with the same content exists, takes a
+// reference to it and returns its hash.
+func (s *BlobStore) Put(data []byte) (Hash, error) {
+	hash := Hash(sha256.Sum256(data))
+	err := s.ownerDB.write(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(s.name, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		refs, err := refsInTxn(txn, dbRef, hash)
+		if err != nil {
+			return err
+		}
+		if refs == 0 {
+			err = txn.Put(dbRef, blobKey(blobDataPrefix, hash), data, putFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to put blob: %w", err)
+			}
+		}
+
+		err = txn.Put(dbRef, blobKey(blobRefsPrefix, hash), binary.BigEndian.AppendUint64(nil, refs+1), putFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to put reference count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Hash{}, err
+	}
+
+	return hash, nil
+}
+
+// Get returns the content of the blob hash. A missing blob is reported as an
+// error matching ErrNotFound.
+func (s *BlobStore) Get(hash Hash) (data []byte, err error) {
+	err = s.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(s.name, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		stored, err := txn.Get(dbRef, blobKey(blobDataPrefix, hash))
+		if err != nil {
+			return fmt.Errorf("failed to get blob %s: %w", hash, err)
+		}
+		data = append([]byte(nil), stored...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Refs returns the number of references to the blob hash, which is zero if
+// it doesn't exist.
+func (s *BlobStore) Refs(hash Hash) (refs uint64, err error) {
+	err = s.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(s.name, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		refs, err = refsInTxn(txn, dbRef, hash)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return refs, nil
+}
+
+// Release gives up a reference to the blob hash, deleting it with its last
+// one. A missing blob is reported as an error matching ErrNotFound.
+func (s *BlobStore) Release(hash Hash) error {
+	return s.ownerDB.write(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(s.name, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		refs, err := refsInTxn(txn, dbRef, hash)
+		if err != nil {
+			return err
+		}
+		switch refs {
+		case 0:
+			return fmt.Errorf("failed to release blob %s: %w", hash, ErrNotFound)
+		case 1:
+			err = txn.Delete(dbRef, blobKey(blobDataPrefix, hash), nil)
+			if err != nil {
+				return fmt.Errorf("failed to delete blob: %w", err)
+			}
+			err = txn.Delete(dbRef, blobKey(blobRefsPrefix, hash), nil)
+		default:
+			err = txn.Put(dbRef, blobKey(blobRefsPrefix, hash), binary.BigEndian.AppendUint64(nil, refs-1), putFlag(0))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update reference count: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,137 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// wantRefs checks that s holds want references to hash.
+func wantRefs(t *testing.T, s *ezdb.BlobStore, hash ezdb.Hash, want uint64) {
+	t.Helper()
+
+	refs, err := s.Refs(hash)
+	if err != nil || refs != want {
+		t.Fatalf("Refs(%s) = %d, %v, want %d", hash, refs, err, want)
+	}
+}
+
+func TestBlobStore(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			s, err := db.BlobStore("blobs")
+			if err != nil {
+				t.Fatalf("BlobStore: %v", err)
+			}
+
+			data := []byte("content")
+			hash, err := s.Put(data)
+			if err != nil || hash != sha256.Sum256(data) {
+				t.Fatalf("Put = %s, %v", hash, err)
+			}
+			again, err := s.Put(data)
+			if err != nil || again != hash {
+				t.Fatalf("second Put = %s, %v", again, err)
+			}
+			wantRefs(t, s, hash, 2)
+			got, err := s.Get(hash)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("Get = %q, %v", got, err)
+			}
+
+			// The content is stored once.
+			n := 0
+			err = rawRef(t, db, "blobs", ezdb.WithKeyCodec(ezdb.StringCodec{})).ForEach(func(*string, *[]byte) error {
+				n++
+				return nil
+			})
+			if err != nil || n != 2 {
+				t.Fatalf("blob store holds %d records: %v", n, err)
+			}
+
+			empty, err := s.Put(nil)
+			if err != nil {
+				t.Fatalf("Put of an empty blob: %v", err)
+			}
+			got, err = s.Get(empty)
+			if err != nil || len(got) != 0 {
+				t.Fatalf("Get of an empty blob = %q, %v", got, err)
+			}
+
+			// The blob is deleted with its last reference.
+			err = s.Release(hash)
+			if err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+			wantRefs(t, s, hash, 1)
+			err = s.Release(hash)
+			if err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+			wantRefs(t, s, hash, 0)
+			_, err = s.Get(hash)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("Get of a released blob returned %v, want ErrNotFound", err)
+			}
+			err = s.Release(hash)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("Release of a released blob returned %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestBlobStoreReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	s, err := db.BlobStore("blobs")
+	if err != nil {
+		t.Fatalf("BlobStore: %v", err)
+	}
+	hash, err := s.Put([]byte("content"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	db.Close()
+
+	db = openClient(t, dir, ezdb.WithReadOnly())
+	s, err = db.BlobStore("blobs")
+	if err != nil {
+		t.Fatalf("BlobStore: %v", err)
+	}
+	got, err := s.Get(hash)
+	if err != nil || string(got) != "content" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	_, err = db.BlobStore("missing")
+	if err == nil {
+		t.Fatal("BlobStore of a missing store on a read-only Client succeeded")
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	s, err := testutil.NewTempClient(t).BlobStore("blobs")
+	if err != nil {
+		t.Fatalf("BlobStore: %v", err)
+	}
+	hash, err := s.Put([]byte("content"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	parsed, err := ezdb.ParseHash(hash.String())
+	if err != nil || parsed != hash {
+		t.Fatalf("ParseHash(%s) = %s, %v", hash, parsed, err)
+	}
+	for _, s := range []string{"", "zz", hash.String()[2:]} {
+		_, err := ezdb.ParseHash(s)
+		if err == nil {
+			t.Errorf("ParseHash(%q) succeeded", s)
+		}
+	}
+}
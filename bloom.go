@@ -0,0 +1,403 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bloomsDB is the named database the Bloom filters of all DBRefs with one
+// are persisted in.
+const bloomsDB = "ezdb.blooms"
+
+// bloomPersistInterval is how often changed Bloom filters are persisted.
+const bloomPersistInterval = time.Minute
+
+// bloomOptions configure the Bloom filter of a DBRef.
+type bloomOptions struct {
+	expectedEntries uint
+	falsePositives  float64
+}
+
+// WithBloomFilter keeps a Bloom filter of the DBRef's keys in memory, so Get,
+// TryGet and the other lookups of a key that doesn't exist usually return
+// without starting a read transaction. The filter is sized for
+// expectedEntries keys with a rate of false positives, lookups of missing keys
+// that still read the database, of falsePositives; beyond that many keys the
+// rate grows. Deleted keys stay in the filter until it is rebuilt.
+//
+// The filter is persisted in a named database, which counts towards
+// WithNumDBs, every minute and when the Client is closed, and loaded when the
+// DBRef is opened. Every write invalidates the persisted copy in the same
+// transaction, so after a crash the filter is rebuilt from the keys instead.
+// The filter only learns of writes made in this process, so every process
+// writing the database must use the option too; it can't be used with
+// WithReadOnly.
+func WithBloomFilter(expectedEntries uint, falsePositives float64) RefOption {
+	return func(option *refOptions) error {
+		if expectedEntries == 0 {
+			return errors.New("expected entries must be positive")
+		}
+		if falsePositives <= 0 || falsePositives >= 1 {
+			return errors.New("false positive rate must be between 0 and 1")
+		}
+		option.bloom = &bloomOptions{expectedEntries: expectedEntries, falsePositives: falsePositives}
+		return nil
+	}
+}
+
+// bloomFilter is a Bloom filter of encoded keys.
+type bloomFilter struct {
+	opts bloomOptions
+	// k is the number of bits set per key, out of the len(bits)*64 bits.
+	k    uint32
+	mu   sync.RWMutex
+	bits []uint64
+	// dirty is set when keys were added since the filter was persisted.
+	dirty atomic.Bool
+}
+
+func newBloomFilter(opts bloomOptions) *bloomFilter {
+	n := float64(opts.expectedEntries)
+	m := math.Ceil(-n * math.Log(opts.falsePositives) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/n*math.Ln2))
+
+	return &bloomFilter{opts: opts, k: uint32(k), bits: make([]uint64, (uint64(m)+63)/64)}
+}
+
+// positions calls fn with the bits of key, derived from two halves of one
+// hash.
+func (f *bloomFilter) positions(key []byte, fn func(word int, mask uint64) bool) {
+	h := fnv.New64a()
+	h.Write(key)
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	m := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % m
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return
+		}
+	}
+}
+
+func (f *bloomFilter) add(key []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.positions(key, func(word int, mask uint64) bool {
+		f.bits[word] |= mask
+		return true
+	})
+	f.dirty.Store(true)
+}
+
+// mayContain reports whether key may have been added; if not, it certainly
+// wasn't.
+func (f *bloomFilter) mayContain(key []byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	found := true
+	f.positions(key, func(word int, mask uint64) bool {
+		found = f.bits[word]&mask != 0
+		return found
+	})
+
+	return found
+}
+
+// marshal encodes the filter as its options followed by its bits.
+func (f *bloomFilter) marshal() []byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	data := make([]byte, 0, 16+8*len(f.bits))
+	data = binary.BigEndian.AppendUint64(data, uint64(f.opts.expectedEntries))
+	data = binary.BigEndian.AppendUint64(data, math.Float64bits(f.opts.falsePositives))
+	for _, word := range f.bits {
+		data = binary.BigEndian.AppendUint64(data, word)
+	}
+
+	return data
+}
+
+// unmarshal loads the bits of data, encoded by marshal, and reports whether
+// it did: a filter persisted with other options is ignored.
+func (f *bloomFilter) unmarshal(data []byte) bool {
+	if len(data) != 16+8*len(f.bits) ||
+		binary.BigEndian.Uint64(data) != uint64(f.opts.expectedEntries) ||
+		binary.BigEndian.Uint64(data[8:]) != math.Float64bits(f.opts.falsePositives) {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.bits {
+		f.bits[i] = binary.BigEndian.Uint64(data[16+8*i:])
+	}
+	return true
+}
+
+// bloomKey identifies the Bloom filter of a DBRef. Filters belong to an
+// environment rather than a Client, so that all Clients sharing it keep them
+// up to date, and are dropped with it.
+type bloomKey struct {
+	env  backend
+	name string
+}
+
+// bloomFilters holds the loaded Bloom filters by bloomKey.
+var bloomFilters sync.Map
+
+// currentEnv returns the open environment of db, or nil.
+func (db *Client) currentEnv() backend {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.db
+}
+
+// loadedBloom returns the Bloom filter of the DBRef name in the current
+// environment, or nil if it isn't loaded.
+func (db *Client) loadedBloom(name string) *bloomFilter {
+	f, ok := bloomFilters.Load(bloomKey{env: db.currentEnv(), name: name})
+	if !ok {
+		return nil
+	}
+
+	return f.(*bloomFilter)
+}
+
+// loadBloomInTxn loads the Bloom filter of the DBRef name from its persisted
+// copy or, if there is none that matches opts, builds it from the keys.
+func (db *Client) loadBloomInTxn(txn writeTxn, name string, opts bloomOptions) (*bloomFilter, error) {
+	bloomsRef, err := txn.DBRef(bloomsDB, dbCreate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	f := newBloomFilter(opts)
+	data, err := txn.Get(bloomsRef, []byte(name))
+	switch {
+	case err == nil && f.unmarshal(data):
+	case err == nil || errors.Is(err, ErrNotFound):
+		dbRef, err := txn.DBRef(name, dbFlag(0))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		key, _, err := cursor.First()
+		for ; err == nil; key, _, err = cursor.Next() {
+			f.add(key)
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("failed to read keys: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read bloom filter: %w", err)
+	}
+
+	loaded, _ := bloomFilters.LoadOrStore(bloomKey{env: db.currentEnv(), name: name}, f)
+	return loaded.(*bloomFilter), nil
+}
+
+// bloomAddInTxn adds keyBytes, just written to the DBRef name, to its Bloom
+// filter, loading it first if the DBRef has opts and it isn't loaded, and
+// invalidates its persisted copy. Writes that bypass the DBRef call it with
+// nil opts.
+func (db *Client) bloomAddInTxn(txn writeTxn, name string, opts *bloomOptions, keyBytes []byte) error {
+	f := db.loadedBloom(name)
+	if f == nil && opts != nil {
+		var err error
+		f, err = db.loadBloomInTxn(txn, name, *opts)
+		if err != nil {
+			return err
+		}
+	}
+	if f != nil {
+		f.add(keyBytes)
+	}
+
+	bloomsRef, err := txn.DBRef(bloomsDB, dbFlag(0))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	err = txn.Delete(bloomsRef, []byte(name), nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to invalidate bloom filter: %w", err)
+	}
+
+	return nil
+}
+
+// bloomInTxn adds keyBytes, just written to ref, to the Bloom filter of ref,
+// if it has one. Another DBRef of the same name in the process may have one
+// even if ref doesn't.
+func (ref *DBRef[K, V]) bloomInTxn(txn writeTxn, keyBytes []byte) error {
+	if ref.options.bloom == nil && ref.ownerDB.loadedBloom(ref.id) == nil {
+		return nil
+	}
+
+	return ref.ownerDB.bloomAddInTxn(txn, ref.id, ref.options.bloom, keyBytes)
+}
+
+// bloom returns the Bloom filter of ref, loading it if needed, or nil if ref
+// has none.
+func (ref *DBRef[K, V]) bloom() (*bloomFilter, error) {
+	if ref.options.bloom == nil {
+		return nil, nil
+	}
+
+	f := ref.ownerDB.loadedBloom(ref.id)
+	if f != nil {
+		return f, nil
+	}
+	err := ref.ownerDB.update(func(txn writeTxn) error {
+		var err error
+		f, err = ref.ownerDB.loadBloomInTxn(txn, ref.id, *ref.options.bloom)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bloom filter: %w", err)
+	}
+
+	return f, nil
+}
+
+// absent reports whether the Bloom filter of ref, if it has one, rules out
+// keyBytes.
+func (ref *DBRef[K, V]) absent(keyBytes []byte) (bool, error) {
+	f, err := ref.bloom()
+	if err != nil || f == nil {
+		return false, err
+	}
+
+	return !f.mayContain(keyBytes), nil
+}
+
+// initBloom loads the Bloom filter of ref and starts persisting the Client's
+// filters.
+func (ref *DBRef[K, V]) initBloom() error {
+	db := ref.ownerDB
+	if db.readOnly() {
+		return errors.New("bloom filters can't be used with a read-only client")
+	}
+
+	_, err := ref.bloom()
+	if err != nil {
+		return err
+	}
+
+	db.bloomTask.Do(func() {
+		db.startBackground(db.persistBlooms)
+	})
+	return nil
+}
+
+// persistBlooms saves the changed Bloom filters of the environment every
+// bloomPersistInterval.
+func (db *Client) persistBlooms(stop <-chan struct{}) {
+	ticker := time.NewTicker(bloomPersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := db.saveBlooms()
+		if err != nil {
+			db.options.log.Error().Err(err).Msg("failed to persist bloom filters")
+		}
+	}
+}
+
+// saveBlooms persists the changed Bloom filters of the environment in one
+// write transaction, which serializes with the writes adding to them.
+func (db *Client) saveBlooms() error {
+	env := db.currentEnv()
+	if env == nil {
+		return nil
+	}
+
+	var dirty []bloomKey
+	bloomFilters.Range(func(key, f any) bool {
+		if key.(bloomKey).env == env && f.(*bloomFilter).dirty.Load() {
+			dirty = append(dirty, key.(bloomKey))
+		}
+		return true
+	})
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	return db.update(func(txn writeTxn) error {
+		bloomsRef, err := txn.DBRef(bloomsDB, dbCreate)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		for _, key := range dirty {
+			f, ok := bloomFilters.Load(key)
+			if !ok {
+				continue
+			}
+			// If the transaction fails, the copy is missing, which only
+			// makes the next open rebuild the filter.
+			f.(*bloomFilter).dirty.Store(false)
+			err = txn.Put(bloomsRef, []byte(key.name), f.(*bloomFilter).marshal(), putFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to persist bloom filter of %s: %w", key.name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// dropBloomInTxn forgets the Bloom filter of the DBRef name, which is being
+// dropped, and deletes its persisted copy.
+func (db *Client) dropBloomInTxn(txn writeTxn, name string) error {
+	bloomFilters.Delete(bloomKey{env: db.currentEnv(), name: name})
+
+	bloomsRef, err := txn.DBRef(bloomsDB, dbFlag(0))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	err = txn.Delete(bloomsRef, []byte(name), nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete bloom filter: %w", err)
+	}
+
+	return nil
+}
+
+// dropBlooms forgets the Bloom filters of env, which is being closed.
+func dropBlooms(env backend) {
+	bloomFilters.Range(func(key, _ any) bool {
+		if key.(bloomKey).env == env {
+			bloomFilters.Delete(key)
+		}
+		return true
+	})
+}
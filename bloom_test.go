@@ -0,0 +1,145 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestBloomFilter(t *testing.T) {
+	dir := t.TempDir()
+	faults := ezdb.NewFaults()
+	db := openClient(t, dir, ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithBloomFilter(1000, 0.001))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	// Lookups of missing keys don't read the database.
+	reads := faults.Count(ezdb.FaultRead)
+	for _, key := range []string{"missing", "k0100", "k9999"} {
+		_, ok, err := ref.TryGet(&key)
+		if err != nil || ok {
+			t.Fatalf("TryGet of a missing key = %t, %v", ok, err)
+		}
+		_, err = ref.Get(&key)
+		if !errors.Is(err, ezdb.ErrNotFound) {
+			t.Fatalf("Get of a missing key returned %v, want ErrNotFound", err)
+		}
+	}
+	if n := faults.Count(ezdb.FaultRead) - reads; n != 0 {
+		t.Fatalf("lookups of missing keys started %d read transactions", n)
+	}
+	wantN(t, ref, 100)
+
+	// Deleted keys stay in the filter, and are looked up.
+	key := "k0000"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	reads = faults.Count(ezdb.FaultRead)
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) || faults.Count(ezdb.FaultRead) == reads {
+		t.Fatalf("Get of a deleted key returned %v without reading", err)
+	}
+
+	// Writes through a DBRef of the same name without a filter still add to
+	// it.
+	plain, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "plain", "1"
+	err = plain.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != "1" {
+		t.Fatalf("Get of a key put without the filter = %v, %v", got, err)
+	}
+
+	// The filter is persisted when the Client closes, and loaded again.
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	db = openClient(t, dir)
+	ref, err = ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithBloomFilter(1000, 0.001))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key = "k0099"
+	got, err = ref.Get(&key)
+	if err != nil || *got != "v99" {
+		t.Fatalf("Get after reopening = %v, %v", got, err)
+	}
+	key = "plain"
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || !ok {
+		t.Fatalf("TryGet after reopening = %t, %v", ok, err)
+	}
+}
+
+func TestBloomFilterRebuild(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 50)
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A filter added to existing data, or with other options than the
+	// persisted one, is built from the keys.
+	for _, n := range []uint{100, 200} {
+		db = openClient(t, dir)
+		ref, err = ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithBloomFilter(n, 0.01))
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		wantN(t, ref, 50)
+		err = db.Close()
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+}
+
+func TestWithBloomFilterErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	for _, opt := range []ezdb.RefOption{
+		ezdb.WithBloomFilter(0, 0.01),
+		ezdb.WithBloomFilter(10, 0),
+		ezdb.WithBloomFilter(10, 1),
+	} {
+		_, err := ezdb.NewDBRef[string, string](db, "ref", opt)
+		if err == nil {
+			t.Error("NewDBRef with an invalid Bloom filter succeeded")
+		}
+	}
+
+	dir := t.TempDir()
+	db = openClient(t, dir)
+	_, err := ezdb.NewDBRef[string, string](db, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	readOnly := openClient(t, dir, ezdb.WithReadOnly())
+	_, err = ezdb.NewDBRef[string, string](readOnly, "ref", ezdb.WithBloomFilter(10, 0.01))
+	if err == nil {
+		t.Fatal("NewDBRef with a Bloom filter on a read-only Client succeeded")
+	}
+}
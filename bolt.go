@@ -0,0 +1,569 @@
+package ezdb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// boltFileName is the name of the bbolt file in a Client's directory.
+	boltFileName = "data.bolt"
+	// boltFlagsBucket records the flags each named database was created
+	// with, by name.
+	boltFlagsBucket = "ezdb.bolt.flags"
+	// boltLockTimeout bounds how long opening waits for another process to
+	// release the file.
+	boltLockTimeout = 5 * time.Second
+)
+
+// NewBolt returns a Client that keeps its named databases in a bbolt file in
+// the directory path instead of an LMDB environment. bbolt is pure Go, so
+// programs using only NewBolt and NewMemory build without cgo and
+// cross-compile like any other Go program, at the cost of LMDB's write
+// throughput; the DBRef API is the same.
+//
+// bbolt locks its file exclusively, so unlike LMDB only one process can have
+// it open at a time, unless all of them use WithReadOnly. A write that grows
+// the file waits for the read transactions open at the time, so a function
+// passed to ViewAll must not wait for a write. Backup, CompactTo,
+// Stats and ReaderCheck work on LMDB's files and fail with an error
+// matching ErrUnsupported, see Capabilities, and options that tune LMDB, such
+// as WithNumReaders, have no effect.
+func NewBolt(path string, opts ...Option) (*Client, error) {
+	opts = append(opts, func(option *options) error {
+		option.engine = EngineBolt
+		option.openBackend = func(path string) (backend, error) {
+			return openBolt(path, option.envFlags&envReadOnly != 0)
+		}
+		return nil
+	})
+
+	return New(path, opts...)
+}
+
+// boltBackend is the backend of NewBolt. A named database is a bucket; a
+// dbDupSort database stores each of its pairs as a key of its own, see
+// dupKey, as bbolt keys are unique.
+type boltBackend struct {
+	db *bolt.DB
+
+	// ids and names map the names of buckets to the dbis handed out for
+	// them, which stay valid across transactions.
+	mu    sync.Mutex
+	ids   map[string]dbi
+	names map[dbi]string
+}
+
+func openBolt(dir string, readOnly bool) (*boltBackend, error) {
+	db, err := bolt.Open(filepath.Join(dir, boltFileName), mode, &bolt.Options{
+		Timeout:  boltLockTimeout,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	if !readOnly {
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(boltFlagsBucket))
+			return err
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create database flags: %w", err)
+		}
+	}
+
+	return &boltBackend{
+		db:    db,
+		ids:   make(map[string]dbi),
+		names: make(map[dbi]string),
+	}, nil
+}
+
+func (b *boltBackend) View(fn func(txn readTxn) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return fn(b.newTxn(tx))
+	})
+}
+
+func (b *boltBackend) Update(fn func(txn writeTxn) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return fn(b.newTxn(tx))
+	})
+}
+
+func (b *boltBackend) newTxn(tx *bolt.Tx) *boltTxn {
+	return &boltTxn{b: b, tx: tx, buckets: make(map[dbi]boltBucket)}
+}
+
+func (b *boltBackend) Sync(force bool) error {
+	return b.db.Sync()
+}
+
+func (b *boltBackend) Copy(path string, compact bool) error {
+	return fmt.Errorf("failed to copy bolt environment: %w", ErrUnsupported)
+}
+
+func (b *boltBackend) Close() {
+	b.db.Close()
+}
+
+// id returns the dbi of the bucket name.
+func (b *boltBackend) id(name string) dbi {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id, ok := b.ids[name]
+	if !ok {
+		id = dbi(len(b.ids) + 1)
+		b.ids[name] = id
+		b.names[id] = name
+	}
+
+	return id
+}
+
+func (b *boltBackend) name(id dbi) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name, ok := b.names[id]
+	return name, ok
+}
+
+// boltTxn is a transaction on a boltBackend, caching the buckets it opened.
+type boltTxn struct {
+	b       *boltBackend
+	tx      *bolt.Tx
+	buckets map[dbi]boltBucket
+}
+
+type boltBucket struct {
+	*bolt.Bucket
+	dup bool
+}
+
+func (t *boltTxn) DBRef(name string, flags dbFlag) (dbi, error) {
+	if name == boltFlagsBucket {
+		return 0, fmt.Errorf("failed to open database %q: name is reserved", name)
+	}
+	id := t.b.id(name)
+	if _, ok := t.buckets[id]; ok {
+		return id, nil
+	}
+
+	bucket := t.tx.Bucket([]byte(name))
+	if bucket == nil {
+		if !t.tx.Writable() || flags&dbCreate == 0 {
+			return 0, fmt.Errorf("failed to open database %q: %w", name, ErrNotFound)
+		}
+
+		if flags&lmdbOnlyDBFlags != 0 {
+			return 0, fmt.Errorf("failed to create database %q with flags %#x: %w", name, flags, ErrUnsupported)
+		}
+		var err error
+		bucket, err = t.tx.CreateBucket([]byte(name))
+		if err != nil {
+			return 0, fmt.Errorf("failed to create database %q: %w", name, err)
+		}
+		if flags&dbDupSort != 0 {
+			err = t.tx.Bucket([]byte(boltFlagsBucket)).Put([]byte(name), []byte{1})
+			if err != nil {
+				return 0, fmt.Errorf("failed to record flags of database %q: %w", name, err)
+			}
+		}
+	}
+
+	dup := false
+	if flagsBucket := t.tx.Bucket([]byte(boltFlagsBucket)); flagsBucket != nil {
+		dup = flagsBucket.Get([]byte(name)) != nil
+	}
+	t.buckets[id] = boltBucket{Bucket: bucket, dup: dup}
+	return id, nil
+}
+
+func (t *boltTxn) ListDBs() ([]namedDB, error) {
+	flagsBucket := t.tx.Bucket([]byte(boltFlagsBucket))
+
+	var dbs []namedDB
+	err := t.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if string(name) == boltFlagsBucket {
+			return nil
+		}
+		db := namedDB{name: string(name)}
+		if flagsBucket != nil && flagsBucket.Get(name) != nil {
+			db.flags = dbDupSort
+		}
+		dbs = append(dbs, db)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+
+	return dbs, nil
+}
+
+func (t *boltTxn) bucket(db dbi) (boltBucket, error) {
+	bucket, ok := t.buckets[db]
+	if ok {
+		return bucket, nil
+	}
+
+	// The dbi was handed out by an earlier transaction.
+	name, ok := t.b.name(db)
+	if !ok {
+		return boltBucket{}, fmt.Errorf("invalid database %d", db)
+	}
+	_, err := t.DBRef(name, 0)
+	if err != nil {
+		return boltBucket{}, err
+	}
+
+	return t.buckets[db], nil
+}
+
+func (t *boltTxn) Get(db dbi, key []byte) ([]byte, error) {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if bucket.dup {
+		return newBoltCursor(bucket).SeekExactKey(key)
+	}
+
+	val := bucket.Get(key)
+	if val == nil {
+		return nil, ErrNotFound
+	}
+	return val, nil
+}
+
+func (t *boltTxn) NewCursor(db dbi) (dbCursor, error) {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBoltCursor(bucket), nil
+}
+
+func (t *boltTxn) Put(db dbi, key, val []byte, flags putFlag) error {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return err
+	}
+	err = t.checkPut(bucket, db, key, val, flags)
+	if err != nil {
+		return err
+	}
+
+	// bbolt keeps the slices until the transaction ends, and callers may
+	// reuse theirs.
+	if bucket.dup {
+		return bucket.Put(dupKey(key, val), []byte{})
+	}
+	return bucket.Put(append([]byte(nil), key...), append([]byte{}, val...))
+}
+
+func (t *boltTxn) PutReserve(db dbi, key []byte, size int, flags putFlag) ([]byte, error) {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.dup {
+		return nil, fmt.Errorf("failed to reserve value of dupsort db: %w", ErrUnsupported)
+	}
+	err = t.checkPut(bucket, db, key, nil, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	// bbolt only reads values when the transaction commits, so val can be
+	// filled in after the Put.
+	val := make([]byte, size)
+	err = bucket.Put(append([]byte(nil), key...), val)
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// checkPut checks a Put of val under key into bucket, the bucket of db,
+// against flags.
+func (t *boltTxn) checkPut(bucket boltBucket, db dbi, key, val []byte, flags putFlag) error {
+	if flags&(putNoOverwrite|putNoDupData) != 0 {
+		_, err := t.Get(db, key)
+		if err == nil && flags&putNoOverwrite != 0 {
+			return ErrKeyExists
+		}
+		if err == nil && bucket.dup && flags&putNoDupData != 0 && bucket.Get(dupKey(key, val)) != nil {
+			return ErrKeyExists
+		}
+	}
+
+	if flags&putAppend != 0 {
+		next := key
+		if bucket.dup {
+			next = dupKey(key, val)
+		}
+		last, _ := bucket.Cursor().Last()
+		if last != nil && bytes.Compare(last, next) >= 0 {
+			return ErrKeyExists
+		}
+		// Nothing will be inserted before appended keys, so their pages can
+		// be filled completely when they split.
+		bucket.FillPercent = 1
+	}
+
+	return nil
+}
+
+func (t *boltTxn) Delete(db dbi, key, val []byte) error {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return err
+	}
+
+	if !bucket.dup {
+		if bucket.Get(key) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(key)
+	}
+
+	if val != nil {
+		k := dupKey(key, val)
+		if bucket.Get(k) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(k)
+	}
+
+	prefix := dupKey(key, nil)
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	if len(keys) == 0 {
+		return ErrNotFound
+	}
+	for _, k := range keys {
+		err = bucket.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *boltTxn) Empty(db dbi) error {
+	bucket, err := t.bucket(db)
+	if err != nil {
+		return err
+	}
+	name, _ := t.b.name(db)
+
+	err = t.tx.DeleteBucket([]byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to empty database %q: %w", name, err)
+	}
+	newBucket, err := t.tx.CreateBucket([]byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to empty database %q: %w", name, err)
+	}
+
+	t.buckets[db] = boltBucket{Bucket: newBucket, dup: bucket.dup}
+	return nil
+}
+
+func (t *boltTxn) Drop(db dbi) error {
+	_, err := t.bucket(db)
+	if err != nil {
+		return err
+	}
+	name, _ := t.b.name(db)
+
+	err = t.tx.DeleteBucket([]byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to drop database %q: %w", name, err)
+	}
+	err = t.tx.Bucket([]byte(boltFlagsBucket)).Delete([]byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to drop flags of database %q: %w", name, err)
+	}
+
+	delete(t.buckets, db)
+	return nil
+}
+
+// dupKey encodes a pair of a dbDupSort database as a bbolt key that sorts by
+// key and then by value: the key with every 0x00 escaped as 0x00 0xff, the
+// terminator 0x00 0x00 and the value. With a nil val it is the prefix of the
+// keys of all pairs with key.
+func dupKey(key, val []byte) []byte {
+	k := make([]byte, 0, len(key)+2+len(val))
+	for _, b := range key {
+		k = append(k, b)
+		if b == 0 {
+			k = append(k, 0xff)
+		}
+	}
+	k = append(k, 0, 0)
+
+	return append(k, val...)
+}
+
+// splitDupKey decodes a key made by dupKey.
+func splitDupKey(k []byte) (key, val []byte) {
+	key = make([]byte, 0, len(k))
+	for i := 0; i < len(k); i++ {
+		if k[i] != 0 {
+			key = append(key, k[i])
+			continue
+		}
+		if i+1 < len(k) && k[i+1] == 0 {
+			return key, k[i+2:]
+		}
+		key = append(key, 0)
+		i++
+	}
+
+	return key, nil
+}
+
+// boltCursor is a cursor on a bucket. It remembers the bbolt key it is on
+// and seeks back to it before every move, since bbolt cursors don't survive
+// writes to their bucket, and ezdb writes while iterating.
+type boltCursor struct {
+	c   *bolt.Cursor
+	dup bool
+	// cur is the bbolt key the cursor is on, or nil if it isn't on one.
+	cur []byte
+}
+
+func newBoltCursor(bucket boltBucket) *boltCursor {
+	return &boltCursor{c: bucket.Cursor(), dup: bucket.dup}
+}
+
+// moveTo positions the cursor on the bbolt entry k, v, or unpositions it if
+// k is nil, and returns the pair it holds.
+func (c *boltCursor) moveTo(k, v []byte) ([]byte, []byte, error) {
+	if k == nil {
+		c.cur = nil
+		return nil, nil, ErrNotFound
+	}
+
+	c.cur = append(c.cur[:0], k...)
+	if c.dup {
+		key, val := splitDupKey(k)
+		return key, val, nil
+	}
+	return k, v, nil
+}
+
+func (c *boltCursor) First() ([]byte, []byte, error) {
+	return c.moveTo(c.c.First())
+}
+
+func (c *boltCursor) Last() ([]byte, []byte, error) {
+	return c.moveTo(c.c.Last())
+}
+
+func (c *boltCursor) Next() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.First()
+	}
+
+	k, v := c.c.Seek(c.cur)
+	if bytes.Equal(k, c.cur) {
+		k, v = c.c.Next()
+	}
+	return c.moveTo(k, v)
+}
+
+func (c *boltCursor) Prev() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.Last()
+	}
+
+	k, _ := c.c.Seek(c.cur)
+	if k == nil {
+		return c.moveTo(c.c.Last())
+	}
+	return c.moveTo(c.c.Prev())
+}
+
+func (c *boltCursor) NextInSameKey() ([]byte, []byte, error) {
+	if c.cur == nil || !c.dup {
+		return nil, nil, ErrNotFound
+	}
+
+	key, _ := splitDupKey(c.cur)
+	prefix := dupKey(key, nil)
+	k, v := c.c.Seek(c.cur)
+	if bytes.Equal(k, c.cur) {
+		k, v = c.c.Next()
+	}
+	if k == nil || !bytes.HasPrefix(k, prefix) {
+		return nil, nil, ErrNotFound
+	}
+	return c.moveTo(k, v)
+}
+
+func (c *boltCursor) Count() (uint64, error) {
+	if c.cur == nil {
+		return 0, ErrNotFound
+	}
+	if !c.dup {
+		return 1, nil
+	}
+
+	key, _ := splitDupKey(c.cur)
+	prefix := dupKey(key, nil)
+	n := uint64(0)
+	for k, _ := c.c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.c.Next() {
+		n++
+	}
+
+	return n, nil
+}
+
+func (c *boltCursor) SeekExactKey(key []byte) ([]byte, error) {
+	if c.dup {
+		prefix := dupKey(key, nil)
+		k, v := c.c.Seek(prefix)
+		if k == nil || !bytes.HasPrefix(k, prefix) {
+			return nil, ErrNotFound
+		}
+		_, val, err := c.moveTo(k, v)
+		return val, err
+	}
+
+	k, v := c.c.Seek(key)
+	if k == nil || !bytes.Equal(k, key) {
+		return nil, ErrNotFound
+	}
+	_, val, err := c.moveTo(k, v)
+	return val, err
+}
+
+func (c *boltCursor) SeekGreaterThanOrEqualKey(key []byte) ([]byte, []byte, error) {
+	if c.dup {
+		return c.moveTo(c.c.Seek(dupKey(key, nil)))
+	}
+
+	return c.moveTo(c.c.Seek(key))
+}
+
+func (c *boltCursor) Close() {}
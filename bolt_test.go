@@ -0,0 +1,158 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// newBoltClient returns an initialized Client from NewBolt in dir, closed
+// when t finishes.
+func newBoltClient(t *testing.T, dir string, opts ...Option) *Client {
+	t.Helper()
+
+	db, err := NewBolt(dir, opts...)
+	if err != nil {
+		t.Fatalf("NewBolt: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestBoltBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T, dir string) *Client {
+		return newBoltClient(t, dir)
+	})
+}
+
+func TestDupKey(t *testing.T) {
+	pairs := [][2]string{
+		{"a", ""},
+		{"a", "\x00"},
+		{"a", "b"},
+		{"a", "\xff\xff"},
+		{"a\x00", ""},
+		{"a\x00", "\x00\x00"},
+		{"a\x00b", "c"},
+		{"ab", ""},
+		{"a\xff", "a"},
+		{"b", "a\x00"},
+	}
+	keys := make([][]byte, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = dupKey([]byte(pair[0]), []byte(pair[1]))
+		key, val := splitDupKey(keys[i])
+		if string(key) != pair[0] || string(val) != pair[1] {
+			t.Errorf("splitDupKey(dupKey(%q, %q)) = %q, %q", pair[0], pair[1], key, val)
+		}
+		if !bytes.HasPrefix(keys[i], dupKey([]byte(pair[0]), nil)) {
+			t.Errorf("dupKey(%q, %q) lacks the prefix of its key", pair[0], pair[1])
+		}
+	}
+
+	// The encoded pairs sort by key and then by value, like LMDB's.
+	if !sort.SliceIsSorted(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 }) {
+		t.Fatalf("dupKey doesn't preserve the order of %q", pairs)
+	}
+}
+
+func TestBoltCursorSurvivesWrites(t *testing.T) {
+	db := newBoltClient(t, t.TempDir())
+	env, release, err := db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	var kv []string
+	for i := 0; i < 1000; i++ {
+		kv = append(kv, fmt.Sprintf("k%04d", i), "v")
+	}
+	put(t, env, "db", 0, kv...)
+
+	// Deleting every entry while iterating, as Clear does, still visits all
+	// of them.
+	n := 0
+	err = env.Update(func(txn writeTxn) error {
+		db, err := txn.DBRef("db", 0)
+		if err != nil {
+			return err
+		}
+		c, err := txn.NewCursor(db)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		for key, _, err := c.First(); err == nil; key, _, err = c.Next() {
+			if want := fmt.Sprintf("k%04d", n); string(key) != want {
+				return fmt.Errorf("entry %d is %q, want %s", n, key, want)
+			}
+			err = txn.Delete(db, key, nil)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(db, append([]byte("new"), key...), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+			n++
+			if n == 1000 {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil || n != 1000 {
+		t.Fatalf("Update visited %d entries: %v", n, err)
+	}
+}
+
+func TestNewBolt(t *testing.T) {
+	dir := t.TempDir()
+	db := newBoltClient(t, dir)
+	ref, err := NewDBRef[string, string](db, "ref", WithKeyCodec(StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "a", "1"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err = db.Stats()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Stats returned %v, want ErrUnsupported", err)
+	}
+	err = db.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(boltFlagsBucket, dbCreate)
+		return err
+	})
+	if err == nil {
+		t.Errorf("DBRef of the flags bucket succeeded")
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The file can be opened read-only once the writer is gone.
+	db = newBoltClient(t, dir, WithReadOnly())
+	ref, err = NewDBRef[string, string](db, "ref", WithKeyCodec(StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != "1" {
+		t.Fatalf("Get of a read-only Client = %v, %v", got, err)
+	}
+	err = ref.Put(&key, &val)
+	if err == nil {
+		t.Fatal("Put into a read-only Client succeeded")
+	}
+}
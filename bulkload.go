@@ -0,0 +1,96 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BulkLoadOptions configure BulkLoad.
+type BulkLoadOptions struct {
+	// ChunkSize is the number of entries written per transaction. The
+	// default is 1024.
+	ChunkSize int
+	// Progress, if set, is called after every committed chunk with the
+	// number of entries loaded so far.
+	Progress func(loaded int)
+}
+
+// BulkLoad writes the entries returned by next, until it returns io.EOF, to
+// ref in chunks, each in its own transaction. It is meant for the initial
+// ingestion of large data sets: the encoded keys must come in strictly
+// ascending order, and after every key already stored, which lets LMDB
+// append each entry to the last page instead of searching the tree for it.
+// KeyCodecs that preserve order, such as OrderedCodec, make the encoded order
+// that of the keys.
+//
+// A key out of order fails BulkLoad with an error matching ErrNotSorted. The
+// writes go through the same validation, indexes, triggers and quotas as
+// Put. If BulkLoad fails, the chunks before the failing one stay written, and
+// loaded counts them.
+func (ref *DBRef[K, V]) BulkLoad(next func() (*K, *V, error), opts BulkLoadOptions) (loaded int, err error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = copyChunkSize
+	}
+
+	type pending struct {
+		n                  int
+		keyBytes, valBytes []byte
+		val                *V
+	}
+	chunk := make([]pending, 0, opts.ChunkSize)
+	var last []byte
+	for n := 1; ; n++ {
+		key, val, err := next()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return loaded, fmt.Errorf("failed to read entry %d: %w", n, err)
+		}
+		if err == nil {
+			keyBytes, valBytes, err := ref.encodePair(key, val)
+			if err != nil {
+				return loaded, fmt.Errorf("invalid entry %d: %w", n, err)
+			}
+			if last != nil && bytes.Compare(keyBytes, last) <= 0 {
+				return loaded, fmt.Errorf("entry %d: %w", n, ErrNotSorted)
+			}
+			last = keyBytes
+			chunk = append(chunk, pending{n, keyBytes, valBytes, val})
+			if len(chunk) < opts.ChunkSize {
+				continue
+			}
+		}
+		if len(chunk) == 0 {
+			return loaded, nil
+		}
+
+		err = ref.ownerDB.update(func(txn writeTxn) error {
+			dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+			if err != nil {
+				return fmt.Errorf("failed to get db ref: %w", err)
+			}
+
+			for _, p := range chunk {
+				err = ref.putInTxn(txn, dbRef, p.keyBytes, p.valBytes, p.val, putAppend)
+				if errors.Is(err, ErrKeyExists) {
+					// LMDB rejects appending keys before the last stored one
+					// as existing.
+					return fmt.Errorf("entry %d is not after the stored keys: %w", p.n, ErrNotSorted)
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return loaded, err
+		}
+		loaded += len(chunk)
+		chunk = chunk[:0]
+		if opts.Progress != nil {
+			opts.Progress(loaded)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// entries returns a BulkLoad source of the keys and values putN stores, from
+// from up to but excluding to.
+func entries(from, to int) func() (*string, *string, error) {
+	i := from
+	return func() (*string, *string, error) {
+		if i >= to {
+			return nil, nil, io.EOF
+		}
+		key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+		i++
+		return &key, &val, nil
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			ref, err := ezdb.NewDBRef[string, string](e.open(t), "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+
+			var progress []int
+			loaded, err := ref.BulkLoad(entries(0, 250), ezdb.BulkLoadOptions{
+				ChunkSize: 100,
+				Progress:  func(loaded int) { progress = append(progress, loaded) },
+			})
+			if err != nil || loaded != 250 {
+				t.Fatalf("BulkLoad = %d, %v", loaded, err)
+			}
+			if fmt.Sprint(progress) != "[100 200 250]" {
+				t.Fatalf("progress reported %v", progress)
+			}
+			wantN(t, ref, 250)
+
+			// Later loads append after the stored keys.
+			loaded, err = ref.BulkLoad(entries(250, 300), ezdb.BulkLoadOptions{})
+			if err != nil || loaded != 50 {
+				t.Fatalf("second BulkLoad = %d, %v", loaded, err)
+			}
+			wantN(t, ref, 300)
+
+			// Keys before the stored ones fail with ErrNotSorted, and so do
+			// their chunks.
+			loaded, err = ref.BulkLoad(entries(10, 20), ezdb.BulkLoadOptions{ChunkSize: 5})
+			if !errors.Is(err, ezdb.ErrNotSorted) || loaded != 0 {
+				t.Fatalf("BulkLoad of stored keys = %d, %v, want ErrNotSorted", loaded, err)
+			}
+			wantN(t, ref, 300)
+
+			loaded, err = ref.BulkLoad(entries(0, 0), ezdb.BulkLoadOptions{})
+			if err != nil || loaded != 0 {
+				t.Fatalf("BulkLoad of nothing = %d, %v", loaded, err)
+			}
+		})
+	}
+}
+
+func TestBulkLoadErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithValidator(func(key, val *string) error {
+		if *key == "k0015" {
+			return errors.New("invalid")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// The chunks before the failing entry stay written.
+	loaded, err := ref.BulkLoad(entries(0, 20), ezdb.BulkLoadOptions{ChunkSize: 10})
+	if err == nil || loaded != 10 {
+		t.Fatalf("BulkLoad with an invalid entry = %d, %v", loaded, err)
+	}
+	wantN(t, ref, 10)
+
+	// Keys out of order in the input fail before anything is written.
+	keys := []string{"k0020", "k0022", "k0021"}
+	loaded, err = ref.BulkLoad(func() (*string, *string, error) {
+		if len(keys) == 0 {
+			return nil, nil, io.EOF
+		}
+		key, val := keys[0], "v"
+		keys = keys[1:]
+		return &key, &val, nil
+	}, ezdb.BulkLoadOptions{})
+	if !errors.Is(err, ezdb.ErrNotSorted) || loaded != 0 {
+		t.Fatalf("BulkLoad of unsorted keys = %d, %v, want ErrNotSorted", loaded, err)
+	}
+
+	errRead := errors.New("read")
+	next := entries(10, 15)
+	loaded, err = ref.BulkLoad(func() (*string, *string, error) {
+		key, val, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errRead
+		}
+		return key, val, err
+	}, ezdb.BulkLoadOptions{ChunkSize: 2})
+	if !errors.Is(err, errRead) || loaded != 4 {
+		t.Fatalf("BulkLoad with a failing source = %d, %v, want the error of next", loaded, err)
+	}
+	wantN(t, ref, 14)
+}
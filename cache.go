@@ -0,0 +1,154 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cache is a persistent read-through cache of values of type V, stored in a
+// named database along with the time they were loaded.
+//
+// A value is fresh for ttl after it was loaded and returned as is. For
+// staleFor after that it is stale: it is still returned, but reloaded in the
+// background. Older values, and missing ones, are loaded before Get returns.
+// Concurrent Gets of the same key in this process share one load.
+type Cache[K, V any] struct {
+	ref      *DBRef[K, cacheEntry[V]]
+	ttl      time.Duration
+	staleFor time.Duration
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// cacheEntry is a cached value and the time it was loaded at.
+type cacheEntry[V any] struct {
+	Value    V
+	LoadedAt time.Time
+}
+
+// NewCache opens the cache stored in the named database name of db, creating
+// it if it doesn't exist. opts are the same as for NewDBRef.
+func NewCache[K, V any](db *Client, name string, ttl, staleFor time.Duration, opts ...RefOption) (*Cache[K, V], error) {
+	if ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+	if staleFor < 0 {
+		return nil, errors.New("stale period must not be negative")
+	}
+
+	ref, err := NewDBRef[K, cacheEntry[V]](db, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache[K, V]{
+		ref:        ref,
+		ttl:        ttl,
+		staleFor:   staleFor,
+		refreshing: make(map[string]bool),
+	}, nil
+}
+
+// Get returns the value cached under key, calling loader to load it if it
+// is missing or no longer fresh. Errors of background reloads are logged and
+// leave the stale value in place.
+func (c *Cache[K, V]) Get(key K, loader func(K) (V, error)) (V, error) {
+	var zero V
+
+	entry, ok, err := c.ref.TryGet(&key)
+	if err != nil {
+		return zero, err
+	}
+	if ok {
+		age := time.Since(entry.LoadedAt)
+		if age < c.ttl {
+			return entry.Value, nil
+		}
+		if age < c.ttl+c.staleFor {
+			c.refresh(key, loader)
+			return entry.Value, nil
+		}
+	}
+
+	entry, err = c.load(key, loader)
+	if err != nil {
+		return zero, err
+	}
+
+	return entry.Value, nil
+}
+
+// Invalidate removes the value cached under key, so the next Get loads it.
+func (c *Cache[K, V]) Invalidate(key K) error {
+	err := c.ref.Delete(&key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// load calls loader and stores its result, unless another caller loaded a
+// fresh value while this one waited for its turn.
+func (c *Cache[K, V]) load(key K, loader func(K) (V, error)) (*cacheEntry[V], error) {
+	keyBytes, err := c.ref.encodeKey(&key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	unlock := c.ref.ownerDB.keyLocks.lock(c.ref.id + "\x00" + string(keyBytes))
+	defer unlock()
+
+	entry, ok, err := c.ref.TryGet(&key)
+	if err != nil {
+		return nil, err
+	}
+	if ok && time.Since(entry.LoadedAt) < c.ttl {
+		return entry, nil
+	}
+
+	val, err := loader(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load value: %w", err)
+	}
+
+	entry = &cacheEntry[V]{Value: val, LoadedAt: time.Now()}
+	err = c.ref.Put(&key, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// refresh reloads the value under key in the background, unless a refresh of
+// it is already running.
+func (c *Cache[K, V]) refresh(key K, loader func(K) (V, error)) {
+	keyBytes, err := c.ref.encodeKey(&key)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	if c.refreshing[string(keyBytes)] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[string(keyBytes)] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, string(keyBytes))
+			c.mu.Unlock()
+		}()
+
+		_, err := c.load(key, loader)
+		if err != nil {
+			c.ref.ownerDB.options.log.Error().Err(err).Str("db", c.ref.id).Msg("failed to refresh cached value")
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package ezdb_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestCache(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c, err := ezdb.NewCache[string, int](db, "cache", time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	var loads atomic.Int32
+	loader := func(key string) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return int(loads.Add(1)), nil
+	}
+
+	// Concurrent misses share one load.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := c.Get("k", loader)
+			if err != nil || val != 1 {
+				t.Errorf("Get = %d, %v, want 1", val, err)
+			}
+		}()
+	}
+	wg.Wait()
+	val, err := c.Get("k", loader)
+	if err != nil || val != 1 || loads.Load() != 1 {
+		t.Fatalf("Get of a fresh value = %d, %v after %d loads", val, err, loads.Load())
+	}
+
+	err = c.Invalidate("k")
+	if err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	err = c.Invalidate("k")
+	if err != nil {
+		t.Fatalf("Invalidate of a missing key: %v", err)
+	}
+	val, err = c.Get("k", loader)
+	if err != nil || val != 2 {
+		t.Fatalf("Get after Invalidate = %d, %v, want 2", val, err)
+	}
+
+	failed := errors.New("failed")
+	_, err = c.Get("other", func(string) (int, error) { return 0, failed })
+	if !errors.Is(err, failed) {
+		t.Fatalf("Get with a failing loader returned %v", err)
+	}
+
+	for _, periods := range [][2]time.Duration{{0, 0}, {time.Second, -time.Second}} {
+		_, err = ezdb.NewCache[string, int](db, "bad", periods[0], periods[1])
+		if err == nil {
+			t.Errorf("NewCache(%v, %v) succeeded", periods[0], periods[1])
+		}
+	}
+}
+
+func TestCacheStaleValues(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c, err := ezdb.NewCache[string, int](db, "cache", 20*time.Millisecond, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	expiring, err := ezdb.NewCache[string, int](db, "expiring", 20*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	var loads atomic.Int32
+	loader := func(key string) (int, error) {
+		return int(loads.Add(1)), nil
+	}
+	for _, c := range []*ezdb.Cache[string, int]{c, expiring} {
+		_, err = c.Get("k", loader)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Stale values are returned and reloaded in the background.
+	val, err := c.Get("k", loader)
+	if err != nil || val != 1 {
+		t.Fatalf("Get of a stale value = %d, %v, want 1", val, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		val, err = c.Get("k", loader)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if val != 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale value wasn't reloaded")
+		}
+	}
+
+	// Values past their stale period are loaded before Get returns.
+	before := int(loads.Load())
+	val, err = expiring.Get("k", loader)
+	if err != nil || val != before+1 {
+		t.Fatalf("Get of an expired value = %d, %v, want %d", val, err, before+1)
+	}
+}
@@ -0,0 +1,281 @@
+// Package cdc publishes the changes made to an ezdb DBRef to a message
+// broker such as NATS or Kafka, so other systems can mirror its contents.
+//
+// Changes are read from the audit log of the Client, see ezdb.WithAuditLog,
+// and published in order with at-least-once delivery: the position of the
+// last published change is persisted in the Client after every publish, and
+// a restarted Bridge resumes after it. Changes the audit log leaves out, such
+// as dropping or clearing the DBRef's database or copying into it with
+// Client.CopyDB, are not published.
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// cursorsDB is the named database the cursors of all Bridges are stored in.
+const cursorsDB = "ezdb.cdc"
+
+// Publisher sends a message to a broker. It must only return once the broker
+// has accepted the message. Package cdcnats has one for NATS JetStream; for
+// other brokers adapters are usually one-liners, e.g. for Kafka with
+// segmentio/kafka-go:
+//
+//	cdc.PublisherFunc(func(ctx context.Context, subject string, data []byte) error {
+//		return w.WriteMessages(ctx, kafka.Message{Topic: subject, Value: data})
+//	})
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, subject string, data []byte) error
+
+func (f PublisherFunc) Publish(ctx context.Context, subject string, data []byte) error {
+	return f(ctx, subject, data)
+}
+
+// Event is a published change.
+type Event[K, V any] struct {
+	// Seq is the position of the change in the audit log. Consumers can use
+	// it to discard duplicates.
+	Seq   uint64 `json:"seq"`
+	DBRef string `json:"dbref"`
+	// Op is "put" or "delete".
+	Op  string `json:"op"`
+	Key K      `json:"key"`
+	// Value is the value stored under Key when the change is published,
+	// which is newer than the change itself if Key was written again since.
+	// It is nil for deletes.
+	Value *V        `json:"value,omitempty"`
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor,omitempty"`
+}
+
+type Option func(option *options) error
+
+type options struct {
+	subject      string
+	pollInterval time.Duration
+	batchSize    int
+	marshal      func(v any) ([]byte, error)
+}
+
+// WithSubject sets the subject, or topic, events are published to. The
+// default is "ezdb." followed by the name of the DBRef.
+func WithSubject(subject string) Option {
+	return func(option *options) error {
+		option.subject = subject
+		return nil
+	}
+}
+
+// WithPollInterval sets how often the audit log is checked for changes made
+// by other processes. Changes made by this process are published right away.
+// The default is one second.
+func WithPollInterval(interval time.Duration) Option {
+	return func(option *options) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		option.pollInterval = interval
+		return nil
+	}
+}
+
+// WithBatchSize sets how many audit records are read per read transaction.
+// The default is 256.
+func WithBatchSize(batchSize int) Option {
+	return func(option *options) error {
+		if batchSize <= 0 {
+			return errors.New("batch size must be positive")
+		}
+		option.batchSize = batchSize
+		return nil
+	}
+}
+
+// WithMarshal sets the function events are serialized with. The default is
+// json.Marshal.
+func WithMarshal(marshal func(v any) ([]byte, error)) Option {
+	return func(option *options) error {
+		option.marshal = marshal
+		return nil
+	}
+}
+
+// Bridge publishes the changes of one DBRef.
+type Bridge[K, V any] struct {
+	name    string
+	db      *ezdb.Client
+	ref     *ezdb.DBRef[K, V]
+	pub     Publisher
+	cursors *ezdb.DBRef[string, uint64]
+	options *options
+}
+
+// New returns a Bridge publishing the changes to ref, a DBRef of db, to pub.
+// name identifies the Bridge's cursor, so it must be unique per Client and
+// stay the same across restarts. The cursors are stored in their own named
+// database, which counts towards ezdb.WithNumDBs.
+func New[K, V any](db *ezdb.Client, name string, ref *ezdb.DBRef[K, V], pub Publisher, opts ...Option) (*Bridge[K, V], error) {
+	o := &options{
+		subject:      "ezdb." + ref.Name(),
+		pollInterval: time.Second,
+		batchSize:    256,
+		marshal:      json.Marshal,
+	}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	cursors, err := ezdb.NewDBRef[string, uint64](db, cursorsDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cursors: %w", err)
+	}
+
+	return &Bridge[K, V]{
+		name:    name,
+		db:      db,
+		ref:     ref,
+		pub:     pub,
+		cursors: cursors,
+		options: o,
+	}, nil
+}
+
+// Cursor returns the sequence number of the last audit record the Bridge has
+// processed, or 0 if it hasn't processed any.
+func (b *Bridge[K, V]) Cursor() (uint64, error) {
+	cursor, _, err := b.cursors.TryGetValue(b.name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cursor: %w", err)
+	}
+
+	return cursor, nil
+}
+
+// Run publishes changes until ctx is done or publishing fails, and returns
+// the error. Calling Run again resumes after the last change that was
+// published successfully, so the change that failed is retried.
+func (b *Bridge[K, V]) Run(ctx context.Context) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wake, err := b.ref.Watch(watchCtx, ezdb.WatchOptions{Buffer: 1})
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(b.options.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		more, err := b.publishBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if more {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+var errBatchFull = errors.New("batch full")
+
+// publishBatch publishes the changes of the next batch of audit records and
+// reports whether there may be more.
+func (b *Bridge[K, V]) publishBatch(ctx context.Context) (more bool, err error) {
+	cursor, err := b.Cursor()
+	if err != nil {
+		return false, err
+	}
+
+	var recs []*ezdb.AuditRecord
+	err = b.db.ReadAuditLog(cursor, func(rec *ezdb.AuditRecord) error {
+		recs = append(recs, rec)
+		if len(recs) == b.options.batchSize {
+			return errBatchFull
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFull) {
+		return false, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(recs) == 0 {
+		return false, nil
+	}
+
+	for _, rec := range recs {
+		err = b.publish(ctx, rec)
+		if err != nil {
+			return false, err
+		}
+		err = b.cursors.PutValue(b.name, rec.Seq)
+		if err != nil {
+			return false, fmt.Errorf("failed to save cursor: %w", err)
+		}
+	}
+
+	return len(recs) == b.options.batchSize, nil
+}
+
+// publish publishes the change recorded by rec, unless it isn't a change to
+// the Bridge's DBRef.
+func (b *Bridge[K, V]) publish(ctx context.Context, rec *ezdb.AuditRecord) error {
+	if rec.DBRef != b.ref.Name() {
+		return nil
+	}
+	key, err := b.ref.DecodeKey(rec.Key)
+	if err != nil {
+		// Outside of the DBRef's namespace.
+		return nil
+	}
+
+	event := Event[K, V]{
+		Seq:   rec.Seq,
+		DBRef: rec.DBRef,
+		Op:    rec.Op,
+		Key:   *key,
+		Time:  rec.Time,
+		Actor: rec.Actor,
+	}
+	if rec.Op == "put" {
+		val, ok, err := b.ref.TryGet(key)
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		if !ok {
+			// The key has been deleted since, which a later record reports.
+			return nil
+		}
+		event.Value = val
+	}
+
+	data, err := b.options.marshal(&event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	err = b.pub.Publish(ctx, b.options.subject, data)
+	if err != nil {
+		return fmt.Errorf("failed to publish event %d: %w", rec.Seq, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package cdc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/cdc"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// message is a message sent to a Publisher.
+type message struct {
+	subject string
+	event   cdc.Event[string, string]
+}
+
+// channelPublisher sends every message it is given to a channel, failing the
+// ones fail returns an error for.
+func channelPublisher(t *testing.T, fail func(event cdc.Event[string, string]) error) (cdc.Publisher, <-chan message) {
+	ch := make(chan message, 100)
+	return cdc.PublisherFunc(func(ctx context.Context, subject string, data []byte) error {
+		var event cdc.Event[string, string]
+		err := json.Unmarshal(data, &event)
+		if err != nil {
+			t.Errorf("published %s: %v", data, err)
+			return err
+		}
+		if fail != nil {
+			err = fail(event)
+			if err != nil {
+				return err
+			}
+		}
+		ch <- message{subject: subject, event: event}
+		return nil
+	}), ch
+}
+
+func next(t *testing.T, ch <-chan message) message {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing published")
+	}
+
+	panic("unreachable")
+}
+
+func TestBridge(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	ref, err := ezdb.NewRef[string, string]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	other, err := ezdb.NewRef[string, string]("other", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	// Changes from before the Bridge started are published too.
+	key, val := "ann", "v1"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = other.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	pub, ch := channelPublisher(t, nil)
+	bridge, err := cdc.New(db, "users", ref, pub, cdc.WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+
+	msg := next(t, ch)
+	if msg.subject != "ezdb.users" || msg.event.Seq != 1 || msg.event.Op != "put" || msg.event.Key != "ann" || *msg.event.Value != "v1" {
+		t.Fatalf("published %+v", msg)
+	}
+
+	// Changes made while running are published right away.
+	ctxActor := ezdb.ContextWithActor(context.Background(), "admin")
+	err = ref.WithContext(ctxActor).Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	msg = next(t, ch)
+	if msg.event.Seq != 3 || msg.event.Op != "delete" || msg.event.Value != nil || msg.event.Actor != "admin" {
+		t.Fatalf("published %+v", msg)
+	}
+
+	cancel()
+	err = <-done
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want context.Canceled", err)
+	}
+	cursor, err := bridge.Cursor()
+	if err != nil || cursor != 3 {
+		t.Fatalf("Cursor = %d, %v, want 3", cursor, err)
+	}
+	select {
+	case msg := <-ch:
+		t.Fatalf("published %+v from another DBRef", msg)
+	default:
+	}
+}
+
+func TestBridgeResumesAfterFailure(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	ref, err := ezdb.NewRef[string, string]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		key, val := key, "v"
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	down := errors.New("broker down")
+	pub, ch := channelPublisher(t, func(event cdc.Event[string, string]) error {
+		if event.Key == "b" {
+			return down
+		}
+		return nil
+	})
+	bridge, err := cdc.New(db, "users", ref, pub, cdc.WithSubject("users"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = bridge.Run(context.Background())
+	if !errors.Is(err, down) {
+		t.Fatalf("Run returned %v, want the publishing error", err)
+	}
+	if msg := next(t, ch); msg.event.Key != "a" || msg.subject != "users" {
+		t.Fatalf("published %+v", msg)
+	}
+	cursor, err := bridge.Cursor()
+	if err != nil || cursor != 1 {
+		t.Fatalf("Cursor after the failure = %d, %v, want 1", cursor, err)
+	}
+
+	// A new Bridge with the same name retries the failed change.
+	pub, ch = channelPublisher(t, nil)
+	bridge, err = cdc.New(db, "users", ref, pub, cdc.WithPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bridge.Run(ctx)
+	for _, want := range []string{"b", "c"} {
+		if msg := next(t, ch); msg.event.Key != want {
+			t.Fatalf("published %+v, want %s", msg, want)
+		}
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	ref, err := ezdb.NewRef[string, string]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	pub, _ := channelPublisher(t, nil)
+
+	for _, opt := range []cdc.Option{cdc.WithPollInterval(0), cdc.WithBatchSize(0)} {
+		_, err = cdc.New(db, "users", ref, pub, opt)
+		if err == nil {
+			t.Error("New with an invalid option succeeded")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package cdcnats is a cdc.Publisher for NATS JetStream.
+package cdcnats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/bjornpagen/ezdb/cdc"
+)
+
+var _ cdc.Publisher = (*Publisher)(nil)
+
+// Publisher publishes events to the JetStream stream bound to their subject.
+// A publish returns once the stream has acknowledged and stored the message,
+// so a Bridge only advances its cursor past events that were persisted.
+type Publisher struct {
+	js nats.JetStreamContext
+}
+
+// New returns a Publisher publishing with js, e.g. from nc.JetStream(). A
+// stream must exist for the subjects the Bridge publishes to.
+func New(js nats.JetStreamContext) (*Publisher, error) {
+	if js == nil {
+		return nil, errors.New("JetStream context must not be nil")
+	}
+
+	return &Publisher{js: js}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, subject string, data []byte) error {
+	_, err := p.js.Publish(subject, data, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package cdcnats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/bjornpagen/ezdb/cdc/cdcnats"
+)
+
+// fakeJetStream records the messages published through it, and fails them
+// with err if it is set. Its other methods aren't implemented.
+type fakeJetStream struct {
+	nats.JetStreamContext
+	subjects []string
+	data     [][]byte
+	err      error
+}
+
+func (js *fakeJetStream) Publish(subject string, data []byte, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	if js.err != nil {
+		return nil, js.err
+	}
+	js.subjects = append(js.subjects, subject)
+	js.data = append(js.data, data)
+
+	return &nats.PubAck{Stream: "EZDB"}, nil
+}
+
+func TestPublisher(t *testing.T) {
+	js := &fakeJetStream{}
+	pub, err := cdcnats.New(js)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = pub.Publish(context.Background(), "ezdb.users", []byte(`{"seq":1}`))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(js.subjects) != 1 || js.subjects[0] != "ezdb.users" || string(js.data[0]) != `{"seq":1}` {
+		t.Fatalf("published %q: %q", js.subjects, js.data)
+	}
+
+	js.err = nats.ErrNoStreamResponse
+	err = pub.Publish(context.Background(), "ezdb.users", []byte(`{"seq":2}`))
+	if !errors.Is(err, nats.ErrNoStreamResponse) {
+		t.Fatalf("Publish returned %v, want the JetStream error", err)
+	}
+
+	_, err = cdcnats.New(nil)
+	if err == nil {
+		t.Fatal("New without a JetStream context succeeded")
+	}
+}
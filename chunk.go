@@ -0,0 +1,360 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Every stored value of a DBRef created with WithChunking starts with a tag:
+// chunkInline is followed by the value, chunkManifest by the blob ID, the
+// number of chunks and the size of a value stored in chunks.
+const (
+	chunkInline   byte = 0
+	chunkManifest byte = 1
+)
+
+// manifestSize is the size of a manifest, including its tag.
+const manifestSize = 1 + 8 + 4 + 8
+
+// chunkTxnBytes is about how many bytes of chunks Put writes per transaction.
+const chunkTxnBytes = 16 << 20
+
+// The chunks database holds, for every value stored in chunks, the chunks
+// under the blob ID followed by their big-endian uint32 index, and
+// chunkNextKey, the next blob ID. While Put still writes the chunks of a
+// value, chunkPendingPrefix+ID marks them.
+var (
+	chunkNextKey       = []byte("n")
+	chunkPendingPrefix = []byte("p")
+)
+
+// chunkOptions configure the chunking of a DBRef.
+type chunkOptions struct {
+	threshold int
+}
+
+// WithChunking stores values whose encoding (and, if enabled, compression and
+// checksum) is larger than threshold bytes as chunks of at most threshold
+// bytes in a database of their own, which counts towards WithNumDBs, and
+// reassembles them when they are read, so values of hundreds of MB don't need
+// a map size or single transaction large enough for them. Put writes the
+// chunks in transactions of their own before committing the entry itself, so
+// a value is never seen half written; chunks of a Put interrupted by a crash
+// are removed when the DBRef is opened again. Puts within an Update write the
+// chunks in its transaction.
+//
+// Stored values get a leading tag byte, so values written without chunking
+// can't be read once it is enabled, and vice versa. Mirrors, the oplog and
+// replication carry the entries but not their chunks, and CopyTo and the
+// other readers of stored records that don't go through a read of the DBRef
+// fail on values stored in chunks.
+func WithChunking(threshold uint) RefOption {
+	return func(option *refOptions) error {
+		if threshold == 0 {
+			return errors.New("chunk threshold must be positive")
+		}
+		option.chunking = &chunkOptions{threshold: int(threshold)}
+		return nil
+	}
+}
+
+func (ref *DBRef[K, V]) chunksDBName() string {
+	return ref.id + ".chunks"
+}
+
+// initChunks opens the chunks database, creating it if it doesn't exist, and
+// removes the chunks of interrupted Puts.
+func (ref *DBRef[K, V]) initChunks() error {
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+			return err
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		chunksRef, err := txn.DBRef(ref.chunksDBName(), dbCreate)
+		if err != nil {
+			return err
+		}
+		cursor, err := txn.NewCursor(chunksRef)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		var pending [][]byte
+		key, _, err := cursor.SeekGreaterThanOrEqualKey(chunkPendingPrefix)
+		for ; err == nil && bytes.HasPrefix(key, chunkPendingPrefix); key, _, err = cursor.Next() {
+			pending = append(pending, bytes.Clone(key))
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		for _, key := range pending {
+			err = deleteChunks(txn, chunksRef, key[len(chunkPendingPrefix):])
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(chunksRef, key, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// chunkKey returns the key of chunk i of the blob id.
+func chunkKey(id []byte, i uint32) []byte {
+	return binary.BigEndian.AppendUint32(bytes.Clone(id), i)
+}
+
+// deleteChunks deletes the chunks of the blob id.
+func deleteChunks(txn writeTxn, chunksRef dbi, id []byte) error {
+	for i := uint32(0); ; i++ {
+		err := txn.Delete(chunksRef, chunkKey(id, i), nil)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete chunk: %w", err)
+		}
+	}
+}
+
+// writeChunksInTxn writes the chunks of data of the blob id, from chunk
+// first on, until about chunkTxnBytes are written unless all is set, and
+// returns the index of the next chunk to write.
+func (ref *DBRef[K, V]) writeChunksInTxn(txn writeTxn, chunksRef dbi, id, data []byte, first uint32, all bool) (uint32, error) {
+	size := ref.options.chunking.threshold
+	i := first
+	for written := 0; int(i)*size < len(data) && (all || written < chunkTxnBytes); i++ {
+		end := int(i+1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[int(i)*size : end]
+		err := txn.Put(chunksRef, chunkKey(id, i), chunk, putFlag(0))
+		if err != nil {
+			return 0, fmt.Errorf("failed to put chunk: %w", err)
+		}
+		written += len(chunk)
+	}
+
+	return i, nil
+}
+
+// newBlobInTxn allocates the ID of a value stored in chunks.
+func newBlobInTxn(txn writeTxn, chunksRef dbi) ([]byte, error) {
+	var next uint64
+	data, err := txn.Get(chunksRef, chunkNextKey)
+	switch {
+	case err == nil && len(data) == 8:
+		next = binary.BigEndian.Uint64(data)
+	case err == nil:
+		return nil, errors.New("invalid next blob ID")
+	case !errors.Is(err, ErrNotFound):
+		return nil, fmt.Errorf("failed to get next blob ID: %w", err)
+	}
+
+	err = txn.Put(chunksRef, chunkNextKey, binary.BigEndian.AppendUint64(nil, next+1), putFlag(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to put next blob ID: %w", err)
+	}
+	return binary.BigEndian.AppendUint64(nil, next), nil
+}
+
+// manifest returns the manifest of the blob id of data.
+func (ref *DBRef[K, V]) manifest(id, data []byte) []byte {
+	size := ref.options.chunking.threshold
+	m := append([]byte{chunkManifest}, id...)
+	m = binary.BigEndian.AppendUint32(m, uint32((len(data)+size-1)/size))
+	return binary.BigEndian.AppendUint64(m, uint64(len(data)))
+}
+
+// stageChunks writes the chunks of data, if it is to be stored in chunks, in
+// transactions of their own and returns the manifest to commit, marked as
+// chunked, in place of enc.
+func (ref *DBRef[K, V]) stageChunks(enc encodedVal) (encodedVal, error) {
+	if ref.options.chunking == nil || enc.len() <= ref.options.chunking.threshold {
+		return enc, nil
+	}
+
+	data, err := enc.materialize()
+	if err != nil {
+		return encodedVal{}, fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	var id []byte
+	for next := uint32(0); int(next)*ref.options.chunking.threshold < len(data); {
+		err = ref.ownerDB.update(func(txn writeTxn) error {
+			chunksRef, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to get db ref: %w", err)
+			}
+			if id == nil {
+				id, err = newBlobInTxn(txn, chunksRef)
+				if err != nil {
+					return err
+				}
+				err = txn.Put(chunksRef, append(bytes.Clone(chunkPendingPrefix), id...), nil, putFlag(0))
+				if err != nil {
+					return fmt.Errorf("failed to mark chunks: %w", err)
+				}
+			}
+
+			next, err = ref.writeChunksInTxn(txn, chunksRef, id, data, next, false)
+			return err
+		})
+		if err != nil {
+			return encodedVal{}, err
+		}
+	}
+
+	return encodedVal{bytes: ref.manifest(id, data), chunked: true}, nil
+}
+
+// chunkInTxn deletes the chunks of the value stored under keyBytes, if any,
+// and returns enc as it is to be stored: tagged, and as a manifest of chunks
+// written in txn if it is too large and not chunked yet.
+func (ref *DBRef[K, V]) chunkInTxn(txn writeTxn, dbRef dbi, keyBytes []byte, enc encodedVal) (encodedVal, error) {
+	chunksRef, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+	if err != nil {
+		return encodedVal{}, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	err = ref.unchunkInTxn(txn, dbRef, chunksRef, keyBytes)
+	if err != nil {
+		return encodedVal{}, err
+	}
+
+	if enc.chunked {
+		// The chunks were written by stageChunks; make sure nobody removed
+		// them as those of an interrupted Put since.
+		pending := append(bytes.Clone(chunkPendingPrefix), enc.bytes[1:9]...)
+		err = txn.Delete(chunksRef, pending, nil)
+		if errors.Is(err, ErrNotFound) {
+			return encodedVal{}, errors.New("chunks of value were removed before it was committed")
+		}
+		if err != nil {
+			return encodedVal{}, fmt.Errorf("failed to unmark chunks: %w", err)
+		}
+		return encodedVal{bytes: enc.bytes}, nil
+	}
+
+	if enc.len() > ref.options.chunking.threshold {
+		data, err := enc.materialize()
+		if err != nil {
+			return encodedVal{}, fmt.Errorf("failed to encode value: %w", err)
+		}
+		id, err := newBlobInTxn(txn, chunksRef)
+		if err != nil {
+			return encodedVal{}, err
+		}
+		_, err = ref.writeChunksInTxn(txn, chunksRef, id, data, 0, true)
+		if err != nil {
+			return encodedVal{}, err
+		}
+		return encodedVal{bytes: ref.manifest(id, data)}, nil
+	}
+
+	if enc.encode != nil {
+		return encodedVal{size: enc.size + 1, encode: func(buf []byte) error {
+			buf[0] = chunkInline
+			return enc.encode(buf[1:])
+		}}, nil
+	}
+	return encodedVal{bytes: append([]byte{chunkInline}, enc.bytes...)}, nil
+}
+
+// unchunkInTxn deletes the chunks of the value stored under keyBytes, if it
+// is stored in chunks.
+func (ref *DBRef[K, V]) unchunkInTxn(txn writeTxn, dbRef, chunksRef dbi, keyBytes []byte) error {
+	old, err := txn.Get(dbRef, keyBytes)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+	if len(old) != manifestSize || old[0] != chunkManifest {
+		return nil
+	}
+
+	return deleteChunks(txn, chunksRef, old[1:9])
+}
+
+// loadChunksInTxn returns the stored value valBytes with its chunks
+// reassembled, if it is stored in chunks.
+func (ref *DBRef[K, V]) loadChunksInTxn(txn readTxn, valBytes []byte) ([]byte, error) {
+	if ref.options.chunking == nil || len(valBytes) == 0 || valBytes[0] != chunkManifest {
+		return valBytes, nil
+	}
+	if len(valBytes) != manifestSize {
+		return nil, errors.New("invalid chunk manifest")
+	}
+
+	chunksRef, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	id := valBytes[1:9]
+	n := binary.BigEndian.Uint32(valBytes[9:])
+	size := binary.BigEndian.Uint64(valBytes[13:])
+
+	data := make([]byte, 1, 1+size)
+	data[0] = chunkInline
+	for i := uint32(0); i < n; i++ {
+		chunk, err := txn.Get(chunksRef, chunkKey(id, i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chunk %d: %w", i, err)
+		}
+		data = append(data, chunk...)
+	}
+	if uint64(len(data)-1) != size {
+		return nil, fmt.Errorf("chunks hold %d bytes instead of %d", len(data)-1, size)
+	}
+
+	return data, nil
+}
+
+// decodeValInTxn is decodeVal for a value that may be stored in chunks.
+func (ref *DBRef[K, V]) decodeValInTxn(txn readTxn, valBytes []byte) (*V, error) {
+	valBytes, err := ref.loadChunksInTxn(txn, valBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.decodeVal(valBytes)
+}
+
+// discardChunks deletes the chunks stageChunks wrote for enc, after the write
+// they were for failed.
+func (ref *DBRef[K, V]) discardChunks(enc encodedVal) {
+	if !enc.chunked {
+		return
+	}
+
+	err := ref.ownerDB.update(func(txn writeTxn) error {
+		chunksRef, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		pending := append(bytes.Clone(chunkPendingPrefix), enc.bytes[1:9]...)
+		err = txn.Delete(chunksRef, pending, nil)
+		if errors.Is(err, ErrNotFound) {
+			// Committed after all, or removed already.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unmark chunks: %w", err)
+		}
+		return deleteChunks(txn, chunksRef, enc.bytes[1:9])
+	})
+	if err != nil {
+		ref.ownerDB.options.log.Error().Err(err).Str("db", ref.id).Msg("failed to discard chunks")
+	}
+}
@@ -0,0 +1,161 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// chunkRecords returns the keys in the chunks database of the DBRef "ref".
+func chunkRecords(t *testing.T, db *ezdb.Client) []string {
+	t.Helper()
+
+	var keys []string
+	err := rawRef(t, db, "ref.chunks", ezdb.WithKeyCodec(ezdb.StringCodec{})).ForEach(func(key *string, _ *[]byte) error {
+		keys = append(keys, *key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	return keys
+}
+
+// chunkedRef opens the DBRef "ref" of db with byte values stored in chunks
+// of 1000 bytes.
+func chunkedRef(t *testing.T, db *ezdb.Client) *ezdb.DBRef[string, []byte] {
+	t.Helper()
+
+	ref, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithChunking(1000))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	return ref
+}
+
+func TestChunking(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref := chunkedRef(t, db)
+	raw := rawRef(t, db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+
+	key, big := "big", bytes.Repeat([]byte("0123456789"), 1050)
+	err := ref.Put(&key, &big)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || !bytes.Equal(*got, big) {
+		t.Fatalf("Get of a chunked value = %d bytes, %v", len(*got), err)
+	}
+	stored, err := raw.Get(&key)
+	if err != nil || len(*stored) != 21 || (*stored)[0] != 1 {
+		t.Fatalf("stored value = %x, %v, want a manifest", stored, err)
+	}
+	// 11 chunks and the next blob ID.
+	if n := len(chunkRecords(t, db)); n != 12 {
+		t.Fatalf("chunks database holds %d records", n)
+	}
+
+	// Small values are stored inline, and replacing a chunked value deletes
+	// its chunks.
+	small := []byte("small")
+	err = ref.Put(&key, &small)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	stored, err = raw.Get(&key)
+	if err != nil || string(*stored) != "\x00small" {
+		t.Fatalf("stored value = %q, %v, want the tagged value", stored, err)
+	}
+	if records := chunkRecords(t, db); len(records) != 1 {
+		t.Fatalf("chunks database holds %q after the value shrank", records)
+	}
+
+	// Puts in an Update write the chunks in its transaction, and Delete
+	// deletes them.
+	err = db.Update(func(tx *ezdb.Tx) error {
+		return ref.PutTx(tx, &key, &big)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err = ref.Get(&key)
+	if err != nil || !bytes.Equal(*got, big) {
+		t.Fatalf("Get of a value chunked in an Update = %d bytes, %v", len(*got), err)
+	}
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if records := chunkRecords(t, db); len(records) != 1 {
+		t.Fatalf("chunks database holds %q after Delete", records)
+	}
+}
+
+func TestChunkingFailedPut(t *testing.T) {
+	faults := ezdb.NewFaults()
+	dir := t.TempDir()
+	db := openClient(t, dir, ezdb.WithFaults(faults))
+	ref := chunkedRef(t, db)
+
+	// The chunks of a Put whose entry fails to commit are deleted.
+	key, big := "big", []byte(strings.Repeat("x", 5000))
+	faults.Add(ezdb.Fault{Op: ezdb.FaultWrite, At: faults.Count(ezdb.FaultWrite) + 2, Err: ezdb.ErrMapFull})
+	err := ref.Put(&key, &big)
+	if err == nil {
+		t.Fatal("Put with a failing commit succeeded")
+	}
+	if records := chunkRecords(t, db); len(records) != 1 {
+		t.Fatalf("chunks database holds %q after a failed Put", records)
+	}
+
+	// If they can't be, they are when the DBRef is opened again.
+	faults.Add(ezdb.Fault{Op: ezdb.FaultWrite, At: faults.Count(ezdb.FaultWrite) + 2, Every: 1, Err: ezdb.ErrMapFull})
+	err = ref.Put(&key, &big)
+	if err == nil {
+		t.Fatal("Put with a failing commit succeeded")
+	}
+	faults.Clear()
+	if records := chunkRecords(t, db); len(records) != 7 {
+		t.Fatalf("chunks database holds %d records after an interrupted Put", len(records))
+	}
+	ref = chunkedRef(t, db)
+	if records := chunkRecords(t, db); len(records) != 1 {
+		t.Fatalf("chunks database holds %q after reopening", records)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a failed Put = %t, %v", ok, err)
+	}
+}
+
+func TestWithChunkingErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	_, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithChunking(0))
+	if err == nil {
+		t.Fatal("NewDBRef with a zero chunk threshold succeeded")
+	}
+	_, err = ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithChunking(1000), ezdb.WithHistory(10))
+	if err == nil {
+		t.Fatal("NewDBRef with chunking and history succeeded")
+	}
+
+	// Values stored without chunking can't be read with it.
+	ref := rawRef(t, db, "plain")
+	key, val := "k", []byte("value")
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	chunked, err := ezdb.NewDBRef[string, []byte](db, "plain", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithChunking(1000))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	got, err := chunked.Get(&key)
+	if err == nil && bytes.Equal(*got, val) {
+		t.Fatal("Get with chunking of a value stored without it succeeded")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// editor reads lines of input with history and tab completion if its input
+// is a terminal that can be put into raw mode, and plain lines otherwise.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+	// raw reports whether to try raw mode; it is cleared once that fails.
+	raw     bool
+	history []string
+	// complete returns the candidates for the last word of line.
+	complete func(line string) []string
+}
+
+// readLine prints prompt and reads a line, returning io.EOF at the end of the
+// input or on Ctrl-D.
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.raw {
+		restore, err := makeRaw(e.fd)
+		if err == nil {
+			defer restore()
+			return e.readRaw(prompt)
+		}
+		e.raw = false
+	}
+
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (e *editor) readRaw(prompt string) (string, error) {
+	var line []rune
+	hist := len(e.history)
+	e.redraw(prompt, line)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			s := string(line)
+			if strings.TrimSpace(s) != "" {
+				e.history = append(e.history, s)
+			}
+			return s, nil
+		case 3: // Ctrl-C discards the line.
+			fmt.Fprint(e.out, "^C\n")
+			line = nil
+			hist = len(e.history)
+		case 4: // Ctrl-D on an empty line ends the input.
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+		case 8, 127:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case 21: // Ctrl-U
+			line = nil
+		case '\t':
+			line = []rune(e.completeLine(prompt, string(line)))
+		case 27:
+			// Up and down arrows move through the history; other escape
+			// sequences are ignored.
+			if b, _ := e.in.ReadByte(); b != '[' {
+				break
+			}
+			switch b, _ := e.in.ReadByte(); b {
+			case 'A':
+				if hist > 0 {
+					hist--
+					line = []rune(e.history[hist])
+				}
+			case 'B':
+				if hist < len(e.history) {
+					hist++
+					line = nil
+					if hist < len(e.history) {
+						line = []rune(e.history[hist])
+					}
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+			}
+		}
+		e.redraw(prompt, line)
+	}
+}
+
+// redraw replaces the current terminal line with prompt and line.
+func (e *editor) redraw(prompt string, line []rune) {
+	fmt.Fprintf(e.out, "\r\x1b[K%s%s", prompt, string(line))
+}
+
+// completeLine completes the last word of line: to the candidate if there is
+// only one, else as far as the candidates agree, listing them if that adds
+// nothing.
+func (e *editor) completeLine(prompt, line string) string {
+	if e.complete == nil {
+		return line
+	}
+	word := line[strings.LastIndexByte(line, ' ')+1:]
+	candidates := e.complete(line)
+
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return line
+	case 1:
+		return line[:len(line)-len(word)] + candidates[0] + " "
+	}
+
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for !utf8.ValidString(common) {
+		common = common[:len(common)-1]
+	}
+	if len(common) > len(word) {
+		return line[:len(line)-len(word)] + common
+	}
+
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+	return line
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newEditor returns an editor reading input in raw mode, as from a terminal,
+// and completing words from words.
+func newEditor(input string, words ...string) (*editor, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &editor{
+		in:  bufio.NewReader(strings.NewReader(input)),
+		out: out,
+		complete: func(line string) []string {
+			word := line[strings.LastIndexByte(line, ' ')+1:]
+			var candidates []string
+			for _, w := range words {
+				if strings.HasPrefix(w, word) {
+					candidates = append(candidates, w)
+				}
+			}
+			return candidates
+		},
+	}, out
+}
+
+// readAll reads the lines of e's input in raw mode until its end.
+func readAll(t *testing.T, e *editor) []string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := e.readRaw("> ")
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		if err != nil {
+			t.Fatalf("readRaw: %v", err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	e, _ := newEditor("gex\x7ft ada\r" + // backspace
+		"junk\x15ls\r" + // Ctrl-U
+		"junk\x03use\r" + // Ctrl-C
+		"\x1b[A\r" + // up repeats the last line
+		"\x1b[A\x1b[A\x1b[B\r" + // up, up, down
+		"\x1b[C\x1bOx\r" + // other escape sequences are ignored
+		"   \r") // blank lines aren't kept in the history
+
+	lines := readAll(t, e)
+	want := []string{"get ada", "ls", "use", "use", "use", "x", "   "}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("read %q, want %q", lines, want)
+	}
+	if len(e.history) != 6 {
+		t.Fatalf("history = %q", e.history)
+	}
+}
+
+func TestEditorCtrlD(t *testing.T) {
+	// Ctrl-D only ends the input on an empty line.
+	e, _ := newEditor("ls\x04\r\x04more")
+
+	line, err := e.readRaw("> ")
+	if err != nil || line != "ls" {
+		t.Fatalf("readRaw = %q, %v", line, err)
+	}
+	_, err = e.readRaw("> ")
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("readRaw after Ctrl-D returned %v, want io.EOF", err)
+	}
+}
+
+func TestEditorComplete(t *testing.T) {
+	e, out := newEditor("", "scan", "stat", "use", "users1", "users2")
+
+	if got := e.completeLine("> ", "us"); got != "use" {
+		t.Errorf("completing us = %q, want the common prefix", got)
+	}
+	if got := e.completeLine("> ", "get u"); got != "get use" {
+		t.Errorf("completing get u = %q", got)
+	}
+	if got := e.completeLine("> ", "sc"); got != "scan " {
+		t.Errorf("completing sc = %q, want the only candidate", got)
+	}
+
+	out.Reset()
+	if got := e.completeLine("> ", "s"); got != "s" || out.String() != "\nscan  stat\n" {
+		t.Errorf("completing s = %q, printed %q, want the candidates listed", got, out)
+	}
+	out.Reset()
+	if got := e.completeLine("> ", "x"); got != "x" || out.String() != "\a" {
+		t.Errorf("completing x = %q, printed %q, want a bell", got, out)
+	}
+
+	// The common prefix of candidates isn't cut within a rune.
+	e, _ = newEditor("", "é1", "è2")
+	if got := e.completeLine("> ", ""); got != "" {
+		t.Errorf("completing runes sharing a first byte = %q", got)
+	}
+
+	e, _ = newEditor("sc\t\r", "scan")
+	lines := readAll(t, e)
+	if len(lines) != 1 || lines[0] != "scan " {
+		t.Fatalf("read %q after a tab, want the completed line", lines)
+	}
+}
+
+func TestEditorPlain(t *testing.T) {
+	// An input that isn't a terminal is read a line at a time.
+	e, out := newEditor("ls\r\nget a")
+	e.raw = true
+	e.fd = -1
+
+	line, err := e.readLine("> ")
+	if err != nil || line != "ls" {
+		t.Fatalf("readLine = %q, %v", line, err)
+	}
+	if e.raw {
+		t.Fatal("raw mode is still tried after failing")
+	}
+	line, err = e.readLine("> ")
+	if err != nil || line != "get a" {
+		t.Fatalf("readLine of the last line = %q, %v", line, err)
+	}
+	_, err = e.readLine("> ")
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("readLine at the end returned %v, want io.EOF", err)
+	}
+	if out.String() != "> > > " {
+		t.Fatalf("printed %q", out)
+	}
+}
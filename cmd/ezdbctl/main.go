@@ -0,0 +1,385 @@
+// Command ezdbctl inspects and maintains ezdb environments from the shell.
+//
+// Usage:
+//
+//	ezdbctl [flags] <path> <command> [arguments]
+//
+// Keys and values are read and written as the bytes stored, so get, put and
+// del work on any database, whatever codecs the program that wrote it used.
+// Run ezdbctl -h for the list of commands.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// command is a subcommand of ezdbctl.
+type command struct {
+	// args describes the arguments for the usage message.
+	args    string
+	summary string
+	// nargs is the number of arguments, -1 for an optional one or -2 for
+	// any number.
+	nargs int
+	// write reports whether the command writes to the environment, which is
+	// opened read-only otherwise.
+	write bool
+	run   func(ctx context.Context, c *ctl, args []string) error
+}
+
+var commands = map[string]command{
+	"stat":    {"", "print map size, page usage and readers", 0, false, runStat},
+	"ls":      {"", "list the named databases", 0, false, runLs},
+	"get":     {"<db> <key>", "print the value stored under key", 2, false, runGet},
+	"put":     {"<db> <key> <value>", "store value under key; a value of - is read from stdin", 3, true, runPut},
+	"del":     {"<db> <key>", "delete key", 2, true, runDel},
+	"dump":    {"[file]", "dump every database to file, or stdout", -1, false, runDump},
+	"load":    {"[file]", "load a dump from file, or stdin", -1, true, runLoad},
+	"backup":  {"<file>", "write a consistent copy of the data file to file; - is stdout", 1, false, runBackup},
+	"compact": {"<dir>", "write a compacted copy of the environment into dir", 1, false, runCompact},
+	"shell":   {"", "start an interactive shell for looking around the databases", 0, false, runShell},
+	"verify":  {"[-checksums] [-json] [db ...]", "read every entry of the databases, or all but ezdb's own", -2, false, runVerify},
+}
+
+// ctl is the state the commands run with.
+type ctl struct {
+	db  *ezdb.Client
+	hex bool
+	out io.Writer
+}
+
+func main() {
+	numDBs := flag.Uint("dbs", 128, "maximum number of named databases to open")
+	useHex := flag.Bool("hex", false, "read and print keys and values hex-encoded")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+	path, name, args := flag.Arg(0), flag.Arg(1), flag.Args()[2:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ezdbctl: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if !validArgs(cmd.nargs, len(args)) {
+		fmt.Fprintf(os.Stderr, "usage: ezdbctl [flags] <path> %s %s\n", name, cmd.args)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, path, cmd, args, *numDBs, *useHex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ezdbctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run opens the environment in path and runs cmd on it.
+func run(ctx context.Context, path string, cmd command, args []string, numDBs uint, useHex bool) error {
+	opts := []ezdb.Option{ezdb.WithNumDBs(numDBs)}
+	if !cmd.write {
+		_, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to open environment: %w", err)
+		}
+		opts = append(opts, ezdb.WithReadOnly())
+	}
+
+	db, err := ezdb.New(path, opts...)
+	if err != nil {
+		return err
+	}
+	err = db.Init()
+	if err != nil {
+		return fmt.Errorf("failed to open environment: %w", err)
+	}
+	defer db.Close()
+
+	return cmd.run(ctx, &ctl{db: db, hex: useHex, out: os.Stdout}, args)
+}
+
+// validArgs reports whether n arguments are valid for a command taking nargs.
+func validArgs(nargs, n int) bool {
+	switch nargs {
+	case -1:
+		return n <= 1
+	case -2:
+		return true
+	default:
+		return n == nargs
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ezdbctl [flags] <path> <command> [arguments]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(os.Stderr, "  %-36s %s\n", strings.TrimSpace(name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// ref opens the database name with its keys and values as stored.
+func (c *ctl) ref(name string) (*ezdb.DBRef[[]byte, []byte], error) {
+	return ezdb.NewDBRef[[]byte, []byte](c.db, name, ezdb.WithKeyCodec(ezdb.BytesCodec{}), ezdb.WithCodec(ezdb.BytesCodec{}))
+}
+
+// decode returns the bytes of a key or value given on the command line.
+func (c *ctl) decode(s string) ([]byte, error) {
+	if !c.hex {
+		return []byte(s), nil
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode hex: %w", err)
+	}
+
+	return b, nil
+}
+
+// print writes b on a line of its own.
+func (c *ctl) print(b []byte) error {
+	var err error
+	if c.hex {
+		_, err = fmt.Fprintln(c.out, hex.EncodeToString(b))
+	} else {
+		_, err = fmt.Fprintf(c.out, "%s\n", b)
+	}
+
+	return err
+}
+
+func runStat(ctx context.Context, c *ctl, args []string) error {
+	stats, err := c.db.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "page size:   %d\n", stats.PageSize)
+	fmt.Fprintf(c.out, "map size:    %d\n", stats.MapSize)
+	fmt.Fprintf(c.out, "used pages:  %d (%d bytes)\n", stats.UsedPages, stats.UsedPages*stats.PageSize)
+	fmt.Fprintf(c.out, "free pages:  %d\n", stats.FreePages)
+	fmt.Fprintf(c.out, "last txn id: %d\n", stats.LastTxnID)
+	if stats.NumReaders >= 0 {
+		fmt.Fprintf(c.out, "readers:     %d\n", stats.NumReaders)
+	}
+
+	return nil
+}
+
+func runLs(ctx context.Context, c *ctl, args []string) error {
+	names, err := c.db.ListDBs()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Fprintln(c.out, name)
+	}
+
+	return nil
+}
+
+func runGet(ctx context.Context, c *ctl, args []string) error {
+	ref, err := c.ref(args[0])
+	if err != nil {
+		return err
+	}
+	key, err := c.decode(args[1])
+	if err != nil {
+		return err
+	}
+
+	val, err := ref.Get(&key)
+	if errors.Is(err, ezdb.ErrNotFound) {
+		return errors.New("key not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.print(*val)
+}
+
+func runPut(ctx context.Context, c *ctl, args []string) error {
+	ref, err := c.ref(args[0])
+	if err != nil {
+		return err
+	}
+	key, err := c.decode(args[1])
+	if err != nil {
+		return err
+	}
+
+	var val []byte
+	if args[2] == "-" {
+		val, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		if c.hex {
+			val, err = c.decode(strings.TrimSpace(string(val)))
+		}
+	} else {
+		val, err = c.decode(args[2])
+	}
+	if err != nil {
+		return err
+	}
+
+	return ref.Put(&key, &val)
+}
+
+func runDel(ctx context.Context, c *ctl, args []string) error {
+	ref, err := c.ref(args[0])
+	if err != nil {
+		return err
+	}
+	key, err := c.decode(args[1])
+	if err != nil {
+		return err
+	}
+
+	return ref.Delete(&key)
+}
+
+func runDump(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 0 || args[0] == "-" {
+		return c.db.Dump(c.out)
+	}
+
+	return writeFile(args[0], func(w io.Writer) error {
+		return c.db.Dump(w)
+	})
+}
+
+func runLoad(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 0 || args[0] == "-" {
+		return c.db.Load(os.Stdin)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer f.Close()
+
+	return c.db.Load(f)
+}
+
+func runBackup(ctx context.Context, c *ctl, args []string) error {
+	if args[0] == "-" {
+		return c.db.Backup(ctx, c.out)
+	}
+
+	return writeFile(args[0], func(w io.Writer) error {
+		return c.db.Backup(ctx, w)
+	})
+}
+
+func runCompact(ctx context.Context, c *ctl, args []string) error {
+	return c.db.CompactTo(args[0])
+}
+
+func runVerify(ctx context.Context, c *ctl, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	checksums := flags.Bool("checksums", false, "check values against the checksums of WithChecksums")
+	asJSON := flags.Bool("json", false, "check that values are valid JSON, as stored by JSONCodec")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	names := flags.Args()
+
+	if len(names) == 0 {
+		all, err := c.db.ListDBs()
+		if err != nil {
+			return err
+		}
+		for _, name := range all {
+			if !strings.HasPrefix(name, "ezdb.") {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// Verify only checks the databases a DBRef is open for, so open each of
+	// them with the value encoding asked for.
+	opts := []ezdb.RefOption{ezdb.WithKeyCodec(ezdb.BytesCodec{})}
+	if *checksums {
+		opts = append(opts, ezdb.WithChecksums())
+	}
+	for _, name := range names {
+		if *asJSON {
+			_, err = ezdb.NewDBRef[[]byte, json.RawMessage](c.db, name, append(opts, ezdb.WithCodec(ezdb.JSONCodec{}))...)
+		} else {
+			_, err = ezdb.NewDBRef[[]byte, []byte](c.db, name, append(opts, ezdb.WithCodec(ezdb.BytesCodec{}))...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	report, err := c.db.Verify(ctx, ezdb.VerifyOptions{DBRefs: names})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "checked %d entries in %d databases\n", report.Checked, len(names))
+	for _, entry := range report.Corrupt {
+		fmt.Fprintf(c.out, "corrupt: %s %x: %v\n", entry.DBRef, entry.Key, entry.Err)
+	}
+	if !report.OK() {
+		return fmt.Errorf("%d corrupt entries found", len(report.Corrupt))
+	}
+
+	return nil
+}
+
+// writeFile creates the file path and calls fn with it, removing the file
+// again if fn fails.
+func writeFile(path string, fn func(w io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	err = fn(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// newCtl returns a ctl for a new environment, writing its output to the
+// returned buffer.
+func newCtl(t *testing.T) (*ctl, *bytes.Buffer) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	return &ctl{db: testutil.NewTempClient(t), out: out}, out
+}
+
+// runCmd runs the command name with args on c.
+func runCmd(c *ctl, name string, args ...string) error {
+	return commands[name].run(context.Background(), c, args)
+}
+
+// withStdin makes os.Stdin read data until the test finishes.
+func withStdin(t *testing.T, data string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdin")
+	err := os.WriteFile(path, []byte(data), 0o644)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestValidArgs(t *testing.T) {
+	tests := []struct {
+		nargs, n int
+		want     bool
+	}{
+		{0, 0, true},
+		{2, 2, true},
+		{2, 3, false},
+		{-1, 0, true},
+		{-1, 1, true},
+		{-1, 2, false},
+		{-2, 5, true},
+	}
+	for _, test := range tests {
+		if got := validArgs(test.nargs, test.n); got != test.want {
+			t.Errorf("validArgs(%d, %d) = %t, want %t", test.nargs, test.n, got, test.want)
+		}
+	}
+}
+
+func TestGetPutDel(t *testing.T) {
+	c, out := newCtl(t)
+
+	err := runCmd(c, "put", "users", "ada", "Lovelace")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = runCmd(c, "get", "users", "ada")
+	if err != nil || out.String() != "Lovelace\n" {
+		t.Fatalf("get = %q, %v", out, err)
+	}
+
+	out.Reset()
+	err = runCmd(c, "ls")
+	if err != nil || out.String() != "users\n" {
+		t.Fatalf("ls = %q, %v", out, err)
+	}
+
+	err = runCmd(c, "del", "users", "ada")
+	if err != nil {
+		t.Fatalf("del: %v", err)
+	}
+	err = runCmd(c, "get", "users", "ada")
+	if err == nil || err.Error() != "key not found" {
+		t.Fatalf("get of a deleted key returned %v", err)
+	}
+	err = runCmd(c, "del", "users", "ada")
+	if err == nil {
+		t.Fatal("del of a missing key succeeded")
+	}
+}
+
+func TestHex(t *testing.T) {
+	c, out := newCtl(t)
+	c.hex = true
+
+	err := runCmd(c, "put", "bin", "00ff", "cafe")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = runCmd(c, "get", "bin", "00ff")
+	if err != nil || out.String() != "cafe\n" {
+		t.Fatalf("get = %q, %v", out, err)
+	}
+	err = runCmd(c, "put", "bin", "zz", "00")
+	if err == nil {
+		t.Fatal("put of an invalid hex key succeeded")
+	}
+
+	// Values read from stdin may end in a newline.
+	withStdin(t, "beef\n")
+	err = runCmd(c, "put", "bin", "01", "-")
+	if err != nil {
+		t.Fatalf("put from stdin: %v", err)
+	}
+	out.Reset()
+	err = runCmd(c, "get", "bin", "01")
+	if err != nil || out.String() != "beef\n" {
+		t.Fatalf("get = %q, %v", out, err)
+	}
+}
+
+func TestPutStdin(t *testing.T) {
+	c, out := newCtl(t)
+	withStdin(t, "line 1\nline 2\n")
+
+	err := runCmd(c, "put", "docs", "a", "-")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = runCmd(c, "get", "docs", "a")
+	if err != nil || out.String() != "line 1\nline 2\n\n" {
+		t.Fatalf("get = %q, %v", out, err)
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	src, _ := newCtl(t)
+	err := runCmd(src, "put", "users", "ada", "Lovelace")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dump")
+	err = runCmd(src, "dump", path)
+	if err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	// Dumps never overwrite a file.
+	err = runCmd(src, "dump", path)
+	if err == nil {
+		t.Fatal("dump to an existing file succeeded")
+	}
+
+	dst, out := newCtl(t)
+	err = runCmd(dst, "load", path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	err = runCmd(dst, "get", "users", "ada")
+	if err != nil || out.String() != "Lovelace\n" {
+		t.Fatalf("get after load = %q, %v", out, err)
+	}
+
+	err = runCmd(dst, "load", filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("load of a missing file succeeded")
+	}
+	withStdin(t, "not a dump")
+	err = runCmd(dst, "load")
+	if err == nil {
+		t.Fatal("load of garbage succeeded")
+	}
+}
+
+func TestBackupCompact(t *testing.T) {
+	c, _ := newCtl(t)
+	err := runCmd(c, "put", "users", "ada", "Lovelace")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	check := func(dir string) {
+		t.Helper()
+		db, err := ezdb.New(dir, ezdb.WithNumDBs(4))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		err = db.Init()
+		if err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		defer db.Close()
+		out := &bytes.Buffer{}
+		err = runCmd(&ctl{db: db, out: out}, "get", "users", "ada")
+		if err != nil || out.String() != "Lovelace\n" {
+			t.Fatalf("get from copy = %q, %v", out, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "backup")
+	err = runCmd(c, "backup", path)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	restored := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(restored, f)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	check(restored)
+
+	compacted := filepath.Join(t.TempDir(), "compacted")
+	err = runCmd(c, "compact", compacted)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	check(compacted)
+}
+
+func TestVerifyCommand(t *testing.T) {
+	c, out := newCtl(t)
+	err := runCmd(c, "put", "docs", "a", `{"ok":true}`)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = runCmd(c, "put", "raw", "a", "not json")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	err = runCmd(c, "verify")
+	if err != nil || out.String() != "checked 2 entries in 2 databases\n" {
+		t.Fatalf("verify = %q, %v", out, err)
+	}
+
+	out.Reset()
+	err = runCmd(c, "verify", "-json", "docs")
+	if err != nil {
+		t.Fatalf("verify -json of JSON values: %v", err)
+	}
+	out.Reset()
+	err = runCmd(c, "verify", "-json", "raw")
+	if err == nil || !strings.Contains(out.String(), "corrupt: raw 61") {
+		t.Fatalf("verify -json of other values = %q, %v", out, err)
+	}
+
+	err = runCmd(c, "verify", "-bogus")
+	if err == nil {
+		t.Fatal("verify with an unknown flag succeeded")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "env")
+
+	// Read-only commands don't create environments.
+	err := run(context.Background(), dir, commands["get"], []string{"users", "ada"}, 4, false)
+	if err == nil {
+		t.Fatal("get from a missing environment succeeded")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("get created the environment: %v", err)
+	}
+
+	err = run(context.Background(), dir, commands["put"], []string{"users", "ada", "Lovelace"}, 4, false)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = run(context.Background(), dir, commands["get"], []string{"users", "bob"}, 4, false)
+	if err == nil || err.Error() != "key not found" {
+		t.Fatalf("get of a missing key returned %v", err)
+	}
+}
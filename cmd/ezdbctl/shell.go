@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+const (
+	// completionLimit bounds the keys offered for tab completion.
+	completionLimit = 100
+	// scanValueWidth bounds the width of the values scan prints.
+	scanValueWidth = 80
+)
+
+// shellCommands are the commands of the shell, with their usage.
+var shellCommands = map[string]string{
+	"help": "help",
+	"ls":   "ls",
+	"use":  "use <db>",
+	"get":  "get <key>",
+	"scan": "scan [prefix]",
+	"next": "next",
+	"page": "page <n>",
+	"stat": "stat",
+	"exit": "exit",
+}
+
+// shell is the state of an interactive session.
+type shell struct {
+	*ctl
+	ed *editor
+
+	dbs  []string
+	refs map[string]*ezdb.DBRef[[]byte, []byte]
+	// cur is the database keys refer to, set with use.
+	cur string
+
+	pageSize int
+	// scanPrefix and scanAfter are where next continues the last scan;
+	// scanAfter is nil once it is done.
+	scanPrefix []byte
+	scanAfter  []byte
+}
+
+func runShell(ctx context.Context, c *ctl, args []string) error {
+	sh := &shell{
+		ctl:      c,
+		refs:     make(map[string]*ezdb.DBRef[[]byte, []byte]),
+		pageSize: 20,
+	}
+	sh.ed = &editor{
+		in:       bufio.NewReader(os.Stdin),
+		out:      c.out,
+		fd:       int(os.Stdin.Fd()),
+		raw:      true,
+		complete: sh.complete,
+	}
+
+	var err error
+	sh.dbs, err = c.db.ListDBs()
+	if err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		prompt := "ezdb> "
+		if sh.cur != "" {
+			prompt = "ezdb:" + sh.cur + "> "
+		}
+		line, err := sh.ed.readLine(prompt)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		fields, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %v\n", err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "exit" || fields[0] == "quit" {
+			return nil
+		}
+		err = sh.exec(ctx, fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// exec runs the shell command name.
+func (sh *shell) exec(ctx context.Context, name string, args []string) error {
+	usage, ok := shellCommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see help", name)
+	}
+	wantArgs := strings.Count(usage, "<")
+	if len(args) < wantArgs || len(args) > strings.Count(usage, " ") {
+		return fmt.Errorf("usage: %s", usage)
+	}
+
+	switch name {
+	case "help":
+		names := make([]string, 0, len(shellCommands))
+		for name := range shellCommands {
+			names = append(names, shellCommands[name])
+		}
+		sort.Strings(names)
+		fmt.Fprintln(sh.out, strings.Join(names, "\n"))
+		fmt.Fprintln(sh.out, "Keys are typed as they are, quoted as Go strings if they aren't printable, or in hex with -hex.")
+		return nil
+
+	case "ls":
+		dbs, err := sh.db.ListDBs()
+		if err != nil {
+			return err
+		}
+		sh.dbs = dbs
+		fmt.Fprintln(sh.out, strings.Join(dbs, "\n"))
+		return nil
+
+	case "use":
+		_, err := sh.ref(args[0])
+		if err != nil {
+			return err
+		}
+		sh.cur = args[0]
+		sh.scanAfter = nil
+		return nil
+
+	case "get":
+		return sh.get(args[0])
+
+	case "scan":
+		sh.scanPrefix = nil
+		if len(args) > 0 {
+			prefix, err := sh.parseKey(args[0])
+			if err != nil {
+				return err
+			}
+			sh.scanPrefix = prefix
+		}
+		sh.scanAfter = []byte{}
+		return sh.scanPage()
+
+	case "next":
+		if sh.scanAfter == nil {
+			return errors.New("no scan to continue")
+		}
+		return sh.scanPage()
+
+	case "page":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return errors.New("page size must be a positive number")
+		}
+		sh.pageSize = n
+		return nil
+
+	case "stat":
+		err := runStat(ctx, sh.ctl, nil)
+		if err != nil || sh.cur == "" {
+			return err
+		}
+		ref, err := sh.ref(sh.cur)
+		if err != nil {
+			return err
+		}
+		stat, err := ref.Stat()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "%s: %d entries, %d bytes\n", sh.cur, stat.Entries, stat.Size)
+		return nil
+	}
+
+	return nil
+}
+
+// ref returns the DBRef of the database name, opening it on first use.
+func (sh *shell) ref(name string) (*ezdb.DBRef[[]byte, []byte], error) {
+	if ref, ok := sh.refs[name]; ok {
+		return ref, nil
+	}
+
+	ref, err := sh.ctl.ref(name)
+	if err != nil {
+		return nil, err
+	}
+	sh.refs[name] = ref
+
+	return ref, nil
+}
+
+// curRef returns the DBRef of the database set with use.
+func (sh *shell) curRef() (*ezdb.DBRef[[]byte, []byte], error) {
+	if sh.cur == "" {
+		return nil, errors.New("no database selected, see use")
+	}
+
+	return sh.ref(sh.cur)
+}
+
+func (sh *shell) get(arg string) error {
+	ref, err := sh.curRef()
+	if err != nil {
+		return err
+	}
+	key, err := sh.parseKey(arg)
+	if err != nil {
+		return err
+	}
+
+	val, err := ref.Get(&key)
+	if errors.Is(err, ezdb.ErrNotFound) {
+		return errors.New("key not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(sh.out, sh.formatValue(*val))
+	return nil
+}
+
+// scanPage prints the next page of the current scan.
+func (sh *shell) scanPage() error {
+	ref, err := sh.curRef()
+	if err != nil {
+		return err
+	}
+
+	after := sh.scanAfter
+	q := ref.Query().Limit(sh.pageSize + 1)
+	if len(sh.scanPrefix) > 0 {
+		q = q.WherePrefix(string(sh.scanPrefix))
+	}
+	if len(after) > 0 {
+		q = q.Filter(func(key *[]byte, val *[]byte) bool {
+			return bytes.Compare(*key, after) > 0
+		})
+	}
+	entries, err := q.All()
+	if err != nil {
+		return err
+	}
+
+	more := len(entries) > sh.pageSize
+	if more {
+		entries = entries[:sh.pageSize]
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(sh.out, "%s  %s\n", sh.formatKey(entry.Key), sh.summarizeValue(entry.Value))
+	}
+
+	sh.scanAfter = nil
+	if more {
+		sh.scanAfter = entries[len(entries)-1].Key
+		fmt.Fprintln(sh.out, "-- more, type next --")
+	}
+
+	return nil
+}
+
+// complete returns the completions of the last word of line: command names
+// for the first, database names for use and keys of the current database for
+// get and scan.
+func (sh *shell) complete(line string) []string {
+	fields := strings.Fields(line)
+	word := line[strings.LastIndexByte(line, ' ')+1:]
+	arg := len(fields)
+	if word != "" {
+		arg--
+	}
+
+	var words []string
+	switch {
+	case arg == 0:
+		for name := range shellCommands {
+			words = append(words, name)
+		}
+	case arg == 1 && fields[0] == "use":
+		words = sh.dbs
+	case arg == 1 && (fields[0] == "get" || fields[0] == "scan"):
+		words = sh.completeKeys(word)
+	}
+
+	var candidates []string
+	for _, w := range words {
+		if strings.HasPrefix(w, word) {
+			candidates = append(candidates, w)
+		}
+	}
+	sort.Strings(candidates)
+
+	return candidates
+}
+
+// completeKeys returns the formatted keys of the current database that could
+// complete word.
+func (sh *shell) completeKeys(word string) []string {
+	ref, err := sh.curRef()
+	if err != nil {
+		return nil
+	}
+
+	// A quoted key can only be completed once it has been parsed, so the
+	// prefix is matched against the formatted keys instead.
+	prefix := word
+	if sh.hex {
+		b, err := hex.DecodeString(word[:len(word)/2*2])
+		if err != nil {
+			return nil
+		}
+		prefix = string(b)
+	} else if strings.HasPrefix(word, `"`) {
+		prefix = ""
+	}
+
+	var keys []string
+	q := ref.Query().Limit(completionLimit)
+	if prefix != "" {
+		q = q.WherePrefix(prefix)
+	}
+	err = q.Each(func(key *[]byte, val *[]byte) error {
+		keys = append(keys, sh.formatKey(*key))
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+
+	return keys
+}
+
+// splitArgs splits line into words at spaces, keeping quoted keys, which
+// may contain spaces, in one word with their quotes.
+func splitArgs(line string) ([]string, error) {
+	var words []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		if line[i] == '"' {
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(line) {
+				return nil, errors.New("unterminated quoted key")
+			}
+			i++
+		} else {
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+		}
+		words = append(words, line[start:i])
+	}
+
+	return words, nil
+}
+
+// parseKey returns the key typed as s, which formatKey formats: in hex with
+// -hex, unquoted if it is a quoted Go string and as it is otherwise.
+func (sh *shell) parseKey(s string) ([]byte, error) {
+	if sh.hex || !strings.HasPrefix(s, `"`) {
+		return sh.decode(s)
+	}
+
+	unquoted, err := strconv.Unquote(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quoted key: %w", err)
+	}
+
+	return []byte(unquoted), nil
+}
+
+func (sh *shell) formatKey(key []byte) string {
+	switch {
+	case sh.hex:
+		return hex.EncodeToString(key)
+	case printable(key) && !bytes.HasPrefix(key, []byte(`"`)) && bytes.IndexFunc(key, unicode.IsSpace) < 0:
+		return string(key)
+	default:
+		return strconv.Quote(string(key))
+	}
+}
+
+// formatValue formats val for get: indented if it is JSON, as it is if it is
+// printable text and as a hex dump otherwise.
+func (sh *shell) formatValue(val []byte) string {
+	var buf bytes.Buffer
+	switch {
+	case sh.hex:
+		return hex.EncodeToString(val)
+	case json.Valid(val) && json.Indent(&buf, val, "", "  ") == nil:
+		return buf.String()
+	case printable(val):
+		return string(val)
+	default:
+		return strings.TrimRight(hex.Dump(val), "\n")
+	}
+}
+
+// summarizeValue formats val on one line of at most scanValueWidth
+// characters for scan.
+func (sh *shell) summarizeValue(val []byte) string {
+	var s string
+	var buf bytes.Buffer
+	switch {
+	case sh.hex:
+		s = hex.EncodeToString(val)
+	case json.Valid(val) && json.Compact(&buf, val) == nil:
+		s = buf.String()
+	case printable(val) && !bytes.ContainsAny(val, "\r\n"):
+		s = string(val)
+	default:
+		s = strconv.Quote(string(val))
+	}
+
+	if utf8.RuneCountInString(s) > scanValueWidth {
+		s = string([]rune(s)[:scanValueWidth-3]) + "..."
+	}
+
+	return s
+}
+
+// printable reports whether b is UTF-8 text without control characters other
+// than whitespace.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
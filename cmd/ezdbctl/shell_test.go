@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// newShell returns a shell for a new environment whose databases users and
+// docs hold a few entries, writing its output to the returned buffer.
+func newShell(t *testing.T) (*shell, *bytes.Buffer) {
+	t.Helper()
+
+	c, out := newCtl(t)
+	for _, kv := range [][3]string{
+		{"users", "ada", "Lovelace"},
+		{"users", "alan", "Turing"},
+		{"users", "grace", "Hopper"},
+		{"docs", "a b", `{"title":"x"}`},
+	} {
+		err := runCmd(c, "put", kv[:]...)
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	out.Reset()
+
+	sh := &shell{ctl: c, refs: make(map[string]*ezdb.DBRef[[]byte, []byte]), pageSize: 20}
+	var err error
+	sh.dbs, err = c.db.ListDBs()
+	if err != nil {
+		t.Fatalf("ListDBs: %v", err)
+	}
+	return sh, out
+}
+
+func TestShell(t *testing.T) {
+	sh, out := newShell(t)
+	withStdin(t, strings.Join([]string{
+		"get ada",
+		"use users",
+		"get ada",
+		"get bob",
+		"page 2",
+		"scan",
+		"next",
+		"next",
+		"scan al",
+		"use docs",
+		`get "a b"`,
+		"stat",
+		"page x",
+		"bogus",
+		"use",
+		"exit",
+		"get ada",
+	}, "\n"))
+
+	err := runShell(context.Background(), sh.ctl, nil)
+	if err != nil {
+		t.Fatalf("runShell: %v", err)
+	}
+	for _, want := range []string{
+		"ezdb> error: no database selected, see use\n",
+		"ezdb:users> Lovelace\n",
+		"error: key not found\n",
+		"ezdb:users> ada  Lovelace\nalan  Turing\n-- more, type next --\n",
+		"ezdb:users> grace  Hopper\n",
+		"ezdb:users> error: no scan to continue\n",
+		"ezdb:users> alan  Turing\nezdb:users> ",
+		"ezdb:docs> {\n  \"title\": \"x\"\n}\n",
+		"docs: 1 entries",
+		"error: page size must be a positive number\n",
+		"error: unknown command \"bogus\", see help\n",
+		"error: usage: use <db>\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	// Nothing runs after exit.
+	if strings.Count(out.String(), "Lovelace") != 2 {
+		t.Errorf("output after exit:\n%s", out)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	sh, _ := newShell(t)
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{"exit", "get", "help", "ls", "next", "page", "scan", "stat", "use"}},
+		{"s", []string{"scan", "stat"}},
+		{"use ", []string{"docs", "users"}},
+		{"use u", []string{"users"}},
+		// Keys are only completed once a database is selected.
+		{"get a", nil},
+		{"next ", nil},
+	}
+	for _, test := range tests {
+		if got := sh.complete(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("complete(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+
+	sh.cur = "users"
+	if got := sh.complete("get a"); !reflect.DeepEqual(got, []string{"ada", "alan"}) {
+		t.Errorf("complete of keys = %q", got)
+	}
+	if got := sh.complete("scan g"); !reflect.DeepEqual(got, []string{"grace"}) {
+		t.Errorf("complete of a scan prefix = %q", got)
+	}
+	sh.cur = "docs"
+	if got := sh.complete(`get "a`); !reflect.DeepEqual(got, []string{`"a b"`}) {
+		t.Errorf("complete of a quoted key = %q", got)
+	}
+
+	sh.hex = true
+	sh.cur = "users"
+	if got := sh.complete("get 616c"); !reflect.DeepEqual(got, []string{"616c616e"}) {
+		t.Errorf("complete of a hex key = %q", got)
+	}
+	if got := sh.complete("get zz"); got != nil {
+		t.Errorf("complete of invalid hex = %q", got)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  get\tada  ", []string{"get", "ada"}},
+		{`get "a b"`, []string{"get", `"a b"`}},
+		{`get "a \" b" c`, []string{"get", `"a \" b"`, "c"}},
+	}
+	for _, test := range tests {
+		got, err := splitArgs(test.line)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitArgs(%q) = %q, %v, want %q", test.line, got, err, test.want)
+		}
+	}
+
+	_, err := splitArgs(`get "a b`)
+	if err == nil {
+		t.Fatal("splitArgs of an unterminated quote succeeded")
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	sh := &shell{ctl: &ctl{}}
+
+	for _, key := range []string{"ada", "a b", `"quoted"`, "\x00\x01", "tab\t", "\xff"} {
+		s := sh.formatKey([]byte(key))
+		got, err := sh.parseKey(s)
+		if err != nil || string(got) != key {
+			t.Errorf("parseKey(formatKey(%q)) = %q, %v", key, got, err)
+		}
+	}
+	if got := sh.formatKey([]byte("a b")); got != `"a b"` {
+		t.Errorf("formatKey of a key with a space = %s", got)
+	}
+	_, err := sh.parseKey(`"\z"`)
+	if err == nil {
+		t.Error("parseKey of an invalid quoted key succeeded")
+	}
+
+	sh.hex = true
+	if got := sh.formatKey([]byte{0, 0xff}); got != "00ff" {
+		t.Errorf("formatKey with -hex = %s", got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	sh := &shell{ctl: &ctl{}}
+
+	if got := sh.formatValue([]byte(`{"a":1}`)); got != "{\n  \"a\": 1\n}" {
+		t.Errorf("formatValue of JSON = %q", got)
+	}
+	if got := sh.formatValue([]byte("two\nlines")); got != "two\nlines" {
+		t.Errorf("formatValue of text = %q", got)
+	}
+	if got := sh.formatValue([]byte{0, 1}); !strings.HasPrefix(got, "00000000  00 01") {
+		t.Errorf("formatValue of binary = %q", got)
+	}
+
+	if got := sh.summarizeValue([]byte("{\n  \"a\": 1\n}")); got != `{"a":1}` {
+		t.Errorf("summarizeValue of JSON = %q", got)
+	}
+	if got := sh.summarizeValue([]byte("two\nlines")); got != `"two\nlines"` {
+		t.Errorf("summarizeValue of text = %q", got)
+	}
+	long := sh.summarizeValue([]byte(strings.Repeat("é", 200)))
+	if n := len([]rune(long)); n != scanValueWidth || !strings.HasSuffix(long, "...") {
+		t.Errorf("summarizeValue of a long value has %d runes: %q", n, long)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal fd into raw mode, so input is read a key at a
+// time without being echoed, and returns a function that restores its
+// previous mode. Output processing is left on, so "\n" still starts a new
+// line.
+func makeRaw(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	err = ioctlTermios(fd, syscall.TCGETS, &old)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INLCR | syscall.IGNCR | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	err = ioctlTermios(fd, syscall.TCSETS, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { ioctlTermios(fd, syscall.TCSETS, &old) }, nil
+}
+
+func ioctlTermios(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package ezdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// WithFlushInterval makes the Puts and Deletes of the Client's DBRefs wait up
+// to interval for other writes, and commits all writes arriving within the
+// window in a single transaction. Every write still returns once it is
+// committed, with its own error: if one fails, the others are committed in
+// transactions of their own. This raises the throughput of bursts of small
+// concurrent writes, at the cost of up to interval of latency per write, so
+// it doesn't suit a single goroutine writing in a loop. Flush commits the
+// waiting writes right away. Unlike WithBatchSize, which batches transactions
+// that arrive while the writer is busy, the window applies even when it is
+// idle.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(option *options) error {
+		if interval <= 0 {
+			return errors.New("flush interval must be positive")
+		}
+		option.flushInterval = interval
+		return nil
+	}
+}
+
+// coalescer groups the writes arriving within its interval into one
+// transaction.
+type coalescer struct {
+	db       *Client
+	interval time.Duration
+
+	// target is the latency target of WithLatencyTarget, if set, and limit
+	// the number of writes a transaction currently takes.
+	target time.Duration
+	limit  int
+
+	mu       sync.Mutex
+	pending  []*coalescedWrite
+	timer    *time.Timer
+	draining bool
+	// committing counts the batches taken from pending whose transaction
+	// hasn't finished yet.
+	committing int
+}
+
+// coalescedWrite is a write waiting for its transaction.
+type coalescedWrite struct {
+	fn    func(txn writeTxn) error
+	done  chan error
+	start time.Time
+}
+
+// writeFailed is an error returned by the function of a coalesced write,
+// which aborts its group.
+type writeFailed struct {
+	err error
+}
+
+func (e writeFailed) Error() string {
+	return e.err.Error()
+}
+
+// write runs fn in the transaction of the current window and returns its
+// error once the transaction commits.
+func (c *coalescer) write(fn func(txn writeTxn) error) error {
+	w := &coalescedWrite{fn: fn, done: make(chan error, 1), start: time.Now()}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, w)
+	switch {
+	case c.target > 0:
+		if !c.draining {
+			c.draining = true
+			go c.drain()
+		}
+	case c.timer == nil:
+		c.timer = time.AfterFunc(c.interval, func() {
+			c.flush()
+		})
+	}
+	c.mu.Unlock()
+
+	return <-w.done
+}
+
+// flush commits the waiting writes, returning an error if the transaction
+// failed for a reason other than one of the writes.
+func (c *coalescer) flush() error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.committing++
+	c.mu.Unlock()
+
+	err := c.commit(batch)
+	c.committed()
+	return err
+}
+
+// committed records that the transaction of a batch taken from pending has
+// finished.
+func (c *coalescer) committed() {
+	c.mu.Lock()
+	c.committing--
+	c.mu.Unlock()
+}
+
+// settle commits the waiting writes and waits until the transactions of all
+// writes taken before have finished, returning an error if one of its own
+// failed for a reason other than one of the writes.
+func (c *coalescer) settle() error {
+	var err error
+	for {
+		c.mu.Lock()
+		pending, committing := len(c.pending), c.committing
+		c.mu.Unlock()
+
+		switch {
+		case pending > 0:
+			if flushErr := c.flush(); flushErr != nil {
+				err = flushErr
+			}
+		case committing > 0:
+			time.Sleep(time.Millisecond)
+		default:
+			return err
+		}
+	}
+}
+
+// commit commits batch in one transaction, returning an error if it failed
+// for a reason other than one of the writes.
+func (c *coalescer) commit(batch []*coalescedWrite) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := c.db.update(func(txn writeTxn) error {
+		for _, w := range batch {
+			err := w.fn(txn)
+			if err != nil {
+				return writeFailed{err: err}
+			}
+		}
+		return nil
+	})
+
+	var failed writeFailed
+	if errors.As(err, &failed) {
+		// Find out which writes fail by running each on its own.
+		for _, w := range batch {
+			w.done <- c.db.update(w.fn)
+		}
+		return nil
+	}
+	for _, w := range batch {
+		w.done <- err
+	}
+
+	return err
+}
+
+// Flush commits the writes waiting for their transaction, see
+// WithFlushInterval and WithLatencyTarget, and returns once they are
+// committed. It fails if their transaction does; the writes return their own
+// errors either way. Without either option, Flush does nothing.
+func (db *Client) Flush() error {
+	if db.coalescer == nil {
+		return nil
+	}
+
+	return db.coalescer.flush()
+}
+
+// write runs fn in a write transaction like update, coalescing it with other
+// writes if the Client has a flush interval or latency target.
+func (db *Client) write(fn func(txn writeTxn) error) error {
+	if db.coalescer == nil {
+		return db.update(fn)
+	}
+
+	db.mu.Lock()
+	refused := db.closed || db.draining
+	db.mu.Unlock()
+	if refused {
+		return ErrClosed
+	}
+
+	// A panicking write fails on its own instead of its whole group.
+	return db.coalescer.write(recovering(fn))
+}
@@ -0,0 +1,145 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestFlushInterval(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithFlushInterval(50*time.Millisecond), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 1)
+
+	// Concurrent writes within the window share a transaction, and a write
+	// that fails doesn't fail the others.
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, val := fmt.Sprintf("k%04d", i+1), fmt.Sprintf("v%d", i+1)
+			if i == 9 {
+				key = "missing"
+				errs[i] = ref.Delete(&key)
+				return
+			}
+			errs[i] = ref.Put(&key, &val)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs[:9] {
+		if err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if !errors.Is(errs[9], ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", errs[9])
+	}
+	wantN(t, ref, 10)
+
+	// Without the failing write, the group commits in one transaction.
+	writes := faults.Count(ezdb.FaultWrite)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+			errs[i] = ref.Put(&key, &val)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if n := faults.Count(ezdb.FaultWrite) - writes; n > 2 {
+		t.Fatalf("10 concurrent writes took %d transactions", n)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithFlushInterval(time.Hour))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Writes wait for the window, or for Flush.
+	done := make(chan error)
+	go func() {
+		key, val := "k0000", "v0"
+		done <- ref.Put(&key, &val)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Put returned %v before the window closed", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// Flush until the write has joined the window.
+	flushed := false
+	for !flushed {
+		err = db.Flush()
+		if err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		select {
+		case err = <-done:
+			flushed = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	wantN(t, ref, 1)
+
+	// Close commits the waiting writes too, and later ones fail. The write
+	// may not have joined the window before Close, failing instead.
+	go func() {
+		key, val := "k0001", "v1"
+		done <- ref.Put(&key, &val)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = <-done
+	if err != nil && !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Put flushed by Close: %v", err)
+	}
+	key, val := "k0002", "v2"
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Put after Close returned %v, want ErrClosed", err)
+	}
+
+	// Flush without a window does nothing.
+	err = testutil.NewTempClient(t).Flush()
+	if err != nil {
+		t.Fatalf("Flush without a window: %v", err)
+	}
+}
+
+func TestWithFlushIntervalErrors(t *testing.T) {
+	_, err := ezdb.New(t.TempDir(), ezdb.WithFlushInterval(0))
+	if err == nil {
+		t.Fatal("New with a zero flush interval succeeded")
+	}
+	_, err = ezdb.New(t.TempDir(), ezdb.WithFlushInterval(time.Second), ezdb.WithLatencyTarget(time.Second))
+	if err == nil {
+		t.Fatal("New with a flush interval and a latency target succeeded")
+	}
+}
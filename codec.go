@@ -0,0 +1,415 @@
+package ezdb
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// castagnoli is the CRC-32C table the checksums of WithChecksums use.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Codec converts keys or values to and from the bytes stored in LMDB.
+// Marshal is passed a pointer to the key or value, and Unmarshal a pointer to
+// the zero value to decode into.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// SizedCodec is a Codec that can tell the size of an encoding before making
+// it, and then write it into a buffer of that size. Values stored with one
+// are encoded right into the space the storage engine reserves for them,
+// where it supports that, saving a copy of every value.
+type SizedCodec interface {
+	Codec
+	// Size returns the size of the encoding of v.
+	Size(v any) (int, error)
+	// MarshalTo writes the encoding of v into buf, which is Size(v) bytes.
+	MarshalTo(buf []byte, v any) error
+}
+
+// GobCodec encodes with encoding/gob. It is the default codec.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := gob.NewEncoder(&buf)
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decodes a value from data into a pointer to a value.
+// Will try and fail if the decoded type is not assignable to the thing we're decoding into.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	return decoder.Decode(v)
+}
+
+// JSONCodec encodes with encoding/json, which keeps stored values readable by
+// other tools.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// encoding identifies the codecs of ref and the transformations of its
+// encoded values, such as "ezdb.GobCodec/ezdb.JSONCodec+flate+crc32c".
+func (ref *DBRef[K, V]) encoding() string {
+	enc := fmt.Sprintf("%T/%T", ref.options.keyCodec, ref.options.valCodec)
+	if ref.options.compressLevel != nil {
+		enc += "+flate"
+	}
+	if ref.options.checksums {
+		enc += "+crc32c"
+	}
+	if ref.options.encodes() {
+		enc += "+transform"
+	}
+
+	return enc
+}
+
+func (ref *DBRef[K, V]) encodeKey(key *K) ([]byte, error) {
+	data, err := ref.options.keyCodec.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	if ref.options.keyPrefixes != nil {
+		data = ref.options.keyPrefixes.compress(data)
+	}
+	if len(ref.prefix) > 0 {
+		data = append(bytes.Clone(ref.prefix), data...)
+	}
+	if ref.options.dbFlags&dbIntegerKey != 0 {
+		err = checkIntegerKey(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func (ref *DBRef[K, V]) decodeKey(data []byte) (*K, error) {
+	if !bytes.HasPrefix(data, ref.prefix) {
+		return nil, errors.New("key outside of namespace")
+	}
+
+	data = data[len(ref.prefix):]
+	if ref.options.keyPrefixes != nil {
+		var err error
+		data, err = ref.options.keyPrefixes.expand(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := new(K)
+	err := ref.options.keyCodec.Unmarshal(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (ref *DBRef[K, V]) encodeVal(val *V) ([]byte, error) {
+	data, err := ref.options.valCodec.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.options.transforms) > 0 {
+		data, err = ref.options.encodeTransforms(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ref.options.compressLevel != nil {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, *ref.options.compressLevel)
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	if ref.options.checksums {
+		data = binary.BigEndian.AppendUint32(data, crc32.Checksum(data, castagnoli))
+	}
+
+	return data, nil
+}
+
+// encodedVal is a value about to be written: its encoding or, for a
+// SizedCodec, the size of its encoding and a function writing it into a
+// buffer of that size.
+type encodedVal struct {
+	bytes  []byte
+	size   int
+	encode func(buf []byte) error
+	// chunked is set if bytes is the manifest of chunks already written,
+	// see WithChunking.
+	chunked bool
+}
+
+func (enc encodedVal) len() int {
+	if enc.encode != nil {
+		return enc.size
+	}
+
+	return len(enc.bytes)
+}
+
+// materialize returns the encoding of enc.
+func (enc encodedVal) materialize() ([]byte, error) {
+	if enc.encode == nil {
+		return enc.bytes, nil
+	}
+
+	buf := make([]byte, enc.size)
+	return buf, enc.encode(buf)
+}
+
+// encodeValSized prepares val to be encoded in place by the value codec, and
+// reports whether it can be: the codec must be a SizedCodec, and values must
+// neither be compressed nor transformed.
+func (ref *DBRef[K, V]) encodeValSized(val *V) (enc encodedVal, ok bool, err error) {
+	codec, ok := ref.options.valCodec.(SizedCodec)
+	if !ok || ref.options.compressLevel != nil || ref.options.encodes() {
+		return encodedVal{}, false, nil
+	}
+
+	size, err := codec.Size(val)
+	if err != nil {
+		return encodedVal{}, true, err
+	}
+	checksums := ref.options.checksums
+	enc = encodedVal{size: size, encode: func(buf []byte) error {
+		err := codec.MarshalTo(buf[:size], val)
+		if err != nil {
+			return err
+		}
+		if checksums {
+			binary.BigEndian.PutUint32(buf[size:], crc32.Checksum(buf[:size], castagnoli))
+		}
+		return nil
+	}}
+	if checksums {
+		enc.size += 4
+	}
+
+	return enc, true, nil
+}
+
+func (ref *DBRef[K, V]) decodeVal(data []byte) (*V, error) {
+	val := new(V)
+	err := ref.decodeValInto(data, val)
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// decodeValInto decodes stored value bytes into v, which need not be a *V:
+// any type the value codec can decode the value into will do.
+func (ref *DBRef[K, V]) decodeValInto(data []byte, v any) error {
+	if ref.options.chunking != nil {
+		switch {
+		case len(data) == 0:
+			return errors.New("value without chunk tag")
+		case data[0] == chunkManifest:
+			return errors.New("value is stored in chunks")
+		}
+		data = data[1:]
+	}
+
+	if ref.options.checksums {
+		if len(data) < 4 {
+			return fmt.Errorf("%w: value too short", ErrChecksum)
+		}
+		sum := binary.BigEndian.Uint32(data[len(data)-4:])
+		data = data[:len(data)-4]
+		if crc32.Checksum(data, castagnoli) != sum {
+			return fmt.Errorf("%w in %q", ErrChecksum, ref.id)
+		}
+	}
+
+	if ref.options.compressLevel != nil {
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+
+		var err error
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(ref.options.transforms) > 0 {
+		var err error
+		data, err = ref.options.decodeTransforms(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ref.options.valCodec.Unmarshal(data, v)
+}
+
+// BytesCodec stores byte slices as they are, for keys and values that are
+// already encoded, or to read the stored bytes of a DBRef as they are.
+type BytesCodec struct{}
+
+func (BytesCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("BytesCodec cannot encode %T", v)
+	}
+
+	return *b, nil
+}
+
+func (BytesCodec) Size(v any) (int, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return 0, fmt.Errorf("BytesCodec cannot encode %T", v)
+	}
+
+	return len(*b), nil
+}
+
+func (BytesCodec) MarshalTo(buf []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("BytesCodec cannot encode %T", v)
+	}
+	copy(buf, *b)
+
+	return nil
+}
+
+func (BytesCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("BytesCodec cannot decode into %T", v)
+	}
+	*b = bytes.Clone(data)
+
+	return nil
+}
+
+// StringCodec stores strings as their bytes, without the overhead of gob, for
+// string keys and values. As keys, they are in the order of the strings.
+// Values are written in place through Size and MarshalTo, which don't
+// allocate; Marshal and Unmarshal copy the string once, since the bytes read
+// from the database are only valid during their transaction.
+type StringCodec struct{}
+
+func (StringCodec) Marshal(v any) ([]byte, error) {
+	s, ok := v.(*string)
+	if !ok {
+		return nil, fmt.Errorf("StringCodec cannot encode %T", v)
+	}
+
+	return []byte(*s), nil
+}
+
+func (StringCodec) Size(v any) (int, error) {
+	s, ok := v.(*string)
+	if !ok {
+		return 0, fmt.Errorf("StringCodec cannot encode %T", v)
+	}
+
+	return len(*s), nil
+}
+
+func (StringCodec) MarshalTo(buf []byte, v any) error {
+	s, ok := v.(*string)
+	if !ok {
+		return fmt.Errorf("StringCodec cannot encode %T", v)
+	}
+	copy(buf, *s)
+
+	return nil
+}
+
+func (StringCodec) Unmarshal(data []byte, v any) error {
+	s, ok := v.(*string)
+	if !ok {
+		return fmt.Errorf("StringCodec cannot decode into %T", v)
+	}
+	*s = string(data)
+
+	return nil
+}
+
+// Uint64Codec stores uint64s as 8 big-endian bytes, for counters and other
+// integer keys and values. As keys, they are in numeric order. Only Marshal
+// allocates, for the 8 bytes it returns.
+type Uint64Codec struct{}
+
+func (Uint64Codec) Marshal(v any) ([]byte, error) {
+	n, ok := v.(*uint64)
+	if !ok {
+		return nil, fmt.Errorf("Uint64Codec cannot encode %T", v)
+	}
+
+	return binary.BigEndian.AppendUint64(nil, *n), nil
+}
+
+func (Uint64Codec) Size(v any) (int, error) {
+	if _, ok := v.(*uint64); !ok {
+		return 0, fmt.Errorf("Uint64Codec cannot encode %T", v)
+	}
+
+	return 8, nil
+}
+
+func (Uint64Codec) MarshalTo(buf []byte, v any) error {
+	n, ok := v.(*uint64)
+	if !ok {
+		return fmt.Errorf("Uint64Codec cannot encode %T", v)
+	}
+	binary.BigEndian.PutUint64(buf, *n)
+
+	return nil
+}
+
+func (Uint64Codec) Unmarshal(data []byte, v any) error {
+	n, ok := v.(*uint64)
+	if !ok {
+		return fmt.Errorf("Uint64Codec cannot decode into %T", v)
+	}
+	if len(data) != 8 {
+		return fmt.Errorf("Uint64Codec cannot decode %d bytes", len(data))
+	}
+	*n = binary.BigEndian.Uint64(data)
+
+	return nil
+}
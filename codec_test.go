@@ -0,0 +1,432 @@
+package ezdb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type user struct {
+	Name  string
+	Email string
+	Bio   string
+}
+
+// rawRef opens the named database of db with values read as stored.
+func rawRef(t *testing.T, db *ezdb.Client, name string, opts ...ezdb.RefOption) *ezdb.DBRef[string, []byte] {
+	t.Helper()
+
+	ref, err := ezdb.NewDBRef[string, []byte](db, name, append(opts, ezdb.WithCodec(ezdb.BytesCodec{}))...)
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	return ref
+}
+
+func TestNewDBRefCodecs(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithKeyCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "ada", user{Name: "Ada", Email: "ada@example.com"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	raw := rawRef(t, db, "users", ezdb.WithKeyCodec(ezdb.JSONCodec{}))
+	stored, err := raw.Get(&key)
+	if err != nil {
+		t.Fatalf("Get raw: %v", err)
+	}
+	var decoded user
+	err = json.Unmarshal(*stored, &decoded)
+	if err != nil || decoded != val {
+		t.Fatalf("stored value %s isn't the JSON of %v: %v", *stored, val, err)
+	}
+}
+
+func TestNewDBRefCompression(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithCompression(9))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "ada", user{Name: "Ada", Bio: strings.Repeat("mathematician ", 100)}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	plain, _ := json.Marshal(val)
+	size, err := ref.GetSize(&key)
+	if err != nil {
+		t.Fatalf("GetSize: %v", err)
+	}
+	if size >= len(plain)/4 {
+		t.Fatalf("compressed value is %d bytes, plain %d", size, len(plain))
+	}
+
+	// Compressed values can't be read without decompressing them.
+	uncompressed, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	_, err = uncompressed.Get(&key)
+	if err == nil {
+		t.Fatal("Get of a compressed value without compression succeeded")
+	}
+}
+
+func TestNewDBRefInvalidOptions(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	_, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithCompression(42))
+	if err == nil {
+		t.Fatal("NewDBRef with compression level 42 succeeded")
+	}
+	_, err = ezdb.NewDBRef[string, string](db, "ref", ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithCompression(-3))
+	if err == nil {
+		t.Fatal("NewDBRef with compression level -3 succeeded")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	raw := rawRef(t, db, "users")
+
+	for name, opts := range map[string][]ezdb.RefOption{
+		"json":       {ezdb.WithCodec(ezdb.JSONCodec{})},
+		"compressed": {ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithCompression(1)},
+	} {
+		ref, err := ezdb.NewDBRef[string, user](db, "users", append(opts, ezdb.WithChecksums())...)
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		key, val := name, user{Name: "Ada", Bio: strings.Repeat("mathematician ", 10)}
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, err := ref.Get(&key)
+		if err != nil || *got != val {
+			t.Fatalf("Get with %s values = %v, %v", name, got, err)
+		}
+
+		stored, err := raw.Get(&key)
+		if err != nil {
+			t.Fatalf("Get raw: %v", err)
+		}
+		(*stored)[0] ^= 1
+		err = raw.Put(&key, stored)
+		if err != nil {
+			t.Fatalf("Put raw: %v", err)
+		}
+		_, err = ref.Get(&key)
+		if !errors.Is(err, ezdb.ErrChecksum) {
+			t.Fatalf("Get of a corrupted %s value returned %v, want ErrChecksum", name, err)
+		}
+
+		short := []byte{1, 2}
+		err = raw.Put(&key, &short)
+		if err != nil {
+			t.Fatalf("Put raw: %v", err)
+		}
+		_, err = ref.Get(&key)
+		if !errors.Is(err, ezdb.ErrChecksum) {
+			t.Fatalf("Get of a truncated %s value returned %v, want ErrChecksum", name, err)
+		}
+	}
+
+	// Values encoded in place get their checksums too.
+	sized, err := ezdb.NewDBRef[string, []byte](db, "bytes", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithChecksums())
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "k", []byte("value")
+	err = sized.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	stored, err := rawRef(t, db, "bytes").Get(&key)
+	if err != nil || len(*stored) != len(val)+4 {
+		t.Fatalf("stored value = %v, %v, want the value and its checksum", stored, err)
+	}
+	got, err := sized.Get(&key)
+	if err != nil || string(*got) != "value" {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	// Verify reports mismatches.
+	(*stored)[0] ^= 1
+	err = rawRef(t, db, "bytes").Put(&key, stored)
+	if err != nil {
+		t.Fatalf("Put raw: %v", err)
+	}
+	// The DBRef opened last on a name is the one verified.
+	_, err = ezdb.NewDBRef[string, []byte](db, "bytes", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithChecksums())
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	report, err := db.Verify(context.Background(), ezdb.VerifyOptions{DBRefs: []string{"bytes"}})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(report.Corrupt) != 1 || !errors.Is(report.Corrupt[0].Err, ezdb.ErrChecksum) {
+		t.Fatalf("Verify = %+v, want a checksum mismatch", report)
+	}
+}
+
+// sizedCodec is a SizedCodec of strings counting its calls. Strings that are
+// "fail" fail to encode, and "bad size" fail to report their size.
+type sizedCodec struct {
+	ezdb.StringCodec
+	marshals, marshalsTo *int
+}
+
+func (c sizedCodec) Marshal(v any) ([]byte, error) {
+	*c.marshals++
+	return c.StringCodec.Marshal(v)
+}
+
+func (c sizedCodec) Size(v any) (int, error) {
+	s := v.(*string)
+	if *s == "bad size" {
+		return 0, errors.New("no size")
+	}
+	return len(*s), nil
+}
+
+func (c sizedCodec) MarshalTo(buf []byte, v any) error {
+	*c.marshalsTo++
+	s := v.(*string)
+	if *s == "fail" {
+		return errors.New("can't encode")
+	}
+	copy(buf, *s)
+	return nil
+}
+
+func TestSizedCodec(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			var marshals, marshalsTo int
+			codec := sizedCodec{marshals: &marshals, marshalsTo: &marshalsTo}
+			ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(codec), ezdb.WithChecksums())
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+
+			// Values are encoded in place, with their checksums.
+			putN(t, ref, 10)
+			wantN(t, ref, 10)
+			if marshals != 0 || marshalsTo != 10 {
+				t.Fatalf("Put called Marshal %d and MarshalTo %d times", marshals, marshalsTo)
+			}
+			key := "k0009"
+			stored, err := rawRef(t, db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{})).Get(&key)
+			if err != nil || string((*stored)[:2]) != "v9" || len(*stored) != 6 {
+				t.Fatalf("stored value = %q, %v", stored, err)
+			}
+
+			// A value that fails to encode writes nothing.
+			for _, val := range []string{"fail", "bad size"} {
+				key := "k0010"
+				err = ref.Put(&key, &val)
+				if err == nil {
+					t.Fatalf("Put of %q succeeded", val)
+				}
+				wantN(t, ref, 10)
+			}
+
+			// Compressed values can't be encoded in place.
+			compressed, err := ezdb.NewDBRef[string, string](db, "compressed", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(codec), ezdb.WithCompression(1))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			putN(t, compressed, 10)
+			wantN(t, compressed, 10)
+			if marshals != 10 {
+				t.Fatalf("Put of compressed values called Marshal %d times", marshals)
+			}
+		})
+	}
+}
+
+func TestBytesCodecSized(t *testing.T) {
+	var codec ezdb.BytesCodec
+	b := []byte("value")
+	size, err := codec.Size(&b)
+	if err != nil || size != 5 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+	buf := make([]byte, size)
+	err = codec.MarshalTo(buf, &b)
+	if err != nil || string(buf) != "value" {
+		t.Fatalf("MarshalTo wrote %q: %v", buf, err)
+	}
+
+	s := "value"
+	_, err = codec.Size(&s)
+	if err == nil {
+		t.Fatal("Size of a string succeeded")
+	}
+	err = codec.MarshalTo(buf, &s)
+	if err == nil {
+		t.Fatal("MarshalTo of a string succeeded")
+	}
+}
+
+func TestStringAndUint64Codecs(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	names, err := ezdb.NewDBRef[string, string](db, "names", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", "Ada Lovelace"
+	err = names.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	stored, err := rawRef(t, db, "names", ezdb.WithKeyCodec(ezdb.StringCodec{})).Get(&key)
+	if err != nil || string(*stored) != val {
+		t.Fatalf("stored string = %q, %v", stored, err)
+	}
+	got, err := names.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	// Uint64 keys are in numeric order.
+	counters, err := ezdb.NewDBRef[uint64, uint64](db, "counters", ezdb.WithKeyCodec(ezdb.Uint64Codec{}), ezdb.WithCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for _, n := range []uint64{256, 1, 1 << 40, 2} {
+		val := n * 10
+		err := counters.Put(&n, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	var order []uint64
+	err = counters.ForEach(func(key, val *uint64) error {
+		if *val != *key*10 {
+			return fmt.Errorf("value of %d = %d", *key, *val)
+		}
+		order = append(order, *key)
+		return nil
+	})
+	if err != nil || fmt.Sprint(order) != fmt.Sprint([]uint64{1, 2, 256, 1 << 40}) {
+		t.Fatalf("ForEach visited %v: %v", order, err)
+	}
+}
+
+func TestStringAndUint64CodecErrors(t *testing.T) {
+	var n uint64
+	s := "s"
+	for _, test := range []struct {
+		codec   ezdb.SizedCodec
+		wrong   any
+		decoded any
+	}{
+		{ezdb.StringCodec{}, &n, &s},
+		{ezdb.Uint64Codec{}, &s, &n},
+	} {
+		_, err := test.codec.Marshal(test.wrong)
+		if err == nil {
+			t.Errorf("%T.Marshal(%T) succeeded", test.codec, test.wrong)
+		}
+		_, err = test.codec.Size(test.wrong)
+		if err == nil {
+			t.Errorf("%T.Size(%T) succeeded", test.codec, test.wrong)
+		}
+		err = test.codec.MarshalTo(make([]byte, 8), test.wrong)
+		if err == nil {
+			t.Errorf("%T.MarshalTo(%T) succeeded", test.codec, test.wrong)
+		}
+		err = test.codec.Unmarshal(make([]byte, 8), test.wrong)
+		if err == nil {
+			t.Errorf("%T.Unmarshal(%T) succeeded", test.codec, test.wrong)
+		}
+
+		// MarshalTo writes what Marshal returns.
+		data, err := test.codec.Marshal(test.decoded)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		size, err := test.codec.Size(test.decoded)
+		if err != nil || size != len(data) {
+			t.Fatalf("%T.Size = %d, %v, want %d", test.codec, size, err, len(data))
+		}
+		buf := make([]byte, size)
+		err = test.codec.MarshalTo(buf, test.decoded)
+		if err != nil || string(buf) != string(data) {
+			t.Fatalf("%T.MarshalTo wrote %x, %v, want %x", test.codec, buf, err, data)
+		}
+	}
+
+	err := ezdb.Uint64Codec{}.Unmarshal([]byte{1, 2, 3}, &n)
+	if err == nil {
+		t.Fatal("Uint64Codec decoded 3 bytes")
+	}
+}
+
+func TestStringAndUint64CodecAllocs(t *testing.T) {
+	n := uint64(42)
+	s := strings.Repeat("s", 64)
+	for _, test := range []struct {
+		codec              ezdb.SizedCodec
+		val                any
+		marshal, unmarshal float64
+	}{
+		{ezdb.StringCodec{}, &s, 1, 1},
+		{ezdb.Uint64Codec{}, &n, 1, 0},
+	} {
+		size, err := test.codec.Size(test.val)
+		if err != nil {
+			t.Fatalf("Size: %v", err)
+		}
+		buf := make([]byte, size)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = test.codec.Size(test.val)
+			_ = test.codec.MarshalTo(buf, test.val)
+		})
+		if allocs != 0 {
+			t.Errorf("%T.Size and MarshalTo allocated %v times", test.codec, allocs)
+		}
+		allocs = testing.AllocsPerRun(100, func() {
+			_, _ = test.codec.Marshal(test.val)
+		})
+		if allocs != test.marshal {
+			t.Errorf("%T.Marshal allocated %v times, want %v", test.codec, allocs, test.marshal)
+		}
+		allocs = testing.AllocsPerRun(100, func() {
+			_ = test.codec.Unmarshal(buf, test.val)
+		})
+		if allocs != test.unmarshal {
+			t.Errorf("%T.Unmarshal allocated %v times, want %v", test.codec, allocs, test.unmarshal)
+		}
+	}
+}
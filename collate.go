@@ -0,0 +1,100 @@
+package ezdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LMDB's comparators can only be C functions, so domain-specific key
+// orders are implemented by codecs instead: the encoded key starts with a
+// sort key, whose byte order LMDB's default comparator then follows.
+
+// sortKeyCodec prefixes the encoding of each key with its sort key.
+type sortKeyCodec[K any] struct {
+	sortKey func(key *K) []byte
+	codec   Codec
+}
+
+// NewSortKeyCodec returns a key codec that orders keys of type K by the bytes
+// sortKey returns for them, then by their encoding with codec, which is
+// OrderedCodec if nil. Use it with WithKeyCodec for orders LMDB's byte-wise
+// comparison can't express directly, e.g. collations.
+func NewSortKeyCodec[K any](sortKey func(key *K) []byte, codec Codec) Codec {
+	if codec == nil {
+		codec = OrderedCodec{}
+	}
+
+	return sortKeyCodec[K]{sortKey: sortKey, codec: codec}
+}
+
+func (c sortKeyCodec[K]) Marshal(v any) ([]byte, error) {
+	key, ok := v.(*K)
+	if !ok {
+		return nil, fmt.Errorf("sort key codec cannot encode %T", v)
+	}
+
+	enc, err := c.codec.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(appendEscaped(nil, c.sortKey(key)), enc...), nil
+}
+
+func (c sortKeyCodec[K]) Unmarshal(data []byte, v any) error {
+	_, rest, err := readEscaped(data)
+	if err != nil {
+		return fmt.Errorf("failed to read sort key: %w", err)
+	}
+
+	return c.codec.Unmarshal(rest, v)
+}
+
+// CaseInsensitiveCodec returns a key codec for string keys that orders them
+// case-insensitively, breaking ties by their exact bytes.
+func CaseInsensitiveCodec() Codec {
+	return NewSortKeyCodec(func(key *string) []byte {
+		return []byte(strings.ToLower(*key))
+	}, nil)
+}
+
+// VersionCodec returns a key codec for string keys that orders runs of
+// digits by their numeric value, so "v1.10" sorts after "v1.9". A run of
+// digits sorts before any other character.
+func VersionCodec() Codec {
+	return NewSortKeyCodec(func(key *string) []byte {
+		return versionSortKey(*key)
+	}, nil)
+}
+
+// versionSortKey writes every run of digits of s as 0x01, the length of the
+// run without leading zeros, and the remaining digits, which orders numbers
+// of up to 255 digits by value.
+func versionSortKey(s string) []byte {
+	var key []byte
+	for i := 0; i < len(s); {
+		if !isDigit(s[i]) {
+			key = append(key, s[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		digits := strings.TrimLeft(s[i:j], "0")
+		if len(digits) > 255 {
+			digits = digits[:255]
+		}
+		key = append(key, 0x01, byte(len(digits)))
+		key = append(key, digits...)
+		i = j
+	}
+
+	return key
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,76 @@
+package ezdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// sortedKeys stores keys in a DBRef using codec and returns them in the order
+// it keeps them in.
+func sortedKeys(t *testing.T, codec ezdb.Codec, keys ...string) string {
+	t.Helper()
+
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(codec))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for i := range keys {
+		err = ref.Put(&keys[i], &i)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	var got []string
+	err = ref.ForEach(func(key *string, val *int) error {
+		if keys[*val] != *key {
+			t.Errorf("key %q came back as %q", keys[*val], *key)
+		}
+		got = append(got, *key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+
+	return fmt.Sprint(got)
+}
+
+func TestCaseInsensitiveCodec(t *testing.T) {
+	got := sortedKeys(t, ezdb.CaseInsensitiveCodec(), "banana", "Apple", "apple", "Cherry", "BANANA")
+	if want := "[Apple apple BANANA banana Cherry]"; got != want {
+		t.Fatalf("keys = %s, want %s", got, want)
+	}
+}
+
+func TestVersionCodec(t *testing.T) {
+	got := sortedKeys(t, ezdb.VersionCodec(), "v1.10", "v1.9", "v1.09.1", "v2", "v1.9a", "v10", "v1.")
+	if want := "[v1. v1.9 v1.09.1 v1.9a v1.10 v2 v10]"; got != want {
+		t.Fatalf("keys = %s, want %s", got, want)
+	}
+}
+
+func TestSortKeyCodec(t *testing.T) {
+	// Order by length, then by the keys themselves.
+	codec := ezdb.NewSortKeyCodec(func(key *string) []byte {
+		return []byte{byte(len(*key))}
+	}, ezdb.StringCodec{})
+	got := sortedKeys(t, codec, "ccc", "a", "bb", "b", "aaaa")
+	if want := "[a b bb ccc aaaa]"; got != want {
+		t.Fatalf("keys = %s, want %s", got, want)
+	}
+
+	_, err := codec.Marshal(3)
+	if err == nil {
+		t.Fatal("Marshal of an int succeeded")
+	}
+	var key string
+	err = codec.Unmarshal([]byte("truncated"), &key)
+	if err == nil {
+		t.Fatal("Unmarshal without a sort key succeeded")
+	}
+}
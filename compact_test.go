@@ -0,0 +1,95 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestCompactTo(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	// Deleting most of the entries leaves the pages they took free.
+	putN(t, ref, 5000)
+	for i := 100; i < 5000; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		err = ref.Delete(&key)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+
+	var full, compacted bytes.Buffer
+	err = db.Backup(context.Background(), &full)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	err = db.CompactToWriter(context.Background(), &compacted)
+	if err != nil {
+		t.Fatalf("CompactToWriter: %v", err)
+	}
+	if compacted.Len() >= full.Len() {
+		t.Fatalf("compacted copy has %d bytes, the full one %d", compacted.Len(), full.Len())
+	}
+	dir := filepath.Join(t.TempDir(), "streamed")
+	err = ezdb.Restore(dir, &compacted)
+	if err != nil {
+		t.Fatalf("Restore of a compacted copy: %v", err)
+	}
+	streamed, err := ezdb.NewRef[string, string]("ref", openClient(t, dir))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, streamed, 100)
+
+	dir = filepath.Join(t.TempDir(), "compacted")
+	err = db.CompactTo(dir)
+	if err != nil {
+		t.Fatalf("CompactTo: %v", err)
+	}
+	copied := openClient(t, dir)
+	ref, err = ezdb.NewRef[string, string]("ref", copied)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, ref, 100)
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	copiedStats, err := copied.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if copiedStats.UsedPages >= stats.UsedPages {
+		t.Fatalf("compacted copy uses %d pages, the original %d", copiedStats.UsedPages, stats.UsedPages)
+	}
+
+	err = db.CompactTo(dir)
+	if err == nil {
+		t.Fatal("CompactTo into an existing environment succeeded")
+	}
+}
+
+func TestCompactToUnsupported(t *testing.T) {
+	db := testutil.NewMemoryClient(t)
+
+	err := db.CompactTo(t.TempDir())
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("CompactTo of a memory Client returned %v, want ErrUnsupported", err)
+	}
+	err = db.CompactToWriter(context.Background(), io.Discard)
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("CompactToWriter of a memory Client returned %v, want ErrUnsupported", err)
+	}
+}
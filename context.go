@@ -0,0 +1,36 @@
+package ezdb
+
+import "context"
+
+// WithContext returns a DBRef for the same data as ref bound to ctx, so
+// request-scoped values and deadlines reach ezdb without changing every call:
+// Gets, Puts and Deletes, including those of helpers such as TryGet, fail
+// with ctx's error once it is done, and scans such as ForEach and Query.Each
+// stop with it between entries. Interceptors see ctx in OpInfo.Context, and
+// the audit log attributes writes to the actor set on it with
+// ContextWithActor. Writes waiting for a batched transaction, see
+// WithFlushInterval, aren't abandoned when ctx is done.
+func (ref *DBRef[K, V]) WithContext(ctx context.Context) *DBRef[K, V] {
+	derived := *ref
+	derived.ctx = ctx
+	return &derived
+}
+
+// Context returns the context bound to ref with WithContext, or
+// context.Background().
+func (ref *DBRef[K, V]) Context() context.Context {
+	if ref.ctx == nil {
+		return context.Background()
+	}
+
+	return ref.ctx
+}
+
+// ctxErr returns the error of the context bound to ref, if it is done.
+func (ref *DBRef[K, V]) ctxErr() error {
+	if ref.ctx == nil {
+		return nil
+	}
+
+	return ref.ctx.Err()
+}
@@ -0,0 +1,88 @@
+package ezdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type ctxKey struct{}
+
+func TestWithContext(t *testing.T) {
+	var seen []any
+	db := testutil.NewTempClient(t, ezdb.WithInterceptor(func(op ezdb.OpInfo, next func() error) error {
+		seen = append(seen, op.Context.Value(ctxKey{}))
+		return next()
+	}))
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	if ref.Context() != context.Background() {
+		t.Fatalf("Context of an unbound DBRef = %v", ref.Context())
+	}
+	putN(t, ref, 5)
+
+	// Interceptors see the context bound to the DBRef.
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "trace"))
+	bound := ref.WithContext(ctx)
+	if bound.Context() != ctx {
+		t.Fatalf("Context = %v, want the bound one", bound.Context())
+	}
+	seen = nil
+	key := "k0000"
+	_, err = bound.Get(&key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "trace" || seen[1] != nil {
+		t.Fatalf("interceptors saw the context values %v", seen)
+	}
+
+	// Scans stop between entries once the context is done.
+	n := 0
+	err = bound.ForEach(func(*string, *string) error {
+		n++
+		if n == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || n != 2 {
+		t.Fatalf("ForEach saw %d entries and returned %v, want 2 and context.Canceled", n, err)
+	}
+
+	// Operations fail right away, without reaching the interceptors.
+	seen = nil
+	val := "changed"
+	for name, op := range map[string]func() error{
+		"Get":    func() error { _, err := bound.Get(&key); return err },
+		"TryGet": func() error { _, _, err := bound.TryGet(&key); return err },
+		"Put":    func() error { return bound.Put(&key, &val) },
+		"Delete": func() error { return bound.Delete(&key) },
+		"ForEach": func() error {
+			return bound.ForEach(func(*string, *string) error { return nil })
+		},
+		"Query.Each": func() error {
+			return bound.Query().Each(func(*string, *string) error { return nil })
+		},
+	} {
+		err := op()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a canceled context returned %v, want context.Canceled", name, err)
+		}
+	}
+	if len(seen) != 0 {
+		t.Fatalf("interceptors ran %d times", len(seen))
+	}
+
+	// The DBRef it was derived from is unaffected.
+	wantN(t, ref, 5)
+}
@@ -0,0 +1,264 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// copyChunkSize is the number of entries read and written per transaction
+// when copying a database.
+const copyChunkSize = 1024
+
+// record is an encoded key/value pair copied out of a transaction.
+type record struct {
+	key []byte
+	val []byte
+}
+
+// CopyTo copies every entry of ref into dst, which may belong to a different
+// Client. Entries are streamed in chunks, each read and written in its own
+// transaction, so the copy is not a point-in-time snapshot of ref if ref is
+// written to concurrently. Entries are decoded and written like by Put, so
+// dst's codecs, indexes and other options apply to them and its watchers see
+// them. Only the live entries of ref's namespace are copied; they keep their
+// expiry if dst was created with WithTTL.
+func (ref *DBRef[K, V]) CopyTo(dst *DBRef[K, V]) error {
+	if ref.ownerDB == dst.ownerDB && ref.id == dst.id {
+		return errors.New("cannot copy a database onto itself")
+	}
+
+	var after []byte
+	for {
+		entries, next, err := ref.readEntries(after, copyChunkSize)
+		if err != nil {
+			return err
+		}
+		err = dst.writeEntries(entries)
+		if err != nil {
+			return err
+		}
+
+		if next == nil {
+			return nil
+		}
+		after = next
+	}
+}
+
+// copiedEntry is a decoded entry copied out of a transaction, with its expiry
+// if it has one.
+type copiedEntry[K, V any] struct {
+	key    *K
+	val    *V
+	expiry time.Time
+}
+
+// readEntries decodes the live ones among up to n stored entries of ref's
+// namespace, starting after the stored key after, or with the first if after
+// is nil. next is the stored key to resume after, or nil once all entries
+// have been read.
+func (ref *DBRef[K, V]) readEntries(after []byte, n int) (entries []copiedEntry[K, V], next []byte, err error) {
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		var key, val []byte
+		switch {
+		case after == nil && len(ref.prefix) == 0:
+			key, val, err = cursor.First()
+		case after == nil:
+			key, val, err = cursor.SeekGreaterThanOrEqualKey(ref.prefix)
+		default:
+			key, val, err = cursor.SeekGreaterThanOrEqualKey(after)
+			if err == nil && bytes.Equal(key, after) {
+				key, val, err = cursor.Next()
+			}
+		}
+
+		now := time.Now()
+		for read := 0; err == nil && read < n && bytes.HasPrefix(key, ref.prefix); key, val, err = cursor.Next() {
+			read++
+			if read == n {
+				next = bytes.Clone(key)
+			}
+
+			var entry copiedEntry[K, V]
+			if ref.options.ttl != nil {
+				var ok bool
+				entry.expiry, ok, err = ref.expiryInTxn(txn, key)
+				if err != nil {
+					return err
+				}
+				if ok && !entry.expiry.After(now) {
+					continue
+				}
+			}
+			entry.key, err = ref.decodeKey(key)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			entry.val, err = ref.decodeValInTxn(txn, val)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entries, next, nil
+}
+
+// writeEntries writes entries like Put, in one transaction, setting their
+// expiries if ref has TTLs enabled.
+func (ref *DBRef[K, V]) writeEntries(entries []copiedEntry[K, V]) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		for _, entry := range entries {
+			keyBytes, enc, err := ref.encodeEntry(entry.key, entry.val, false)
+			if err != nil {
+				return err
+			}
+			err = ref.putEncodedInTxn(txn, dbRef, keyBytes, enc, entry.val, putFlag(0))
+			if err != nil {
+				return err
+			}
+			if ref.options.ttl != nil && !entry.expiry.IsZero() {
+				err = ref.setExpiryInTxn(txn, keyBytes, entry.expiry)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// CopyDB copies every entry of the named database src into the named database
+// dst of the same Client, creating dst if it doesn't exist. Entries are
+// copied as stored, without running the hooks of DBRefs opened for dst, such
+// as their indexes.
+func (db *Client) CopyDB(src, dst string) error {
+	if src == dst {
+		return errors.New("cannot copy a database onto itself")
+	}
+
+	err := db.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(dst, dbCreate)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open db ref: %w", err)
+	}
+
+	var after []byte
+	for {
+		chunk, err := readChunk(db, src, nil, after, copyChunkSize)
+		if err != nil {
+			return err
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		err = db.update(func(txn writeTxn) error {
+			dbRef, err := txn.DBRef(dst, dbFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to get db ref: %w", err)
+			}
+
+			for _, rec := range chunk {
+				err = txn.Put(dbRef, rec.key, rec.val, putFlag(0))
+				if err != nil {
+					return fmt.Errorf("failed to put key/value pair: %w", err)
+				}
+				db.invalidateInTxn(txn, dst, rec.key)
+				err = db.bloomAddInTxn(txn, dst, nil, rec.key)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(chunk) < copyChunkSize {
+			return nil
+		}
+		after = chunk[len(chunk)-1].key
+	}
+}
+
+// readChunk reads up to n records of the named database whose keys start
+// with prefix, starting with the first such key greater than after, or with
+// the first such key if after is nil.
+func readChunk(db *Client, id string, prefix, after []byte, n int) (chunk []record, err error) {
+	err = db.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(id, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		var key, val []byte
+		switch {
+		case after == nil && len(prefix) == 0:
+			key, val, err = cursor.First()
+		case after == nil:
+			key, val, err = cursor.SeekGreaterThanOrEqualKey(prefix)
+		default:
+			key, val, err = cursor.SeekGreaterThanOrEqualKey(after)
+			if err == nil && bytes.Equal(key, after) {
+				key, val, err = cursor.Next()
+			}
+		}
+
+		for ; err == nil && len(chunk) < n && bytes.HasPrefix(key, prefix); key, val, err = cursor.Next() {
+			chunk = append(chunk, record{key: bytes.Clone(key), val: bytes.Clone(val)})
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
@@ -0,0 +1,200 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// putN stores the values "v0" to "v<n-1>" under the keys "k0000" to
+// "k<n-1>" of ref.
+func putN(t *testing.T, ref *ezdb.DBRef[string, string], n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+}
+
+// wantN checks that ref holds exactly what putN stored.
+func wantN(t *testing.T, ref *ezdb.DBRef[string, string], n int) {
+	t.Helper()
+
+	i := 0
+	err := ref.ForEach(func(key, val *string) error {
+		if want := fmt.Sprintf("k%04d", i); *key != want {
+			return fmt.Errorf("key %d = %q, want %q", i, *key, want)
+		}
+		if want := fmt.Sprintf("v%d", i); *val != want {
+			return fmt.Errorf("value of %s = %q, want %q", *key, *val, want)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != n {
+		t.Fatalf("%s has %d entries, want %d", ref.Name(), i, n)
+	}
+}
+
+func TestCopyToAcrossClients(t *testing.T) {
+	src := testutil.NewTempClient(t)
+	dst := testutil.NewTempClient(t)
+
+	from, err := ezdb.NewRef[string, string]("from", src)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	// More entries than are copied per transaction.
+	putN(t, from, 2500)
+
+	same, err := ezdb.NewRef[string, string]("to", dst)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	err = from.CopyTo(same)
+	if err != nil {
+		t.Fatalf("CopyTo: %v", err)
+	}
+	wantN(t, same, 2500)
+
+	recoded, err := ezdb.NewDBRef[string, string](dst, "json", ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithCompression(1))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = from.CopyTo(recoded)
+	if err != nil {
+		t.Fatalf("CopyTo with other codecs: %v", err)
+	}
+	wantN(t, recoded, 2500)
+}
+
+func TestCopyToNamespace(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref.Sub("a"), 10)
+	putN(t, ref.Sub("b"), 20)
+
+	dst, err := ezdb.NewRef[string, string]("dst", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	err = ref.Sub("b").CopyTo(dst)
+	if err != nil {
+		t.Fatalf("CopyTo: %v", err)
+	}
+	wantN(t, dst, 20)
+}
+
+func TestCopyDB(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("src", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	err = db.CopyDB("src", "dst")
+	if err != nil {
+		t.Fatalf("CopyDB: %v", err)
+	}
+	dst, err := ezdb.NewRef[string, string]("dst", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, dst, 100)
+
+	err = db.CopyDB("src", "src")
+	if err == nil {
+		t.Fatal("CopyDB onto itself succeeded")
+	}
+	err = ref.CopyTo(ref)
+	if err == nil {
+		t.Fatal("CopyTo onto itself succeeded")
+	}
+	err = db.CopyDB("missing", "dst")
+	if err == nil {
+		t.Fatal("CopyDB of a missing database succeeded")
+	}
+}
+
+func TestCopyToOptions(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	src, err := ezdb.NewDBRef[string, string](db, "src", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for _, e := range []struct {
+		key string
+		ttl time.Duration
+	}{{"expired", time.Millisecond}, {"expiring", 200 * time.Millisecond}, {"kept", 0}} {
+		val := "v-" + e.key
+		if e.ttl == 0 {
+			err = src.Put(&e.key, &val)
+		} else {
+			err = src.PutTTL(&e.key, &val, e.ttl)
+		}
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Entries are written like by Put, so dst's indexes cover them.
+	dst, err := ezdb.NewDBRef[string, string](db, "dst", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	byVal, err := ezdb.NewIndex(dst, "val", func(v *string) string { return *v }, ezdb.WithUnique())
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	err = src.CopyTo(dst)
+	if err != nil {
+		t.Fatalf("CopyTo: %v", err)
+	}
+	for key, want := range map[string]bool{"expired": false, "expiring": true, "kept": true} {
+		if has(t, dst, key) != want {
+			t.Errorf("copy of %s present = %t, want %t", key, !want, want)
+		}
+	}
+	val := "v-kept"
+	key, _, err := byVal.Get(&val)
+	if err != nil || *key != "kept" {
+		t.Fatalf("index Get = %v, %v, want kept", key, err)
+	}
+
+	// Copies keep their expiry.
+	time.Sleep(250 * time.Millisecond)
+	if has(t, dst, "expiring") {
+		t.Fatal("copy of expiring outlived its expiry")
+	}
+
+	// A unique index of dst rejects entries clashing with its own.
+	other, err := ezdb.NewDBRef[string, string](db, "other")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	clash := "clash"
+	err = other.Put(&clash, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = other.CopyTo(dst)
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("CopyTo of a duplicate returned %v, want ErrDuplicate", err)
+	}
+}
@@ -0,0 +1,112 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// countersDB is the named database all counters of a Client are stored in.
+const countersDB = "ezdb.counters"
+
+// Counter is a persistent int64 counter, e.g. for rate counting or usage
+// metering. Every change is a read-modify-write in its own write transaction,
+// so concurrent changes are never lost; with WithBatchSize, concurrent
+// transactions are batched into a single commit, which keeps frequent
+// increments cheap.
+type Counter struct {
+	name    string
+	ownerDB *Client
+}
+
+// Counter opens the named counter of db. A counter that was never changed has
+// a value of zero. All counters share one named database, which counts
+// towards WithNumDBs.
+func (db *Client) Counter(name string) (*Counter, error) {
+	err := db.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	err = db.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(countersDB, dbCreate)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open counter %q: %w", name, err)
+	}
+
+	return &Counter{name: name, ownerDB: db}, nil
+}
+
+// Incr adds delta to the counter and returns its new value.
+func (c *Counter) Incr(delta int64) (value int64, err error) {
+	err = c.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(countersDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		old, err := c.read(txn, dbRef)
+		if err != nil {
+			return err
+		}
+		value = old + delta
+		if (delta > 0 && value < old) || (delta < 0 && value > old) {
+			return fmt.Errorf("counter %q overflows", c.name)
+		}
+
+		err = txn.Put(dbRef, []byte(c.name), binary.BigEndian.AppendUint64(nil, uint64(value)), putFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to write counter %q: %w", c.name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// Decr subtracts delta from the counter and returns its new value.
+func (c *Counter) Decr(delta int64) (int64, error) {
+	if delta == -delta && delta != 0 {
+		return 0, fmt.Errorf("counter %q overflows", c.name)
+	}
+
+	return c.Incr(-delta)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() (value int64, err error) {
+	err = c.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(countersDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		value, err = c.read(txn, dbRef)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+func (c *Counter) read(txn readTxn, dbRef dbi) (int64, error) {
+	valBytes, err := txn.Get(dbRef, []byte(c.name))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to read counter %q: %w", c.name, err)
+	case len(valBytes) != 8:
+		return 0, fmt.Errorf("corrupt counter %q", c.name)
+	}
+
+	return int64(binary.BigEndian.Uint64(valBytes)), nil
+}
@@ -0,0 +1,111 @@
+package ezdb_test
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestCounter(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c, err := db.Counter("hits")
+	if err != nil {
+		t.Fatalf("Counter: %v", err)
+	}
+
+	value, err := c.Value()
+	if err != nil || value != 0 {
+		t.Fatalf("Value of a new counter = %d, %v", value, err)
+	}
+	value, err = c.Incr(5)
+	if err != nil || value != 5 {
+		t.Fatalf("Incr(5) = %d, %v", value, err)
+	}
+	value, err = c.Decr(8)
+	if err != nil || value != -3 {
+		t.Fatalf("Decr(8) = %d, %v", value, err)
+	}
+	value, err = c.Value()
+	if err != nil || value != -3 {
+		t.Fatalf("Value = %d, %v", value, err)
+	}
+
+	other, err := db.Counter("misses")
+	if err != nil {
+		t.Fatalf("Counter: %v", err)
+	}
+	value, err = other.Value()
+	if err != nil || value != 0 {
+		t.Fatalf("Value of another counter = %d, %v", value, err)
+	}
+}
+
+func TestCounterOverflow(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c, err := db.Counter("c")
+	if err != nil {
+		t.Fatalf("Counter: %v", err)
+	}
+
+	_, err = c.Incr(math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Incr: %v", err)
+	}
+	_, err = c.Incr(1)
+	if err == nil {
+		t.Fatal("Incr past the maximum succeeded")
+	}
+	_, err = c.Decr(math.MinInt64)
+	if err == nil {
+		t.Fatal("Decr of the minimum succeeded")
+	}
+	value, err := c.Value()
+	if err != nil || value != math.MaxInt64 {
+		t.Fatalf("Value after failed changes = %d, %v", value, err)
+	}
+
+	_, err = c.Decr(math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Decr: %v", err)
+	}
+	_, err = c.Decr(math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Decr: %v", err)
+	}
+	_, err = c.Decr(2)
+	if err == nil {
+		t.Fatal("Decr past the minimum succeeded")
+	}
+}
+
+func TestCounterConcurrentIncr(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithBatchSize(16))
+	c, err := db.Counter("c")
+	if err != nil {
+		t.Fatalf("Counter: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_, err := c.Incr(1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := c.Value()
+	if err != nil || value != 400 {
+		t.Fatalf("Value = %d, %v, want 400", value, err)
+	}
+}
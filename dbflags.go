@@ -0,0 +1,72 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// lmdbOnlyDBFlags are the database flags only LMDB supports.
+const lmdbOnlyDBFlags = dbReverseKey | dbIntegerKey | dbDupFixed
+
+// WithReverseKeys makes LMDB compare the keys of the DBRef's named database
+// from their last byte to their first, e.g. for keys that are domain names
+// or paths read best from the end. The order of the keys no longer follows
+// that of their encodings, so queries scan every entry instead of narrowing
+// key ranges, and Sub namespaces, whose keys share their first bytes, don't
+// work. Like the other database flags, it is recorded when the database is
+// created and must be given whenever it is opened, and only LMDB supports
+// it, see Capabilities.
+func WithReverseKeys() RefOption {
+	return func(option *refOptions) error {
+		option.dbFlags |= dbReverseKey
+		return nil
+	}
+}
+
+// WithIntegerKeys makes LMDB store and compare the keys of the DBRef's named
+// database as native unsigned integers, for databases shared with programs
+// that write such keys. Every key must encode to 4 or 8 bytes in the byte
+// order of the machine, all of them to the same size, which rules out Sub
+// namespaces and WithKeyPrefixes; Uint64Codec, which is big-endian, already
+// orders keys numerically without it. Queries scan every entry instead of
+// narrowing key ranges. See WithReverseKeys.
+func WithIntegerKeys() RefOption {
+	return func(option *refOptions) error {
+		option.dbFlags |= dbIntegerKey
+		return nil
+	}
+}
+
+// WithFixedSizeValues tells LMDB that every value of the MultiRef has the
+// same encoded size, so it stores them packed without a size each and reads
+// them in pages. Adding a value of another size than those of its key
+// fails. It only applies to MultiRefs. See WithReverseKeys.
+func WithFixedSizeValues() RefOption {
+	return func(option *refOptions) error {
+		option.dbFlags |= dbDupFixed
+		return nil
+	}
+}
+
+// checkDBFlags checks the database flags of o for a DBRef whose database is
+// opened with flags.
+func (o *refOptions) checkDBFlags(flags dbFlag) error {
+	if o.dbFlags&dbDupFixed != 0 && flags&dbDupSort == 0 {
+		return errors.New("fixed-size values only apply to MultiRefs")
+	}
+	if o.dbFlags&dbIntegerKey != 0 && o.keyPrefixes != nil {
+		return errors.New("integer keys can't have key prefixes")
+	}
+
+	return nil
+}
+
+// checkIntegerKey checks that keyBytes can be a key of a database with
+// integer keys.
+func checkIntegerKey(keyBytes []byte) error {
+	if len(keyBytes) != 4 && len(keyBytes) != 8 {
+		return fmt.Errorf("integer key of %d bytes, not 4 or 8", len(keyBytes))
+	}
+
+	return nil
+}
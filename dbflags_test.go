@@ -0,0 +1,136 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestReverseKeys(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithReverseKeys())
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for _, key := range []string{"ab", "ca", "ba"} {
+		err = ref.Put(&key, &key)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	key := "ca"
+	got, err := ref.Get(&key)
+	if err != nil || *got != "ca" {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	// Keys are ordered by their last byte first.
+	var keys []string
+	err = ref.ForEach(func(key, _ *string) error {
+		keys = append(keys, *key)
+		return nil
+	})
+	if err != nil || fmt.Sprint(keys) != "[ba ca ab]" {
+		t.Fatalf("ForEach saw %v, %v", keys, err)
+	}
+}
+
+func TestIntegerKeys(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[uint64, string](db, "ref", ezdb.WithKeyCodec(ezdb.Uint64Codec{}), ezdb.WithIntegerKeys())
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		val := fmt.Sprint(i)
+		err = ref.Put(&i, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	for i := uint64(1); i <= 3; i++ {
+		got, err := ref.Get(&i)
+		if err != nil || *got != fmt.Sprint(i) {
+			t.Fatalf("Get(%d) = %v, %v", i, got, err)
+		}
+	}
+
+	// Keys of other sizes are rejected before they reach LMDB.
+	strs, err := ezdb.NewDBRef[string, string](db, "strs", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithIntegerKeys())
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key := "abc"
+	err = strs.Put(&key, &key)
+	if err == nil {
+		t.Fatal("Put of a 3-byte integer key succeeded")
+	}
+	_, err = ezdb.NewDBRef[string, string](db, "prefixed", ezdb.WithIntegerKeys(), ezdb.WithKeyPrefixes("a"))
+	if err == nil {
+		t.Fatal("NewDBRef with integer keys and key prefixes succeeded")
+	}
+}
+
+func TestFixedSizeValues(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	m, err := ezdb.NewMultiRef[string, string](db, "ref", ezdb.WithCodec(ezdb.StringCodec{}), ezdb.WithFixedSizeValues())
+	if err != nil {
+		t.Fatalf("NewMultiRef: %v", err)
+	}
+	key := "a"
+	for _, val := range []string{"bbbb", "aaaa", "cccc"} {
+		err = m.Add(&key, &val)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	vals, err := m.GetAll(&key)
+	if err != nil || fmt.Sprint(vals) != "[aaaa bbbb cccc]" {
+		t.Fatalf("GetAll = %v, %v", vals, err)
+	}
+	val := "ddddd"
+	err = m.Add(&key, &val)
+	if err == nil {
+		t.Fatal("Add of a value of another size succeeded")
+	}
+	vals, err = m.GetAll(&key)
+	if err != nil || fmt.Sprint(vals) != "[aaaa bbbb cccc]" {
+		t.Fatalf("GetAll after a failed Add = %v, %v", vals, err)
+	}
+
+	_, err = ezdb.NewDBRef[string, string](db, "plain", ezdb.WithFixedSizeValues())
+	if err == nil {
+		t.Fatal("NewDBRef with fixed-size values succeeded")
+	}
+}
+
+func TestDBFlagsUnsupported(t *testing.T) {
+	if !testutil.NewTempClient(t).Capabilities().DBFlags {
+		t.Fatal("LMDB doesn't report database flags as supported")
+	}
+
+	for _, e := range engines {
+		if e.engine == ezdb.EngineLMDB {
+			continue
+		}
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			if db.Capabilities().DBFlags {
+				t.Fatal("database flags reported as supported")
+			}
+			for _, opt := range []ezdb.RefOption{ezdb.WithReverseKeys(), ezdb.WithIntegerKeys()} {
+				_, err := ezdb.NewDBRef[uint64, string](db, "ref", ezdb.WithKeyCodec(ezdb.Uint64Codec{}), opt)
+				if !errors.Is(err, ezdb.ErrUnsupported) {
+					t.Errorf("NewDBRef with an LMDB flag returned %v, want ErrUnsupported", err)
+				}
+			}
+			_, err := ezdb.NewMultiRef[string, string](db, "multi", ezdb.WithFixedSizeValues())
+			if !errors.Is(err, ezdb.ErrUnsupported) {
+				t.Errorf("NewMultiRef with fixed-size values returned %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
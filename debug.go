@@ -0,0 +1,293 @@
+package ezdb
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// slowOpsKept is the number of slow operations RecentSlowOps remembers.
+const slowOpsKept = 100
+
+// SlowOp is an operation that took longer than the threshold given with
+// WithSlowOpThreshold.
+type SlowOp struct {
+	Time     time.Time
+	DBRef    string
+	Op       string
+	KeySize  int
+	Duration time.Duration
+}
+
+// slowOpLog keeps the last slowOpsKept slow operations in a ring.
+type slowOpLog struct {
+	mu   sync.Mutex
+	ops  []SlowOp
+	next int
+}
+
+func (l *slowOpLog) add(op SlowOp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.ops) < slowOpsKept {
+		l.ops = append(l.ops, op)
+		return
+	}
+	l.ops[l.next] = op
+	l.next = (l.next + 1) % slowOpsKept
+}
+
+// RecentSlowOps returns the last slow operations, newest first. It is empty
+// unless the Client was created with WithSlowOpThreshold.
+func (db *Client) RecentSlowOps() []SlowOp {
+	l := &db.slowOps
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ops := make([]SlowOp, 0, len(l.ops))
+	for i := len(l.ops) - 1; i >= 0; i-- {
+		ops = append(ops, l.ops[(l.next+i)%len(l.ops)])
+	}
+
+	return ops
+}
+
+// DebugHandler returns an http.Handler serving a page with the environment's
+// Stats, its named databases with their entry counts, for engines that
+// report them, the recent slow operations and a form for looking up single
+// keys, like the index page of
+// net/http/pprof but for the Client, to be mounted on a path such as
+// /debug/ezdb/. The page only reads, but shows stored values, so it belongs
+// on an internal port like the rest of /debug.
+//
+// Keys of databases a DBRef has been opened for are typed as JSON, or as
+// plain text for string keys, and their values are shown decoded; keys of
+// other databases, and keys typed with "raw" ticked, are the hex encoding of
+// the stored bytes.
+func (db *Client) DebugHandler() http.Handler {
+	return http.HandlerFunc(db.serveDebug)
+}
+
+// debugDB is a named database on the debug page. Entries and Size are only
+// set if HasStat is, for engines that report them.
+type debugDB struct {
+	Name     string
+	HasStat  bool
+	Entries  uint64
+	Size     uint64
+	Encoding string
+}
+
+// debugLookup is a key lookup on the debug page.
+type debugLookup struct {
+	DB, Key string
+	Raw     bool
+	// Done reports whether a key was looked up, rather than just the
+	// database picked.
+	Done  bool
+	Found bool
+	Value string
+	Err   string
+}
+
+type debugPage struct {
+	Stats         Stats
+	StatsErr      string
+	DBs           []debugDB
+	SlowOps       []SlowOp
+	SlowThreshold time.Duration
+	Lookup        *debugLookup
+}
+
+func (db *Client) serveDebug(w http.ResponseWriter, r *http.Request) {
+	page := debugPage{
+		SlowOps:       db.RecentSlowOps(),
+		SlowThreshold: db.options.slowOpThreshold,
+	}
+	refs := db.registeredRefs()
+
+	var err error
+	page.Stats, err = db.Stats()
+	if err != nil {
+		page.StatsErr = err.Error()
+	}
+	err = db.view(func(txn readTxn) error {
+		dbs, err := txn.ListDBs()
+		if err != nil {
+			return fmt.Errorf("failed to list databases: %w", err)
+		}
+
+		st, hasStat := txn.(statTxn)
+		page.DBs = page.DBs[:0]
+		for _, d := range dbs {
+			entry := debugDB{Name: d.name, HasStat: hasStat, Encoding: refs[d.name].encoding}
+			if hasStat {
+				dbRef, err := txn.DBRef(d.name, d.flags)
+				if err != nil {
+					return fmt.Errorf("failed to get db ref %s: %w", d.name, err)
+				}
+				stat, err := st.Stat(dbRef)
+				if err != nil {
+					return fmt.Errorf("failed to stat %s: %w", d.name, err)
+				}
+				entry.Entries, entry.Size = stat.Entries, stat.Size
+			}
+			page.DBs = append(page.DBs, entry)
+		}
+		return nil
+	})
+	if err != nil && page.StatsErr == "" {
+		page.StatsErr = err.Error()
+	}
+
+	if q := r.URL.Query(); q.Get("db") != "" {
+		page.Lookup = &debugLookup{DB: q.Get("db")}
+		if q.Has("key") {
+			page.Lookup = db.debugLookup(q.Get("db"), q.Get("key"), q.Get("raw") != "", refs[q.Get("db")])
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = debugTemplate.Execute(w, page)
+	if err != nil {
+		db.options.log.Error().Err(err).Msg("failed to write debug page")
+	}
+}
+
+// debugLookup looks up key in the named database name, whose DBRef, if one
+// has been opened, is described by info.
+func (db *Client) debugLookup(name, key string, raw bool, info refInfo) *debugLookup {
+	l := &debugLookup{DB: name, Key: key, Raw: raw || info.encodeKey == nil, Done: true}
+
+	var keyBytes []byte
+	var err error
+	if l.Raw {
+		keyBytes, err = hex.DecodeString(key)
+	} else {
+		keyBytes, err = info.encodeKey(key)
+	}
+	if err != nil {
+		l.Err = fmt.Sprintf("invalid key: %v", err)
+		return l
+	}
+
+	var valBytes []byte
+	err = db.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(name, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		val, err := txn.Get(dbRef, keyBytes)
+		if err != nil {
+			return err
+		}
+		valBytes = append([]byte(nil), val...)
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return l
+	}
+	if err != nil {
+		l.Err = err.Error()
+		return l
+	}
+	l.Found = true
+
+	if info.decodeVal == nil {
+		l.Value = hex.Dump(valBytes)
+		return l
+	}
+	val, err := info.decodeVal(valBytes)
+	if err != nil {
+		l.Err = fmt.Sprintf("failed to decode value: %v", err)
+		l.Value = hex.Dump(valBytes)
+		return l
+	}
+	data, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		l.Value = fmt.Sprintf("%+v", reflect.ValueOf(val).Elem())
+		return l
+	}
+	l.Value = string(data)
+
+	return l
+}
+
+// parseKeyText parses a key typed on the debug page as JSON into a *K, or
+// takes it as it is if K is a string and it isn't a JSON string, and encodes
+// it as ref stores it.
+func (ref *DBRef[K, V]) parseKeyText(s string) ([]byte, error) {
+	key := new(K)
+	err := json.Unmarshal([]byte(s), key)
+	if err != nil {
+		str, ok := any(key).(*string)
+		if !ok {
+			return nil, err
+		}
+		*str = s
+	}
+
+	return ref.encodeKey(key)
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>ezdb</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 12px 2px 0; text-align: left; vertical-align: top; }
+td.num { text-align: right; }
+pre { background: #f4f4f4; padding: 8px; }
+.err { color: #b00; }
+</style>
+</head>
+<body>
+<h1>ezdb</h1>
+
+<h2>Environment</h2>
+{{if .StatsErr}}<p class="err">{{.StatsErr}}</p>{{end}}
+<table>
+<tr><td>Map size</td><td class="num">{{.Stats.MapSize}}</td></tr>
+<tr><td>Page size</td><td class="num">{{.Stats.PageSize}}</td></tr>
+<tr><td>Used pages</td><td class="num">{{.Stats.UsedPages}}</td></tr>
+<tr><td>Free pages</td><td class="num">{{.Stats.FreePages}}</td></tr>
+<tr><td>Last txn ID</td><td class="num">{{.Stats.LastTxnID}}</td></tr>
+<tr><td>Readers</td><td class="num">{{.Stats.NumReaders}} / {{.Stats.MaxReaders}}</td></tr>
+{{with .Stats.LastSnapshot}}<tr><td>Last snapshot</td><td>{{.Time}} {{.Path}}{{if .Err}} <span class="err">{{.Err}}</span>{{end}}</td></tr>{{end}}
+</table>
+
+<h2>Databases</h2>
+<table>
+<tr><th>Name</th><th>Entries</th><th>Size</th><th>DBRef encoding</th></tr>
+{{range .DBs}}<tr><td><a href="?db={{.Name}}">{{.Name}}</a></td>{{if .HasStat}}<td class="num">{{.Entries}}</td><td class="num">{{.Size}}</td>{{else}}<td class="num">-</td><td class="num">-</td>{{end}}<td>{{.Encoding}}</td></tr>
+{{end}}</table>
+
+<h2>Lookup</h2>
+<form method="get">
+<input name="db" list="dbs" placeholder="database"{{with .Lookup}} value="{{.DB}}"{{end}}>
+<datalist id="dbs">{{range .DBs}}<option value="{{.Name}}">{{end}}</datalist>
+<input name="key" size="60" placeholder="key"{{with .Lookup}} value="{{.Key}}"{{end}}>
+<label><input type="checkbox" name="raw" value="1"{{with .Lookup}}{{if .Raw}} checked{{end}}{{end}}> raw (hex)</label>
+<input type="submit" value="Get">
+</form>
+{{with .Lookup}}{{if .Done}}{{if .Err}}<p class="err">{{.Err}}</p>{{end}}{{if .Found}}<pre>{{.Value}}</pre>{{else if not .Err}}<p>Not found.</p>{{end}}{{end}}{{end}}
+
+<h2>Recent slow operations</h2>
+{{if not .SlowThreshold}}<p>Not recorded; see WithSlowOpThreshold.</p>{{else}}
+<p>Slower than {{.SlowThreshold}}, newest first.</p>
+<table>
+<tr><th>Time</th><th>DBRef</th><th>Op</th><th>Key size</th><th>Duration</th></tr>
+{{range .SlowOps}}<tr><td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td><td>{{.DBRef}}</td><td>{{.Op}}</td><td class="num">{{.KeySize}}</td><td class="num">{{.Duration}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,178 @@
+package ezdb_test
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// debugPage returns the page the debug handler of db serves for the query
+// q, unescaped.
+func debugPage(t *testing.T, db *ezdb.Client, q url.Values) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	db.DebugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/ezdb/?"+q.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("debug page returned %d", w.Code)
+	}
+	return html.UnescapeString(w.Body.String())
+}
+
+func TestRecentSlowOps(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithSlowOpThreshold(time.Nanosecond))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 3)
+	key := "k0000"
+	_, err = ref.Get(&key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	ops := db.RecentSlowOps()
+	if len(ops) != 4 {
+		t.Fatalf("RecentSlowOps returned %d ops, want 4", len(ops))
+	}
+	if op := ops[0]; op.Op != "get" || op.DBRef != "ref" || op.KeySize != 5 || op.Duration <= 0 || op.Time.IsZero() {
+		t.Fatalf("newest slow op = %+v", op)
+	}
+	if ops[1].Op != "put" {
+		t.Fatalf("slow ops = %+v", ops)
+	}
+
+	// Only the last 100 are kept, still newest first.
+	putN(t, ref, 150)
+	ops = db.RecentSlowOps()
+	if len(ops) != 100 {
+		t.Fatalf("RecentSlowOps returned %d ops, want 100", len(ops))
+	}
+	for i := 1; i < len(ops); i++ {
+		if ops[i].Time.After(ops[i-1].Time) {
+			t.Fatalf("slow op %d is newer than the one before it", i)
+		}
+	}
+
+	if ops := testutil.NewTempClient(t).RecentSlowOps(); len(ops) != 0 {
+		t.Fatalf("RecentSlowOps without a threshold returned %+v", ops)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithSlowOpThreshold(time.Nanosecond))
+	users, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada Lovelace"}
+	err = users.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	counts, err := ezdb.NewDBRef[uint64, int](db, "counts", ezdb.WithKeyCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for i := uint64(1); i <= 2; i++ {
+		n := int(i * 10)
+		err = counts.Put(&i, &n)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	page := debugPage(t, db, nil)
+	for _, want := range []string{
+		`<a href="?db=counts">counts</a></td><td class="num">2</td>`,
+		`<a href="?db=users">users</a></td><td class="num">1</td>`,
+		"Slower than 1ns",
+		"<td>users</td><td>put</td><td class=\"num\">3</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("debug page lacks %q:\n%s", want, page)
+		}
+	}
+
+	tests := []struct {
+		q    url.Values
+		want string
+	}{
+		{url.Values{"db": {"users"}, "key": {"ada"}}, `"Name": "Ada Lovelace"`},
+		{url.Values{"db": {"users"}, "key": {`"ada"`}}, `"Name": "Ada Lovelace"`},
+		{url.Values{"db": {"users"}, "key": {"alan"}}, "Not found."},
+		{url.Values{"db": {"users"}, "key": {"616461"}, "raw": {"1"}}, `"Name": "Ada Lovelace"`},
+		{url.Values{"db": {"users"}, "key": {"zz"}, "raw": {"1"}}, "invalid key"},
+		{url.Values{"db": {"counts"}, "key": {"2"}}, "<pre>20</pre>"},
+		{url.Values{"db": {"counts"}, "key": {"x"}}, "invalid key"},
+		{url.Values{"db": {"missing"}, "key": {"00"}}, "Not found."},
+	}
+	for _, test := range tests {
+		if page := debugPage(t, db, test.q); !strings.Contains(page, test.want) {
+			t.Errorf("lookup of %v lacks %q", test.q, test.want)
+		}
+	}
+
+	// Picking a database doesn't look anything up yet.
+	page = debugPage(t, db, url.Values{"db": {"users"}})
+	if !strings.Contains(page, `value="users"`) || strings.Contains(page, "Not found.") {
+		t.Errorf("debug page of a picked database:\n%s", page)
+	}
+}
+
+func TestDebugHandlerWithoutDBRef(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref := rawRef(t, db, "raw", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	key, val := "k", []byte{0xde, 0xad}
+	err := ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Keys of databases no DBRef has been opened for are typed in hex, and
+	// their values shown as hex dumps.
+	db = openClient(t, dir)
+	page := debugPage(t, db, url.Values{"db": {"raw"}, "key": {"6b"}})
+	if !strings.Contains(page, "00000000  de ad") || !strings.Contains(page, "value=\"6b\"") {
+		t.Errorf("lookup without a DBRef:\n%s", page)
+	}
+	if !strings.Contains(page, "Not recorded; see WithSlowOpThreshold.") {
+		t.Errorf("debug page without a threshold:\n%s", page)
+	}
+}
+
+func TestDebugHandlerEngines(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			ref, err := ezdb.NewRef[string, string]("ref", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			putN(t, ref, 3)
+
+			// Engines without statistics still list their databases.
+			want := `<a href="?db=ref">ref</a></td><td class="num">3</td>`
+			if !db.Capabilities().Files {
+				want = `<a href="?db=ref">ref</a></td><td class="num">-</td>`
+			}
+			page := debugPage(t, db, nil)
+			if !strings.Contains(page, want) {
+				t.Fatalf("debug page lacks %q:\n%s", want, page)
+			}
+		})
+	}
+}
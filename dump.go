@@ -0,0 +1,396 @@
+package ezdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// The dump format is a header, a section per named database and a trailer.
+// The header is dumpMagic followed by the format version as a uvarint. A
+// section is dumpTagDB, the database's name, its flags as a uvarint and the
+// encoding of the DBRef it was opened with, or an empty string if none was,
+// followed by a dumpTagRecord, key and value per record. The trailer is
+// dumpTagEnd, the number of records as a uvarint and the big-endian CRC-32C
+// of everything before it. Strings and byte slices are written as their
+// length as a uvarint followed by their bytes.
+const (
+	dumpMagic   = "ezdbdump"
+	dumpVersion = 1
+
+	dumpTagDB     = 1
+	dumpTagRecord = 2
+	dumpTagEnd    = 3
+
+	// dumpMaxLen bounds the lengths read from a dump, so a corrupt one can't
+	// make Load allocate without bound.
+	dumpMaxLen = 1 << 31
+)
+
+// persistentDBFlags are the flags stored in a database's record, which it
+// must be opened with.
+const persistentDBFlags = 0x7e
+
+// Dump writes every named database of the environment, including ezdb's own
+// bookkeeping, to w in a portable binary format that Load reads back. Records
+// are written as stored, along with the flags of their database and the
+// codecs of the DBRef opened for it on the Client, if any, so an environment
+// can be moved to another architecture or map size without re-encoding.
+// The dump is taken in a single read transaction and ends with a checksum.
+func (db *Client) Dump(w io.Writer) error {
+	refs := db.registeredRefs()
+
+	return db.view(func(txn readTxn) error {
+		dbs, err := txn.ListDBs()
+		if err != nil {
+			return fmt.Errorf("failed to list databases: %w", err)
+		}
+
+		bw := bufio.NewWriter(w)
+		dw := &dumpWriter{w: bw, crc: crc32.New(castagnoli)}
+		dw.writeString(dumpMagic)
+		dw.writeUvarint(dumpVersion)
+
+		var count uint64
+		for _, d := range dbs {
+			name, flags := d.name, d.flags&persistentDBFlags
+			dbRef, err := txn.DBRef(name, flags)
+			if err != nil {
+				return fmt.Errorf("failed to get db ref %s: %w", name, err)
+			}
+
+			dw.writeByte(dumpTagDB)
+			dw.writeString(name)
+			dw.writeUvarint(uint64(flags))
+			dw.writeString(refs[name].encoding)
+
+			n, err := dumpDB(txn, dbRef, dw)
+			if err != nil {
+				return fmt.Errorf("failed to dump %s: %w", name, err)
+			}
+			count += n
+		}
+
+		dw.writeByte(dumpTagEnd)
+		dw.writeUvarint(count)
+		if dw.err != nil {
+			return fmt.Errorf("failed to write dump: %w", dw.err)
+		}
+		err = binary.Write(bw, binary.BigEndian, dw.crc.Sum32())
+		if err != nil {
+			return fmt.Errorf("failed to write dump: %w", err)
+		}
+
+		return bw.Flush()
+	})
+}
+
+// dumpDB writes a record for every entry of dbRef and returns how many.
+func dumpDB(txn readTxn, dbRef dbi, dw *dumpWriter) (uint64, error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	var n uint64
+	key, val, err := cursor.First()
+	for ; err == nil && dw.err == nil; key, val, err = cursor.Next() {
+		dw.writeByte(dumpTagRecord)
+		dw.writeBytes(key)
+		dw.writeBytes(val)
+		n++
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, fmt.Errorf("failed to read entry: %w", err)
+	}
+	if dw.err != nil {
+		return 0, fmt.Errorf("failed to write dump: %w", dw.err)
+	}
+
+	return n, nil
+}
+
+// dumpWriter writes the elements of a dump and checksums them. After the
+// first error it writes nothing and keeps the error in err.
+type dumpWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	err error
+}
+
+func (dw *dumpWriter) write(b []byte) {
+	if dw.err != nil {
+		return
+	}
+	_, dw.err = dw.w.Write(b)
+	dw.crc.Write(b)
+}
+
+func (dw *dumpWriter) writeByte(b byte) {
+	dw.write([]byte{b})
+}
+
+func (dw *dumpWriter) writeUvarint(n uint64) {
+	dw.write(binary.AppendUvarint(nil, n))
+}
+
+func (dw *dumpWriter) writeBytes(b []byte) {
+	dw.writeUvarint(uint64(len(b)))
+	dw.write(b)
+}
+
+func (dw *dumpWriter) writeString(s string) {
+	dw.writeBytes([]byte(s))
+}
+
+// Load reads a dump written by Dump from r into the environment. Every named
+// database of the dump is created with its flags and must not hold any
+// entries yet; if a DBRef has already been opened for it on the Client, its
+// codecs must match the dumped ones. Records are written in chunks, each in
+// its own transaction, and the checksum is only checked at the end of the
+// dump, so load into a new environment that can be discarded if Load fails.
+func (db *Client) Load(r io.Reader) error {
+	refs := db.registeredRefs()
+	dr := &dumpReader{r: bufio.NewReader(r), crc: crc32.New(castagnoli)}
+
+	magic, err := dr.readBytes()
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if string(magic) != dumpMagic {
+		return errors.New("failed to load: not an ezdb dump")
+	}
+	version, err := dr.readUvarint()
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if version != dumpVersion {
+		return fmt.Errorf("failed to load: unsupported dump version %d", version)
+	}
+
+	var (
+		name  string
+		flags dbFlag
+		chunk []record
+		count uint64
+	)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		err := db.update(func(txn writeTxn) error {
+			dbRef, err := txn.DBRef(name, flags)
+			if err != nil {
+				return fmt.Errorf("failed to get db ref: %w", err)
+			}
+
+			for _, rec := range chunk {
+				err = txn.Put(dbRef, rec.key, rec.val, putFlag(0))
+				if err != nil {
+					return fmt.Errorf("failed to put key/value pair: %w", err)
+				}
+				db.invalidateInTxn(txn, name, rec.key)
+				err = db.bloomAddInTxn(txn, name, nil, rec.key)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", name, err)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for {
+		tag, err := dr.readByte()
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %w", err)
+		}
+
+		switch tag {
+		case dumpTagDB:
+			err = flush()
+			if err != nil {
+				return err
+			}
+			var encoding string
+			name, flags, encoding, err = dr.readDBHeader()
+			if err != nil {
+				return fmt.Errorf("failed to read dump: %w", err)
+			}
+			err = db.createLoadedDB(name, flags, encoding, refs[name].encoding)
+			if err != nil {
+				return err
+			}
+
+		case dumpTagRecord:
+			if name == "" {
+				return errors.New("failed to read dump: record outside of a database")
+			}
+			key, err := dr.readBytes()
+			if err != nil {
+				return fmt.Errorf("failed to read dump: %w", err)
+			}
+			val, err := dr.readBytes()
+			if err != nil {
+				return fmt.Errorf("failed to read dump: %w", err)
+			}
+			chunk = append(chunk, record{key: key, val: val})
+			count++
+			if len(chunk) == copyChunkSize {
+				err = flush()
+				if err != nil {
+					return err
+				}
+			}
+
+		case dumpTagEnd:
+			err = flush()
+			if err != nil {
+				return err
+			}
+			return dr.readTrailer(count)
+
+		default:
+			return fmt.Errorf("failed to read dump: unknown tag %d", tag)
+		}
+	}
+}
+
+// createLoadedDB creates the named database of a dump and checks that it is
+// empty and that the encoding it was dumped with matches the one of the DBRef
+// opened for it, if any.
+func (db *Client) createLoadedDB(name string, flags dbFlag, dumped, opened string) error {
+	if dumped != "" && opened != "" && dumped != opened {
+		return fmt.Errorf("failed to load %s: dumped with encoding %s, but opened with %s", name, dumped, opened)
+	}
+
+	err := db.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(name, flags|dbCreate)
+		if err != nil {
+			return fmt.Errorf("failed to open db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		_, _, err = cursor.First()
+		if err == nil {
+			return errors.New("database is not empty")
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// dumpReader reads the elements of a dump and checksums them.
+type dumpReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (dr *dumpReader) ReadByte() (byte, error) {
+	b, err := dr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	dr.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (dr *dumpReader) readByte() (byte, error) {
+	b, err := dr.ReadByte()
+	if errors.Is(err, io.EOF) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (dr *dumpReader) readUvarint() (uint64, error) {
+	n, err := binary.ReadUvarint(dr)
+	if errors.Is(err, io.EOF) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (dr *dumpReader) readBytes() ([]byte, error) {
+	n, err := dr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > dumpMaxLen {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(dr.r, b)
+	if err != nil {
+		return nil, err
+	}
+	dr.crc.Write(b)
+	return b, nil
+}
+
+// readDBHeader reads the rest of a database header after its tag.
+func (dr *dumpReader) readDBHeader() (name string, flags dbFlag, encoding string, err error) {
+	nameBytes, err := dr.readBytes()
+	if err != nil {
+		return "", 0, "", err
+	}
+	if len(nameBytes) == 0 {
+		return "", 0, "", errors.New("empty database name")
+	}
+	flagBits, err := dr.readUvarint()
+	if err != nil {
+		return "", 0, "", err
+	}
+	if flagBits&^persistentDBFlags != 0 {
+		return "", 0, "", fmt.Errorf("invalid flags %#x of %s", flagBits, nameBytes)
+	}
+	encodingBytes, err := dr.readBytes()
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	return string(nameBytes), dbFlag(flagBits), string(encodingBytes), nil
+}
+
+// readTrailer reads the rest of the trailer after its tag and checks it
+// against the number of records read and the checksum of the dump.
+func (dr *dumpReader) readTrailer(count uint64) error {
+	n, err := dr.readUvarint()
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	sum := dr.crc.Sum32()
+
+	var want uint32
+	err = binary.Read(dr.r, binary.BigEndian, &want)
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if n != count || sum != want {
+		return fmt.Errorf("failed to load: %w in dump", ErrChecksum)
+	}
+
+	return nil
+}
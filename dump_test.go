@@ -0,0 +1,139 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// dumpOf returns a dump of a Client with a DBRef "ref" holding putN's 100
+// entries and a MultiRef "tags".
+func dumpOf(t *testing.T) []byte {
+	t.Helper()
+
+	return dumpFrom(t, testutil.NewTempClient(t))
+}
+
+// dumpFrom is dumpOf for the new Client db.
+func dumpFrom(t *testing.T, db *ezdb.Client) []byte {
+	t.Helper()
+
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+	tags, err := ezdb.NewMultiRef[string, string](db, "tags", ezdb.WithCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewMultiRef: %v", err)
+	}
+	key := "post"
+	for _, tag := range []string{"go", "db"} {
+		err = tags.Add(&key, &tag)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = db.Dump(&buf)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// wantLoaded checks that db holds what dumpFrom dumped.
+func wantLoaded(t *testing.T, db *ezdb.Client) {
+	t.Helper()
+
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, ref, 100)
+	// The MultiRef's database keeps its flags.
+	tags, err := ezdb.NewMultiRef[string, string](db, "tags", ezdb.WithCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewMultiRef: %v", err)
+	}
+	key := "post"
+	vals, err := tags.GetAll(&key)
+	if err != nil || len(vals) != 2 || vals[0] != "db" || vals[1] != "go" {
+		t.Fatalf("GetAll = %v, %v", vals, err)
+	}
+}
+
+func TestDumpAndLoad(t *testing.T) {
+	dump := dumpOf(t)
+	db := testutil.NewTempClient(t)
+
+	err := db.Load(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	wantLoaded(t, db)
+
+	// Loaded databases must be empty.
+	err = db.Load(bytes.NewReader(dump))
+	if err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("Load into a non-empty database returned %v", err)
+	}
+}
+
+func TestLoadChecksEncodings(t *testing.T) {
+	dump := dumpOf(t)
+	db := testutil.NewTempClient(t)
+	_, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	err = db.Load(bytes.NewReader(dump))
+	if err == nil || !strings.Contains(err.Error(), "encoding") {
+		t.Fatalf("Load with other codecs returned %v", err)
+	}
+}
+
+func TestLoadRejectsBrokenDumps(t *testing.T) {
+	dump := dumpOf(t)
+	corrupt := bytes.Clone(dump)
+	corrupt[len(corrupt)/2] ^= 1
+
+	for name, tt := range map[string]struct {
+		dump []byte
+		want error
+	}{
+		"empty":     {nil, nil},
+		"garbage":   {[]byte("not a dump at all"), nil},
+		"truncated": {dump[:len(dump)-10], nil},
+		"corrupt":   {corrupt, ezdb.ErrChecksum},
+		"version":   {append([]byte("\x08ezdbdump\x02"), dump[10:]...), nil},
+	} {
+		err := testutil.NewTempClient(t).Load(bytes.NewReader(tt.dump))
+		if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Load of a %s dump returned %v", name, err)
+		}
+	}
+}
+
+func TestDumpEngines(t *testing.T) {
+	for _, from := range engines {
+		dump := dumpFrom(t, from.open(t))
+		for _, to := range engines {
+			t.Run(string(from.engine)+"/"+string(to.engine), func(t *testing.T) {
+				db := to.open(t)
+				err := db.Load(bytes.NewReader(dump))
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				wantLoaded(t, db)
+			})
+		}
+	}
+}
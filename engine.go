@@ -0,0 +1,51 @@
+package ezdb
+
+// Engine names the storage engine of a Client.
+type Engine string
+
+const (
+	// EngineLMDB is the engine of Clients created with New.
+	EngineLMDB Engine = "lmdb"
+	// EngineBolt is the engine of Clients created with NewBolt.
+	EngineBolt Engine = "bolt"
+	// EngineMemory is the engine of Clients created with NewMemory.
+	EngineMemory Engine = "memory"
+	// EnginePebble is the engine of Clients created with NewPebble.
+	EnginePebble Engine = "pebble"
+)
+
+// Capabilities reports which of the features that depend on the storage
+// engine a Client supports. Using an unsupported one fails with an error
+// matching ErrUnsupported; the DBRef API itself works on every engine.
+type Capabilities struct {
+	Engine Engine
+	// Persistent reports whether data outlives the Client.
+	Persistent bool
+	// MultiProcess reports whether several processes can open the same
+	// environment at once with writers among them, see WithReadOnly.
+	MultiProcess bool
+	// Copy reports whether Backup, BackupTo, CompactTo, CompactToWriter and
+	// WithSnapshotSchedule are supported.
+	Copy bool
+	// Files reports whether Stats, Info, ReaderCheck, Readers and
+	// DebugHandler's statistics, which report on LMDB's environment and
+	// files, are supported.
+	Files bool
+	// DBFlags reports whether WithReverseKeys, WithIntegerKeys and
+	// WithFixedSizeValues are supported.
+	DBFlags bool
+}
+
+var engineCapabilities = map[Engine]Capabilities{
+	EngineLMDB:   {Engine: EngineLMDB, Persistent: true, MultiProcess: true, Copy: true, Files: true, DBFlags: true},
+	EngineBolt:   {Engine: EngineBolt, Persistent: true},
+	EngineMemory: {Engine: EngineMemory},
+	EnginePebble: {Engine: EnginePebble, Persistent: true},
+}
+
+// Capabilities reports the features the Client's storage engine supports, so
+// code running on more than one engine can check for them up front instead
+// of handling ErrUnsupported.
+func (db *Client) Capabilities() Capabilities {
+	return engineCapabilities[db.options.engine]
+}
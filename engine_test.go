@@ -0,0 +1,72 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// engines are the storage engines, with a function opening a Client on each.
+var engines = []struct {
+	engine ezdb.Engine
+	open   func(t *testing.T) *ezdb.Client
+}{
+	{ezdb.EngineLMDB, func(t *testing.T) *ezdb.Client { return testutil.NewTempClient(t) }},
+	{ezdb.EngineMemory, func(t *testing.T) *ezdb.Client { return testutil.NewMemoryClient(t) }},
+	{ezdb.EnginePebble, func(t *testing.T) *ezdb.Client { return testutil.NewPebbleClient(t) }},
+	{ezdb.EngineBolt, func(t *testing.T) *ezdb.Client {
+		db, err := ezdb.NewBolt(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewBolt: %v", err)
+		}
+		err = db.Init()
+		if err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}},
+}
+
+func TestCapabilities(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			c := db.Capabilities()
+			if c.Engine != e.engine {
+				t.Fatalf("Capabilities report engine %q", c.Engine)
+			}
+			if c.Persistent != (e.engine != ezdb.EngineMemory) {
+				t.Errorf("Capabilities report Persistent %t", c.Persistent)
+			}
+
+			// The DBRef API works on every engine.
+			ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			putN(t, ref, 10)
+			wantN(t, ref, 10)
+
+			var buf bytes.Buffer
+			err = db.Backup(context.Background(), &buf)
+			if c.Copy && err != nil {
+				t.Errorf("Backup: %v", err)
+			}
+			if !c.Copy && !errors.Is(err, ezdb.ErrUnsupported) {
+				t.Errorf("Backup returned %v, want ErrUnsupported", err)
+			}
+			_, err = db.Stats()
+			if c.Files && err != nil {
+				t.Errorf("Stats: %v", err)
+			}
+			if !c.Files && !errors.Is(err, ezdb.ErrUnsupported) {
+				t.Errorf("Stats returned %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
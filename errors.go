@@ -0,0 +1,156 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// maxKeySize is LMDB's default limit on the encoded size of a key, as
+// reported by mdb_env_get_maxkeysize.
+const maxKeySize = 511
+
+var (
+	// ErrNotFound is returned when a key doesn't exist.
+	ErrNotFound = errors.New("ezdb: not found")
+	// ErrKeyExists is returned when a write that must not overwrite finds the
+	// key already present.
+	ErrKeyExists = errors.New("ezdb: key exists")
+	// ErrDuplicate is returned when a Put would map a value of a unique index
+	// to a second primary key.
+	ErrDuplicate = errors.New("ezdb: duplicate value in unique index")
+	// ErrClosed is returned by operations on a Client that has been closed.
+	ErrClosed = errors.New("ezdb: client closed")
+	// ErrMapFull is returned when the environment's memory map is full.
+	ErrMapFull = errors.New("ezdb: map full")
+	// ErrTxnTooBig is returned when a transaction has too many dirty pages.
+	ErrTxnTooBig = errors.New("ezdb: transaction too big")
+	// ErrAlreadyOpen is returned when a Client opens a directory that another
+	// Client of this process has open with different options or another
+	// storage engine.
+	ErrAlreadyOpen = errors.New("ezdb: environment already open with different options")
+	// ErrKeyTooLarge is matched by errors for keys whose encoding exceeds
+	// LMDB's maximum key size.
+	ErrKeyTooLarge = errors.New("ezdb: key too large")
+	// ErrValueTooLarge is matched by errors for values whose encoding exceeds
+	// the maximum value size configured for the Client or DBRef.
+	ErrValueTooLarge = errors.New("ezdb: value too large")
+	// ErrQuotaExceeded is matched by errors for Puts that would grow a DBRef
+	// beyond its quota, see WithQuota.
+	ErrQuotaExceeded = errors.New("ezdb: quota exceeded")
+	// ErrChecksum is matched by errors for stored values whose checksum
+	// doesn't match, see WithChecksums.
+	ErrChecksum = errors.New("ezdb: checksum mismatch")
+	// ErrNotSorted is matched by errors for keys given to BulkLoad out of
+	// order.
+	ErrNotSorted = errors.New("ezdb: keys not in sorted order")
+	// ErrUnsupported is matched by errors for operations the Client's storage
+	// engine doesn't support, such as reading LMDB's files of an in-memory
+	// Client, see NewMemory.
+	ErrUnsupported = errors.New("ezdb: not supported by storage engine")
+)
+
+// sentinelError adds a sentinel to an error chain without changing its
+// message.
+type sentinelError struct {
+	err      error
+	sentinel error
+}
+
+func (e *sentinelError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sentinelError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+// SizeError reports an encoded key or value that exceeds its size limit. It
+// matches ErrKeyTooLarge or ErrValueTooLarge with errors.Is.
+type SizeError struct {
+	// Err is ErrKeyTooLarge or ErrValueTooLarge.
+	Err error
+	// DB is the name of the database the write was meant for.
+	DB string
+	// Size is the encoded size in bytes.
+	Size int
+	// Limit is the maximum allowed size in bytes.
+	Limit int
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%v in %q: %d bytes, limit is %d", e.Err, e.DB, e.Size, e.Limit)
+}
+
+func (e *SizeError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned by transactions whose callback panicked, such as the
+// functions given to Update, ViewAll and ForEach. The transaction is aborted
+// as if the callback had returned an error.
+type PanicError struct {
+	// Value is the value the callback panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("ezdb: panic in transaction: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error, so errors.Is and errors.As see
+// through the panic.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recovering returns fn made to return a PanicError instead of panicking.
+func recovering[T any](fn func(txn T) error) func(txn T) error {
+	return func(txn T) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		return fn(txn)
+	}
+}
+
+// checkSizes validates encoded key and value sizes against ref's key size
+// limit, see keyLimit, and the value size limit configured for ref or,
+// failing that, its Client.
+func (ref *DBRef[K, V]) checkSizes(keySize, valSize int) error {
+	if keyLimit := ref.keyLimit(); keySize > keyLimit {
+		return &SizeError{Err: ErrKeyTooLarge, DB: ref.id, Size: keySize, Limit: keyLimit}
+	}
+
+	limit := int(ref.ownerDB.options.maxValueSize)
+	if ref.options.maxValueSize != nil {
+		limit = int(*ref.options.maxValueSize)
+	}
+	if limit > 0 && valSize > limit {
+		return &SizeError{Err: ErrValueTooLarge, DB: ref.id, Size: valSize, Limit: limit}
+	}
+
+	return nil
+}
+
+// keyLimit returns the largest encoded key ref accepts: LMDB's key size
+// limit less the most bytes a companion database of ref adds to the keys it
+// stores.
+func (ref *DBRef[K, V]) keyLimit() int {
+	switch {
+	case ref.options.history != nil:
+		// A length of at most two bytes and the revision.
+		return maxKeySize - 2 - 8
+	case ref.options.ttl != nil:
+		return maxKeySize - len(ttlExpiryPrefix) - 8
+	case ref.options.maxEntries != nil:
+		return maxKeySize - len(lruKeyPrefix)
+	}
+
+	return maxKeySize
+}
@@ -0,0 +1,230 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSizeLimits(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithMaxValueSize(100))
+	ref, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := strings.Repeat("k", 511), make([]byte, 100)
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put at the limits: %v", err)
+	}
+
+	key = strings.Repeat("k", 512)
+	err = ref.Put(&key, &val)
+	var sizeErr *ezdb.SizeError
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) || !errors.As(err, &sizeErr) {
+		t.Fatalf("Put of a long key returned %v, want a SizeError matching ErrKeyTooLarge", err)
+	}
+	if sizeErr.DB != "ref" || sizeErr.Size != 512 || sizeErr.Limit != 511 {
+		t.Fatalf("SizeError = %+v", sizeErr)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("Get of a long key returned %v, want ErrKeyTooLarge", err)
+	}
+
+	key, val = "k", make([]byte, 101)
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, ezdb.ErrValueTooLarge) || !errors.As(err, &sizeErr) {
+		t.Fatalf("Put of a large value returned %v, want a SizeError matching ErrValueTooLarge", err)
+	}
+	if sizeErr.Size != 101 || sizeErr.Limit != 100 {
+		t.Fatalf("SizeError = %+v", sizeErr)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get after a rejected Put returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key := "missing"
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of a missing key returned %v, want ErrNotFound", err)
+	}
+	err = ref.Delete(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Get after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestRefMaxValueSize(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithMaxValueSize(10))
+	small, err := ezdb.NewDBRef[string, []byte](db, "small", ezdb.WithCodec(ezdb.BytesCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	large, err := ezdb.NewDBRef[string, []byte](db, "large", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithRefMaxValueSize(1000))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	unlimited, err := ezdb.NewDBRef[string, []byte](db, "unlimited", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithRefMaxValueSize(0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "k", make([]byte, 500)
+	err = small.Put(&key, &val)
+	var sizeErr *ezdb.SizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 10 || sizeErr.DB != "small" {
+		t.Fatalf("Put over the Client's limit returned %v", err)
+	}
+	err = large.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put under the DBRef's limit: %v", err)
+	}
+	val = make([]byte, 1001)
+	err = large.Put(&key, &val)
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 1000 || sizeErr.Size != 1001 {
+		t.Fatalf("Put over the DBRef's limit returned %v", err)
+	}
+	err = unlimited.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put without a limit: %v", err)
+	}
+}
+
+func TestKeyLimitOfBookkeepingRefs(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ttl", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// The expiry index adds to the keys, so they must be shorter than
+	// LMDB's limit.
+	key, val := strings.Repeat("k", 511), "v"
+	err = ref.PutTTL(&key, &val, time.Hour)
+	var sizeErr *ezdb.SizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit >= 511 {
+		t.Fatalf("PutTTL of a 511 byte key returned %v, want a SizeError", err)
+	}
+	key = strings.Repeat("k", sizeErr.Limit)
+	err = ref.PutTTL(&key, &val, time.Hour)
+	if err != nil {
+		t.Fatalf("PutTTL at the limit: %v", err)
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			ref, err := ezdb.NewRef[string, string]("ref", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			putN(t, ref, 2)
+
+			// The writes of a panicking Update are rolled back.
+			err = db.Update(func(tx *ezdb.Tx) error {
+				key, val := "k0000", "changed"
+				err := ref.PutTx(tx, &key, &val)
+				if err != nil {
+					return err
+				}
+				panic("boom")
+			})
+			var panicErr *ezdb.PanicError
+			if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 ||
+				!strings.Contains(err.Error(), "boom") {
+				t.Fatalf("Update with a panicking fn returned %v, want a PanicError", err)
+			}
+			wantN(t, ref, 2)
+
+			// Errors panicked with are seen through.
+			errBoom := errors.New("boom")
+			err = db.ViewAll(func(*ezdb.Snapshot) error { panic(errBoom) })
+			if !errors.As(err, &panicErr) || !errors.Is(err, errBoom) {
+				t.Fatalf("ViewAll with a panicking fn returned %v, want a PanicError of errBoom", err)
+			}
+			err = ref.ForEach(func(*string, *string) error { panic("boom") })
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("ForEach with a panicking fn returned %v, want a PanicError", err)
+			}
+			if errors.Unwrap(&ezdb.PanicError{Value: "boom"}) != nil {
+				t.Fatal("PanicError of a string unwraps to an error")
+			}
+
+			// The transactions were ended.
+			putN(t, ref, 3)
+			wantN(t, ref, 3)
+		})
+	}
+}
+
+func TestPanicErrorCoalesced(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithFlushInterval(20*time.Millisecond))
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	ref.OnPut(func(tx *ezdb.Tx, key *string, old, val *string) error {
+		if *key == "panic" {
+			panic("boom")
+		}
+		return nil
+	})
+
+	// A panicking write fails on its own, not its group.
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+			if i == 2 {
+				key = "panic"
+			}
+			errs[i] = ref.Put(&key, &val)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		var panicErr *ezdb.PanicError
+		if i == 2 && !errors.As(err, &panicErr) {
+			t.Errorf("panicking Put returned %v, want a PanicError", err)
+		}
+		if i != 2 && err != nil {
+			t.Errorf("Put %d: %v", i, err)
+		}
+	}
+	key := "panic"
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of the panicking Put = %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,198 @@
+package ezdb
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a text format entries are exported in and imported from.
+type Format string
+
+const (
+	// FormatJSONLines writes one JSON object per entry and line, with the
+	// key under "key" and the value under "value".
+	FormatJSONLines Format = "jsonl"
+	// FormatCSV writes a header row and one row per entry. It only supports
+	// flat keys and values: scalars, which get the columns "key" and
+	// "value", and structs of scalars, whose fields get a column each, named
+	// like JSONCodec would name them and, for keys, prefixed with "key.".
+	// Scalars are strings, booleans, numbers, time.Time, formatted as RFC
+	// 3339, and []byte, formatted as base64.
+	FormatCSV Format = "csv"
+)
+
+// Export writes every entry of ref to w in format, in the order of the
+// encoded keys, from a single read transaction, so the export is a consistent
+// snapshot.
+func (ref *DBRef[K, V]) Export(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSONLines:
+		return ref.exportJSONLines(w)
+	case FormatCSV:
+		return ref.exportCSV(w)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// jsonLine is an entry as exported in FormatJSONLines.
+type jsonLine[K, V any] struct {
+	Key   *K `json:"key"`
+	Value *V `json:"value"`
+}
+
+func (ref *DBRef[K, V]) exportJSONLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := ref.ForEach(func(key *K, val *V) error {
+		err := enc.Encode(jsonLine[K, V]{Key: key, Value: val})
+		if err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (ref *DBRef[K, V]) exportCSV(w io.Writer) error {
+	keyCols, err := csvColumns(reflect.TypeOf((*K)(nil)).Elem(), "key", "key.")
+	if err != nil {
+		return fmt.Errorf("failed to map key to CSV: %w", err)
+	}
+	valCols, err := csvColumns(reflect.TypeOf((*V)(nil)).Elem(), "value", "")
+	if err != nil {
+		return fmt.Errorf("failed to map value to CSV: %w", err)
+	}
+	cols := append(keyCols, valCols...)
+	seen := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		if seen[col.name] {
+			return fmt.Errorf("duplicate CSV column %q", col.name)
+		}
+		seen[col.name] = true
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	err = cw.Write(header)
+	if err != nil {
+		return err
+	}
+
+	row := make([]string, len(cols))
+	err = ref.ForEach(func(key *K, val *V) error {
+		keyVal, valVal := reflect.ValueOf(key).Elem(), reflect.ValueOf(val).Elem()
+		for i, col := range cols {
+			v := valVal
+			if i < len(keyCols) {
+				v = keyVal
+			}
+			if col.index != nil {
+				v = v.FieldByIndex(col.index)
+			}
+			row[i] = formatScalar(v)
+		}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumn maps a CSV column to a scalar within a key or value.
+type csvColumn struct {
+	name string
+	// index is the field index path of the scalar, empty for a scalar key or
+	// value.
+	index []int
+}
+
+// csvColumns maps t to CSV columns: a scalar to one column named scalarName,
+// a struct of scalars to a column per exported field, named prefix followed
+// by the field's JSON name.
+func csvColumns(t reflect.Type, scalarName, prefix string) ([]csvColumn, error) {
+	if isScalar(t) {
+		return []csvColumn{{name: scalarName}}, nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is neither a scalar nor a struct", t)
+	}
+
+	var cols []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if !isScalar(field.Type) {
+			return nil, fmt.Errorf("field %s of %v is not a scalar", field.Name, t)
+		}
+		cols = append(cols, csvColumn{name: prefix + name, index: field.Index})
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%v has no exported fields", t)
+	}
+
+	return cols, nil
+}
+
+func isScalar(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	default:
+		return false
+	}
+}
+
+func formatScalar(v reflect.Value) string {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	default:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	}
+}
@@ -0,0 +1,145 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// event has a field of every kind of scalar FormatCSV supports.
+type event struct {
+	Name    string `json:"name"`
+	At      time.Time
+	Count   int
+	Ratio   float64
+	OK      bool
+	Payload []byte
+	Secret  string `json:"-"`
+	hidden  string
+}
+
+func TestExport(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, event](db, "events", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, key := range []string{"b", "a"} {
+		val := event{Name: "deploy " + key, At: at, Count: -3, Ratio: 0.5, OK: true, Payload: []byte{0xff}, Secret: "s", hidden: "h"}
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = ref.Export(&buf, ezdb.FormatCSV)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	want := "key,name,At,Count,Ratio,OK,Payload\n" +
+		"a,deploy a,2024-01-02T03:04:05.000000006Z,-3,0.5,true,/w==\n" +
+		"b,deploy b,2024-01-02T03:04:05.000000006Z,-3,0.5,true,/w==\n"
+	if buf.String() != want {
+		t.Fatalf("CSV export =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	err = ref.Export(&buf, ezdb.FormatJSONLines)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	want = `{"key":"a","value":{"name":"deploy a","At":"2024-01-02T03:04:05.000000006Z","Count":-3,"Ratio":0.5,"OK":true,"Payload":"/w=="}}` + "\n" +
+		`{"key":"b","value":{"name":"deploy b","At":"2024-01-02T03:04:05.000000006Z","Count":-3,"Ratio":0.5,"OK":true,"Payload":"/w=="}}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("JSON Lines export =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	err = ref.Export(&buf, "xml")
+	if err == nil {
+		t.Fatal("Export in an unknown format succeeded")
+	}
+}
+
+func TestExportCSVScalarsAndStructKeys(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	scalars, err := ezdb.NewDBRef[uint64, string](db, "scalars", ezdb.WithKeyCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := uint64(7), "a, \"quoted\" value"
+	err = scalars.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	var buf bytes.Buffer
+	err = scalars.Export(&buf, ezdb.FormatCSV)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "key,value\n7,\"a, \"\"quoted\"\" value\"\n"; buf.String() != want {
+		t.Fatalf("CSV export = %q, want %q", buf.String(), want)
+	}
+
+	type id struct {
+		Tenant string `json:"tenant"`
+		N      int
+	}
+	structs, err := ezdb.NewDBRef[id, float64](db, "structs", ezdb.WithKeyCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	structKey, structVal := id{Tenant: "acme", N: 1}, 2.5
+	err = structs.Put(&structKey, &structVal)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	buf.Reset()
+	err = structs.Export(&buf, ezdb.FormatCSV)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "key.tenant,key.N,value\nacme,1,2.5\n"; buf.String() != want {
+		t.Fatalf("CSV export = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestExportCSVRejectsNestedValues(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	nested, err := ezdb.NewDBRef[string, map[string]int](db, "nested", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = nested.Export(&bytes.Buffer{}, ezdb.FormatCSV)
+	if err == nil {
+		t.Fatal("CSV export of maps succeeded")
+	}
+
+	type withSlice struct{ Tags []string }
+	slices, err := ezdb.NewDBRef[string, withSlice](db, "slices", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = slices.Export(&bytes.Buffer{}, ezdb.FormatCSV)
+	if err == nil {
+		t.Fatal("CSV export of a struct with a slice succeeded")
+	}
+
+	// The column of a scalar key clashes with a field named "key".
+	type clash struct {
+		Key string `json:"key"`
+	}
+	clashing, err := ezdb.NewDBRef[string, clash](db, "clash", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = clashing.Export(&bytes.Buffer{}, ezdb.FormatCSV)
+	if err == nil {
+		t.Fatal("CSV export with duplicate columns succeeded")
+	}
+}
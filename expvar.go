@@ -0,0 +1,69 @@
+package ezdb
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// WithExpvar publishes the Client's operation counts, its environment's
+// Stats and its last failed operation with expvar under "ezdb."+name, so they
+// show up on /debug/vars. It implies WithMetrics. Names are process-wide, so
+// New fails if another variable is already published under the same name.
+func WithExpvar(name string) Option {
+	return func(option *options) error {
+		if name == "" {
+			return fmt.Errorf("expvar name must not be empty")
+		}
+		option.expvarName = name
+		option.metrics = true
+		return nil
+	}
+}
+
+func (db *Client) publishExpvar(name string) error {
+	name = "ezdb." + name
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("failed to publish expvar: %s is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(db.expvarValue))
+
+	return nil
+}
+
+// expvarValue returns the value published by WithExpvar. It is called every
+// time /debug/vars is served.
+func (db *Client) expvarValue() any {
+	v := map[string]any{}
+
+	stats, err := db.Stats()
+	if err != nil {
+		v["stats"] = map[string]string{"error": err.Error()}
+	} else {
+		v["stats"] = stats
+	}
+
+	m := db.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := map[string]uint64{}
+	errs := map[string]uint64{}
+	for k, n := range m.ops {
+		ops[k.ref+"."+k.op] = n
+		errs[k.ref+"."+k.op] = m.errors[k]
+	}
+	v["ops"] = ops
+	v["errors"] = errs
+
+	if m.lastErr != nil {
+		v["last_error"] = map[string]string{
+			"dbref": m.lastErrOp.ref,
+			"op":    m.lastErrOp.op,
+			"error": m.lastErr.Error(),
+			"time":  m.lastErrAt.Format(time.RFC3339Nano),
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,62 @@
+package ezdb_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestExpvar(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithExpvar("expvar-test"))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	key = strings.Repeat("k", 512)
+	err = ref.Put(&key, &val)
+	if err == nil {
+		t.Fatal("Put of a long key succeeded")
+	}
+
+	published := expvar.Get("ezdb.expvar-test")
+	if published == nil {
+		t.Fatal("nothing published under ezdb.expvar-test")
+	}
+	var v struct {
+		Stats     ezdb.Stats
+		Ops       map[string]uint64
+		Errors    map[string]uint64
+		LastError map[string]string `json:"last_error"`
+	}
+	err = json.Unmarshal([]byte(published.String()), &v)
+	if err != nil {
+		t.Fatalf("published %s: %v", published, err)
+	}
+	if v.Ops["ref.put"] != 2 || v.Errors["ref.put"] != 1 {
+		t.Fatalf("published the counts %v and errors %v", v.Ops, v.Errors)
+	}
+	if v.LastError["dbref"] != "ref" || v.LastError["op"] != "put" || v.LastError["error"] == "" {
+		t.Fatalf("published the last error %v", v.LastError)
+	}
+	if v.Stats.PageSize == 0 {
+		t.Fatalf("published the stats %+v", v.Stats)
+	}
+
+	_, err = ezdb.New(t.TempDir(), ezdb.WithExpvar("expvar-test"))
+	if err == nil {
+		t.Fatal("New with a name already published succeeded")
+	}
+	_, err = ezdb.New(t.TempDir(), ezdb.WithExpvar(""))
+	if err == nil {
+		t.Fatal("New with an empty expvar name succeeded")
+	}
+}
@@ -2,18 +2,38 @@ package ezdb
 
 import (
 	"bytes"
-	"encoding/gob"
+	"compress/flate"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
-	lmdb "wellquite.org/golmdb"
 )
 
 const mode = os.FileMode(0644)
 
+// Flag values from lmdb.h.
+const (
+	envReadOnly  = envFlag(0x20000)
+	envNoLock    = envFlag(0x400000)
+	envNoRdAhead = envFlag(0x800000)
+
+	dbReverseKey = dbFlag(0x02)
+	dbDupSort    = dbFlag(0x04)
+	dbIntegerKey = dbFlag(0x08)
+	dbDupFixed   = dbFlag(0x10)
+	dbCreate     = dbFlag(0x40000)
+
+	putNoOverwrite = putFlag(0x10)
+	putNoDupData   = putFlag(0x20)
+	putAppend      = putFlag(0x20000)
+)
+
 type Option func(option *options) error
 
 type options struct {
@@ -21,6 +41,33 @@ type options struct {
 	numDbs     *uint
 	batchSize  *uint
 	log        *zerolog.Logger
+	envFlags   envFlag
+	// engine is the storage engine, opened by openBackend unless it is
+	// LMDB.
+	engine      Engine
+	openBackend func(path string) (backend, error)
+	// faults wraps the storage engine in a faultBackend, if set.
+	faults *Faults
+
+	maxValueSize uint
+
+	readerCheckOnOpen bool
+	metrics           bool
+	expvarName        string
+	opLog             *opLogOptions
+	slowOpThreshold   time.Duration
+	interceptors      []Interceptor
+	audit             bool
+	oplog             bool
+	writeLimiter      *tokenBucket
+	snapshots         *snapshotOptions
+	readCache         uint64
+	flushInterval     time.Duration
+	snapshotReads     time.Duration
+	latencyTarget     time.Duration
+	// noCreate makes opening fail instead of creating a missing environment
+	// or named database.
+	noCreate bool
 }
 
 func WithNumReaders(numReaders uint) Option {
@@ -51,12 +98,137 @@ func WithLogger(logger zerolog.Logger) Option {
 	}
 }
 
+// WithMaxValueSize rejects Puts whose encoded value is larger than
+// maxValueSize bytes with a *SizeError matching ErrValueTooLarge. Zero means
+// no limit. WithRefMaxValueSize overrides it for a single DBRef.
+func WithMaxValueSize(maxValueSize uint) Option {
+	return func(option *options) error {
+		option.maxValueSize = maxValueSize
+		return nil
+	}
+}
+
+// WithReaderCheckOnOpen makes the Client clear stale reader slots, see
+// Client.ReaderCheck, every time it opens the environment.
+func WithReaderCheckOnOpen() Option {
+	return func(option *options) error {
+		option.readerCheckOnOpen = true
+		return nil
+	}
+}
+
+// WithReadOnly opens the environment read-only, for processes that only read
+// an environment written by another process, such as a sidecar. LMDB supports
+// any number of processes opening the same environment concurrently, with
+// readers never blocking the writer; the directory and the named databases
+// must already exist, and writes fail. The process still needs write access
+// to the lock file unless WithNoLock is also given.
+func WithReadOnly() Option {
+	return func(option *options) error {
+		option.envFlags |= envReadOnly
+		return nil
+	}
+}
+
+// WithNoLock disables LMDB's locking of the reader table and the writer. Only
+// use it if all processes (and Clients) opening the environment coordinate
+// externally so that no write happens while any transaction is active;
+// otherwise readers can see corrupted data.
+func WithNoLock() Option {
+	return func(option *options) error {
+		option.envFlags |= envNoLock
+		return nil
+	}
+}
+
+// WithNoReadahead turns off the operating system's readahead of the LMDB
+// data file. Random reads of a database larger than RAM then don't evict
+// useful pages for ones read ahead in vain; sequential scans get slower. It
+// has no effect on Windows, and the other storage engines ignore it.
+func WithNoReadahead() Option {
+	return func(option *options) error {
+		option.envFlags |= envNoRdAhead
+		return nil
+	}
+}
+
+// WithNoCreate makes the Client fail to open, with an error matching
+// ErrNotFound, if its directory or the data file in it doesn't exist yet, and
+// NewDBRef and the other constructors fail the same way for named databases
+// that don't, instead of creating empty ones, so a misconfigured path is
+// noticed right away rather than once data seems to have disappeared. The
+// databases ezdb keeps its own bookkeeping in, such as those of WithTTL or
+// indexes, are still created. It has no effect on NewMemory.
+func WithNoCreate() Option {
+	return func(option *options) error {
+		option.noCreate = true
+		return nil
+	}
+}
+
 type Client struct {
 	path    string
 	options *options
+	// envKey identifies the environment in the process-wide registry.
+	envKey string
+
+	// lifecycle serializes Init, Close, Shutdown, Reopen and swapEnv; mu
+	// guards the fields below. inflight counts the operations on db, and
+	// draining is set while Shutdown commits the batched writes.
+	lifecycle sync.Mutex
+	mu        sync.Mutex
+	db        backend
+	initErr   error
+	closed    bool
+	draining  bool
+	inflight  *sync.WaitGroup
+
+	// tasks run in the background while the Client is open, until stopTasks
+	// is closed.
+	tasks     []backgroundTask
+	stopTasks chan struct{}
+	taskWG    sync.WaitGroup
+
+	keyLocks keyLocks
+
+	// metrics is nil unless the Client was created with WithMetrics.
+	metrics *metrics
+	// readCache is nil unless the Client was created with WithReadCache.
+	readCache *readCache
+	// coalescer is nil unless the Client was created with WithFlushInterval.
+	coalescer *coalescer
+	// snapshotPool is nil unless the Client was created with
+	// WithSnapshotReads.
+	snapshotPool *snapshotPool
+	// snapshotter is nil unless the Client was created with
+	// WithSnapshotSchedule.
+	snapshotter *snapshotter
+	// slowOps are the last operations slower than WithSlowOpThreshold.
+	slowOps slowOpLog
 
-	db       *lmdb.LMDBClient
-	initOnce sync.Once
+	// watchers are the subscribers of Watch by DBRef name, and watching
+	// counts them. pendingChanges maps each running write transaction to the
+	// changes it made, published once it commits.
+	watchMu        sync.Mutex
+	watchers       map[string]map[*watcher]struct{}
+	watching       atomic.Int64
+	pendingChanges sync.Map
+
+	// refs describe the DBRefs opened on the Client by name, for Verify and
+	// Dump.
+	refsMu sync.Mutex
+	refs   map[string]refInfo
+
+	// mirror is set while Mirror duplicates the Client's writes.
+	mirror atomic.Pointer[Mirror]
+
+	// commitSignal, if set, is closed by the next write transaction that
+	// commits, see nextCommit.
+	commitSignal atomic.Pointer[chan struct{}]
+	// follower is set while a Follower replicates into the Client.
+	follower atomic.Pointer[Follower]
+	// bloomTask starts persisting Bloom filters once a DBRef has one.
+	bloomTask sync.Once
 }
 
 func New(path string, opts ...Option) (*Client, error) {
@@ -85,168 +257,1369 @@ func New(path string, opts ...Option) (*Client, error) {
 		o.log = new(zerolog.Logger)
 		*o.log = zerolog.Nop()
 	}
+	if o.engine == "" {
+		o.engine = EngineLMDB
+	}
 
-	return &Client{
-		path:    path,
-		options: o,
-	}, nil
+	db := &Client{
+		path:     path,
+		options:  o,
+		inflight: new(sync.WaitGroup),
+	}
+	if o.metrics {
+		db.metrics = newMetrics()
+	}
+	if o.readCache > 0 {
+		db.readCache = newReadCache(o.readCache)
+	}
+	switch {
+	case o.flushInterval > 0 && o.latencyTarget > 0:
+		return nil, errors.New("flush interval and latency target can't be combined")
+	case o.flushInterval > 0:
+		db.coalescer = &coalescer{db: db, interval: o.flushInterval}
+	case o.latencyTarget > 0:
+		db.coalescer = &coalescer{db: db, target: o.latencyTarget, limit: 1}
+	}
+	if o.snapshotReads > 0 {
+		db.snapshotPool = &snapshotPool{db: db, maxStaleness: o.snapshotReads, reads: make(chan snapshotRead)}
+		for i := 0; i < snapshotPoolSize; i++ {
+			db.startBackground(db.snapshotPool.serve)
+		}
+	}
+	if o.snapshots != nil {
+		db.snapshotter = &snapshotter{db: db, options: o.snapshots}
+		db.startBackground(db.snapshotter.run)
+	}
+	if o.expvarName != "" {
+		err := db.publishExpvar(o.expvarName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
 }
 
-func (db *Client) init() error {
-	// Check if directory exists, if not create it.
-	if _, err := os.Stat(db.path); os.IsNotExist(err) {
-		err = os.MkdirAll(db.path, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("failed to create db directory: %w", err)
+// Init opens the underlying environment if it isn't open yet. It is safe to
+// call more than once: a failed initialization is not cached, so a later call
+// retries it, e.g. after a temporarily unwritable directory has been fixed.
+func (db *Client) Init() error {
+	db.lifecycle.Lock()
+	defer db.lifecycle.Unlock()
+
+	db.mu.Lock()
+	closed, open := db.closed, db.db != nil
+	db.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+	if open {
+		return nil
+	}
+
+	return db.open()
+}
+
+// Reopen opens a closed Client again. DBRefs created before Close keep
+// working once Reopen succeeds, so they don't need to be rebuilt. If reopening
+// fails the Client stays closed and Reopen may be retried. Calling Reopen on a
+// Client that was never closed is equivalent to calling Init.
+func (db *Client) Reopen() error {
+	db.lifecycle.Lock()
+	defer db.lifecycle.Unlock()
+
+	db.mu.Lock()
+	open := db.db != nil
+	db.mu.Unlock()
+
+	if open {
+		return nil
+	}
+
+	return db.open()
+}
+
+// open initializes the environment and marks the Client as open. The caller
+// must hold db.lifecycle.
+func (db *Client) open() error {
+	newDB, err := db.init()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err == nil && db.options.audit {
+		err = db.openAuditLog(newDB)
+		if err != nil && releaseShared(db.envKey) {
+			newDB.Close()
 		}
 	}
 
-	// Open DB.
-	newDB, err := lmdb.NewLMDB(*db.options.log, db.path, mode, *db.options.numReaders, *db.options.numDbs, lmdb.EnvironmentFlag(0), *db.options.batchSize)
+	db.initErr = err
 	if err != nil {
-		return fmt.Errorf("failed to open db: %w", err)
+		return err
 	}
 
 	db.db = newDB
+	db.closed = false
+	db.runTasks()
 	return nil
 }
 
-func (db *Client) Close() {
-	db.db.TerminateSync()
+// acquire returns the open environment and registers an in-flight operation
+// on it that Close waits for. Every successful call must be paired with a
+// call of release.
+func (db *Client) acquire() (env backend, release func(), err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, nil, ErrClosed
+	}
+	if db.db == nil {
+		if db.initErr != nil {
+			return nil, nil, fmt.Errorf("database not initialized: %w", db.initErr)
+		}
+		return nil, nil, fmt.Errorf("database not initialized")
+	}
+
+	inflight := db.inflight
+	inflight.Add(1)
+	return db.db, inflight.Done, nil
+}
+
+// envPath returns the directory of the environment.
+func (db *Client) envPath() string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.path
 }
 
-type DBRef[K, V any] struct {
-	id      string
-	ownerDB *Client
-	// TODO: reuse the gob encoder here.
-	// Also, since typeinfo is hardcoded here, maybe better to replace gob with raw bytes.
-	// Worth looking into go-bolt for their pure byte implementation.
+// lmdbPath returns the directory of the environment's LMDB files, for the
+// features that read them directly. It fails with ErrUnsupported for other
+// storage engines.
+func (db *Client) lmdbPath() (string, error) {
+	if !db.Capabilities().Files {
+		return "", fmt.Errorf("failed to find LMDB files of %s engine: %w", db.options.engine, ErrUnsupported)
+	}
+
+	return db.envPath(), nil
 }
 
-func NewRef[K, V any](refID string, db *Client) (ref *DBRef[K, V], err error) {
-	ref = new(DBRef[K, V])
-	err = ref.init(refID, db)
+// view runs fn in a read transaction, returning a PanicError if fn panics.
+func (db *Client) view(fn func(txn readTxn) error) error {
+	fn = recovering(fn)
+	if db.snapshotPool != nil {
+		ran, err := db.snapshotPool.view(fn)
+		if ran {
+			return err
+		}
+	}
+
+	env, release, err := db.acquire()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize db ref: %w", err)
+		return err
 	}
+	defer release()
+	defer db.metrics.recordTxn("read", time.Now())
 
-	return ref, nil
+	return env.View(fn)
 }
 
-func (ref *DBRef[K, V]) init(refID string, db *Client) error {
-	var err error
-	db.initOnce.Do(func() {
-		err = db.init()
-	})
+// update runs fn in a write transaction, returning a PanicError if fn panics,
+// and publishes its changes once it commits.
+func (db *Client) update(fn func(txn writeTxn) error) error {
+	fn = recovering(fn)
+	env, release, err := db.acquire()
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return err
+	}
+	defer release()
+	defer db.metrics.recordTxn("write", time.Now())
+	if db.snapshotPool != nil {
+		defer db.snapshotPool.changing()()
 	}
 
-	err = db.db.Update(func(txn *lmdb.ReadWriteTxn) error {
-		_, err := txn.DBRef(refID, lmdb.DatabaseFlag(0x40000))
-		if err != nil {
-			return err
-		}
+	var changes []change
+	var written []writeTxn
+	err = env.Update(func(txn writeTxn) error {
+		// fn may be run again if the transaction is retried.
+		changes = changes[:0]
+		db.pendingChanges.Store(txn, &changes)
+		defer db.pendingChanges.Delete(txn)
+		written = append(written, txn)
 
-		return nil
+		return fn(txn)
 	})
+	if db.readCache != nil {
+		for _, txn := range written {
+			db.readCache.finish(txn)
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to open db ref: %w", err)
+		return err
 	}
-
-	*ref = DBRef[K, V]{
-		id:      refID,
-		ownerDB: db,
+	if len(changes) > 0 {
+		db.publish(changes)
+	}
+	if m := db.mirror.Load(); m != nil {
+		m.notify()
+	}
+	if signal := db.commitSignal.Swap(nil); signal != nil {
+		close(*signal)
 	}
 
 	return nil
 }
 
-func (ref *DBRef[K, V]) Put(key *K, val *V) (err error) {
-	err = ref.ownerDB.db.Update(func(txn *lmdb.ReadWriteTxn) error {
-		dbRef, err := txn.DBRef(ref.id, lmdb.DatabaseFlag(0))
+func (db *Client) init() (backend, error) {
+	// An in-memory environment has no directory to create or share.
+	if db.path == "" && db.options.openBackend != nil {
+		return db.openEnv()
+	}
+
+	if db.options.noCreate {
+		err := db.checkEnvExists()
 		if err != nil {
-			return fmt.Errorf("failed to get db ref: %w", err)
+			return nil, err
 		}
+	}
 
-		// Encode the key.
-		keyBuf, err := encode(key)
+	// Check if directory exists, if not create it.
+	if _, err := os.Stat(db.path); os.IsNotExist(err) && !db.readOnly() {
+		err = os.MkdirAll(db.path, os.ModePerm)
 		if err != nil {
-			return fmt.Errorf("failed to encode key: %w", err)
+			return nil, fmt.Errorf("failed to create db directory: %w", err)
 		}
+	}
+
+	key, err := registryKey(db.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve db path: %w", err)
+	}
+
+	// Share the environment with any other Client of this process that has
+	// the same directory open.
+	newDB, err := openShared(key, db.options, db.openEnv)
+	if err != nil {
+		return nil, err
+	}
 
-		// Encode the value.
-		valBuf, err := encode(val)
+	db.envKey = key
+	return newDB, nil
+}
+
+// checkEnvExists checks that the directory of the Client and the data file,
+// or directory, of its storage engine in it exist.
+func (db *Client) checkEnvExists() error {
+	name := dataFileName
+	switch db.options.engine {
+	case EngineBolt:
+		name = boltFileName
+	case EnginePebble:
+		name = pebbleDirName
+	}
+
+	for _, path := range []string{db.path, filepath.Join(db.path, name)} {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("environment %s doesn't exist: %w", path, ErrNotFound)
+		}
 		if err != nil {
-			return fmt.Errorf("failed to encode value: %w", err)
+			return fmt.Errorf("failed to check environment: %w", err)
 		}
+	}
+
+	return nil
+}
+
+func (db *Client) openEnv() (backend, error) {
+	var newDB backend
+	var err error
+	if db.options.openBackend != nil {
+		newDB, err = db.options.openBackend(db.path)
+	} else {
+		newDB, err = db.openLMDBEnv()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if db.options.faults != nil {
+		newDB = &faultBackend{backend: newDB, faults: db.options.faults}
+	}
 
-		err = txn.Put(dbRef, keyBuf.Bytes(), valBuf.Bytes(), lmdb.PutFlag(0))
+	return newDB, nil
+}
+
+func (db *Client) openLMDBEnv() (backend, error) {
+	// Open DB.
+	newDB, err := openLMDB(db.path, db.options)
+	if err != nil {
+		return nil, err
+	}
+
+	if checker, ok := newDB.(readerChecker); ok && db.options.readerCheckOnOpen {
+		cleared, err := checker.ReaderCheck()
 		if err != nil {
-			return fmt.Errorf("failed to put key/value pair: %w", err)
+			newDB.Close()
+			return nil, fmt.Errorf("failed to check readers: %w", err)
 		}
+		if cleared > 0 {
+			db.options.log.Warn().Int("cleared", cleared).Msg("cleared stale reader slots")
+		}
+	}
 
+	return newDB, nil
+}
+
+// readOnly reports whether the environment is opened with WithReadOnly.
+func (db *Client) readOnly() bool {
+	return db.options.envFlags&envReadOnly != 0
+}
+
+// Close stops accepting new operations and stops background tasks such as
+// TTL sweepers, waits for in-flight operations (including batched writes) to
+// commit, syncs the environment to disk and terminates it
+// once no other Client of this process shares it.
+// Operations started after Close return ErrClosed until the Client is
+// reopened with Reopen.
+func (db *Client) Close() error {
+	db.lifecycle.Lock()
+	defer db.lifecycle.Unlock()
+
+	err := db.Flush()
+	if err != nil {
+		db.options.log.Error().Err(err).Msg("failed to flush writes")
+	}
+	err = db.saveBlooms()
+	if err != nil {
+		db.options.log.Error().Err(err).Msg("failed to persist bloom filters")
+	}
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	db.closed = true
+	env, inflight := db.db, db.inflight
+	db.db = nil
+	db.mu.Unlock()
+
+	if env == nil {
 		return nil
-	})
+	}
+
+	close(db.stopTasks)
+	db.taskWG.Wait()
+	inflight.Wait()
+
+	return db.terminate(env)
+}
+
+// terminate syncs env to disk and closes it once no other Client of this
+// process shares it. No operations may be running on env.
+func (db *Client) terminate(env backend) error {
+	err := env.Sync(true)
+	if releaseShared(db.envKey) {
+		dropBlooms(env)
+		env.Close()
+	}
+	db.readCache.clear()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to sync db: %w", err)
 	}
 
 	return nil
 }
 
-func (ref *DBRef[K, V]) Get(key *K) (val *V, err error) {
-	err = ref.ownerDB.db.View(func(txn *lmdb.ReadOnlyTxn) error {
-		dbRef, err := txn.DBRef(ref.id, lmdb.DatabaseFlag(0))
-		if err != nil {
-			return fmt.Errorf("failed to get db ref: %w", err)
-		}
+// Ping verifies that the Client is open and that a read transaction can be
+// started and finished, for use in readiness and liveness probes. It returns
+// ctx.Err() if ctx is done before the transaction completes.
+func (db *Client) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		// Encode the key.
-		keyBuf, err := encode(key)
-		if err != nil {
-			return fmt.Errorf("failed to encode key: %w", err)
-		}
+	env, release, err := db.acquire()
+	if err != nil {
+		return err
+	}
 
-		// Get the value.
-		valBytes, err := txn.Get(dbRef, keyBuf.Bytes())
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		done <- env.View(func(txn readTxn) error {
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
 		if err != nil {
-			return fmt.Errorf("failed to get key: %w", err)
+			return fmt.Errorf("failed to begin read transaction: %w", err)
 		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-		// Decode the value.
-		err = decode(&val, bytes.NewReader(valBytes))
-		if err != nil {
-			return fmt.Errorf("failed to decode value: %w", err)
+type DBRef[K, V any] struct {
+	id      string
+	ownerDB *Client
+	options *refOptions
+	indexes []indexer[V]
+	// prefix is prepended to every encoded key of a namespace made by Sub.
+	prefix []byte
+	// ctx is the context bound with WithContext, if any.
+	ctx context.Context
+	// TODO: reuse the gob encoder here.
+	// Also, since typeinfo is hardcoded here, maybe better to replace gob with raw bytes.
+	// Worth looking into go-bolt for their pure byte implementation.
+}
+
+type RefOption func(option *refOptions) error
+
+type refOptions struct {
+	keyCodec Codec
+	valCodec Codec
+	// compressLevel is the flate level values are compressed with, if set.
+	compressLevel *int
+	// checksums appends a CRC-32C of every stored value, if set.
+	checksums bool
+	// maxValueSize overrides the Client's value size limit, if set.
+	maxValueSize *uint
+	// validators are func(*K, *V) error, for the K and V of the DBRef.
+	validators []any
+	// maxEntries caps the number of entries, evicting the least recently used
+	// ones, if set.
+	maxEntries *uint
+	// ttl enables PutTTL, if set.
+	ttl *ttlOptions
+	// onPut and onDelete are the triggers registered with OnPut and
+	// OnDelete, for the K and V of the DBRef.
+	onPut    []any
+	onDelete []any
+	// quota caps the bytes stored in the DBRef, if set.
+	quota *quotaOptions
+	// bloom enables a Bloom filter of the keys, if set.
+	bloom *bloomOptions
+	// keyPrefixes replaces common prefixes of the encoded keys, if set.
+	keyPrefixes *keyPrefixOptions
+	// chunking stores large values in chunks, if set.
+	chunking *chunkOptions
+	// history is the number of versions of every value kept, if set.
+	history *uint
+	// softDelete keeps tombstones of soft-deleted entries, if set.
+	softDelete bool
+	// gc configures GC, if set.
+	gc *gcOptions
+	// transforms rewrite the encodings of values, see WithTransform.
+	transforms []Transform
+	// dbFlags are the flags of the DBRef's named database beyond dbDupSort,
+	// see WithReverseKeys.
+	dbFlags dbFlag
+}
+
+// WithCodec sets the codec values are stored with. The default is GobCodec.
+func WithCodec(codec Codec) RefOption {
+	return func(option *refOptions) error {
+		option.valCodec = codec
+		return nil
+	}
+}
+
+// WithKeyCodec sets the codec keys are stored with. The default is GobCodec.
+func WithKeyCodec(codec Codec) RefOption {
+	return func(option *refOptions) error {
+		option.keyCodec = codec
+		return nil
+	}
+}
+
+// WithCompression compresses encoded values with DEFLATE at the given
+// compress/flate level. Values written without compression can't be read once
+// it is enabled, and vice versa.
+func WithCompression(level int) RefOption {
+	return func(option *refOptions) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level %d", level)
 		}
+		option.compressLevel = &level
+		return nil
+	}
+}
 
+// WithChecksums stores a CRC-32C checksum after every encoded (and, if
+// enabled, compressed) value and verifies it whenever the value is read, so
+// silent corruption of the storage fails reads with an error matching
+// ErrChecksum instead of returning wrong data. Values written without
+// checksums can't be read once they are enabled, and vice versa.
+func WithChecksums() RefOption {
+	return func(option *refOptions) error {
+		option.checksums = true
+		return nil
+	}
+}
+
+// WithRefMaxValueSize rejects Puts to this DBRef whose encoded value is larger
+// than maxValueSize bytes with a *SizeError matching ErrValueTooLarge. It
+// overrides the Client's WithMaxValueSize; zero means no limit.
+func WithRefMaxValueSize(maxValueSize uint) RefOption {
+	return func(option *refOptions) error {
+		option.maxValueSize = &maxValueSize
 		return nil
-	})
-	if err != nil {
-		return new(V), err
 	}
+}
 
-	return val, nil
+// WithValidator registers a function that checks every key/value pair before
+// it is encoded and written; a non-nil error aborts the write and is returned
+// wrapped. Validators run in the order they were registered. K and V must be
+// the key and value types of the DBRef the option is passed to.
+func WithValidator[K, V any](validate func(key *K, val *V) error) RefOption {
+	return func(option *refOptions) error {
+		option.validators = append(option.validators, validate)
+		return nil
+	}
+}
+
+// NewRef opens the named database refID of db with default options, creating
+// it if it doesn't exist. It is equivalent to NewDBRef(db, refID).
+func NewRef[K, V any](refID string, db *Client) (ref *DBRef[K, V], err error) {
+	return NewDBRef[K, V](db, refID)
 }
 
-func encode[T any](val *T) (buf bytes.Buffer, err error) {
-	encoder := gob.NewEncoder(&buf)
-	err = encoder.Encode(val)
+// NewDBRef opens the named database name of db, creating it if it doesn't
+// exist, and configures how its keys and values are stored.
+func NewDBRef[K, V any](db *Client, name string, opts ...RefOption) (ref *DBRef[K, V], err error) {
+	o, err := newRefOptions[K, V](opts)
 	if err != nil {
-		return buf, err
+		return nil, err
 	}
 
-	return buf, nil
-}
+	err = o.checkDBFlags(dbFlag(0))
+	if err != nil {
+		return nil, err
+	}
+
+	ref = new(DBRef[K, V])
+	err = ref.init(name, db, o, o.dbFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize db ref: %w", err)
+	}
+	ref.register(o.dbFlags)
 
-// Decodes a value from a reader into a pointer to a value.
-// Will try and fail if the decoded type is not assignable to the thing we're decoding into.
-func decode[T any](val *T, r io.Reader) error {
-	decoder := gob.NewDecoder(r)
-	err := decoder.Decode(val)
+	if o.tracksAccess() {
+		err = ref.initLRU()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access order: %w", err)
+		}
+	}
+	if o.ttl != nil {
+		err = ref.initTTL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open expiry: %w", err)
+		}
+	}
+	if o.quota != nil {
+		err = ref.initQuota()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open usage: %w", err)
+		}
+	}
+	if o.keyPrefixes != nil {
+		err = ref.initKeyPrefixes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key prefixes: %w", err)
+		}
+	}
+	if o.chunking != nil {
+		err = ref.initChunks()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open chunks: %w", err)
+		}
+	}
+	if o.history != nil {
+		err = ref.initHistory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open history: %w", err)
+		}
+	}
+	if o.softDelete {
+		err = ref.initSoftDelete()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tombstones: %w", err)
+		}
+	}
+	if o.bloom != nil {
+		err = ref.initBloom()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bloom filter: %w", err)
+		}
+	}
+	if o.gc != nil && o.gc.interval > 0 && !db.readOnly() {
+		db.startBackground(ref.gcLoop)
+	}
+
+	return ref, nil
+}
+
+// newRefOptions applies opts for a DBRef with keys of type K and values of
+// type V and fills in the defaults.
+func newRefOptions[K, V any](opts []RefOption) (*refOptions, error) {
+	o := &refOptions{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	for _, validator := range o.validators {
+		if _, ok := validator.(func(*K, *V) error); !ok {
+			return nil, fmt.Errorf("validator %T doesn't match key and value types", validator)
+		}
+	}
+
+	if o.maxEntries != nil && o.quota != nil && o.quota.policy == QuotaEvictOldest {
+		return nil, errors.New("max entries can't be combined with evicting the oldest entries")
+	}
+	if o.history != nil && o.chunking != nil {
+		return nil, errors.New("history can't be kept of chunked values")
+	}
+
+	// Default values
+	if o.keyCodec == nil {
+		o.keyCodec = GobCodec{}
+	}
+	if o.valCodec == nil {
+		o.valCodec = GobCodec{}
+	}
+
+	return o, nil
+}
+
+// init opens the named database refID of db with the given flags, creating it
+// unless db is read-only.
+func (ref *DBRef[K, V]) init(refID string, db *Client, o *refOptions, flags dbFlag) error {
+	err := db.Init()
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// A read-only environment can't create databases, only check that they
+	// exist; neither does a Client with WithNoCreate.
+	if db.readOnly() || (db.options.noCreate && db.options.engine != EngineMemory) {
+		err = db.view(func(txn readTxn) error {
+			_, err := txn.DBRef(refID, flags)
+			return err
+		})
+	} else {
+		err = db.update(func(txn writeTxn) error {
+			_, err := txn.DBRef(refID, flags|dbCreate)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open db ref: %w", err)
+	}
+
+	*ref = DBRef[K, V]{
+		id:      refID,
+		ownerDB: db,
+		options: o,
+	}
+
+	return nil
+}
+
+func (ref *DBRef[K, V]) Put(key *K, val *V) (err error) {
+	return ref.put(key, val, putFlag(0))
+}
+
+func (ref *DBRef[K, V]) put(key *K, val *V, flags putFlag) error {
+	return ref.intercept(opPut, key, val, func() error {
+		return ref.doPut(key, val, flags)
+	})
+}
+
+func (ref *DBRef[K, V]) doPut(key *K, val *V, flags putFlag) error {
+	return ref.putWith(key, val, flags, nil)
+}
+
+// putWith is doPut, also running then, if not nil, in the write transaction
+// right after the entry is written.
+func (ref *DBRef[K, V]) putWith(key *K, val *V, flags putFlag, then func(txn writeTxn, keyBytes []byte) error) (err error) {
+	var keyBytes []byte
+	var n int
+	t := newOpTimer()
+	defer func() { ref.ownerDB.finishOp(ref.id, opPut, &t, len(keyBytes), n, err) }()
+
+	keyBytes, enc, err := ref.encodeEntry(key, val, true)
+	t.mark(&t.encode)
+	if err != nil {
+		return err
+	}
+	ref.ownerDB.options.writeLimiter.wait()
+	enc, err = ref.stageChunks(enc)
+	if err != nil {
+		return err
+	}
+
+	err = ref.ownerDB.write(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		err = ref.putEncodedInTxn(txn, dbRef, keyBytes, enc, val, flags)
+		if err != nil || then == nil {
+			return err
+		}
+
+		return then(txn, keyBytes)
+	})
+	t.mark(&t.txn)
+	if err != nil {
+		ref.discardChunks(enc)
+		return err
+	}
+	n = len(keyBytes) + enc.len()
+
+	return nil
+}
+
+// encodePair validates and encodes a key/value pair about to be written.
+func (ref *DBRef[K, V]) encodePair(key *K, val *V) (keyBytes, valBytes []byte, err error) {
+	keyBytes, enc, err := ref.encodeEntry(key, val, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyBytes, enc.bytes, nil
+}
+
+// encodeEntry is encodePair, leaving the value to be encoded in place if
+// sized is set and its codec supports that, see encodeValSized.
+func (ref *DBRef[K, V]) encodeEntry(key *K, val *V, sized bool) (keyBytes []byte, enc encodedVal, err error) {
+	err = ref.validate(key, val)
+	if err != nil {
+		return nil, encodedVal{}, err
+	}
+
+	// Encode the key.
+	keyBytes, err = ref.encodeKey(key)
+	if err != nil {
+		return nil, encodedVal{}, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	// Encode the value.
+	ok := false
+	if sized {
+		enc, ok, err = ref.encodeValSized(val)
+	}
+	if !ok {
+		enc.bytes, err = ref.encodeVal(val)
+	}
+	if err != nil {
+		return nil, encodedVal{}, fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	err = ref.checkSizes(len(keyBytes), enc.len())
+	if err != nil {
+		return nil, encodedVal{}, err
+	}
+
+	return keyBytes, enc, nil
+}
+
+// putInTxn writes an encoded key/value pair and updates the DBRef's indexes.
+// val is the decoded value, which the indexes are computed from.
+func (ref *DBRef[K, V]) putInTxn(txn writeTxn, dbRef dbi, keyBytes, valBytes []byte, val *V, flags putFlag) error {
+	return ref.putEncodedInTxn(txn, dbRef, keyBytes, encodedVal{bytes: valBytes}, val, flags)
+}
+
+// putEncodedInTxn is putInTxn for a value that may still have to be encoded
+// in place.
+func (ref *DBRef[K, V]) putEncodedInTxn(txn writeTxn, dbRef dbi, keyBytes []byte, enc encodedVal, val *V, flags putFlag) error {
+	var old *V
+	if len(ref.indexes) > 0 || ref.hasTriggers() {
+		var err error
+		old, err = ref.getInTxn(txn, dbRef, keyBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.chunking != nil {
+		var err error
+		enc, err = ref.chunkInTxn(txn, dbRef, keyBytes, enc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.quota != nil {
+		err := ref.chargeInTxn(txn, dbRef, keyBytes, uint64(len(keyBytes)+enc.len()))
+		if err != nil {
+			return err
+		}
+	}
+
+	valBytes, err := writeVal(txn, dbRef, keyBytes, enc, flags)
+	if err != nil {
+		return fmt.Errorf("failed to put key/value pair: %w", err)
+	}
+	if enc.encode != nil && (ref.options.history != nil || ref.ownerDB.options.oplog || ref.ownerDB.mirror.Load() != nil) {
+		// Space reserved by the storage engine is only valid until the next
+		// write, see reserveTxn.
+		valBytes = bytes.Clone(valBytes)
+	}
+
+	err = ref.bloomInTxn(txn, keyBytes)
+	if err != nil {
+		return err
+	}
+	ref.ownerDB.invalidateInTxn(txn, ref.id, keyBytes)
+
+	err = ref.reindex(txn, keyBytes, old, val)
+	if err != nil {
+		return err
+	}
+
+	err = ref.triggerInTxn(txn, keyBytes, old, val)
+	if err != nil {
+		return err
+	}
+
+	err = ref.historyInTxn(txn, keyBytes, valBytes)
+	if err != nil {
+		return err
+	}
+	err = ref.auditInTxn(txn, opPut, keyBytes)
+	if err != nil {
+		return err
+	}
+	err = ref.oplogInTxn(txn, opPut, keyBytes, valBytes)
+	if err != nil {
+		return err
+	}
+	err = ref.mirrorInTxn(txn, keyBytes, valBytes, false)
+	if err != nil {
+		return err
+	}
+	if ref.options.ttl != nil && ref.ownerDB.watching.Load() > 0 {
+		expired, err := ref.expiredInTxn(txn, keyBytes, time.Now())
+		if err != nil {
+			return err
+		}
+		if expired {
+			ref.ownerDB.recordChange(txn, change{ref: ref.id, op: opExpire, keyBytes: keyBytes})
+		}
+	}
+	ref.ownerDB.recordChange(txn, change{ref: ref.id, op: opPut, keyBytes: keyBytes, val: val})
+
+	if ref.options.ttl != nil {
+		err = ref.clearExpiryInTxn(txn, keyBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.tracksAccess() {
+		return ref.touchInTxn(txn, keyBytes)
+	}
+
+	return nil
+}
+
+// writeVal puts enc under keyBytes and returns the encoded value, encoding it
+// right into the space the storage engine reserves for it if it can, in which
+// case it is only valid until the next write of txn.
+func writeVal(txn writeTxn, dbRef dbi, keyBytes []byte, enc encodedVal, flags putFlag) ([]byte, error) {
+	if enc.encode == nil {
+		return enc.bytes, txn.Put(dbRef, keyBytes, enc.bytes, flags)
+	}
+
+	if rt, ok := txn.(reserveTxn); ok {
+		buf, err := rt.PutReserve(dbRef, keyBytes, enc.size, flags)
+		if err != nil {
+			return nil, err
+		}
+		return buf, enc.encode(buf)
+	}
+
+	buf := make([]byte, enc.size)
+	err := enc.encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf, txn.Put(dbRef, keyBytes, buf, flags)
+}
+
+// getInTxn returns the decoded value stored under keyBytes, or nil if there
+// is none.
+func (ref *DBRef[K, V]) getInTxn(txn readTxn, dbRef dbi, keyBytes []byte) (*V, error) {
+	valBytes, err := txn.Get(dbRef, keyBytes)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+
+	val, err := ref.decodeValInTxn(txn, valBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return val, nil
+}
+
+// Delete removes the value stored under key, along with its index entries. A
+// missing key is reported as an error matching ErrNotFound.
+func (ref *DBRef[K, V]) Delete(key *K) error {
+	return ref.intercept(opDelete, key, nil, func() error {
+		return ref.doDelete(key)
+	})
+}
+
+func (ref *DBRef[K, V]) doDelete(key *K) error {
+	return ref.writeKey(opDelete, key, ref.deleteInTxn)
+}
+
+// writeKey runs fn on the encoded key in a write transaction, the way Delete
+// does: rate limited, coalesced with other writes and recorded as op.
+func (ref *DBRef[K, V]) writeKey(op string, key *K, fn func(txn writeTxn, dbRef dbi, keyBytes []byte) error) (err error) {
+	var keyBytes []byte
+	t := newOpTimer()
+	defer func() { ref.ownerDB.finishOp(ref.id, op, &t, len(keyBytes), 0, err) }()
+
+	// Encode the key.
+	keyBytes, err = ref.encodeKey(key)
+	t.mark(&t.encode)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	err = ref.checkSizes(len(keyBytes), 0)
+	if err != nil {
+		return err
+	}
+	ref.ownerDB.options.writeLimiter.wait()
+
+	err = ref.ownerDB.write(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		return fn(txn, dbRef, keyBytes)
+	})
+	t.mark(&t.txn)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deleteInTxn deletes an encoded key and its index entries.
+func (ref *DBRef[K, V]) deleteInTxn(txn writeTxn, dbRef dbi, keyBytes []byte) error {
+	return ref.removeInTxn(txn, dbRef, keyBytes, opDelete)
+}
+
+// removeInTxn is deleteInTxn, reporting the deletion to watchers as op, or
+// as opExpire if the entry has expired.
+func (ref *DBRef[K, V]) removeInTxn(txn writeTxn, dbRef dbi, keyBytes []byte, op string) error {
+	var old *V
+	if len(ref.indexes) > 0 || ref.hasTriggers() {
+		var err error
+		old, err = ref.getInTxn(txn, dbRef, keyBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.quota != nil {
+		err := ref.chargeInTxn(txn, dbRef, keyBytes, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.chunking != nil {
+		chunksRef, err := txn.DBRef(ref.chunksDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		err = ref.unchunkInTxn(txn, dbRef, chunksRef, keyBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := txn.Delete(dbRef, keyBytes, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	ref.ownerDB.invalidateInTxn(txn, ref.id, keyBytes)
+
+	err = ref.reindex(txn, keyBytes, old, nil)
+	if err != nil {
+		return err
+	}
+
+	err = ref.triggerInTxn(txn, keyBytes, old, nil)
+	if err != nil {
+		return err
+	}
+
+	err = ref.historyInTxn(txn, keyBytes, nil)
+	if err != nil {
+		return err
+	}
+	err = ref.auditInTxn(txn, opDelete, keyBytes)
+	if err != nil {
+		return err
+	}
+	err = ref.oplogInTxn(txn, opDelete, keyBytes, nil)
+	if err != nil {
+		return err
+	}
+	err = ref.mirrorInTxn(txn, keyBytes, nil, true)
+	if err != nil {
+		return err
+	}
+	if ref.options.ttl != nil && op != opExpire && ref.ownerDB.watching.Load() > 0 {
+		expired, err := ref.expiredInTxn(txn, keyBytes, time.Now())
+		if err != nil {
+			return err
+		}
+		if expired {
+			op = opExpire
+		}
+	}
+	ref.ownerDB.recordChange(txn, change{ref: ref.id, op: op, keyBytes: keyBytes})
+
+	if ref.options.ttl != nil {
+		err = ref.clearExpiryInTxn(txn, keyBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref.options.tracksAccess() {
+		return ref.forgetInTxn(txn, keyBytes)
+	}
+
+	return nil
+}
+
+// validate runs the DBRef's validators on a key/value pair about to be
+// written.
+func (ref *DBRef[K, V]) validate(key *K, val *V) error {
+	for _, validator := range ref.options.validators {
+		err := validator.(func(*K, *V) error)(key, val)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value stored under key. A missing key is reported as an
+// error matching ErrNotFound; use TryGet to check for presence instead.
+func (ref *DBRef[K, V]) Get(key *K) (*V, error) {
+	val, ok, err := ref.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return val, nil
+}
+
+// TryGet returns the value stored under key and whether it was found. A
+// missing key is not an error.
+func (ref *DBRef[K, V]) TryGet(key *K) (val *V, ok bool, err error) {
+	if ref.cached() {
+		return ref.tryGetCached(key)
+	}
+
+	ok, err = ref.getRaw(key, func(valBytes []byte) error {
+		// Decode the value.
+		val, err = ref.decodeVal(valBytes)
+		if err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, ok, nil
+}
+
+// getRaw calls fn with the stored bytes of the value under key, if there is
+// one, and reports whether there was. valBytes points into LMDB's memory map
+// and is only valid until fn returns.
+func (ref *DBRef[K, V]) getRaw(key *K, fn func(valBytes []byte) error) (ok bool, err error) {
+	err = ref.intercept(opGet, key, nil, func() error {
+		ok, err = ref.doGetRaw(key, fn)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (ref *DBRef[K, V]) doGetRaw(key *K, fn func(valBytes []byte) error) (ok bool, err error) {
+	var keyBytes []byte
+	var n int
+	t := newOpTimer()
+	defer func() { ref.ownerDB.finishOp(ref.id, opGet, &t, len(keyBytes), n, err) }()
+
+	// Encode the key.
+	keyBytes, err = ref.encodeKey(key)
+	t.mark(&t.encode)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	err = ref.checkSizes(len(keyBytes), 0)
+	if err != nil {
+		return false, err
+	}
+
+	absent, err := ref.absent(keyBytes)
+	if err != nil || absent {
+		return false, err
+	}
+
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		// Get the value.
+		valBytes, err := txn.Get(dbRef, keyBytes)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get key: %w", err)
+		}
+
+		if ref.options.ttl != nil {
+			expired, err := ref.expiredInTxn(txn, keyBytes, time.Now())
+			if err != nil || expired {
+				return err
+			}
+		}
+		valBytes, err = ref.loadChunksInTxn(txn, valBytes)
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		n = len(valBytes)
+		// fn decodes the value, so time it as the decode phase.
+		t.mark(&t.txn)
+		err = fn(valBytes)
+		t.mark(&t.decode)
+		return err
+	})
+	t.mark(&t.txn)
+	if err != nil {
+		return false, err
+	}
+
+	if ok && ref.options.touchesOnRead() && !ref.ownerDB.readOnly() {
+		err = ref.touch(keyBytes)
+		if err != nil {
+			return false, fmt.Errorf("failed to record access: %w", err)
+		}
+	}
+
+	return ok, nil
+}
+
+// GetSize returns the size in bytes of the value stored under key, as stored
+// (i.e. encoded and, if enabled, compressed), without decoding it. A missing
+// key is reported as an error matching ErrNotFound.
+func (ref *DBRef[K, V]) GetSize(key *K) (size int, err error) {
+	ok, err := ref.getRaw(key, func(valBytes []byte) error {
+		size = len(valBytes)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return size, nil
+}
+
+// GetRaw calls fn with the value stored under key as stored (i.e. encoded
+// and, if enabled, compressed and checksummed), without copying or decoding
+// it, for code that only hashes, forwards or proxies values. fn runs inside
+// the read transaction and val points into LMDB's memory map: fn must not
+// modify it, and must copy whatever it keeps after returning. An error from
+// fn is returned as is. A missing key is reported as an error matching
+// ErrNotFound.
+func (ref *DBRef[K, V]) GetRaw(key *K, fn func(val []byte) error) error {
+	ok, err := ref.getRaw(key, fn)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// Drop deletes the sub-database backing ref and frees its pages, along with
+// the named databases of its indexes, history, TTLs and other bookkeeping,
+// its Bloom filter and its key prefix dictionary. Afterwards ref can no
+// longer be used; create a new one with NewRef to start over. Drop on a
+// namespace made by Sub only deletes the entries of the namespace.
+func (ref *DBRef[K, V]) Drop() (err error) {
+	if len(ref.prefix) > 0 {
+		return ref.dropNamespace()
+	}
+
+	err = ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		err = txn.Drop(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to drop db ref: %w", err)
+		}
+
+		for _, companion := range ref.companionDBs() {
+			err = dropIfExistsInTxn(txn, companion.name, companion.flags)
+			if err != nil {
+				return err
+			}
+		}
+
+		quotaRef, err := txn.DBRef(quotasDB, dbFlag(0))
+		if err == nil {
+			err = txn.Delete(quotaRef, []byte(ref.id), nil)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete usage: %w", err)
+		}
+
+		err = ref.ownerDB.dropBloomInTxn(txn, ref.id)
+		if err != nil {
+			return err
+		}
+
+		return dropKeyPrefixesInTxn(txn, ref.id)
+	})
+	if err != nil {
+		return err
+	}
+	ref.ownerDB.readCache.clear()
+
+	return nil
+}
+
+// companionDB is a named database a DBRef keeps bookkeeping in.
+type companionDB struct {
+	name  string
+	flags dbFlag
+}
+
+// companionDBs returns the named databases ref may keep bookkeeping in,
+// whether or not its options use them, since a DBRef of the same name opened
+// earlier may have.
+func (ref *DBRef[K, V]) companionDBs() []companionDB {
+	dbs := []companionDB{
+		{ref.chunksDBName(), 0},
+		{ref.historyDBName(), 0},
+		{ref.deletedDBName(), 0},
+		{ref.lruDBName(), 0},
+		{ref.ttlDBName(), 0},
+	}
+	for _, idx := range ref.indexes {
+		dbs = append(dbs, companionDB{idx.indexDBName(), dbDupSort})
+	}
+
+	return dbs
+}
+
+// dropIfExistsInTxn drops the named database name, if it exists.
+func dropIfExistsInTxn(txn writeTxn, name string, flags dbFlag) error {
+	dbRef, err := txn.DBRef(name, flags)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	err = txn.Drop(dbRef)
+	if err != nil {
+		return fmt.Errorf("failed to drop %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// dropNamespace deletes every entry of the namespace ref, and its
+// bookkeeping, in one write transaction.
+func (ref *DBRef[K, V]) dropNamespace() error {
+	err := ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		var keys [][]byte
+		err = scanPrefix(txn, dbRef, ref.prefix, func(key []byte) error {
+			keys = append(keys, bytes.Clone(key))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			err = ref.deleteInTxn(txn, dbRef, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	ref.ownerDB.readCache.clear()
 
 	return nil
 }
@@ -0,0 +1,401 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestInitRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	err := os.WriteFile(blocker, nil, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ezdb.New(filepath.Join(blocker, "db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Init()
+	if err == nil {
+		t.Fatal("Init succeeded under a regular file")
+	}
+	_, err = ezdb.NewRef[string, string]("ref", db)
+	if err == nil {
+		t.Fatal("NewRef succeeded on an uninitialized Client")
+	}
+
+	err = os.Remove(blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init after fixing the directory: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("second Init: %v", err)
+	}
+
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+func TestCloseDrainsInFlightOperations(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	scanned := make(chan error, 1)
+	go func() {
+		scanned <- ref.ForEach(func(key *string, val *string) error {
+			close(entered)
+			<-release
+			return nil
+		})
+	}()
+	<-entered
+
+	closed := make(chan error, 1)
+	go func() { closed <- db.Close() }()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v before the scan finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	err = <-scanned
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	err = <-closed
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Get after Close returned %v, want ErrClosed", err)
+	}
+	err = db.Close()
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("second Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestReopenKeepsDBRefs(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = db.Init()
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Init after Close returned %v, want ErrClosed", err)
+	}
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen of an open Client: %v", err)
+	}
+
+	got, err := ref.Get(&key)
+	if err != nil {
+		t.Fatalf("Get after Reopen: %v", err)
+	}
+	if *got != val {
+		t.Fatalf("Get after Reopen = %q, want %q", *got, val)
+	}
+}
+
+func TestPing(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	err := db.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.Ping(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ping with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = db.Ping(context.Background())
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Ping after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	db, err := ezdb.New(dir, ezdb.WithNumDBs(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, opts := range [][]ezdb.Option{
+		{ezdb.WithReadOnly()},
+		{ezdb.WithReadOnly(), ezdb.WithNoLock()},
+	} {
+		ro, err := ezdb.New(dir, append(opts, ezdb.WithNumDBs(16))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ro.Init()
+		if err != nil {
+			t.Fatalf("Init read-only: %v", err)
+		}
+		ref, err = ezdb.NewRef[string, string]("ref", ro)
+		if err != nil {
+			t.Fatalf("NewRef read-only: %v", err)
+		}
+		got, err := ref.Get(&key)
+		if err != nil || *got != val {
+			t.Fatalf("Get read-only = %v, %v", got, err)
+		}
+		err = ref.Put(&key, &val)
+		if err == nil {
+			t.Fatal("Put on a read-only Client succeeded")
+		}
+		_, err = ezdb.NewRef[string, string]("missing", ro)
+		if err == nil {
+			t.Fatal("NewRef of a missing database on a read-only Client succeeded")
+		}
+		err = ro.Close()
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	ro, err := ezdb.New(filepath.Join(dir, "missing"), ezdb.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ro.Init()
+	if err == nil {
+		ro.Close()
+		t.Fatal("Init of a missing directory read-only succeeded")
+	}
+}
+
+func TestTryGet(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	got, ok, err := ref.TryGet(&key)
+	if err != nil || ok || got != nil {
+		t.Fatalf("TryGet of a missing key = %v, %v, %v", got, ok, err)
+	}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, ok, err = ref.TryGet(&key)
+	if err != nil || !ok || *got != val {
+		t.Fatalf("TryGet = %v, %v, %v", got, ok, err)
+	}
+
+	long := strings.Repeat("k", 1000)
+	_, _, err = ref.TryGet(&long)
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("TryGet of a long key returned %v, want ErrKeyTooLarge", err)
+	}
+}
+
+func TestGetSize(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithCodec(ezdb.BytesCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "k", make([]byte, 1234)
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	size, err := ref.GetSize(&key)
+	if err != nil || size != 1234 {
+		t.Fatalf("GetSize = %d, %v, want 1234", size, err)
+	}
+
+	missing := "missing"
+	_, err = ref.GetSize(&missing)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("GetSize of a missing key returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, user](db, "ref", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var raw []byte
+	err = ref.GetRaw(&key, func(val []byte) error {
+		raw = append(raw, val...)
+		return nil
+	})
+	want, _ := json.Marshal(val)
+	if err != nil || string(raw) != string(want) {
+		t.Fatalf("GetRaw = %q, %v, want %q", raw, err, want)
+	}
+
+	// Errors of fn are returned as they are.
+	errStop := errors.New("stop")
+	err = ref.GetRaw(&key, func([]byte) error { return errStop })
+	if err != errStop {
+		t.Fatalf("GetRaw returned %v, want the error of fn", err)
+	}
+
+	missing := "missing"
+	err = ref.GetRaw(&missing, func([]byte) error {
+		t.Fatal("GetRaw called fn for a missing key")
+		return nil
+	})
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("GetRaw of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	// Values are passed as stored, compressed if compression is enabled.
+	compressed, err := ezdb.NewDBRef[string, user](db, "compressed", ezdb.WithCodec(ezdb.JSONCodec{}), ezdb.WithCompression(flate.BestCompression))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = compressed.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = compressed.GetRaw(&key, func(val []byte) error {
+		raw, err = io.ReadAll(flate.NewReader(bytes.NewReader(val)))
+		return err
+	})
+	if err != nil || string(raw) != string(want) {
+		t.Fatalf("GetRaw of a compressed value inflates to %q, %v", raw, err)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	errEmpty := errors.New("empty value")
+	var order []string
+	ref, err := ezdb.NewDBRef[string, string](db, "ref",
+		ezdb.WithValidator(func(key, val *string) error {
+			order = append(order, "first")
+			if *val == "" {
+				return errEmpty
+			}
+			return nil
+		}),
+		ezdb.WithValidator(func(key, val *string) error {
+			order = append(order, "second")
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put of a valid value: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "first,second" {
+		t.Fatalf("validators ran in order %s", got)
+	}
+
+	empty := ""
+	err = ref.Put(&key, &empty)
+	if !errors.Is(err, errEmpty) {
+		t.Fatalf("Put of an invalid value returned %v, want the validator's error", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get after a rejected Put = %v, %v", got, err)
+	}
+
+	_, err = ezdb.NewDBRef[string, string](db, "other", ezdb.WithValidator(func(key *string, val *int) error { return nil }))
+	if err == nil {
+		t.Fatal("NewDBRef with a validator of other types succeeded")
+	}
+}
@@ -0,0 +1,6 @@
+// Package ezdbpb holds the gRPC services of package server and of
+// replication, see ezdb.Client.ReplicationServer, generated from the .proto
+// files in this directory.
+package ezdbpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative kv.proto replication.proto
@@ -0,0 +1,206 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: replication.proto
+
+package ezdbpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Replication_Snapshot_FullMethodName = "/ezdb.v1.Replication/Snapshot"
+	Replication_Changes_FullMethodName  = "/ezdb.v1.Replication/Changes"
+)
+
+// ReplicationClient is the client API for Replication service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReplicationClient interface {
+	// Snapshot streams a dump of the environment, see ezdb.Client.Dump.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (Replication_SnapshotClient, error)
+	// Changes streams the changes recorded in the audit log after the record
+	// after, in batches, and an empty batch every second if there are none.
+	Changes(ctx context.Context, in *ChangesRequest, opts ...grpc.CallOption) (Replication_ChangesClient, error)
+}
+
+type replicationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplicationClient(cc grpc.ClientConnInterface) ReplicationClient {
+	return &replicationClient{cc}
+}
+
+func (c *replicationClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (Replication_SnapshotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Replication_ServiceDesc.Streams[0], Replication_Snapshot_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicationSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Replication_SnapshotClient interface {
+	Recv() (*SnapshotChunk, error)
+	grpc.ClientStream
+}
+
+type replicationSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicationSnapshotClient) Recv() (*SnapshotChunk, error) {
+	m := new(SnapshotChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *replicationClient) Changes(ctx context.Context, in *ChangesRequest, opts ...grpc.CallOption) (Replication_ChangesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Replication_ServiceDesc.Streams[1], Replication_Changes_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicationChangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Replication_ChangesClient interface {
+	Recv() (*ChangeBatch, error)
+	grpc.ClientStream
+}
+
+type replicationChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicationChangesClient) Recv() (*ChangeBatch, error) {
+	m := new(ChangeBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReplicationServer is the server API for Replication service.
+// All implementations must embed UnimplementedReplicationServer
+// for forward compatibility
+type ReplicationServer interface {
+	// Snapshot streams a dump of the environment, see ezdb.Client.Dump.
+	Snapshot(*SnapshotRequest, Replication_SnapshotServer) error
+	// Changes streams the changes recorded in the audit log after the record
+	// after, in batches, and an empty batch every second if there are none.
+	Changes(*ChangesRequest, Replication_ChangesServer) error
+	mustEmbedUnimplementedReplicationServer()
+}
+
+// UnimplementedReplicationServer must be embedded to have forward compatible implementations.
+type UnimplementedReplicationServer struct {
+}
+
+func (UnimplementedReplicationServer) Snapshot(*SnapshotRequest, Replication_SnapshotServer) error {
+	return status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedReplicationServer) Changes(*ChangesRequest, Replication_ChangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method Changes not implemented")
+}
+func (UnimplementedReplicationServer) mustEmbedUnimplementedReplicationServer() {}
+
+// UnsafeReplicationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplicationServer will
+// result in compilation errors.
+type UnsafeReplicationServer interface {
+	mustEmbedUnimplementedReplicationServer()
+}
+
+func RegisterReplicationServer(s grpc.ServiceRegistrar, srv ReplicationServer) {
+	s.RegisterService(&Replication_ServiceDesc, srv)
+}
+
+func _Replication_Snapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServer).Snapshot(m, &replicationSnapshotServer{stream})
+}
+
+type Replication_SnapshotServer interface {
+	Send(*SnapshotChunk) error
+	grpc.ServerStream
+}
+
+type replicationSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicationSnapshotServer) Send(m *SnapshotChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Replication_Changes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServer).Changes(m, &replicationChangesServer{stream})
+}
+
+type Replication_ChangesServer interface {
+	Send(*ChangeBatch) error
+	grpc.ServerStream
+}
+
+type replicationChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicationChangesServer) Send(m *ChangeBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Replication_ServiceDesc is the grpc.ServiceDesc for Replication service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Replication_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ezdb.v1.Replication",
+	HandlerType: (*ReplicationServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Snapshot",
+			Handler:       _Replication_Snapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Changes",
+			Handler:       _Replication_Changes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "replication.proto",
+}
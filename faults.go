@@ -0,0 +1,229 @@
+package ezdb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FaultOp is a kind of storage operation a Fault can hit.
+type FaultOp string
+
+const (
+	// FaultRead is the start of a read transaction.
+	FaultRead FaultOp = "read"
+	// FaultWrite is the commit of a write transaction.
+	FaultWrite FaultOp = "write"
+)
+
+// Fault is a storage failure injected by Faults.
+type Fault struct {
+	Op FaultOp
+	// At is the number of the operation, counting operations of kind Op
+	// from 1, that the fault hits. If Every is positive, it hits every
+	// Every-th operation after that as well.
+	At, Every int
+	// Delay is added before the operation, for latency spikes.
+	Delay time.Duration
+	// Err is the error the operation fails with, such as ErrMapFull or
+	// ErrTxnTooBig, or nil for a delay only. A write fails after its
+	// transaction function ran, and its writes are discarded.
+	Err error
+	// Torn, for a write with Err, commits the first Keep writes of the
+	// transaction and drops the rest before failing with Err, as a batch cut
+	// short by a crash would. The writes include the ones ezdb makes itself,
+	// for indexes and other bookkeeping.
+	Torn bool
+	Keep int
+}
+
+// hits reports whether f hits the nth operation of its kind.
+func (f Fault) hits(n int) bool {
+	if n == f.At {
+		return true
+	}
+
+	return f.Every > 0 && n > f.At && (n-f.At)%f.Every == 0
+}
+
+// Faults injects storage failures into Clients created with WithFaults, so
+// that tests can check how an application copes with them. Operations are
+// counted from the creation of the Faults, across every Client using it. It
+// is safe for concurrent use, and faults can be added while Clients run.
+type Faults struct {
+	mu       sync.Mutex
+	faults   []Fault
+	counts   map[FaultOp]int
+	injected int
+}
+
+// NewFaults returns Faults injecting faults.
+func NewFaults(faults ...Fault) *Faults {
+	return &Faults{faults: faults, counts: make(map[FaultOp]int)}
+}
+
+// WithFaults injects the failures of faults into every transaction of the
+// Client, whatever its storage engine.
+func WithFaults(faults *Faults) Option {
+	return func(option *options) error {
+		option.faults = faults
+		return nil
+	}
+}
+
+// Add adds fault.
+func (f *Faults) Add(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = append(f.faults, fault)
+}
+
+// Clear removes all faults. The operations counted so far stay counted.
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = nil
+}
+
+// Count returns the number of operations of kind op so far.
+func (f *Faults) Count(op FaultOp) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.counts[op]
+}
+
+// Injected returns the number of operations that a fault has hit.
+func (f *Faults) Injected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.injected
+}
+
+// next counts an operation of kind op and returns the faults hitting it,
+// merged into one: their delays add up, and the first with an error decides
+// how the operation fails.
+func (f *Faults) next(op FaultOp) (fault Fault, hit bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.counts[op]++
+	n := f.counts[op]
+	for _, candidate := range f.faults {
+		if candidate.Op != op || !candidate.hits(n) {
+			continue
+		}
+
+		hit = true
+		fault.Delay += candidate.Delay
+		if fault.Err == nil && candidate.Err != nil {
+			fault.Err, fault.Torn, fault.Keep = candidate.Err, candidate.Torn, candidate.Keep
+		}
+	}
+	if hit {
+		f.injected++
+	}
+
+	return fault, hit
+}
+
+// faultBackend injects the failures of faults into backend.
+type faultBackend struct {
+	backend
+	faults *Faults
+}
+
+func (b *faultBackend) View(fn func(txn readTxn) error) error {
+	fault, hit := b.faults.next(FaultRead)
+	if hit {
+		time.Sleep(fault.Delay)
+		if fault.Err != nil {
+			return fmt.Errorf("injected fault: %w", fault.Err)
+		}
+	}
+
+	return b.backend.View(fn)
+}
+
+func (b *faultBackend) Update(fn func(txn writeTxn) error) error {
+	fault, hit := b.faults.next(FaultWrite)
+	if !hit {
+		return b.backend.Update(fn)
+	}
+
+	time.Sleep(fault.Delay)
+	if fault.Err == nil {
+		return b.backend.Update(fn)
+	}
+	injected := fmt.Errorf("injected fault: %w", fault.Err)
+
+	if fault.Torn {
+		err := b.backend.Update(func(txn writeTxn) error {
+			return fn(&tornTxn{writeTxn: txn, keep: fault.Keep})
+		})
+		if err != nil {
+			return err
+		}
+		return injected
+	}
+
+	// Returning the error from within the transaction aborts it.
+	return b.backend.Update(func(txn writeTxn) error {
+		err := fn(txn)
+		if err != nil {
+			return err
+		}
+		return injected
+	})
+}
+
+// tornTxn is a write transaction that drops its writes after the first keep.
+type tornTxn struct {
+	writeTxn
+	keep int
+}
+
+// write reports whether the next write is kept.
+func (t *tornTxn) write() bool {
+	if t.keep <= 0 {
+		return false
+	}
+
+	t.keep--
+	return true
+}
+
+func (t *tornTxn) Put(db dbi, key, val []byte, flags putFlag) error {
+	if !t.write() {
+		return nil
+	}
+
+	return t.writeTxn.Put(db, key, val, flags)
+}
+
+func (t *tornTxn) Delete(db dbi, key, val []byte) error {
+	if !t.write() {
+		return nil
+	}
+
+	return t.writeTxn.Delete(db, key, val)
+}
+
+func (t *tornTxn) Empty(db dbi) error {
+	if !t.write() {
+		return nil
+	}
+
+	return t.writeTxn.Empty(db)
+}
+
+func (t *tornTxn) Drop(db dbi) error {
+	if !t.write() {
+		return nil
+	}
+
+	return t.writeTxn.Drop(db)
+}
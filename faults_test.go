@@ -0,0 +1,128 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestFaults(t *testing.T) {
+	for _, engine := range []struct {
+		name string
+		open func(t testing.TB, opts ...ezdb.Option) *ezdb.Client
+	}{
+		{"lmdb", testutil.NewTempClient},
+		{"memory", testutil.NewMemoryClient},
+	} {
+		t.Run(engine.name, func(t *testing.T) {
+			faults := ezdb.NewFaults()
+			db := engine.open(t, ezdb.WithFaults(faults))
+			ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			putN(t, ref, 2)
+			key := "k0000"
+
+			// Reads fail from the At-th on, every Every-th time.
+			reads := faults.Count(ezdb.FaultRead)
+			faults.Add(ezdb.Fault{Op: ezdb.FaultRead, At: reads + 2, Every: 2, Err: ezdb.ErrTxnTooBig})
+			var failed []int
+			for i := 1; i <= 6; i++ {
+				_, err := ref.Get(&key)
+				if errors.Is(err, ezdb.ErrTxnTooBig) {
+					failed = append(failed, i)
+				} else if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+			}
+			if fmt.Sprint(failed) != "[2 4 6]" || faults.Injected() != 3 {
+				t.Fatalf("reads %v failed, %d injected", failed, faults.Injected())
+			}
+			if n := faults.Count(ezdb.FaultRead); n != reads+6 {
+				t.Fatalf("Count = %d, want %d", n, reads+6)
+			}
+
+			// A failing write discards its writes.
+			faults.Clear()
+			faults.Add(ezdb.Fault{Op: ezdb.FaultWrite, At: faults.Count(ezdb.FaultWrite) + 1, Err: ezdb.ErrMapFull})
+			val := "changed"
+			err = ref.Put(&key, &val)
+			if !errors.Is(err, ezdb.ErrMapFull) {
+				t.Fatalf("Put returned %v, want ErrMapFull", err)
+			}
+			got, err := ref.Get(&key)
+			if err != nil || *got == "changed" {
+				t.Fatalf("Get after a failed Put = %v, %v", got, err)
+			}
+			err = ref.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put after the fault: %v", err)
+			}
+
+			// Delays hold operations up without failing them.
+			faults.Add(ezdb.Fault{Op: ezdb.FaultRead, At: faults.Count(ezdb.FaultRead) + 1, Delay: 20 * time.Millisecond})
+			start := time.Now()
+			_, err = ref.Get(&key)
+			if err != nil || time.Since(start) < 20*time.Millisecond {
+				t.Fatalf("delayed Get took %v: %v", time.Since(start), err)
+			}
+		})
+	}
+}
+
+func TestTornWrite(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Only the first two of the three writes are committed.
+	faults.Add(ezdb.Fault{Op: ezdb.FaultWrite, At: faults.Count(ezdb.FaultWrite) + 1, Err: ezdb.ErrMapFull, Torn: true, Keep: 2})
+	err = db.Update(func(tx *ezdb.Tx) error {
+		for _, key := range []string{"a", "b", "c"} {
+			err := ref.PutTx(tx, &key, &key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, ezdb.ErrMapFull) {
+		t.Fatalf("Update returned %v, want ErrMapFull", err)
+	}
+	var keys []string
+	err = ref.ForEach(func(key, val *string) error {
+		keys = append(keys, *key)
+		return nil
+	})
+	if err != nil || fmt.Sprint(keys) != "[a b]" {
+		t.Fatalf("torn write left %v: %v", keys, err)
+	}
+
+	// Errors of the transaction function win over the injected one.
+	errAbort := errors.New("abort")
+	faults.Add(ezdb.Fault{Op: ezdb.FaultWrite, At: faults.Count(ezdb.FaultWrite) + 1, Err: ezdb.ErrMapFull, Torn: true, Keep: 1})
+	err = db.Update(func(tx *ezdb.Tx) error {
+		key := "d"
+		err := ref.PutTx(tx, &key, &key)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want the error of fn", err)
+	}
+	key := "d"
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet after an aborted torn write = %t, %v", ok, err)
+	}
+}
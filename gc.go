@@ -0,0 +1,277 @@
+package ezdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// gcBatch is about how many records GC reads per transaction.
+const gcBatch = 1024
+
+type gcOptions struct {
+	interval  time.Duration
+	retention time.Duration
+}
+
+// WithGC configures GC of the DBRef: tombstones of SoftDelete older than
+// retention, and the history of keys deleted longer than retention ago, are
+// removed along with the records GC always removes. A positive interval also
+// runs GC in the background every interval. Without WithGC, GC keeps the
+// tombstones and the history of deleted keys.
+func WithGC(interval, retention time.Duration) RefOption {
+	return func(option *refOptions) error {
+		if interval < 0 {
+			return errors.New("GC interval must not be negative")
+		}
+		if retention < 0 {
+			return errors.New("GC retention must not be negative")
+		}
+		option.gc = &gcOptions{interval: interval, retention: retention}
+		return nil
+	}
+}
+
+// GC removes the records of ref that are no longer needed: expired entries,
+// see WithTTL, versions beyond the history length, see WithHistory, and the
+// tombstones and history WithGC lets go. It works in transactions of about a
+// thousand records, waiting as long as each took before the next, so that it
+// keeps the file from growing without holding up other writes, and returns
+// how many records it removed. The pages freed are reused by later writes;
+// CompactTo writes a smaller copy. If ctx is done, GC stops after the current
+// transaction and returns the error of ctx.
+func (ref *DBRef[K, V]) GC(ctx context.Context) (n int, err error) {
+	if ref.options.ttl != nil {
+		for {
+			start := time.Now()
+			swept, err := ref.sweepOnce(start)
+			n += swept
+			if err != nil {
+				return n, err
+			}
+			if swept < int(ref.options.ttl.sweepBatch) {
+				break
+			}
+			err = gcPause(ctx, time.Since(start))
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	var retention *time.Duration
+	if ref.options.gc != nil {
+		retention = &ref.options.gc.retention
+	}
+	if ref.options.softDelete && retention != nil {
+		cutoff := time.Now().Add(-*retention)
+		removed, err := ref.gcBatches(ctx, func(txn writeTxn, from []byte) ([]byte, int, error) {
+			return ref.gcTombstonesInTxn(txn, from, cutoff)
+		})
+		n += removed
+		if err != nil {
+			return n, err
+		}
+	}
+	if ref.options.history != nil {
+		var cutoff time.Time
+		if retention != nil {
+			cutoff = time.Now().Add(-*retention)
+		}
+		removed, err := ref.gcBatches(ctx, func(txn writeTxn, from []byte) ([]byte, int, error) {
+			return ref.gcHistoryInTxn(txn, from, cutoff)
+		})
+		n += removed
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// gcBatches calls fn in write transactions of their own, each with the key
+// to continue at that the previous call returned, starting at nil, until fn
+// returns none, and returns how many records the calls removed.
+func (ref *DBRef[K, V]) gcBatches(ctx context.Context, fn func(txn writeTxn, from []byte) (next []byte, removed int, err error)) (n int, err error) {
+	var from []byte
+	for {
+		start := time.Now()
+		var removed int
+		err = ref.ownerDB.update(func(txn writeTxn) error {
+			var err error
+			from, removed, err = fn(txn, from)
+			return err
+		})
+		if err != nil {
+			return n, err
+		}
+		n += removed
+		if from == nil {
+			return n, nil
+		}
+
+		err = gcPause(ctx, time.Since(start))
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// gcPause waits for d, or until ctx is done.
+func gcPause(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// gcTombstonesInTxn removes the tombstones older than cutoff among the next
+// batch of them, from the key from on, and returns the key of the batch after
+// it, if any.
+func (ref *DBRef[K, V]) gcTombstonesInTxn(txn writeTxn, from []byte, cutoff time.Time) (next []byte, removed int, err error) {
+	deletedRef, err := txn.DBRef(ref.deletedDBName(), dbFlag(0))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	cursor, err := txn.NewCursor(deletedRef)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open cursor: %w", err)
+	}
+
+	var stale [][]byte
+	var keyBytes, tombstone []byte
+	if from == nil {
+		keyBytes, tombstone, err = cursor.First()
+	} else {
+		keyBytes, tombstone, err = cursor.SeekGreaterThanOrEqualKey(from)
+	}
+	for seen := 0; err == nil; keyBytes, tombstone, err = cursor.Next() {
+		if seen == gcBatch {
+			next = bytes.Clone(keyBytes)
+			break
+		}
+		seen++
+		if len(tombstone) >= 8 && int64(binary.BigEndian.Uint64(tombstone)) < cutoff.UnixNano() {
+			stale = append(stale, bytes.Clone(keyBytes))
+		}
+	}
+	cursor.Close()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, 0, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+
+	for _, keyBytes := range stale {
+		err = txn.Delete(deletedRef, keyBytes, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to delete tombstone: %w", err)
+		}
+	}
+
+	return next, len(stale), nil
+}
+
+// gcHistoryInTxn removes, among the versions of the next batch of keys from
+// the history key from on, those beyond the history length, and all of those
+// of keys whose last version is a deletion older than cutoff, if set. It
+// returns the history key of the batch after it, if any.
+func (ref *DBRef[K, V]) gcHistoryInTxn(txn writeTxn, from []byte, cutoff time.Time) (next []byte, removed int, err error) {
+	histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	cursor, err := txn.NewCursor(histRef)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open cursor: %w", err)
+	}
+
+	var stale, versions [][]byte
+	var deletedAt int64
+	// done checks the versions of the key before the next one.
+	done := func() {
+		drop := len(versions) - int(*ref.options.history)
+		if !cutoff.IsZero() && deletedAt != 0 && deletedAt < cutoff.UnixNano() {
+			drop = len(versions)
+		}
+		if drop > 0 {
+			stale = append(stale, versions[:drop]...)
+		}
+		versions = nil
+	}
+
+	var histKey, data []byte
+	if from == nil {
+		histKey, data, err = cursor.First()
+	} else {
+		histKey, data, err = cursor.SeekGreaterThanOrEqualKey(from)
+	}
+	for seen := 0; err == nil; histKey, data, err = cursor.Next() {
+		if bytes.Equal(histKey, historyRevKey) || len(histKey) < 8 {
+			continue
+		}
+		if len(versions) > 0 && !bytes.Equal(histKey[:len(histKey)-8], versions[0][:len(versions[0])-8]) {
+			done()
+			if seen >= gcBatch {
+				next = bytes.Clone(histKey)
+				break
+			}
+		}
+		seen++
+		versions = append(versions, bytes.Clone(histKey))
+		deletedAt = 0
+		if len(data) >= 9 && data[0] == historyDeleted {
+			deletedAt = int64(binary.BigEndian.Uint64(data[1:]))
+		}
+	}
+	cursor.Close()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, 0, fmt.Errorf("failed to read versions: %w", err)
+	}
+	done()
+
+	for _, histKey := range stale {
+		err = txn.Delete(histRef, histKey, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to delete version: %w", err)
+		}
+	}
+
+	return next, len(stale), nil
+}
+
+// gcLoop runs GC every GC interval until stop is closed.
+func (ref *DBRef[K, V]) gcLoop(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	ticker := time.NewTicker(ref.options.gc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		_, err := ref.GC(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			ref.ownerDB.options.log.Error().Err(err).Str("db", ref.id).Msg("failed to collect garbage")
+		}
+	}
+}
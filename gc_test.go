@@ -0,0 +1,188 @@
+package ezdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestGC(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}),
+		ezdb.WithTTL(0, 2), ezdb.WithSoftDelete(), ezdb.WithHistory(5), ezdb.WithGC(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// 5 expired entries, swept 2 per transaction, and one that isn't.
+	val := "v"
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("ttl%d", i)
+		ttl := time.Millisecond
+		if i == 5 {
+			ttl = time.Hour
+		}
+		err = ref.PutTTL(&key, &val, ttl)
+		if err != nil {
+			t.Fatalf("PutTTL: %v", err)
+		}
+	}
+	// a has 5 versions, and b is deleted with 2 versions and a tombstone.
+	a, b := "a", "b"
+	for i := 0; i < 5; i++ {
+		err = ref.Put(&a, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	err = ref.Put(&b, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = ref.SoftDelete(&b)
+	if err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Reopened with a shorter history, 3 versions of a are beyond it.
+	ref, err = ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}),
+		ezdb.WithTTL(0, 2), ezdb.WithSoftDelete(), ezdb.WithHistory(2), ezdb.WithGC(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	n, err := ref.GC(context.Background())
+	// 5 expired entries and their 2 versions each, the expiry being a
+	// deletion, 3 versions of a, and the tombstone and 2 versions of b.
+	if err != nil || n != 5+10+3+1+2 {
+		t.Fatalf("GC = %d, %v, want %d", n, err, 5+10+3+1+2)
+	}
+	if !has(t, ref, "ttl5") || !has(t, ref, "a") {
+		t.Fatal("GC removed live entries")
+	}
+	vs, err := ref.History(&a)
+	if err != nil || versions(vs) != "10=v 11=v" {
+		t.Fatalf("History(a) = %s, %v", versions(vs), err)
+	}
+	vs, err = ref.History(&b)
+	if err != nil || len(vs) != 0 {
+		t.Fatalf("History(b) = %s, %v", versions(vs), err)
+	}
+	deleted, err := ref.ListDeleted()
+	if err != nil || len(deleted) != 0 {
+		t.Fatalf("ListDeleted = %v, %v", deleted, err)
+	}
+
+	n, err = ref.GC(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("GC with nothing to remove = %d, %v", n, err)
+	}
+}
+
+func TestGCRetention(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	for _, test := range []struct {
+		name string
+		opts []ezdb.RefOption
+	}{
+		{"without", nil},
+		{"retention", []ezdb.RefOption{ezdb.WithGC(0, time.Hour)}},
+	} {
+		opts := append([]ezdb.RefOption{ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithSoftDelete(), ezdb.WithHistory(2)}, test.opts...)
+		ref, err := ezdb.NewDBRef[string, string](db, test.name, opts...)
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		key, val := "a", "v"
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		err = ref.SoftDelete(&key)
+		if err != nil {
+			t.Fatalf("SoftDelete: %v", err)
+		}
+
+		// Tombstones and the history of deleted keys are kept.
+		n, err := ref.GC(context.Background())
+		if err != nil || n != 0 {
+			t.Fatalf("%s: GC = %d, %v, want 0", test.name, n, err)
+		}
+		deleted, err := ref.ListDeleted()
+		if err != nil || len(deleted) != 1 {
+			t.Fatalf("%s: ListDeleted = %v, %v", test.name, deleted, err)
+		}
+		vs, err := ref.History(&key)
+		if err != nil || len(vs) != 2 {
+			t.Fatalf("%s: History = %s, %v", test.name, versions(vs), err)
+		}
+	}
+}
+
+func TestGCCancel(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithSoftDelete(), ezdb.WithGC(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 1500)
+	for i := 0; i < 1500; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		err = ref.SoftDelete(&key)
+		if err != nil {
+			t.Fatalf("SoftDelete: %v", err)
+		}
+	}
+
+	// GC stops after the first transaction.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := ref.GC(ctx)
+	if !errors.Is(err, context.Canceled) || n != 1024 {
+		t.Fatalf("GC with a canceled context = %d, %v", n, err)
+	}
+	n, err = ref.GC(context.Background())
+	if err != nil || n != 1500-1024 {
+		t.Fatalf("GC = %d, %v, want %d", n, err, 1500-1024)
+	}
+}
+
+func TestGCInterval(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithSoftDelete(), ezdb.WithGC(time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 1)
+	key := "k0000"
+	err = ref.SoftDelete(&key)
+	if err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		deleted, err := ref.ListDeleted()
+		if err != nil {
+			t.Fatalf("ListDeleted: %v", err)
+		}
+		if len(deleted) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the tombstone wasn't collected")
+		}
+	}
+}
+
+func TestWithGCErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	for _, opt := range []ezdb.RefOption{ezdb.WithGC(-time.Second, 0), ezdb.WithGC(0, -time.Second)} {
+		_, err := ezdb.NewDBRef[string, string](db, "ref", opt)
+		if err == nil {
+			t.Error("NewDBRef with a negative GC duration succeeded")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GetManyParallel returns the values stored under keys, in the same order,
+// with nil for keys that have none. The keys are split into up to
+// concurrency groups, each looked up and decoded by a goroutine of its own in
+// one read transaction, so resolving hundreds of keys takes a few
+// transactions instead of one per key, and decoding them several cores.
+// concurrency is capped at the number of readers the database was opened
+// with, see WithNumReaders. Like Join, it reads the database directly, so
+// interceptors and the read cache don't see the lookups.
+func (ref *DBRef[K, V]) GetManyParallel(keys []*K, concurrency int) ([]*V, error) {
+	if concurrency <= 0 {
+		return nil, errors.New("concurrency must be positive")
+	}
+	if readers := int(*ref.ownerDB.options.numReaders); readers > 0 && concurrency > readers {
+		concurrency = readers
+	}
+	if concurrency > len(keys) {
+		concurrency = len(keys)
+	}
+
+	vals := make([]*V, len(keys))
+	errs := make([]error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		lo, hi := i*len(keys)/concurrency, (i+1)*len(keys)/concurrency
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ref.getManyInto(keys[lo:hi], vals[lo:hi])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vals, nil
+}
+
+// getManyInto looks up keys in one read transaction and stores their values
+// in vals.
+func (ref *DBRef[K, V]) getManyInto(keys []*K, vals []*V) error {
+	keysBytes := make([][]byte, len(keys))
+	for i, key := range keys {
+		var err error
+		keysBytes[i], err = ref.encodeKey(key)
+		if err != nil {
+			return fmt.Errorf("failed to encode key: %w", err)
+		}
+		err = ref.checkSizes(len(keysBytes[i]), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		now := time.Now()
+		for i, keyBytes := range keysBytes {
+			absent, err := ref.absent(keyBytes)
+			if err != nil {
+				return err
+			}
+			if absent {
+				continue
+			}
+
+			if ref.options.ttl != nil {
+				expired, err := ref.expiredInTxn(txn, keyBytes, now)
+				if err != nil {
+					return err
+				}
+				if expired {
+					continue
+				}
+			}
+
+			vals[i], err = ref.getInTxn(txn, dbRef, keyBytes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ref.options.touchesOnRead() && !ref.ownerDB.readOnly() {
+		for i, keyBytes := range keysBytes {
+			if vals[i] == nil {
+				continue
+			}
+			err = ref.touch(keyBytes)
+			if err != nil {
+				return fmt.Errorf("failed to record access: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
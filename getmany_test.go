@@ -0,0 +1,105 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestGetManyParallel(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			ref, err := ezdb.NewDBRef[string, string](e.open(t), "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			putN(t, ref, 100)
+
+			// Values come back in the order of the keys, with nil for
+			// missing ones.
+			var keys []*string
+			for i := 149; i >= 0; i -= 3 {
+				key := fmt.Sprintf("k%04d", i)
+				keys = append(keys, &key)
+			}
+			for _, concurrency := range []int{1, 4, 1000} {
+				vals, err := ref.GetManyParallel(keys, concurrency)
+				if err != nil || len(vals) != len(keys) {
+					t.Fatalf("GetManyParallel with concurrency %d = %d values, %v", concurrency, len(vals), err)
+				}
+				for i, val := range vals {
+					var n int
+					fmt.Sscanf(*keys[i], "k%d", &n)
+					switch {
+					case n >= 100 && val != nil:
+						t.Fatalf("value of missing key %s = %q", *keys[i], *val)
+					case n < 100 && (val == nil || *val != fmt.Sprintf("v%d", n)):
+						t.Fatalf("value of %s = %v", *keys[i], val)
+					}
+				}
+			}
+
+			vals, err := ref.GetManyParallel(nil, 4)
+			if err != nil || len(vals) != 0 {
+				t.Fatalf("GetManyParallel of no keys = %v, %v", vals, err)
+			}
+		})
+	}
+}
+
+func TestGetManyParallelReaders(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithNumReaders(2), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	// The lookups share as many transactions as there are readers.
+	var keys []*string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		keys = append(keys, &key)
+	}
+	reads := faults.Count(ezdb.FaultRead)
+	vals, err := ref.GetManyParallel(keys, 100)
+	if err != nil || len(vals) != 100 {
+		t.Fatalf("GetManyParallel = %d values, %v", len(vals), err)
+	}
+	if n := faults.Count(ezdb.FaultRead) - reads; n != 2 {
+		t.Fatalf("GetManyParallel took %d transactions, want 2", n)
+	}
+}
+
+func TestGetManyParallelErrors(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key := "k"
+	_, err = ref.GetManyParallel([]*string{&key}, 0)
+	if err == nil {
+		t.Fatal("GetManyParallel with concurrency 0 succeeded")
+	}
+
+	long := strings.Repeat("k", 600)
+	_, err = ref.GetManyParallel([]*string{&key, &long}, 2)
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("GetManyParallel with a long key returned %v, want ErrKeyTooLarge", err)
+	}
+
+	faults := ezdb.NewFaults(ezdb.Fault{Op: ezdb.FaultRead, At: 1, Every: 1, Err: ezdb.ErrMapFull})
+	ref, err = ezdb.NewDBRef[string, string](testutil.NewMemoryClient(t, ezdb.WithFaults(faults)), "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	_, err = ref.GetManyParallel([]*string{&key}, 1)
+	if !errors.Is(err, ezdb.ErrMapFull) {
+		t.Fatalf("GetManyParallel with a failing read returned %v, want the injected error", err)
+	}
+}
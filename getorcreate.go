@@ -0,0 +1,86 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// keyLocks hands out one mutex per key, so callers working on the same key
+// are serialized without blocking callers working on other keys.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key and returns the function that unlocks it.
+func (l *keyLocks) lock(key string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*keyLock)
+	}
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	kl.Lock()
+	return func() {
+		kl.Unlock()
+
+		l.mu.Lock()
+		kl.refs--
+		if kl.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// GetOrCreate returns the value stored under key. If the key is missing, it
+// stores and returns the result of create instead. Concurrent callers in this
+// process wait for each other, so create runs at most once per missing key.
+// create runs outside any transaction; if another process inserts the key in
+// the meantime, its value wins and is returned.
+func (ref *DBRef[K, V]) GetOrCreate(key *K, create func() (*V, error)) (*V, error) {
+	val, ok, err := ref.TryGet(key)
+	if err != nil || ok {
+		return val, err
+	}
+
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	unlock := ref.ownerDB.keyLocks.lock(ref.id + "\x00" + string(keyBytes))
+	defer unlock()
+
+	// Another caller may have created the value while we waited.
+	val, ok, err = ref.TryGet(key)
+	if err != nil || ok {
+		return val, err
+	}
+
+	val, err = create()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create value: %w", err)
+	}
+
+	err = ref.put(key, val, putNoOverwrite)
+	if errors.Is(err, ErrKeyExists) {
+		return ref.Get(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
@@ -0,0 +1,81 @@
+package ezdb_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestGetOrCreateRunsCreateOnce(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := "k"
+			val, err := ref.GetOrCreate(&key, func() (*string, error) {
+				calls.Add(1)
+				val := "created"
+				return &val, nil
+			})
+			if err != nil || *val != "created" {
+				t.Errorf("GetOrCreate = %v, %v", val, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("create ran %d times, want once", n)
+	}
+}
+
+func TestGetOrCreateKeepsExistingValues(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "stored"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := ref.GetOrCreate(&key, func() (*string, error) {
+		t.Error("create ran for a stored key")
+		return nil, nil
+	})
+	if err != nil || *got != val {
+		t.Fatalf("GetOrCreate = %v, %v", got, err)
+	}
+}
+
+func TestGetOrCreateFailure(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	errCreate := errors.New("create failed")
+	key := "k"
+	_, err = ref.GetOrCreate(&key, func() (*string, error) { return nil, errCreate })
+	if !errors.Is(err, errCreate) {
+		t.Fatalf("GetOrCreate returned %v, want the error of create", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet after a failed create = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,422 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The history database of a DBRef holds every retained version under
+// uvarint(len(key))+key+rev, with rev big-endian, so that the versions of a
+// key are adjacent and in order. A version is a tag, historyValue or
+// historyDeleted, the big-endian Unix nanoseconds it was written at and, for
+// historyValue, the stored value. historyRevKey, shorter than any history
+// key, holds the last revision.
+const (
+	historyDeleted byte = 0
+	historyValue   byte = 1
+)
+
+var historyRevKey = []byte{0xff}
+
+// WithHistory keeps the last n versions of every value of the DBRef, the
+// current one included, along with deletions, in a database of their own,
+// which counts towards WithNumDBs. Every Put and Delete through the DBRef is
+// a new revision of the DBRef, numbered from 1 on; History and GetVersion
+// read the retained versions. Writes that bypass the DBRef, such as loads,
+// copies and mirroring, aren't recorded. It can't be combined with
+// WithChunking, whose chunks are replaced with the values.
+func WithHistory(n uint) RefOption {
+	return func(option *refOptions) error {
+		if n == 0 {
+			return errors.New("history length must be positive")
+		}
+		option.history = &n
+		return nil
+	}
+}
+
+// Version is a retained version of a value, see WithHistory.
+type Version[V any] struct {
+	// Rev is the revision of the DBRef the version was written in.
+	Rev  uint64
+	Time time.Time
+	// Value is nil if the version is a deletion.
+	Value *V
+}
+
+func (ref *DBRef[K, V]) historyDBName() string {
+	return ref.id + ".history"
+}
+
+// initHistory opens the history database, creating it if it doesn't exist.
+func (ref *DBRef[K, V]) initHistory() error {
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+			return err
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(ref.historyDBName(), dbCreate)
+		return err
+	})
+}
+
+// historyPrefix returns the prefix of the history keys of keyBytes.
+func historyPrefix(keyBytes []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(keyBytes))), keyBytes...)
+}
+
+// revisionInTxn returns the last revision of the DBRef with the history
+// database histRef.
+func revisionInTxn(txn readTxn, histRef dbi) (uint64, error) {
+	data, err := txn.Get(histRef, historyRevKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get revision: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, errors.New("invalid revision")
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// historyInTxn records a new version of the value under keyBytes, stored as
+// valBytes or deleted if valBytes is nil, and drops the versions beyond the
+// history length.
+func (ref *DBRef[K, V]) historyInTxn(txn writeTxn, keyBytes, valBytes []byte) error {
+	if ref.options.history == nil {
+		return nil
+	}
+
+	histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	rev, err := revisionInTxn(txn, histRef)
+	if err != nil {
+		return err
+	}
+	rev++
+	err = txn.Put(histRef, historyRevKey, binary.BigEndian.AppendUint64(nil, rev), putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to put revision: %w", err)
+	}
+
+	prefix := historyPrefix(keyBytes)
+	version := []byte{historyDeleted}
+	if valBytes != nil {
+		version[0] = historyValue
+	}
+	version = binary.BigEndian.AppendUint64(version, uint64(time.Now().UnixNano()))
+	version = append(version, valBytes...)
+	err = txn.Put(histRef, binary.BigEndian.AppendUint64(bytes.Clone(prefix), rev), version, putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to put version: %w", err)
+	}
+
+	cursor, err := txn.NewCursor(histRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	var versions [][]byte
+	key, _, err := cursor.SeekGreaterThanOrEqualKey(prefix)
+	for ; err == nil && isVersionOf(key, prefix); key, _, err = cursor.Next() {
+		versions = append(versions, bytes.Clone(key))
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read versions: %w", err)
+	}
+	for len(versions) > int(*ref.options.history) {
+		err = txn.Delete(histRef, versions[0], nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete version: %w", err)
+		}
+		versions = versions[1:]
+	}
+
+	return nil
+}
+
+// isVersionOf reports whether key is a history key with prefix.
+func isVersionOf(key, prefix []byte) bool {
+	return len(key) == len(prefix)+8 && bytes.HasPrefix(key, prefix)
+}
+
+// decodeVersion decodes a version stored under histKey.
+func (ref *DBRef[K, V]) decodeVersion(histKey, data []byte) (Version[V], error) {
+	if len(data) < 9 || data[0] > historyValue {
+		return Version[V]{}, errors.New("invalid version")
+	}
+
+	v := Version[V]{
+		Rev:  binary.BigEndian.Uint64(histKey[len(histKey)-8:]),
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(data[1:]))),
+	}
+	if data[0] == historyValue {
+		var err error
+		v.Value, err = ref.decodeVal(data[9:])
+		if err != nil {
+			return Version[V]{}, fmt.Errorf("failed to decode value: %w", err)
+		}
+	}
+
+	return v, nil
+}
+
+// Revision returns the last revision of ref, which must have been created
+// with WithHistory, or 0 if it was never written.
+func (ref *DBRef[K, V]) Revision() (rev uint64, err error) {
+	if ref.options.history == nil {
+		return 0, errors.New("db ref has no history")
+	}
+
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		rev, err = revisionInTxn(txn, histRef)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rev, nil
+}
+
+// History returns the retained versions of the value under key, oldest
+// first. ref must have been created with WithHistory.
+func (ref *DBRef[K, V]) History(key *K) ([]Version[V], error) {
+	if ref.options.history == nil {
+		return nil, errors.New("db ref has no history")
+	}
+
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	prefix := historyPrefix(keyBytes)
+
+	var versions []Version[V]
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(histRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		histKey, data, err := cursor.SeekGreaterThanOrEqualKey(prefix)
+		for ; err == nil && isVersionOf(histKey, prefix); histKey, data, err = cursor.Next() {
+			v, err := ref.decodeVersion(histKey, data)
+			if err != nil {
+				return err
+			}
+			versions = append(versions, v)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read versions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetVersion returns the value under key as of revision rev of ref, which
+// must have been created with WithHistory. A key that had no value then, or
+// whose version of then is no longer retained, is reported as an error
+// matching ErrNotFound.
+func (ref *DBRef[K, V]) GetVersion(key *K, rev uint64) (*V, error) {
+	if ref.options.history == nil {
+		return nil, errors.New("db ref has no history")
+	}
+
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	var val *V
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		val, err = ref.versionInTxn(txn, keyBytes, rev)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// versionInTxn returns the value under keyBytes as of revision rev.
+func (ref *DBRef[K, V]) versionInTxn(txn readTxn, keyBytes []byte, rev uint64) (*V, error) {
+	histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	cursor, err := txn.NewCursor(histRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	// Find the last version of the key up to rev, right before the first
+	// one after it. Revisions never get near the largest uint64.
+	if rev == ^uint64(0) {
+		rev--
+	}
+	prefix := historyPrefix(keyBytes)
+	histKey, data, err := cursor.SeekGreaterThanOrEqualKey(binary.BigEndian.AppendUint64(bytes.Clone(prefix), rev+1))
+	switch {
+	case err == nil:
+		histKey, data, err = cursor.Prev()
+	case errors.Is(err, ErrNotFound):
+		histKey, data, err = cursor.Last()
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read versions: %w", err)
+	}
+	if err != nil || !isVersionOf(histKey, prefix) {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	v, err := ref.decodeVersion(histKey, data)
+	if err != nil {
+		return nil, err
+	}
+	if v.Value == nil {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return v.Value, nil
+}
+
+// versionAtInTxn returns the value under keyBytes as of time t.
+func (ref *DBRef[K, V]) versionAtInTxn(txn readTxn, keyBytes []byte, t time.Time) (*V, error) {
+	histRef, err := txn.DBRef(ref.historyDBName(), dbFlag(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref: %w", err)
+	}
+	cursor, err := txn.NewCursor(histRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	// The last version written by t is the last one of those in order.
+	prefix := historyPrefix(keyBytes)
+	var last []byte
+	histKey, data, err := cursor.SeekGreaterThanOrEqualKey(prefix)
+	for ; err == nil && isVersionOf(histKey, prefix); histKey, data, err = cursor.Next() {
+		if len(data) < 9 || int64(binary.BigEndian.Uint64(data[1:])) > t.UnixNano() {
+			break
+		}
+		last = append(append(last[:0], histKey...), data...)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read versions: %w", err)
+	}
+	if last == nil {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	split := len(prefix) + 8
+	v, err := ref.decodeVersion(last[:split], last[split:])
+	if err != nil {
+		return nil, err
+	}
+	if v.Value == nil {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return v.Value, nil
+}
+
+// RevisionView is a read-only view of a DBRef as of a past revision or time,
+// see DBRef.At and DBRef.AtTime.
+type RevisionView[K, V any] struct {
+	ref *DBRef[K, V]
+	// rev is the revision of the view, unless t is set.
+	rev uint64
+	t   time.Time
+}
+
+// At returns a view of ref as of revision rev, which answers Gets from the
+// retained versions, e.g. to see what a config looked like when an incident
+// started. ref must have been created with WithHistory. Keys whose version
+// of then is no longer retained are reported missing.
+func (ref *DBRef[K, V]) At(rev uint64) (*RevisionView[K, V], error) {
+	if ref.options.history == nil {
+		return nil, errors.New("db ref has no history")
+	}
+
+	return &RevisionView[K, V]{ref: ref, rev: rev}, nil
+}
+
+// AtTime is At for the revision current at t, by the clock of the writers.
+func (ref *DBRef[K, V]) AtTime(t time.Time) (*RevisionView[K, V], error) {
+	if ref.options.history == nil {
+		return nil, errors.New("db ref has no history")
+	}
+	if t.IsZero() {
+		return nil, errors.New("time must be set")
+	}
+
+	return &RevisionView[K, V]{ref: ref, t: t}, nil
+}
+
+// Get returns the value under key as of the view. A key that had no value
+// then is reported as an error matching ErrNotFound.
+func (v *RevisionView[K, V]) Get(key *K) (*V, error) {
+	keyBytes, err := v.ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	var val *V
+	err = v.ref.ownerDB.view(func(txn readTxn) error {
+		if v.t.IsZero() {
+			val, err = v.ref.versionInTxn(txn, keyBytes, v.rev)
+		} else {
+			val, err = v.ref.versionAtInTxn(txn, keyBytes, v.t)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// TryGet returns the value under key as of the view and whether it had one.
+func (v *RevisionView[K, V]) TryGet(key *K) (*V, bool, error) {
+	val, err := v.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
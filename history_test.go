@@ -0,0 +1,278 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// historyRef opens the DBRef "ref" of db with string keys and values,
+// keeping n versions of each.
+func historyRef(t *testing.T, db *ezdb.Client, n uint) *ezdb.DBRef[string, string] {
+	t.Helper()
+
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithHistory(n))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	return ref
+}
+
+// versions formats versions as rev=value, with - for deletions.
+func versions(vs []ezdb.Version[string]) string {
+	var parts []string
+	for _, v := range vs {
+		val := "-"
+		if v.Value != nil {
+			val = *v.Value
+		}
+		parts = append(parts, fmt.Sprintf("%d=%s", v.Rev, val))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestHistory(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			ref := historyRef(t, e.open(t), 3)
+			rev, err := ref.Revision()
+			if err != nil || rev != 0 {
+				t.Fatalf("Revision of a new DBRef = %d, %v", rev, err)
+			}
+
+			a, b := "a", "b"
+			for _, val := range []string{"a1", "a2"} {
+				err = ref.Put(&a, &val)
+				if err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+			}
+			val := "b1"
+			err = ref.Put(&b, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			err = ref.Delete(&a)
+			if err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			val = "a3"
+			err = ref.Put(&a, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			// Only the last 3 versions of a are kept, the current one and
+			// the deletion included.
+			rev, err = ref.Revision()
+			if err != nil || rev != 5 {
+				t.Fatalf("Revision = %d, %v, want 5", rev, err)
+			}
+			vs, err := ref.History(&a)
+			if err != nil || versions(vs) != "2=a2 4=- 5=a3" {
+				t.Fatalf("History(a) = %s, %v", versions(vs), err)
+			}
+			for i := 1; i < len(vs); i++ {
+				if vs[i].Time.Before(vs[i-1].Time) {
+					t.Fatalf("versions aren't in time order: %v", vs)
+				}
+			}
+			vs, err = ref.History(&b)
+			if err != nil || versions(vs) != "3=b1" {
+				t.Fatalf("History(b) = %s, %v", versions(vs), err)
+			}
+			missing := "missing"
+			vs, err = ref.History(&missing)
+			if err != nil || len(vs) != 0 {
+				t.Fatalf("History of a missing key = %s, %v", versions(vs), err)
+			}
+
+			for _, test := range []struct {
+				key  *string
+				rev  uint64
+				want string
+			}{
+				{&a, 1, ""}, // no longer retained
+				{&a, 2, "a2"},
+				{&a, 3, "a2"},
+				{&a, 4, ""}, // deleted
+				{&a, 5, "a3"},
+				{&a, 100, "a3"},
+				{&a, ^uint64(0), "a3"},
+				{&b, 2, ""},
+				{&b, 3, "b1"},
+				{&missing, 5, ""},
+			} {
+				got, err := ref.GetVersion(test.key, test.rev)
+				switch {
+				case test.want == "" && !errors.Is(err, ezdb.ErrNotFound):
+					t.Errorf("GetVersion(%s, %d) = %v, %v, want ErrNotFound", *test.key, test.rev, got, err)
+				case test.want != "" && (err != nil || *got != test.want):
+					t.Errorf("GetVersion(%s, %d) = %v, %v, want %s", *test.key, test.rev, got, err, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryDrop(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref := historyRef(t, db, 2)
+	putN(t, ref, 3)
+	err := ref.Drop()
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+
+	ref = historyRef(t, db, 2)
+	rev, err := ref.Revision()
+	if err != nil || rev != 0 {
+		t.Fatalf("Revision after Drop = %d, %v", rev, err)
+	}
+	key := "k0000"
+	vs, err := ref.History(&key)
+	if err != nil || len(vs) != 0 {
+		t.Fatalf("History after Drop = %s, %v", versions(vs), err)
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	_, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithHistory(0))
+	if err == nil {
+		t.Fatal("NewDBRef with a history of 0 succeeded")
+	}
+
+	ref, err := ezdb.NewDBRef[string, string](db, "plain", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key := "k"
+	_, err = ref.Revision()
+	if err == nil {
+		t.Fatal("Revision without history succeeded")
+	}
+	_, err = ref.History(&key)
+	if err == nil {
+		t.Fatal("History without history succeeded")
+	}
+	_, err = ref.GetVersion(&key, 1)
+	if err == nil {
+		t.Fatal("GetVersion without history succeeded")
+	}
+}
+
+func TestAt(t *testing.T) {
+	ref := historyRef(t, testutil.NewTempClient(t), 3)
+	a, b := "a", "b"
+	var times []time.Time
+	put := func(key *string, val string) {
+		t.Helper()
+		err := ref.Put(key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		times = append(times, time.Now())
+		time.Sleep(time.Millisecond)
+	}
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	put(&a, "a1")
+	put(&b, "b1")
+	put(&a, "a2")
+	err := ref.Delete(&b)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	times = append(times, time.Now())
+	// at[i] is a time after revision i.
+	at := append([]time.Time{before}, times...)
+
+	for _, test := range []struct {
+		rev  uint64
+		a, b string
+	}{
+		{0, "", ""},
+		{1, "a1", ""},
+		{2, "a1", "b1"},
+		{3, "a2", "b1"},
+		{4, "a2", ""},
+		{100, "a2", ""},
+	} {
+		view, err := ref.At(test.rev)
+		if err != nil {
+			t.Fatalf("At: %v", err)
+		}
+		wantView(t, fmt.Sprintf("At(%d)", test.rev), view, test.a, test.b)
+		i := int(test.rev)
+		if i >= len(at) {
+			i = len(at) - 1
+		}
+		view, err = ref.AtTime(at[i])
+		if err != nil {
+			t.Fatalf("AtTime: %v", err)
+		}
+		wantView(t, fmt.Sprintf("AtTime of revision %d", test.rev), view, test.a, test.b)
+	}
+
+	// Views see later writes as of their revision, and not at all once the
+	// versions are no longer retained.
+	view, err := ref.At(1)
+	if err != nil {
+		t.Fatalf("At: %v", err)
+	}
+	put(&a, "a3")
+	wantView(t, "At(1)", view, "a1", "")
+	put(&a, "a4")
+	wantView(t, "At(1) after trimming", view, "", "")
+}
+
+// wantView fails t unless view has the values a and b under the keys a and
+// b, an empty value meaning none.
+func wantView(t *testing.T, name string, view *ezdb.RevisionView[string, string], a, b string) {
+	t.Helper()
+
+	for key, want := range map[string]string{"a": a, "b": b} {
+		key := key
+		got, ok, err := view.TryGet(&key)
+		switch {
+		case err != nil:
+			t.Errorf("%s: TryGet(%s): %v", name, key, err)
+		case want == "" && ok:
+			t.Errorf("%s: TryGet(%s) = %s, want none", name, key, *got)
+		case want != "" && (!ok || *got != want):
+			t.Errorf("%s: TryGet(%s) = %v, %t, want %s", name, key, got, ok, want)
+		}
+		_, err = view.Get(&key)
+		if want == "" && !errors.Is(err, ezdb.ErrNotFound) {
+			t.Errorf("%s: Get(%s) returned %v, want ErrNotFound", name, key, err)
+		}
+	}
+}
+
+func TestAtErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref := historyRef(t, db, 3)
+	_, err := ref.AtTime(time.Time{})
+	if err == nil {
+		t.Fatal("AtTime of the zero time succeeded")
+	}
+
+	plain, err := ezdb.NewDBRef[string, string](db, "plain", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	_, err = plain.At(1)
+	if err == nil {
+		t.Fatal("At without history succeeded")
+	}
+	_, err = plain.AtTime(time.Now())
+	if err == nil {
+		t.Fatal("AtTime without history succeeded")
+	}
+}
@@ -0,0 +1,277 @@
+package ezdb
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Conflict is what Import does with records whose key already exists.
+type Conflict string
+
+const (
+	// ConflictOverwrite replaces the stored value. It is the default.
+	ConflictOverwrite Conflict = "overwrite"
+	// ConflictSkip keeps the stored value and skips the record.
+	ConflictSkip Conflict = "skip"
+	// ConflictError fails the import with an error matching ErrKeyExists.
+	ConflictError Conflict = "error"
+)
+
+// ImportOptions configure Import.
+type ImportOptions struct {
+	// OnConflict is what to do with records whose key already exists. The
+	// default is ConflictOverwrite.
+	OnConflict Conflict
+	// ChunkSize is the number of records written per transaction. The
+	// default is 1024.
+	ChunkSize int
+}
+
+// ImportResult counts the records processed by Import.
+type ImportResult struct {
+	// Imported is the number of records written.
+	Imported int
+	// Skipped is the number of records skipped because of ConflictSkip.
+	Skipped int
+}
+
+// Import reads records in format from r, as written by Export, and writes
+// them to ref in chunks, each in its own transaction. The writes go through
+// the same validation, indexes, triggers, quotas and audit log as Put. If
+// Import fails, the chunks before the failing one stay written, and the
+// result counts them.
+func (ref *DBRef[K, V]) Import(r io.Reader, format Format, opts ImportOptions) (result ImportResult, err error) {
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = ConflictOverwrite
+	case ConflictOverwrite, ConflictSkip, ConflictError:
+	default:
+		return result, fmt.Errorf("unknown conflict policy %q", opts.OnConflict)
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = copyChunkSize
+	}
+
+	var next func() (*K, *V, error)
+	switch format {
+	case FormatJSONLines:
+		next = jsonLinesReader[K, V](r)
+	case FormatCSV:
+		next, err = csvReader[K, V](r)
+		if err != nil {
+			return result, err
+		}
+	default:
+		return result, fmt.Errorf("unknown import format %q", format)
+	}
+
+	type pending struct {
+		n                  int
+		keyBytes, valBytes []byte
+		val                *V
+	}
+	chunk := make([]pending, 0, opts.ChunkSize)
+	for n := 1; ; n++ {
+		key, val, err := next()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return result, fmt.Errorf("failed to read record %d: %w", n, err)
+		}
+		if err == nil {
+			keyBytes, valBytes, err := ref.encodePair(key, val)
+			if err != nil {
+				return result, fmt.Errorf("invalid record %d: %w", n, err)
+			}
+			chunk = append(chunk, pending{n, keyBytes, valBytes, val})
+			if len(chunk) < opts.ChunkSize {
+				continue
+			}
+		}
+		if len(chunk) == 0 {
+			return result, nil
+		}
+
+		var chunkResult ImportResult
+		err = ref.ownerDB.update(func(txn writeTxn) error {
+			chunkResult = ImportResult{}
+			dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+			if err != nil {
+				return fmt.Errorf("failed to get db ref: %w", err)
+			}
+
+			for _, p := range chunk {
+				if opts.OnConflict != ConflictOverwrite {
+					_, err = txn.Get(dbRef, p.keyBytes)
+					if err == nil && opts.OnConflict == ConflictSkip {
+						chunkResult.Skipped++
+						continue
+					}
+					if err == nil {
+						return fmt.Errorf("record %d: %w", p.n, ErrKeyExists)
+					}
+					if !errors.Is(err, ErrNotFound) {
+						return fmt.Errorf("failed to get key: %w", err)
+					}
+				}
+
+				err = ref.putInTxn(txn, dbRef, p.keyBytes, p.valBytes, p.val, putFlag(0))
+				if err != nil {
+					return err
+				}
+				chunkResult.Imported++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Imported += chunkResult.Imported
+		result.Skipped += chunkResult.Skipped
+		chunk = chunk[:0]
+	}
+}
+
+// jsonLinesReader returns a function reading the next record of r in
+// FormatJSONLines, or io.EOF.
+func jsonLinesReader[K, V any](r io.Reader) func() (*K, *V, error) {
+	dec := json.NewDecoder(r)
+	return func() (*K, *V, error) {
+		var line jsonLine[K, V]
+		err := dec.Decode(&line)
+		if err != nil {
+			return nil, nil, err
+		}
+		if line.Key == nil {
+			return nil, nil, errors.New("missing key")
+		}
+		if line.Value == nil {
+			line.Value = new(V)
+		}
+
+		return line.Key, line.Value, nil
+	}
+}
+
+// csvReader reads the header of r in FormatCSV and returns a function reading
+// the next record, or io.EOF. Columns missing from the header are left at
+// their zero value.
+func csvReader[K, V any](r io.Reader) (func() (*K, *V, error), error) {
+	keyCols, err := csvColumns(reflect.TypeOf((*K)(nil)).Elem(), "key", "key.")
+	if err != nil {
+		return nil, fmt.Errorf("failed to map key to CSV: %w", err)
+	}
+	valCols, err := csvColumns(reflect.TypeOf((*V)(nil)).Elem(), "value", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to map value to CSV: %w", err)
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// cols[i] is the column of field i of the header, and isKey[i] whether it
+	// is part of the key.
+	cols := make([]csvColumn, len(header))
+	isKey := make([]bool, len(header))
+	hasKey := false
+	for i, name := range header {
+		found := false
+		for j, col := range append(keyCols, valCols...) {
+			if col.name == name {
+				cols[i], isKey[i], found = col, j < len(keyCols), true
+				hasKey = hasKey || isKey[i]
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+	if !hasKey {
+		return nil, errors.New("no key column in CSV header")
+	}
+
+	return func() (*K, *V, error) {
+		row, err := cr.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key, val := new(K), new(V)
+		keyVal, valVal := reflect.ValueOf(key).Elem(), reflect.ValueOf(val).Elem()
+		for i, field := range row {
+			v := valVal
+			if isKey[i] {
+				v = keyVal
+			}
+			if cols[i].index != nil {
+				v = v.FieldByIndex(cols[i].index)
+			}
+			err = parseScalar(field, v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %w", cols[i].name, err)
+			}
+		}
+
+		return key, val, nil
+	}, nil
+}
+
+// parseScalar sets v, a scalar as defined by isScalar, to s as formatted by
+// formatScalar.
+func parseScalar(s string, v reflect.Value) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(b)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestImportRoundTrip(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	src, err := ezdb.NewDBRef[string, event](db, "src", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, key := range []string{"a", "b", "c"} {
+		val := event{Name: key, At: at, Count: 3, Ratio: 0.25, OK: true, Payload: []byte(key)}
+		err = src.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	for _, format := range []ezdb.Format{ezdb.FormatJSONLines, ezdb.FormatCSV} {
+		var buf bytes.Buffer
+		err = src.Export(&buf, format)
+		if err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+		dst, err := ezdb.NewDBRef[string, event](db, "dst-"+string(format), ezdb.WithCodec(ezdb.JSONCodec{}))
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		result, err := dst.Import(&buf, format, ezdb.ImportOptions{ChunkSize: 2})
+		if err != nil || result != (ezdb.ImportResult{Imported: 3}) {
+			t.Fatalf("Import of %s = %+v, %v", format, result, err)
+		}
+		for _, key := range []string{"a", "b", "c"} {
+			got, err := dst.Get(&key)
+			if err != nil || got.Name != key || !got.At.Equal(at) || string(got.Payload) != key || got.Ratio != 0.25 {
+				t.Fatalf("Get %s after importing %s = %+v, %v", key, format, got, err)
+			}
+		}
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "a", "stored"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	const records = `{"key":"a","value":"imported"}
+{"key":"b","value":"imported"}
+`
+
+	result, err := ref.Import(strings.NewReader(records), ezdb.FormatJSONLines, ezdb.ImportOptions{OnConflict: ezdb.ConflictSkip})
+	if err != nil || result != (ezdb.ImportResult{Imported: 1, Skipped: 1}) {
+		t.Fatalf("Import skipping conflicts = %+v, %v", result, err)
+	}
+	got, _ := ref.Get(&key)
+	if *got != "stored" {
+		t.Fatalf("skipped key holds %q", *got)
+	}
+
+	_, err = ref.Import(strings.NewReader(records), ezdb.FormatJSONLines, ezdb.ImportOptions{OnConflict: ezdb.ConflictError})
+	if !errors.Is(err, ezdb.ErrKeyExists) {
+		t.Fatalf("Import with conflicts as errors returned %v, want ErrKeyExists", err)
+	}
+
+	result, err = ref.Import(strings.NewReader(records), ezdb.FormatJSONLines, ezdb.ImportOptions{})
+	if err != nil || result.Imported != 2 {
+		t.Fatalf("Import overwriting = %+v, %v", result, err)
+	}
+	got, _ = ref.Get(&key)
+	if *got != "imported" {
+		t.Fatalf("overwritten key holds %q", *got)
+	}
+
+	_, err = ref.Import(strings.NewReader(records), ezdb.FormatJSONLines, ezdb.ImportOptions{OnConflict: "merge"})
+	if err == nil {
+		t.Fatal("Import with an unknown conflict policy succeeded")
+	}
+	_, err = ref.Import(strings.NewReader(records), "xml", ezdb.ImportOptions{})
+	if err == nil {
+		t.Fatal("Import in an unknown format succeeded")
+	}
+}
+
+func TestImportKeepsEarlierChunks(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithValidator(func(key, val *string) error {
+		if *val == "invalid" {
+			return errors.New("invalid value")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// The fifth record fails the validator, which fails the third chunk.
+	records := "key,value\nk0000,v0\nk0001,v1\nk0002,v2\nk0003,v3\nk0004,invalid\nk0005,v5\n"
+	result, err := ref.Import(strings.NewReader(records), ezdb.FormatCSV, ezdb.ImportOptions{ChunkSize: 2})
+	if err == nil || result.Imported != 4 {
+		t.Fatalf("Import of an invalid record = %+v, %v", result, err)
+	}
+	wantN(t, ref, 4)
+}
+
+func TestImportRejectsMalformedRecords(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, event](db, "events", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	for name, tt := range map[string]struct {
+		format  ezdb.Format
+		records string
+	}{
+		"json":           {ezdb.FormatJSONLines, "{\"key\":\"a\",\n"},
+		"missing key":    {ezdb.FormatJSONLines, `{"value":{"name":"a"}}`},
+		"unknown column": {ezdb.FormatCSV, "key,colour\na,red\n"},
+		"no key column":  {ezdb.FormatCSV, "name\na\n"},
+		"empty":          {ezdb.FormatCSV, ""},
+		"bad number":     {ezdb.FormatCSV, "key,Count\na,many\n"},
+		"bad time":       {ezdb.FormatCSV, "key,At\na,yesterday\n"},
+		"bad bytes":      {ezdb.FormatCSV, "key,Payload\na,!!!\n"},
+		"bad bool":       {ezdb.FormatCSV, "key,OK\na,maybe\n"},
+	} {
+		result, err := ref.Import(strings.NewReader(tt.records), tt.format, ezdb.ImportOptions{})
+		if err == nil || result.Imported != 0 {
+			t.Errorf("Import of %s = %+v, %v, want an error", name, result, err)
+		}
+	}
+
+	// Missing columns are left at their zero value.
+	result, err := ref.Import(strings.NewReader("key,name\na,deploy\n"), ezdb.FormatCSV, ezdb.ImportOptions{})
+	if err != nil || result.Imported != 1 {
+		t.Fatalf("Import = %+v, %v", result, err)
+	}
+	key := "a"
+	got, err := ref.Get(&key)
+	if err != nil || got.Name != "deploy" || got.Count != 0 {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
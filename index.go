@@ -0,0 +1,514 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// indexer is a secondary index kept up to date by the writes of a DBRef.
+type indexer[V any] interface {
+	// reindex replaces the entries of the primary key pk derived from old by
+	// the ones derived from val. old is nil for new keys, val for deletes.
+	reindex(txn writeTxn, pk []byte, old, val *V) error
+	// indexDBName returns the name of the named database of the index.
+	indexDBName() string
+}
+
+// reindex updates all of ref's indexes for a write of pk.
+func (ref *DBRef[K, V]) reindex(txn writeTxn, pk []byte, old, val *V) error {
+	for _, idx := range ref.indexes {
+		err := idx.reindex(txn, pk, old, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Index is a secondary index mapping values of type I, derived from the
+// values of a DBRef, to the primary keys they were derived from. It is stored
+// in its own named database, so it counts towards WithNumDBs, and is updated
+// in the same transaction as every Put and Delete of the DBRef.
+type Index[I, K, V any] struct {
+	name    string
+	dbName  string
+	ref     *DBRef[K, V]
+	extract func(val *V) []I
+	options *indexOptions
+}
+
+type IndexOption func(option *indexOptions) error
+
+type indexOptions struct {
+	codec  Codec
+	unique bool
+}
+
+// WithIndexCodec sets the codec indexed values are stored with. The default
+// is GobCodec.
+func WithIndexCodec(codec Codec) IndexOption {
+	return func(option *indexOptions) error {
+		option.codec = codec
+		return nil
+	}
+}
+
+// WithUnique makes Puts that would map an indexed value to a second primary
+// key fail with an error matching ErrDuplicate.
+func WithUnique() IndexOption {
+	return func(option *indexOptions) error {
+		option.unique = true
+		return nil
+	}
+}
+
+// NewIndex adds an index named name to ref, indexing every value by the
+// result of extract. If the index is empty, as a new one is, it is built
+// from the entries already in ref. Indexes must be added before ref is used
+// concurrently.
+func NewIndex[I, K, V any](ref *DBRef[K, V], name string, extract func(val *V) I, opts ...IndexOption) (*Index[I, K, V], error) {
+	return newIndex(ref, name, func(val *V) []I {
+		return []I{extract(val)}
+	}, opts)
+}
+
+// NewMultiIndex is like NewIndex for extractors returning several values,
+// such as tags or label sets: every value is indexed under each element of
+// the slice extract returns for it.
+func NewMultiIndex[I, K, V any](ref *DBRef[K, V], name string, extract func(val *V) []I, opts ...IndexOption) (*Index[I, K, V], error) {
+	return newIndex(ref, name, extract, opts)
+}
+
+func newIndex[I, K, V any](ref *DBRef[K, V], name string, extract func(val *V) []I, opts []IndexOption) (*Index[I, K, V], error) {
+	o := &indexOptions{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	// Default values
+	if o.codec == nil {
+		o.codec = GobCodec{}
+	}
+
+	idx := &Index[I, K, V]{
+		name:    name,
+		dbName:  ref.id + ".idx." + name,
+		ref:     ref,
+		extract: extract,
+		options: o,
+	}
+
+	// The transaction may run again once the storage engine has created the
+	// index database, so whether it existed says nothing about whether it
+	// was built.
+	err := ref.ownerDB.update(func(txn writeTxn) error {
+		idxRef, err := txn.DBRef(idx.dbName, dbDupSort|dbCreate)
+		if err != nil {
+			return err
+		}
+		empty, err := isEmptyInTxn(txn, idxRef)
+		if err != nil || !empty {
+			return err
+		}
+
+		return idx.build(txn)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index %q: %w", name, err)
+	}
+
+	ref.indexes = append(ref.indexes, idx)
+	return idx, nil
+}
+
+func (idx *Index[I, K, V]) indexDBName() string {
+	return idx.dbName
+}
+
+// build indexes every entry already in the DBRef.
+func (idx *Index[I, K, V]) build(txn writeTxn) error {
+	dbRef, err := txn.DBRef(idx.ref.id, idx.ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	key, valBytes, err := cursor.First()
+	for ; err == nil; key, valBytes, err = cursor.Next() {
+		val, err := idx.ref.decodeValInTxn(txn, valBytes)
+		if err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+
+		err = idx.reindex(txn, key, nil, val)
+		if err != nil {
+			return err
+		}
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return nil
+}
+
+// isEmptyInTxn reports whether the named database dbRef has no entries.
+func isEmptyInTxn(txn readTxn, dbRef dbi) (bool, error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return false, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	_, _, err = cursor.First()
+	if errors.Is(err, ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return false, nil
+}
+
+// entries returns the distinct encoded index values of val.
+func (idx *Index[I, K, V]) entries(val *V) ([][]byte, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	var entries [][]byte
+	for _, v := range idx.extract(val) {
+		entry, err := idx.options.codec.Marshal(&v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode index value: %w", err)
+		}
+		if !containsBytes(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (idx *Index[I, K, V]) reindex(txn writeTxn, pk []byte, old, val *V) error {
+	dbRef, err := txn.DBRef(idx.dbName, dbDupSort)
+	if err != nil {
+		return fmt.Errorf("failed to get index %q: %w", idx.name, err)
+	}
+
+	oldEntries, err := idx.entries(old)
+	if err != nil {
+		return err
+	}
+	newEntries, err := idx.entries(val)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range oldEntries {
+		if containsBytes(newEntries, entry) {
+			continue
+		}
+		err = txn.Delete(dbRef, entry, pk)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete from index %q: %w", idx.name, err)
+		}
+	}
+
+	for _, entry := range newEntries {
+		if containsBytes(oldEntries, entry) {
+			continue
+		}
+		if len(entry) > maxKeySize {
+			return &SizeError{Err: ErrKeyTooLarge, DB: idx.dbName, Size: len(entry), Limit: maxKeySize}
+		}
+
+		if idx.options.unique {
+			owner, err := txn.Get(dbRef, entry)
+			if err == nil && !bytes.Equal(owner, pk) {
+				return fmt.Errorf("%w: index %q", ErrDuplicate, idx.name)
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to read index %q: %w", idx.name, err)
+			}
+		}
+
+		err = txn.Put(dbRef, entry, pk, putNoDupData)
+		if err != nil && !errors.Is(err, ErrKeyExists) {
+			return fmt.Errorf("failed to write index %q: %w", idx.name, err)
+		}
+	}
+
+	return nil
+}
+
+func containsBytes(list [][]byte, b []byte) bool {
+	for _, item := range list {
+		if bytes.Equal(item, b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys returns the primary keys of all values indexed under v, in the order
+// of their encoding. Entries of a DBRef with a TTL that have expired are
+// left out, as are those of Get and Range.
+func (idx *Index[I, K, V]) Keys(v *I) (keys []K, err error) {
+	entry, err := idx.options.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index value: %w", err)
+	}
+
+	err = idx.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(idx.dbName, dbDupSort)
+		if err != nil {
+			return fmt.Errorf("failed to get index %q: %w", idx.name, err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		now := time.Now()
+		pk, err := cursor.SeekExactKey(entry)
+		for ; err == nil; _, pk, err = cursor.NextInSameKey() {
+			ok, err := idx.visibleInTxn(txn, pk, now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			key, err := idx.ref.decodeKey(pk)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			keys = append(keys, *key)
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read index %q: %w", idx.name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Sub returns the index for the namespace prefix of the DBRef of idx, see
+// DBRef.Sub: its lookups only report the entries of that namespace.
+func (idx *Index[I, K, V]) Sub(prefix string) *Index[I, K, V] {
+	sub := *idx
+	sub.ref = idx.ref.Sub(prefix)
+	return &sub
+}
+
+// FindBy returns the primary keys of all values indexed under v, e.g. every
+// record carrying a given tag in a multi-valued index.
+func FindBy[I, K, V any](idx *Index[I, K, V], v I) ([]K, error) {
+	return idx.Keys(&v)
+}
+
+// Get returns the key and value of the entry indexed under v, which is
+// meant for unique indexes; if several entries are, it returns the first of
+// them. A missing entry is reported as an error matching ErrNotFound.
+func (idx *Index[I, K, V]) Get(v *I) (*K, *V, error) {
+	entry, err := idx.options.codec.Marshal(v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode index value: %w", err)
+	}
+
+	var key *K
+	var val *V
+	err = idx.ref.ownerDB.view(func(txn readTxn) error {
+		idxRef, err := txn.DBRef(idx.dbName, dbDupSort)
+		if err != nil {
+			return fmt.Errorf("failed to get index %q: %w", idx.name, err)
+		}
+		dbRef, err := txn.DBRef(idx.ref.id, idx.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		pk, err := idx.firstInTxn(txn, idxRef, entry)
+		if err != nil {
+			return fmt.Errorf("failed to read index %q: %w", idx.name, err)
+		}
+
+		key, err = idx.ref.decodeKey(pk)
+		if err != nil {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+		val, err = idx.ref.getInTxn(txn, dbRef, pk)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			return fmt.Errorf("index %q points to missing key: %w", idx.name, ErrNotFound)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, val, nil
+}
+
+// firstInTxn returns the first primary key indexed under entry that is
+// visible through idx, see visibleInTxn.
+func (idx *Index[I, K, V]) firstInTxn(txn readTxn, idxRef dbi, entry []byte) ([]byte, error) {
+	if len(idx.ref.prefix) == 0 && idx.ref.options.ttl == nil {
+		return txn.Get(idxRef, entry)
+	}
+
+	cursor, err := txn.NewCursor(idxRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	now := time.Now()
+	pk, err := cursor.SeekExactKey(entry)
+	for ; err == nil; _, pk, err = cursor.NextInSameKey() {
+		ok, err := idx.visibleInTxn(txn, pk, now)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return pk, nil
+		}
+	}
+
+	return nil, err
+}
+
+// visibleInTxn reports whether the entry under the primary key pk is in the
+// namespace of the DBRef of idx and, on DBRefs with a TTL, hasn't expired by
+// now. Index entries of expired values remain until they're deleted.
+func (idx *Index[I, K, V]) visibleInTxn(txn readTxn, pk []byte, now time.Time) (bool, error) {
+	if !bytes.HasPrefix(pk, idx.ref.prefix) {
+		return false, nil
+	}
+	if idx.ref.options.ttl == nil {
+		return true, nil
+	}
+	expired, err := idx.ref.expiredInTxn(txn, pk, now)
+	if err != nil {
+		return false, err
+	}
+
+	return !expired, nil
+}
+
+// Range calls fn with the key and value of every entry indexed under a value
+// in [from, to), in the order of the encoded index values and then of the
+// encoded primary keys. A nil bound leaves that side of the range open. The
+// scan runs in a single read transaction and stops at the first error fn
+// returns, which Range returns.
+//
+// The order of the encoded index values only matches the order of the values
+// themselves with an order-preserving codec, so indexes meant for range
+// queries should be created with WithIndexCodec(OrderedCodec{}).
+func (idx *Index[I, K, V]) Range(from, to *I, fn func(key *K, val *V) error) error {
+	var fromEntry, toEntry []byte
+	var err error
+	if from != nil {
+		fromEntry, err = idx.options.codec.Marshal(from)
+		if err != nil {
+			return fmt.Errorf("failed to encode index value: %w", err)
+		}
+	}
+	if to != nil {
+		toEntry, err = idx.options.codec.Marshal(to)
+		if err != nil {
+			return fmt.Errorf("failed to encode index value: %w", err)
+		}
+	}
+
+	return idx.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(idx.ref.id, idx.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		now := time.Now()
+		return idx.scanEntries(txn, fromEntry, toEntry, func(_, pk []byte) error {
+			ok, err := idx.visibleInTxn(txn, pk, now)
+			if err != nil || !ok {
+				return err
+			}
+			key, err := idx.ref.decodeKey(pk)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			val, err := idx.ref.getInTxn(txn, dbRef, pk)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				return fmt.Errorf("index %q points to missing key: %w", idx.name, ErrNotFound)
+			}
+
+			return fn(key, val)
+		})
+	})
+}
+
+// scanEntries calls fn with every encoded index value in [from, to) and each
+// primary key indexed under it. A nil bound leaves that side open.
+func (idx *Index[I, K, V]) scanEntries(txn readTxn, from, to []byte, fn func(entry, pk []byte) error) error {
+	idxRef, err := txn.DBRef(idx.dbName, dbDupSort)
+	if err != nil {
+		return fmt.Errorf("failed to get index %q: %w", idx.name, err)
+	}
+
+	cursor, err := txn.NewCursor(idxRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	var entry, pk []byte
+	if from == nil {
+		entry, pk, err = cursor.First()
+	} else {
+		entry, pk, err = cursor.SeekGreaterThanOrEqualKey(from)
+	}
+
+	for ; err == nil; entry, pk, err = cursor.Next() {
+		if to != nil && bytes.Compare(entry, to) >= 0 {
+			return nil
+		}
+
+		err = fn(entry, pk)
+		if err != nil {
+			return err
+		}
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read index %q: %w", idx.name, err)
+	}
+
+	return nil
+}
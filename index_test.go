@@ -0,0 +1,322 @@
+package ezdb_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestIndex(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, user]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	// Entries written before the index is added are indexed when it is.
+	err = ref.PutValue("ada", user{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+
+	byName, err := ezdb.NewIndex(ref, "name", func(u *user) string { return u.Name })
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	err = ref.PutValue("ada2", user{Name: "Ada"})
+	if err == nil {
+		err = ref.PutValue("grace", user{Name: "Grace"})
+	}
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+
+	keys, err := ezdb.FindBy(byName, "Ada")
+	if err != nil || !reflect.DeepEqual(keys, []string{"ada", "ada2"}) {
+		t.Fatalf("FindBy(Ada) = %v, %v", keys, err)
+	}
+
+	// Overwrites and deletes move and remove index entries.
+	err = ref.PutValue("ada2", user{Name: "Grace"})
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	key := "grace"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	keys, err = ezdb.FindBy(byName, "Grace")
+	if err != nil || !reflect.DeepEqual(keys, []string{"ada2"}) {
+		t.Fatalf("FindBy(Grace) = %v, %v", keys, err)
+	}
+	keys, err = ezdb.FindBy(byName, "Ada")
+	if err != nil || !reflect.DeepEqual(keys, []string{"ada"}) {
+		t.Fatalf("FindBy(Ada) = %v, %v", keys, err)
+	}
+	keys, err = ezdb.FindBy(byName, "Nobody")
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("FindBy(Nobody) = %v, %v", keys, err)
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, user]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	byEmail, err := ezdb.NewIndex(ref, "email", func(u *user) string { return u.Email }, ezdb.WithUnique())
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+
+	ada := user{Name: "Ada", Email: "ada@example.com"}
+	err = ref.PutValue("ada", ada)
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	// Rewriting the owner of an indexed value is fine.
+	ada.Name = "Ada Lovelace"
+	err = ref.PutValue("ada", ada)
+	if err != nil {
+		t.Fatalf("PutValue of the same key: %v", err)
+	}
+
+	err = ref.PutValue("impostor", user{Name: "Eve", Email: "ada@example.com"})
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("PutValue of a duplicate returned %v, want ErrDuplicate", err)
+	}
+	_, ok, err := ref.TryGetValue("impostor")
+	if err != nil || ok {
+		t.Fatalf("the duplicate was stored: %v, %v", ok, err)
+	}
+
+	email := "ada@example.com"
+	key, val, err := byEmail.Get(&email)
+	if err != nil || *key != "ada" || *val != ada {
+		t.Fatalf("Get = %v, %v, %v", key, val, err)
+	}
+	email = "nobody@example.com"
+	_, _, err = byEmail.Get(&email)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of a missing value returned %v, want ErrNotFound", err)
+	}
+
+	// Once the owner is deleted the value is free again.
+	key = new(string)
+	*key = "ada"
+	err = ref.Delete(key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	err = ref.PutValue("eve", user{Name: "Eve", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("PutValue after the owner was deleted: %v", err)
+	}
+}
+
+func TestUniqueIndexBuildFailsOnDuplicates(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, user]("users", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	err = ref.PutValue("a", user{Email: "same@example.com"})
+	if err == nil {
+		err = ref.PutValue("b", user{Email: "same@example.com"})
+	}
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+
+	_, err = ezdb.NewIndex(ref, "email", func(u *user) string { return u.Email }, ezdb.WithUnique())
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("NewIndex over duplicates returned %v, want ErrDuplicate", err)
+	}
+}
+
+type post struct {
+	Title string
+	Tags  []string
+}
+
+func TestMultiIndex(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, post]("posts", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	byTag, err := ezdb.NewMultiIndex(ref, "tags", func(p *post) []string { return p.Tags })
+	if err != nil {
+		t.Fatalf("NewMultiIndex: %v", err)
+	}
+
+	// Repeated tags are indexed once.
+	err = ref.PutValue("a", post{Tags: []string{"go", "db", "go"}})
+	if err == nil {
+		err = ref.PutValue("b", post{Tags: []string{"go"}})
+	}
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	keys, err := ezdb.FindBy(byTag, "go")
+	if err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("FindBy(go) = %v, %v", keys, err)
+	}
+	keys, err = ezdb.FindBy(byTag, "db")
+	if err != nil || !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Fatalf("FindBy(db) = %v, %v", keys, err)
+	}
+
+	// Tags dropped from a value are removed from the index, the others kept.
+	err = ref.PutValue("a", post{Tags: []string{"db", "lmdb"}})
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	for tag, want := range map[string][]string{"go": {"b"}, "db": {"a"}, "lmdb": {"a"}} {
+		keys, err = ezdb.FindBy(byTag, tag)
+		if err != nil || !reflect.DeepEqual(keys, want) {
+			t.Fatalf("FindBy(%s) = %v, %v, want %v", tag, keys, err, want)
+		}
+	}
+}
+
+func TestMultiIndexUnique(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, post]("posts", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	_, err = ezdb.NewMultiIndex(ref, "tags", func(p *post) []string { return p.Tags }, ezdb.WithUnique())
+	if err != nil {
+		t.Fatalf("NewMultiIndex: %v", err)
+	}
+
+	err = ref.PutValue("a", post{Tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	err = ref.PutValue("b", post{Tags: []string{"z", "y"}})
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("PutValue sharing a tag returned %v, want ErrDuplicate", err)
+	}
+}
+
+type employee struct {
+	Name string
+	Age  int
+}
+
+func TestIndexRange(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, employee]("employees", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	byAge, err := ezdb.NewIndex(ref, "age", func(e *employee) int { return e.Age }, ezdb.WithIndexCodec(ezdb.OrderedCodec{}))
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	for key, age := range map[string]int{"d": 40, "a": 25, "c": 31, "b": 25, "e": -1} {
+		err = ref.PutValue(key, employee{Name: key, Age: age})
+		if err != nil {
+			t.Fatalf("PutValue: %v", err)
+		}
+	}
+
+	collect := func(from, to *int) []string {
+		t.Helper()
+		var keys []string
+		err := byAge.Range(from, to, func(key *string, val *employee) error {
+			if val.Name != *key {
+				t.Errorf("Range passed %v for %s", val, *key)
+			}
+			keys = append(keys, *key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Range: %v", err)
+		}
+		return keys
+	}
+	lo, hi := 25, 40
+	for _, tc := range []struct {
+		from, to *int
+		want     []string
+	}{
+		{&lo, &hi, []string{"a", "b", "c"}},
+		{nil, &hi, []string{"e", "a", "b", "c"}},
+		{&lo, nil, []string{"a", "b", "c", "d"}},
+		{nil, nil, []string{"e", "a", "b", "c", "d"}},
+		{&hi, &lo, nil},
+	} {
+		if got := collect(tc.from, tc.to); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Range(%v, %v) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err = byAge.Range(nil, nil, func(key *string, val *employee) error {
+		n++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || n != 1 {
+		t.Fatalf("Range stopped after %d entries with %v", n, err)
+	}
+}
+
+func TestIndexSkipsExpired(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, employee](db, "employees", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	byAge, err := ezdb.NewIndex(ref, "age", func(e *employee) int { return e.Age }, ezdb.WithIndexCodec(ezdb.OrderedCodec{}))
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	for _, e := range []struct {
+		key string
+		age int
+		ttl time.Duration
+	}{
+		{"a", 25, time.Millisecond},
+		{"b", 25, time.Hour},
+		{"c", 31, time.Millisecond},
+	} {
+		val := employee{Name: e.key, Age: e.age}
+		err = ref.PutTTL(&e.key, &val, e.ttl)
+		if err != nil {
+			t.Fatalf("PutTTL: %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	keys, err := ezdb.FindBy(byAge, 25)
+	if err != nil || !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("FindBy(25) = %v, %v", keys, err)
+	}
+	age := 25
+	key, val, err := byAge.Get(&age)
+	if err != nil || *key != "b" || val.Name != "b" {
+		t.Fatalf("Get(25) = %v, %v, %v", key, val, err)
+	}
+	age = 31
+	_, _, err = byAge.Get(&age)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get(31) of an expired entry returned %v, want ErrNotFound", err)
+	}
+	var ranged []string
+	err = byAge.Range(nil, nil, func(key *string, _ *employee) error {
+		ranged = append(ranged, *key)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(ranged, []string{"b"}) {
+		t.Fatalf("Range = %v, %v", ranged, err)
+	}
+}
@@ -0,0 +1,83 @@
+package ezdb
+
+import "fmt"
+
+// Info describes the LMDB environment of a Client, for automation deciding
+// when to compact it or grow its map.
+type Info struct {
+	// Version is the version string of the LMDB library, such as
+	// "LMDB 0.9.70: (December 19, 2015)", and VersionMajor, VersionMinor
+	// and VersionPatch its parts.
+	Version                                  string
+	VersionMajor, VersionMinor, VersionPatch int
+	// LastTxnID is the ID of the last committed write transaction.
+	LastTxnID uint64
+	// MapSize is the current size of the memory map in bytes, and PageSize
+	// the size of a page.
+	MapSize  uint64
+	PageSize uint64
+	// UsedPages is the number of pages the data file has grown to, including
+	// pages on the free list, and MapPages the number of pages the map
+	// holds. Writes fail with ErrMapFull once the free pages run out with
+	// UsedPages at MapPages.
+	UsedPages uint64
+	MapPages  uint64
+	// Flags are the names of the environment flags the Client opens the
+	// environment with, such as "MDB_RDONLY", as in lmdb.h.
+	Flags []string
+	// MaxReaders and MaxDBs are the configured size of the reader table and
+	// number of named databases, see WithNumReaders and WithNumDBs.
+	MaxReaders uint
+	MaxDBs     uint
+}
+
+// MapUsed returns the fraction of the map the data file has grown to.
+func (info Info) MapUsed() float64 {
+	if info.MapPages == 0 {
+		return 0
+	}
+
+	return float64(info.UsedPages) / float64(info.MapPages)
+}
+
+// envFlagNames are the names of the environment flags ezdb sets.
+var envFlagNames = []struct {
+	flag envFlag
+	name string
+}{
+	{envReadOnly, "MDB_RDONLY"},
+	{envNoLock, "MDB_NOLOCK"},
+	{envNoRdAhead, "MDB_NORDAHEAD"},
+}
+
+// Info returns information about the Client's LMDB environment. Other
+// storage engines fail with an error matching ErrUnsupported.
+func (db *Client) Info() (Info, error) {
+	s, err := db.envStat()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read environment info: %w", err)
+	}
+	info := Info{
+		Version:      s.Version,
+		VersionMajor: s.VersionMajor,
+		VersionMinor: s.VersionMinor,
+		VersionPatch: s.VersionPatch,
+		LastTxnID:    s.LastTxnID,
+		MapSize:      s.MapSize,
+		PageSize:     s.PageSize,
+		UsedPages:    s.LastPage + 1,
+		MaxReaders:   *db.options.numReaders,
+		MaxDBs:       *db.options.numDbs,
+	}
+	if s.PageSize > 0 {
+		info.MapPages = s.MapSize / s.PageSize
+	}
+
+	for _, f := range envFlagNames {
+		if db.options.envFlags&f.flag != 0 {
+			info.Flags = append(info.Flags, f.name)
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,76 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestInfo(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir, ezdb.WithNumReaders(20), ezdb.WithNoReadahead())
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	before, err := db.Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	putN(t, ref, 100)
+	info, err := db.Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+
+	version := fmt.Sprintf("LMDB %d.%d.%d", info.VersionMajor, info.VersionMinor, info.VersionPatch)
+	if !strings.HasPrefix(info.Version, version) || info.PageSize == 0 || info.MapSize != info.MapPages*info.PageSize ||
+		info.MaxReaders != 20 || info.MaxDBs != 16 || fmt.Sprint(info.Flags) != "[MDB_NORDAHEAD]" {
+		t.Fatalf("Info = %+v", info)
+	}
+	if info.LastTxnID <= before.LastTxnID || info.UsedPages <= before.UsedPages {
+		t.Fatalf("Info after writes = %+v, before %+v", info, before)
+	}
+	if used := info.MapUsed(); used <= 0 || used > 1 || used != float64(info.UsedPages)/float64(info.MapPages) {
+		t.Fatalf("MapUsed = %v of %+v", used, info)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	info, err = openClient(t, dir, ezdb.WithReadOnly()).Info()
+	if err != nil || fmt.Sprint(info.Flags) != "[MDB_RDONLY]" || info.MaxReaders != 8 {
+		t.Fatalf("Info of a read-only Client = %+v, %v", info, err)
+	}
+
+	if used := (ezdb.Info{}).MapUsed(); used != 0 {
+		t.Fatalf("MapUsed without a map = %v", used)
+	}
+}
+
+func TestInfoUnsupported(t *testing.T) {
+	for _, e := range engines {
+		if e.engine == ezdb.EngineLMDB {
+			continue
+		}
+		_, err := e.open(t).Info()
+		if !errors.Is(err, ezdb.ErrUnsupported) {
+			t.Errorf("%s: Info returned %v, want ErrUnsupported", e.engine, err)
+		}
+	}
+
+	db := testutil.NewTempClient(t)
+	err := db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_, err = db.Info()
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Info after Close returned %v, want ErrClosed", err)
+	}
+}
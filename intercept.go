@@ -0,0 +1,65 @@
+package ezdb
+
+import "context"
+
+// OpInfo describes an operation passed to an Interceptor.
+type OpInfo struct {
+	// Context is the context of the DBRef, as bound with WithContext, for
+	// request-scoped values such as trace IDs, or context.Background().
+	Context context.Context
+	// Op is "get", "put", "delete" or, for Expire, "expire".
+	Op string
+	// DBRef is the name of the DBRef the operation is on.
+	DBRef string
+	// Key is a pointer to the key, of the DBRef's key type.
+	Key any
+	// Value is a pointer to the value written by a Put, of the DBRef's value
+	// type, and nil for other operations and for Restore, which is a put of
+	// the value in the tombstone.
+	Value any
+}
+
+// Interceptor wraps an operation. It must call next to run the operation, or
+// the rest of the chain, and usually returns its error; it may also return an
+// error without calling next to reject the operation.
+type Interceptor func(op OpInfo, next func() error) error
+
+// WithInterceptor adds an interceptor wrapping every Get, Put and Delete of
+// the Client's DBRefs, including the ones made by helpers such as TryGet,
+// GetOrCreate, PutTTL, SoftDelete and Restore, and every Expire, for metrics,
+// tracing, access checks or fault injection. Interceptors run in the order
+// they are given, the first one outermost. APIs that write in transactions of
+// their own, such as Queue and TimeSeries, are not intercepted.
+func WithInterceptor(interceptor Interceptor) Option {
+	return func(option *options) error {
+		option.interceptors = append(option.interceptors, interceptor)
+		return nil
+	}
+}
+
+// intercept runs fn, an operation on key, through the Client's interceptors.
+// The operation fails with the context's error if the context of ref is done.
+func (ref *DBRef[K, V]) intercept(op string, key *K, val *V, fn func() error) error {
+	err := ref.ctxErr()
+	if err != nil {
+		return err
+	}
+
+	interceptors := ref.ownerDB.options.interceptors
+	if len(interceptors) == 0 {
+		return fn()
+	}
+
+	info := OpInfo{Context: ref.Context(), Op: op, DBRef: ref.id, Key: key}
+	if val != nil {
+		info.Value = val
+	}
+
+	next := fn
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func() error { return interceptor(info, inner) }
+	}
+
+	return next()
+}
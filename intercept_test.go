@@ -0,0 +1,72 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	record := func(name string) ezdb.Interceptor {
+		return func(op ezdb.OpInfo, next func() error) error {
+			calls = append(calls, name+":"+op.Op)
+			return next()
+		}
+	}
+	denied := errors.New("denied")
+	var infos []ezdb.OpInfo
+	db := testutil.NewTempClient(t,
+		ezdb.WithInterceptor(record("outer")),
+		ezdb.WithInterceptor(record("inner")),
+		ezdb.WithInterceptor(func(op ezdb.OpInfo, next func() error) error {
+			infos = append(infos, op)
+			if key := op.Key.(*string); *key == "secret" {
+				return denied
+			}
+			return next()
+		}),
+	)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, _, err = ref.TryGet(&key)
+	if err != nil {
+		t.Fatalf("TryGet: %v", err)
+	}
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := fmt.Sprint(calls); got != "[outer:put inner:put outer:get inner:get outer:delete inner:delete]" {
+		t.Fatalf("interceptors ran as %s", got)
+	}
+	if len(infos) != 3 || infos[0].DBRef != "ref" || infos[0].Value.(*string) != &val || infos[1].Value != nil || infos[0].Context == nil {
+		t.Fatalf("interceptors were passed %+v", infos)
+	}
+
+	// Rejected operations don't run.
+	key = "secret"
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, denied) {
+		t.Fatalf("rejected Put returned %v", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if !errors.Is(err, denied) || ok {
+		t.Fatalf("rejected TryGet = %t, %v", ok, err)
+	}
+	stat, err := ref.Stat()
+	if err != nil || stat.Entries != 0 {
+		t.Fatalf("rejected Put stored something: %+v, %v", stat, err)
+	}
+}
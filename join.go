@@ -0,0 +1,41 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Join calls fn with every entry of left, in key order, along with the value
+// of right stored under the foreign key fk extracts from it, or nil if there
+// is no such value or fk returns nil. left is scanned and right read in one
+// shared read transaction instead of one per lookup, so left and right must
+// belong to the same Client. The join stops at the first error fn returns,
+// which Join returns.
+func Join[K1, V1, K2, V2 any](left *DBRef[K1, V1], right *DBRef[K2, V2], fk func(key *K1, val *V1) *K2, fn func(key *K1, val *V1, ref *V2) error) error {
+	if left.ownerDB != right.ownerDB {
+		return errors.New("cannot join databases of different clients")
+	}
+
+	return left.ownerDB.view(func(txn readTxn) error {
+		rightRef, err := txn.DBRef(right.id, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		return left.scanInTxn(txn, func(key *K1, val *V1) error {
+			var refVal *V2
+			if refKey := fk(key, val); refKey != nil {
+				keyBytes, err := right.encodeKey(refKey)
+				if err != nil {
+					return fmt.Errorf("failed to encode foreign key: %w", err)
+				}
+				refVal, err = right.getInTxn(txn, rightRef, keyBytes)
+				if err != nil {
+					return err
+				}
+			}
+
+			return fn(key, val, refVal)
+		})
+	})
+}
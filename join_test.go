@@ -0,0 +1,121 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type order struct {
+	Customer string
+	Total    int
+}
+
+func TestJoin(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	orders, err := ezdb.NewRef[string, order]("orders", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	customers, err := ezdb.NewRef[string, string]("customers", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	for key, val := range map[string]string{"ann": "Ann", "bob": "Bob"} {
+		key, val := key, val
+		err = customers.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	for key, val := range map[string]order{
+		"o1": {Customer: "bob", Total: 10},
+		"o2": {Customer: "ann", Total: 20},
+		"o3": {Customer: "eve", Total: 30},
+		"o4": {Total: 40},
+	} {
+		key, val := key, val
+		err = orders.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	var got []string
+	err = ezdb.Join(orders, customers, func(key *string, val *order) *string {
+		if val.Customer == "" {
+			return nil
+		}
+		return &val.Customer
+	}, func(key *string, val *order, name *string) error {
+		if name == nil {
+			got = append(got, *key+":-")
+		} else {
+			got = append(got, *key+":"+*name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	want := []string{"o1:Bob", "o2:Ann", "o3:-", "o4:-"}
+	if len(got) != len(want) {
+		t.Fatalf("Join saw %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Join saw %v, want %v", got, want)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = ezdb.Join(orders, customers, func(key *string, val *order) *string {
+		return &val.Customer
+	}, func(key *string, val *order, name *string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("Join returned %v after %d calls, want stop after 1", err, calls)
+	}
+
+	otherDB := testutil.NewTempClient(t)
+	other, err := ezdb.NewRef[string, string]("customers", otherDB)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	err = ezdb.Join(orders, other, func(key *string, val *order) *string {
+		return &val.Customer
+	}, func(key *string, val *order, name *string) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Join across Clients succeeded")
+	}
+}
+
+func TestForEachStops(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = ref.ForEach(func(key, val *string) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || calls != 3 {
+		t.Fatalf("ForEach returned %v after %d calls, want stop after 3", err, calls)
+	}
+}
@@ -0,0 +1,259 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// keyPrefixesDB is the named database the key prefix dictionaries of all
+// DBRefs with one are recorded in.
+const keyPrefixesDB = "ezdb.keyprefixes"
+
+// keyPrefixOptions are the key prefix dictionary of a DBRef.
+type keyPrefixOptions struct {
+	declared []string
+	// ids and prefixes map the recorded prefixes to their IDs and back, and
+	// lengths are the distinct lengths of the prefixes, once the DBRef is
+	// opened.
+	ids      map[string]uint64
+	prefixes map[uint64]string
+	lengths  []int
+}
+
+// WithKeyPrefixes stores keys starting with one of prefixes with the prefix
+// replaced by a short ID, which shrinks the stored keys of deeply namespaced
+// schemas, such as "tenants/acme/projects/", so more of them fit in a page.
+// Prefixes are matched against the encoded keys, so they suit codecs that
+// encode string keys as their bytes, such as BytesCodec or, for strings
+// without zero bytes, OrderedCodec; no prefix may be a prefix of another.
+//
+// The IDs are recorded in a named database, which counts towards WithNumDBs,
+// the first time the DBRef is opened with the prefixes. Further prefixes can
+// only be declared while the database is empty, since keys already stored
+// with them would no longer be found; recorded ones stay in use even if they
+// are no longer declared. Stored keys are no longer in the order of their
+// encodings, so queries don't narrow key ranges and scan every entry instead.
+func WithKeyPrefixes(prefixes ...string) RefOption {
+	return func(option *refOptions) error {
+		declared := append([]string(nil), prefixes...)
+		sort.Strings(declared)
+		err := checkKeyPrefixes(declared)
+		if err != nil {
+			return err
+		}
+		option.keyPrefixes = &keyPrefixOptions{declared: declared}
+		return nil
+	}
+}
+
+// checkKeyPrefixes checks that none of the sorted prefixes is empty or a
+// prefix of another. A prefix of another would sort right before it or before
+// other strings starting with it, so checking neighbours is enough.
+func checkKeyPrefixes(prefixes []string) error {
+	for i, p := range prefixes {
+		if p == "" {
+			return errors.New("key prefixes must not be empty")
+		}
+		if i > 0 && len(p) >= len(prefixes[i-1]) && p[:len(prefixes[i-1])] == prefixes[i-1] {
+			return fmt.Errorf("key prefix %q starts with key prefix %q", p, prefixes[i-1])
+		}
+	}
+
+	return nil
+}
+
+// keyPrefixRecord returns the key the prefix of the DBRef name is recorded
+// under.
+func keyPrefixRecord(name, prefix string) []byte {
+	return []byte(name + "\x00" + prefix)
+}
+
+// compress replaces the recorded prefix data starts with by its ID, or marks
+// data as having none with ID 0.
+func (d *keyPrefixOptions) compress(data []byte) []byte {
+	for _, n := range d.lengths {
+		if n > len(data) {
+			continue
+		}
+		if id, ok := d.ids[string(data[:n])]; ok {
+			return append(binary.AppendUvarint(nil, id), data[n:]...)
+		}
+	}
+
+	return append([]byte{0}, data...)
+}
+
+// expand reverses compress.
+func (d *keyPrefixOptions) expand(data []byte) ([]byte, error) {
+	id, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errors.New("invalid key prefix ID")
+	}
+	if id == 0 {
+		return data[n:], nil
+	}
+
+	prefix, ok := d.prefixes[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key prefix ID %d", id)
+	}
+	return append([]byte(prefix), data[n:]...), nil
+}
+
+// loadInTxn loads the prefixes recorded for the DBRef name.
+func (d *keyPrefixOptions) loadInTxn(txn readTxn, name string) error {
+	d.ids = make(map[string]uint64)
+	d.prefixes = make(map[uint64]string)
+	d.lengths = nil
+
+	dictRef, err := txn.DBRef(keyPrefixesDB, dbFlag(0))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	cursor, err := txn.NewCursor(dictRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	start := keyPrefixRecord(name, "")
+	key, val, err := cursor.SeekGreaterThanOrEqualKey(start)
+	for ; err == nil && bytes.HasPrefix(key, start); key, val, err = cursor.Next() {
+		id, n := binary.Uvarint(val)
+		if n <= 0 || id == 0 {
+			return fmt.Errorf("invalid key prefix ID of %q", key[len(start):])
+		}
+		d.add(string(key[len(start):]), id)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read key prefixes: %w", err)
+	}
+
+	return nil
+}
+
+// dropKeyPrefixesInTxn deletes the prefixes recorded for the DBRef name,
+// which is being dropped.
+func dropKeyPrefixesInTxn(txn writeTxn, name string) error {
+	dictRef, err := txn.DBRef(keyPrefixesDB, dbFlag(0))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	var records [][]byte
+	err = scanPrefix(txn, dictRef, keyPrefixRecord(name, ""), func(key []byte) error {
+		records = append(records, bytes.Clone(key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read key prefixes: %w", err)
+	}
+	for _, record := range records {
+		err = txn.Delete(dictRef, record, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete key prefix: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// add makes prefix known under id.
+func (d *keyPrefixOptions) add(prefix string, id uint64) {
+	d.ids[prefix] = id
+	d.prefixes[id] = prefix
+	for _, n := range d.lengths {
+		if n == len(prefix) {
+			return
+		}
+	}
+	d.lengths = append(d.lengths, len(prefix))
+}
+
+// initKeyPrefixes loads the key prefix dictionary of ref, recording the
+// declared prefixes that aren't yet.
+func (ref *DBRef[K, V]) initKeyPrefixes() error {
+	d := ref.options.keyPrefixes
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			err := d.loadInTxn(txn, ref.id)
+			if err != nil {
+				return err
+			}
+			for _, p := range d.declared {
+				if _, ok := d.ids[p]; !ok {
+					return fmt.Errorf("key prefix %q isn't recorded", p)
+				}
+			}
+			return nil
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		dictRef, err := txn.DBRef(keyPrefixesDB, dbCreate)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		err = d.loadInTxn(txn, ref.id)
+		if err != nil {
+			return err
+		}
+
+		var added []string
+		for _, p := range d.declared {
+			if _, ok := d.ids[p]; !ok {
+				added = append(added, p)
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+
+		all := append([]string(nil), added...)
+		for p := range d.ids {
+			all = append(all, p)
+		}
+		sort.Strings(all)
+		err = checkKeyPrefixes(all)
+		if err != nil {
+			return err
+		}
+
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+		_, _, err = cursor.First()
+		if err == nil {
+			return fmt.Errorf("key prefix %q can't be added to a database with entries", added[0])
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		next := uint64(len(d.prefixes)) + 1
+		for _, p := range added {
+			err = txn.Put(dictRef, keyPrefixRecord(ref.id, p), binary.AppendUvarint(nil, next), putFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to record key prefix: %w", err)
+			}
+			d.add(p, next)
+			next++
+		}
+		return nil
+	})
+}
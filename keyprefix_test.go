@@ -0,0 +1,161 @@
+package ezdb_test
+
+import (
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// prefixedRef opens the DBRef "ref" of db with string keys and values,
+// storing the key prefixes given.
+func prefixedRef(t *testing.T, db *ezdb.Client, prefixes ...string) (*ezdb.DBRef[string, string], error) {
+	t.Helper()
+
+	return ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithKeyPrefixes(prefixes...))
+}
+
+func TestKeyPrefixes(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := prefixedRef(t, db, "tenants/acme/projects/", "tenants/initech/")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	keys := []string{"tenants/acme/projects/apollo", "tenants/initech/tps", "tenants/other", "tenants/acme/projects/"}
+	for _, key := range keys {
+		val := "v " + key
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	for _, key := range keys {
+		got, err := ref.Get(&key)
+		if err != nil || *got != "v "+key {
+			t.Fatalf("Get(%s) = %v, %v", key, got, err)
+		}
+	}
+	seen := map[string]bool{}
+	err = ref.ForEach(func(key, val *string) error {
+		seen[*key] = *val == "v "+*key
+		return nil
+	})
+	if err != nil || len(seen) != len(keys) {
+		t.Fatalf("ForEach saw %v: %v", seen, err)
+	}
+
+	// Stored keys have their prefix replaced.
+	raw := rawRef(t, db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	var stored []string
+	err = raw.ForEach(func(key *string, _ *[]byte) error {
+		stored = append(stored, *key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	for _, key := range stored {
+		if len(key) > len("tenants/other")+1 {
+			t.Fatalf("stored key %q isn't compressed", key)
+		}
+	}
+
+	// Queries check their ranges on the decoded keys.
+	var matched []string
+	err = ref.Query().WherePrefix("tenants/acme/").Each(func(key, _ *string) error {
+		matched = append(matched, *key)
+		return nil
+	})
+	if err != nil || len(matched) != 2 {
+		t.Fatalf("prefix query matched %v: %v", matched, err)
+	}
+
+	// Prefixes can't be added once the database has entries, but recorded
+	// ones are used even when no longer declared.
+	_, err = prefixedRef(t, db, "tenants/acme/projects/", "tenants/initech/", "tenants/hooli/")
+	if err == nil {
+		t.Fatal("NewDBRef with a new prefix for a database with entries succeeded")
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	ref, err = prefixedRef(t, openClient(t, dir, ezdb.WithReadOnly()), "tenants/initech/")
+	if err != nil {
+		t.Fatalf("NewDBRef after reopening: %v", err)
+	}
+	key := "tenants/acme/projects/apollo"
+	got, err := ref.Get(&key)
+	if err != nil || *got != "v "+key {
+		t.Fatalf("Get after reopening = %v, %v", got, err)
+	}
+}
+
+func TestKeyPrefixesEmpty(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := prefixedRef(t, db, "a/")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Prefixes can be added while the database is empty.
+	ref, err = prefixedRef(t, db, "a/", "b/")
+	if err != nil {
+		t.Fatalf("NewDBRef with a new prefix: %v", err)
+	}
+	key, val := "b/1", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Dropping the database forgets them.
+	err = ref.Drop()
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	_, err = prefixedRef(t, db, "b/1")
+	if err != nil {
+		t.Fatalf("NewDBRef after Drop: %v", err)
+	}
+}
+
+func TestKeyPrefixesErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	for _, prefixes := range [][]string{{""}, {"a/", "a/b/"}, {"a", "a"}} {
+		_, err := prefixedRef(t, db, prefixes...)
+		if err == nil {
+			t.Errorf("NewDBRef with prefixes %q succeeded", prefixes)
+		}
+	}
+
+	// A prefix may not start with a recorded one.
+	_, err := prefixedRef(t, db, "a/")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	_, err = prefixedRef(t, db, "a/b/")
+	if err == nil {
+		t.Fatal("NewDBRef with a prefix starting with a recorded one succeeded")
+	}
+
+	// Read-only Clients can't record prefixes.
+	dir := t.TempDir()
+	rw := openClient(t, dir)
+	_, err = ezdb.NewDBRef[string, string](rw, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	rw.Close()
+	_, err = prefixedRef(t, openClient(t, dir, ezdb.WithReadOnly()), "a/")
+	if err == nil {
+		t.Fatal("NewDBRef with an unrecorded prefix on a read-only Client succeeded")
+	}
+
+	_, err = ezdb.NewDBRef[uint64, string](db, "ints", ezdb.WithIntegerKeys(), ezdb.WithKeyPrefixes("a"))
+	if err == nil {
+		t.Fatal("NewDBRef with integer keys and key prefixes succeeded")
+	}
+}
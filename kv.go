@@ -0,0 +1,24 @@
+package ezdb
+
+// KV is the key-value API of a DBRef, for application code that takes its
+// storage as a dependency: it can be given a DBRef in production and a fake,
+// such as testutil.Fake, in unit tests. remote.DBRef implements it as well.
+type KV[K, V any] interface {
+	// Name returns the name of the database.
+	Name() string
+	// Get returns the value stored under key, failing with an error matching
+	// ErrNotFound if there is none.
+	Get(key *K) (*V, error)
+	// TryGet returns the value stored under key and whether there is one.
+	TryGet(key *K) (val *V, ok bool, err error)
+	// Put stores val under key, replacing any existing value.
+	Put(key *K, val *V) error
+	// Delete deletes key, failing with an error matching ErrNotFound if it
+	// doesn't exist.
+	Delete(key *K) error
+	// ForEach calls fn with every entry until fn returns an error, which
+	// ForEach returns.
+	ForEach(fn func(key *K, val *V) error) error
+}
+
+var _ KV[string, string] = (*DBRef[string, string])(nil)
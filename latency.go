@@ -0,0 +1,70 @@
+package ezdb
+
+import (
+	"errors"
+	"time"
+)
+
+// maxAdaptiveBatch caps the number of writes WithLatencyTarget puts in one
+// transaction.
+const maxAdaptiveBatch = 4096
+
+// WithLatencyTarget commits the Puts and Deletes of the Client's DBRefs that
+// arrive while a commit is running together in the next transaction, taking
+// as many of them per transaction as keeps the time from a write arriving to
+// its commit within target: the number grows by one after every full
+// transaction that met the target and halves after every one that didn't.
+// Quiet periods thus get transactions of single writes, and sustained load
+// ever larger ones, until they get too slow; unlike WithBatchSize, which
+// fixes the number. As with WithFlushInterval, every write still returns once
+// it is committed, with its own error; the two can't be combined.
+func WithLatencyTarget(target time.Duration) Option {
+	return func(option *options) error {
+		if target <= 0 {
+			return errors.New("latency target must be positive")
+		}
+		option.latencyTarget = target
+		return nil
+	}
+}
+
+// drain commits the waiting writes, up to limit per transaction, until there
+// are none left.
+func (c *coalescer) drain() {
+	for {
+		c.mu.Lock()
+		n := len(c.pending)
+		if n > c.limit {
+			n = c.limit
+		}
+		if n == 0 {
+			c.draining = false
+			c.mu.Unlock()
+			return
+		}
+		batch := c.pending[:n:n]
+		c.pending = append([]*coalescedWrite(nil), c.pending[n:]...)
+		full := n == c.limit
+		c.committing++
+		c.mu.Unlock()
+
+		// The writes get the error, if any.
+		c.commit(batch)
+		c.committed()
+		c.adapt(time.Since(batch[0].start), full)
+	}
+}
+
+// adapt adjusts the limit after a transaction whose oldest write took
+// latency to commit.
+func (c *coalescer) adapt(latency time.Duration, full bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case latency > c.target:
+		c.limit = (c.limit + 1) / 2
+	case full && c.limit < maxAdaptiveBatch:
+		c.limit++
+	}
+}
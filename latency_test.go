@@ -0,0 +1,104 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestLatencyTarget(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithLatencyTarget(time.Minute), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Writes arriving while others commit share transactions, and a write
+	// that fails doesn't fail the others.
+	writes := faults.Count(ezdb.FaultWrite)
+	var wg sync.WaitGroup
+	errs := make([]error, 101)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i == 100 {
+				key := "missing"
+				errs[i] = ref.Delete(&key)
+				return
+			}
+			key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+			errs[i] = ref.Put(&key, &val)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs[:100] {
+		if err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if !errors.Is(errs[100], ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", errs[100])
+	}
+	wantN(t, ref, 100)
+	if n := faults.Count(ezdb.FaultWrite) - writes; n >= 100 {
+		t.Fatalf("%d concurrent writes took %d transactions", len(errs), n)
+	}
+
+	err = db.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Put after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestLatencyTargetMissed(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithLatencyTarget(time.Nanosecond), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Transactions that miss the target stay at single writes.
+	writes := faults.Count(ezdb.FaultWrite)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+			err := ref.Put(&key, &val)
+			if err != nil {
+				t.Errorf("Put: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	wantN(t, ref, 20)
+	if n := faults.Count(ezdb.FaultWrite) - writes; n != 20 {
+		t.Fatalf("20 writes missing the target took %d transactions", n)
+	}
+}
+
+func TestWithLatencyTargetErrors(t *testing.T) {
+	_, err := ezdb.New(t.TempDir(), ezdb.WithLatencyTarget(0))
+	if err == nil {
+		t.Fatal("New with a zero latency target succeeded")
+	}
+}
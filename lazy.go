@@ -0,0 +1,48 @@
+package ezdb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Lazy is a value read from a DBRef but not decoded yet, for pipelines that
+// only forward or hash values.
+type Lazy[V any] struct {
+	raw        []byte
+	decode     func(data []byte) (*V, error)
+	decodeInto func(data []byte, v any) error
+}
+
+// Bytes returns the value as stored, i.e. encoded and, if the DBRef enables
+// it, compressed. The caller must not modify the returned slice.
+func (l *Lazy[V]) Bytes() []byte {
+	return l.raw
+}
+
+// Decode decodes the value. Each call decodes it anew.
+func (l *Lazy[V]) Decode() (*V, error) {
+	val, err := l.decode(l.raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return val, nil
+}
+
+// GetLazy returns the value stored under key without decoding it. A missing
+// key is reported as an error matching ErrNotFound.
+func (ref *DBRef[K, V]) GetLazy(key *K) (*Lazy[V], error) {
+	var raw []byte
+	ok, err := ref.getRaw(key, func(valBytes []byte) error {
+		raw = bytes.Clone(valBytes)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return &Lazy[V]{raw: raw, decode: ref.decodeVal, decodeInto: ref.decodeValInto}, nil
+}
@@ -0,0 +1,77 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestGetLazy(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada", Email: "ada@example.com"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	lazy, err := ref.GetLazy(&key)
+	if err != nil {
+		t.Fatalf("GetLazy: %v", err)
+	}
+	want, _ := json.Marshal(val)
+	if !bytes.Equal(lazy.Bytes(), want) {
+		t.Fatalf("Bytes = %s, want %s", lazy.Bytes(), want)
+	}
+	got, err := lazy.Decode()
+	if err != nil || *got != val {
+		t.Fatalf("Decode = %v, %v", got, err)
+	}
+
+	// The handle outlives the transaction it was read in.
+	val.Name = "Grace"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err = lazy.Decode()
+	if err != nil || got.Name != "Ada" {
+		t.Fatalf("Decode after an overwrite = %v, %v", got, err)
+	}
+
+	missing := "missing"
+	_, err = ref.GetLazy(&missing)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("GetLazy of a missing key returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestLazyDecodeFailure(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	raw := rawRef(t, db, "users")
+	key, garbage := "ada", []byte("{not json")
+	err := raw.Put(&key, &garbage)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	lazy, err := ref.GetLazy(&key)
+	if err != nil {
+		t.Fatalf("GetLazy: %v", err)
+	}
+	_, err = lazy.Decode()
+	if err == nil {
+		t.Fatal("Decode of invalid JSON succeeded")
+	}
+}
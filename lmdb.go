@@ -0,0 +1,771 @@
+//go:build cgo
+
+package ezdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// initialMapSize is the size of the memory map an environment is opened
+// with. The map doubles whenever a write transaction fills it.
+const initialMapSize = 64 << 20
+
+// freeDBI is the handle of LMDB's free list, whose values are lists of page
+// numbers, each starting with their length.
+const freeDBI = lmdb.DBI(0)
+
+// mainDBI is the handle of LMDB's main database, which holds the records of
+// the named databases and is open in every transaction.
+const mainDBI = lmdb.DBI(1)
+
+// lmdbBackend is the default backend, an LMDB environment. Its errors are
+// translated with translateErr, so errors.Is matches the package's sentinel
+// errors.
+type lmdbBackend struct {
+	env      *lmdb.Env
+	readOnly bool
+
+	// resize is held for reading by every transaction, and for writing while
+	// the map is resized, which LMDB only allows while the process has no
+	// transactions.
+	resize sync.RWMutex
+	// mapSize is the size of the map, so that writers that filled it
+	// together only grow it once.
+	mapSize atomic.Int64
+
+	// LMDB's database handles are shared by all transactions, and a handle
+	// may only be used by transactions that began after the one opening it
+	// ended, so handles are opened in transactions of their own, one at a
+	// time under dbiMu, and recorded in dbis along with the value of gen
+	// once they are usable.
+	dbiMu sync.Mutex
+	dbis  sync.Map // name -> lmdbDBI
+	gen   atomic.Uint64
+
+	// writes queues the Updates for the writer goroutine if the environment
+	// was opened with a batch size, see WithBatchSize.
+	writes    chan *lmdbWrite
+	batchSize int
+	writer    sync.WaitGroup
+}
+
+// lmdbDBI is an open database handle, usable by transactions that began once
+// gen reached its value.
+type lmdbDBI struct {
+	dbi lmdb.DBI
+	gen uint64
+}
+
+// lmdbWrite is an Update waiting for the writer goroutine.
+type lmdbWrite struct {
+	fn   func(txn writeTxn) error
+	done chan error
+}
+
+// dbiRequest aborts a transaction that needs a database handle that isn't
+// open yet; the transaction is run again once the handle is.
+type dbiRequest struct {
+	name  string
+	flags dbFlag
+}
+
+func (r *dbiRequest) Error() string {
+	return fmt.Sprintf("database %q is not open", r.name)
+}
+
+// openLMDB opens the LMDB environment in the directory path.
+func openLMDB(path string, o *options) (backend, error) {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create environment: %w", err)
+	}
+	err = env.SetMaxReaders(int(*o.numReaders))
+	if err == nil {
+		err = env.SetMaxDBs(int(*o.numDbs))
+	}
+	if err == nil {
+		err = env.SetMapSize(initialMapSize)
+	}
+	if err == nil {
+		err = env.Open(path, uint(o.envFlags), mode)
+	}
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("failed to open db: %w", translateErr(err))
+	}
+
+	// LMDB only sets up the key comparison of the main database once a
+	// handle is opened, and lookups of named databases in it need it.
+	err = env.View(func(txn *lmdb.Txn) error {
+		_, err := txn.OpenRoot(0)
+		return err
+	})
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("failed to open main db: %w", translateErr(err))
+	}
+
+	b := &lmdbBackend{env: env, readOnly: o.envFlags&envReadOnly != 0}
+	err = b.loadMapSize()
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	if *o.batchSize > 1 {
+		b.writes = make(chan *lmdbWrite)
+		b.batchSize = int(*o.batchSize)
+		b.writer.Add(1)
+		go b.write()
+	}
+
+	return b, nil
+}
+
+func (b *lmdbBackend) View(fn func(txn readTxn) error) error {
+	for {
+		gen := b.gen.Load()
+		b.resize.RLock()
+		err := b.env.View(func(txn *lmdb.Txn) error {
+			txn.RawRead = true
+			return fn(lmdbReadTxn{txn: txn, b: b, gen: gen})
+		})
+		b.resize.RUnlock()
+
+		retry, err := b.recover(err)
+		if !retry {
+			return err
+		}
+	}
+}
+
+func (b *lmdbBackend) Update(fn func(txn writeTxn) error) error {
+	if b.writes == nil {
+		return b.update(func(txn *lmdb.Txn, gen uint64) error {
+			return fn(newLMDBWriteTxn(txn, b, gen))
+		})
+	}
+
+	w := &lmdbWrite{fn: fn, done: make(chan error, 1)}
+	b.writes <- w
+	return <-w.done
+}
+
+// update runs op in a write transaction, growing the map and opening
+// database handles as needed.
+func (b *lmdbBackend) update(op func(txn *lmdb.Txn, gen uint64) error) error {
+	for {
+		gen := b.gen.Load()
+		b.resize.RLock()
+		size := b.mapSize.Load()
+		err := b.env.Update(func(txn *lmdb.Txn) error {
+			txn.RawRead = true
+			return op(txn, gen)
+		})
+		b.resize.RUnlock()
+
+		if errno, ok := lmdbErrno(err); ok && errno == lmdb.MapFull && !b.readOnly {
+			err = b.growMap(size)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		retry, err := b.recover(err)
+		if !retry {
+			return err
+		}
+	}
+}
+
+// recover handles the errors a transaction is run again after: another
+// process growing the map, and missing database handles.
+func (b *lmdbBackend) recover(err error) (retry bool, _ error) {
+	if errno, ok := lmdbErrno(err); ok && errno == lmdb.MapResized {
+		b.resize.Lock()
+		defer b.resize.Unlock()
+		err = b.env.SetMapSize(0)
+		if err != nil {
+			return false, fmt.Errorf("failed to adopt map size: %w", translateErr(err))
+		}
+		return true, b.loadMapSize()
+	}
+
+	var req *dbiRequest
+	if errors.As(err, &req) {
+		err = b.openDBI(req.name, req.flags)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, translateErr(err)
+}
+
+// write runs the queued Updates in transactions of up to batchSize, each in a
+// nested transaction of its own, so that one failing doesn't abort the
+// others.
+func (b *lmdbBackend) write() {
+	defer b.writer.Done()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for w := range b.writes {
+		batch := []*lmdbWrite{w}
+	collect:
+		for len(batch) < b.batchSize {
+			select {
+			case w, ok := <-b.writes:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, w)
+			default:
+				break collect
+			}
+		}
+
+		errs := make([]error, len(batch))
+		err := b.update(func(txn *lmdb.Txn, gen uint64) error {
+			for i, w := range batch {
+				errs[i] = txn.Sub(func(sub *lmdb.Txn) error {
+					sub.RawRead = true
+					return w.fn(newLMDBWriteTxn(sub, b, gen))
+				})
+				// The whole batch is run again after these.
+				var req *dbiRequest
+				if errno, ok := lmdbErrno(errs[i]); (ok && errno == lmdb.MapFull) || errors.As(errs[i], &req) {
+					return errs[i]
+				}
+			}
+			return nil
+		})
+		for i, w := range batch {
+			if err != nil {
+				w.done <- err
+				continue
+			}
+			w.done <- translateErr(errs[i])
+		}
+	}
+}
+
+// growMap doubles the size of the memory map, unless it has grown since it
+// was size.
+func (b *lmdbBackend) growMap(size int64) error {
+	b.resize.Lock()
+	defer b.resize.Unlock()
+
+	if b.mapSize.Load() != size {
+		return nil
+	}
+	err := b.env.SetMapSize(size * 2)
+	if err != nil {
+		return fmt.Errorf("failed to grow map: %w", translateErr(err))
+	}
+
+	return b.loadMapSize()
+}
+
+// ResizeMap sets the size of the memory map once the transactions in flight
+// are done, holding off new ones until it is resized.
+func (b *lmdbBackend) ResizeMap(size int64) error {
+	b.resize.Lock()
+	defer b.resize.Unlock()
+
+	err := b.env.SetMapSize(size)
+	if err != nil {
+		return fmt.Errorf("failed to resize map: %w", translateErr(err))
+	}
+
+	return b.loadMapSize()
+}
+
+// loadMapSize records the size of the memory map.
+func (b *lmdbBackend) loadMapSize() error {
+	info, err := b.env.Info()
+	if err != nil {
+		return fmt.Errorf("failed to read map size: %w", translateErr(err))
+	}
+	b.mapSize.Store(info.MapSize)
+
+	return nil
+}
+
+// handle returns the open handle of the database name if it is usable by a
+// transaction that began at gen.
+func (b *lmdbBackend) handle(name string, gen uint64) (lmdb.DBI, bool) {
+	v, ok := b.dbis.Load(name)
+	if !ok {
+		return 0, false
+	}
+	h := v.(lmdbDBI)
+	if h.gen > gen {
+		return 0, false
+	}
+
+	return h.dbi, true
+}
+
+// openDBI opens the handle of the database name in a transaction of its own,
+// creating the database if flags includes dbCreate.
+func (b *lmdbBackend) openDBI(name string, flags dbFlag) error {
+	b.dbiMu.Lock()
+	defer b.dbiMu.Unlock()
+
+	if _, ok := b.dbis.Load(name); ok {
+		return nil
+	}
+
+	var dbi lmdb.DBI
+	open := func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenDBI(name, uint(flags))
+		return err
+	}
+	var err error
+	b.resize.RLock()
+	if flags&dbCreate != 0 && !b.readOnly {
+		err = b.env.Update(open)
+	} else {
+		err = b.env.View(open)
+	}
+	b.resize.RUnlock()
+	if err != nil {
+		return translateErr(err)
+	}
+
+	b.dbis.Store(name, lmdbDBI{dbi: dbi, gen: b.gen.Add(1)})
+	return nil
+}
+
+func (b *lmdbBackend) Sync(force bool) error {
+	// A read-only environment has nothing to flush, and LMDB refuses to.
+	if b.readOnly {
+		return nil
+	}
+
+	return translateErr(b.env.Sync(force))
+}
+
+func (b *lmdbBackend) Copy(path string, compact bool) error {
+	var flags uint
+	if compact {
+		flags = lmdb.CopyCompact
+	}
+
+	return translateErr(b.env.CopyFlag(path, flags))
+}
+
+func (b *lmdbBackend) CopyTo(ctx context.Context, w io.Writer, compact bool) error {
+	var flags uint
+	if compact {
+		flags = lmdb.CopyCompact
+	}
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	// Closing r makes the copy fail with EPIPE if w fails or ctx is done
+	// first.
+	copied := make(chan error, 1)
+	go func() {
+		b.resize.RLock()
+		err := b.env.CopyFDFlag(pw.Fd(), flags)
+		b.resize.RUnlock()
+		pw.Close()
+		copied <- err
+	}()
+	_, err = io.Copy(w, &ctxReader{ctx: ctx, r: r})
+	r.Close()
+	copyErr := <-copied
+	if err != nil {
+		return fmt.Errorf("failed to write copy: %w", err)
+	}
+
+	return translateErr(copyErr)
+}
+
+func (b *lmdbBackend) ReaderCheck() (int, error) {
+	cleared, err := b.env.ReaderCheck()
+	return cleared, translateErr(err)
+}
+
+func (b *lmdbBackend) EnvStat() (envStat, error) {
+	info, err := b.env.Info()
+	if err != nil {
+		return envStat{}, fmt.Errorf("failed to read environment info: %w", translateErr(err))
+	}
+	stat, err := b.env.Stat()
+	if err != nil {
+		return envStat{}, fmt.Errorf("failed to read environment stat: %w", translateErr(err))
+	}
+
+	s := envStat{
+		PageSize:  uint64(stat.PSize),
+		MapSize:   uint64(info.MapSize),
+		LastPage:  uint64(info.LastPNO),
+		LastTxnID: uint64(info.LastTxnID),
+	}
+	s.VersionMajor, s.VersionMinor, s.VersionPatch, s.Version = lmdb.Version()
+	s.FreePages, err = b.freePages()
+	if err != nil {
+		return envStat{}, fmt.Errorf("failed to read free list: %w", err)
+	}
+	s.Readers, err = b.readers()
+	if err != nil {
+		return envStat{}, fmt.Errorf("failed to read readers: %w", err)
+	}
+
+	return s, nil
+}
+
+// freePages counts the pages on the free list.
+func (b *lmdbBackend) freePages() (n uint64, err error) {
+	b.resize.RLock()
+	defer b.resize.RUnlock()
+
+	err = b.env.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+		cursor, err := txn.OpenCursor(freeDBI)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		for {
+			_, val, err := cursor.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// The length is a native pgno_t, i.e. size_t.
+			var count uintptr
+			copy(unsafe.Slice((*byte)(unsafe.Pointer(&count)), unsafe.Sizeof(count)), val)
+			n += uint64(count)
+		}
+	})
+
+	return n, translateErr(err)
+}
+
+// readers returns the slots of the reader table in use, as listed by
+// mdb_reader_list, whose lines are the PID, the thread in hex and the ID of
+// the snapshot of each slot, or "-" for slots without a transaction.
+func (b *lmdbBackend) readers() ([]readerSlot, error) {
+	var slots []readerSlot
+	err := b.env.ReaderList(func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// The header, or the line saying there are no readers.
+			return nil
+		}
+
+		slot := readerSlot{PID: pid, TxnID: ^uint64(0)}
+		slot.TID, err = strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse reader %q: %w", line, err)
+		}
+		if fields[2] != "-" {
+			slot.TxnID, err = strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse reader %q: %w", line, err)
+			}
+		}
+		slots = append(slots, slot)
+		return nil
+	})
+	if err != nil {
+		return nil, translateErr(err)
+	}
+
+	return slots, nil
+}
+
+func (b *lmdbBackend) Close() {
+	if b.writes != nil {
+		close(b.writes)
+		b.writer.Wait()
+	}
+	b.env.Close()
+}
+
+type lmdbReadTxn struct {
+	txn *lmdb.Txn
+	b   *lmdbBackend
+	// gen is the value of b.gen when the transaction began.
+	gen   uint64
+	write bool
+}
+
+func (t lmdbReadTxn) DBRef(name string, flags dbFlag) (dbi, error) {
+	if h, ok := t.b.handle(name, t.gen); ok {
+		return dbi(h), nil
+	}
+
+	if !t.write || t.b.readOnly {
+		flags &^= dbCreate
+	}
+	if flags&dbCreate == 0 {
+		// Only ask for a handle if the database exists in this
+		// transaction's snapshot.
+		_, err := t.txn.Get(mainDBI, []byte(name))
+		if err != nil {
+			return 0, translateErr(err)
+		}
+	}
+
+	return 0, &dbiRequest{name: name, flags: flags}
+}
+
+// ListDBs returns the databases recorded in the main database, opening the
+// handles of any it has none for yet to read their flags.
+func (t lmdbReadTxn) ListDBs() ([]namedDB, error) {
+	cursor, err := t.NewCursor(dbi(mainDBI))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	var dbs []namedDB
+	key, _, err := cursor.First()
+	for ; err == nil; key, _, err = cursor.Next() {
+		name := string(key)
+		db, err := t.DBRef(name, dbFlag(0))
+		if err != nil {
+			return nil, err
+		}
+		flags, err := t.txn.Flags(lmdb.DBI(db))
+		if err != nil {
+			return nil, translateErr(err)
+		}
+		dbs = append(dbs, namedDB{name: name, flags: dbFlag(flags)})
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	return dbs, nil
+}
+
+func (t lmdbReadTxn) Get(db dbi, key []byte) ([]byte, error) {
+	val, err := t.txn.Get(lmdb.DBI(db), key)
+	return val, translateErr(err)
+}
+
+func (t lmdbReadTxn) NewCursor(db dbi) (dbCursor, error) {
+	cursor, err := t.txn.OpenCursor(lmdb.DBI(db))
+	if err != nil {
+		return nil, translateErr(err)
+	}
+
+	return lmdbCursor{cursor}, nil
+}
+
+func (t lmdbReadTxn) Stat(db dbi) (DBStat, error) {
+	stat, err := t.txn.Stat(lmdb.DBI(db))
+	if err != nil {
+		return DBStat{}, translateErr(err)
+	}
+
+	pages := stat.BranchPages + stat.LeafPages + stat.OverflowPages
+	return DBStat{
+		Entries:       stat.Entries,
+		Depth:         stat.Depth,
+		BranchPages:   stat.BranchPages,
+		LeafPages:     stat.LeafPages,
+		OverflowPages: stat.OverflowPages,
+		Size:          pages * uint64(stat.PSize),
+	}, nil
+}
+
+type lmdbWriteTxn struct {
+	lmdbReadTxn
+}
+
+// newLMDBWriteTxn returns a pointer, so that the transaction can be used as
+// a map key.
+func newLMDBWriteTxn(txn *lmdb.Txn, b *lmdbBackend, gen uint64) *lmdbWriteTxn {
+	return &lmdbWriteTxn{lmdbReadTxn{txn: txn, b: b, gen: gen, write: true}}
+}
+
+func (t *lmdbWriteTxn) Put(db dbi, key, val []byte, flags putFlag) error {
+	return translateErr(t.txn.Put(lmdb.DBI(db), key, val, uint(flags)))
+}
+
+// PutReserve stores a value of size bytes with MDB_RESERVE, and returns the
+// space LMDB reserved for it in the map.
+func (t *lmdbWriteTxn) PutReserve(db dbi, key []byte, size int, flags putFlag) ([]byte, error) {
+	dbFlags, err := t.txn.Flags(lmdb.DBI(db))
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	if dbFlags&lmdb.DupSort != 0 {
+		return nil, fmt.Errorf("failed to reserve value of dupsort db: %w", ErrUnsupported)
+	}
+
+	val, err := t.txn.PutReserve(lmdb.DBI(db), key, size, uint(flags))
+	if err != nil {
+		return nil, translateErr(err)
+	}
+
+	return val, nil
+}
+
+func (t *lmdbWriteTxn) Delete(db dbi, key, val []byte) error {
+	if val != nil {
+		return translateErr(t.txn.Del(lmdb.DBI(db), key, val))
+	}
+
+	// lmdb-go passes an empty value rather than none, which mdb_del looks
+	// for among the values of a DUPSORT key, so those are deleted through a
+	// cursor.
+	flags, err := t.txn.Flags(lmdb.DBI(db))
+	if err != nil {
+		return translateErr(err)
+	}
+	if flags&lmdb.DupSort == 0 {
+		return translateErr(t.txn.Del(lmdb.DBI(db), key, nil))
+	}
+
+	cursor, err := t.txn.OpenCursor(lmdb.DBI(db))
+	if err != nil {
+		return translateErr(err)
+	}
+	defer cursor.Close()
+
+	_, _, err = cursor.Get(key, nil, lmdb.Set)
+	if err != nil {
+		return translateErr(err)
+	}
+
+	return translateErr(cursor.Del(lmdb.NoDupData))
+}
+
+func (t *lmdbWriteTxn) Empty(db dbi) error {
+	return translateErr(t.txn.Drop(lmdb.DBI(db), false))
+}
+
+func (t *lmdbWriteTxn) Drop(db dbi) error {
+	// mdb_drop closes the handle right away, whether or not the transaction
+	// commits.
+	t.b.dbiMu.Lock()
+	defer t.b.dbiMu.Unlock()
+	t.b.dbis.Range(func(name, v any) bool {
+		if v.(lmdbDBI).dbi == lmdb.DBI(db) {
+			t.b.dbis.Delete(name)
+			return false
+		}
+		return true
+	})
+
+	return translateErr(t.txn.Drop(lmdb.DBI(db), true))
+}
+
+type lmdbCursor struct {
+	c *lmdb.Cursor
+}
+
+func (c lmdbCursor) get(op uint) ([]byte, []byte, error) {
+	key, val, err := c.c.Get(nil, nil, op)
+	return key, val, translateErr(err)
+}
+
+func (c lmdbCursor) First() ([]byte, []byte, error) {
+	return c.get(lmdb.First)
+}
+
+func (c lmdbCursor) Last() ([]byte, []byte, error) {
+	return c.get(lmdb.Last)
+}
+
+func (c lmdbCursor) Next() ([]byte, []byte, error) {
+	return c.get(lmdb.Next)
+}
+
+func (c lmdbCursor) Prev() ([]byte, []byte, error) {
+	return c.get(lmdb.Prev)
+}
+
+func (c lmdbCursor) NextInSameKey() ([]byte, []byte, error) {
+	return c.get(lmdb.NextDup)
+}
+
+func (c lmdbCursor) Count() (uint64, error) {
+	n, err := c.c.Count()
+	return n, translateErr(err)
+}
+
+func (c lmdbCursor) SeekExactKey(key []byte) ([]byte, error) {
+	_, val, err := c.c.Get(key, nil, lmdb.SetKey)
+	return val, translateErr(err)
+}
+
+func (c lmdbCursor) SeekGreaterThanOrEqualKey(key []byte) ([]byte, []byte, error) {
+	k, val, err := c.c.Get(key, nil, lmdb.SetRange)
+	return k, val, translateErr(err)
+}
+
+func (c lmdbCursor) Close() {
+	c.c.Close()
+}
+
+// lmdbErrno returns the LMDB error code in err's chain, if any.
+func lmdbErrno(err error) (lmdb.Errno, bool) {
+	var opErr *lmdb.OpError
+	if !errors.As(err, &opErr) {
+		return 0, false
+	}
+	errno, ok := opErr.Errno.(lmdb.Errno)
+	return errno, ok
+}
+
+// translateErr makes errors.Is match the sentinel error corresponding to an
+// LMDB error anywhere in err's chain. The LMDB error stays in the chain.
+func translateErr(err error) error {
+	errno, ok := lmdbErrno(err)
+	if !ok {
+		return err
+	}
+	// Errors returned through a backend's transactions are translated
+	// already.
+	var translated *sentinelError
+	if errors.As(err, &translated) {
+		return err
+	}
+
+	var sentinel error
+	switch errno {
+	case lmdb.NotFound:
+		sentinel = ErrNotFound
+	case lmdb.KeyExist:
+		sentinel = ErrKeyExists
+	case lmdb.MapFull:
+		sentinel = ErrMapFull
+	case lmdb.TxnFull:
+		sentinel = ErrTxnTooBig
+	default:
+		return err
+	}
+
+	return &sentinelError{err: err, sentinel: sentinel}
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package ezdb
+
+import "fmt"
+
+// openLMDB fails in builds without cgo, which LMDB needs; use NewBolt or
+// NewMemory in those.
+func openLMDB(path string, o *options) (backend, error) {
+	return nil, fmt.Errorf("failed to open db: LMDB requires cgo: %w", ErrUnsupported)
+}
@@ -0,0 +1,84 @@
+//go:build cgo
+
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestTranslateErr(t *testing.T) {
+	for _, tc := range []struct {
+		errno lmdb.Errno
+		want  error
+	}{
+		{lmdb.NotFound, ErrNotFound},
+		{lmdb.KeyExist, ErrKeyExists},
+		{lmdb.MapFull, ErrMapFull},
+		{lmdb.TxnFull, ErrTxnTooBig},
+	} {
+		opErr := &lmdb.OpError{Op: "mdb_op", Errno: tc.errno}
+		err := translateErr(fmt.Errorf("failed to op: %w", opErr))
+		if !errors.Is(err, tc.want) {
+			t.Errorf("translateErr(%v) = %v, want a match of %v", opErr, err, tc.want)
+		}
+		var got *lmdb.OpError
+		if !errors.As(err, &got) || got != opErr {
+			t.Errorf("translateErr(%v) dropped the LMDB error", opErr)
+		}
+		if err.Error() != "failed to op: "+opErr.Error() {
+			t.Errorf("translateErr changed the message to %q", err)
+		}
+		if again := translateErr(err); again != err {
+			t.Errorf("translateErr of a translated error = %v", again)
+		}
+	}
+
+	other := &lmdb.OpError{Op: "mdb_op", Errno: lmdb.Corrupted}
+	if err := translateErr(other); err != error(other) {
+		t.Errorf("translateErr(%v) = %v, want it unchanged", other, err)
+	}
+	if err := translateErr(nil); err != nil {
+		t.Errorf("translateErr(nil) = %v", err)
+	}
+}
+
+func TestLMDBBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T, dir string) *Client {
+		db, err := New(dir, WithNumDBs(8))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		err = db.Init()
+		if err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func TestWithNoReadahead(t *testing.T) {
+	flags, err := lmdbEnv(t, newTestClient(t, WithNoReadahead())).env.Flags()
+	if err != nil || flags&lmdb.NoReadahead == 0 {
+		t.Fatalf("environment flags = %#x, %v, want MDB_NORDAHEAD", flags, err)
+	}
+	flags, err = lmdbEnv(t, newTestClient(t)).env.Flags()
+	if err != nil || flags&lmdb.NoReadahead != 0 {
+		t.Fatalf("environment flags by default = %#x, %v", flags, err)
+	}
+
+	// The other engines ignore it.
+	db, err := NewMemory(WithNoReadahead())
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	db.Close()
+}
@@ -0,0 +1,187 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+)
+
+// Restores and replicas check that the files they receive are complete LMDB
+// data files before LMDB opens them, so they read them straight from the
+// file. The on-disk layout below is the one used by LMDB 0.9 on 64-bit
+// little-endian hosts; openDataFile refuses other hosts.
+
+const (
+	dataFileName = "data.mdb"
+
+	metaMagic   = 0xBEEFC0DE
+	pageHdrSize = 16
+	dbRecSize   = 48
+
+	// Offsets into a meta page, relative to the start of the page.
+	metaOffMagic   = pageHdrSize + 0
+	metaOffVersion = pageHdrSize + 4
+	metaOffMapSize = pageHdrSize + 16
+	metaOffFreeDB  = pageHdrSize + 24
+	metaOffMainDB  = metaOffFreeDB + dbRecSize
+	metaOffLastPg  = metaOffMainDB + dbRecSize
+	metaOffTxnID   = metaOffLastPg + 8
+	metaSize       = metaOffTxnID + 8
+)
+
+var le = binary.LittleEndian
+
+// dbRecord is LMDB's MDB_db: the B+tree header of a (sub-)database.
+type dbRecord struct {
+	PageSize      uint32 // md_pad, only meaningful for the free DB in a meta page
+	Flags         uint16
+	Depth         uint16
+	BranchPages   uint64
+	LeafPages     uint64
+	OverflowPages uint64
+	Entries       uint64
+	Root          uint64
+}
+
+func parseDBRecord(b []byte) (dbRecord, error) {
+	if len(b) < dbRecSize {
+		return dbRecord{}, fmt.Errorf("short database record: %d bytes", len(b))
+	}
+
+	return dbRecord{
+		PageSize:      le.Uint32(b[0:]),
+		Flags:         le.Uint16(b[4:]),
+		Depth:         le.Uint16(b[6:]),
+		BranchPages:   le.Uint64(b[8:]),
+		LeafPages:     le.Uint64(b[16:]),
+		OverflowPages: le.Uint64(b[24:]),
+		Entries:       le.Uint64(b[32:]),
+		Root:          le.Uint64(b[40:]),
+	}, nil
+}
+
+// metaPage is LMDB's MDB_meta.
+type metaPage struct {
+	Version uint32
+	MapSize uint64
+	FreeDB  dbRecord
+	MainDB  dbRecord
+	LastPg  uint64
+	TxnID   uint64
+}
+
+func parseMeta(page []byte) (metaPage, error) {
+	if len(page) < metaSize {
+		return metaPage{}, fmt.Errorf("short meta page: %d bytes", len(page))
+	}
+	if le.Uint32(page[metaOffMagic:]) != metaMagic {
+		return metaPage{}, errors.New("not an LMDB data file")
+	}
+
+	freeDB, err := parseDBRecord(page[metaOffFreeDB:])
+	if err != nil {
+		return metaPage{}, err
+	}
+	mainDB, err := parseDBRecord(page[metaOffMainDB:])
+	if err != nil {
+		return metaPage{}, err
+	}
+
+	return metaPage{
+		Version: le.Uint32(page[metaOffVersion:]),
+		MapSize: le.Uint64(page[metaOffMapSize:]),
+		FreeDB:  freeDB,
+		MainDB:  mainDB,
+		LastPg:  le.Uint64(page[metaOffLastPg:]),
+		TxnID:   le.Uint64(page[metaOffTxnID:]),
+	}, nil
+}
+
+// dataFile is a read-only view of an LMDB data file.
+type dataFile struct {
+	f        *os.File
+	pageSize uint64
+	meta     metaPage
+}
+
+// nativeLayout reports whether the host is 64-bit little-endian, so that its
+// data files have the layout parsed here.
+func nativeLayout() bool {
+	one := uint16(1)
+	return unsafe.Sizeof(uintptr(0)) == 8 && *(*byte)(unsafe.Pointer(&one)) == 1
+}
+
+// openDataFile opens the data file in dir and reads the most recent of its
+// two meta pages.
+func openDataFile(dir string) (*dataFile, error) {
+	if !nativeLayout() {
+		return nil, fmt.Errorf("data files of %s hosts can't be read: %w", runtime.GOARCH, ErrUnsupported)
+	}
+
+	f, err := os.Open(filepath.Join(dir, dataFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	df := &dataFile{f: f}
+	err = df.readMeta()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return df, nil
+}
+
+func (df *dataFile) Close() error {
+	return df.f.Close()
+}
+
+func (df *dataFile) readMeta() error {
+	buf := make([]byte, metaSize)
+	_, err := df.f.ReadAt(buf, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read meta page: %w", err)
+	}
+
+	meta0, err := parseMeta(buf)
+	if err != nil {
+		return err
+	}
+	df.pageSize = uint64(meta0.FreeDB.PageSize)
+	if df.pageSize == 0 {
+		return errors.New("invalid page size in meta page")
+	}
+
+	_, err = df.f.ReadAt(buf, int64(df.pageSize))
+	if err != nil {
+		return fmt.Errorf("failed to read meta page: %w", err)
+	}
+	meta1, err := parseMeta(buf)
+	if err != nil {
+		return err
+	}
+
+	df.meta = meta0
+	if meta1.TxnID > meta0.TxnID {
+		df.meta = meta1
+	}
+
+	return nil
+}
+
+// checkSize checks that a data file of size bytes holds every page up to the
+// last one in use, as copies of an environment do, so it wasn't cut off after
+// its meta pages.
+func (df *dataFile) checkSize(size int64) error {
+	want := (df.meta.LastPg + 1) * df.pageSize
+	if uint64(size) < want {
+		return fmt.Errorf("data file is truncated: %d bytes of %d", size, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package ezdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns an initialized LMDB Client in t.TempDir(), closed
+// when t finishes. The package's own tests can't use testutil, which imports
+// it.
+func newTestClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+
+	opts = append([]Option{WithNumDBs(512), WithNumReaders(32)}, opts...)
+	db, err := New(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// lmdbEnv returns the LMDB backend of db.
+func lmdbEnv(t *testing.T, db *Client) *lmdbBackend {
+	t.Helper()
+
+	env, release, err := db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	b, ok := backendAs[*lmdbBackend](env)
+	if !ok {
+		t.Fatalf("backend is %T, not LMDB", env)
+	}
+
+	return b
+}
+
+func TestDataFileMatchesEnvironment(t *testing.T) {
+	db := newTestClient(t)
+
+	// Enough databases with long names that the main database needs branch
+	// pages.
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("ref-%03d-%s", i, strings.Repeat("x", 40))
+		_, err := NewRef[string, string](name, db)
+		if err != nil {
+			t.Fatalf("NewRef(%s): %v", name, err)
+		}
+	}
+
+	df, err := openDataFile(db.envPath())
+	if err != nil {
+		t.Fatalf("openDataFile: %v", err)
+	}
+	defer df.Close()
+	info, err := lmdbEnv(t, db).env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.meta.TxnID != uint64(info.LastTxnID) {
+		t.Errorf("meta TxnID = %d, want %d", df.meta.TxnID, info.LastTxnID)
+	}
+	if df.meta.LastPg != uint64(info.LastPNO) {
+		t.Errorf("meta LastPg = %d, want %d", df.meta.LastPg, info.LastPNO)
+	}
+	if df.meta.MapSize == 0 || df.meta.MapSize > uint64(info.MapSize) {
+		t.Errorf("meta MapSize = %d, want at most %d", df.meta.MapSize, info.MapSize)
+	}
+	if df.pageSize != uint64(os.Getpagesize()) {
+		t.Errorf("page size = %d, want %d", df.pageSize, os.Getpagesize())
+	}
+	if df.meta.MainDB.Depth < 2 {
+		t.Errorf("main db depth = %d, want branch pages", df.meta.MainDB.Depth)
+	}
+	err = df.checkSize(int64((df.meta.LastPg + 1) * df.pageSize))
+	if err != nil {
+		t.Errorf("checkSize of a complete file: %v", err)
+	}
+	err = df.checkSize(int64(df.meta.LastPg * df.pageSize))
+	if err == nil {
+		t.Error("checkSize of a truncated file succeeded")
+	}
+}
+
+func TestOpenDataFileRejectsOtherFiles(t *testing.T) {
+	page := make([]byte, 2*os.Getpagesize())
+	validMeta := func() []byte {
+		b := append([]byte(nil), page...)
+		le.PutUint32(b[metaOffMagic:], metaMagic)
+		le.PutUint32(b[metaOffFreeDB:], uint32(os.Getpagesize()))
+		return b
+	}
+	zeroPageSize := validMeta()
+	le.PutUint32(zeroPageSize[metaOffFreeDB:], 0)
+	badSecondMeta := validMeta()[:os.Getpagesize()+8]
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "failed to read meta page"},
+		{"garbage", []byte(strings.Repeat("not lmdb ", 1000)), "not an LMDB data file"},
+		{"zero page size", zeroPageSize, "invalid page size"},
+		{"short second meta", badSecondMeta, "failed to read meta page"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, dataFileName), tc.data, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			df, err := openDataFile(dir)
+			if err == nil {
+				df.Close()
+				t.Fatal("openDataFile succeeded")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("openDataFile returned %q, want %q", err, tc.want)
+			}
+		})
+	}
+
+	_, err := openDataFile(t.TempDir())
+	if !os.IsNotExist(err) {
+		t.Fatalf("openDataFile of an empty directory returned %v, want a missing file", err)
+	}
+}
@@ -0,0 +1,82 @@
+package ezdb
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// WithOpLogging logs the Gets, Puts and Deletes of the Client's DBRefs to the
+// logger given with WithLogger, with the operation, DBRef, latency, size in
+// bytes and error. Successful operations are logged at level, and only one in
+// every sampleEvery of them, so busy services can keep the volume down; zero
+// and one log every operation. Failed operations are always logged at error
+// level.
+func WithOpLogging(level zerolog.Level, sampleEvery uint) Option {
+	return func(option *options) error {
+		if sampleEvery == 0 {
+			sampleEvery = 1
+		}
+		option.opLog = &opLogOptions{level: level, sampleEvery: uint64(sampleEvery)}
+		return nil
+	}
+}
+
+type opLogOptions struct {
+	level       zerolog.Level
+	sampleEvery uint64
+
+	// seen counts successful operations, to pick the ones to sample.
+	seen atomic.Uint64
+}
+
+// WithSlowOpThreshold logs a warning with the operation, DBRef, key size and
+// duration of every Get, Put and Delete that takes longer than threshold, to
+// the logger given with WithLogger, and keeps the last of them for
+// RecentSlowOps and DebugHandler. A growing number of slow operations is an
+// early sign of the map filling up or of page cache pressure.
+func WithSlowOpThreshold(threshold time.Duration) Option {
+	return func(option *options) error {
+		option.slowOpThreshold = threshold
+		return nil
+	}
+}
+
+// finishOp records an operation of the DBRef ref timed by t, with a key of
+// keySize bytes, that read or wrote n bytes in the Client's metrics and logs.
+func (db *Client) finishOp(ref, op string, t *opTimer, keySize, n int, err error) {
+	db.metrics.recordOp(ref, op, t, n, err)
+
+	if threshold := db.options.slowOpThreshold; threshold > 0 {
+		if d := time.Since(t.start); d > threshold {
+			db.slowOps.add(SlowOp{Time: t.start, DBRef: ref, Op: op, KeySize: keySize, Duration: d})
+			db.options.log.Warn().
+				Str("op", op).
+				Str("dbref", ref).
+				Int("key_size", keySize).
+				Dur("duration", d).
+				Msg("slow ezdb operation")
+		}
+	}
+
+	l := db.options.opLog
+	if l == nil {
+		return
+	}
+
+	var event *zerolog.Event
+	if err != nil {
+		event = db.options.log.Error().Err(err)
+	} else {
+		if (l.seen.Add(1)-1)%l.sampleEvery != 0 {
+			return
+		}
+		event = db.options.log.WithLevel(l.level)
+	}
+	event.Str("op", op).
+		Str("dbref", ref).
+		Dur("latency", time.Since(t.start)).
+		Int("size", n).
+		Msg("ezdb operation")
+}
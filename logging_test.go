@@ -0,0 +1,120 @@
+package ezdb_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+	"github.com/rs/zerolog"
+)
+
+// logLine is a line logged by a Client.
+type logLine struct {
+	Level   string
+	Message string
+	Op      string
+	DBRef   string
+	Size    int
+	KeySize int `json:"key_size"`
+	Error   string
+	// Duration is in milliseconds.
+	Duration float64
+}
+
+// logLines returns the lines in buf with the given message.
+func logLines(t *testing.T, buf *bytes.Buffer, msg string) []logLine {
+	t.Helper()
+
+	var lines []logLine
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		var line logLine
+		err := json.Unmarshal(scanner.Bytes(), &line)
+		if err != nil {
+			t.Fatalf("logged %s: %v", scanner.Text(), err)
+		}
+		if line.Message == msg {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func TestOpLogging(t *testing.T) {
+	var buf bytes.Buffer
+	db := testutil.NewTempClient(t, ezdb.WithLogger(zerolog.New(&buf)), ezdb.WithOpLogging(zerolog.DebugLevel, 2))
+	ref, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// Every other successful operation is logged, and every failed one.
+	key, val := "k", []byte("value")
+	for i := 0; i < 4; i++ {
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	long := strings.Repeat("k", 512)
+	_, err = ref.Get(&long)
+	if err == nil {
+		t.Fatal("Get of a long key succeeded")
+	}
+
+	lines := logLines(t, &buf, "ezdb operation")
+	if len(lines) != 3 {
+		t.Fatalf("logged %d operations, want 3:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines[:2] {
+		if line.Level != "debug" || line.Op != "put" || line.DBRef != "ref" || line.Size != 6 || line.Error != "" {
+			t.Errorf("logged %+v for a Put", line)
+		}
+	}
+	if line := lines[2]; line.Level != "error" || line.Op != "get" || line.Error == "" {
+		t.Errorf("logged %+v for a failed Get", line)
+	}
+}
+
+func TestSlowOpThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	db := testutil.NewTempClient(t, ezdb.WithLogger(zerolog.New(&buf)), ezdb.WithSlowOpThreshold(time.Nanosecond))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "key", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	lines := logLines(t, &buf, "slow ezdb operation")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d slow operations, want 1:\n%s", len(lines), buf.String())
+	}
+	if line := lines[0]; line.Level != "warn" || line.Op != "put" || line.DBRef != "ref" || line.KeySize != 3 || line.Duration <= 0 {
+		t.Fatalf("logged %+v for a slow Put", line)
+	}
+
+	// Without a threshold, nothing is slow.
+	buf.Reset()
+	fast := testutil.NewTempClient(t, ezdb.WithLogger(zerolog.New(&buf)))
+	ref, err = ezdb.NewDBRef[string, string](fast, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if lines := logLines(t, &buf, "slow ezdb operation"); len(lines) != 0 {
+		t.Fatalf("logged slow operations without a threshold: %+v", lines)
+	}
+}
@@ -0,0 +1,252 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WithMaxEntries caps the DBRef at maxEntries entries, turning it into a
+// persistent LRU cache: once a Put adds an entry beyond the cap, the least
+// recently used entries are deleted in the same transaction. Puts and reads
+// of a value count as uses, so with the cap set every read also runs a small
+// write transaction. The access order is kept in a companion named database,
+// which counts towards WithNumDBs.
+func WithMaxEntries(maxEntries uint) RefOption {
+	return func(option *refOptions) error {
+		if maxEntries == 0 {
+			return errors.New("max entries must be positive")
+		}
+		option.maxEntries = &maxEntries
+		return nil
+	}
+}
+
+// tracksAccess reports whether the DBRef keeps an access order, for
+// WithMaxEntries or a quota that evicts entries.
+func (o *refOptions) tracksAccess() bool {
+	return o.maxEntries != nil || (o.quota != nil && o.quota.policy != QuotaReject)
+}
+
+// touchesOnRead reports whether reads of the DBRef count as uses in its
+// access order; with QuotaEvictOldest only writes do.
+func (o *refOptions) touchesOnRead() bool {
+	return o.maxEntries != nil || (o.quota != nil && o.quota.policy == QuotaEvictLRU)
+}
+
+// The access order database holds, for every entry of the DBRef, a key
+// lruKeyPrefix+key mapping it to its last access tick and a key
+// lruTickPrefix+tick mapping the tick back to it, plus the entry count and
+// the last tick handed out.
+var (
+	lruKeyPrefix  = []byte("k")
+	lruTickPrefix = []byte("t")
+	lruCountKey   = []byte("n")
+	lruClockKey   = []byte("c")
+)
+
+func (ref *DBRef[K, V]) lruDBName() string {
+	return ref.id + ".lru"
+}
+
+// initLRU opens the access order database, or creates it and records the
+// entries already in ref, in key order, if it is empty.
+func (ref *DBRef[K, V]) initLRU() error {
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(ref.lruDBName(), dbFlag(0))
+			return err
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		// As with indexes, the transaction may run again once the database
+		// has been created, so only an empty one is filled.
+		lruRef, err := txn.DBRef(ref.lruDBName(), dbCreate)
+		if err != nil {
+			return err
+		}
+		empty, err := isEmptyInTxn(txn, lruRef)
+		if err != nil || !empty {
+			return err
+		}
+
+		var keys [][]byte
+		err = scanKeys(txn, ref.id, func(key []byte) error {
+			keys = append(keys, bytes.Clone(key))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			err = ref.touchInTxn(txn, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// touchInTxn marks the entry under keyBytes as the most recently used one
+// and evicts the least recently used entries beyond the cap, if any.
+func (ref *DBRef[K, V]) touchInTxn(txn writeTxn, keyBytes []byte) error {
+	lruRef, err := txn.DBRef(ref.lruDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	count, err := readUint64(txn, lruRef, lruCountKey)
+	if err != nil {
+		return err
+	}
+	clock, err := readUint64(txn, lruRef, lruClockKey)
+	if err != nil {
+		return err
+	}
+
+	entryKey := prefixed(lruKeyPrefix, keyBytes)
+	oldTick, err := txn.Get(lruRef, entryKey)
+	switch {
+	case err == nil:
+		err = txn.Delete(lruRef, prefixed(lruTickPrefix, oldTick), nil)
+		if err != nil {
+			return fmt.Errorf("failed to update access order: %w", err)
+		}
+	case errors.Is(err, ErrNotFound):
+		count++
+	default:
+		return fmt.Errorf("failed to read access order: %w", err)
+	}
+
+	clock++
+	tick := binary.BigEndian.AppendUint64(nil, clock)
+	for _, kv := range [][2][]byte{
+		{entryKey, tick},
+		{prefixed(lruTickPrefix, tick), keyBytes},
+		{lruCountKey, binary.BigEndian.AppendUint64(nil, count)},
+		{lruClockKey, tick},
+	} {
+		err = txn.Put(lruRef, kv[0], kv[1], putFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to update access order: %w", err)
+		}
+	}
+
+	if ref.options.maxEntries == nil || count <= uint64(*ref.options.maxEntries) {
+		return nil
+	}
+
+	return ref.evictInTxn(txn, lruRef, count-uint64(*ref.options.maxEntries))
+}
+
+// evictInTxn deletes the n least recently used entries.
+func (ref *DBRef[K, V]) evictInTxn(txn writeTxn, lruRef dbi, n uint64) error {
+	dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	for ; n > 0; n-- {
+		cursor, err := txn.NewCursor(lruRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		_, keyBytes, err := cursor.SeekGreaterThanOrEqualKey(lruTickPrefix)
+		if err == nil {
+			keyBytes = bytes.Clone(keyBytes)
+		}
+		cursor.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read access order: %w", err)
+		}
+
+		err = ref.deleteInTxn(txn, dbRef, keyBytes)
+		if err != nil {
+			return fmt.Errorf("failed to evict entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// forgetInTxn removes the access order of the deleted entry under keyBytes.
+func (ref *DBRef[K, V]) forgetInTxn(txn writeTxn, keyBytes []byte) error {
+	lruRef, err := txn.DBRef(ref.lruDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	entryKey := prefixed(lruKeyPrefix, keyBytes)
+	tick, err := txn.Get(lruRef, entryKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read access order: %w", err)
+	}
+	tickKey := prefixed(lruTickPrefix, tick)
+
+	count, err := readUint64(txn, lruRef, lruCountKey)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range [][]byte{entryKey, tickKey} {
+		err = txn.Delete(lruRef, key, nil)
+		if err != nil {
+			return fmt.Errorf("failed to update access order: %w", err)
+		}
+	}
+	err = txn.Put(lruRef, lruCountKey, binary.BigEndian.AppendUint64(nil, count-1), putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to update access order: %w", err)
+	}
+
+	return nil
+}
+
+// touch marks the entry under keyBytes as used after a read.
+func (ref *DBRef[K, V]) touch(keyBytes []byte) error {
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		// The entry may have been deleted or evicted since it was read.
+		_, err = txn.Get(dbRef, keyBytes)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get key: %w", err)
+		}
+
+		return ref.touchInTxn(txn, keyBytes)
+	})
+}
+
+// readUint64 reads a big-endian uint64 stored under key, or zero if there is
+// none.
+func readUint64(txn readTxn, dbRef dbi, key []byte) (uint64, error) {
+	valBytes, err := txn.Get(dbRef, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to read %q: %w", key, err)
+	case len(valBytes) != 8:
+		return 0, fmt.Errorf("corrupt value under %q", key)
+	}
+
+	return binary.BigEndian.Uint64(valBytes), nil
+}
+
+// prefixed returns a new slice holding prefix followed by b.
+func prefixed(prefix, b []byte) []byte {
+	return append(bytes.Clone(prefix), b...)
+}
@@ -0,0 +1,98 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// has reports whether ref has an entry under key.
+func has(t *testing.T, ref *ezdb.DBRef[string, string], key string) bool {
+	t.Helper()
+
+	_, err := ref.Get(&key)
+	if errors.Is(err, ezdb.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	return true
+}
+
+func TestMaxEntries(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "cache", ezdb.WithMaxEntries(3))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 3)
+
+	// Reading k0000 makes k0001 the least recently used entry.
+	if !has(t, ref, "k0000") {
+		t.Fatal("k0000 is missing")
+	}
+	key, val := "k0003", "v3"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for key, want := range map[string]bool{"k0000": true, "k0001": false, "k0002": true, "k0003": true} {
+		if has(t, ref, key) != want {
+			t.Errorf("entry %s kept = %t, want %t", key, !want, want)
+		}
+	}
+
+	// Deleted entries free up their place.
+	key = "k0002"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	key, val = "k0004", "v4"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for _, key := range []string{"k0000", "k0003", "k0004"} {
+		if !has(t, ref, key) {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+
+	_, err = ezdb.NewDBRef[string, string](db, "zero", ezdb.WithMaxEntries(0))
+	if err == nil {
+		t.Fatal("NewDBRef with WithMaxEntries(0) succeeded")
+	}
+}
+
+func TestMaxEntriesOfExistingDB(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("cache", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 5)
+
+	// The entries already stored count towards the cap, oldest key first.
+	capped, err := ezdb.NewDBRef[string, string](db, "cache", ezdb.WithMaxEntries(5))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "k0005", "v5"
+	err = capped.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if has(t, capped, "k0000") {
+		t.Fatal("k0000 wasn't evicted")
+	}
+	for _, key := range []string{"k0001", "k0004", "k0005"} {
+		if !has(t, capped, key) {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+}
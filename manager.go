@@ -0,0 +1,271 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ManagerOptions configure a Manager.
+type ManagerOptions struct {
+	// Dir holds the environments, each in the subdirectory named after it.
+	Dir string
+	// Options are passed to New for every environment.
+	Options []Option
+	// MaxOpen is the number of environments kept open at once; beyond it
+	// the least recently used one not in use is closed. Zero means no limit.
+	MaxOpen int
+	// IdleTimeout closes environments that haven't been used for that long.
+	// Zero means never.
+	IdleTimeout time.Duration
+}
+
+// Manager opens and supervises many environments of the same layout, such as
+// one per tenant, by name. Environments are opened on first use and closed
+// again when idle, see ManagerOptions; a closed one is reopened on its next
+// use, and DBRefs created on its Client keep working once it is, so callers
+// can keep them, e.g. in a map by name.
+type Manager struct {
+	opts ManagerOptions
+
+	mu      sync.Mutex
+	clients map[string]*managedClient
+	closed  bool
+	opened  uint64
+	evicted uint64
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// managedClient is an environment of a Manager.
+type managedClient struct {
+	db *Client
+	// open reports whether db is open, pins counts the calls of Use
+	// running on it and lastUsed is when the last one started.
+	open     bool
+	pins     int
+	lastUsed time.Time
+}
+
+// NewManager returns a Manager of the environments in opts.Dir. It opens
+// none of them until they are used.
+func NewManager(opts ManagerOptions) (*Manager, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("manager directory must be set")
+	}
+	if opts.MaxOpen < 0 || opts.IdleTimeout < 0 {
+		return nil, errors.New("manager limits must not be negative")
+	}
+
+	m := &Manager{opts: opts, clients: make(map[string]*managedClient), stop: make(chan struct{})}
+	if opts.IdleTimeout > 0 {
+		m.wg.Add(1)
+		go m.closeIdle()
+	}
+
+	return m, nil
+}
+
+// Use calls fn with the Client of the environment name, opening it if
+// needed, and returns fn's error. The environment isn't closed while fn
+// runs; fn must not keep using the Client after it returns, except through
+// further calls of Use. Names must be valid directory names without path
+// separators.
+func (m *Manager) Use(name string, fn func(db *Client) error) error {
+	mc, err := m.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer m.release(mc)
+
+	return fn(mc.db)
+}
+
+// acquire returns the open environment name and pins it.
+func (m *Manager) acquire(name string) (*managedClient, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid environment name %q", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	mc, ok := m.clients[name]
+	if !ok {
+		db, err := New(filepath.Join(m.opts.Dir, name), m.opts.Options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client: %w", err)
+		}
+		mc = &managedClient{db: db}
+		m.clients[name] = mc
+	}
+
+	if !mc.open {
+		// Reopen is Init for a Client that was never opened.
+		err := mc.db.Reopen()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		mc.open = true
+		m.opened++
+	}
+	mc.pins++
+	mc.lastUsed = time.Now()
+
+	m.evictLocked()
+	return mc, nil
+}
+
+// release unpins mc.
+func (m *Manager) release(mc *managedClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mc.pins--
+	m.evictLocked()
+}
+
+// evictLocked closes the least recently used environments not in use until
+// at most MaxOpen are open. The caller must hold m.mu.
+func (m *Manager) evictLocked() {
+	if m.opts.MaxOpen == 0 {
+		return
+	}
+
+	for {
+		var open int
+		var lru *managedClient
+		for _, mc := range m.clients {
+			if !mc.open {
+				continue
+			}
+			open++
+			if mc.pins == 0 && (lru == nil || mc.lastUsed.Before(lru.lastUsed)) {
+				lru = mc
+			}
+		}
+		if open <= m.opts.MaxOpen || lru == nil {
+			return
+		}
+
+		m.closeLocked(lru)
+	}
+}
+
+// closeLocked closes mc. The caller must hold m.mu.
+func (m *Manager) closeLocked(mc *managedClient) {
+	err := mc.db.Close()
+	if err != nil && !errors.Is(err, ErrClosed) {
+		mc.db.options.log.Error().Err(err).Str("path", mc.db.envPath()).Msg("failed to close idle environment")
+	}
+	mc.open = false
+	m.evicted++
+}
+
+// closeIdle closes the environments idle for longer than IdleTimeout until
+// the Manager is closed.
+func (m *Manager) closeIdle() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.opts.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		for _, mc := range m.clients {
+			if mc.open && mc.pins == 0 && time.Since(mc.lastUsed) > m.opts.IdleTimeout {
+				m.closeLocked(mc)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// ManagerStats describes the environments of a Manager.
+type ManagerStats struct {
+	// Known is the number of environments used since the Manager was
+	// created, and Open the number of them open now.
+	Known, Open int
+	// Opened counts the times an environment was opened, and Evicted the
+	// times one was closed for being idle or beyond MaxOpen.
+	Opened, Evicted uint64
+	// DataBytes is the size of the data of the open environments, as the
+	// sum of UsedPages times PageSize of their Stats.
+	DataBytes uint64
+	// Clients are the Stats of the open environments by name. Environments
+	// whose storage engine doesn't report Stats are missing.
+	Clients map[string]Stats
+}
+
+// Stats returns statistics aggregated over the environments of m. Closed
+// environments aren't opened for it.
+func (m *Manager) Stats() (ManagerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := ManagerStats{
+		Known:   len(m.clients),
+		Opened:  m.opened,
+		Evicted: m.evicted,
+		Clients: make(map[string]Stats),
+	}
+	for name, mc := range m.clients {
+		if !mc.open {
+			continue
+		}
+		stats.Open++
+
+		s, err := mc.db.Stats()
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			return ManagerStats{}, fmt.Errorf("failed to get stats of %s: %w", name, err)
+		}
+		stats.Clients[name] = s
+		stats.DataBytes += s.UsedPages * s.PageSize
+	}
+
+	return stats, nil
+}
+
+// Close closes all open environments and makes further calls of Use fail
+// with ErrClosed, returning the first error.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.closed = true
+	close(m.stop)
+
+	var firstErr error
+	for name, mc := range m.clients {
+		if !mc.open {
+			continue
+		}
+		err := mc.db.Close()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close %s: %w", name, err)
+		}
+		mc.open = false
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,174 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// newManager returns a Manager of environments in a temporary directory and
+// closes it after the test.
+func newManager(t *testing.T, opts ezdb.ManagerOptions) *ezdb.Manager {
+	t.Helper()
+
+	opts.Dir = t.TempDir()
+	m, err := ezdb.NewManager(opts)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// managerStats returns the Stats of m.
+func managerStats(t *testing.T, m *ezdb.Manager) ezdb.ManagerStats {
+	t.Helper()
+
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	return stats
+}
+
+func TestManager(t *testing.T) {
+	m := newManager(t, ezdb.ManagerOptions{MaxOpen: 1})
+	if stats := managerStats(t, m); stats.Known != 0 || stats.Opened != 0 {
+		t.Fatalf("new Manager has stats %+v", stats)
+	}
+
+	// DBRefs keep working after their environment is closed and reopened.
+	refs := map[string]*ezdb.DBRef[string, string]{}
+	for _, name := range []string{"a", "b", "a"} {
+		err := m.Use(name, func(db *ezdb.Client) error {
+			ref, ok := refs[name]
+			if !ok {
+				var err error
+				ref, err = ezdb.NewRef[string, string]("ref", db)
+				if err != nil {
+					return err
+				}
+				refs[name] = ref
+				putN(t, ref, 10)
+			}
+			wantN(t, ref, 10)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Use(%s): %v", name, err)
+		}
+	}
+	stats := managerStats(t, m)
+	if stats.Known != 2 || stats.Open != 1 || stats.Opened != 3 || stats.Evicted != 2 {
+		t.Fatalf("stats after using a, b and a = %+v", stats)
+	}
+	if _, ok := stats.Clients["a"]; !ok || len(stats.Clients) != 1 || stats.DataBytes == 0 {
+		t.Fatalf("stats of open environments = %+v", stats)
+	}
+
+	// Environments in use aren't closed, even beyond MaxOpen.
+	err := m.Use("a", func(*ezdb.Client) error {
+		return m.Use("b", func(*ezdb.Client) error {
+			if stats := managerStats(t, m); stats.Open != 2 {
+				return errors.New("an environment in use was closed")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if stats := managerStats(t, m); stats.Open != 1 {
+		t.Fatalf("%d environments open after Use returned", stats.Open)
+	}
+
+	errUse := errors.New("use")
+	err = m.Use("a", func(*ezdb.Client) error { return errUse })
+	if !errors.Is(err, errUse) {
+		t.Fatalf("Use returned %v, want the error of fn", err)
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stats := managerStats(t, m); stats.Open != 0 {
+		t.Fatalf("%d environments open after Close", stats.Open)
+	}
+	err = m.Use("a", func(*ezdb.Client) error { return nil })
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Use after Close returned %v, want ErrClosed", err)
+	}
+	err = m.Close()
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("second Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestManagerIdleTimeout(t *testing.T) {
+	m := newManager(t, ezdb.ManagerOptions{IdleTimeout: 20 * time.Millisecond})
+	var ref *ezdb.DBRef[string, string]
+	err := m.Use("a", func(db *ezdb.Client) error {
+		var err error
+		ref, err = ezdb.NewRef[string, string]("ref", db)
+		if err != nil {
+			return err
+		}
+		putN(t, ref, 10)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for managerStats(t, m).Open != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle environment wasn't closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := managerStats(t, m); stats.Evicted != 1 {
+		t.Fatalf("stats after the idle timeout = %+v", stats)
+	}
+
+	err = m.Use("a", func(*ezdb.Client) error {
+		wantN(t, ref, 10)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Use after the idle timeout: %v", err)
+	}
+}
+
+func TestManagerErrors(t *testing.T) {
+	for _, opts := range []ezdb.ManagerOptions{
+		{},
+		{Dir: t.TempDir(), MaxOpen: -1},
+		{Dir: t.TempDir(), IdleTimeout: -time.Second},
+	} {
+		_, err := ezdb.NewManager(opts)
+		if err == nil {
+			t.Errorf("NewManager(%+v) succeeded", opts)
+		}
+	}
+
+	m := newManager(t, ezdb.ManagerOptions{})
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		err := m.Use(name, func(*ezdb.Client) error {
+			t.Errorf("Use(%q) called fn", name)
+			return nil
+		})
+		if err == nil {
+			t.Errorf("Use(%q) succeeded", name)
+		}
+	}
+
+	m = newManager(t, ezdb.ManagerOptions{Options: []ezdb.Option{ezdb.WithFlushInterval(0)}})
+	err := m.Use("a", func(*ezdb.Client) error { return nil })
+	if err == nil {
+		t.Fatal("Use with invalid options succeeded")
+	}
+}
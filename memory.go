@@ -0,0 +1,535 @@
+package ezdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// NewMemory returns a Client whose named databases live in memory instead of
+// an LMDB environment on disk, for unit tests and ephemeral data. It needs no
+// directory to create or clean up, and its contents are gone once it is
+// closed; Reopen starts over empty.
+//
+// Transactions behave as they do on LMDB: one writer at a time, and readers
+// seeing the snapshot that was committed when they began. Features that read
+// or copy LMDB's files, such as Stats, Backup, CompactTo and
+// ReaderCheck, fail with an error matching ErrUnsupported, see Capabilities,
+// and options that tune LMDB, such as WithNumReaders and WithNumDBs, have no
+// effect.
+func NewMemory(opts ...Option) (*Client, error) {
+	opts = append(opts, func(option *options) error {
+		option.engine = EngineMemory
+		option.openBackend = func(string) (backend, error) {
+			return newMemBackend(), nil
+		}
+		return nil
+	})
+
+	return New("", opts...)
+}
+
+// memBackend is the backend of NewMemory. Each named database is a treap that
+// is never modified in place: a write transaction builds new paths to the
+// nodes it changes, so readers keep the trees that were committed when they
+// began without locking.
+type memBackend struct {
+	// writer serializes write transactions.
+	writer sync.Mutex
+
+	mu    sync.Mutex
+	names map[string]dbi
+	// tables are the committed named databases by dbi.
+	tables map[dbi]memTable
+}
+
+// memTable is a named database.
+type memTable struct {
+	root *memNode
+	// dup is set for dbDupSort databases, whose entries are ordered by key
+	// and then by value.
+	dup bool
+}
+
+// memNode is a node of a treap: a binary search tree by entry that is a heap
+// by prio.
+type memNode struct {
+	key, val    []byte
+	prio        uint32
+	left, right *memNode
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{
+		names:  make(map[string]dbi),
+		tables: make(map[dbi]memTable),
+	}
+}
+
+func (b *memBackend) View(fn func(txn readTxn) error) error {
+	b.mu.Lock()
+	txn := &memTxn{b: b, tables: b.tables}
+	b.mu.Unlock()
+
+	return fn(txn)
+}
+
+func (b *memBackend) Update(fn func(txn writeTxn) error) error {
+	b.writer.Lock()
+	defer b.writer.Unlock()
+
+	b.mu.Lock()
+	tables := make(map[dbi]memTable, len(b.tables))
+	for id, table := range b.tables {
+		tables[id] = table
+	}
+	b.mu.Unlock()
+
+	txn := &memTxn{b: b, tables: tables, write: true}
+	err := fn(txn)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.tables = tables
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *memBackend) Sync(force bool) error {
+	return nil
+}
+
+func (b *memBackend) Copy(path string, compact bool) error {
+	return fmt.Errorf("failed to copy in-memory environment: %w", ErrUnsupported)
+}
+
+func (b *memBackend) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.names = nil
+	b.tables = nil
+}
+
+// memTxn is a transaction on a memBackend. tables is shared with the
+// committed state for read transactions, and a private copy for write
+// transactions.
+type memTxn struct {
+	b      *memBackend
+	tables map[dbi]memTable
+	write  bool
+}
+
+func (t *memTxn) DBRef(name string, flags dbFlag) (dbi, error) {
+	t.b.mu.Lock()
+	id, ok := t.b.names[name]
+	if !ok && t.write && flags&dbCreate != 0 {
+		id = dbi(len(t.b.names) + 1)
+		t.b.names[name] = id
+	}
+	t.b.mu.Unlock()
+
+	if _, exists := t.tables[id]; ok && exists {
+		return id, nil
+	}
+	if !t.write || flags&dbCreate == 0 {
+		return 0, fmt.Errorf("failed to open database %q: %w", name, ErrNotFound)
+	}
+
+	if flags&lmdbOnlyDBFlags != 0 {
+		return 0, fmt.Errorf("failed to create database %q with flags %#x: %w", name, flags, ErrUnsupported)
+	}
+	t.tables[id] = memTable{dup: flags&dbDupSort != 0}
+	return id, nil
+}
+
+func (t *memTxn) ListDBs() ([]namedDB, error) {
+	t.b.mu.Lock()
+	var dbs []namedDB
+	for name, id := range t.b.names {
+		table, ok := t.tables[id]
+		if !ok {
+			continue
+		}
+		db := namedDB{name: name}
+		if table.dup {
+			db.flags = dbDupSort
+		}
+		dbs = append(dbs, db)
+	}
+	t.b.mu.Unlock()
+
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].name < dbs[j].name })
+	return dbs, nil
+}
+
+func (t *memTxn) table(db dbi) (memTable, error) {
+	table, ok := t.tables[db]
+	if !ok {
+		return memTable{}, fmt.Errorf("invalid database %d", db)
+	}
+
+	return table, nil
+}
+
+func (t *memTxn) Get(db dbi, key []byte) ([]byte, error) {
+	table, err := t.table(db)
+	if err != nil {
+		return nil, err
+	}
+
+	n := ceilNode(table.root, keyBelow(key))
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil, ErrNotFound
+	}
+
+	return n.val, nil
+}
+
+func (t *memTxn) NewCursor(db dbi) (dbCursor, error) {
+	_, err := t.table(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memCursor{txn: t, db: db}, nil
+}
+
+func (t *memTxn) Put(db dbi, key, val []byte, flags putFlag) error {
+	return t.put(db, key, append([]byte(nil), val...), flags)
+}
+
+func (t *memTxn) PutReserve(db dbi, key []byte, size int, flags putFlag) ([]byte, error) {
+	table, err := t.table(db)
+	if err != nil {
+		return nil, err
+	}
+	if table.dup {
+		return nil, fmt.Errorf("failed to reserve value of dupsort db: %w", ErrUnsupported)
+	}
+
+	val := make([]byte, size)
+	err = t.put(db, key, val, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// put stores val under key, keeping val itself rather than a copy.
+func (t *memTxn) put(db dbi, key, val []byte, flags putFlag) error {
+	table, err := t.table(db)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("failed to put: empty key")
+	}
+
+	n := ceilNode(table.root, keyBelow(key))
+	keyExists := n != nil && bytes.Equal(n.key, key)
+	if keyExists && flags&putNoOverwrite != 0 {
+		return ErrKeyExists
+	}
+	if last := lastNode(table.root); flags&putAppend != 0 && last != nil && bytes.Compare(last.key, key) >= 0 {
+		return ErrKeyExists
+	}
+
+	var left, right *memNode
+	if table.dup {
+		left, right = splitNodes(table.root, entryBelow(key, val))
+		if n := firstNode(right); n != nil && bytes.Equal(n.key, key) && bytes.Equal(n.val, val) {
+			if flags&putNoDupData != 0 {
+				return ErrKeyExists
+			}
+			return nil
+		}
+	} else {
+		var rest *memNode
+		left, rest = splitNodes(table.root, keyBelow(key))
+		_, right = splitNodes(rest, keyAtMost(key))
+	}
+
+	node := &memNode{
+		key:  append([]byte(nil), key...),
+		val:  val,
+		prio: rand.Uint32(),
+	}
+	table.root = mergeNodes(mergeNodes(left, node), right)
+	t.tables[db] = table
+	return nil
+}
+
+func (t *memTxn) Delete(db dbi, key, val []byte) error {
+	table, err := t.table(db)
+	if err != nil {
+		return err
+	}
+
+	var left, mid, right *memNode
+	if table.dup && val != nil {
+		var rest *memNode
+		left, rest = splitNodes(table.root, entryBelow(key, val))
+		mid, right = splitNodes(rest, entryAtMost(key, val))
+	} else {
+		var rest *memNode
+		left, rest = splitNodes(table.root, keyBelow(key))
+		mid, right = splitNodes(rest, keyAtMost(key))
+	}
+	if mid == nil {
+		return ErrNotFound
+	}
+
+	table.root = mergeNodes(left, right)
+	t.tables[db] = table
+	return nil
+}
+
+func (t *memTxn) Empty(db dbi) error {
+	table, err := t.table(db)
+	if err != nil {
+		return err
+	}
+
+	table.root = nil
+	t.tables[db] = table
+	return nil
+}
+
+func (t *memTxn) Drop(db dbi) error {
+	_, err := t.table(db)
+	if err != nil {
+		return err
+	}
+
+	delete(t.tables, db)
+	return nil
+}
+
+// memCursor is a cursor on a memTxn. It remembers the entry it is on rather
+// than a path through the tree, and finds its neighbours in the tree as it is
+// when it moves, so it sees the writes of its transaction.
+type memCursor struct {
+	txn      *memTxn
+	db       dbi
+	key, val []byte
+	valid    bool
+}
+
+func (c *memCursor) root() *memNode {
+	return c.txn.tables[c.db].root
+}
+
+func (c *memCursor) dup() bool {
+	return c.txn.tables[c.db].dup
+}
+
+// moveTo positions the cursor on n, or unpositions it if n is nil.
+func (c *memCursor) moveTo(n *memNode) ([]byte, []byte, error) {
+	if n == nil {
+		c.valid = false
+		return nil, nil, ErrNotFound
+	}
+
+	c.key, c.val, c.valid = n.key, n.val, true
+	return n.key, n.val, nil
+}
+
+// atMost reports whether an entry is at or before the cursor's entry.
+func (c *memCursor) atMost() func(n *memNode) bool {
+	if c.dup() {
+		return entryAtMost(c.key, c.val)
+	}
+	return keyAtMost(c.key)
+}
+
+// below reports whether an entry is before the cursor's entry.
+func (c *memCursor) below() func(n *memNode) bool {
+	if c.dup() {
+		return entryBelow(c.key, c.val)
+	}
+	return keyBelow(c.key)
+}
+
+func (c *memCursor) First() ([]byte, []byte, error) {
+	return c.moveTo(firstNode(c.root()))
+}
+
+func (c *memCursor) Last() ([]byte, []byte, error) {
+	return c.moveTo(lastNode(c.root()))
+}
+
+func (c *memCursor) Next() ([]byte, []byte, error) {
+	if !c.valid {
+		return c.First()
+	}
+
+	return c.moveTo(ceilNode(c.root(), c.atMost()))
+}
+
+func (c *memCursor) Prev() ([]byte, []byte, error) {
+	if !c.valid {
+		return c.Last()
+	}
+
+	return c.moveTo(floorNode(c.root(), c.below()))
+}
+
+func (c *memCursor) NextInSameKey() ([]byte, []byte, error) {
+	if !c.valid {
+		return nil, nil, ErrNotFound
+	}
+
+	n := ceilNode(c.root(), c.atMost())
+	if n == nil || !bytes.Equal(n.key, c.key) {
+		return nil, nil, ErrNotFound
+	}
+
+	return c.moveTo(n)
+}
+
+func (c *memCursor) Count() (uint64, error) {
+	if !c.valid {
+		return 0, ErrNotFound
+	}
+
+	_, rest := splitNodes(c.root(), keyBelow(c.key))
+	mid, _ := splitNodes(rest, keyAtMost(c.key))
+	return countNodes(mid), nil
+}
+
+func (c *memCursor) SeekExactKey(key []byte) ([]byte, error) {
+	n := ceilNode(c.root(), keyBelow(key))
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil, ErrNotFound
+	}
+
+	_, val, err := c.moveTo(n)
+	return val, err
+}
+
+func (c *memCursor) SeekGreaterThanOrEqualKey(key []byte) ([]byte, []byte, error) {
+	return c.moveTo(ceilNode(c.root(), keyBelow(key)))
+}
+
+func (c *memCursor) Close() {}
+
+// The predicates below order nodes against a bound. Each is monotone over
+// the tree: true for every entry up to some point and false afterwards.
+
+func keyBelow(key []byte) func(n *memNode) bool {
+	return func(n *memNode) bool { return bytes.Compare(n.key, key) < 0 }
+}
+
+func keyAtMost(key []byte) func(n *memNode) bool {
+	return func(n *memNode) bool { return bytes.Compare(n.key, key) <= 0 }
+}
+
+func entryBelow(key, val []byte) func(n *memNode) bool {
+	return func(n *memNode) bool {
+		c := bytes.Compare(n.key, key)
+		return c < 0 || c == 0 && bytes.Compare(n.val, val) < 0
+	}
+}
+
+func entryAtMost(key, val []byte) func(n *memNode) bool {
+	return func(n *memNode) bool {
+		c := bytes.Compare(n.key, key)
+		return c < 0 || c == 0 && bytes.Compare(n.val, val) <= 0
+	}
+}
+
+// splitNodes splits the tree n into the nodes for which left is true and the
+// rest, copying the nodes on the path between them.
+func splitNodes(n *memNode, left func(n *memNode) bool) (*memNode, *memNode) {
+	if n == nil {
+		return nil, nil
+	}
+
+	c := *n
+	if left(n) {
+		var r *memNode
+		c.right, r = splitNodes(n.right, left)
+		return &c, r
+	}
+	var l *memNode
+	l, c.left = splitNodes(n.left, left)
+	return l, &c
+}
+
+// mergeNodes joins the trees a and b, every entry of a being ordered before
+// every entry of b.
+func mergeNodes(a, b *memNode) *memNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	if a.prio > b.prio {
+		c := *a
+		c.right = mergeNodes(a.right, b)
+		return &c
+	}
+	c := *b
+	c.left = mergeNodes(a, b.left)
+	return &c
+}
+
+// ceilNode returns the first node for which below is false.
+func ceilNode(n *memNode, below func(n *memNode) bool) *memNode {
+	var found *memNode
+	for n != nil {
+		if below(n) {
+			n = n.right
+		} else {
+			found, n = n, n.left
+		}
+	}
+
+	return found
+}
+
+// floorNode returns the last node for which below is true.
+func floorNode(n *memNode, below func(n *memNode) bool) *memNode {
+	var found *memNode
+	for n != nil {
+		if below(n) {
+			found, n = n, n.right
+		} else {
+			n = n.left
+		}
+	}
+
+	return found
+}
+
+func firstNode(n *memNode) *memNode {
+	for n != nil && n.left != nil {
+		n = n.left
+	}
+
+	return n
+}
+
+func lastNode(n *memNode) *memNode {
+	for n != nil && n.right != nil {
+		n = n.right
+	}
+
+	return n
+}
+
+func countNodes(n *memNode) uint64 {
+	if n == nil {
+		return 0
+	}
+
+	return 1 + countNodes(n.left) + countNodes(n.right)
+}
@@ -0,0 +1,144 @@
+package ezdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// newMemoryClient returns an initialized Client from NewMemory, closed when
+// t finishes.
+func newMemoryClient(t *testing.T) *Client {
+	t.Helper()
+
+	db, err := NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T, dir string) *Client {
+		return newMemoryClient(t)
+	})
+}
+
+func TestMemorySnapshots(t *testing.T) {
+	env := newMemBackend()
+	put(t, env, "db", 0, "a", "1", "b", "2")
+
+	// Readers keep the tree committed when they began while a writer
+	// changes it.
+	err := env.View(func(txn readTxn) error {
+		db, err := txn.DBRef("db", 0)
+		if err != nil {
+			return err
+		}
+		put(t, env, "db", 0, "a", "changed", "c", "3")
+		err = env.Update(func(txn writeTxn) error {
+			db, err := txn.DBRef("db", 0)
+			if err != nil {
+				return err
+			}
+			return txn.Delete(db, []byte("b"), nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		val, err := txn.Get(db, []byte("a"))
+		if err != nil || string(val) != "1" {
+			t.Errorf("Get of a snapshot = %q, %v", val, err)
+		}
+		c, err := txn.NewCursor(db)
+		if err != nil {
+			return err
+		}
+		var keys []byte
+		for key, _, err := c.First(); err == nil; key, _, err = c.Next() {
+			keys = append(keys, key...)
+		}
+		if string(keys) != "ab" {
+			t.Errorf("snapshot holds keys %q, want ab", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	wantEntries(t, env, "db", "a=changed", "c=3")
+
+	// Values are copied, so changing them after Put doesn't change the
+	// database.
+	val := []byte("4")
+	err = env.Update(func(txn writeTxn) error {
+		db, err := txn.DBRef("db", 0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(db, []byte("d"), val, 0)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	val[0] = 'x'
+	wantEntries(t, env, "db", "a=changed", "c=3", "d=4")
+}
+
+func TestNewMemory(t *testing.T) {
+	db := newMemoryClient(t)
+	ref, err := NewDBRef[string, string](db, "ref", WithKeyCodec(StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "a", "1"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != "1" {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	// Features that read or copy LMDB's files aren't supported.
+	_, err = db.Stats()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Stats returned %v, want ErrUnsupported", err)
+	}
+	_, err = db.ReaderCheck()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ReaderCheck returned %v, want ErrUnsupported", err)
+	}
+	var buf bytes.Buffer
+	err = db.Backup(context.Background(), &buf)
+	if !errors.Is(err, ErrUnsupported) || buf.Len() != 0 {
+		t.Errorf("Backup returned %v and wrote %d bytes, want ErrUnsupported", err, buf.Len())
+	}
+
+	// Reopening starts over empty.
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	ref, err = NewDBRef[string, string](db, "ref", WithKeyCodec(StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Reopen returned %v, want ErrNotFound", err)
+	}
+}
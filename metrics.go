@@ -0,0 +1,425 @@
+package ezdb
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rs/zerolog"
+)
+
+// WithMetrics makes the Client count the operations of its DBRefs, their
+// errors and the bytes they read and write, and time them and the
+// transactions they run in. The metrics are exposed to Prometheus by
+// Client.Collector, Client.MetricsHandler and Client.WritePrometheus.
+func WithMetrics() Option {
+	return func(option *options) error {
+		option.metrics = true
+		return nil
+	}
+}
+
+const (
+	opGet    = "get"
+	opPut    = "put"
+	opDelete = "delete"
+	// opExpire is the op of Expire and of the watch events of expired entries.
+	opExpire = "expire"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms.
+// LMDB operations mostly complete in microseconds, so the buckets start much
+// lower than Prometheus' defaults.
+var latencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	s := d.Seconds()
+	for i, bound := range latencyBuckets {
+		if s <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += s
+	h.count++
+}
+
+type opKey struct {
+	ref string
+	op  string
+}
+
+type refCounters struct {
+	bytesRead    uint64
+	bytesWritten uint64
+}
+
+// metrics holds the counters and histograms of a Client created with
+// WithMetrics.
+type metrics struct {
+	mu        sync.Mutex
+	ops       map[opKey]uint64
+	errors    map[opKey]uint64
+	latencies map[opKey]*opHistograms
+	refs      map[string]*refCounters
+	txns      map[string]*histogram
+
+	// lastErr is the most recent failed operation, if any.
+	lastErr   error
+	lastErrAt time.Time
+	lastErrOp opKey
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		ops:       make(map[opKey]uint64),
+		errors:    make(map[opKey]uint64),
+		latencies: make(map[opKey]*opHistograms),
+		refs:      make(map[string]*refCounters),
+		txns:      make(map[string]*histogram),
+	}
+}
+
+// opTimer measures the phases of an operation: encoding, the transaction and
+// decoding. Each call to mark adds the time since the previous one to a phase.
+type opTimer struct {
+	start, last         time.Time
+	encode, txn, decode time.Duration
+}
+
+func newOpTimer() opTimer {
+	now := time.Now()
+	return opTimer{start: now, last: now}
+}
+
+func (t *opTimer) mark(phase *time.Duration) {
+	now := time.Now()
+	*phase += now.Sub(t.last)
+	t.last = now
+}
+
+// opHistograms are the latency histograms of one operation of one DBRef.
+type opHistograms struct {
+	total, encode, txn, decode histogram
+}
+
+// recordOp records an operation of the DBRef ref timed by t that read or wrote
+// n bytes. It does nothing on a nil *metrics, i.e. if metrics are disabled.
+func (m *metrics) recordOp(ref, op string, t *opTimer, n int, err error) {
+	if m == nil {
+		return
+	}
+	d := time.Since(t.start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := opKey{ref: ref, op: op}
+	m.ops[k]++
+	if err != nil {
+		m.errors[k]++
+		m.lastErr, m.lastErrAt, m.lastErrOp = err, time.Now(), k
+	}
+	h, ok := m.latencies[k]
+	if !ok {
+		h = &opHistograms{}
+		m.latencies[k] = h
+	}
+	h.total.observe(d)
+	// Phases an operation doesn't have, such as decoding for Put, stay empty.
+	for _, p := range []struct {
+		h *histogram
+		d time.Duration
+	}{{&h.encode, t.encode}, {&h.txn, t.txn}, {&h.decode, t.decode}} {
+		if p.d > 0 {
+			p.h.observe(p.d)
+		}
+	}
+
+	c, ok := m.refs[ref]
+	if !ok {
+		c = &refCounters{}
+		m.refs[ref] = c
+	}
+	if op == opGet {
+		c.bytesRead += uint64(n)
+	} else {
+		c.bytesWritten += uint64(n)
+	}
+}
+
+// recordTxn records a transaction of the given kind, "read" or "write", that
+// started at start.
+func (m *metrics) recordTxn(kind string, start time.Time) {
+	if m == nil {
+		return
+	}
+	d := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.txns[kind]
+	if !ok {
+		h = &histogram{}
+		m.txns[kind] = h
+	}
+	h.observe(d)
+}
+
+var (
+	opsDesc = prometheus.NewDesc("ezdb_ops_total",
+		"Operations by DBRef and operation.", []string{"dbref", "op"}, nil)
+	errorsDesc = prometheus.NewDesc("ezdb_errors_total",
+		"Failed operations by DBRef and operation.", []string{"dbref", "op"}, nil)
+	readBytesDesc = prometheus.NewDesc("ezdb_read_bytes_total",
+		"Bytes of values read by DBRef.", []string{"dbref"}, nil)
+	writtenBytesDesc = prometheus.NewDesc("ezdb_written_bytes_total",
+		"Bytes of keys and values written by DBRef.", []string{"dbref"}, nil)
+	opDurationDesc = prometheus.NewDesc("ezdb_op_duration_seconds",
+		"Latency of operations by DBRef and operation.", []string{"dbref", "op"}, nil)
+	opPhaseDurationDesc = prometheus.NewDesc("ezdb_op_phase_duration_seconds",
+		"Latency of the encode, txn and decode phases of operations by DBRef and operation.", []string{"dbref", "op", "phase"}, nil)
+	txnDurationDesc = prometheus.NewDesc("ezdb_txn_duration_seconds",
+		"Latency of transactions until commit, including time spent waiting for a batch.", []string{"kind"}, nil)
+	appliedSeqDesc = prometheus.NewDesc("ezdb_replication_applied_seq",
+		"Position of the last change applied by the follower in the leader's audit log.", nil, nil)
+	leaderSeqDesc = prometheus.NewDesc("ezdb_replication_leader_seq",
+		"Position of the leader's last change as of the last contact.", nil, nil)
+	lagDesc = prometheus.NewDesc("ezdb_replication_lag",
+		"Changes the follower is behind the leader.", nil, nil)
+)
+
+// Collector returns a prometheus.Collector of the Client's metrics, labeled
+// by DBRef, to register with the application's prometheus.Registerer. It
+// collects nothing unless the Client was created with WithMetrics.
+func (db *Client) Collector() prometheus.Collector {
+	return metricsCollector{db: db}
+}
+
+// metricsCollector collects the metrics of a Client.
+type metricsCollector struct {
+	db *Client
+}
+
+func (c metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		opsDesc, errorsDesc, readBytesDesc, writtenBytesDesc,
+		opDurationDesc, opPhaseDurationDesc, txnDurationDesc,
+		appliedSeqDesc, leaderSeqDesc, lagDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.db.metrics
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	for k, n := range m.ops {
+		ch <- prometheus.MustNewConstMetric(opsDesc, prometheus.CounterValue, float64(n), k.ref, k.op)
+		ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(m.errors[k]), k.ref, k.op)
+
+		h := m.latencies[k]
+		ch <- h.total.metric(opDurationDesc, k.ref, k.op)
+		for _, p := range []struct {
+			name string
+			h    *histogram
+		}{{"encode", &h.encode}, {"txn", &h.txn}, {"decode", &h.decode}} {
+			if p.h.count > 0 {
+				ch <- p.h.metric(opPhaseDurationDesc, k.ref, k.op, p.name)
+			}
+		}
+	}
+	for ref, counters := range m.refs {
+		ch <- prometheus.MustNewConstMetric(readBytesDesc, prometheus.CounterValue, float64(counters.bytesRead), ref)
+		ch <- prometheus.MustNewConstMetric(writtenBytesDesc, prometheus.CounterValue, float64(counters.bytesWritten), ref)
+	}
+	for kind, h := range m.txns {
+		ch <- h.metric(txnDurationDesc, kind)
+	}
+	m.mu.Unlock()
+
+	if f := c.db.follower.Load(); f != nil {
+		status := f.Status()
+		ch <- prometheus.MustNewConstMetric(appliedSeqDesc, prometheus.GaugeValue, float64(status.Applied))
+		ch <- prometheus.MustNewConstMetric(leaderSeqDesc, prometheus.GaugeValue, float64(status.Leader))
+		ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, float64(status.Lag()))
+	}
+}
+
+// metric returns h as a Prometheus histogram with the given label values.
+func (h *histogram) metric(desc *prometheus.Desc, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(latencyBuckets))
+	for i, bound := range latencyBuckets {
+		var n uint64
+		if h.counts != nil {
+			n = h.counts[i]
+		}
+		buckets[bound] = n
+	}
+
+	return prometheus.MustNewConstHistogram(desc, h.count, h.sum, buckets, labels...)
+}
+
+// WritePrometheus writes the Client's metrics to w in the Prometheus text
+// exposition format. It writes nothing unless the Client was created with
+// WithMetrics.
+func (db *Client) WritePrometheus(w io.Writer) error {
+	registry := prometheus.NewRegistry()
+	err := registry.Register(db.Collector())
+	if err != nil {
+		return fmt.Errorf("failed to register metrics: %w", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		err = enc.Encode(family)
+		if err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MetricsHandler returns an http.Handler serving the Client's metrics, to be
+// mounted on a Prometheus scrape endpoint such as /metrics. Applications
+// with metrics of their own register Collector instead.
+func (db *Client) MetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(db.Collector())
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{db.options.log}})
+}
+
+// promLogger adapts zerolog to promhttp's logger.
+type promLogger struct {
+	log *zerolog.Logger
+}
+
+func (l promLogger) Println(v ...any) {
+	l.log.Error().Msg(fmt.Sprint(v...))
+}
+
+// RefMetrics is a snapshot of the metrics of a DBRef, see DBRef.Metrics.
+type RefMetrics struct {
+	// Ops holds the metrics of each operation, keyed by "get", "put" and
+	// "delete". Operations that never ran are missing.
+	Ops          map[string]OpMetrics
+	BytesRead    uint64
+	BytesWritten uint64
+}
+
+// OpMetrics are the metrics of one operation of a DBRef.
+type OpMetrics struct {
+	Count  uint64
+	Errors uint64
+	// Latency is the distribution of the latency of whole operations, and
+	// Encode, Txn and Decode are those of their phases: encoding the key and
+	// value, running the transaction, and decoding the value.
+	Latency, Encode, Txn, Decode Histogram
+}
+
+// Histogram is a snapshot of a latency distribution.
+type Histogram struct {
+	// Bounds are the upper bounds of the buckets in increasing order, and
+	// Counts[i] is the number of observations no greater than Bounds[i].
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Quantile estimates the q-quantile of the distribution, for q between 0 and
+// 1, as the upper bound of the bucket it falls into. Observations above the
+// largest bound are reported as the largest bound. It returns 0 if the
+// histogram is empty.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	for i, n := range h.Counts {
+		if n >= rank {
+			return h.Bounds[i]
+		}
+	}
+
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+func (h *histogram) snapshot() Histogram {
+	bounds := make([]time.Duration, len(latencyBuckets))
+	for i, bound := range latencyBuckets {
+		bounds[i] = time.Duration(bound * float64(time.Second))
+	}
+	counts := make([]uint64, len(latencyBuckets))
+	copy(counts, h.counts)
+
+	return Histogram{
+		Bounds: bounds,
+		Counts: counts,
+		Count:  h.count,
+		Sum:    time.Duration(h.sum * float64(time.Second)),
+	}
+}
+
+// Metrics returns a snapshot of the operation counts and latencies of ref.
+// It is empty unless the Client was created with WithMetrics. DBRefs sharing
+// a name, such as ones created by Sub, share their metrics.
+func (ref *DBRef[K, V]) Metrics() RefMetrics {
+	snap := RefMetrics{Ops: map[string]OpMetrics{}}
+	m := ref.ownerDB.metrics
+	if m == nil {
+		return snap
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, n := range m.ops {
+		if k.ref != ref.id {
+			continue
+		}
+		h := m.latencies[k]
+		snap.Ops[k.op] = OpMetrics{
+			Count:   n,
+			Errors:  m.errors[k],
+			Latency: h.total.snapshot(),
+			Encode:  h.encode.snapshot(),
+			Txn:     h.txn.snapshot(),
+			Decode:  h.decode.snapshot(),
+		}
+	}
+	if c, ok := m.refs[ref.id]; ok {
+		snap.BytesRead = c.bytesRead
+		snap.BytesWritten = c.bytesWritten
+	}
+
+	return snap
+}
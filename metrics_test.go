@@ -0,0 +1,191 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithMetrics())
+	ref, err := ezdb.NewDBRef[string, []byte](db, "ref", ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "key", []byte("value")
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = ref.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	// Misses aren't errors, but rejected writes are.
+	missing := "missing"
+	_, ok, err := ref.TryGet(&missing)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a missing key = %t, %v", ok, err)
+	}
+	long := strings.Repeat("k", 512)
+	err = ref.Put(&long, &val)
+	if err == nil {
+		t.Fatal("Put of a long key succeeded")
+	}
+
+	var buf bytes.Buffer
+	err = db.WritePrometheus(&buf)
+	if err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	for _, want := range []string{
+		`ezdb_ops_total{dbref="ref",op="get"} 3`,
+		`ezdb_ops_total{dbref="ref",op="put"} 2`,
+		`ezdb_errors_total{dbref="ref",op="get"} 0`,
+		`ezdb_errors_total{dbref="ref",op="put"} 1`,
+		`ezdb_read_bytes_total{dbref="ref"} 10`,
+		`ezdb_written_bytes_total{dbref="ref"} 8`,
+		`ezdb_op_duration_seconds_count{dbref="ref",op="get"} 3`,
+		`ezdb_txn_duration_seconds_count{kind="write"}`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics lack %s:\n%s", want, buf.String())
+		}
+	}
+
+	srv := httptest.NewServer(db.MetricsHandler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || !strings.Contains(string(body), `ezdb_ops_total{dbref="ref",op="put"} 2`) {
+		t.Fatalf("MetricsHandler served %s, %v", body, err)
+	}
+
+	// The collector can be registered with other metrics.
+	registry := prometheus.NewRegistry()
+	err = registry.Register(db.Collector())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = registry.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "app_requests_total"}))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, err = registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = db.WritePrometheus(&buf)
+	if err != nil || buf.Len() != 0 {
+		t.Fatalf("WritePrometheus without WithMetrics wrote %q, %v", buf.String(), err)
+	}
+}
+
+func TestRefMetrics(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithMetrics())
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	other, err := ezdb.NewRef[string, string]("other", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	for i := 0; i < 10; i++ {
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		_, err = ref.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	err = other.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	m := ref.Metrics()
+	if len(m.Ops) != 2 || m.BytesRead == 0 || m.BytesWritten == 0 {
+		t.Fatalf("Metrics = %+v", m)
+	}
+	for _, op := range []string{"get", "put"} {
+		om := m.Ops[op]
+		if om.Count != 10 || om.Errors != 0 {
+			t.Errorf("%s ran %d times with %d errors, want 10 and 0", op, om.Count, om.Errors)
+		}
+		for name, h := range map[string]ezdb.Histogram{"latency": om.Latency, "encode": om.Encode, "txn": om.Txn} {
+			if h.Count != 10 || h.Sum <= 0 || len(h.Counts) != len(h.Bounds) || h.Counts[len(h.Counts)-1] > h.Count {
+				t.Errorf("%s %s histogram = %+v", op, name, h)
+			}
+			if q := h.Quantile(0.5); q <= 0 || q > h.Quantile(1) {
+				t.Errorf("%s %s median = %v, max = %v", op, name, q, h.Quantile(1))
+			}
+		}
+	}
+	// Puts have nothing to decode.
+	if n := m.Ops["put"].Decode.Count; n != 0 {
+		t.Errorf("put decode histogram has %d observations", n)
+	}
+	if n := m.Ops["get"].Decode.Count; n != 10 {
+		t.Errorf("get decode histogram has %d observations, want 10", n)
+	}
+	if n := other.Metrics().Ops["put"].Count; n != 1 {
+		t.Errorf("other DBRef ran %d Puts, want 1", n)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := ezdb.Histogram{
+		Bounds: []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond},
+		Counts: []uint64{5, 9, 9},
+		Count:  10,
+	}
+	for q, want := range map[float64]time.Duration{
+		0:    time.Millisecond,
+		0.5:  time.Millisecond,
+		0.51: 10 * time.Millisecond,
+		0.9:  10 * time.Millisecond,
+		// The last observation is above every bound.
+		1: 100 * time.Millisecond,
+	} {
+		if got := h.Quantile(q); got != want {
+			t.Errorf("Quantile(%g) = %v, want %v", q, got, want)
+		}
+	}
+	if got := (ezdb.Histogram{}).Quantile(0.5); got != 0 {
+		t.Errorf("Quantile of an empty histogram = %v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// migrationsDB is the named database the applied migrations of a Client are
+// recorded in.
+const migrationsDB = "ezdb.migrations"
+
+// Migration is a step in the evolution of the stored data, such as
+// re-encoding the values of a DBRef after a change of their type.
+type Migration struct {
+	// Version orders the migrations. Versions must be positive, and a
+	// version must not be reused for a different migration once applied.
+	Version uint64
+	// Name describes the migration.
+	Name string
+	// Up applies the migration. It must only read and write through tx,
+	// with methods such as GetTx, PutTx and DeleteTx: other writes would
+	// wait for tx to finish and deadlock. The DBRefs it uses must be opened
+	// before Migrate is called.
+	Up func(tx *Tx) error
+}
+
+// MigrationRecord records an applied migration.
+type MigrationRecord struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+type MigrateOption func(option *migrateOptions) error
+
+type migrateOptions struct {
+	dryRun bool
+}
+
+// WithDryRun makes Migrate run the pending migrations in a single transaction
+// and abort it, so nothing is changed but the migrations are checked to
+// succeed against the current data.
+func WithDryRun() MigrateOption {
+	return func(option *migrateOptions) error {
+		option.dryRun = true
+		return nil
+	}
+}
+
+// errDryRun aborts the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// Migrate applies the migrations that haven't been applied to db yet, in
+// order of their versions, and returns the versions it applied. Each
+// migration runs in its own transaction, which also records it as applied,
+// so if one fails the ones before it stay applied and Migrate can be retried
+// once it is fixed. Migrate fails without applying anything if a migration
+// older than the newest applied one is pending, or if an applied version has
+// a different name, which both indicate that migrations were reordered. The
+// record of applied migrations is stored in its own named database, which
+// counts towards WithNumDBs.
+func Migrate(db *Client, migrations []Migration, opts ...MigrateOption) (applied []uint64, err error) {
+	o := &migrateOptions{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	for i, m := range migrations {
+		if m.Version == 0 || m.Up == nil {
+			return nil, fmt.Errorf("migration %q needs a positive version and Up", m.Name)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return nil, fmt.Errorf("migration %d is not ordered after migration %d", m.Version, migrations[i-1].Version)
+		}
+	}
+
+	records, err := openMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := AppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		rec := findMigration(done, m.Version)
+		if rec != nil && rec.Name != m.Name {
+			return nil, fmt.Errorf("migration %d was applied as %q, not %q", m.Version, rec.Name, m.Name)
+		}
+		if rec == nil {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) > 0 && len(done) > 0 && pending[0].Version < done[len(done)-1].Version {
+		return nil, fmt.Errorf("migration %d is older than the applied migration %d", pending[0].Version, done[len(done)-1].Version)
+	}
+
+	if o.dryRun {
+		err = db.update(func(txn writeTxn) error {
+			tx := &Tx{txn: txn}
+			for _, m := range pending {
+				err := m.Up(tx)
+				if err != nil {
+					return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+				}
+				applied = append(applied, m.Version)
+			}
+			return errDryRun
+		})
+		if !errors.Is(err, errDryRun) {
+			return nil, err
+		}
+		return applied, nil
+	}
+
+	for _, m := range pending {
+		ran := false
+		err = db.update(func(txn writeTxn) error {
+			tx := &Tx{txn: txn}
+			// Another process may have applied it in the meantime.
+			_, err := records.GetTx(tx, &m.Version)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, ErrNotFound) {
+				return err
+			}
+
+			err = m.Up(tx)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+			ran = true
+			return records.PutTx(tx, &m.Version, &MigrationRecord{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now().UTC(),
+			})
+		})
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, m.Version)
+			db.options.log.Info().Uint64("version", m.Version).Str("name", m.Name).Msg("applied migration")
+		}
+	}
+
+	return applied, nil
+}
+
+// AppliedMigrations returns the records of the migrations applied to db, in
+// order of their versions.
+func AppliedMigrations(db *Client) ([]MigrationRecord, error) {
+	records, err := openMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []MigrationRecord
+	err = records.ForEach(func(_ *uint64, rec *MigrationRecord) error {
+		done = append(done, *rec)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	return done, nil
+}
+
+func openMigrations(db *Client) (*DBRef[uint64, MigrationRecord], error) {
+	records, err := NewDBRef[uint64, MigrationRecord](db, migrationsDB, WithKeyCodec(OrderedCodec{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open applied migrations: %w", err)
+	}
+
+	return records, nil
+}
+
+func findMigration(records []MigrationRecord, version uint64) *MigrationRecord {
+	for i := range records {
+		if records[i].Version == version {
+			return &records[i]
+		}
+	}
+
+	return nil
+}
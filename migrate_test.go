@@ -0,0 +1,138 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// putMigration returns a migration putting key with the value of its name.
+func putMigration(ref *ezdb.DBRef[string, string], version uint64, key string) ezdb.Migration {
+	name := fmt.Sprintf("put %s", key)
+	return ezdb.Migration{Version: version, Name: name, Up: func(tx *ezdb.Tx) error {
+		return ref.PutTx(tx, &key, &name)
+	}}
+}
+
+func TestMigrate(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	migrations := []ezdb.Migration{putMigration(ref, 1, "a"), putMigration(ref, 2, "b")}
+
+	applied, err := ezdb.Migrate(db, migrations)
+	if err != nil || fmt.Sprint(applied) != "[1 2]" {
+		t.Fatalf("Migrate = %v, %v", applied, err)
+	}
+	records, err := ezdb.AppliedMigrations(db)
+	if err != nil || len(records) != 2 || records[1].Version != 2 || records[1].Name != "put b" || records[1].AppliedAt.IsZero() {
+		t.Fatalf("AppliedMigrations = %+v, %v", records, err)
+	}
+
+	applied, err = ezdb.Migrate(db, migrations)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("Migrate again = %v, %v", applied, err)
+	}
+	applied, err = ezdb.Migrate(db, append(migrations, putMigration(ref, 5, "c")))
+	if err != nil || fmt.Sprint(applied) != "[5]" {
+		t.Fatalf("Migrate with a new migration = %v, %v", applied, err)
+	}
+
+	// Migrations added before applied ones, or renamed, were reordered.
+	_, err = ezdb.Migrate(db, []ezdb.Migration{migrations[0], migrations[1], putMigration(ref, 3, "d"), putMigration(ref, 5, "c")})
+	if err == nil {
+		t.Fatal("Migrate with a migration older than an applied one succeeded")
+	}
+	renamed := putMigration(ref, 2, "e")
+	_, err = ezdb.Migrate(db, []ezdb.Migration{migrations[0], renamed})
+	if err == nil {
+		t.Fatal("Migrate with a renamed migration succeeded")
+	}
+	key := "d"
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("rejected migration was applied: %v", err)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	errBroken := errors.New("broken")
+	broken := ezdb.Migration{Version: 2, Name: "put b", Up: func(tx *ezdb.Tx) error {
+		key, val := "b", "partial"
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		return errBroken
+	}}
+
+	applied, err := ezdb.Migrate(db, []ezdb.Migration{putMigration(ref, 1, "a"), broken})
+	if !errors.Is(err, errBroken) || fmt.Sprint(applied) != "[1]" {
+		t.Fatalf("Migrate with a failing migration = %v, %v", applied, err)
+	}
+	key := "b"
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("write of the failed migration was kept: %v", err)
+	}
+
+	applied, err = ezdb.Migrate(db, []ezdb.Migration{putMigration(ref, 1, "a"), putMigration(ref, 2, "b")})
+	if err != nil || fmt.Sprint(applied) != "[2]" {
+		t.Fatalf("Migrate once fixed = %v, %v", applied, err)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	migrations := []ezdb.Migration{putMigration(ref, 1, "a"), putMigration(ref, 2, "b")}
+
+	applied, err := ezdb.Migrate(db, migrations, ezdb.WithDryRun())
+	if err != nil || fmt.Sprint(applied) != "[1 2]" {
+		t.Fatalf("dry run = %v, %v", applied, err)
+	}
+	wantN(t, ref, 0)
+	records, err := ezdb.AppliedMigrations(db)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("AppliedMigrations after a dry run = %+v, %v", records, err)
+	}
+
+	errBroken := errors.New("broken")
+	migrations = append(migrations, ezdb.Migration{Version: 3, Name: "broken", Up: func(tx *ezdb.Tx) error {
+		return errBroken
+	}})
+	_, err = ezdb.Migrate(db, migrations, ezdb.WithDryRun())
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("dry run of a failing migration returned %v", err)
+	}
+}
+
+func TestMigrateRejectsInvalidMigrations(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	up := func(tx *ezdb.Tx) error { return nil }
+
+	for name, migrations := range map[string][]ezdb.Migration{
+		"zero version": {{Version: 0, Name: "zero", Up: up}},
+		"no up":        {{Version: 1, Name: "no up"}},
+		"unordered":    {{Version: 2, Name: "b", Up: up}, {Version: 1, Name: "a", Up: up}},
+		"duplicate":    {{Version: 1, Name: "a", Up: up}, {Version: 1, Name: "b", Up: up}},
+	} {
+		_, err := ezdb.Migrate(db, migrations)
+		if err == nil {
+			t.Errorf("Migrate with a %s migration succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mirrorDB is the named database the journal of writes yet to be mirrored is
+// stored in.
+const mirrorDB = "ezdb.mirror"
+
+// mirrorRecord is a journaled write.
+type mirrorRecord struct {
+	DBRef  string
+	Delete bool
+	Key    []byte
+	Value  []byte
+}
+
+// Mirror duplicates the writes of a Client to a secondary Client, see
+// Client.Mirror.
+type Mirror struct {
+	db        *Client
+	secondary *Client
+	// wake is signaled whenever a write transaction of db commits.
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// Mirror starts duplicating every Put and Delete on the Client's DBRefs to
+// secondary, such as an environment at a new path or with a new map size,
+// so data can be migrated without downtime: once Mirror returns, secondary
+// holds a copy of the DBRefs opened on the Client so far, and keeps up with
+// their writes until Stop.
+//
+// Writes are recorded in a journal in the same transaction as the write and
+// replayed to secondary in the background, in order. If replaying fails, e.g.
+// because secondary's map is full, the journal keeps the writes and replaying
+// is retried with backoff, so secondary catches up once the cause is fixed.
+// The journal is stored in its own named database, which counts towards
+// WithNumDBs.
+//
+// Entries are written to secondary as stored, unless a DBRef of the same name
+// has been opened on secondary, in which case they are decoded and written
+// through it, so it may use different codecs, compression or indexes. Writes
+// that bypass a DBRef's Put and Delete, such as those of MultiRef, and writes
+// by other processes are not mirrored, nor are the DBRefs opened after Mirror
+// is called.
+func (db *Client) Mirror(secondary *Client) (*Mirror, error) {
+	if db == secondary {
+		return nil, errors.New("cannot mirror a Client to itself")
+	}
+	err := secondary.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize secondary: %w", err)
+	}
+
+	// The journal must exist before writes are journaled into it.
+	err = db.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(mirrorDB, dbCreate)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mirror journal: %w", err)
+	}
+
+	m := &Mirror{
+		db:        db,
+		secondary: secondary,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if !db.mirror.CompareAndSwap(nil, m) {
+		return nil, errors.New("client is already mirrored")
+	}
+
+	// The copy below subsumes writes left in the journal by an earlier
+	// Mirror, which would otherwise be replayed over newer values, as well
+	// as those journaled since m was installed.
+	err = db.update(func(txn writeTxn) error {
+		journal, err := txn.DBRef(mirrorDB, dbFlag(0))
+		if err != nil {
+			return err
+		}
+		return txn.Empty(journal)
+	})
+	if err != nil {
+		db.mirror.CompareAndSwap(m, nil)
+		return nil, fmt.Errorf("failed to clear mirror journal: %w", err)
+	}
+
+	// Writes are journaled from now on, so copying the current entries
+	// afterwards can't miss any, and replaying the journal after the copy
+	// leaves secondary with the newest values.
+	err = m.copyAll()
+	if err != nil {
+		db.mirror.CompareAndSwap(m, nil)
+		return nil, err
+	}
+
+	db.startBackground(m.run)
+	return m, nil
+}
+
+// copyAll copies the entries of every DBRef opened on the primary.
+func (m *Mirror) copyAll() error {
+	refs := m.db.registeredRefs()
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		if !strings.HasPrefix(name, internalPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var after []byte
+		for {
+			chunk, err := readChunk(m.db, name, nil, after, copyChunkSize)
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", name, err)
+			}
+			if len(chunk) == 0 {
+				break
+			}
+
+			recs := make([]mirrorRecord, len(chunk))
+			for i, rec := range chunk {
+				recs[i] = mirrorRecord{DBRef: name, Key: rec.key, Value: rec.val}
+			}
+			err = m.apply(recs)
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", name, err)
+			}
+
+			if len(chunk) < copyChunkSize {
+				break
+			}
+			after = chunk[len(chunk)-1].key
+		}
+	}
+
+	return nil
+}
+
+// apply writes recs to the secondary in one transaction.
+func (m *Mirror) apply(recs []mirrorRecord) error {
+	src, dst := m.db.registeredRefs(), m.secondary.registeredRefs()
+
+	return m.secondary.update(func(txn writeTxn) error {
+		for _, rec := range recs {
+			from, ok := src[rec.DBRef]
+			if !ok {
+				return fmt.Errorf("no DBRef %s opened on primary", rec.DBRef)
+			}
+
+			var to *refInfo
+			if info, ok := dst[rec.DBRef]; ok {
+				to = &info
+			}
+			err := writeRecord(txn, rec, from, to)
+			if err != nil {
+				return err
+			}
+			m.secondary.invalidateInTxn(txn, rec.DBRef, rec.Key)
+			if !rec.Delete {
+				err = m.secondary.bloomAddInTxn(txn, rec.DBRef, nil, rec.Key)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// writeRecord writes rec, stored by a DBRef described by from, in txn. If to
+// is set, rec is decoded and written through the DBRef it describes, so its
+// indexes and triggers are maintained; otherwise it is written as stored.
+func writeRecord(txn writeTxn, rec mirrorRecord, from refInfo, to *refInfo) error {
+	if to != nil && from.flags != 0 && to.encoding != from.encoding {
+		return fmt.Errorf("cannot re-encode %s, which stores several values per key", rec.DBRef)
+	}
+	if to != nil && from.flags == 0 {
+		key, err := from.decodeKey(rec.Key)
+		if err != nil {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+		var val any
+		if !rec.Delete {
+			val, err = from.decodeVal(rec.Value)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+		}
+		return to.write(txn, key, val)
+	}
+
+	dbRef, err := txn.DBRef(rec.DBRef, from.flags|dbCreate)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	if rec.Delete {
+		err = txn.Delete(dbRef, rec.Key, nil)
+		if errors.Is(err, ErrNotFound) {
+			err = nil
+		}
+	} else {
+		err = txn.Put(dbRef, rec.Key, rec.Value, putFlag(0))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", rec.DBRef, err)
+	}
+
+	return nil
+}
+
+// mirrorInTxn journals a put of keyBytes, or a delete if del is set, if the
+// Client is mirrored.
+func (ref *DBRef[K, V]) mirrorInTxn(txn writeTxn, keyBytes, valBytes []byte, del bool) error {
+	if ref.ownerDB.mirror.Load() == nil || strings.HasPrefix(ref.id, internalPrefix) {
+		return nil
+	}
+
+	journal, err := txn.DBRef(mirrorDB, dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get mirror journal db ref: %w", err)
+	}
+	seq, err := lastSeq(txn, journal)
+	if err != nil {
+		return err
+	}
+
+	recBytes, err := GobCodec{}.Marshal(&mirrorRecord{DBRef: ref.id, Delete: del, Key: keyBytes, Value: valBytes})
+	if err != nil {
+		return fmt.Errorf("failed to encode mirror record: %w", err)
+	}
+	err = txn.Put(journal, binary.BigEndian.AppendUint64(nil, seq+1), recBytes, putNoOverwrite)
+	if err != nil {
+		return fmt.Errorf("failed to journal write: %w", err)
+	}
+
+	return nil
+}
+
+// notify wakes the replayer up after a write transaction committed.
+func (m *Mirror) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run replays the journal until stop or Stop.
+func (m *Mirror) run(stop <-chan struct{}) {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Duration(0)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		full, err := m.replay()
+		m.mu.Lock()
+		m.lastErr = err
+		m.mu.Unlock()
+
+		if err != nil {
+			m.db.options.log.Error().Err(err).Msg("failed to mirror writes")
+			backoff = 2*backoff + 100*time.Millisecond
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			select {
+			case <-stop:
+				return
+			case <-m.done:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		if full {
+			continue
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-m.done:
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// replay writes the next chunk of the journal to the secondary and removes it
+// from the journal. It reports whether the chunk was full.
+func (m *Mirror) replay() (full bool, err error) {
+	var keys [][]byte
+	var recs []mirrorRecord
+	err = m.db.view(func(txn readTxn) error {
+		journal, err := txn.DBRef(mirrorDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get mirror journal db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(journal)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		key, val, err := cursor.First()
+		for ; err == nil && len(recs) < copyChunkSize; key, val, err = cursor.Next() {
+			var rec mirrorRecord
+			err = GobCodec{}.Unmarshal(val, &rec)
+			if err != nil {
+				return fmt.Errorf("failed to decode mirror record: %w", err)
+			}
+			keys = append(keys, append([]byte(nil), key...))
+			recs = append(recs, rec)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read mirror journal: %w", err)
+		}
+		return nil
+	})
+	if err != nil || len(recs) == 0 {
+		return false, err
+	}
+
+	err = m.apply(recs)
+	if err != nil {
+		return false, err
+	}
+
+	// Replaying is idempotent, so if this fails the chunk is just replayed
+	// again.
+	err = m.db.update(func(txn writeTxn) error {
+		journal, err := txn.DBRef(mirrorDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get mirror journal db ref: %w", err)
+		}
+		for _, key := range keys {
+			err = txn.Delete(journal, key, nil)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to trim mirror journal: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(recs) == copyChunkSize, nil
+}
+
+// Pending returns the number of journaled writes not yet replayed to the
+// secondary. Once it is zero, the secondary is in sync as of the last write.
+func (m *Mirror) Pending() (n int, err error) {
+	err = m.db.view(func(txn readTxn) error {
+		journal, err := txn.DBRef(mirrorDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get mirror journal db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(journal)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		_, _, err = cursor.First()
+		for ; err == nil; _, _, err = cursor.Next() {
+			n++
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read mirror journal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Err returns the error of the last attempt to replay the journal, or nil if
+// it succeeded.
+func (m *Mirror) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastErr
+}
+
+// Stop stops journaling and replaying writes. Writes still in the journal
+// are not replayed; calling Mirror again copies the data afresh.
+func (m *Mirror) Stop() {
+	m.stopOnce.Do(func() {
+		m.db.mirror.CompareAndSwap(m, nil)
+		close(m.done)
+	})
+}
@@ -0,0 +1,165 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// waitSynced waits for m to replay its journal.
+func waitSynced(t *testing.T, m *ezdb.Mirror) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := m.Pending()
+		if err != nil {
+			t.Fatalf("Pending: %v", err)
+		}
+		if n == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("mirror didn't catch up: %v", m.Err())
+}
+
+func TestMirror(t *testing.T) {
+	primary := testutil.NewTempClient(t)
+	secondary := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", primary)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+	// The secondary stores these values as JSON.
+	recoded, err := ezdb.NewRef[string, string]("recoded", primary)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, recoded, 10)
+	secondaryRecoded, err := ezdb.NewDBRef[string, string](secondary, "recoded", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	queue, err := ezdb.NewQueue[string](primary, "queue")
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+
+	m, err := primary.Mirror(secondary)
+	if err != nil {
+		t.Fatalf("Mirror: %v", err)
+	}
+	defer m.Stop()
+	copied, err := ezdb.NewRef[string, string]("ref", secondary)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	// The existing entries are copied before Mirror returns.
+	wantN(t, copied, 100)
+	wantN(t, secondaryRecoded, 10)
+
+	putN(t, ref, 200)
+	for i := 10; i < 20; i++ {
+		key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+		err = recoded.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	key := "k0199"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	val := "job"
+	err = queue.Enqueue(&val)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	waitSynced(t, m)
+	if m.Err() != nil {
+		t.Fatalf("Err: %v", m.Err())
+	}
+	wantN(t, copied, 199)
+	wantN(t, secondaryRecoded, 20)
+	secondaryQueue, err := ezdb.NewQueue[string](secondary, "queue")
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	got, ok, err := secondaryQueue.Peek()
+	if err != nil || !ok || *got != "job" {
+		t.Fatalf("Peek of the mirrored queue = %v, %t, %v", got, ok, err)
+	}
+
+	// Writes after Stop aren't journaled.
+	m.Stop()
+	key, val = "k0199", "v199"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	n, err := m.Pending()
+	if err != nil || n != 0 {
+		t.Fatalf("Pending after Stop = %d, %v", n, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	wantN(t, copied, 199)
+}
+
+func TestMirrorRetriesFailedReplays(t *testing.T) {
+	primary := testutil.NewTempClient(t)
+	secondary := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", primary)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	m, err := primary.Mirror(secondary)
+	if err != nil {
+		t.Fatalf("Mirror: %v", err)
+	}
+	defer m.Stop()
+	err = secondary.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	putN(t, ref, 10)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for m.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !errors.Is(m.Err(), ezdb.ErrClosed) {
+		t.Fatalf("Err after closing the secondary = %v, want ErrClosed", m.Err())
+	}
+	// The writes stay journaled.
+	n, err := m.Pending()
+	if err != nil || n != 10 {
+		t.Fatalf("Pending = %d, %v, want 10", n, err)
+	}
+	// Mirroring again fails without touching the journal.
+	_, err = primary.Mirror(testutil.NewTempClient(t))
+	if err == nil {
+		t.Fatal("Mirror of a mirrored Client succeeded")
+	}
+	n, err = m.Pending()
+	if err != nil || n != 10 {
+		t.Fatalf("Pending after a second Mirror = %d, %v, want 10", n, err)
+	}
+}
+
+func TestMirrorToItself(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	_, err := db.Mirror(db)
+	if err == nil {
+		t.Fatal("Mirror to itself succeeded")
+	}
+}
@@ -0,0 +1,223 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MultiRef is a reference to a named database storing any number of distinct
+// values per key, using LMDB's DUPSORT mode. The values of a key are kept in
+// the order of their encoding. Since LMDB stores them like keys, an encoded
+// value may be at most 511 bytes long.
+type MultiRef[K, V any] struct {
+	ref *DBRef[K, V]
+}
+
+// NewMultiRef opens the named multi-valued database name of db, creating it
+// if it doesn't exist. opts are the same as for NewDBRef. Values must encode
+// deterministically, which GobCodec and JSONCodec do for values without maps,
+// for RemoveValue to find them.
+func NewMultiRef[K, V any](db *Client, name string, opts ...RefOption) (*MultiRef[K, V], error) {
+	o, err := newRefOptions[K, V](opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.checkDBFlags(dbDupSort)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := new(DBRef[K, V])
+	err = ref.init(name, db, o, dbDupSort|o.dbFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize db ref: %w", err)
+	}
+	ref.register(dbDupSort | o.dbFlags)
+
+	return &MultiRef[K, V]{ref: ref}, nil
+}
+
+// encode validates and encodes a key/value pair about to be written.
+func (m *MultiRef[K, V]) encode(key *K, val *V) (keyBytes, valBytes []byte, err error) {
+	keyBytes, valBytes, err = m.ref.encodePair(key, val)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(valBytes) > maxKeySize {
+		return nil, nil, &SizeError{Err: ErrValueTooLarge, DB: m.ref.id, Size: len(valBytes), Limit: maxKeySize}
+	}
+
+	return keyBytes, valBytes, nil
+}
+
+// Add adds val to the values of key. Adding a value the key already has is
+// a no-op.
+func (m *MultiRef[K, V]) Add(key *K, val *V) error {
+	keyBytes, valBytes, err := m.encode(key, val)
+	if err != nil {
+		return err
+	}
+
+	return m.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(m.ref.id, dbDupSort|m.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		// LMDB doesn't check the sizes of fixed-size values, and mixing
+		// them corrupts those of the key.
+		if m.ref.options.dbFlags&dbDupFixed != 0 {
+			first, err := txn.Get(dbRef, keyBytes)
+			if err == nil && len(first) != len(valBytes) {
+				return fmt.Errorf("value of %d bytes among fixed-size values of %d bytes", len(valBytes), len(first))
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to get key: %w", err)
+			}
+		}
+
+		err = txn.Put(dbRef, keyBytes, valBytes, putNoDupData)
+		if errors.Is(err, ErrKeyExists) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to put key/value pair: %w", err)
+		}
+
+		return m.ref.auditInTxn(txn, opPut, keyBytes)
+	})
+}
+
+// RemoveValue removes val from the values of key. A missing pair is reported
+// as an error matching ErrNotFound.
+func (m *MultiRef[K, V]) RemoveValue(key *K, val *V) error {
+	keyBytes, err := m.ref.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	valBytes, err := m.ref.encodeVal(val)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	return m.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(m.ref.id, dbDupSort|m.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		err = txn.Delete(dbRef, keyBytes, valBytes)
+		if err != nil {
+			return fmt.Errorf("failed to delete key/value pair: %w", err)
+		}
+
+		return m.ref.auditInTxn(txn, opDelete, keyBytes)
+	})
+}
+
+// Delete removes key with all its values. A missing key is reported as an
+// error matching ErrNotFound.
+func (m *MultiRef[K, V]) Delete(key *K) error {
+	keyBytes, err := m.ref.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	return m.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(m.ref.id, dbDupSort|m.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		err = txn.Delete(dbRef, keyBytes, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete key: %w", err)
+		}
+
+		return m.ref.auditInTxn(txn, opDelete, keyBytes)
+	})
+}
+
+// GetAll returns the values of key in the order of their encoding, or no
+// values if key is missing.
+func (m *MultiRef[K, V]) GetAll(key *K) (vals []V, err error) {
+	keyBytes, err := m.ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	err = m.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(m.ref.id, dbDupSort|m.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		valBytes, err := cursor.SeekExactKey(keyBytes)
+		for ; err == nil; _, valBytes, err = cursor.NextInSameKey() {
+			val, err := m.ref.decodeVal(valBytes)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			vals = append(vals, *val)
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+// Count returns the number of values of key.
+func (m *MultiRef[K, V]) Count(key *K) (n int, err error) {
+	keyBytes, err := m.ref.encodeKey(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	err = m.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(m.ref.id, dbDupSort|m.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		_, err = cursor.SeekExactKey(keyBytes)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		count, err := cursor.Count()
+		if err != nil {
+			return fmt.Errorf("failed to count values: %w", err)
+		}
+		n = int(count)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,80 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestMultiRef(t *testing.T) {
+	for name, db := range map[string]*ezdb.Client{
+		"lmdb":   testutil.NewTempClient(t),
+		"memory": testutil.NewMemoryClient(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := ezdb.NewMultiRef[string, string](db, "tags", ezdb.WithCodec(ezdb.StringCodec{}))
+			if err != nil {
+				t.Fatalf("NewMultiRef: %v", err)
+			}
+
+			key := "post"
+			for _, val := range []string{"go", "db", "lmdb", "db"} {
+				val := val
+				err = m.Add(&key, &val)
+				if err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+			}
+			vals, err := m.GetAll(&key)
+			if err != nil || fmt.Sprint(vals) != "[db go lmdb]" {
+				t.Fatalf("GetAll = %v, %v", vals, err)
+			}
+			n, err := m.Count(&key)
+			if err != nil || n != 3 {
+				t.Fatalf("Count = %d, %v, want 3", n, err)
+			}
+
+			val := "go"
+			err = m.RemoveValue(&key, &val)
+			if err != nil {
+				t.Fatalf("RemoveValue: %v", err)
+			}
+			err = m.RemoveValue(&key, &val)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("second RemoveValue returned %v, want ErrNotFound", err)
+			}
+			vals, err = m.GetAll(&key)
+			if err != nil || fmt.Sprint(vals) != "[db lmdb]" {
+				t.Fatalf("GetAll after RemoveValue = %v, %v", vals, err)
+			}
+
+			err = m.Delete(&key)
+			if err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			err = m.Delete(&key)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("second Delete returned %v, want ErrNotFound", err)
+			}
+			vals, err = m.GetAll(&key)
+			if err != nil || len(vals) != 0 {
+				t.Fatalf("GetAll after Delete = %v, %v", vals, err)
+			}
+			n, err = m.Count(&key)
+			if err != nil || n != 0 {
+				t.Fatalf("Count after Delete = %d, %v", n, err)
+			}
+
+			// Values are stored like keys, so they are limited like them.
+			val = strings.Repeat("v", 512)
+			err = m.Add(&key, &val)
+			if !errors.Is(err, ezdb.ErrValueTooLarge) {
+				t.Fatalf("Add of a long value returned %v, want ErrValueTooLarge", err)
+			}
+		})
+	}
+}
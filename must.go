@@ -0,0 +1,30 @@
+package ezdb
+
+// The Must variants panic instead of returning an error. They are meant for
+// tests, migrations and one-off scripts, where error plumbing is pure noise.
+
+// MustInit is like Init but panics on error.
+func (db *Client) MustInit() {
+	err := db.Init()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MustPut is like Put but panics on error.
+func (ref *DBRef[K, V]) MustPut(key *K, val *V) {
+	err := ref.Put(key, val)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MustGet is like Get but panics on error, including a missing key.
+func (ref *DBRef[K, V]) MustGet(key *K) *V {
+	val, err := ref.Get(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return val
+}
@@ -0,0 +1,50 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// panicValue returns the value fn panics with, or nil if it returns.
+func panicValue(fn func()) (v any) {
+	defer func() { v = recover() }()
+	fn()
+	return nil
+}
+
+func TestMustVariants(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	db.MustInit()
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key, val := "k", "v"
+	ref.MustPut(&key, &val)
+	if got := ref.MustGet(&key); *got != val {
+		t.Fatalf("MustGet = %q, want %q", *got, val)
+	}
+
+	missing := "missing"
+	p := panicValue(func() { ref.MustGet(&missing) })
+	if err, ok := p.(error); !ok || !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("MustGet of a missing key panicked with %v, want ErrNotFound", p)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	p = panicValue(func() { ref.MustPut(&key, &val) })
+	if err, ok := p.(error); !ok || !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("MustPut after Close panicked with %v, want ErrClosed", p)
+	}
+	p = panicValue(db.MustInit)
+	if err, ok := p.(error); !ok || !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("MustInit after Close panicked with %v, want ErrClosed", p)
+	}
+}
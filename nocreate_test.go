@@ -0,0 +1,85 @@
+package ezdb_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestNoCreate(t *testing.T) {
+	for _, test := range []struct {
+		engine ezdb.Engine
+		new    func(path string, opts ...ezdb.Option) (*ezdb.Client, error)
+	}{
+		{ezdb.EngineLMDB, ezdb.New},
+		{ezdb.EngineBolt, ezdb.NewBolt},
+		{ezdb.EnginePebble, ezdb.NewPebble},
+	} {
+		t.Run(string(test.engine), func(t *testing.T) {
+			open := func(dir string, opts ...ezdb.Option) (*ezdb.Client, error) {
+				db, err := test.new(dir, append([]ezdb.Option{ezdb.WithNumDBs(16)}, opts...)...)
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				t.Cleanup(func() { db.Close() })
+				return db, db.Init()
+			}
+
+			// Neither a missing directory nor an empty one is created.
+			for _, dir := range []string{filepath.Join(t.TempDir(), "missing"), t.TempDir()} {
+				_, err := open(dir, ezdb.WithNoCreate())
+				if !errors.Is(err, ezdb.ErrNotFound) {
+					t.Fatalf("Init of %s returned %v, want ErrNotFound", dir, err)
+				}
+			}
+
+			dir := t.TempDir()
+			db, err := open(dir)
+			if err != nil {
+				t.Fatalf("Init: %v", err)
+			}
+			ref, err := ezdb.NewRef[string, string]("ref", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			putN(t, ref, 3)
+			err = db.Close()
+			if err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			db, err = open(dir, ezdb.WithNoCreate())
+			if err != nil {
+				t.Fatalf("Init of an existing environment: %v", err)
+			}
+			ref, err = ezdb.NewRef[string, string]("ref", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			wantN(t, ref, 3)
+			_, err = ezdb.NewRef[string, string]("missing", db)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("NewRef of a missing database returned %v, want ErrNotFound", err)
+			}
+
+			// Bookkeeping databases are still created.
+			_, err = ezdb.NewDBRef[string, string](db, "ref", ezdb.WithTTL(0, 0))
+			if err != nil {
+				t.Fatalf("NewDBRef with a TTL: %v", err)
+			}
+		})
+	}
+}
+
+func TestNoCreateMemory(t *testing.T) {
+	db := testutil.NewMemoryClient(t, ezdb.WithNoCreate())
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 3)
+	wantN(t, ref, 3)
+}
@@ -0,0 +1,248 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// oplogDB is the named database the operation log of a Client is stored in.
+const oplogDB = "ezdb.oplog"
+
+// WithOplog records every Put and Delete on the Client's DBRefs, with the
+// value written, in an append-only operation log, in the same transaction as
+// the write. Each entry gets a log sequence number (LSN) one higher than the
+// one before, so the log can be replayed with ReplayFrom from any point, e.g.
+// onto a restored backup for point-in-time recovery, or to synchronize
+// another system. It leaves out writes that bypass DBRef.Put and
+// DBRef.Delete, such as those of MultiRefs and SliceRefs, which the audit
+// log does record, and writes to DBRefs whose names start with "ezdb.". The
+// log is stored in its own named database, which counts towards WithNumDBs,
+// and grows until it is truncated with TruncateOplog.
+func WithOplog() Option {
+	return func(option *options) error {
+		option.oplog = true
+		return nil
+	}
+}
+
+// OplogEntry is an entry of the operation log.
+type OplogEntry struct {
+	// LSN is the position of the entry in the log, starting at 1.
+	LSN uint64
+	// DBRef is the name of the DBRef written to.
+	DBRef string
+	// Op is "put" or "delete".
+	Op string
+	// Key and Value are the key and value written, as stored. Value is nil
+	// for deletes.
+	Key   []byte
+	Value []byte
+	// Time is when the write was made.
+	Time time.Time
+}
+
+// oplogInTxn appends an entry for a write of keyBytes to the operation log, if
+// it is enabled.
+func (ref *DBRef[K, V]) oplogInTxn(txn writeTxn, op string, keyBytes, valBytes []byte) error {
+	if !ref.ownerDB.options.oplog || strings.HasPrefix(ref.id, internalPrefix) {
+		return nil
+	}
+
+	oplogRef, err := txn.DBRef(oplogDB, dbCreate)
+	if err != nil {
+		return fmt.Errorf("failed to get oplog db ref: %w", err)
+	}
+	lsn, err := lastSeq(txn, oplogRef)
+	if err != nil {
+		return err
+	}
+
+	entryBytes, err := GobCodec{}.Marshal(&OplogEntry{
+		LSN:   lsn + 1,
+		DBRef: ref.id,
+		Op:    op,
+		Key:   keyBytes,
+		Value: valBytes,
+		Time:  time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode oplog entry: %w", err)
+	}
+
+	err = txn.Put(oplogRef, binary.BigEndian.AppendUint64(nil, lsn+1), entryBytes, putNoOverwrite)
+	if err != nil {
+		return fmt.Errorf("failed to append oplog entry: %w", err)
+	}
+
+	return nil
+}
+
+// LastLSN returns the LSN of the last entry of the operation log, or 0 if it
+// is empty. A backup taken with Backup includes the log, so after restoring
+// it, replaying the source's log from LastLSN()+1 recovers the writes made
+// since.
+func (db *Client) LastLSN() (lsn uint64, err error) {
+	err = db.view(func(txn readTxn) error {
+		oplogRef, err := txn.DBRef(oplogDB, dbFlag(0))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get oplog db ref: %w", err)
+		}
+
+		lsn, err = lastSeq(txn, oplogRef)
+		return err
+	})
+
+	return lsn, err
+}
+
+// ReplayFrom calls fn with the entries of the operation log from the one with
+// LSN lsn on, in order, until fn returns an error, which ReplayFrom returns.
+// Entries are read in chunks, each in its own read transaction, and fn is
+// called outside of them, so it may write to the Client, e.g. with
+// ApplyOplogEntry; entries appended while replaying are replayed too.
+func (db *Client) ReplayFrom(lsn uint64, fn func(entry *OplogEntry) error) error {
+	if !db.options.oplog {
+		return errors.New("oplog is not enabled, see WithOplog")
+	}
+	if lsn == 0 {
+		lsn = 1
+	}
+
+	for {
+		var entries []*OplogEntry
+		err := db.view(func(txn readTxn) error {
+			oplogRef, err := txn.DBRef(oplogDB, dbFlag(0))
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get oplog db ref: %w", err)
+			}
+
+			cursor, err := txn.NewCursor(oplogRef)
+			if err != nil {
+				return fmt.Errorf("failed to open cursor: %w", err)
+			}
+			defer cursor.Close()
+
+			_, entryBytes, err := cursor.SeekGreaterThanOrEqualKey(binary.BigEndian.AppendUint64(nil, lsn))
+			for ; err == nil && len(entries) < copyChunkSize; _, entryBytes, err = cursor.Next() {
+				entry := &OplogEntry{}
+				err = GobCodec{}.Unmarshal(entryBytes, entry)
+				if err != nil {
+					return fmt.Errorf("failed to decode oplog entry: %w", err)
+				}
+				entries = append(entries, entry)
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to read oplog: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err = fn(entry)
+			if err != nil {
+				return err
+			}
+		}
+		if len(entries) < copyChunkSize {
+			return nil
+		}
+		lsn = entries[len(entries)-1].LSN + 1
+	}
+}
+
+// ApplyOplogEntry applies the write recorded by entry, which may come from
+// the operation log of another Client. If a DBRef of the entry's name has
+// been opened on the Client, the write goes through it, so its indexes and
+// triggers see it and it is logged in the Client's own operation log;
+// otherwise it is written as stored.
+func (db *Client) ApplyOplogEntry(entry *OplogEntry) error {
+	var from refInfo
+	var to *refInfo
+	if info, ok := db.registeredRefs()[entry.DBRef]; ok {
+		from, to = info, &info
+	}
+	rec := mirrorRecord{DBRef: entry.DBRef, Delete: entry.Op == opDelete, Key: entry.Key, Value: entry.Value}
+
+	err := db.update(func(txn writeTxn) error {
+		return writeRecord(txn, rec, from, to)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply oplog entry %d: %w", entry.LSN, err)
+	}
+
+	return nil
+}
+
+// TruncateOplog deletes the entries of the operation log up to and including
+// the one with LSN through, e.g. once they are covered by a backup, except for
+// the last entry, which is kept so new entries continue its LSNs.
+func (db *Client) TruncateOplog(through uint64) error {
+	for {
+		var done bool
+		err := db.update(func(txn writeTxn) error {
+			oplogRef, err := txn.DBRef(oplogDB, dbFlag(0))
+			if errors.Is(err, ErrNotFound) {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get oplog db ref: %w", err)
+			}
+
+			// The last entry is kept, so LSNs keep increasing.
+			last, err := lastSeq(txn, oplogRef)
+			if err != nil {
+				return err
+			}
+			if last == 0 {
+				done = true
+				return nil
+			}
+			if through >= last {
+				through = last - 1
+			}
+
+			cursor, err := txn.NewCursor(oplogRef)
+			if err != nil {
+				return fmt.Errorf("failed to open cursor: %w", err)
+			}
+			defer cursor.Close()
+
+			var keys [][]byte
+			key, _, err := cursor.First()
+			for ; err == nil && len(keys) < copyChunkSize; key, _, err = cursor.Next() {
+				if binary.BigEndian.Uint64(key) > through {
+					break
+				}
+				keys = append(keys, append([]byte(nil), key...))
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to read oplog: %w", err)
+			}
+			done = len(keys) < copyChunkSize
+
+			for _, key := range keys {
+				err = txn.Delete(oplogRef, key, nil)
+				if err != nil {
+					return fmt.Errorf("failed to delete oplog entry: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
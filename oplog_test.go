@@ -0,0 +1,242 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// oplogEntries returns the entries of db's operation log from lsn on.
+func oplogEntries(t *testing.T, db *ezdb.Client, lsn uint64) []*ezdb.OplogEntry {
+	t.Helper()
+
+	var entries []*ezdb.OplogEntry
+	err := db.ReplayFrom(lsn, func(entry *ezdb.OplogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+	return entries
+}
+
+func TestOplog(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithOplog())
+	lsn, err := db.LastLSN()
+	if err != nil || lsn != 0 {
+		t.Fatalf("LastLSN of an empty oplog = %d, %v", lsn, err)
+	}
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 3)
+	key := "k0001"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	lsn, err = db.LastLSN()
+	if err != nil || lsn != 4 {
+		t.Fatalf("LastLSN = %d, %v, want 4", lsn, err)
+	}
+	entries := oplogEntries(t, db, 0)
+	if len(entries) != 4 {
+		t.Fatalf("replayed %d entries, want 4", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.LSN != uint64(i+1) || entry.DBRef != "ref" || entry.Time.IsZero() {
+			t.Fatalf("entry %d = %+v", i, entry)
+		}
+	}
+	if entries[0].Op != "put" || entries[0].Value == nil {
+		t.Fatalf("entry of a Put = %+v", entries[0])
+	}
+	if entries[3].Op != "delete" || entries[3].Value != nil {
+		t.Fatalf("entry of a Delete = %+v", entries[3])
+	}
+
+	entries = oplogEntries(t, db, 3)
+	if len(entries) != 2 || entries[0].LSN != 3 {
+		t.Fatalf("replayed %d entries from LSN 3, want 2", len(entries))
+	}
+	if entries = oplogEntries(t, db, 5); len(entries) != 0 {
+		t.Fatalf("replayed %d entries past the end of the log", len(entries))
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = db.ReplayFrom(1, func(*ezdb.OplogEntry) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Fatalf("ReplayFrom with a failing fn returned %v after %d calls", err, calls)
+	}
+}
+
+func TestOplogLeavesOutInternalRefs(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithOplog())
+	ref, err := ezdb.NewRef[string, string]("ezdb.internal", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 1)
+
+	lsn, err := db.LastLSN()
+	if err != nil || lsn != 0 {
+		t.Fatalf("LastLSN after an internal write = %d, %v, want 0", lsn, err)
+	}
+}
+
+func TestReplayFromChunks(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithOplog())
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 2500)
+
+	entries := oplogEntries(t, db, 1000)
+	if len(entries) != 1501 {
+		t.Fatalf("replayed %d entries, want 1501", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.LSN != uint64(1000+i) {
+			t.Fatalf("entry %d has LSN %d, want %d", i, entry.LSN, 1000+i)
+		}
+	}
+}
+
+func TestReplayFromWithoutOplog(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	err := db.ReplayFrom(1, func(*ezdb.OplogEntry) error { return nil })
+	if err == nil {
+		t.Fatal("ReplayFrom without WithOplog succeeded")
+	}
+	lsn, err := db.LastLSN()
+	if err != nil || lsn != 0 {
+		t.Fatalf("LastLSN without WithOplog = %d, %v", lsn, err)
+	}
+}
+
+func TestApplyOplogEntry(t *testing.T) {
+	src := testutil.NewTempClient(t, ezdb.WithOplog())
+	srcRef, err := ezdb.NewRef[string, string]("ref", src)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, srcRef, 3)
+	key := "k0002"
+	err = srcRef.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	entries := oplogEntries(t, src, 0)
+
+	t.Run("raw", func(t *testing.T) {
+		dst := testutil.NewTempClient(t, ezdb.WithOplog())
+		for _, entry := range entries {
+			err := dst.ApplyOplogEntry(entry)
+			if err != nil {
+				t.Fatalf("ApplyOplogEntry: %v", err)
+			}
+		}
+
+		// Without a DBRef, writes are stored as is and not logged again.
+		lsn, err := dst.LastLSN()
+		if err != nil || lsn != 0 {
+			t.Fatalf("LastLSN after raw writes = %d, %v, want 0", lsn, err)
+		}
+		ref, err := ezdb.NewRef[string, string]("ref", dst)
+		if err != nil {
+			t.Fatalf("NewRef: %v", err)
+		}
+		wantN(t, ref, 2)
+	})
+
+	t.Run("through DBRef", func(t *testing.T) {
+		dst := testutil.NewTempClient(t, ezdb.WithOplog())
+		ref, err := ezdb.NewRef[string, string]("ref", dst)
+		if err != nil {
+			t.Fatalf("NewRef: %v", err)
+		}
+		for _, entry := range entries {
+			err := dst.ApplyOplogEntry(entry)
+			if err != nil {
+				t.Fatalf("ApplyOplogEntry: %v", err)
+			}
+		}
+
+		wantN(t, ref, 2)
+		lsn, err := dst.LastLSN()
+		if err != nil || lsn != 4 {
+			t.Fatalf("LastLSN after writes through a DBRef = %d, %v, want 4", lsn, err)
+		}
+	})
+
+	t.Run("twice", func(t *testing.T) {
+		dst := testutil.NewTempClient(t)
+		ref, err := ezdb.NewRef[string, string]("ref", dst)
+		if err != nil {
+			t.Fatalf("NewRef: %v", err)
+		}
+
+		// Replaying is idempotent, so a delete of a missing key succeeds.
+		for i := 0; i < 2; i++ {
+			for _, entry := range entries {
+				err := dst.ApplyOplogEntry(entry)
+				if err != nil {
+					t.Fatalf("ApplyOplogEntry %d: %v", entry.LSN, err)
+				}
+			}
+		}
+		wantN(t, ref, 2)
+	})
+}
+
+func TestTruncateOplog(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithOplog())
+	err := db.TruncateOplog(10)
+	if err != nil {
+		t.Fatalf("TruncateOplog of an empty oplog: %v", err)
+	}
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 2500)
+
+	err = db.TruncateOplog(2000)
+	if err != nil {
+		t.Fatalf("TruncateOplog: %v", err)
+	}
+	entries := oplogEntries(t, db, 0)
+	if len(entries) != 500 || entries[0].LSN != 2001 {
+		t.Fatalf("kept %d entries, want 500 from LSN 2001", len(entries))
+	}
+
+	// The last entry is kept, so LSNs continue.
+	err = db.TruncateOplog(10000)
+	if err != nil {
+		t.Fatalf("TruncateOplog: %v", err)
+	}
+	entries = oplogEntries(t, db, 0)
+	if len(entries) != 1 || entries[0].LSN != 2500 {
+		t.Fatalf("kept %v, want only the last entry", entries)
+	}
+	key, val := "new", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	lsn, err := db.LastLSN()
+	if err != nil || lsn != 2501 {
+		t.Fatalf("LastLSN after truncating = %d, %v, want 2501", lsn, err)
+	}
+}
@@ -0,0 +1,325 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// OrderedCodec encodes values so that the byte order of the encodings, which
+// is the order LMDB keeps keys in, matches the natural order of the values.
+// Use it as the key codec of a DBRef, or the codec of an Index, to make range
+// and prefix scans meaningful.
+//
+// Supported are booleans, integers, floats, strings, byte slices, time.Time,
+// and arrays, slices, pointers and structs of supported types. Structs are
+// encoded as the tuple of their exported fields in declaration order, so they
+// sort by their first field, then their second, and so on. Every integer is
+// encoded in 8 bytes regardless of its size, except for the elements of byte
+// arrays, which are stored as they are. Times are encoded with
+// nanosecond precision and decoded in UTC.
+type OrderedCodec struct{}
+
+const (
+	// Strings and byte slices are terminated by escTerm, and zero bytes within
+	// them are escaped as escZero, so that a string sorts before every longer
+	// string it is a prefix of.
+	escByte = 0x00
+	escTerm = 0x01
+	escZero = 0xFF
+
+	// Elements of slices are preceded by seqMore and the slice is terminated
+	// by seqEnd; pointers are encoded as seqEnd if nil and as seqMore followed
+	// by the pointee otherwise.
+	seqEnd  = 0x00
+	seqMore = 0x01
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var errShortOrdered = errors.New("ordered encoding too short")
+
+func (OrderedCodec) Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("cannot encode nil pointer")
+		}
+		rv = rv.Elem()
+	}
+
+	return appendOrdered(nil, rv)
+}
+
+func (OrderedCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("cannot decode into non-pointer")
+	}
+
+	rest, err := readOrdered(data, rv.Elem())
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d trailing bytes after ordered encoding", len(rest))
+	}
+
+	return nil
+}
+
+func appendOrdered(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		return binary.BigEndian.AppendUint64(buf, uint64(t.UnixNano())^(1<<63)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.BigEndian.AppendUint64(buf, uint64(v.Int())^(1<<63)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.BigEndian.AppendUint64(buf, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		bits := math.Float64bits(v.Float())
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(buf, bits), nil
+	case reflect.String:
+		return appendEscaped(buf, []byte(v.String())), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendEscaped(buf, v.Bytes()), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			buf = append(buf, seqMore)
+			buf, err = appendOrdered(buf, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, seqEnd), nil
+	case reflect.Array:
+		// Byte arrays have a fixed length, so they are ordered as they are.
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				buf = append(buf, byte(v.Index(i).Uint()))
+			}
+			return buf, nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			buf, err = appendOrdered(buf, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, seqEnd), nil
+		}
+		return appendOrdered(append(buf, seqMore), v.Elem())
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			buf, err = appendOrdered(buf, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+
+	return nil, fmt.Errorf("ordered encoding of %s is not supported", v.Type())
+}
+
+func appendEscaped(buf, b []byte) []byte {
+	for _, c := range b {
+		if c == escByte {
+			buf = append(buf, escByte, escZero)
+			continue
+		}
+		buf = append(buf, c)
+	}
+
+	return append(buf, escByte, escTerm)
+}
+
+func readOrdered(data []byte, v reflect.Value) ([]byte, error) {
+	if v.Type() == timeType {
+		if len(data) < 8 {
+			return nil, errShortOrdered
+		}
+		nanos := int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+		v.Set(reflect.ValueOf(time.Unix(0, nanos).UTC()))
+		return data[8:], nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 {
+			return nil, errShortOrdered
+		}
+		v.SetBool(data[0] != 0)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(data) < 8 {
+			return nil, errShortOrdered
+		}
+		n := int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+		if v.OverflowInt(n) {
+			return nil, fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+		return data[8:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if len(data) < 8 {
+			return nil, errShortOrdered
+		}
+		n := binary.BigEndian.Uint64(data)
+		if v.OverflowUint(n) {
+			return nil, fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+		return data[8:], nil
+	case reflect.Float32, reflect.Float64:
+		if len(data) < 8 {
+			return nil, errShortOrdered
+		}
+		bits := binary.BigEndian.Uint64(data)
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		v.SetFloat(math.Float64frombits(bits))
+		return data[8:], nil
+	case reflect.String:
+		b, rest, err := readEscaped(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetString(string(b))
+		return rest, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, rest, err := readEscaped(data)
+			if err != nil {
+				return nil, err
+			}
+			v.SetBytes(b)
+			return rest, nil
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		for {
+			if len(data) < 1 {
+				return nil, errShortOrdered
+			}
+			if data[0] == seqEnd {
+				return data[1:], nil
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			var err error
+			data, err = readOrdered(data[1:], elem)
+			if err != nil {
+				return nil, err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if len(data) < v.Len() {
+				return nil, errShortOrdered
+			}
+			for i := 0; i < v.Len(); i++ {
+				v.Index(i).SetUint(uint64(data[i]))
+			}
+			return data[v.Len():], nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			data, err = readOrdered(data, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	case reflect.Pointer:
+		if len(data) < 1 {
+			return nil, errShortOrdered
+		}
+		if data[0] == seqEnd {
+			v.SetZero()
+			return data[1:], nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		rest, err := readOrdered(data[1:], elem.Elem())
+		if err != nil {
+			return nil, err
+		}
+		v.Set(elem)
+		return rest, nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			data, err = readOrdered(data, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("ordered decoding of %s is not supported", v.Type())
+}
+
+func readEscaped(data []byte) (b, rest []byte, err error) {
+	for i := 0; i < len(data); i++ {
+		if data[i] != escByte {
+			b = append(b, data[i])
+			continue
+		}
+		if i+1 >= len(data) {
+			return nil, nil, errShortOrdered
+		}
+		i++
+		switch data[i] {
+		case escTerm:
+			if b == nil {
+				b = []byte{}
+			}
+			return b, data[i+1:], nil
+		case escZero:
+			b = append(b, escByte)
+		default:
+			return nil, nil, fmt.Errorf("invalid escape 0x%02x in ordered encoding", data[i])
+		}
+	}
+
+	return nil, nil, errShortOrdered
+}
+
+// orderedPrefix returns the ordered encoding of s without its terminator,
+// which the encodings of all strings starting with s start with.
+func orderedPrefix(s string) []byte {
+	enc := appendEscaped(nil, []byte(s))
+	return enc[:len(enc)-2]
+}
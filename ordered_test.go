@@ -0,0 +1,126 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// checkOrdered checks that the encodings of vals, which are in ascending
+// order, are too, and that they decode to the values they encode.
+func checkOrdered[T any](t *testing.T, vals ...T) {
+	t.Helper()
+
+	var codec ezdb.OrderedCodec
+	var prev []byte
+	for i, v := range vals {
+		enc, err := codec.Marshal(&v)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", v, err)
+		}
+		if i > 0 && bytes.Compare(prev, enc) >= 0 {
+			t.Errorf("encoding of %v doesn't sort after that of %v", v, vals[i-1])
+		}
+		prev = enc
+
+		var got T
+		err = codec.Unmarshal(enc, &got)
+		if err != nil {
+			t.Fatalf("Unmarshal of %v: %v", v, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("Unmarshal of %v = %v", v, got)
+		}
+	}
+}
+
+func TestOrderedCodecOrder(t *testing.T) {
+	checkOrdered(t, false, true)
+	checkOrdered(t, math.MinInt64, -300, -1, 0, 1, 255, 256, math.MaxInt64)
+	checkOrdered(t, int8(-128), int8(-1), int8(0), int8(127))
+	checkOrdered(t, uint64(0), uint64(1), uint64(1<<32), uint64(math.MaxUint64))
+	checkOrdered(t, math.Inf(-1), -1e300, -1.5, -1e-300, 0, 1e-300, 1.5, math.Inf(1))
+	checkOrdered(t, float32(-2.5), float32(0), float32(3.25))
+	checkOrdered(t, "", "a", "a\x00", "a\x00\x00", "a\x01", "ab", "b", "\xff")
+	checkOrdered(t, []byte{}, []byte{0}, []byte{0, 1}, []byte{1})
+	checkOrdered(t, [2]byte{0, 9}, [2]byte{1, 0}, [2]byte{1, 1})
+	checkOrdered(t, []int{}, []int{-1}, []int{1}, []int{1, 2}, []int{2})
+	checkOrdered(t, []string{"a"}, []string{"a", ""}, []string{"ab"})
+	checkOrdered(t, [2]int{1, 2}, [2]int{1, 3}, [2]int{2, 0})
+	type optional struct {
+		P *int
+	}
+	one, two := 1, 2
+	checkOrdered(t, optional{}, optional{&one}, optional{&two})
+
+	base := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	checkOrdered(t, time.Unix(0, 0).UTC(), base, base.Add(time.Nanosecond), base.Add(time.Hour))
+
+	type name struct {
+		Last, First string
+		private     int
+	}
+	checkOrdered(t, name{"Hopper", "Grace", 0}, name{"Lovelace", "Ada", 0}, name{"Lovelace", "Byron", 0})
+}
+
+func TestOrderedCodecTimesDecodeInUTC(t *testing.T) {
+	var codec ezdb.OrderedCodec
+	local := time.Date(2026, 10, 14, 8, 0, 0, 5, time.FixedZone("X", 3600))
+	enc, err := codec.Marshal(&local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got time.Time
+	err = codec.Unmarshal(enc, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(local) || got.Location() != time.UTC {
+		t.Fatalf("Unmarshal = %v, want %v in UTC", got, local)
+	}
+}
+
+func TestOrderedCodecErrors(t *testing.T) {
+	var codec ezdb.OrderedCodec
+
+	if _, err := codec.Marshal(map[string]int{}); err == nil {
+		t.Error("Marshal of a map succeeded")
+	}
+	if _, err := codec.Marshal((*int)(nil)); err == nil {
+		t.Error("Marshal of a nil pointer succeeded")
+	}
+	if _, err := codec.Marshal(struct{ C chan int }{}); err == nil {
+		t.Error("Marshal of a struct with a channel succeeded")
+	}
+
+	var n int
+	if err := codec.Unmarshal(nil, n); err == nil {
+		t.Error("Unmarshal into a non-pointer succeeded")
+	}
+	enc, _ := codec.Marshal(300)
+	if err := codec.Unmarshal(enc[:5], &n); err == nil {
+		t.Error("Unmarshal of a short integer succeeded")
+	}
+	if err := codec.Unmarshal(append(enc, 0), &n); err == nil {
+		t.Error("Unmarshal with trailing bytes succeeded")
+	}
+	var i8 int8
+	if err := codec.Unmarshal(enc, &i8); err == nil {
+		t.Error("Unmarshal of 300 into an int8 succeeded")
+	}
+
+	var s string
+	for _, data := range [][]byte{[]byte("abc"), []byte("abc\x00"), []byte("abc\x00\x07")} {
+		if err := codec.Unmarshal(data, &s); err == nil {
+			t.Errorf("Unmarshal of %q into a string succeeded", data)
+		}
+	}
+	var ints []int
+	if err := codec.Unmarshal([]byte{1}, &ints); err == nil {
+		t.Error("Unmarshal of an unterminated slice succeeded")
+	}
+}
@@ -0,0 +1,624 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// pebbleDirName is the name of the Pebble directory in a Client's
+	// directory.
+	pebbleDirName = "data.pebble"
+	// pebbleCatalog is the id of the keys recording the named databases:
+	// the name of each maps to its id and flags, and the empty name to the
+	// next id.
+	pebbleCatalog = dbi(0)
+)
+
+// NewPebble returns a Client that keeps its named databases in a Pebble
+// log-structured merge tree in the directory path instead of an LMDB
+// environment. Writes only append to Pebble's log and memtable, which
+// flushes and compacts in the background, so workloads dominated by writes
+// of random keys spend far less time committing than on LMDB, whose single
+// writer rewrites the pages of a B+tree; reads are slower in turn. Write
+// transactions still run one at a time. The DBRef API is the same.
+//
+// Pebble locks its directory, so only one process can have it open at a
+// time. Features that read or copy LMDB's files, such as Stats, Backup
+// and ReaderCheck, and the LMDB-only database flags fail with an error
+// matching ErrUnsupported, see Capabilities, and options that tune LMDB,
+// such as WithNumReaders, have no effect.
+func NewPebble(path string, opts ...Option) (*Client, error) {
+	opts = append(opts, func(option *options) error {
+		option.engine = EnginePebble
+		option.openBackend = func(path string) (backend, error) {
+			return openPebble(path, option.envFlags&envReadOnly != 0, *option.log)
+		}
+		return nil
+	})
+
+	return New(path, opts...)
+}
+
+// pebbleBackend is the backend of NewPebble. All named databases share
+// Pebble's key space: each key is prefixed with the big-endian id of its
+// database, which is also its dbi. A dbDupSort database stores each of its
+// pairs as a key of its own, see dupKey.
+type pebbleBackend struct {
+	db *pebble.DB
+
+	// writer serializes write transactions.
+	writer sync.Mutex
+
+	// flags are the flags of the committed named databases by dbi.
+	mu    sync.Mutex
+	flags map[dbi]dbFlag
+}
+
+// pebbleLogger logs Pebble's messages to the Client's logger.
+type pebbleLogger struct {
+	log zerolog.Logger
+}
+
+func (l pebbleLogger) Infof(format string, args ...any) {
+	l.log.Info().Msgf(format, args...)
+}
+
+func (l pebbleLogger) Fatalf(format string, args ...any) {
+	l.log.Fatal().Msgf(format, args...)
+}
+
+func openPebble(dir string, readOnly bool, log zerolog.Logger) (*pebbleBackend, error) {
+	db, err := pebble.Open(filepath.Join(dir, pebbleDirName), &pebble.Options{
+		ReadOnly: readOnly,
+		Logger:   pebbleLogger{log},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	b := &pebbleBackend{db: db, flags: make(map[dbi]dbFlag)}
+	err = b.loadCatalog()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read database catalog: %w", err)
+	}
+
+	return b, nil
+}
+
+// loadCatalog records the flags of every named database.
+func (b *pebbleBackend) loadCatalog() error {
+	lower, upper := pebbleBounds(pebbleCatalog)
+	it := b.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	defer it.Close()
+
+	for valid := it.First(); valid; valid = it.Next() {
+		if len(it.Key()) == len(lower) {
+			// The next id.
+			continue
+		}
+		id, flags, err := parseCatalogEntry(it.Value())
+		if err != nil {
+			return err
+		}
+		b.flags[id] = flags
+	}
+
+	return it.Error()
+}
+
+func (b *pebbleBackend) View(fn func(txn readTxn) error) error {
+	snap := b.db.NewSnapshot()
+	defer snap.Close()
+
+	return fn(&pebbleTxn{b: b, r: snap})
+}
+
+func (b *pebbleBackend) Update(fn func(txn writeTxn) error) error {
+	b.writer.Lock()
+	defer b.writer.Unlock()
+
+	batch := b.db.NewIndexedBatch()
+	defer batch.Close()
+	txn := &pebbleTxn{b: b, r: batch, batch: batch}
+	err := fn(txn)
+	if err != nil {
+		return err
+	}
+
+	// Readers find the databases the transaction created as soon as it
+	// commits, so their flags are recorded first. Their ids aren't in use
+	// until then.
+	b.mu.Lock()
+	for id, flags := range txn.created {
+		b.flags[id] = flags
+	}
+	b.mu.Unlock()
+
+	err = batch.Commit(pebble.Sync)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		for id := range txn.created {
+			delete(b.flags, id)
+		}
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	for id := range txn.dropped {
+		delete(b.flags, id)
+	}
+
+	return nil
+}
+
+func (b *pebbleBackend) Sync(force bool) error {
+	return b.db.LogData(nil, pebble.Sync)
+}
+
+func (b *pebbleBackend) Copy(path string, compact bool) error {
+	return fmt.Errorf("failed to copy pebble environment: %w", ErrUnsupported)
+}
+
+func (b *pebbleBackend) Close() {
+	b.db.Close()
+}
+
+// dbFlags returns the flags of the committed named database db.
+func (b *pebbleBackend) dbFlags(db dbi) (dbFlag, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	flags, ok := b.flags[db]
+	return flags, ok
+}
+
+// pebbleReader is what a pebbleTxn reads from: a snapshot for read
+// transactions and an indexed batch, which sees its own writes, for write
+// transactions.
+type pebbleReader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+	NewIter(o *pebble.IterOptions) *pebble.Iterator
+}
+
+// pebbleTxn is a transaction on a pebbleBackend.
+type pebbleTxn struct {
+	b     *pebbleBackend
+	r     pebbleReader
+	batch *pebble.Batch
+	// created and dropped are the named databases the transaction created
+	// and dropped, by dbi.
+	created map[dbi]dbFlag
+	dropped map[dbi]bool
+	// writes counts the writes of the transaction, so that its cursors know
+	// when to refresh their view of the batch.
+	writes uint64
+}
+
+func (t *pebbleTxn) DBRef(name string, flags dbFlag) (dbi, error) {
+	val, err := t.get(pebbleKey(pebbleCatalog, []byte(name)))
+	if err == nil {
+		id, _, err := parseCatalogEntry(val)
+		return id, err
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return 0, fmt.Errorf("failed to open database %q: %w", name, err)
+	}
+	if t.batch == nil || flags&dbCreate == 0 {
+		return 0, fmt.Errorf("failed to open database %q: %w", name, ErrNotFound)
+	}
+
+	if flags&lmdbOnlyDBFlags != 0 {
+		return 0, fmt.Errorf("failed to create database %q with flags %#x: %w", name, flags, ErrUnsupported)
+	}
+	id := pebbleCatalog + 1
+	nextKey := pebbleKey(pebbleCatalog, nil)
+	next, err := t.get(nextKey)
+	if err == nil && len(next) == 4 {
+		id = dbi(binary.BigEndian.Uint32(next))
+	}
+	flags &^= dbCreate
+	entry := binary.BigEndian.AppendUint32(nil, uint32(id))
+	entry = binary.BigEndian.AppendUint32(entry, uint32(flags))
+	err = t.set(pebbleKey(pebbleCatalog, []byte(name)), entry)
+	if err == nil {
+		err = t.set(nextKey, binary.BigEndian.AppendUint32(nil, uint32(id+1)))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create database %q: %w", name, err)
+	}
+
+	if t.created == nil {
+		t.created = make(map[dbi]dbFlag)
+	}
+	t.created[id] = flags
+	return id, nil
+}
+
+func (t *pebbleTxn) ListDBs() ([]namedDB, error) {
+	lower, upper := pebbleBounds(pebbleCatalog)
+	it := t.r.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	defer it.Close()
+
+	var dbs []namedDB
+	for valid := it.First(); valid; valid = it.Next() {
+		if len(it.Key()) == len(lower) {
+			// The next id.
+			continue
+		}
+		_, flags, err := parseCatalogEntry(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, namedDB{name: string(it.Key()[len(lower):]), flags: flags})
+	}
+
+	return dbs, it.Error()
+}
+
+// dup reports whether db is a dbDupSort database.
+func (t *pebbleTxn) dup(db dbi) (bool, error) {
+	flags, ok := t.created[db]
+	if !ok {
+		flags, ok = t.b.dbFlags(db)
+	}
+	if !ok || t.dropped[db] {
+		return false, fmt.Errorf("invalid database %d", db)
+	}
+
+	return flags&dbDupSort != 0, nil
+}
+
+// get returns a copy of the value of the Pebble key k.
+func (t *pebbleTxn) get(k []byte) ([]byte, error) {
+	val, closer, err := t.r.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	return append([]byte{}, val...), nil
+}
+
+func (t *pebbleTxn) set(k, val []byte) error {
+	t.writes++
+	return t.batch.Set(k, val, nil)
+}
+
+func (t *pebbleTxn) Get(db dbi, key []byte) ([]byte, error) {
+	dup, err := t.dup(db)
+	if err != nil {
+		return nil, err
+	}
+	if dup {
+		c := t.newCursor(db, dup)
+		defer c.Close()
+		return c.SeekExactKey(key)
+	}
+
+	return t.get(pebbleKey(db, key))
+}
+
+func (t *pebbleTxn) NewCursor(db dbi) (dbCursor, error) {
+	dup, err := t.dup(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.newCursor(db, dup), nil
+}
+
+func (t *pebbleTxn) Put(db dbi, key, val []byte, flags putFlag) error {
+	dup, err := t.dup(db)
+	if err != nil {
+		return err
+	}
+
+	k := pebbleKey(db, key)
+	if dup {
+		k = pebbleKey(db, dupKey(key, val))
+	}
+	if flags&(putNoOverwrite|putNoDupData) != 0 {
+		_, err := t.Get(db, key)
+		if err == nil && flags&putNoOverwrite != 0 {
+			return ErrKeyExists
+		}
+		if err == nil && dup && flags&putNoDupData != 0 {
+			if _, err := t.get(k); err == nil {
+				return ErrKeyExists
+			}
+		}
+	}
+	if flags&putAppend != 0 {
+		c := t.newCursor(db, false)
+		valid := c.it.Last()
+		last := append([]byte(nil), c.it.Key()...)
+		c.Close()
+		if valid && bytes.Compare(last, k) >= 0 {
+			return ErrKeyExists
+		}
+	}
+
+	if dup {
+		return t.set(k, []byte{})
+	}
+	return t.set(k, val)
+}
+
+func (t *pebbleTxn) Delete(db dbi, key, val []byte) error {
+	dup, err := t.dup(db)
+	if err != nil {
+		return err
+	}
+
+	if !dup || val != nil {
+		k := pebbleKey(db, key)
+		if dup {
+			k = pebbleKey(db, dupKey(key, val))
+		}
+		_, err := t.get(k)
+		if err != nil {
+			return err
+		}
+		t.writes++
+		return t.batch.Delete(k, nil)
+	}
+
+	_, err = t.Get(db, key)
+	if err != nil {
+		return err
+	}
+	prefix := pebbleKey(db, dupKey(key, nil))
+	t.writes++
+	return t.batch.DeleteRange(prefix, prefixEnd(prefix), nil)
+}
+
+func (t *pebbleTxn) Empty(db dbi) error {
+	_, err := t.dup(db)
+	if err != nil {
+		return err
+	}
+
+	lower, upper := pebbleBounds(db)
+	t.writes++
+	err = t.batch.DeleteRange(lower, upper, nil)
+	if err != nil {
+		return fmt.Errorf("failed to empty database %d: %w", db, err)
+	}
+
+	return nil
+}
+
+func (t *pebbleTxn) Drop(db dbi) error {
+	err := t.Empty(db)
+	if err != nil {
+		return err
+	}
+
+	// Find the name of db in the catalog.
+	lower, upper := pebbleBounds(pebbleCatalog)
+	it := t.r.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	defer it.Close()
+	for valid := it.First(); valid; valid = it.Next() {
+		if len(it.Key()) == len(lower) {
+			continue
+		}
+		id, _, err := parseCatalogEntry(it.Value())
+		if err != nil {
+			return err
+		}
+		if id != db {
+			continue
+		}
+
+		t.writes++
+		err = t.batch.Delete(it.Key(), nil)
+		if err != nil {
+			return fmt.Errorf("failed to drop database %d: %w", db, err)
+		}
+		if t.dropped == nil {
+			t.dropped = make(map[dbi]bool)
+		}
+		t.dropped[db] = true
+		delete(t.created, db)
+		return nil
+	}
+
+	return fmt.Errorf("failed to drop database %d: %w", db, ErrNotFound)
+}
+
+// pebbleKey returns the Pebble key of key in db.
+func pebbleKey(db dbi, key []byte) []byte {
+	k := make([]byte, 4, 4+len(key))
+	binary.BigEndian.PutUint32(k, uint32(db))
+	return append(k, key...)
+}
+
+// pebbleBounds returns the bounds of the Pebble keys of db.
+func pebbleBounds(db dbi) (lower, upper []byte) {
+	return pebbleKey(db, nil), pebbleKey(db+1, nil)
+}
+
+// parseCatalogEntry decodes the id and flags recorded for a named database.
+func parseCatalogEntry(val []byte) (dbi, dbFlag, error) {
+	if len(val) != 8 {
+		return 0, 0, fmt.Errorf("invalid catalog entry of %d bytes", len(val))
+	}
+
+	return dbi(binary.BigEndian.Uint32(val)), dbFlag(binary.BigEndian.Uint32(val[4:])), nil
+}
+
+// pebbleCursor is a cursor on a named database. It remembers the Pebble key
+// it is on and seeks back to it whenever the transaction wrote since its
+// last move, since a batch iterator only sees the writes made before it was
+// last refreshed, and ezdb writes while iterating.
+type pebbleCursor struct {
+	txn  *pebbleTxn
+	it   *pebble.Iterator
+	opts pebble.IterOptions
+	dup  bool
+	// cur is the Pebble key the cursor is on, or nil if it isn't on one, and
+	// writes the number of writes of txn the iterator sees.
+	cur    []byte
+	writes uint64
+}
+
+func (t *pebbleTxn) newCursor(db dbi, dup bool) *pebbleCursor {
+	lower, upper := pebbleBounds(db)
+	c := &pebbleCursor{
+		txn:    t,
+		opts:   pebble.IterOptions{LowerBound: lower, UpperBound: upper},
+		dup:    dup,
+		writes: t.writes,
+	}
+	c.it = t.r.NewIter(&c.opts)
+
+	return c
+}
+
+// refresh makes the iterator see the transaction's writes, which unpositions
+// it.
+func (c *pebbleCursor) refresh() {
+	if c.writes != c.txn.writes {
+		c.it.SetOptions(&c.opts)
+		c.writes = c.txn.writes
+	}
+}
+
+// onCur reports whether the iterator is positioned on cur and sees every
+// write.
+func (c *pebbleCursor) onCur() bool {
+	return c.writes == c.txn.writes && c.it.Valid() && bytes.Equal(c.it.Key(), c.cur)
+}
+
+// moveTo positions the cursor on the iterator's entry if valid, and returns
+// the pair it holds. Otherwise the cursor stays where it is.
+func (c *pebbleCursor) moveTo(valid bool) ([]byte, []byte, error) {
+	if !valid {
+		return nil, nil, ErrNotFound
+	}
+
+	c.cur = append(c.cur[:0], c.it.Key()...)
+	k := append([]byte(nil), c.cur[4:]...)
+	if c.dup {
+		key, val := splitDupKey(k)
+		return key, val, nil
+	}
+	return k, append([]byte{}, c.it.Value()...), nil
+}
+
+func (c *pebbleCursor) First() ([]byte, []byte, error) {
+	c.refresh()
+	return c.moveTo(c.it.First())
+}
+
+func (c *pebbleCursor) Last() ([]byte, []byte, error) {
+	c.refresh()
+	return c.moveTo(c.it.Last())
+}
+
+func (c *pebbleCursor) Next() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.First()
+	}
+
+	if !c.onCur() {
+		c.refresh()
+		if !c.it.SeekGE(c.cur) {
+			return nil, nil, ErrNotFound
+		}
+		if !bytes.Equal(c.it.Key(), c.cur) {
+			// cur was deleted, and the iterator is on its successor.
+			return c.moveTo(true)
+		}
+	}
+	return c.moveTo(c.it.Next())
+}
+
+func (c *pebbleCursor) Prev() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.Last()
+	}
+
+	if !c.onCur() {
+		c.refresh()
+		return c.moveTo(c.it.SeekLT(c.cur))
+	}
+	return c.moveTo(c.it.Prev())
+}
+
+func (c *pebbleCursor) NextInSameKey() ([]byte, []byte, error) {
+	if c.cur == nil || !c.dup {
+		return nil, nil, ErrNotFound
+	}
+
+	cur := append([]byte(nil), c.cur...)
+	key, _ := splitDupKey(cur[4:])
+	k, v, err := c.Next()
+	if err != nil || !bytes.Equal(k, key) {
+		c.cur = cur
+		return nil, nil, ErrNotFound
+	}
+	return k, v, nil
+}
+
+func (c *pebbleCursor) Count() (uint64, error) {
+	if c.cur == nil {
+		return 0, ErrNotFound
+	}
+	if !c.dup {
+		return 1, nil
+	}
+
+	key, _ := splitDupKey(c.cur[4:])
+	prefix := append(c.cur[:4:4], dupKey(key, nil)...)
+	it := c.txn.r.NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixEnd(prefix)})
+	defer it.Close()
+	var n uint64
+	for valid := it.First(); valid; valid = it.Next() {
+		n++
+	}
+
+	return n, it.Error()
+}
+
+func (c *pebbleCursor) SeekExactKey(key []byte) ([]byte, error) {
+	k := append(c.opts.LowerBound[:4:4], key...)
+	if c.dup {
+		k = append(c.opts.LowerBound[:4:4], dupKey(key, nil)...)
+	}
+
+	c.refresh()
+	valid := c.it.SeekGE(k)
+	if !valid || !c.dup && !bytes.Equal(c.it.Key(), k) || c.dup && !bytes.HasPrefix(c.it.Key(), k) {
+		return nil, ErrNotFound
+	}
+	_, val, err := c.moveTo(true)
+	return val, err
+}
+
+func (c *pebbleCursor) SeekGreaterThanOrEqualKey(key []byte) ([]byte, []byte, error) {
+	k := append(c.opts.LowerBound[:4:4], key...)
+	if c.dup {
+		k = append(c.opts.LowerBound[:4:4], dupKey(key, nil)...)
+	}
+
+	c.refresh()
+	return c.moveTo(c.it.SeekGE(k))
+}
+
+func (c *pebbleCursor) Close() {
+	c.it.Close()
+}
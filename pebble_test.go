@@ -0,0 +1,201 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newPebbleClient returns an initialized Client from NewPebble in dir,
+// closed when t finishes.
+func newPebbleClient(t *testing.T, dir string, opts ...Option) *Client {
+	t.Helper()
+
+	db, err := NewPebble(dir, opts...)
+	if err != nil {
+		t.Fatalf("NewPebble: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestPebbleBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T, dir string) *Client {
+		return newPebbleClient(t, dir)
+	})
+}
+
+func TestPebbleCatalog(t *testing.T) {
+	dir := t.TempDir()
+	db := newPebbleClient(t, dir)
+	env, release, err := db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	put(t, env, "dropped", 0, "a", "1")
+	put(t, env, "dup", dbDupSort, "a", "1", "a", "2")
+
+	var dropped dbi
+	err = env.Update(func(txn writeTxn) (err error) {
+		dropped, err = txn.DBRef("dropped", 0)
+		if err != nil {
+			return err
+		}
+		return txn.Drop(dropped)
+	})
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The flags of databases outlive the Client, and the ids of dropped ones
+	// aren't handed out again.
+	db = newPebbleClient(t, dir)
+	env, release, err = db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	wantEntries(t, env, "dup", "a=1", "a=2")
+	put(t, env, "dropped", 0)
+	err = env.View(func(txn readTxn) error {
+		id, err := txn.DBRef("dropped", 0)
+		if err == nil && id == dropped {
+			err = fmt.Errorf("database created again got the id %d of the dropped one", id)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries(t, env, "dropped")
+}
+
+func TestPebbleWritesWhileIterating(t *testing.T) {
+	db := newPebbleClient(t, t.TempDir())
+	env, release, err := db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	put(t, env, "db", 0, "a", "1", "b", "2", "c", "3", "d", "4")
+
+	err = env.Update(func(txn writeTxn) error {
+		db, err := txn.DBRef("db", 0)
+		if err != nil {
+			return err
+		}
+		c, err := txn.NewCursor(db)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		// Deleting the entry the cursor is on leaves it between its
+		// neighbours.
+		_, _, err = c.SeekGreaterThanOrEqualKey([]byte("b"))
+		if err != nil {
+			return err
+		}
+		err = txn.Delete(db, []byte("b"), nil)
+		if err != nil {
+			return err
+		}
+		key, _, err := c.Prev()
+		if err != nil || string(key) != "a" {
+			return fmt.Errorf("Prev after deleting the current entry = %q, %v", key, err)
+		}
+		err = txn.Put(db, []byte("b2"), []byte("x"), 0)
+		if err != nil {
+			return err
+		}
+		key, val, err := c.Next()
+		if err != nil || string(key) != "b2" || string(val) != "x" {
+			return fmt.Errorf("Next after a Put = %q=%q, %v", key, val, err)
+		}
+
+		// Entries written after an Empty are kept.
+		err = txn.Empty(db)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(db, []byte("e"), []byte("5"), 0)
+		if err != nil {
+			return err
+		}
+		key, val, err = c.First()
+		if err != nil || string(key) != "e" || string(val) != "5" {
+			return fmt.Errorf("First after Empty and Put = %q=%q, %v", key, val, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries(t, env, "db", "e=5")
+}
+
+func TestPebbleReadersSeeNewDatabases(t *testing.T) {
+	db := newPebbleClient(t, t.TempDir())
+	env, release, err := db.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+
+	// Readers racing a writer that creates databases can use every one they
+	// find.
+	const n = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			err := env.Update(func(txn writeTxn) error {
+				db, err := txn.DBRef(fmt.Sprint("db", i), dbCreate)
+				if err != nil {
+					return err
+				}
+				return txn.Put(db, []byte("a"), []byte("1"), 0)
+			})
+			if err != nil {
+				t.Errorf("Update: %v", err)
+				return
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		err := env.View(func(txn readTxn) error {
+			for i := 0; i < n; i++ {
+				db, err := txn.DBRef(fmt.Sprint("db", i), 0)
+				if errors.Is(err, ErrNotFound) {
+					return nil
+				}
+				if err == nil {
+					_, err = txn.Get(db, []byte("a"))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			<-done
+			t.Fatalf("View: %v", err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ezdb
+
+import "fmt"
+
+// GetAs decodes the value stored under key into a P rather than a V, e.g. a
+// struct declaring only the few fields of V a caller needs. Both GobCodec and
+// JSONCodec match fields by name and skip the ones P lacks, so large nested
+// fields that P leaves out are never materialized. A missing key is reported
+// as an error matching ErrNotFound.
+func GetAs[P, K, V any](ref *DBRef[K, V], key *K) (*P, error) {
+	proj := new(P)
+	ok, err := ref.getRaw(key, func(valBytes []byte) error {
+		err := ref.decodeValInto(valBytes, proj)
+		if err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return proj, nil
+}
+
+// DecodeAs decodes l into a P rather than a V, like GetAs does.
+func DecodeAs[P, V any](l *Lazy[V]) (*P, error) {
+	proj := new(P)
+	err := l.decodeInto(l.raw, proj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return proj, nil
+}
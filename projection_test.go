@@ -0,0 +1,73 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// userName declares only the Name field of user.
+type userName struct {
+	Name string
+}
+
+func TestGetAs(t *testing.T) {
+	for name, codec := range map[string]ezdb.Codec{"gob": ezdb.GobCodec{}, "json": ezdb.JSONCodec{}} {
+		t.Run(name, func(t *testing.T) {
+			db := testutil.NewTempClient(t)
+			ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(codec), ezdb.WithCompression(1))
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			key, val := "ada", user{Name: "Ada", Email: "ada@example.com", Bio: "..."}
+			err = ref.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			got, err := ezdb.GetAs[userName](ref, &key)
+			if err != nil || got.Name != "Ada" {
+				t.Fatalf("GetAs = %v, %v", got, err)
+			}
+
+			lazy, err := ref.GetLazy(&key)
+			if err != nil {
+				t.Fatalf("GetLazy: %v", err)
+			}
+			got, err = ezdb.DecodeAs[userName](lazy)
+			if err != nil || got.Name != "Ada" {
+				t.Fatalf("DecodeAs = %v, %v", got, err)
+			}
+
+			missing := "missing"
+			_, err = ezdb.GetAs[userName](ref, &missing)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("GetAs of a missing key returned %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestGetAsMismatchedType(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// A projection whose field has another type can't be decoded into.
+	type badName struct {
+		Name int
+	}
+	_, err = ezdb.GetAs[badName](ref, &key)
+	if err == nil {
+		t.Fatal("GetAs into a mismatched type succeeded")
+	}
+}
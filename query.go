@@ -0,0 +1,325 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Query is a scan of a DBRef built up from conditions, created by
+// DBRef.Query. Key conditions are evaluated on the OrderedCodec encoding of
+// the keys: if the DBRef stores its keys with OrderedCodec, they narrow the
+// range of keys the scan visits, otherwise every entry is scanned and the
+// conditions are checked on the decoded keys.
+type Query[K, V any] struct {
+	ref     *DBRef[K, V]
+	from    []byte
+	to      []byte
+	filters []func(key *K, val *V) bool
+	desc    bool
+	limit   int
+	// after is the stored key the query starts after, see After.
+	after []byte
+	err   error
+}
+
+// Entry is a key/value pair returned by a Query.
+type Entry[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// Query returns a Query over all entries of ref, in ascending key order.
+func (ref *DBRef[K, V]) Query() *Query[K, V] {
+	return &Query[K, V]{ref: ref}
+}
+
+// WherePrefix restricts the query to keys starting with p. K must be a
+// string or byte slice, or a struct whose first exported field is one.
+func (q *Query[K, V]) WherePrefix(p string) *Query[K, V] {
+	if !stringLed(reflect.TypeOf((*K)(nil)).Elem()) {
+		q.setErr(errors.New("prefix queries need string keys"))
+		return q
+	}
+
+	prefix := orderedPrefix(p)
+	q.narrow(prefix, prefixEnd(prefix))
+	return q
+}
+
+// WhereRange restricts the query to keys in [from, to), in the order of
+// their OrderedCodec encoding. A nil bound leaves that side of the range
+// open.
+func (q *Query[K, V]) WhereRange(from, to *K) *Query[K, V] {
+	var fromEnc, toEnc []byte
+	var err error
+	if from != nil {
+		fromEnc, err = OrderedCodec{}.Marshal(from)
+		if err != nil {
+			q.setErr(fmt.Errorf("failed to encode key: %w", err))
+			return q
+		}
+	}
+	if to != nil {
+		toEnc, err = OrderedCodec{}.Marshal(to)
+		if err != nil {
+			q.setErr(fmt.Errorf("failed to encode key: %w", err))
+			return q
+		}
+	}
+
+	q.narrow(fromEnc, toEnc)
+	return q
+}
+
+// Filter restricts the query to entries pred returns true for. Filters are
+// applied in the order they were added, after the key conditions.
+func (q *Query[K, V]) Filter(pred func(key *K, val *V) bool) *Query[K, V] {
+	q.filters = append(q.filters, pred)
+	return q
+}
+
+// After makes the query start after key, in the order the query visits the
+// keys, i.e. that of the stored keys, whatever the DBRef's key codec. Passing
+// the last key of a page continues a scan where it left off, whether or not
+// key still exists.
+func (q *Query[K, V]) After(key *K) *Query[K, V] {
+	enc, err := q.ref.encodeKey(key)
+	if err != nil {
+		q.setErr(fmt.Errorf("failed to encode key: %w", err))
+		return q
+	}
+
+	q.after = enc
+	return q
+}
+
+// OrderDesc makes the query return entries in descending key order.
+func (q *Query[K, V]) OrderDesc() *Query[K, V] {
+	q.desc = true
+	return q
+}
+
+// Limit makes the query return at most n entries; zero means no limit.
+func (q *Query[K, V]) Limit(n int) *Query[K, V] {
+	q.limit = n
+	return q
+}
+
+// All runs the query and returns the matching entries.
+func (q *Query[K, V]) All() ([]Entry[K, V], error) {
+	var entries []Entry[K, V]
+	err := q.Each(func(key *K, val *V) error {
+		entries = append(entries, Entry[K, V]{Key: *key, Value: *val})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Each runs the query in a single read transaction and calls fn with every
+// matching entry. It stops at the first error fn returns, which Each
+// returns.
+func (q *Query[K, V]) Each(fn func(key *K, val *V) error) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	// lo and hi bound the stored keys the cursor visits. If the stored keys
+	// are ordered encodings, they include the key range; otherwise they only
+	// keep the cursor within the DBRef's namespace, and the key range is
+	// checked on the decoded keys, as it is with WithKeyPrefixes.
+	_, planned := q.ref.options.keyCodec.(OrderedCodec)
+	planned = planned && q.ref.options.keyPrefixes == nil && q.ref.options.dbFlags&(dbReverseKey|dbIntegerKey) == 0
+	lo, hi := q.ref.prefix, prefixEnd(q.ref.prefix)
+	if planned {
+		if q.from != nil {
+			lo = append(bytes.Clone(q.ref.prefix), q.from...)
+		}
+		if q.to != nil {
+			hi = append(bytes.Clone(q.ref.prefix), q.to...)
+		}
+	}
+	if q.after != nil {
+		if !q.desc && bytes.Compare(q.after, lo) > 0 {
+			lo = q.after
+		}
+		if q.desc && (hi == nil || bytes.Compare(q.after, hi) < 0) {
+			hi = q.after
+		}
+	}
+
+	return q.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(q.ref.id, q.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		var keyBytes, valBytes []byte
+		switch {
+		case !q.desc && len(lo) > 0:
+			keyBytes, valBytes, err = cursor.SeekGreaterThanOrEqualKey(lo)
+		case q.desc && hi != nil:
+			keyBytes, valBytes, err = cursor.SeekGreaterThanOrEqualKey(hi)
+			if err == nil {
+				keyBytes, valBytes, err = cursor.Prev()
+			} else if errors.Is(err, ErrNotFound) {
+				keyBytes, valBytes, err = cursor.Last()
+			}
+		case q.desc:
+			keyBytes, valBytes, err = cursor.Last()
+		default:
+			keyBytes, valBytes, err = cursor.First()
+		}
+
+		now := time.Now()
+		var n int
+		for ; err == nil; keyBytes, valBytes, err = q.step(cursor) {
+			if err := q.ref.ctxErr(); err != nil {
+				return err
+			}
+			if bytes.Compare(keyBytes, lo) < 0 || (hi != nil && bytes.Compare(keyBytes, hi) >= 0) {
+				// The cursor has left the range, which it started in.
+				return nil
+			}
+			if !q.desc && q.after != nil && bytes.Equal(keyBytes, q.after) {
+				continue
+			}
+
+			if q.ref.options.ttl != nil {
+				expired, err := q.ref.expiredInTxn(txn, keyBytes, now)
+				if err != nil {
+					return err
+				}
+				if expired {
+					continue
+				}
+			}
+
+			key, err := q.ref.decodeKey(keyBytes)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			if !planned && (q.from != nil || q.to != nil) {
+				enc, err := OrderedCodec{}.Marshal(key)
+				if err != nil {
+					return fmt.Errorf("failed to encode key: %w", err)
+				}
+				if !q.inRange(enc) {
+					continue
+				}
+			}
+
+			val, err := q.ref.decodeValInTxn(txn, valBytes)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			if !q.matches(key, val) {
+				continue
+			}
+
+			err = fn(key, val)
+			if err != nil {
+				return err
+			}
+			n++
+			if q.limit > 0 && n >= q.limit {
+				return nil
+			}
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (q *Query[K, V]) step(cursor dbCursor) ([]byte, []byte, error) {
+	if q.desc {
+		return cursor.Prev()
+	}
+
+	return cursor.Next()
+}
+
+func (q *Query[K, V]) matches(key *K, val *V) bool {
+	for _, pred := range q.filters {
+		if !pred(key, val) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q *Query[K, V]) inRange(enc []byte) bool {
+	if q.from != nil && bytes.Compare(enc, q.from) < 0 {
+		return false
+	}
+	if q.to != nil && bytes.Compare(enc, q.to) >= 0 {
+		return false
+	}
+
+	return true
+}
+
+// narrow intersects the query's key range with [from, to).
+func (q *Query[K, V]) narrow(from, to []byte) {
+	if from != nil && (q.from == nil || bytes.Compare(from, q.from) > 0) {
+		q.from = from
+	}
+	if to != nil && (q.to == nil || bytes.Compare(to, q.to) < 0) {
+		q.to = to
+	}
+}
+
+func (q *Query[K, V]) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// stringLed reports whether the ordered encoding of t starts with that of a
+// string.
+func stringLed(t reflect.Type) bool {
+	switch {
+	case t.Kind() == reflect.String:
+		return true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return true
+	case t.Kind() == reflect.Struct && t != timeType:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				return stringLed(t.Field(i).Type)
+			}
+		}
+	}
+
+	return false
+}
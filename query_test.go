@@ -0,0 +1,146 @@
+package ezdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// queryKeys runs q and returns the keys it returns.
+func queryKeys[V any](t *testing.T, q *ezdb.Query[string, V]) []string {
+	t.Helper()
+
+	entries, err := q.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+
+	return keys
+}
+
+func TestQuery(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	// The conditions narrow the scan of ordered keys and are checked on the
+	// decoded keys otherwise; with keys that sort alike, both give the same
+	// results.
+	for name, opts := range map[string][]ezdb.RefOption{
+		"ordered": {ezdb.WithKeyCodec(ezdb.OrderedCodec{})},
+		"string":  {ezdb.WithKeyCodec(ezdb.StringCodec{})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ref, err := ezdb.NewDBRef[string, int](db, name, opts...)
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			for i, key := range []string{"apple", "apricot", "banana", "blueberry", "cherry", "date"} {
+				i := i
+				key := key
+				err = ref.Put(&key, &i)
+				if err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+			}
+
+			for desc, c := range map[string]struct {
+				q    *ezdb.Query[string, int]
+				want string
+			}{
+				"all":    {ref.Query(), "[apple apricot banana blueberry cherry date]"},
+				"prefix": {ref.Query().WherePrefix("ap"), "[apple apricot]"},
+				"range":  {ref.Query().WhereRange(ptr("b"), ptr("cherry")), "[banana blueberry]"},
+				"open":   {ref.Query().WhereRange(ptr("cherry"), nil), "[cherry date]"},
+				"desc":   {ref.Query().WhereRange(nil, ptr("banana")).OrderDesc(), "[apricot apple]"},
+				"limit":  {ref.Query().OrderDesc().Limit(2), "[date cherry]"},
+				"filter": {ref.Query().Filter(func(key *string, val *int) bool { return *val%2 == 1 }), "[apricot blueberry date]"},
+				"both": {ref.Query().WherePrefix("b").Filter(func(key *string, val *int) bool {
+					return len(*key) > 6
+				}), "[blueberry]"},
+				"narrowed": {ref.Query().WherePrefix("b").WhereRange(ptr("a"), ptr("bb")), "[banana]"},
+				"empty":    {ref.Query().WherePrefix("x"), "[]"},
+			} {
+				if got := fmt.Sprint(queryKeys(t, c.q)); got != c.want {
+					t.Errorf("%s query = %s, want %s", desc, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[int, int]("ints", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	_, err = ref.Query().WherePrefix("a").All()
+	if err == nil {
+		t.Fatal("prefix query of integer keys succeeded")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestQueryAfter(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		i, key := i, key
+		err = ref.Put(&key, &i)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	for desc, c := range map[string]struct {
+		q    *ezdb.Query[string, int]
+		want string
+	}{
+		"after":     {ref.Query().After(ptr("b")), "[c d e]"},
+		"missing":   {ref.Query().After(ptr("bb")), "[c d e]"},
+		"page":      {ref.Query().After(ptr("a")).Limit(2), "[b c]"},
+		"desc":      {ref.Query().OrderDesc().After(ptr("d")), "[c b a]"},
+		"last":      {ref.Query().After(ptr("e")), "[]"},
+		"range":     {ref.Query().WhereRange(nil, ptr("d")).After(ptr("b")), "[c]"},
+		"before lo": {ref.Query().WhereRange(ptr("c"), nil).After(ptr("a")), "[c d e]"},
+	} {
+		if got := fmt.Sprint(queryKeys(t, c.q)); got != c.want {
+			t.Errorf("%s query = %s, want %s", desc, got, c.want)
+		}
+	}
+
+	// Paging with the last key of each page visits every key once, also
+	// within a namespace.
+	sub := ref.Sub("tenant")
+	for i, key := range []string{"x", "y", "z"} {
+		i, key := i, key
+		err = sub.Put(&key, &i)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	var seen []string
+	q := sub.Query().Limit(2)
+	for {
+		keys := queryKeys(t, q)
+		seen = append(seen, keys...)
+		if len(keys) < 2 {
+			break
+		}
+		q = sub.Query().Limit(2).After(&keys[len(keys)-1])
+	}
+	if got := fmt.Sprint(seen); got != "[x y z]" {
+		t.Fatalf("paged through %s, want [x y z]", got)
+	}
+}
@@ -0,0 +1,191 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Queue is a persistent FIFO queue of values of type T, stored in a named
+// database under consecutive uint64 keys. Every operation runs in a single
+// transaction, so concurrent Dequeues never return the same value twice.
+type Queue[T any] struct {
+	ref *DBRef[uint64, T]
+}
+
+// NewQueue opens the queue stored in the named database name of db, creating
+// it if it doesn't exist. opts are the same as for NewDBRef; the key codec
+// can't be changed.
+func NewQueue[T any](db *Client, name string, opts ...RefOption) (*Queue[T], error) {
+	ref, err := NewDBRef[uint64, T](db, name, append(opts, WithKeyCodec(OrderedCodec{}))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Queue[T]{ref: ref}, nil
+}
+
+// Enqueue appends val to the end of the queue.
+func (q *Queue[T]) Enqueue(val *T) error {
+	valBytes, err := q.ref.encodeVal(val)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	err = q.ref.checkSizes(0, len(valBytes))
+	if err != nil {
+		return err
+	}
+
+	return q.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(q.ref.id, q.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		_, last, ok, err := q.bounds(txn, dbRef)
+		if err != nil {
+			return err
+		}
+		next := uint64(1)
+		if ok {
+			next = last + 1
+		}
+
+		// Validators are passed the key, which is only known now.
+		err = q.ref.validate(&next, val)
+		if err != nil {
+			return err
+		}
+
+		keyBytes, err := q.ref.encodeKey(&next)
+		if err != nil {
+			return fmt.Errorf("failed to encode key: %w", err)
+		}
+
+		return q.ref.putInTxn(txn, dbRef, keyBytes, valBytes, val, putNoOverwrite)
+	})
+}
+
+// Dequeue removes and returns the value at the front of the queue, and
+// reports whether there was one.
+func (q *Queue[T]) Dequeue() (val *T, ok bool, err error) {
+	err = q.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(q.ref.id, q.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		var keyBytes []byte
+		keyBytes, val, err = q.front(txn, dbRef)
+		if err != nil || val == nil {
+			return err
+		}
+
+		// Copy keyBytes, which points into the database, before deleting.
+		return q.ref.deleteInTxn(txn, dbRef, bytes.Clone(keyBytes))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, val != nil, nil
+}
+
+// Peek returns the value at the front of the queue without removing it, and
+// reports whether there was one.
+func (q *Queue[T]) Peek() (val *T, ok bool, err error) {
+	err = q.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(q.ref.id, q.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		_, val, err = q.front(txn, dbRef)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, val != nil, nil
+}
+
+// Len returns the number of values in the queue.
+func (q *Queue[T]) Len() (n uint64, err error) {
+	err = q.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(q.ref.id, q.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		// Keys are consecutive, since values are only ever added at the back
+		// and removed at the front.
+		first, last, ok, err := q.bounds(txn, dbRef)
+		if ok {
+			n = last - first + 1
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// front returns the encoded key and decoded value at the front of the queue,
+// or nils if it is empty.
+func (q *Queue[T]) front(txn readTxn, dbRef dbi) ([]byte, *T, error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	keyBytes, valBytes, err := cursor.First()
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	val, err := q.ref.decodeValInTxn(txn, valBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return keyBytes, val, nil
+}
+
+// bounds returns the first and last key of the queue, and whether it has any.
+func (q *Queue[T]) bounds(txn readTxn, dbRef dbi) (first, last uint64, ok bool, err error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	firstBytes, _, err := cursor.First()
+	if errors.Is(err, ErrNotFound) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to read entry: %w", err)
+	}
+	lastBytes, _, err := cursor.Last()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	firstKey, err := q.ref.decodeKey(firstBytes)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to decode key: %w", err)
+	}
+	lastKey, err := q.ref.decodeKey(lastBytes)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to decode key: %w", err)
+	}
+
+	return *firstKey, *lastKey, true, nil
+}
@@ -0,0 +1,151 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestQueue(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	q, err := ezdb.NewQueue[string](db, "jobs")
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+
+	_, ok, err := q.Dequeue()
+	if err != nil || ok {
+		t.Fatalf("Dequeue of an empty queue = %t, %v", ok, err)
+	}
+	_, ok, err = q.Peek()
+	if err != nil || ok {
+		t.Fatalf("Peek of an empty queue = %t, %v", ok, err)
+	}
+
+	for _, job := range []string{"a", "b", "c"} {
+		job := job
+		err = q.Enqueue(&job)
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	n, err := q.Len()
+	if err != nil || n != 3 {
+		t.Fatalf("Len = %d, %v, want 3", n, err)
+	}
+	val, ok, err := q.Peek()
+	if err != nil || !ok || *val != "a" {
+		t.Fatalf("Peek = %v, %t, %v", val, ok, err)
+	}
+
+	for _, want := range []string{"a", "b"} {
+		val, ok, err = q.Dequeue()
+		if err != nil || !ok || *val != want {
+			t.Fatalf("Dequeue = %v, %t, %v, want %s", val, ok, err, want)
+		}
+	}
+	job := "d"
+	err = q.Enqueue(&job)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	n, err = q.Len()
+	if err != nil || n != 2 {
+		t.Fatalf("Len = %d, %v, want 2", n, err)
+	}
+	for _, want := range []string{"c", "d"} {
+		val, ok, err = q.Dequeue()
+		if err != nil || !ok || *val != want {
+			t.Fatalf("Dequeue = %v, %t, %v, want %s", val, ok, err, want)
+		}
+	}
+	n, err = q.Len()
+	if err != nil || n != 0 {
+		t.Fatalf("Len of a drained queue = %d, %v", n, err)
+	}
+}
+
+func TestQueueConcurrentDequeue(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	q, err := ezdb.NewQueue[int](db, "jobs")
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	const jobs = 200
+	for i := 0; i < jobs; i++ {
+		i := i
+		err = q.Enqueue(&i)
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				val, ok, err := q.Dequeue()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok {
+					return
+				}
+				mu.Lock()
+				if seen[*val] {
+					t.Errorf("job %d dequeued twice", *val)
+				}
+				seen[*val] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != jobs {
+		t.Fatalf("dequeued %d jobs, want %d", len(seen), jobs)
+	}
+}
+
+func TestQueueValidators(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	var keys []uint64
+	q, err := ezdb.NewQueue[string](db, "jobs", ezdb.WithValidator(func(key *uint64, val *string) error {
+		if *val == "" {
+			return errors.New("empty job")
+		}
+		keys = append(keys, *key)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+
+	for _, job := range []string{"a", "b"} {
+		job := job
+		err = q.Enqueue(&job)
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	if fmt.Sprint(keys) != "[1 2]" {
+		t.Fatalf("validators saw the keys %v, want [1 2]", keys)
+	}
+	empty := ""
+	err = q.Enqueue(&empty)
+	if err == nil {
+		t.Fatal("Enqueue of an invalid value succeeded")
+	}
+	n, err := q.Len()
+	if err != nil || n != 2 {
+		t.Fatalf("Len after a rejected Enqueue = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,275 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// quotasDB is the named database the usage of all DBRefs with a quota is
+// stored in.
+const quotasDB = "ezdb.quotas"
+
+// WithWriteRateLimit limits the Puts and Deletes of the Client's DBRefs to
+// perSecond on average, with bursts of up to burst, by making writes beyond
+// the limit wait. This keeps a runaway caller from monopolizing LMDB's single
+// writer. APIs that write in transactions of their own, such as Queue, are
+// not limited.
+func WithWriteRateLimit(perSecond float64, burst uint) Option {
+	return func(option *options) error {
+		if perSecond <= 0 || burst == 0 {
+			return errors.New("write rate and burst must be positive")
+		}
+		option.writeLimiter = &tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst)}
+		return nil
+	}
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token, waiting until one is available. Tokens are handed out
+// in the order they are asked for.
+func (b *tokenBucket) wait() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	// The bucket goes into debt, which later callers wait for as well.
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// QuotaPolicy is what a Put that would grow a DBRef beyond its quota does,
+// see WithQuota.
+type QuotaPolicy int
+
+const (
+	// QuotaReject fails the Put.
+	QuotaReject QuotaPolicy = iota
+	// QuotaEvictLRU deletes the least recently used entries, counting Puts
+	// and reads as uses, until the Put fits.
+	QuotaEvictLRU
+	// QuotaEvictOldest deletes the least recently written entries until the
+	// Put fits.
+	QuotaEvictOldest
+)
+
+type quotaOptions struct {
+	maxBytes uint64
+	policy   QuotaPolicy
+}
+
+// WithQuota limits the DBRef to maxBytes of keys and values, as stored, so
+// that a cache-like DBRef can't fill the map shared with the others. A Put
+// that would grow it beyond the limit fails with an error matching
+// ErrQuotaExceeded under QuotaReject, and under the evicting policies first
+// deletes other entries in its transaction until it fits, failing only if it
+// doesn't fit on its own. Puts that don't grow the DBRef, and Deletes, always
+// succeed. The usage is kept in a named database shared by all DBRefs with a
+// quota, which counts towards WithNumDBs, and updated in the same transaction
+// as every write. The evicting policies keep an access order like
+// WithMaxEntries; QuotaEvictLRU thus runs a small write transaction for every
+// read, and QuotaEvictOldest can't be combined with WithMaxEntries.
+func WithQuota(maxBytes uint64, policy QuotaPolicy) RefOption {
+	return func(option *refOptions) error {
+		if policy < QuotaReject || policy > QuotaEvictOldest {
+			return fmt.Errorf("unknown quota policy %d", policy)
+		}
+		option.quota = &quotaOptions{maxBytes: maxBytes, policy: policy}
+		return nil
+	}
+}
+
+// initQuota creates the usage record of ref from its current contents, if
+// it doesn't exist.
+func (ref *DBRef[K, V]) initQuota() error {
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(quotasDB, dbFlag(0))
+			return err
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		quotaRef, err := txn.DBRef(quotasDB, dbCreate)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Get(quotaRef, []byte(ref.id))
+		if err == nil || !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return err
+		}
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		var usage uint64
+		key, val, err := cursor.First()
+		for ; err == nil; key, val, err = cursor.Next() {
+			usage += uint64(len(key) + len(val))
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		return txn.Put(quotaRef, []byte(ref.id), binary.BigEndian.AppendUint64(nil, usage), putFlag(0))
+	})
+}
+
+// chargeInTxn updates the usage of ref for replacing the entry under keyBytes,
+// if any, with one of size bytes, or for deleting it if size is 0. It fails
+// if the usage would grow beyond the quota.
+func (ref *DBRef[K, V]) chargeInTxn(txn writeTxn, dbRef dbi, keyBytes []byte, size uint64) error {
+	quotaRef, err := txn.DBRef(quotasDB, dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	var oldSize uint64
+	oldVal, err := txn.Get(dbRef, keyBytes)
+	switch {
+	case err == nil:
+		oldSize = uint64(len(keyBytes) + len(oldVal))
+	case !errors.Is(err, ErrNotFound):
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	usage, err := ref.usageInTxn(txn, quotaRef, oldSize, size)
+	if err != nil {
+		return err
+	}
+	quota := ref.options.quota.maxBytes
+	if size > oldSize && usage > quota && ref.options.quota.policy != QuotaReject {
+		err = ref.evictForInTxn(txn, dbRef, keyBytes, usage-quota)
+		if err != nil {
+			return err
+		}
+		usage, err = ref.usageInTxn(txn, quotaRef, oldSize, size)
+		if err != nil {
+			return err
+		}
+	}
+	if size > oldSize && usage > quota {
+		return fmt.Errorf("%d bytes would exceed the quota of %d bytes: %w", usage, quota, ErrQuotaExceeded)
+	}
+
+	err = txn.Put(quotaRef, []byte(ref.id), binary.BigEndian.AppendUint64(nil, usage), putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+
+	return nil
+}
+
+// usageInTxn returns the usage of ref after replacing an entry of oldSize
+// bytes with one of size bytes.
+func (ref *DBRef[K, V]) usageInTxn(txn readTxn, quotaRef dbi, oldSize, size uint64) (uint64, error) {
+	usage, err := readUint64(txn, quotaRef, []byte(ref.id))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read usage: %w", err)
+	}
+	if usage < oldSize {
+		// The usage record is behind, e.g. after a Drop; don't wrap around.
+		usage = oldSize
+	}
+
+	return usage - oldSize + size, nil
+}
+
+// evictForInTxn deletes entries other than the one under keyBytes, first in
+// the access order, until they add up to at least need bytes or there are
+// none left.
+func (ref *DBRef[K, V]) evictForInTxn(txn writeTxn, dbRef dbi, keyBytes []byte, need uint64) error {
+	lruRef, err := txn.DBRef(ref.lruDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	var freed uint64
+	for freed < need {
+		cursor, err := txn.NewCursor(lruRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		var victim []byte
+		tickKey, entryKey, err := cursor.SeekGreaterThanOrEqualKey(lruTickPrefix)
+		for ; err == nil && bytes.HasPrefix(tickKey, lruTickPrefix); tickKey, entryKey, err = cursor.Next() {
+			if !bytes.Equal(entryKey, keyBytes) {
+				victim = bytes.Clone(entryKey)
+				break
+			}
+		}
+		cursor.Close()
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read access order: %w", err)
+		}
+		if victim == nil {
+			return nil
+		}
+
+		valBytes, err := txn.Get(dbRef, victim)
+		if err != nil {
+			return fmt.Errorf("failed to get key: %w", err)
+		}
+		freed += uint64(len(victim) + len(valBytes))
+		err = ref.deleteInTxn(txn, dbRef, victim)
+		if err != nil {
+			return fmt.Errorf("failed to evict entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Usage returns the number of bytes of keys and values stored in ref, as
+// counted towards its quota. ref must have been created with WithQuota.
+func (ref *DBRef[K, V]) Usage() (usage uint64, err error) {
+	if ref.options.quota == nil {
+		return 0, errors.New("quota is not enabled, see WithQuota")
+	}
+
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		quotaRef, err := txn.DBRef(quotasDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		usage, err = readUint64(txn, quotaRef, []byte(ref.id))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return usage, nil
+}
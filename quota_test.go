@@ -0,0 +1,187 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithWriteRateLimit(100, 2))
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	// The burst goes through right away, the rest at 100 per second.
+	start := time.Now()
+	putN(t, ref, 6)
+	if d := time.Since(start); d < 35*time.Millisecond {
+		t.Fatalf("6 Puts took %v, want about 40ms", d)
+	}
+
+	for _, opt := range []ezdb.Option{ezdb.WithWriteRateLimit(0, 1), ezdb.WithWriteRateLimit(1, 0)} {
+		_, err = ezdb.New(t.TempDir(), opt)
+		if err == nil {
+			t.Error("New with an invalid rate limit succeeded")
+		}
+	}
+}
+
+// bytesRef opens a DBRef of db storing raw bytes, so entries have a known
+// size.
+func bytesRef(t *testing.T, db *ezdb.Client, name string, opts ...ezdb.RefOption) *ezdb.DBRef[string, []byte] {
+	t.Helper()
+
+	ref, err := ezdb.NewDBRef[string, []byte](db, name, append([]ezdb.RefOption{ezdb.WithCodec(ezdb.BytesCodec{}), ezdb.WithKeyCodec(ezdb.StringCodec{})}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	return ref
+}
+
+func TestQuotaReject(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	// Entries from before the quota was set count towards it.
+	key, val := "a", make([]byte, 39)
+	err := bytesRef(t, db, "ref").Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	ref := bytesRef(t, db, "ref", ezdb.WithQuota(100, ezdb.QuotaReject))
+	wantUsage := func(want uint64) {
+		t.Helper()
+		usage, err := ref.Usage()
+		if err != nil || usage != want {
+			t.Fatalf("Usage = %d, %v, want %d", usage, err, want)
+		}
+	}
+	wantUsage(40)
+
+	key, val = "b", make([]byte, 59)
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put up to the quota: %v", err)
+	}
+	wantUsage(100)
+	key, val = "c", []byte{}
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, ezdb.ErrQuotaExceeded) {
+		t.Fatalf("Put beyond the quota returned %v, want ErrQuotaExceeded", err)
+	}
+	wantUsage(100)
+
+	// Shrinking an entry and deleting one make room.
+	key, val = "b", make([]byte, 9)
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put of a smaller value: %v", err)
+	}
+	wantUsage(50)
+	key = "a"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	wantUsage(10)
+
+	_, err = bytesRef(t, db, "unlimited").Usage()
+	if err == nil {
+		t.Fatal("Usage without a quota succeeded")
+	}
+	_, err = ezdb.NewDBRef[string, string](db, "bad", ezdb.WithQuota(1, ezdb.QuotaPolicy(7)))
+	if err == nil {
+		t.Fatal("NewDBRef with an unknown quota policy succeeded")
+	}
+}
+
+func TestQuotaEvict(t *testing.T) {
+	for _, test := range []struct {
+		policy ezdb.QuotaPolicy
+		// evicted is the entry evicted after a is read.
+		evicted string
+	}{
+		{ezdb.QuotaEvictLRU, "b"},
+		{ezdb.QuotaEvictOldest, "a"},
+	} {
+		ref := bytesRef(t, testutil.NewTempClient(t), "ref", ezdb.WithQuota(100, test.policy))
+		// put stores an entry of size bytes, key included, under key.
+		put := func(key string, size int) error {
+			val := make([]byte, size-len(key))
+			return ref.Put(&key, &val)
+		}
+		for _, key := range []string{"a", "b", "c"} {
+			err := put(key, 30)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+		key := "a"
+		_, err := ref.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		err = put("d", 30)
+		if err != nil {
+			t.Fatalf("Put beyond the quota: %v", err)
+		}
+		wantKeys(t, ref, test.evicted, "a", "b", "c", "d")
+		usage, err := ref.Usage()
+		if err != nil || usage != 90 {
+			t.Fatalf("Usage = %d, %v, want 90", usage, err)
+		}
+
+		// Growing an entry evicts others, but never the entry itself.
+		err = put("d", 80)
+		if err != nil {
+			t.Fatalf("Put of a larger value: %v", err)
+		}
+		wantKeys(t, ref, "", "d")
+
+		// A Put that doesn't fit on its own fails, evicting nothing.
+		err = put("e", 101)
+		if !errors.Is(err, ezdb.ErrQuotaExceeded) {
+			t.Fatalf("Put larger than the quota returned %v, want ErrQuotaExceeded", err)
+		}
+		wantKeys(t, ref, "", "d")
+		usage, err = ref.Usage()
+		if err != nil || usage != 80 {
+			t.Fatalf("Usage = %d, %v, want 80", usage, err)
+		}
+	}
+
+	_, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithMaxEntries(10), ezdb.WithQuota(100, ezdb.QuotaEvictOldest))
+	if err == nil {
+		t.Fatal("NewDBRef with max entries and QuotaEvictOldest succeeded")
+	}
+}
+
+// wantKeys fails t unless ref has exactly the entries under keys, but for
+// the one under missing.
+func wantKeys(t *testing.T, ref *ezdb.DBRef[string, []byte], missing string, keys ...string) {
+	t.Helper()
+
+	var got []string
+	err := ref.ForEach(func(key *string, _ *[]byte) error {
+		got = append(got, *key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	var want []string
+	for _, key := range keys {
+		if key != missing {
+			want = append(want, key)
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+}
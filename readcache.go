@@ -0,0 +1,227 @@
+package ezdb
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// readCacheOverhead approximates the memory a cached value takes beyond its
+// encoded key and value.
+const readCacheOverhead = 64
+
+// WithReadCache keeps recently read values of the Client's DBRefs, decoded,
+// in an in-process LRU cache of about maxBytes, measured by their encoded
+// size, so Get and TryGet of hot keys skip the transaction and the decoding.
+// Writes through the Client remove the values they replace; writes by other
+// Clients of the environment, or other processes, are not seen, so only use
+// it when the Client is the only writer.
+//
+// Cached values are shared: Get returns a copy of the value struct, but maps,
+// slices and pointers in it are the cache's and must not be modified. DBRefs
+// created with WithTTL or WithMaxEntries are not cached, since reads keep
+// their bookkeeping up to date, and neither are those with WithTransform,
+// whose values may differ from those other DBRefs of the database read.
+func WithReadCache(maxBytes uint64) Option {
+	return func(option *options) error {
+		if maxBytes == 0 {
+			return errors.New("read cache size must be positive")
+		}
+		option.readCache = maxBytes
+		return nil
+	}
+}
+
+// readCache is an LRU cache of decoded values by DBRef name and encoded key.
+type readCache struct {
+	mu       sync.Mutex
+	maxBytes uint64
+	size     uint64
+	order    *list.List
+	entries  map[string]*list.Element
+	// epoch counts invalidations, so that reads overlapping a write don't
+	// cache the value it replaces.
+	epoch uint64
+	// pending are the keys written by each running write transaction, to
+	// invalidate again once it commits, and writing counts them by key:
+	// values read while a transaction writing them may commit can't be
+	// cached, as the read may have come before the commit.
+	pending map[writeTxn][]string
+	writing map[string]int
+}
+
+type readCacheEntry struct {
+	key  string
+	val  any
+	size uint64
+}
+
+func newReadCache(maxBytes uint64) *readCache {
+	return &readCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		pending:  make(map[writeTxn][]string),
+		writing:  make(map[string]int),
+	}
+}
+
+// readCacheKey returns the key the value under keyBytes of the DBRef name is
+// cached under.
+func readCacheKey(name string, keyBytes []byte) string {
+	return name + "\x00" + string(keyBytes)
+}
+
+// get returns the value cached under key and the current epoch, to pass to
+// add after reading the value on a miss.
+func (c *readCache) get(key string) (val any, ok bool, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, c.epoch
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*readCacheEntry).val, true, c.epoch
+}
+
+// add caches val under key, read after get returned epoch, unless a write
+// has invalidated values since or a running transaction writes key.
+func (c *readCache) add(key string, val any, size int, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entrySize := uint64(len(key) + size + readCacheOverhead)
+	if c.epoch != epoch || c.writing[key] > 0 || entrySize > c.maxBytes {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.order.PushFront(&readCacheEntry{key: key, val: val, size: entrySize})
+	c.size += entrySize
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops elem. The caller must hold c.mu.
+func (c *readCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*readCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// invalidateInTxn drops the value cached under key, which txn writes, now
+// and once txn finishes, see finish.
+func (c *readCache) invalidateInTxn(txn writeTxn, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.pending[txn] = append(c.pending[txn], key)
+	c.writing[key]++
+}
+
+// finish drops the values cached under the keys txn wrote, which reads
+// overlapping txn may have cached from before it committed.
+func (c *readCache) finish(txn writeTxn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys, ok := c.pending[txn]
+	if !ok {
+		return
+	}
+	delete(c.pending, txn)
+
+	c.epoch++
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+		c.writing[key]--
+		if c.writing[key] == 0 {
+			delete(c.writing, key)
+		}
+	}
+}
+
+// clear drops all cached values, for when the whole environment changes.
+func (c *readCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+}
+
+// invalidateInTxn drops the cached value, if any, of keyBytes in the DBRef
+// name, which txn writes.
+func (db *Client) invalidateInTxn(txn writeTxn, name string, keyBytes []byte) {
+	if db.readCache == nil {
+		return
+	}
+
+	db.readCache.invalidateInTxn(txn, readCacheKey(name, keyBytes))
+}
+
+// cached reports whether the values of ref are kept in the Client's read
+// cache.
+func (ref *DBRef[K, V]) cached() bool {
+	return ref.ownerDB.readCache != nil && ref.options.ttl == nil && !ref.options.touchesOnRead() &&
+		len(ref.options.transforms) == 0
+}
+
+// tryGetCached is TryGet through the Client's read cache.
+func (ref *DBRef[K, V]) tryGetCached(key *K) (val *V, ok bool, err error) {
+	err = ref.intercept(opGet, key, nil, func() error {
+		keyBytes, err := ref.encodeKey(key)
+		if err != nil {
+			return fmt.Errorf("failed to encode key: %w", err)
+		}
+		cacheKey := readCacheKey(ref.id, keyBytes)
+
+		cached, hit, epoch := ref.ownerDB.readCache.get(cacheKey)
+		if v, isV := cached.(*V); hit && isV {
+			copied := *v
+			val, ok = &copied, true
+			return nil
+		}
+
+		var size int
+		ok, err = ref.doGetRaw(key, func(valBytes []byte) error {
+			size = len(valBytes)
+			val, err = ref.decodeVal(valBytes)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			return nil
+		})
+		if err != nil || !ok {
+			return err
+		}
+
+		copied := *val
+		ref.ownerDB.readCache.add(cacheKey, &copied, size, epoch)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, ok, nil
+}
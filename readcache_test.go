@@ -0,0 +1,206 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestReadCache(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithReadCache(1<<20), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Only the first Get reads the database, and each returns a copy.
+	reads := faults.Count(ezdb.FaultRead)
+	for i := 0; i < 3; i++ {
+		got, err := ref.Get(&key)
+		if err != nil || got.Name != "Ada" {
+			t.Fatalf("Get = %v, %v", got, err)
+		}
+		got.Name = "changed"
+	}
+	if n := faults.Count(ezdb.FaultRead) - reads; n != 1 {
+		t.Fatalf("cached Gets started %d read transactions, want 1", n)
+	}
+
+	// Writes replace cached values, also in transactions, and those that
+	// abort leave them alone.
+	val.Name = "Ada Lovelace"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || got.Name != "Ada Lovelace" {
+		t.Fatalf("Get after Put = %v, %v", got, err)
+	}
+	errAbort := errors.New("abort")
+	err = db.Update(func(tx *ezdb.Tx) error {
+		val := user{Name: "aborted"}
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want the error of fn", err)
+	}
+	got, err = ref.Get(&key)
+	if err != nil || got.Name != "Ada Lovelace" {
+		t.Fatalf("Get after an aborted Update = %v, %v", got, err)
+	}
+	err = db.Update(func(tx *ezdb.Tx) error {
+		val := user{Name: "committed"}
+		return ref.PutTx(tx, &key, &val)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err = ref.Get(&key)
+	if err != nil || got.Name != "committed" {
+		t.Fatalf("Get after Update = %v, %v", got, err)
+	}
+
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet after Delete = %t, %v", ok, err)
+	}
+}
+
+func TestReadCacheEviction(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithReadCache(1000), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	// Reading every key fills the cache past its size, evicting the least
+	// recently read.
+	wantN(t, ref, 100)
+	get := func(key string) int {
+		t.Helper()
+		reads := faults.Count(ezdb.FaultRead)
+		_, err := ref.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return faults.Count(ezdb.FaultRead) - reads
+	}
+	for i := 0; i < 10; i++ {
+		get("k0099")
+	}
+	if get("k0099") != 0 {
+		t.Fatal("Get of the most recently read key read the database")
+	}
+	if get("k0000") != 1 {
+		t.Fatal("Get of an evicted key didn't read the database")
+	}
+
+	// Values larger than the cache aren't cached.
+	key, val := "big", string(make([]byte, 2000))
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	get("big")
+	if get("big") != 1 {
+		t.Fatal("Get of a value larger than the cache didn't read the database")
+	}
+}
+
+func TestReadCacheUncached(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithReadCache(1<<20), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ttl", ezdb.WithTTL(time.Hour, 100))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 1)
+
+	// DBRefs with a TTL read the database every time.
+	key := "k0000"
+	reads := faults.Count(ezdb.FaultRead)
+	for i := 0; i < 3; i++ {
+		_, err = ref.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if n := faults.Count(ezdb.FaultRead) - reads; n < 3 {
+		t.Fatalf("Gets of a DBRef with a TTL started %d read transactions", n)
+	}
+
+	_, err = ezdb.New(t.TempDir(), ezdb.WithReadCache(0))
+	if err == nil {
+		t.Fatal("New with an empty read cache succeeded")
+	}
+}
+
+func TestReadCacheConcurrentWrites(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithReadCache(1<<20))
+	ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key := "n"
+
+	// Readers racing a writer never see a value go back, and see the last
+	// one once it is done.
+	const n = 300
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := -1
+			for last < n-1 {
+				val, ok, err := ref.TryGet(&key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !ok {
+					continue
+				}
+				if *val < last {
+					errs <- fmt.Errorf("read %d after %d", *val, last)
+					return
+				}
+				last = *val
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		err := ref.Put(&key, &i)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package ezdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// readerSlot is one entry of LMDB's reader table, as listed by
+// mdb_reader_list.
+type readerSlot struct {
+	TxnID uint64
+	PID   int
+	TID   uint64
+}
+
+// active reports whether the slot currently pins a snapshot.
+func (s readerSlot) active() bool {
+	return s.PID != 0 && s.TxnID != ^uint64(0)
+}
+
+// ReaderCheck clears reader slots left behind by processes that exited
+// without ending their read transactions, and returns how many were cleared.
+// Such stale slots pin old snapshots and make the database grow without
+// bound. Use WithReaderCheckOnOpen to run it every time the Client is opened.
+func (db *Client) ReaderCheck() (int, error) {
+	env, release, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	checker, ok := backendAs[readerChecker](env)
+	if !ok {
+		return 0, fmt.Errorf("failed to check readers of %s engine: %w", db.options.engine, ErrUnsupported)
+	}
+	cleared, err := checker.ReaderCheck()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check readers: %w", err)
+	}
+
+	return cleared, nil
+}
+
+// Reader is a read transaction in LMDB's reader table, see Client.Readers.
+type Reader struct {
+	// PID is the process the transaction runs in, and Thread the pthread
+	// handle of the thread that began it, which tells threads of a process
+	// apart but is not the OS thread ID.
+	PID    int
+	Thread uint64
+	// TxnID is the ID of the write transaction whose snapshot the reader
+	// sees, and Lag how many write transactions have committed since. Until
+	// the reader ends, LMDB can't reuse the pages those freed, so the data
+	// file grows with a reader that lags far behind.
+	TxnID uint64
+	Lag   uint64
+}
+
+// Readers returns the read transactions in LMDB's reader table, of every
+// process with the environment open, oldest snapshot first, so operators can
+// find the one pinning an old snapshot. Reader slots left behind by exited
+// processes are listed as well until ReaderCheck clears them.
+func (db *Client) Readers() ([]Reader, error) {
+	s, err := db.envStat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read readers: %w", err)
+	}
+
+	var readers []Reader
+	for _, slot := range s.Readers {
+		if !slot.active() {
+			continue
+		}
+		r := Reader{PID: slot.PID, Thread: slot.TID, TxnID: slot.TxnID}
+		if s.LastTxnID > slot.TxnID {
+			r.Lag = s.LastTxnID - slot.TxnID
+		}
+		readers = append(readers, r)
+	}
+	sort.Slice(readers, func(i, j int) bool { return readers[i].TxnID < readers[j].TxnID })
+
+	return readers, nil
+}
@@ -0,0 +1,214 @@
+package ezdb_test
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// readerEnv names the environment TestHelperReader opens.
+const readerEnv = "EZDB_TEST_READER_DIR"
+
+// TestHelperReader isn't a test: it opens the environment in $readerEnv,
+// prints a line once it is in a read transaction and waits to be killed,
+// which leaves its reader slot behind.
+func TestHelperReader(t *testing.T) {
+	dir := os.Getenv(readerEnv)
+	if dir == "" {
+		t.Skip("only run by startStaleReader")
+	}
+
+	db, err := ezdb.New(dir, ezdb.WithNumDBs(128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.ForEach(func(key, val *string) error {
+		os.Stdout.WriteString("ready\n")
+		select {}
+	})
+}
+
+// startStaleReader runs a process holding a read transaction on the
+// environment in dir and kills it, leaving its reader slot behind.
+func startStaleReader(t *testing.T, dir string) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperReader$")
+	cmd.Env = append(os.Environ(), readerEnv+"="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil || line != "ready\n" {
+		cmd.Process.Kill()
+		t.Fatalf("reader process said %q: %v", line, err)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+}
+
+func TestReaderCheckClearsStaleSlots(t *testing.T) {
+	dir := t.TempDir()
+	db, err := ezdb.New(dir, ezdb.WithNumDBs(128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleared, err := db.ReaderCheck()
+	if err != nil || cleared != 0 {
+		t.Fatalf("ReaderCheck without stale readers = %d, %v", cleared, err)
+	}
+
+	startStaleReader(t, dir)
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NumReaders != 1 {
+		t.Fatalf("NumReaders with a stale reader = %d, want 1", stats.NumReaders)
+	}
+	cleared, err = db.ReaderCheck()
+	if err != nil || cleared != 1 {
+		t.Fatalf("ReaderCheck = %d, %v, want 1 cleared", cleared, err)
+	}
+	stats, err = db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NumReaders != 0 {
+		t.Fatalf("NumReaders after ReaderCheck = %d", stats.NumReaders)
+	}
+}
+
+func TestReaderCheckOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	startStaleReader(t, dir)
+
+	db, err := ezdb.New(dir, ezdb.WithNumDBs(128), ezdb.WithReaderCheckOnOpen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NumReaders != 0 {
+		t.Fatalf("NumReaders after opening with WithReaderCheckOnOpen = %d", stats.NumReaders)
+	}
+}
+
+func TestReaderCheckUnsupported(t *testing.T) {
+	db := testutil.NewMemoryClient(t)
+
+	_, err := db.ReaderCheck()
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("ReaderCheck of a memory Client returned %v, want ErrUnsupported", err)
+	}
+}
+
+func TestReaders(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putN(t, ref, 1)
+
+	readers, err := db.Readers()
+	if err != nil || len(readers) != 0 {
+		t.Fatalf("Readers without read transactions = %+v, %v", readers, err)
+	}
+
+	// A read transaction lags behind by the writes committed since it began.
+	err = db.ViewAll(func(*ezdb.Snapshot) error {
+		done := make(chan error)
+		go func() {
+			var err error
+			for i := 0; i < 3 && err == nil; i++ {
+				key, val := "k", "v"
+				err = ref.Put(&key, &val)
+			}
+			done <- err
+		}()
+		err := <-done
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		readers, err := db.Readers()
+		if err != nil || len(readers) != 1 {
+			t.Fatalf("Readers = %+v, %v", readers, err)
+		}
+		if r := readers[0]; r.PID != os.Getpid() || r.TxnID == 0 || r.Lag != 3 {
+			t.Fatalf("reader = %+v", r)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ViewAll: %v", err)
+	}
+
+	// Stale slots are listed until ReaderCheck clears them.
+	startStaleReader(t, dir)
+	readers, err = db.Readers()
+	if err != nil || len(readers) != 1 || readers[0].PID == os.Getpid() {
+		t.Fatalf("Readers with a stale reader = %+v, %v", readers, err)
+	}
+	_, err = db.ReaderCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readers, err = db.Readers()
+	if err != nil || len(readers) != 0 {
+		t.Fatalf("Readers after ReaderCheck = %+v, %v", readers, err)
+	}
+
+	_, err = testutil.NewMemoryClient(t).Readers()
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("Readers of a memory Client returned %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,86 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// refInfo describes a DBRef opened on a Client, for APIs that work on all of
+// a Client's data, such as Verify, Dump and Mirror.
+type refInfo struct {
+	// flags are the flags the DBRef's named database was opened with.
+	flags  dbFlag
+	verify verifier
+	// encoding identifies how the DBRef encodes its keys and values, see
+	// DBRef.encoding.
+	encoding string
+	// encodeKey encodes a key typed as text, see DBRef.parseKeyText.
+	encodeKey func(s string) ([]byte, error)
+	// decodeKey and decodeVal decode a stored key into a *K and a stored
+	// value into a *V.
+	decodeKey func(keyBytes []byte) (any, error)
+	decodeVal func(valBytes []byte) (any, error)
+	// write puts a *K and *V like PutTx, or deletes the key if val is nil,
+	// ignoring missing keys.
+	write func(txn writeTxn, key, val any) error
+}
+
+// register records ref, whose named database was opened with flags, in its
+// Client's refs, replacing an earlier DBRef with the same name.
+func (ref *DBRef[K, V]) register(flags dbFlag) {
+	db := ref.ownerDB
+	db.refsMu.Lock()
+	defer db.refsMu.Unlock()
+
+	if db.refs == nil {
+		db.refs = make(map[string]refInfo)
+	}
+	db.refs[ref.id] = refInfo{
+		flags:     flags,
+		verify:    ref.verifyInTxn,
+		encoding:  ref.encoding(),
+		encodeKey: ref.parseKeyText,
+		decodeKey: func(keyBytes []byte) (any, error) {
+			return ref.decodeKey(keyBytes)
+		},
+		decodeVal: func(valBytes []byte) (any, error) {
+			return ref.decodeVal(valBytes)
+		},
+		write: ref.writeAny,
+	}
+}
+
+// registeredRefs returns a copy of db.refs.
+func (db *Client) registeredRefs() map[string]refInfo {
+	db.refsMu.Lock()
+	defer db.refsMu.Unlock()
+
+	refs := make(map[string]refInfo, len(db.refs))
+	for name, info := range db.refs {
+		refs[name] = info
+	}
+
+	return refs
+}
+
+func (ref *DBRef[K, V]) writeAny(txn writeTxn, key, val any) error {
+	k, ok := key.(*K)
+	if !ok {
+		return fmt.Errorf("cannot write key of type %T to %s", key, ref.id)
+	}
+	tx := &Tx{txn: txn}
+	if val == nil {
+		err := ref.DeleteTx(tx, k)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	v, ok := val.(*V)
+	if !ok {
+		return fmt.Errorf("cannot write value of type %T to %s", val, ref.id)
+	}
+
+	return ref.PutTx(tx, k, v)
+}
@@ -0,0 +1,105 @@
+package ezdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// Opening the same LMDB environment twice in one process breaks LMDB's
+// locking: POSIX locks belong to the process, not to the file descriptor, so
+// closing the lock file of either environment releases the locks held
+// through both. bbolt and Pebble lock their files exclusively, so a second
+// open fails. The registry makes Clients of the same directory share one
+// environment instead.
+var registry = struct {
+	sync.Mutex
+	envs map[string]*sharedEnv
+}{envs: make(map[string]*sharedEnv)}
+
+type sharedEnv struct {
+	env    backend
+	config envConfig
+	refs   int
+}
+
+// envConfig holds the options that are fixed when an environment is opened.
+type envConfig struct {
+	engine     Engine
+	numReaders uint
+	numDbs     uint
+	batchSize  uint
+	flags      envFlag
+	faults     *Faults
+}
+
+func configOf(o *options) envConfig {
+	return envConfig{
+		engine:     o.engine,
+		numReaders: *o.numReaders,
+		numDbs:     *o.numDbs,
+		batchSize:  *o.batchSize,
+		flags:      o.envFlags,
+		faults:     o.faults,
+	}
+}
+
+// registryKey resolves path to the key identifying its environment.
+func registryKey(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
+
+// openShared returns the environment registered under key, opening it with
+// open if there is none. Sharing an environment requires the same options it
+// was opened with, including the engine, so New and NewBolt can't share a
+// directory.
+func openShared(key string, o *options, open func() (backend, error)) (backend, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	config := configOf(o)
+	if shared, ok := registry.envs[key]; ok {
+		if shared.config.engine != config.engine {
+			return nil, fmt.Errorf("%w: %s is open with the %s engine", ErrAlreadyOpen, key, shared.config.engine)
+		}
+		if shared.config != config {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyOpen, key)
+		}
+		shared.refs++
+		return shared.env, nil
+	}
+
+	env, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	registry.envs[key] = &sharedEnv{env: env, config: config, refs: 1}
+	return env, nil
+}
+
+// releaseShared drops a reference to the environment registered under key
+// and reports whether it was the last one, in which case the caller must
+// terminate the environment.
+func releaseShared(key string) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	shared, ok := registry.envs[key]
+	if !ok {
+		return true
+	}
+
+	shared.refs--
+	if shared.refs > 0 {
+		return false
+	}
+
+	delete(registry.envs, key)
+	return true
+}
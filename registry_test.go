@@ -0,0 +1,75 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// openClient returns an initialized Client of dir, closed when t finishes.
+func openClient(t *testing.T, dir string, opts ...ezdb.Option) *ezdb.Client {
+	t.Helper()
+
+	db, err := ezdb.New(dir, append([]ezdb.Option{ezdb.WithNumDBs(16)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestClientsOfOneDirectoryShareTheEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	first := openClient(t, dir)
+	second := openClient(t, dir+"/.")
+
+	a, err := ezdb.NewRef[string, string]("ref", first)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	b, err := ezdb.NewRef[string, string]("ref", second)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = a.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Closing one Client leaves the environment open for the other.
+	err = first.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err := b.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get through the second Client = %v, %v", got, err)
+	}
+}
+
+func TestOpeningWithOtherOptionsFails(t *testing.T) {
+	dir := t.TempDir()
+	openClient(t, dir)
+
+	for name, open := range map[string]func() (*ezdb.Client, error){
+		"readers": func() (*ezdb.Client, error) { return ezdb.New(dir, ezdb.WithNumDBs(16), ezdb.WithNumReaders(3)) },
+		"engine":  func() (*ezdb.Client, error) { return ezdb.NewBolt(dir, ezdb.WithNumDBs(16)) },
+	} {
+		db, err := open()
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		err = db.Init()
+		if !errors.Is(err, ezdb.ErrAlreadyOpen) {
+			db.Close()
+			t.Fatalf("Init with other %s returned %v, want ErrAlreadyOpen", name, err)
+		}
+	}
+}
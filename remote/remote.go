@@ -0,0 +1,387 @@
+// Package remote is a client for the key-value service of package server,
+// with the API of an embedded ezdb Client and its DBRefs, so application code
+// written against the Ref interface can switch between embedded and remote
+// storage without changing call sites.
+//
+// It speaks the gRPC service defined in ezdbpb/kv.proto. Keys and values
+// travel as JSON, so K and V must round-trip through encoding/json, and the
+// codecs of a DBRef are those it was opened with on the server. Errors the
+// server reports for one of ezdb's errors match it with errors.Is, as they
+// do with an embedded DBRef.
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/ezdbpb"
+)
+
+// pageSize is the number of entries ForEach fetches per request.
+const pageSize = 1000
+
+// Ref is the API a DBRef of this package shares with ezdb.DBRef, which is
+// ezdb.KV.
+type Ref[K, V any] interface {
+	ezdb.KV[K, V]
+}
+
+var (
+	_ Ref[string, string] = (*ezdb.DBRef[string, string])(nil)
+	_ Ref[string, string] = (*DBRef[string, string])(nil)
+)
+
+type Option func(option *options) error
+
+type options struct {
+	tls         *tls.Config
+	dialTimeout time.Duration
+}
+
+// WithTLS connects with TLS, configured by config, for servers created with
+// server.WithTLS.
+func WithTLS(config *tls.Config) Option {
+	return func(option *options) error {
+		if config == nil {
+			return errors.New("TLS config must not be nil")
+		}
+		option.tls = config
+		return nil
+	}
+}
+
+// WithDialTimeout makes Dial wait up to timeout for the connection to be
+// established, and fail if it isn't. By default Dial returns right away and
+// the connection is established in the background; calls fail until it is.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(option *options) error {
+		option.dialTimeout = timeout
+		return nil
+	}
+}
+
+// Client is a connection to a server. It is safe for concurrent use; calls
+// are multiplexed over the one connection, which is reestablished if it is
+// lost.
+type Client struct {
+	conn   *grpc.ClientConn
+	kv     ezdbpb.KVClient
+	closed atomic.Bool
+}
+
+// Dial connects to the server listening on the TCP address addr.
+func Dial(addr string, opts ...Option) (*Client, error) {
+	o := &options{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if o.tls != nil {
+		creds = credentials.NewTLS(o.tls)
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	ctx := context.Background()
+	if o.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.dialTimeout)
+		defer cancel()
+		dialOpts = append(dialOpts, grpc.WithBlock())
+	}
+
+	conn, err := grpc.DialContext(ctx, addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	return &Client{conn: conn, kv: ezdbpb.NewKVClient(conn)}, nil
+}
+
+// Close closes the connection. Calls in flight fail with ezdb.ErrClosed.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	return c.conn.Close()
+}
+
+// err returns the error of a call that failed with err, matching the ezdb
+// error the server reported, if any, so errors.Is works as it does with an
+// embedded DBRef.
+func (c *Client) err(err error) error {
+	if c.closed.Load() {
+		return ezdb.ErrClosed
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != "ezdb" {
+			continue
+		}
+		if sentinel, ok := reasonErrors[info.Reason]; ok {
+			return &serverError{msg: st.Message(), err: sentinel}
+		}
+	}
+
+	return err
+}
+
+// reasonErrors are the ezdb errors by their reasons, see ezdbpb.Error.
+var reasonErrors = map[string]error{
+	ezdbpb.Error_ERROR_NOT_FOUND.String():       ezdb.ErrNotFound,
+	ezdbpb.Error_ERROR_KEY_EXISTS.String():      ezdb.ErrKeyExists,
+	ezdbpb.Error_ERROR_DUPLICATE.String():       ezdb.ErrDuplicate,
+	ezdbpb.Error_ERROR_CLOSED.String():          ezdb.ErrClosed,
+	ezdbpb.Error_ERROR_MAP_FULL.String():        ezdb.ErrMapFull,
+	ezdbpb.Error_ERROR_TXN_TOO_BIG.String():     ezdb.ErrTxnTooBig,
+	ezdbpb.Error_ERROR_KEY_TOO_LARGE.String():   ezdb.ErrKeyTooLarge,
+	ezdbpb.Error_ERROR_VALUE_TOO_LARGE.String(): ezdb.ErrValueTooLarge,
+	ezdbpb.Error_ERROR_QUOTA_EXCEEDED.String():  ezdb.ErrQuotaExceeded,
+	ezdbpb.Error_ERROR_CHECKSUM.String():        ezdb.ErrChecksum,
+	ezdbpb.Error_ERROR_UNSUPPORTED.String():     ezdb.ErrUnsupported,
+}
+
+// serverError is an error reported by the server that matches an ezdb error.
+type serverError struct {
+	msg string
+	err error
+}
+
+func (e *serverError) Error() string {
+	return e.msg
+}
+
+func (e *serverError) Unwrap() error {
+	return e.err
+}
+
+// Tx collects the writes of Client.Update.
+type Tx struct {
+	ops []*ezdbpb.TxnOp
+	err error
+}
+
+func (tx *Tx) add(op ezdbpb.TxnOp_Op, dbRef string, key, val any) {
+	if tx.err != nil {
+		return
+	}
+
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		tx.err = fmt.Errorf("failed to encode key: %w", err)
+		return
+	}
+	var valData []byte
+	if val != nil {
+		valData, err = json.Marshal(val)
+		if err != nil {
+			tx.err = fmt.Errorf("failed to encode value: %w", err)
+			return
+		}
+	}
+
+	tx.ops = append(tx.ops, &ezdbpb.TxnOp{Op: op, Dbref: dbRef, Key: keyData, Value: valData})
+}
+
+// Update calls fn with a transaction whose writes, made with PutTx and
+// DeleteTx, are sent to the server once fn returns nil and applied
+// atomically, like ezdb.Client.Update. Unlike there, the writes are
+// buffered: reads from within fn don't see them, and a DeleteTx of a missing
+// key is not an error.
+func (c *Client) Update(fn func(tx *Tx) error) error {
+	tx := &Tx{}
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if tx.err != nil {
+		return tx.err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	_, err = c.kv.Txn(context.Background(), &ezdbpb.TxnRequest{Ops: tx.ops})
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", c.err(err))
+	}
+
+	return nil
+}
+
+// DBRef is a DBRef registered on the server under its name.
+type DBRef[K, V any] struct {
+	c    *Client
+	name string
+}
+
+// NewDBRef returns the DBRef registered on the server as name, failing if
+// there is none.
+func NewDBRef[K, V any](c *Client, name string) (*DBRef[K, V], error) {
+	_, err := c.kv.Scan(context.Background(), &ezdbpb.ScanRequest{Dbref: name, Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize db ref: %w", c.err(err))
+	}
+
+	return &DBRef[K, V]{c: c, name: name}, nil
+}
+
+// Name returns the name of the DBRef.
+func (ref *DBRef[K, V]) Name() string {
+	return ref.name
+}
+
+// Get returns the value stored under key. A missing key is reported as an
+// error matching ezdb.ErrNotFound; use TryGet to check for presence instead.
+func (ref *DBRef[K, V]) Get(key *K) (*V, error) {
+	val, ok, err := ref.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ezdb.ErrNotFound)
+	}
+
+	return val, nil
+}
+
+// TryGet returns the value stored under key and whether it was found. A
+// missing key is not an error.
+func (ref *DBRef[K, V]) TryGet(key *K) (val *V, ok bool, err error) {
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	reply, err := ref.c.kv.Get(context.Background(), &ezdbpb.GetRequest{Dbref: ref.name, Key: keyData})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get key: %w", ref.c.err(err))
+	}
+	if !reply.Found {
+		return nil, false, nil
+	}
+
+	val = new(V)
+	err = json.Unmarshal(reply.Value, val)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return val, true, nil
+}
+
+// Put stores val under key, replacing any existing value.
+func (ref *DBRef[K, V]) Put(key *K, val *V) error {
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	valData, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	_, err = ref.c.kv.Put(context.Background(), &ezdbpb.PutRequest{Dbref: ref.name, Key: keyData, Value: valData})
+	if err != nil {
+		return fmt.Errorf("failed to put key: %w", ref.c.err(err))
+	}
+
+	return nil
+}
+
+// Delete deletes key. A missing key is reported as an error matching
+// ezdb.ErrNotFound.
+func (ref *DBRef[K, V]) Delete(key *K) error {
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	reply, err := ref.c.kv.Delete(context.Background(), &ezdbpb.DeleteRequest{Dbref: ref.name, Key: keyData})
+	if err != nil {
+		return fmt.Errorf("failed to delete key: %w", ref.c.err(err))
+	}
+	if !reply.Found {
+		return fmt.Errorf("failed to delete key: %w", ezdb.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ForEach calls fn with every entry of ref, in the order of the encoded keys,
+// until fn returns an error, which ForEach returns. The entries are fetched
+// in pages, each read in its own transaction on the server, so unlike
+// ezdb.DBRef.ForEach the scan is not a consistent snapshot.
+func (ref *DBRef[K, V]) ForEach(fn func(key *K, val *V) error) error {
+	return ref.scan("", fn)
+}
+
+// ForEachPrefix is ForEach restricted to keys starting with prefix, see
+// ezdb.Query.WherePrefix.
+func (ref *DBRef[K, V]) ForEachPrefix(prefix string, fn func(key *K, val *V) error) error {
+	if prefix == "" {
+		return errors.New("prefix must not be empty")
+	}
+
+	return ref.scan(prefix, fn)
+}
+
+func (ref *DBRef[K, V]) scan(prefix string, fn func(key *K, val *V) error) error {
+	var after []byte
+	for {
+		reply, err := ref.c.kv.Scan(context.Background(), &ezdbpb.ScanRequest{Dbref: ref.name, Prefix: prefix, After: after, Limit: pageSize})
+		if err != nil {
+			return fmt.Errorf("failed to scan: %w", ref.c.err(err))
+		}
+
+		for _, entry := range reply.Entries {
+			key, val := new(K), new(V)
+			err = json.Unmarshal(entry.Key, key)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			err = json.Unmarshal(entry.Value, val)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+
+			err = fn(key, val)
+			if err != nil {
+				return err
+			}
+		}
+		if len(reply.Entries) < pageSize {
+			return nil
+		}
+		after = reply.Entries[len(reply.Entries)-1].Key
+	}
+}
+
+// PutTx stores val under key when tx commits.
+func (ref *DBRef[K, V]) PutTx(tx *Tx, key *K, val *V) error {
+	tx.add(ezdbpb.TxnOp_OP_PUT, ref.name, key, val)
+	return tx.err
+}
+
+// DeleteTx deletes key when tx commits, if it exists.
+func (ref *DBRef[K, V]) DeleteTx(tx *Tx, key *K) error {
+	tx.add(ezdbpb.TxnOp_OP_DELETE, ref.name, key, nil)
+	return tx.err
+}
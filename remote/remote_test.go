@@ -0,0 +1,333 @@
+package remote_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/remote"
+	"github.com/bjornpagen/ezdb/server"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// serve serves the DBRef "ref" of db, with string keys and int values, on a
+// local port and returns a Client connected to it.
+func serve(t *testing.T, db *ezdb.Client) *remote.Client {
+	t.Helper()
+
+	ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	s, err := server.New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = server.Register(s, ref)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	c, err := remote.Dial(l.Addr().String(), remote.WithDialTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+// exercise runs the same calls on ref whether it is embedded or remote.
+func exercise(t *testing.T, ref ezdb.KV[string, int]) {
+	t.Helper()
+
+	if ref.Name() != "ref" {
+		t.Fatalf("Name = %q", ref.Name())
+	}
+	key, val := "a", 1
+	err := ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != 1 {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	key = "b"
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of a missing key returned %v, want ErrNotFound", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a missing key = %t, %v", ok, err)
+	}
+	err = ref.Delete(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	key = "a"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, ok, err = ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a deleted key = %t, %v", ok, err)
+	}
+}
+
+func TestDBRef(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, db)
+
+	t.Run("embedded", func(t *testing.T) {
+		ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		exercise(t, ref)
+	})
+	t.Run("remote", func(t *testing.T) {
+		ref, err := remote.NewDBRef[string, int](c, "ref")
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		exercise(t, ref)
+	})
+
+	_, err := remote.NewDBRef[string, int](c, "missing")
+	if err == nil {
+		t.Fatal("NewDBRef of an unregistered DBRef succeeded")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := serve(t, testutil.NewTempClient(t))
+	ref, err := remote.NewDBRef[string, int](c, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	// The server's errors match ezdb's.
+	key, val := strings.Repeat("k", 600), 1
+	err = ref.Put(&key, &val)
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("Put of a long key returned %v, want ErrKeyTooLarge", err)
+	}
+
+	// Values that don't fit V fail to decode.
+	wrong, err := remote.NewDBRef[string, string](c, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key = "a"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err = wrong.Get(&key)
+	if err == nil {
+		t.Fatal("Get of an int as a string succeeded")
+	}
+
+	err = c.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Get after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, db)
+	ref, err := remote.NewDBRef[string, int](c, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	// More entries than fit a page.
+	err = c.Update(func(tx *remote.Tx) error {
+		for i := 0; i < 2500; i++ {
+			key := fmt.Sprintf("k%04d", i)
+			err := ref.PutTx(tx, &key, &i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	i := 0
+	err = ref.ForEach(func(key *string, val *int) error {
+		if want := fmt.Sprintf("k%04d", i); *key != want || *val != i {
+			return fmt.Errorf("entry %d = %s: %d, want %s", i, *key, *val, want)
+		}
+		i++
+		return nil
+	})
+	if err != nil || i != 2500 {
+		t.Fatalf("ForEach saw %d entries: %v", i, err)
+	}
+
+	n := 0
+	err = ref.ForEachPrefix("k1", func(key *string, val *int) error {
+		if !strings.HasPrefix(*key, "k1") {
+			return fmt.Errorf("key %s lacks the prefix", *key)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != 1000 {
+		t.Fatalf("ForEachPrefix saw %d entries: %v", n, err)
+	}
+	err = ref.ForEachPrefix("", func(*string, *int) error { return nil })
+	if err == nil {
+		t.Fatal("ForEachPrefix with an empty prefix succeeded")
+	}
+
+	errStop := errors.New("stop")
+	err = ref.ForEach(func(*string, *int) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ForEach returned %v, want the error of fn", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, db)
+	ref, err := remote.NewDBRef[string, int](c, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "a", 1
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Deleting a missing key in a transaction is not an error.
+	err = c.Update(func(tx *remote.Tx) error {
+		key, val := "b", 2
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		key = "a"
+		err = ref.DeleteTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		key = "missing"
+		return ref.DeleteTx(tx, &key)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	key = "b"
+	got, err := ref.Get(&key)
+	if err != nil || *got != 2 {
+		t.Fatalf("Get after Update = %v, %v", got, err)
+	}
+
+	// Nothing is sent if fn fails, and a failing write rolls back the rest.
+	errAbort := errors.New("abort")
+	err = c.Update(func(tx *remote.Tx) error {
+		key := "b"
+		err := ref.DeleteTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want the error of fn", err)
+	}
+	err = c.Update(func(tx *remote.Tx) error {
+		key := "b"
+		err := ref.DeleteTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		key, val := strings.Repeat("k", 600), 1
+		return ref.PutTx(tx, &key, &val)
+	})
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("Update with a long key returned %v, want ErrKeyTooLarge", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || !ok {
+		t.Fatalf("TryGet after failed Updates = %t, %v", ok, err)
+	}
+
+	// Values that don't encode fail the transaction before it is sent, also
+	// if fn ignores the error.
+	floats, err := remote.NewDBRef[string, float64](c, "ref")
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	err = c.Update(func(tx *remote.Tx) error {
+		key := "b"
+		err := ref.DeleteTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		val := math.Inf(1)
+		floats.PutTx(tx, &key, &val)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to encode value") {
+		t.Fatalf("Update with an unencodable value returned %v", err)
+	}
+	_, ok, err = ref.TryGet(&key)
+	if err != nil || !ok {
+		t.Fatalf("TryGet after failed Updates = %t, %v", ok, err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	_, err := remote.Dial("127.0.0.1:1", remote.WithTLS(nil))
+	if err == nil {
+		t.Fatal("Dial with a nil TLS config succeeded")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	_, err = remote.Dial(addr, remote.WithDialTimeout(100*time.Millisecond))
+	if err == nil {
+		t.Fatal("Dial of a closed port with a timeout succeeded")
+	}
+
+	// Without a timeout, Dial doesn't wait for the connection.
+	c, err := remote.Dial(addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	_, err = remote.NewDBRef[string, int](c, "ref")
+	if err == nil {
+		t.Fatal("NewDBRef without a server succeeded")
+	}
+}
@@ -0,0 +1,394 @@
+package ezdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replica copies are named replicaPrefix followed by the time they were
+// taken in snapshotLayout, so they sort by age, and are received under a name
+// starting with replicaTmpPrefix.
+const (
+	replicaPrefix    = "replica-"
+	replicaTmpPrefix = "tmp-"
+)
+
+// ReplicaSource ships a compacted copy of a Client's environment to a
+// BackupSink every interval, for a ReplicaTarget to serve reads from. The
+// sink is usually a ReplicaTarget, but may be any BackupSink, such as object
+// storage that replicas are restored from.
+type ReplicaSource struct {
+	db       *Client
+	sink     BackupSink
+	interval time.Duration
+
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu   sync.Mutex
+	last *SnapshotStatus
+}
+
+// NewReplicaSource starts shipping copies of db to sink every interval, until
+// Stop or db is closed. The first copy is shipped after interval; call Ship
+// to ship one right away.
+func NewReplicaSource(db *Client, sink BackupSink, interval time.Duration) (*ReplicaSource, error) {
+	if interval <= 0 {
+		return nil, errors.New("replica interval must be positive")
+	}
+
+	s := &ReplicaSource{db: db, sink: sink, interval: interval, done: make(chan struct{})}
+	db.startBackground(s.run)
+	return s, nil
+}
+
+// run ships a copy every interval until stop or Stop.
+func (s *ReplicaSource) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		err := s.Ship(context.Background())
+		if err != nil {
+			s.db.options.log.Error().Err(err).Msg("failed to ship replica")
+		}
+	}
+}
+
+// Ship ships a compacted copy of the environment to the sink now.
+func (s *ReplicaSource) Ship(ctx context.Context) error {
+	now := time.Now().UTC()
+	status := SnapshotStatus{Time: now, Path: replicaPrefix + now.Format(snapshotLayout)}
+
+	status.Err = s.db.copyToSink(ctx, s.sink, status.Path, true)
+	status.Duration = time.Since(now)
+	if status.Err != nil {
+		status.Path = ""
+	}
+
+	s.mu.Lock()
+	s.last = &status
+	s.mu.Unlock()
+
+	return status.Err
+}
+
+// Last returns the status of the last shipped copy, with Path set to its name
+// in the sink, or nil if none has been shipped.
+func (s *ReplicaSource) Last() *SnapshotStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		return nil
+	}
+	last := *s.last
+	return &last
+}
+
+// Stop stops shipping copies.
+func (s *ReplicaSource) Stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// ReplicaTarget receives the copies shipped by a ReplicaSource into a
+// directory and serves reads from the newest one. It is a BackupSink: each
+// copy is written to a temporary directory, checked to be an LMDB data file
+// and then swapped in as the environment of the target's Client, so DBRefs
+// opened on the Client keep working and read the new copy from then on.
+// Operations in flight during a swap finish on the previous copy, which is
+// deleted once they have.
+type ReplicaTarget struct {
+	dir string
+	db  *Client
+
+	// mu serializes swaps and guards current, the name of the copy the
+	// Client serves.
+	mu      sync.Mutex
+	current string
+}
+
+// NewReplicaTarget returns a ReplicaTarget receiving copies into dir, and
+// opens the newest copy already in dir, if any. The Client is created with
+// opts and WithReadOnly; DBRefs can be opened on it once it serves a copy,
+// see Current.
+func NewReplicaTarget(dir string, opts ...Option) (*ReplicaTarget, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replica directory: %w", err)
+	}
+
+	names, err := listReplicas(dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	path := dir
+	if len(names) > 0 {
+		path = filepath.Join(dir, names[len(names)-1])
+	}
+	db, err := New(path, append(opts, WithReadOnly())...)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ReplicaTarget{dir: dir, db: db}
+	if len(names) > 0 {
+		err = db.Init()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replica: %w", err)
+		}
+		t.current = names[len(names)-1]
+	}
+
+	return t, nil
+}
+
+// Client returns the Client serving the current copy.
+func (t *ReplicaTarget) Client() *Client {
+	return t.db
+}
+
+// Current returns the name of the copy the Client serves, or "" if none has
+// been received yet.
+func (t *ReplicaTarget) Current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.current
+}
+
+// Create implements BackupSink. Names must sort by age, as those of
+// ReplicaSource do; a copy older than the current one is rejected on Commit.
+func (t *ReplicaTarget) Create(ctx context.Context, name string) (BackupUpload, error) {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, replicaTmpPrefix) {
+		return nil, fmt.Errorf("invalid replica name %q", name)
+	}
+
+	tmp, err := os.MkdirTemp(t.dir, replicaTmpPrefix+name+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(tmp, dataFileName), os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to create data file: %w", err)
+	}
+
+	return &replicaUpload{target: t, name: name, tmp: tmp, f: f}, nil
+}
+
+// List implements BackupSink.
+func (t *ReplicaTarget) List(ctx context.Context, prefix string) ([]string, error) {
+	return listReplicas(t.dir, prefix)
+}
+
+// Delete implements BackupSink. The current copy can't be deleted.
+func (t *ReplicaTarget) Delete(ctx context.Context, name string) error {
+	if name == "" || filepath.Base(name) != name {
+		return fmt.Errorf("invalid replica name %q", name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if name == t.current {
+		return errors.New("cannot delete the current replica")
+	}
+	err := os.RemoveAll(filepath.Join(t.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to delete replica: %w", err)
+	}
+
+	return nil
+}
+
+// install moves the received copy in tmp into place as name and swaps it in.
+func (t *ReplicaTarget) install(name, tmp string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if name <= t.current {
+		return fmt.Errorf("replica %s is not newer than the current replica %s", name, t.current)
+	}
+
+	path := filepath.Join(t.dir, name)
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("replica %s already exists", name)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("failed to move replica into place: %w", err)
+	}
+
+	err = t.db.swapEnv(path)
+	if err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("failed to swap in replica: %w", err)
+	}
+
+	previous := t.current
+	t.current = name
+	if previous != "" {
+		err = os.RemoveAll(filepath.Join(t.dir, previous))
+		if err != nil {
+			t.db.options.log.Warn().Err(err).Str("replica", previous).Msg("failed to delete previous replica")
+		}
+	}
+
+	return nil
+}
+
+// listReplicas returns the names of the received copies in dir starting with
+// prefix, oldest first.
+func listReplicas(dir, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicas: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, replicaTmpPrefix) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		_, err = os.Stat(filepath.Join(dir, name, dataFileName))
+		if err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// replicaUpload is a copy being received by a ReplicaTarget.
+type replicaUpload struct {
+	target *ReplicaTarget
+	name   string
+	tmp    string
+	f      *os.File
+}
+
+func (u *replicaUpload) Write(p []byte) (int, error) {
+	return u.f.Write(p)
+}
+
+func (u *replicaUpload) Commit() (err error) {
+	defer func() {
+		if err != nil {
+			u.Abort()
+		}
+	}()
+
+	err = u.f.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync data file: %w", err)
+	}
+	df := &dataFile{f: u.f}
+	err = df.readMeta()
+	if err != nil {
+		return fmt.Errorf("replica is not an LMDB data file: %w", err)
+	}
+	info, err := u.f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat data file: %w", err)
+	}
+	err = df.checkSize(info.Size())
+	if err != nil {
+		return fmt.Errorf("replica is not a complete LMDB data file: %w", err)
+	}
+	err = u.f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close data file: %w", err)
+	}
+	err = syncDir(u.tmp)
+	if err != nil {
+		return err
+	}
+
+	return u.target.install(u.name, u.tmp)
+}
+
+func (u *replicaUpload) Abort() error {
+	u.f.Close()
+	err := os.RemoveAll(u.tmp)
+	if err != nil {
+		return fmt.Errorf("failed to delete staging directory: %w", err)
+	}
+
+	return nil
+}
+
+// swapEnv makes the Client serve the environment in the directory path
+// instead of its current one. Operations that already acquired the current
+// environment finish on it, and it is terminated once they have.
+func (db *Client) swapEnv(path string) error {
+	db.lifecycle.Lock()
+	defer db.lifecycle.Unlock()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	oldEnv, oldKey, oldPath, oldInflight := db.db, db.envKey, db.path, db.inflight
+	db.path = path
+	db.mu.Unlock()
+
+	restorePath := func() {
+		db.mu.Lock()
+		db.path = oldPath
+		db.mu.Unlock()
+	}
+
+	if oldEnv == nil {
+		err := db.open()
+		if err != nil {
+			restorePath()
+		}
+		return err
+	}
+
+	newEnv, err := db.init()
+	if err != nil {
+		restorePath()
+		return err
+	}
+
+	done := func() {}
+	if db.snapshotPool != nil {
+		done = db.snapshotPool.changing()
+	}
+	db.mu.Lock()
+	db.db = newEnv
+	db.inflight = new(sync.WaitGroup)
+	db.mu.Unlock()
+	done()
+
+	oldInflight.Wait()
+	if releaseShared(oldKey) {
+		dropBlooms(oldEnv)
+		oldEnv.Close()
+	}
+	db.readCache.clear()
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// newReplicaTarget returns a ReplicaTarget receiving copies into dir, whose
+// Client is closed when t finishes.
+func newReplicaTarget(t *testing.T, dir string) *ezdb.ReplicaTarget {
+	t.Helper()
+
+	target, err := ezdb.NewReplicaTarget(dir, ezdb.WithNumDBs(16))
+	if err != nil {
+		t.Fatalf("NewReplicaTarget: %v", err)
+	}
+	t.Cleanup(func() { target.Client().Close() })
+
+	return target
+}
+
+func TestReplica(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+	dir := t.TempDir()
+	target := newReplicaTarget(t, dir)
+	if target.Current() != "" {
+		t.Fatalf("Current of an empty target = %q", target.Current())
+	}
+
+	source, err := ezdb.NewReplicaSource(db, target, time.Hour)
+	if err != nil {
+		t.Fatalf("NewReplicaSource: %v", err)
+	}
+	defer source.Stop()
+	err = source.Ship(context.Background())
+	if err != nil {
+		t.Fatalf("Ship: %v", err)
+	}
+	first := target.Current()
+	if last := source.Last(); last == nil || last.Err != nil || last.Path != first {
+		t.Fatalf("Last = %+v, want the current replica %s", last, first)
+	}
+	replica, err := ezdb.NewRef[string, string]("ref", target.Client())
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, replica, 10)
+	key, val := "k", "v"
+	err = replica.Put(&key, &val)
+	if err == nil {
+		t.Fatal("Put to a replica succeeded")
+	}
+
+	// The DBRef reads the new copy once it is swapped in, and the previous
+	// one is deleted.
+	putN(t, ref, 20)
+	err = source.Ship(context.Background())
+	if err != nil {
+		t.Fatalf("Ship: %v", err)
+	}
+	wantN(t, replica, 20)
+	names, err := target.List(context.Background(), "")
+	if err != nil || len(names) != 1 || names[0] != target.Current() || names[0] == first {
+		t.Fatalf("List = %v, %v, current %s", names, err, target.Current())
+	}
+	err = target.Delete(context.Background(), target.Current())
+	if err == nil {
+		t.Fatal("Delete of the current replica succeeded")
+	}
+
+	// A new target serves the newest copy in its directory.
+	current := target.Current()
+	err = target.Client().Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened := newReplicaTarget(t, dir)
+	if reopened.Current() != current {
+		t.Fatalf("Current after reopening = %s, want %s", reopened.Current(), current)
+	}
+	replica, err = ezdb.NewRef[string, string]("ref", reopened.Client())
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, replica, 20)
+}
+
+func TestReplicaTargetRejectsBadCopies(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 1000)
+	var buf bytes.Buffer
+	err = db.Backup(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	dir := t.TempDir()
+	target := newReplicaTarget(t, dir)
+
+	receive := func(name string, data []byte) error {
+		upload, err := target.Create(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		_, err = upload.Write(data)
+		if err != nil {
+			return err
+		}
+		return upload.Commit()
+	}
+
+	for _, name := range []string{"", "../escape", "tmp-copy"} {
+		_, err = target.Create(context.Background(), name)
+		if err == nil {
+			t.Errorf("Create of %q succeeded", name)
+		}
+	}
+	err = receive("replica-2", bytes.Repeat([]byte("garbage!"), 4096))
+	if err == nil {
+		t.Fatal("Commit of garbage succeeded")
+	}
+	err = receive("replica-2", buf.Bytes()[:buf.Len()/2])
+	if err == nil {
+		t.Fatal("Commit of a truncated copy succeeded")
+	}
+	if target.Current() != "" {
+		t.Fatalf("Current after rejected copies = %q", target.Current())
+	}
+
+	err = receive("replica-2", buf.Bytes())
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	err = receive("replica-1", buf.Bytes())
+	if err == nil {
+		t.Fatal("Commit of an older copy succeeded")
+	}
+	if target.Current() != "replica-2" {
+		t.Fatalf("Current = %q, want replica-2", target.Current())
+	}
+	// Rejected copies leave nothing behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("replica directory holds %v, %v", entries, err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "replica-2"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+}
+
+func TestReplicaSourceFailures(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	_, err := ezdb.NewReplicaSource(db, newMemSink(), 0)
+	if err == nil {
+		t.Fatal("NewReplicaSource with a zero interval succeeded")
+	}
+
+	source, err := ezdb.NewReplicaSource(testutil.NewMemoryClient(t), newMemSink(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewReplicaSource: %v", err)
+	}
+	defer source.Stop()
+	err = source.Ship(context.Background())
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("Ship of a memory Client returned %v, want ErrUnsupported", err)
+	}
+	if last := source.Last(); last == nil || last.Err == nil || last.Path != "" {
+		t.Fatalf("Last = %+v, want the failure", last)
+	}
+}
@@ -0,0 +1,532 @@
+package ezdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/bjornpagen/ezdb/ezdbpb"
+)
+
+// replicationDB is the named database a Follower records its position in the
+// leader's audit log in, under replicationSeqKey.
+const (
+	replicationDB     = "ezdb.replication"
+	replicationSeqKey = "seq"
+)
+
+// replicationHeartbeat is how often the leader sends a batch, empty if there
+// are no changes, so followers can tell their lag and that the leader is
+// alive.
+const replicationHeartbeat = time.Second
+
+// snapshotChunkSize is the size of the chunks a snapshot is streamed in.
+const snapshotChunkSize = 64 << 10
+
+// ReplicationServer returns the gRPC service Replication of package ezdbpb, for
+// Followers to replicate the Client from. Register it with
+// ezdbpb.RegisterReplicationServer on a grpc.Server, or on a server.Server.
+// It serves a dump of the environment, see Dump, and streams the changes
+// recorded in the audit log. The Client must be created with WithAuditLog;
+// the audit log is the change feed that followers catch up from, so it must
+// never be trimmed while they may lag behind.
+func (db *Client) ReplicationServer() ezdbpb.ReplicationServer {
+	return &replicationServer{db: db}
+}
+
+// replicationServer implements the gRPC service Replication.
+type replicationServer struct {
+	ezdbpb.UnimplementedReplicationServer
+	db *Client
+}
+
+func (s *replicationServer) Snapshot(req *ezdbpb.SnapshotRequest, stream ezdbpb.Replication_SnapshotServer) error {
+	if !s.db.options.audit {
+		return status.Error(codes.FailedPrecondition, "audit log is not enabled")
+	}
+
+	bw := bufio.NewWriterSize(snapshotWriter{stream}, snapshotChunkSize)
+	err := s.db.Dump(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		if stream.Context().Err() == nil {
+			s.db.options.log.Error().Err(err).Msg("failed to serve replication snapshot")
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (s *replicationServer) Changes(req *ezdbpb.ChangesRequest, stream ezdbpb.Replication_ChangesServer) error {
+	if !s.db.options.audit {
+		return status.Error(codes.FailedPrecondition, "audit log is not enabled")
+	}
+
+	err := s.db.streamChanges(stream.Context(), req.After, stream.Send)
+	if err != nil && stream.Context().Err() == nil {
+		s.db.options.log.Error().Err(err).Msg("failed to serve replication changes")
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return err
+}
+
+// snapshotWriter sends what is written to it as snapshot chunks.
+type snapshotWriter struct {
+	stream ezdbpb.Replication_SnapshotServer
+}
+
+func (w snapshotWriter) Write(p []byte) (int, error) {
+	err := w.stream.Send(&ezdbpb.SnapshotChunk{Data: p})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// nextCommit returns a channel that is closed once the next write transaction
+// of the Client commits.
+func (db *Client) nextCommit() <-chan struct{} {
+	for {
+		if signal := db.commitSignal.Load(); signal != nil {
+			return *signal
+		}
+		signal := make(chan struct{})
+		if db.commitSignal.CompareAndSwap(nil, &signal) {
+			return signal
+		}
+	}
+}
+
+// streamChanges sends batches of the changes after the audit record after
+// until ctx is done or sending fails.
+func (db *Client) streamChanges(ctx context.Context, after uint64, send func(*ezdbpb.ChangeBatch) error) error {
+	ticker := time.NewTicker(replicationHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		// Taken before reading, so a commit in between isn't missed.
+		commit := db.nextCommit()
+
+		batch, err := db.changeBatch(&after)
+		if err != nil {
+			return err
+		}
+		err = send(batch)
+		if err != nil {
+			return fmt.Errorf("failed to send changes: %w", err)
+		}
+		if len(batch.Changes) == copyChunkSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-commit:
+		case <-ticker.C:
+		}
+	}
+}
+
+// changeBatch returns a batch of the next changes after *after, up to
+// copyChunkSize, and advances *after past them.
+func (db *Client) changeBatch(after *uint64) (*ezdbpb.ChangeBatch, error) {
+	batch := &ezdbpb.ChangeBatch{}
+	err := db.view(func(txn readTxn) error {
+		auditRef, err := txn.DBRef(auditDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get audit log db ref: %w", err)
+		}
+		leaderSeq, err := lastSeq(txn, auditRef)
+		if err != nil {
+			return err
+		}
+		if leaderSeq < *after {
+			return fmt.Errorf("follower is at %d, ahead of the audit log at %d", *after, leaderSeq)
+		}
+		count := leaderSeq - *after
+		if count > copyChunkSize {
+			count = copyChunkSize
+		}
+		batch.LeaderSeq = leaderSeq
+
+		cursor, err := txn.NewCursor(auditRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		_, recBytes, err := cursor.SeekGreaterThanOrEqualKey(binary.BigEndian.AppendUint64(nil, *after+1))
+		for ; err == nil && uint64(len(batch.Changes)) < count; _, recBytes, err = cursor.Next() {
+			var rec AuditRecord
+			err = GobCodec{}.Unmarshal(recBytes, &rec)
+			if err != nil {
+				return fmt.Errorf("failed to decode audit record: %w", err)
+			}
+			// Values are sent as of this transaction rather than of the
+			// write, which converges to the same state once the follower has
+			// applied the later records too.
+			val, err := currentValue(txn, rec.DBRef, rec.Key)
+			if err != nil {
+				return err
+			}
+
+			batch.Changes = append(batch.Changes, &ezdbpb.Change{
+				Record: bytes.Clone(recBytes),
+				Exists: val != nil,
+				Value:  bytes.Clone(val),
+			})
+			*after = rec.Seq
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if uint64(len(batch.Changes)) != count {
+			return errors.New("audit log has gaps")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+
+	return batch, nil
+}
+
+// currentValue returns the value stored under key in the named database name,
+// or nil if there is none.
+func currentValue(txn readTxn, name string, key []byte) ([]byte, error) {
+	dbRef, err := txn.DBRef(name, dbFlag(0))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref %s: %w", name, err)
+	}
+
+	val, err := txn.Get(dbRef, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
+	}
+	if val == nil {
+		val = []byte{}
+	}
+
+	return val, nil
+}
+
+// Follower replicates a leader's Client, served by ReplicationServer, into a
+// local Client, see NewFollower.
+type Follower struct {
+	db     *Client
+	leader ezdbpb.ReplicationClient
+
+	mu     sync.Mutex
+	status FollowerStatus
+}
+
+// FollowerStatus describes how far a Follower has caught up with its leader.
+type FollowerStatus struct {
+	// Applied is the sequence number of the last change applied, in the
+	// leader's audit log.
+	Applied uint64
+	// Leader is the sequence number of the leader's last change, as of
+	// LastContact.
+	Leader uint64
+	// LastContact is when the follower last heard from the leader, which
+	// sends a heartbeat every second.
+	LastContact time.Time
+	// Err is why the last attempt to replicate failed, or nil.
+	Err error
+}
+
+// Lag returns the number of changes the follower is behind the leader.
+func (s FollowerStatus) Lag() uint64 {
+	if s.Leader < s.Applied {
+		return 0
+	}
+	return s.Leader - s.Applied
+}
+
+// NewFollower returns a Follower replicating the leader served by
+// ReplicationServer on the other end of conn, such as a *grpc.ClientConn,
+// into db. The follower first loads a snapshot of the
+// leader, so db must be empty the first time; afterwards it resumes after the
+// last change it applied, which is recorded in its own named database
+// together with the changes. Changes are written through the DBRefs opened on
+// db, so their indexes, triggers and watchers see them, and as stored
+// otherwise; the leader's audit records are copied too, so the follower can
+// take over as leader by reopening it with WithAuditLog. db must not be
+// written to otherwise, and must not have WithAuditLog while following.
+func NewFollower(db *Client, conn grpc.ClientConnInterface) (*Follower, error) {
+	if db.options.audit {
+		return nil, errors.New("a follower must not have its own audit log")
+	}
+	err := db.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	f := &Follower{db: db, leader: ezdbpb.NewReplicationClient(conn)}
+	if !db.follower.CompareAndSwap(nil, f) {
+		return nil, errors.New("client already has a follower")
+	}
+
+	return f, nil
+}
+
+// Run replicates until ctx is done and returns ctx.Err(). Failures, such as a
+// lost connection to the leader, are retried with backoff and reported by
+// Status.
+func (f *Follower) Run(ctx context.Context) error {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Duration(0)
+
+	for {
+		err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		f.mu.Lock()
+		f.status.Err = err
+		f.mu.Unlock()
+		f.db.options.log.Error().Err(err).Msg("failed to replicate")
+
+		backoff = 2*backoff + 100*time.Millisecond
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Status returns how far the follower has caught up.
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.status
+}
+
+// Stop detaches the follower from its Client. Stop it after Run returned.
+func (f *Follower) Stop() {
+	f.db.follower.CompareAndSwap(f, nil)
+}
+
+// follow loads a snapshot if the follower has none yet and applies the
+// changes streamed by the leader until the stream fails.
+func (f *Follower) follow(ctx context.Context) error {
+	applied, ok, err := f.position()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		applied, err = f.loadSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	f.status.Applied = applied
+	f.mu.Unlock()
+
+	stream, err := f.leader.Changes(ctx, &ezdbpb.ChangesRequest{After: applied})
+	if err != nil {
+		return fmt.Errorf("failed to reach leader: %w", err)
+	}
+
+	for {
+		batch, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("failed to read changes: %w", err)
+		}
+		if len(batch.Changes) > copyChunkSize {
+			return fmt.Errorf("failed to read changes: batch of %d", len(batch.Changes))
+		}
+
+		if len(batch.Changes) > 0 {
+			applied, err = f.applyBatch(batch.Changes)
+			if err != nil {
+				return err
+			}
+		}
+
+		f.mu.Lock()
+		f.status = FollowerStatus{Applied: applied, Leader: batch.LeaderSeq, LastContact: time.Now()}
+		f.mu.Unlock()
+	}
+}
+
+// position returns the follower's position in the leader's audit log, and
+// whether it has loaded a snapshot yet.
+func (f *Follower) position() (seq uint64, ok bool, err error) {
+	err = f.db.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(replicationDB, dbFlag(0))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get replication db ref: %w", err)
+		}
+
+		val, err := txn.Get(dbRef, []byte(replicationSeqKey))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get replication position: %w", err)
+		}
+		if len(val) != 8 {
+			return errors.New("corrupt replication position")
+		}
+		seq, ok = binary.BigEndian.Uint64(val), true
+		return nil
+	})
+
+	return seq, ok, err
+}
+
+// loadSnapshot loads a dump of the leader and records the position it was
+// taken at, the last record of the audit log it includes.
+func (f *Follower) loadSnapshot(ctx context.Context) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := f.leader.Snapshot(ctx, &ezdbpb.SnapshotRequest{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach leader: %w", err)
+	}
+
+	err = f.db.Load(&snapshotReader{stream: stream})
+	if err != nil {
+		return 0, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	var seq uint64
+	err = f.db.update(func(txn writeTxn) error {
+		auditRef, err := txn.DBRef(auditDB, dbCreate)
+		if err != nil {
+			return fmt.Errorf("failed to get audit log db ref: %w", err)
+		}
+		seq, err = lastSeq(txn, auditRef)
+		if err != nil {
+			return err
+		}
+		return putPosition(txn, seq)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record snapshot position: %w", err)
+	}
+	f.db.options.log.Info().Uint64("seq", seq).Msg("loaded replication snapshot")
+
+	return seq, nil
+}
+
+// applyBatch applies changes in one transaction, along with the new
+// position, which it returns.
+func (f *Follower) applyBatch(changes []*ezdbpb.Change) (uint64, error) {
+	recs := make([]AuditRecord, len(changes))
+	for i, c := range changes {
+		err := GobCodec{}.Unmarshal(c.Record, &recs[i])
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode audit record: %w", err)
+		}
+	}
+
+	refs := f.db.registeredRefs()
+	seq := recs[len(recs)-1].Seq
+	err := f.db.update(func(txn writeTxn) error {
+		auditRef, err := txn.DBRef(auditDB, dbCreate)
+		if err != nil {
+			return fmt.Errorf("failed to get audit log db ref: %w", err)
+		}
+
+		for i, c := range changes {
+			rec := mirrorRecord{DBRef: recs[i].DBRef, Delete: !c.Exists, Key: recs[i].Key, Value: c.Value}
+			var from refInfo
+			var to *refInfo
+			if info, ok := refs[recs[i].DBRef]; ok {
+				from, to = info, &info
+			}
+			err = writeRecord(txn, rec, from, to)
+			if err != nil {
+				return fmt.Errorf("failed to apply change %d: %w", recs[i].Seq, err)
+			}
+			f.db.invalidateInTxn(txn, rec.DBRef, rec.Key)
+			if !rec.Delete {
+				err = f.db.bloomAddInTxn(txn, rec.DBRef, nil, rec.Key)
+				if err != nil {
+					return fmt.Errorf("failed to apply change %d: %w", recs[i].Seq, err)
+				}
+			}
+
+			err = txn.Put(auditRef, binary.BigEndian.AppendUint64(nil, recs[i].Seq), c.Record, putFlag(0))
+			if err != nil {
+				return fmt.Errorf("failed to copy audit record: %w", err)
+			}
+		}
+
+		return putPosition(txn, seq)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// putPosition records seq as the follower's position.
+func putPosition(txn writeTxn, seq uint64) error {
+	dbRef, err := txn.DBRef(replicationDB, dbCreate)
+	if err != nil {
+		return fmt.Errorf("failed to get replication db ref: %w", err)
+	}
+
+	err = txn.Put(dbRef, []byte(replicationSeqKey), binary.BigEndian.AppendUint64(nil, seq), putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to record replication position: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotReader reads the chunks of a snapshot stream.
+type snapshotReader struct {
+	stream ezdbpb.Replication_SnapshotClient
+	buf    []byte
+}
+
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Data
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,183 @@
+package ezdb_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/ezdbpb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// serveReplication serves the replication service of leader in memory and
+// returns a connection to it.
+func serveReplication(t *testing.T, leader *ezdb.Client) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	ezdbpb.RegisterReplicationServer(srv, leader.ReplicationServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// runFollower runs a Follower of the leader on conn into db until the
+// returned function is called.
+func runFollower(t *testing.T, db *ezdb.Client, conn *grpc.ClientConn) (*ezdb.Follower, func()) {
+	t.Helper()
+
+	f, err := ezdb.NewFollower(db, conn)
+	if err != nil {
+		t.Fatalf("NewFollower: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	stop := func() {
+		cancel()
+		err := <-done
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want context.Canceled", err)
+		}
+		f.Stop()
+	}
+	t.Cleanup(func() {
+		select {
+		case <-ctx.Done():
+		default:
+			stop()
+		}
+	})
+
+	return f, stop
+}
+
+// waitApplied waits for f to apply the leader's audit log up to its last
+// record.
+func waitApplied(t *testing.T, f *ezdb.Follower, leader *ezdb.Client) {
+	t.Helper()
+
+	recs := auditLog(t, leader, 0)
+	want := recs[len(recs)-1].Seq
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if s := f.Status(); s.Applied == want && s.Lag() == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("follower at %+v, want %d", f.Status(), want)
+}
+
+func TestReplication(t *testing.T) {
+	leader := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	ref, err := ezdb.NewRef[string, string]("ref", leader)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+	conn := serveReplication(t, leader)
+	dir := t.TempDir()
+	db := openClient(t, dir)
+
+	// The snapshot brings the follower up to date.
+	f, stop := runFollower(t, db, conn)
+	waitApplied(t, f, leader)
+	replica, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, replica, 100)
+
+	// Later writes are streamed.
+	putN(t, ref, 150)
+	key := "k0149"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	waitApplied(t, f, leader)
+	wantN(t, replica, 149)
+	if s := f.Status(); s.Err != nil || s.LastContact.IsZero() {
+		t.Fatalf("Status = %+v", s)
+	}
+	stop()
+
+	// A new follower resumes where the last one stopped, rather than loading
+	// a snapshot into the non-empty Client.
+	putN(t, ref, 200)
+	f, stop = runFollower(t, db, conn)
+	waitApplied(t, f, leader)
+	wantN(t, replica, 200)
+	if s := f.Status(); s.Err != nil {
+		t.Fatalf("Status = %+v", s)
+	}
+	stop()
+
+	// The follower can take over with the leader's audit log.
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	db = openClient(t, dir, ezdb.WithAuditLog())
+	if got, want := len(auditLog(t, db, 0)), len(auditLog(t, leader, 0)); got != want {
+		t.Fatalf("follower has %d audit records, the leader %d", got, want)
+	}
+}
+
+func TestReplicationWithoutAuditLog(t *testing.T) {
+	leader := testutil.NewTempClient(t)
+	f, _ := runFollower(t, testutil.NewTempClient(t), serveReplication(t, leader))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for f.Status().Err == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if code := status.Code(f.Status().Err); code != codes.FailedPrecondition {
+		t.Fatalf("Status().Err = %v, want FailedPrecondition", f.Status().Err)
+	}
+}
+
+func TestNewFollowerRejectsInvalidClients(t *testing.T) {
+	leader := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	conn := serveReplication(t, leader)
+
+	_, err := ezdb.NewFollower(testutil.NewTempClient(t, ezdb.WithAuditLog()), conn)
+	if err == nil {
+		t.Fatal("NewFollower of a Client with an audit log succeeded")
+	}
+	db := testutil.NewTempClient(t)
+	f, err := ezdb.NewFollower(db, conn)
+	if err != nil {
+		t.Fatalf("NewFollower: %v", err)
+	}
+	_, err = ezdb.NewFollower(db, conn)
+	if err == nil {
+		t.Fatal("second NewFollower of a Client succeeded")
+	}
+	f.Stop()
+	_, err = ezdb.NewFollower(db, conn)
+	if err != nil {
+		t.Fatalf("NewFollower after Stop: %v", err)
+	}
+}
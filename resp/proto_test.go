@@ -0,0 +1,130 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$4\r\na\r\nb\r\n" +
+		"SET  k v\n" +
+		"*1\r\n$0\r\n\r\n" +
+		"\r\n"))
+
+	for _, want := range []string{`["GET" "a\r\nb"]`, `["SET" "k" "v"]`, `[""]`, `[]`} {
+		args, err := readCommand(r)
+		if err != nil {
+			t.Fatalf("readCommand: %v", err)
+		}
+		if got := quoteArgs(args); got != want {
+			t.Fatalf("readCommand = %s, want %s", got, want)
+		}
+	}
+	_, err := readCommand(r)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("readCommand at the end returned %v, want io.EOF", err)
+	}
+}
+
+func quoteArgs(args [][]byte) string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(`"`+string(arg)+`"`, "\r", `\r`), "\n", `\n`))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func TestReadCommandErrors(t *testing.T) {
+	for _, input := range []string{
+		"*x\r\n",
+		"*-1\r\n",
+		"*2000000\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$33554433\r\n",
+		"*1\r\n$1\r\nab\r\n",
+		strings.Repeat("a", 5000) + "\r\n",
+	} {
+		r := bufio.NewReaderSize(strings.NewReader(input), 4096)
+		_, err := readCommand(r)
+		if !errors.Is(err, errProtocol) {
+			t.Errorf("readCommand(%.20q) returned %v, want a protocol error", input, err)
+		}
+	}
+
+	// A request cut off is not a protocol error, just the end of the input.
+	r := bufio.NewReader(strings.NewReader("*1\r\n$5\r\nab"))
+	_, err := readCommand(r)
+	if err == nil || errors.Is(err, errProtocol) {
+		t.Fatalf("readCommand of a truncated request returned %v", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := writer{bufio.NewWriter(&buf)}
+
+	w.simple("OK")
+	w.error("ERR two\r\nlines")
+	w.int(-3)
+	w.bulk([]byte("a\r\nb"))
+	w.null()
+	w.array(2)
+	w.Flush()
+
+	want := "+OK\r\n-ERR two  lines\r\n:-3\r\n$4\r\na\r\nb\r\n$-1\r\n*2\r\n"
+	if buf.String() != want {
+		t.Fatalf("wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, literal string
+		match, noMatch   []string
+	}{
+		{"user:*", "user:", []string{"user:", "user:1"}, []string{"use", "xuser:1"}},
+		{"h?llo", "h", []string{"hello", "hallo"}, []string{"hllo", "heello"}},
+		{"h[ae]llo", "h", []string{"hello", "hallo"}, []string{"hillo"}},
+		{"h[^e]llo", "h", []string{"hallo"}, []string{"hello"}},
+		{"h[!e]llo", "h", []string{"hallo"}, []string{"hello"}},
+		{`a\*b`, "a*b", []string{"a*b"}, []string{"axb"}},
+		{"a.b", "a.b", []string{"a.b"}, []string{"axb"}},
+		{"café:*", "café:", []string{"café:1"}, []string{"cafe:1"}},
+		{"*\n", "", []string{"a\n", "a\nb\n"}, []string{"a"}},
+	}
+	for _, test := range tests {
+		re, literal, err := globRegexp(test.pattern)
+		if err != nil {
+			t.Errorf("globRegexp(%q): %v", test.pattern, err)
+			continue
+		}
+		if literal != test.literal {
+			t.Errorf("globRegexp(%q) has literal prefix %q, want %q", test.pattern, literal, test.literal)
+		}
+		for _, s := range test.match {
+			if !re.MatchString(s) {
+				t.Errorf("pattern %q doesn't match %q", test.pattern, s)
+			}
+		}
+		for _, s := range test.noMatch {
+			if re.MatchString(s) {
+				t.Errorf("pattern %q matches %q", test.pattern, s)
+			}
+		}
+	}
+
+	_, _, err := globRegexp("a[b")
+	if err == nil {
+		t.Fatal("globRegexp of an unterminated class succeeded")
+	}
+}
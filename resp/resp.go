@@ -0,0 +1,657 @@
+// Package resp serves DBRefs of an ezdb Client over RESP, the protocol of
+// Redis, so existing Redis clients in any language can read and write them,
+// e.g. while an application migrates from Redis to ezdb.
+//
+// Only a subset of Redis is supported, on string keys and values:
+//
+//	GET key
+//	SET key value [EX seconds | PX milliseconds]
+//	DEL key [key ...]
+//	EXPIRE key seconds
+//	SCAN cursor [MATCH pattern] [COUNT count]
+//
+// along with PING, ECHO, SELECT, AUTH, QUIT and an empty COMMAND reply for
+// clients that ask for the command table. Each DBRef is served as a Redis
+// database number, which clients pick with SELECT or in their connection URL.
+// SET with a TTL and EXPIRE need DBRefs created with ezdb.WithTTL.
+package resp
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+const (
+	// defaultScanCount is the number of keys SCAN returns without COUNT.
+	defaultScanCount = 10
+	// maxScanCount bounds COUNT.
+	maxScanCount = 10000
+	// maxCursors bounds the SCAN cursors a Server remembers; the oldest are
+	// forgotten first.
+	maxCursors = 4096
+)
+
+type Option func(option *options) error
+
+type options struct {
+	tls      *tls.Config
+	password string
+}
+
+// WithTLS makes the Server accept only TLS connections, configured by config,
+// which must have a certificate.
+func WithTLS(config *tls.Config) Option {
+	return func(option *options) error {
+		if config == nil {
+			return errors.New("TLS config must not be nil")
+		}
+		option.tls = config
+		return nil
+	}
+}
+
+// WithPassword requires clients to authenticate with AUTH and password before
+// any other command, like Redis' requirepass. Any user name is accepted.
+func WithPassword(password string) Option {
+	return func(option *options) error {
+		if password == "" {
+			return errors.New("password must not be empty")
+		}
+		option.password = password
+		return nil
+	}
+}
+
+// Server serves the DBRefs registered with Register.
+type Server struct {
+	options *options
+
+	mu        sync.Mutex
+	routes    map[int]route
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+
+	// cursors map the SCAN cursors handed out to where the scans continue,
+	// and cursorOrder holds them oldest first.
+	cursors     map[uint64]scanCursor
+	cursorOrder []uint64
+	nextCursor  uint64
+}
+
+// scanCursor is where a SCAN continues: after the key after of database db.
+type scanCursor struct {
+	db    int
+	after string
+}
+
+// route serves the commands for one DBRef.
+type route struct {
+	get    func(key string) (val []byte, found bool, err error)
+	set    func(key string, val []byte, ttl time.Duration) error
+	del    func(key string) (found bool, err error)
+	expire func(key string, ttl time.Duration) (found bool, err error)
+	// scan returns up to count keys starting with prefix for which match
+	// returns true, after the key after if it isn't nil.
+	scan func(prefix string, after *string, match func(key string) bool, count int) ([]string, error)
+}
+
+// New returns a Server.
+func New(opts ...Option) (*Server, error) {
+	o := &options{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	return &Server{
+		options:   o,
+		routes:    make(map[int]route),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		cursors:   make(map[uint64]scanCursor),
+	}, nil
+}
+
+// Register serves ref as the Redis database number index. Clients start out
+// on database 0.
+func Register[V ~string | ~[]byte](s *Server, index int, ref *ezdb.DBRef[string, V]) error {
+	if index < 0 {
+		return errors.New("database index must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.routes[index]; ok {
+		return fmt.Errorf("database %d is already registered", index)
+	}
+
+	s.routes[index] = route{
+		get: func(key string) ([]byte, bool, error) {
+			val, ok, err := ref.TryGet(&key)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			return []byte(*val), true, nil
+		},
+		set: func(key string, val []byte, ttl time.Duration) error {
+			v := V(val)
+			if ttl > 0 {
+				return ref.PutTTL(&key, &v, ttl)
+			}
+			return ref.Put(&key, &v)
+		},
+		del: func(key string) (bool, error) {
+			err := ref.Delete(&key)
+			if errors.Is(err, ezdb.ErrNotFound) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		expire: func(key string, ttl time.Duration) (bool, error) {
+			return ref.Expire(&key, ttl)
+		},
+		scan: func(prefix string, after *string, match func(key string) bool, count int) ([]string, error) {
+			q := ref.Query().Limit(count)
+			if prefix != "" {
+				q = q.WherePrefix(prefix)
+			}
+			if after != nil {
+				q = q.After(after)
+			}
+			if match != nil {
+				q = q.Filter(func(key *string, val *V) bool { return match(*key) })
+			}
+			var keys []string
+			err := q.Each(func(key *string, val *V) error {
+				keys = append(keys, *key)
+				return nil
+			})
+			return keys, err
+		},
+	}
+
+	return nil
+}
+
+// route returns the route of database index.
+func (s *Server) route(index int) (route, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.routes[index]
+	return r, ok
+}
+
+// Serve accepts connections on l and serves them until l fails or Close is
+// called. With WithTLS, l is wrapped to accept TLS connections.
+func (s *Server) Serve(l net.Listener) error {
+	if s.options.tls != nil {
+		l = tls.NewListener(l, s.options.tls)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ezdb.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ezdb.ErrClosed
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	return s.Serve(l)
+}
+
+// Close stops the listeners and closes all connections. It doesn't close the
+// Client.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var errs []error
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+	for conn := range s.conns {
+		errs = append(errs, conn.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// session is the state of a connection.
+type session struct {
+	db     int
+	authed bool
+	quit   bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, 64<<10)
+	w := writer{bufio.NewWriter(conn)}
+	sess := &session{authed: s.options.password == ""}
+
+	for !sess.quit {
+		args, err := readCommand(r)
+		if errors.Is(err, errProtocol) {
+			w.error("ERR " + err.Error())
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.exec(sess, w, args)
+		// Replies to pipelined commands are flushed together.
+		if r.Buffered() == 0 {
+			err = w.Flush()
+			if err != nil {
+				return
+			}
+		}
+	}
+	w.Flush()
+}
+
+// exec runs the command args and writes its reply.
+func (s *Server) exec(sess *session, w writer, args [][]byte) {
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+
+	wrongArgs := func() {
+		w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+
+	switch name {
+	case "AUTH":
+		if len(args) < 1 || len(args) > 2 {
+			wrongArgs()
+			return
+		}
+		if s.options.password == "" {
+			w.error("ERR AUTH called without any password configured")
+			return
+		}
+		if subtle.ConstantTimeCompare(args[len(args)-1], []byte(s.options.password)) != 1 {
+			w.error("WRONGPASS invalid username-password pair")
+			return
+		}
+		sess.authed = true
+		w.simple("OK")
+		return
+	case "QUIT":
+		sess.quit = true
+		w.simple("OK")
+		return
+	}
+	if !sess.authed {
+		w.error("NOAUTH Authentication required.")
+		return
+	}
+
+	switch name {
+	case "PING":
+		switch len(args) {
+		case 0:
+			w.simple("PONG")
+		case 1:
+			w.bulk(args[0])
+		default:
+			wrongArgs()
+		}
+		return
+	case "ECHO":
+		if len(args) != 1 {
+			wrongArgs()
+			return
+		}
+		w.bulk(args[0])
+		return
+	case "COMMAND":
+		w.array(0)
+		return
+	case "SELECT":
+		if len(args) != 1 {
+			wrongArgs()
+			return
+		}
+		index, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		if _, ok := s.route(index); !ok {
+			w.error("ERR DB index is out of range")
+			return
+		}
+		sess.db = index
+		w.simple("OK")
+		return
+	}
+
+	rt, ok := s.route(sess.db)
+	if !ok {
+		w.error("ERR no DBRef is served as database " + strconv.Itoa(sess.db))
+		return
+	}
+
+	switch name {
+	case "GET":
+		if len(args) != 1 {
+			wrongArgs()
+			return
+		}
+		val, found, err := rt.get(string(args[0]))
+		switch {
+		case err != nil:
+			w.error("ERR " + err.Error())
+		case !found:
+			w.null()
+		default:
+			w.bulk(val)
+		}
+
+	case "SET":
+		if len(args) != 2 && len(args) != 4 {
+			w.error("ERR syntax error")
+			return
+		}
+		var ttl time.Duration
+		if len(args) == 4 {
+			n, err := strconv.ParseInt(string(args[3]), 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			switch strings.ToUpper(string(args[2])) {
+			case "EX":
+				ttl = time.Duration(n) * time.Second
+			case "PX":
+				ttl = time.Duration(n) * time.Millisecond
+			default:
+				w.error("ERR syntax error")
+				return
+			}
+		}
+		err := rt.set(string(args[0]), args[1], ttl)
+		if err != nil {
+			w.error("ERR " + err.Error())
+			return
+		}
+		w.simple("OK")
+
+	case "DEL":
+		if len(args) == 0 {
+			wrongArgs()
+			return
+		}
+		var n int64
+		for _, key := range args {
+			found, err := rt.del(string(key))
+			if err != nil {
+				w.error("ERR " + err.Error())
+				return
+			}
+			if found {
+				n++
+			}
+		}
+		w.int(n)
+
+	case "EXPIRE":
+		if len(args) != 2 {
+			wrongArgs()
+			return
+		}
+		seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		var found bool
+		if seconds <= 0 {
+			// Like Redis, a TTL in the past deletes the key.
+			found, err = rt.del(string(args[0]))
+		} else {
+			found, err = rt.expire(string(args[0]), time.Duration(seconds)*time.Second)
+		}
+		if err != nil {
+			w.error("ERR " + err.Error())
+			return
+		}
+		if found {
+			w.int(1)
+		} else {
+			w.int(0)
+		}
+
+	case "SCAN":
+		s.scan(sess, w, rt, args)
+
+	default:
+		w.error("ERR unknown command '" + strings.ToLower(name) + "'")
+	}
+}
+
+// scan runs SCAN. Cursors are remembered by the Server rather than encoding
+// a position, since keys can be of any length; 0 starts a scan and is
+// returned once it is done.
+func (s *Server) scan(sess *session, w writer, rt route, args [][]byte) {
+	if len(args) == 0 {
+		w.error("ERR wrong number of arguments for 'scan' command")
+		return
+	}
+	id, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return
+	}
+
+	count := defaultScanCount
+	var pattern string
+	for opts := args[1:]; len(opts) > 0; opts = opts[2:] {
+		if len(opts) < 2 {
+			w.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(string(opts[0])) {
+		case "MATCH":
+			pattern = string(opts[1])
+		case "COUNT":
+			count, err = strconv.Atoi(string(opts[1]))
+			if err != nil || count < 1 {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			if count > maxScanCount {
+				count = maxScanCount
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	var after *string
+	if id != 0 {
+		c, ok := s.cursor(id)
+		if !ok || c.db != sess.db {
+			w.error("ERR invalid cursor")
+			return
+		}
+		after = &c.after
+	}
+
+	var prefix string
+	var match func(string) bool
+	if pattern != "" && pattern != "*" {
+		re, literal, err := globRegexp(pattern)
+		if err != nil {
+			w.error("ERR invalid pattern")
+			return
+		}
+		prefix = literal
+		match = re.MatchString
+	}
+
+	keys, err := rt.scan(prefix, after, match, count)
+	if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+
+	next := uint64(0)
+	if len(keys) == count {
+		next = s.addCursor(scanCursor{db: sess.db, after: keys[len(keys)-1]})
+	}
+
+	w.array(2)
+	w.bulk([]byte(strconv.FormatUint(next, 10)))
+	w.array(len(keys))
+	for _, key := range keys {
+		w.bulk([]byte(key))
+	}
+}
+
+func (s *Server) cursor(id uint64) (scanCursor, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.cursors[id]
+	return c, ok
+}
+
+// addCursor remembers c and returns its id, forgetting the oldest cursor if
+// there are too many.
+func (s *Server) addCursor(c scanCursor) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cursorOrder) >= maxCursors {
+		delete(s.cursors, s.cursorOrder[0])
+		s.cursorOrder = s.cursorOrder[1:]
+	}
+	s.nextCursor++
+	s.cursors[s.nextCursor] = c
+	s.cursorOrder = append(s.cursorOrder, s.nextCursor)
+
+	return s.nextCursor
+}
+
+// globRegexp translates the Redis glob pattern into a regular expression,
+// and returns the literal prefix of the pattern, which the matching keys
+// start with.
+func globRegexp(pattern string) (re *regexp.Regexp, literal string, err error) {
+	var b strings.Builder
+	var lit strings.Builder
+	inLiteral := true
+
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+			inLiteral = false
+		case '?':
+			b.WriteString(".")
+			inLiteral = false
+		case '[':
+			j := strings.IndexByte(pattern[i+1:], ']')
+			if j < 0 {
+				return nil, "", errors.New("unterminated class")
+			}
+			class := pattern[i+1 : i+1+j]
+			if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			b.WriteString("[" + class + "]")
+			i += j + 1
+			inLiteral = false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				c = pattern[i]
+			}
+			fallthrough
+		default:
+			// Bytes of multibyte characters are copied as they are.
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			if inLiteral {
+				lit.WriteByte(c)
+			}
+		}
+	}
+	b.WriteString("$")
+
+	re, err = regexp.Compile(b.String())
+	if err != nil {
+		return nil, "", err
+	}
+
+	return re, lit.String(), nil
+}
@@ -0,0 +1,403 @@
+package resp_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/resp"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// conn is a connection to a Server speaking RESP.
+type conn struct {
+	t *testing.T
+	net.Conn
+	r *bufio.Reader
+}
+
+// serve serves s on a local port and returns a connection to it.
+func serve(t *testing.T, s *resp.Server) *conn {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		err := <-done
+		if !errors.Is(err, ezdb.ErrClosed) {
+			t.Errorf("Serve returned %v, want ErrClosed", err)
+		}
+	})
+
+	return dial(t, l.Addr().String())
+}
+
+func dial(t *testing.T, addr string) *conn {
+	t.Helper()
+
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { nc.Close() })
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+
+	return &conn{t: t, Conn: nc, r: bufio.NewReader(nc)}
+}
+
+// do sends the command args and returns the reply, formatted by reply.
+func (c *conn) do(args ...string) string {
+	c.t.Helper()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := c.Write([]byte(b.String()))
+	if err != nil {
+		c.t.Fatalf("Write: %v", err)
+	}
+
+	return c.reply()
+}
+
+// reply reads a reply and formats it: simple strings and errors as they are,
+// integers with a leading ':', bulk strings quoted, nulls as nil and arrays
+// in brackets.
+func (c *conn) reply() string {
+	c.t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("failed to read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+', '-':
+		return line[1:]
+	case ':':
+		return line
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "nil"
+		}
+		data := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, data)
+		if err != nil {
+			c.t.Fatalf("failed to read bulk string: %v", err)
+		}
+		return strconv.Quote(string(data[:n]))
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		elems := make([]string, n)
+		for i := range elems {
+			elems[i] = c.reply()
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return ""
+}
+
+// newServer returns a Server for two DBRefs of db: "strings" as database 0,
+// with TTLs, and "bytes" as database 1.
+func newServer(t *testing.T, db *ezdb.Client, opts ...resp.Option) *resp.Server {
+	t.Helper()
+
+	strs, err := ezdb.NewDBRef[string, string](db, "strings", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.StringCodec{}), ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	bytesRef, err := ezdb.NewDBRef[string, []byte](db, "bytes", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.BytesCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	s, err := resp.New(opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = resp.Register(s, 0, strs)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = resp.Register(s, 1, bytesRef)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = resp.Register(s, 1, bytesRef)
+	if err == nil {
+		t.Fatal("second Register of a database succeeded")
+	}
+	err = resp.Register(s, -1, bytesRef)
+	if err == nil {
+		t.Fatal("Register of a negative database succeeded")
+	}
+
+	return s
+}
+
+func TestCommands(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, newServer(t, db))
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "PONG"},
+		{[]string{"ping", "hi"}, `"hi"`},
+		{[]string{"ECHO", "a b"}, `"a b"`},
+		{[]string{"COMMAND"}, "[]"},
+		{[]string{"GET", "a"}, "nil"},
+		{[]string{"SET", "a", "1\r\n2"}, "OK"},
+		{[]string{"GET", "a"}, `"1\r\n2"`},
+		{[]string{"SET", "b", "2"}, "OK"},
+		{[]string{"DEL", "a", "b", "c"}, ":2"},
+		{[]string{"GET", "a"}, "nil"},
+		{[]string{"SELECT", "1"}, "OK"},
+		{[]string{"SET", "bin", "\x00\xff"}, "OK"},
+		{[]string{"GET", "bin"}, `"\x00\xff"`},
+		{[]string{"SELECT", "2"}, "ERR DB index is out of range"},
+		{[]string{"SELECT", "x"}, "ERR value is not an integer or out of range"},
+		{[]string{"SELECT", "0"}, "OK"},
+		{[]string{"GET", "bin"}, "nil"},
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"SET", "a"}, "ERR syntax error"},
+		{[]string{"SET", "a", "1", "KEEPTTL", "1"}, "ERR syntax error"},
+		{[]string{"SET", "a", "1", "EX", "0"}, "ERR invalid expire time in 'set' command"},
+		{[]string{"DEL"}, "ERR wrong number of arguments for 'del' command"},
+		{[]string{"EXPIRE", "a", "x"}, "ERR value is not an integer or out of range"},
+		{[]string{"FLUSHALL"}, "ERR unknown command 'flushall'"},
+		{[]string{"AUTH", "secret"}, "ERR AUTH called without any password configured"},
+	} {
+		if got := c.do(test.args...); got != test.want {
+			t.Errorf("%q = %s, want %s", test.args, got, test.want)
+		}
+	}
+
+	// Inline commands and pipelined requests are served too.
+	_, err := c.Write([]byte("PING\r\n*1\r\n$4\r\nPING\r\nECHO x\n"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, want := range []string{"PONG", "PONG", `"x"`} {
+		if got := c.reply(); got != want {
+			t.Fatalf("pipelined reply = %s, want %s", got, want)
+		}
+	}
+
+	if got := c.do("QUIT"); got != "OK" {
+		t.Fatalf("QUIT = %s", got)
+	}
+	_, err = c.r.ReadByte()
+	if err == nil {
+		t.Fatal("connection is open after QUIT")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, newServer(t, db))
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "ex", "v", "EX", "100"}, "OK"},
+		{[]string{"SET", "px", "v", "px", "1"}, "OK"},
+		{[]string{"SET", "plain", "v"}, "OK"},
+		{[]string{"EXPIRE", "plain", "100"}, ":1"},
+		{[]string{"EXPIRE", "missing", "100"}, ":0"},
+		// A TTL in the past deletes the key.
+		{[]string{"SET", "gone", "v"}, "OK"},
+		{[]string{"EXPIRE", "gone", "0"}, ":1"},
+		{[]string{"GET", "gone"}, "nil"},
+	} {
+		if got := c.do(test.args...); got != test.want {
+			t.Errorf("%q = %s, want %s", test.args, got, test.want)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if got := c.do("GET", "px"); got != "nil" {
+		t.Errorf("GET of an expired key = %s", got)
+	}
+	if got := c.do("GET", "ex"); got != `"v"` {
+		t.Errorf("GET of a key with a TTL = %s", got)
+	}
+
+	// TTLs need a DBRef with WithTTL.
+	c.do("SELECT", "1")
+	c.do("SET", "a", "v")
+	if got := c.do("EXPIRE", "a", "10"); !strings.HasPrefix(got, "ERR ") {
+		t.Errorf("EXPIRE without WithTTL = %s", got)
+	}
+	if got := c.do("SET", "b", "v", "EX", "10"); !strings.HasPrefix(got, "ERR ") {
+		t.Errorf("SET with a TTL without WithTTL = %s", got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	c := serve(t, newServer(t, db))
+	for i := 0; i < 25; i++ {
+		c.do("SET", fmt.Sprintf("user:%02d", i), "v")
+	}
+	c.do("SET", "other", "v")
+
+	// scanAll follows the cursors of SCAN with opts until it returns 0.
+	scanAll := func(opts ...string) []string {
+		t.Helper()
+		var keys []string
+		cursor := "0"
+		for i := 0; ; i++ {
+			reply := c.do(append([]string{"SCAN", cursor}, opts...)...)
+			fields := strings.Fields(strings.NewReplacer("[", " ", "]", " ").Replace(reply))
+			if len(fields) == 0 {
+				t.Fatalf("SCAN = %s", reply)
+			}
+			cursor, _ = strconv.Unquote(fields[0])
+			for _, key := range fields[1:] {
+				key, _ = strconv.Unquote(key)
+				keys = append(keys, key)
+			}
+			if cursor == "0" {
+				return keys
+			}
+			if i > 100 {
+				t.Fatal("SCAN doesn't finish")
+			}
+		}
+	}
+
+	keys := scanAll()
+	if len(keys) != 26 || !sort.StringsAreSorted(keys) {
+		t.Fatalf("SCAN returned %d keys: %v", len(keys), keys)
+	}
+	if keys := scanAll("MATCH", "user:1*", "COUNT", "3"); fmt.Sprint(keys) != "[user:10 user:11 user:12 user:13 user:14 user:15 user:16 user:17 user:18 user:19]" {
+		t.Fatalf("SCAN MATCH user:1* = %v", keys)
+	}
+	if keys := scanAll("MATCH", "*er"); fmt.Sprint(keys) != "[other]" {
+		t.Fatalf("SCAN MATCH *er = %v", keys)
+	}
+	if keys := scanAll("MATCH", "*", "COUNT", "100"); len(keys) != 26 {
+		t.Fatalf("SCAN MATCH * returned %d keys", len(keys))
+	}
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SCAN"}, "ERR wrong number of arguments for 'scan' command"},
+		{[]string{"SCAN", "x"}, "ERR invalid cursor"},
+		{[]string{"SCAN", "99999"}, "ERR invalid cursor"},
+		{[]string{"SCAN", "0", "COUNT"}, "ERR syntax error"},
+		{[]string{"SCAN", "0", "COUNT", "0"}, "ERR value is not an integer or out of range"},
+		{[]string{"SCAN", "0", "TYPE", "string"}, "ERR syntax error"},
+		{[]string{"SCAN", "0", "MATCH", "a[b"}, "ERR invalid pattern"},
+	} {
+		if got := c.do(test.args...); got != test.want {
+			t.Errorf("%q = %s, want %s", test.args, got, test.want)
+		}
+	}
+
+	// Cursors belong to the database they were handed out for.
+	reply := c.do("SCAN", "0", "COUNT", "1")
+	cursor, _ := strconv.Unquote(strings.Fields(reply[1:])[0])
+	c.do("SELECT", "1")
+	if got := c.do("SCAN", cursor); got != "ERR invalid cursor" {
+		t.Fatalf("SCAN with a cursor of another database = %s", got)
+	}
+}
+
+func TestWithPassword(t *testing.T) {
+	_, err := resp.New(resp.WithPassword(""))
+	if err == nil {
+		t.Fatal("New with an empty password succeeded")
+	}
+	_, err = resp.New(resp.WithTLS(nil))
+	if err == nil {
+		t.Fatal("New with a nil TLS config succeeded")
+	}
+
+	c := serve(t, newServer(t, testutil.NewTempClient(t), resp.WithPassword("secret")))
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"GET", "a"}, "NOAUTH Authentication required."},
+		{[]string{"PING"}, "NOAUTH Authentication required."},
+		{[]string{"AUTH", "wrong"}, "WRONGPASS invalid username-password pair"},
+		{[]string{"AUTH"}, "ERR wrong number of arguments for 'auth' command"},
+		{[]string{"AUTH", "default", "secret"}, "OK"},
+		{[]string{"GET", "a"}, "nil"},
+	} {
+		if got := c.do(test.args...); got != test.want {
+			t.Errorf("%q = %s, want %s", test.args, got, test.want)
+		}
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	c := serve(t, newServer(t, testutil.NewTempClient(t)))
+
+	// Malformed requests close the connection after an error.
+	_, err := c.Write([]byte("*-1\r\n"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := c.reply(); !strings.HasPrefix(got, "ERR protocol error") {
+		t.Fatalf("reply to a malformed request = %s", got)
+	}
+	_, err = c.r.ReadByte()
+	if err == nil {
+		t.Fatal("connection is open after a protocol error")
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := newServer(t, testutil.NewTempClient(t))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	c := dial(t, l.Addr().String())
+	if got := c.do("PING"); got != "PONG" {
+		t.Fatalf("PING = %s", got)
+	}
+
+	// Close closes the connections and stops Serve.
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = <-done
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Serve returned %v, want ErrClosed", err)
+	}
+	_, err = c.r.ReadByte()
+	if err == nil {
+		t.Fatal("connection is open after Close")
+	}
+	err = s.Serve(l)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Serve after Close returned %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,96 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Restore materializes a stream written by Backup into a new environment in
+// the directory path, creating the directory if needed, so it can be opened
+// with New. To never clobber a live environment, it refuses to restore into a
+// directory that already holds one. The stream is written to a temporary file
+// and checked to be an LMDB data file before it is moved into place, so a
+// truncated or corrupt stream leaves no environment behind.
+func Restore(path string, r io.Reader) (err error) {
+	dataPath := filepath.Join(path, dataFileName)
+	_, err = os.Stat(dataPath)
+	if err == nil {
+		return fmt.Errorf("failed to restore: %s already holds an environment", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check for an environment: %w", err)
+	}
+
+	err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create db directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(path, dataFileName+".restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create data file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync data file: %w", err)
+	}
+
+	df := &dataFile{f: f}
+	err = df.readMeta()
+	if err != nil {
+		return fmt.Errorf("backup is not an LMDB data file: %w", err)
+	}
+	err = df.checkSize(n)
+	if err != nil {
+		return fmt.Errorf("backup is not a complete LMDB data file: %w", err)
+	}
+
+	err = f.Chmod(mode)
+	if err != nil {
+		return fmt.Errorf("failed to set data file mode: %w", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close data file: %w", err)
+	}
+
+	// Link fails if an environment was created in the meantime, unlike
+	// rename, which would replace it.
+	err = os.Link(f.Name(), dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to move data file into place: %w", err)
+	}
+	os.Remove(f.Name())
+
+	return syncDir(path)
+}
+
+// syncDir makes the entries of the directory dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestRestoreRejectsBrokenStreams(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 1000)
+	var buf bytes.Buffer
+	err = db.Backup(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	for name, stream := range map[string][]byte{
+		"empty":     nil,
+		"garbage":   bytes.Repeat([]byte("garbage!"), 4096),
+		"truncated": buf.Bytes()[:buf.Len()/2],
+	} {
+		dir := t.TempDir()
+		err = ezdb.Restore(dir, bytes.NewReader(stream))
+		if err == nil {
+			t.Errorf("Restore of a %s stream succeeded", name)
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 0 {
+			t.Errorf("Restore of a %s stream left %v behind: %v", name, entries, err)
+		}
+	}
+
+	failing := io.MultiReader(bytes.NewReader(buf.Bytes()[:8192]), errReader{})
+	err = ezdb.Restore(t.TempDir(), failing)
+	if !errors.Is(err, errRead) {
+		t.Fatalf("Restore of a failing reader returned %v", err)
+	}
+}
+
+var errRead = errors.New("read failed")
+
+// errReader fails every read with errRead.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errRead
+}
+
+func TestRestoreKeepsExistingEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+	var buf bytes.Buffer
+	err = db.Backup(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	err = ezdb.Restore(dir, &buf)
+	if err == nil {
+		t.Fatal("Restore into an existing environment succeeded")
+	}
+	wantN(t, ref, 10)
+}
@@ -0,0 +1,318 @@
+// Package s3backup stores ezdb backups and snapshots in S3 or any
+// S3-compatible object store, such as MinIO.
+//
+// Backups are streamed with multipart uploads, so they never have to fit in
+// memory, and every part is verified against its MD5 checksum, as is the
+// completed object.
+package s3backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// minPartSize is the smallest part S3 accepts, except for the last one.
+const minPartSize = 5 << 20
+
+// Config configures a Sink.
+type Config struct {
+	// Endpoint is the base URL of the object store, such as
+	// "https://s3.eu-west-1.amazonaws.com". Objects are addressed path-style,
+	// as Endpoint/Bucket/Key.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to the names of backups to make their keys.
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials, if any.
+	SessionToken string
+
+	// PartSize is the size of the parts backups are uploaded in. It must be
+	// at least 5 MiB, which is the default.
+	PartSize int
+	// HTTPClient sends the requests. The default is http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Sink is an ezdb.BackupSink storing backups as objects.
+type Sink struct {
+	cfg      Config
+	endpoint *url.URL
+}
+
+var _ ezdb.BackupSink = (*Sink)(nil)
+
+// New returns a Sink storing backups as configured by cfg.
+func New(cfg Config) (*Sink, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("bucket and region must be set")
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = minPartSize
+	}
+	if cfg.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size must be at least %d bytes", minPartSize)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &Sink{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// do sends a signed request for the object key, or for the bucket if key is
+// empty, and returns the response body. It fails unless the status is 2xx.
+func (s *Sink) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (http.Header, []byte, error) {
+	path := strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.cfg.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u := *s.endpoint
+	u.Path = path
+	u.RawPath = escapePath(path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, vals := range header {
+		req.Header[name] = vals
+	}
+	payloadHash := emptySHA256
+	if len(body) > 0 {
+		payloadHash = hexSHA256(body)
+	}
+	s.sign(req, payloadHash, time.Now())
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, fmt.Errorf("%s %s: %s: %s", method, u.Path, resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	return resp.Header, respBody, nil
+}
+
+// Create starts a multipart upload of the backup named name.
+func (s *Sink) Create(ctx context.Context, name string) (ezdb.BackupUpload, error) {
+	key := s.cfg.Prefix + name
+	_, body, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.Unmarshal(body, &result)
+	if err != nil || result.UploadID == "" {
+		return nil, fmt.Errorf("invalid response to create multipart upload: %s", body)
+	}
+
+	return &upload{
+		ctx:      ctx,
+		sink:     s,
+		key:      key,
+		uploadID: result.UploadID,
+		buf:      make([]byte, 0, s.cfg.PartSize),
+	}, nil
+}
+
+// List returns the names of the backups starting with prefix.
+func (s *Sink) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix + prefix}}
+	for {
+		_, body, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.Unmarshal(body, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object list: %w", err)
+		}
+		for _, obj := range result.Contents {
+			names = append(names, strings.TrimPrefix(obj.Key, s.cfg.Prefix))
+		}
+		if !result.IsTruncated {
+			return names, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// Delete deletes the backup named name.
+func (s *Sink) Delete(ctx context.Context, name string) error {
+	_, _, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+name, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// upload is a multipart upload in progress. Writes are buffered into parts of
+// the configured size, and each full part is uploaded before Write returns.
+type upload struct {
+	ctx      context.Context
+	sink     *Sink
+	key      string
+	uploadID string
+
+	buf   []byte
+	parts []completedPart
+	// sums are the MD5 checksums of the uploaded parts, which the ETag of
+	// the completed object is derived from.
+	sums []byte
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (u *upload) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		free := cap(u.buf) - len(u.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		u.buf = append(u.buf, p[:free]...)
+		p = p[free:]
+		n += free
+
+		if len(u.buf) == cap(u.buf) {
+			err := u.uploadPart()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// uploadPart uploads the buffered bytes as the next part and checks that the
+// store received them intact.
+func (u *upload) uploadPart() error {
+	sum := md5.Sum(u.buf)
+	number := len(u.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	header := http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}
+
+	respHeader, _, err := u.sink.do(u.ctx, http.MethodPut, u.key, query, u.buf, header)
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+	etag := respHeader.Get("ETag")
+	if strings.Trim(etag, `"`) != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("checksum mismatch for part %d: got ETag %s", number, etag)
+	}
+
+	u.parts = append(u.parts, completedPart{PartNumber: number, ETag: etag})
+	u.sums = append(u.sums, sum[:]...)
+	u.buf = u.buf[:0]
+	return nil
+}
+
+// Commit uploads the last part and completes the upload, then checks the
+// ETag of the object against the checksums of the parts.
+func (u *upload) Commit() error {
+	// The last part may be smaller than the minimum, or even empty if no
+	// part has been uploaded yet.
+	if len(u.buf) > 0 || len(u.parts) == 0 {
+		err := u.uploadPart()
+		if err != nil {
+			return errors.Join(err, u.Abort())
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	_, respBody, err := u.sink.do(u.ctx, http.MethodPost, u.key, url.Values{"uploadId": {u.uploadID}}, body, nil)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to complete multipart upload: %w", err), u.Abort())
+	}
+
+	// Completion can fail after a 200 response, with the error in the body.
+	var result struct {
+		XMLName xml.Name
+		ETag    string
+		Message string
+	}
+	err = xml.Unmarshal(respBody, &result)
+	if err != nil {
+		return fmt.Errorf("failed to parse completion response: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return errors.Join(fmt.Errorf("failed to complete multipart upload: %s", result.Message), u.Abort())
+	}
+
+	sum := md5.Sum(u.sums)
+	want := fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(u.parts))
+	if strings.Trim(result.ETag, `"`) != want {
+		err = fmt.Errorf("checksum mismatch for %s: got ETag %s, want %s", u.key, result.ETag, want)
+		return errors.Join(err, u.sink.Delete(u.ctx, strings.TrimPrefix(u.key, u.sink.cfg.Prefix)))
+	}
+
+	return nil
+}
+
+// Abort aborts the upload, deleting the parts uploaded so far.
+func (u *upload) Abort() error {
+	// Use a fresh context, so that the parts are cleaned up even if the
+	// upload failed because its context was canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, _, err := u.sink.do(ctx, http.MethodDelete, u.key, url.Values{"uploadId": {u.uploadID}}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	return nil
+}
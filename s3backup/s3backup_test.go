@@ -0,0 +1,434 @@
+package s3backup_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/s3backup"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// fakeS3 is an object store serving the requests Sink sends for the bucket
+// "bucket" under the path "/s3". Its fields after mu make it misbehave.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+
+	// pageSize is the number of keys per page of a listing.
+	pageSize int
+	// corruptParts makes the ETags of parts wrong.
+	corruptParts bool
+	// failCompletion reports an error in the body of a 200 response to
+	// completing an upload.
+	failCompletion bool
+	// corruptObjects makes the ETags of completed objects wrong.
+	corruptObjects bool
+	// unavailable fails every request.
+	unavailable bool
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3backup.Sink) {
+	t.Helper()
+
+	f := &fakeS3{
+		objects:  make(map[string][]byte),
+		uploads:  make(map[string]map[int][]byte),
+		pageSize: 1000,
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	sink, err := s3backup.New(s3backup.Config{
+		Endpoint:        srv.URL + "/s3",
+		Region:          "us-east-1",
+		Bucket:          "bucket",
+		Prefix:          "backups/",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return f, sink
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.unavailable {
+		http.Error(w, "slow down", http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/s3/bucket")
+	if !ok {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		md5Sum := md5.Sum(body)
+		if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(md5Sum[:]) {
+			http.Error(w, "bad digest", http.StatusBadRequest)
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		if f.corruptParts {
+			md5Sum[0]++
+		}
+		w.Header().Set("ETag", `"`+hex.EncodeToString(md5Sum[:])+`"`)
+
+	case r.Method == http.MethodPost && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		if f.failCompletion {
+			fmt.Fprint(w, "<Error><Code>InternalError</Code><Message>try again</Message></Error>")
+			return
+		}
+		var complete struct {
+			Parts []struct{ PartNumber int } `xml:"Part"`
+		}
+		err = xml.Unmarshal(body, &complete)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var object, sums []byte
+		for _, part := range complete.Parts {
+			object = append(object, parts[part.PartNumber]...)
+			md5Sum := md5.Sum(parts[part.PartNumber])
+			sums = append(sums, md5Sum[:]...)
+		}
+		delete(f.uploads, uploadID)
+		f.objects[key] = object
+		md5Sum := md5.Sum(sums)
+		if f.corruptObjects {
+			md5Sum[0]++
+		}
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><ETag>"%s-%d"</ETag></CompleteMultipartUploadResult>`,
+			hex.EncodeToString(md5Sum[:]), len(complete.Parts))
+
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		var keys []string
+		for key := range f.objects {
+			if strings.HasPrefix(key, query.Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		start, _ := strconv.Atoi(query.Get("continuation-token"))
+		end := start + f.pageSize
+		truncated := end < len(keys)
+		if !truncated {
+			end = len(keys)
+		}
+		fmt.Fprint(w, "<ListBucketResult>")
+		for _, key := range keys[start:end] {
+			fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+		}
+		fmt.Fprintf(w, "<IsTruncated>%t</IsTruncated><NextContinuationToken>%d</NextContinuationToken></ListBucketResult>", truncated, end)
+
+	default:
+		http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+	}
+}
+
+// object returns the stored object key and whether it exists.
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	object, ok := f.objects[key]
+	return object, ok
+}
+
+// pendingUploads returns the number of uploads neither completed nor
+// aborted.
+func (f *fakeS3) pendingUploads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.uploads)
+}
+
+func TestBackupTo(t *testing.T) {
+	f, sink := newFakeS3(t)
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	err = db.BackupTo(context.Background(), sink, "backup")
+	if err != nil {
+		t.Fatalf("BackupTo: %v", err)
+	}
+	object, ok := f.object("backups/backup")
+	if !ok {
+		t.Fatal("backup wasn't stored")
+	}
+
+	dir := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(dir, bytes.NewReader(object))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := ezdb.New(dir, ezdb.WithNumDBs(16))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = restored.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer restored.Close()
+	ref, err = ezdb.NewRef[string, string]("ref", restored)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != val {
+		t.Fatalf("Get from the restored backup = %v, %v", got, err)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	f, sink := newFakeS3(t)
+	// Two full parts of 5 MiB and a short last one.
+	data := bytes.Repeat([]byte("0123456789abcdef"), (11<<20)/16)
+
+	upload, err := sink.Create(context.Background(), "large")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// Writes don't line up with the parts.
+	for rest := data; len(rest) > 0; {
+		n := 3 << 19
+		if n > len(rest) {
+			n = len(rest)
+		}
+		_, err = upload.Write(rest[:n])
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	err = upload.Commit()
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	object, _ := f.object("backups/large")
+	if !bytes.Equal(object, data) {
+		t.Fatalf("stored %d bytes, want %d", len(object), len(data))
+	}
+
+	// An empty backup is uploaded as a single empty part.
+	upload, err = sink.Create(context.Background(), "empty")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	err = upload.Commit()
+	if err != nil {
+		t.Fatalf("Commit of an empty upload: %v", err)
+	}
+	if object, ok := f.object("backups/empty"); !ok || len(object) != 0 {
+		t.Fatalf("empty backup = %q, %t", object, ok)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	f, sink := newFakeS3(t)
+	f.pageSize = 2
+	f.objects["other"] = nil
+	for _, name := range []string{"snapshot-3", "snapshot-1", "backup", "snapshot-2", "snapshot-4"} {
+		f.objects["backups/"+name] = nil
+	}
+
+	names, err := sink.List(context.Background(), "snapshot-")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got := strings.Join(names, " "); got != "snapshot-1 snapshot-2 snapshot-3 snapshot-4" {
+		t.Fatalf("List = %s", got)
+	}
+
+	err = sink.Delete(context.Background(), "snapshot-1")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	names, err = sink.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got := strings.Join(names, " "); got != "backup snapshot-2 snapshot-3 snapshot-4" {
+		t.Fatalf("List after Delete = %s", got)
+	}
+}
+
+func TestUploadFailures(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 6<<20)
+
+	t.Run("part checksum", func(t *testing.T) {
+		f, sink := newFakeS3(t)
+		f.corruptParts = true
+		upload, err := sink.Create(context.Background(), "backup")
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		_, err = upload.Write(data)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("Write of a corrupted part returned %v", err)
+		}
+		err = upload.Abort()
+		if err != nil {
+			t.Fatalf("Abort: %v", err)
+		}
+		if n := f.pendingUploads(); n != 0 {
+			t.Fatalf("%d uploads left after Abort", n)
+		}
+	})
+
+	t.Run("completion error", func(t *testing.T) {
+		f, sink := newFakeS3(t)
+		f.failCompletion = true
+		upload, err := sink.Create(context.Background(), "backup")
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		_, err = upload.Write(data)
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		err = upload.Commit()
+		if err == nil || !strings.Contains(err.Error(), "try again") {
+			t.Fatalf("Commit returned %v, want the error of the response", err)
+		}
+		if n := f.pendingUploads(); n != 0 {
+			t.Fatalf("%d uploads left after a failed Commit", n)
+		}
+	})
+
+	t.Run("object checksum", func(t *testing.T) {
+		f, sink := newFakeS3(t)
+		f.corruptObjects = true
+		upload, err := sink.Create(context.Background(), "backup")
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		_, err = upload.Write(data)
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		err = upload.Commit()
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("Commit of a corrupted object returned %v", err)
+		}
+		if _, ok := f.object("backups/backup"); ok {
+			t.Fatal("corrupted object wasn't deleted")
+		}
+	})
+
+	t.Run("canceled backup", func(t *testing.T) {
+		f, sink := newFakeS3(t)
+		db := testutil.NewTempClient(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// Creating the upload fails, as it uses ctx too.
+		err := db.BackupTo(ctx, sink, "backup")
+		if err == nil {
+			t.Fatal("BackupTo with a canceled context succeeded")
+		}
+		if _, ok := f.object("backups/backup"); ok || f.pendingUploads() != 0 {
+			t.Fatal("canceled backup left an object or upload behind")
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		f, sink := newFakeS3(t)
+		f.unavailable = true
+		_, err := sink.Create(context.Background(), "backup")
+		if err == nil || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("Create returned %v, want the status of the response", err)
+		}
+		_, err = sink.List(context.Background(), "")
+		if err == nil {
+			t.Fatal("List succeeded")
+		}
+	})
+}
+
+func TestNewRejectsInvalidConfigs(t *testing.T) {
+	for name, cfg := range map[string]s3backup.Config{
+		"endpoint":  {Endpoint: "://", Region: "us-east-1", Bucket: "bucket"},
+		"bucket":    {Endpoint: "http://localhost", Region: "us-east-1"},
+		"region":    {Endpoint: "http://localhost", Bucket: "bucket"},
+		"part size": {Endpoint: "http://localhost", Region: "us-east-1", Bucket: "bucket", PartSize: 1 << 20},
+	} {
+		_, err := s3backup.New(cfg)
+		if err == nil {
+			t.Errorf("New with an invalid %s succeeded", name)
+		}
+	}
+}
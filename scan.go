@@ -0,0 +1,97 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ForEach calls fn with every entry of ref, in the order of the encoded keys.
+// The scan runs in a single read transaction and stops at the first error fn
+// returns, which ForEach returns.
+func (ref *DBRef[K, V]) ForEach(fn func(key *K, val *V) error) error {
+	return ref.ownerDB.view(func(txn readTxn) error {
+		return ref.scanInTxn(txn, fn)
+	})
+}
+
+// scanInTxn decodes every entry of ref in order and calls fn with it.
+func (ref *DBRef[K, V]) scanInTxn(txn readTxn, fn func(key *K, val *V) error) error {
+	dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	now := time.Now()
+	var keyBytes, valBytes []byte
+	if len(ref.prefix) == 0 {
+		keyBytes, valBytes, err = cursor.First()
+	} else {
+		keyBytes, valBytes, err = cursor.SeekGreaterThanOrEqualKey(ref.prefix)
+	}
+	for ; err == nil && bytes.HasPrefix(keyBytes, ref.prefix); keyBytes, valBytes, err = cursor.Next() {
+		if err := ref.ctxErr(); err != nil {
+			return err
+		}
+		if ref.options.ttl != nil {
+			expired, err := ref.expiredInTxn(txn, keyBytes, now)
+			if err != nil {
+				return err
+			}
+			if expired {
+				continue
+			}
+		}
+
+		key, err := ref.decodeKey(keyBytes)
+		if err != nil {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+		val, err := ref.decodeValInTxn(txn, valBytes)
+		if err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+
+		err = fn(key, val)
+		if err != nil {
+			return err
+		}
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return nil
+}
+
+// Sub returns a DBRef for the namespace prefix of ref: it shares ref's named
+// database and options, but transparently prefixes every key with an encoding
+// of prefix, so namespaces, e.g. one per tenant, don't see each other's keys
+// and don't use up named databases. Scans and queries of the returned DBRef
+// only visit its namespace, but scans of ref visit every namespace and fail
+// to decode their keys, so a database with namespaces should only be accessed
+// through them. Namespaces can be nested.
+//
+// The indexes of ref, as of the call, are kept up to date by the writes of
+// the namespace. They map indexed values to the keys of every namespace, so
+// WithUnique holds across namespaces; use Index.Sub to look them up within
+// one. WithMaxEntries and WithTTL apply to the named database as a whole, so
+// Sub should not be used on DBRefs that have them. Drop on a namespace only
+// deletes its own entries.
+func (ref *DBRef[K, V]) Sub(prefix string) *DBRef[K, V] {
+	return &DBRef[K, V]{
+		id:      ref.id,
+		ownerDB: ref.ownerDB,
+		options: ref.options,
+		indexes: ref.indexes,
+		prefix:  appendEscaped(bytes.Clone(ref.prefix), []byte(prefix)),
+		ctx:     ref.ctx,
+	}
+}
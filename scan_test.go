@@ -0,0 +1,169 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// subKeys returns the keys and values ForEach visits in ref.
+func subKeys(t *testing.T, ref *ezdb.DBRef[string, string]) string {
+	t.Helper()
+
+	var entries []string
+	err := ref.ForEach(func(key, val *string) error {
+		entries = append(entries, *key+"="+*val)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	return fmt.Sprint(entries)
+}
+
+func TestSub(t *testing.T) {
+	ref, err := ezdb.NewRef[string, string]("ref", testutil.NewTempClient(t))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	// "a" is a prefix of "ab", but their namespaces don't overlap.
+	a, ab, nested := ref.Sub("a"), ref.Sub("ab"), ref.Sub("c").Sub("a")
+	for _, test := range []struct {
+		ref  *ezdb.DBRef[string, string]
+		vals []string
+	}{
+		{a, []string{"1", "2"}},
+		{ab, []string{"3"}},
+		{nested, []string{"4"}},
+	} {
+		for i, val := range test.vals {
+			key := fmt.Sprintf("k%d", i)
+			err = test.ref.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+	}
+
+	key := "k0"
+	for _, test := range []struct {
+		ref  *ezdb.DBRef[string, string]
+		want string
+	}{
+		{a, "1"},
+		{ab, "3"},
+		{nested, "4"},
+	} {
+		got, err := test.ref.Get(&key)
+		if err != nil || *got != test.want {
+			t.Fatalf("Get = %v, %v, want %s", got, err, test.want)
+		}
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get outside of the namespaces returned %v, want ErrNotFound", err)
+	}
+	if got := subKeys(t, a); got != "[k0=1 k1=2]" {
+		t.Fatalf("entries of a = %s", got)
+	}
+	if got := subKeys(t, ab); got != "[k0=3]" {
+		t.Fatalf("entries of ab = %s", got)
+	}
+	if got := subKeys(t, nested); got != "[k0=4]" {
+		t.Fatalf("entries of c/a = %s", got)
+	}
+
+	// Deletes and Drop only touch the namespace.
+	err = ab.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := subKeys(t, a); got != "[k0=1 k1=2]" {
+		t.Fatalf("entries of a after a Delete in ab = %s", got)
+	}
+	err = a.Drop()
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	if got := subKeys(t, ref.Sub("a")); got != "[]" {
+		t.Fatalf("entries of a after Drop = %s", got)
+	}
+	if got := subKeys(t, nested); got != "[k0=4]" {
+		t.Fatalf("entries of c/a after dropping a = %s", got)
+	}
+
+	// Scans of the whole database see the namespaces' prefixes.
+	err = ref.ForEach(func(*string, *string) error { return nil })
+	if err == nil {
+		t.Fatal("ForEach over namespaces succeeded")
+	}
+}
+
+func TestSubIndex(t *testing.T) {
+	ref, err := ezdb.NewRef[string, string]("ref", testutil.NewTempClient(t))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	idx, err := ezdb.NewIndex(ref, "val", func(v *string) string { return *v }, ezdb.WithUnique())
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	sub, subIdx := ref.Sub("t"), idx.Sub("t")
+
+	key, other, val := "k", "other", "v"
+	err = sub.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	keys, err := subIdx.Keys(&val)
+	if err != nil || fmt.Sprint(keys) != "[k]" {
+		t.Fatalf("Keys = %v, %v, want [k]", keys, err)
+	}
+	gotKey, gotVal, err := subIdx.Get(&val)
+	if err != nil || *gotKey != key || *gotVal != val {
+		t.Fatalf("Get = %v, %v, %v", gotKey, gotVal, err)
+	}
+	var ranged []string
+	err = subIdx.Range(nil, nil, func(key, _ *string) error {
+		ranged = append(ranged, *key)
+		return nil
+	})
+	if err != nil || fmt.Sprint(ranged) != "[k]" {
+		t.Fatalf("Range = %v, %v, want [k]", ranged, err)
+	}
+
+	// Other namespaces don't see the entry, but WithUnique spans them.
+	keys, err = idx.Sub("u").Keys(&val)
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("Keys of another namespace = %v, %v, want none", keys, err)
+	}
+	_, _, err = idx.Sub("u").Get(&val)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of another namespace returned %v, want ErrNotFound", err)
+	}
+	err = ref.Put(&other, &val)
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("Put of a duplicate outside of the namespace returned %v, want ErrDuplicate", err)
+	}
+
+	// Deletes through the namespace clear its index entries.
+	err = sub.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	keys, err = subIdx.Keys(&val)
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("Keys after Delete = %v, %v, want none", keys, err)
+	}
+	err = ref.Put(&other, &val)
+	if err != nil {
+		t.Fatalf("Put after the namespace's Delete: %v", err)
+	}
+	keys, err = idx.Keys(&val)
+	if err != nil || fmt.Sprint(keys) != "[other]" {
+		t.Fatalf("Keys = %v, %v, want [other]", keys, err)
+	}
+}
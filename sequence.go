@@ -0,0 +1,87 @@
+package ezdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// sequencesDB is the named database all sequences of a Client are stored in.
+const sequencesDB = "ezdb.sequences"
+
+// Sequence is a persistent monotonic counter, e.g. for generating primary
+// keys or log offsets. Values are handed out in write transactions, so they
+// are never handed out twice, even across processes, but values reserved by a
+// transaction that ends up failing are skipped.
+type Sequence struct {
+	name    string
+	ownerDB *Client
+}
+
+// Sequence opens the named sequence of db, creating it if it doesn't exist.
+// All sequences share one named database, which counts towards WithNumDBs.
+// The first value a new sequence hands out is 1.
+func (db *Client) Sequence(name string) (*Sequence, error) {
+	err := db.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	err = db.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(sequencesDB, dbCreate)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sequence %q: %w", name, err)
+	}
+
+	return &Sequence{name: name, ownerDB: db}, nil
+}
+
+// Next returns the next value of the sequence.
+func (seq *Sequence) Next() (uint64, error) {
+	return seq.NextN(1)
+}
+
+// NextN reserves the next n values of the sequence in one transaction and
+// returns the first of them; the caller owns [first, first+n).
+func (seq *Sequence) NextN(n uint64) (first uint64, err error) {
+	if n == 0 {
+		return 0, errors.New("cannot reserve zero values")
+	}
+
+	err = seq.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(sequencesDB, dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		var last uint64
+		valBytes, err := txn.Get(dbRef, []byte(seq.name))
+		switch {
+		case err == nil && len(valBytes) == 8:
+			last = binary.BigEndian.Uint64(valBytes)
+		case err == nil:
+			return fmt.Errorf("corrupt sequence %q", seq.name)
+		case !errors.Is(err, ErrNotFound):
+			return fmt.Errorf("failed to read sequence %q: %w", seq.name, err)
+		}
+
+		if last+n < last {
+			return fmt.Errorf("sequence %q overflows", seq.name)
+		}
+		first = last + 1
+
+		err = txn.Put(dbRef, []byte(seq.name), binary.BigEndian.AppendUint64(nil, last+n), putFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to write sequence %q: %w", seq.name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return first, nil
+}
@@ -0,0 +1,108 @@
+package ezdb_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSequence(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	seq, err := db.Sequence("ids")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+
+	first, err := seq.Next()
+	if err != nil || first != 1 {
+		t.Fatalf("first Next = %d, %v, want 1", first, err)
+	}
+	first, err = seq.NextN(10)
+	if err != nil || first != 2 {
+		t.Fatalf("NextN(10) = %d, %v, want 2", first, err)
+	}
+	_, err = seq.NextN(0)
+	if err == nil {
+		t.Fatal("NextN(0) succeeded")
+	}
+
+	// Sequences are independent of each other.
+	other, err := db.Sequence("other")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+	first, err = other.Next()
+	if err != nil || first != 1 {
+		t.Fatalf("Next of another sequence = %d, %v, want 1", first, err)
+	}
+
+	// And they persist.
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	db = openClient(t, dir)
+	seq, err = db.Sequence("ids")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+	first, err = seq.Next()
+	if err != nil || first != 12 {
+		t.Fatalf("Next after reopening = %d, %v, want 12", first, err)
+	}
+}
+
+func TestSequenceConcurrentNext(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	seq, err := db.Sequence("ids")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+
+	const workers, each = 8, 50
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				v, err := seq.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[v] {
+					t.Errorf("value %d handed out twice", v)
+				}
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*each {
+		t.Fatalf("handed out %d values, want %d", len(seen), workers*each)
+	}
+}
+
+func TestSequenceOverflow(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	seq, err := db.Sequence("ids")
+	if err != nil {
+		t.Fatalf("Sequence: %v", err)
+	}
+
+	_, err = seq.NextN(^uint64(0))
+	if err != nil {
+		t.Fatalf("NextN of every value: %v", err)
+	}
+	_, err = seq.Next()
+	if err == nil {
+		t.Fatal("Next of an exhausted sequence succeeded")
+	}
+}
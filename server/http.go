@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBodySize bounds the request bodies the handler reads.
+const maxBodySize = 32 << 20
+
+// Handler returns an http.Handler exposing the registered DBRefs as REST
+// resources, with keys and values as JSON like the gRPC service:
+//
+//	GET    /{dbref}/{key}                 the value, or 404 Not Found
+//	PUT    /{dbref}/{key}                 stores the JSON value in the body
+//	DELETE /{dbref}/{key}                 deletes the key, if it exists
+//	GET    /{dbref}?prefix={p}&after={key}&limit={n}
+//	                                      the entries, as for Scan
+//
+// The key, like the after parameter, is the escaped string for DBRefs with
+// string keys, and the escaped JSON of the key otherwise. Errors are reported
+// with the status matching the gRPC status code of the error, such as 400 Bad
+// Request for a malformed key. Mount the handler under a prefix with
+// http.StripPrefix. Requests are authorized with the function given with
+// WithAuth, if any.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/")
+	name, escapedKey, hasKey := strings.Cut(path, "/")
+	name, err := url.PathUnescape(name)
+	if err != nil || name == "" {
+		httpError(w, http.StatusNotFound, "not found")
+		return
+	}
+	rt, err := s.route(name)
+	if err != nil {
+		httpError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	write := r.Method == http.MethodPut || r.Method == http.MethodDelete
+	if s.options.auth != nil {
+		err = s.options.auth(r, name, write)
+		if err != nil {
+			httpError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	if !hasKey {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			httpError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.serveScan(w, r, rt)
+		return
+	}
+
+	key, err := url.PathUnescape(escapedKey)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "invalid key")
+		return
+	}
+	keyJSON := json.RawMessage(key)
+	if rt.stringKey {
+		keyJSON, _ = json.Marshal(key)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		val, found, err := rt.get(r.Context(), keyJSON)
+		if err != nil {
+			httpError(w, httpStatusOf(err), err.Error())
+			return
+		}
+		if !found {
+			httpError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeJSON(w, val)
+
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			httpError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		err = rt.put(r.Context(), nil, keyJSON, body)
+		if err != nil {
+			httpError(w, httpStatusOf(err), err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		_, err = rt.del(r.Context(), nil, keyJSON)
+		if err != nil {
+			httpError(w, httpStatusOf(err), err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		httpError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveScan(w http.ResponseWriter, r *http.Request, rt route) {
+	limit := defaultScanLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			httpError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	if limit > maxScanLimit {
+		limit = maxScanLimit
+	}
+
+	var after json.RawMessage
+	if a := r.URL.Query().Get("after"); a != "" {
+		after = json.RawMessage(a)
+		if rt.stringKey {
+			after, _ = json.Marshal(a)
+		}
+	}
+
+	entries, err := rt.scan(r.Context(), r.URL.Query().Get("prefix"), after, limit)
+	if err != nil {
+		httpError(w, httpStatusOf(err), err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, data)
+}
+
+func writeJSON(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// httpStatusOf returns the HTTP status matching the gRPC status code of err.
+func httpStatusOf(err error) int {
+	switch status.Code(statusOf(err)) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusInsufficientStorage
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// httpError writes msg as a JSON error body with status.
+func httpError(w http.ResponseWriter, status int, msg string) {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s\n", data)
+}
@@ -0,0 +1,174 @@
+package server_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/server"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// newHTTP returns an HTTP server of a Server of db for the DBRefs "accounts",
+// with string keys, and "counts", with integer keys.
+func newHTTP(t *testing.T, db *ezdb.Client, opts ...server.Option) *httptest.Server {
+	t.Helper()
+
+	accounts, err := ezdb.NewDBRef[string, account](db, "accounts", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	counts, err := ezdb.NewDBRef[uint64, int](db, "counts", ezdb.WithKeyCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	s, err := server.New(db, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = server.Register(s, accounts)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = server.Register(s, counts)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(hs.Close)
+
+	return hs
+}
+
+// do sends a request with body, if not empty, and returns the status and
+// body of the response.
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	return resp.StatusCode, strings.TrimSpace(string(data))
+}
+
+func TestHandler(t *testing.T) {
+	hs := newHTTP(t, testutil.NewTempClient(t))
+
+	code, _ := do(t, http.MethodPut, hs.URL+"/accounts/ada%2F1", `{"owner":"Ada","balance":10}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("PUT = %d", code)
+	}
+	code, body := do(t, http.MethodGet, hs.URL+"/accounts/ada%2F1", "")
+	if code != http.StatusOK || body != `{"owner":"Ada","balance":10}` {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	code, _ = do(t, http.MethodPut, hs.URL+"/counts/7", `3`)
+	if code != http.StatusNoContent {
+		t.Fatalf("PUT of an integer key = %d", code)
+	}
+	code, body = do(t, http.MethodGet, hs.URL+"/counts/7", "")
+	if code != http.StatusOK || body != "3" {
+		t.Fatalf("GET of an integer key = %d %s", code, body)
+	}
+
+	code, body = do(t, http.MethodGet, hs.URL+"/accounts", "")
+	if code != http.StatusOK || body != `[{"key":"ada/1","value":{"owner":"Ada","balance":10}}]` {
+		t.Fatalf("GET of the DBRef = %d %s", code, body)
+	}
+	code, body = do(t, http.MethodGet, hs.URL+"/accounts?after=ada%2F1", "")
+	if code != http.StatusOK || body != "[]" {
+		t.Fatalf("GET after the last key = %d %s", code, body)
+	}
+
+	code, _ = do(t, http.MethodDelete, hs.URL+"/accounts/ada%2F1", "")
+	if code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", code)
+	}
+	code, _ = do(t, http.MethodDelete, hs.URL+"/accounts/ada%2F1", "")
+	if code != http.StatusNoContent {
+		t.Fatalf("DELETE of a missing key = %d", code)
+	}
+	code, body = do(t, http.MethodGet, hs.URL+"/accounts/ada%2F1", "")
+	if code != http.StatusNotFound || body != `{"error":"not found"}` {
+		t.Fatalf("GET of a deleted key = %d %s", code, body)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	hs := newHTTP(t, db)
+
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/", "", http.StatusNotFound},
+		{http.MethodGet, "/missing/a", "", http.StatusNotFound},
+		{http.MethodPost, "/accounts", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/accounts/a", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/counts/x", "", http.StatusBadRequest},
+		{http.MethodPut, "/accounts/a", "[]", http.StatusBadRequest},
+		{http.MethodPut, "/accounts/" + strings.Repeat("k", 600), "{}", http.StatusBadRequest},
+		{http.MethodGet, "/accounts?limit=0", "", http.StatusBadRequest},
+		{http.MethodGet, "/counts?after=x", "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		code, body := do(t, test.method, hs.URL+test.path, test.body)
+		if code != test.code || !strings.HasPrefix(body, `{"error":`) {
+			t.Errorf("%s %s = %d %s, want %d", test.method, test.path, code, body, test.code)
+		}
+	}
+
+	// Failures of the Client aren't the request's fault.
+	err := db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	code, _ := do(t, http.MethodGet, hs.URL+"/accounts/a", "")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("GET from a closed Client = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	var writes []bool
+	hs := newHTTP(t, testutil.NewTempClient(t), server.WithAuth(func(r *http.Request, dbRef string, write bool) error {
+		writes = append(writes, write)
+		if dbRef == "counts" || (write && r.Header.Get("Authorization") == "") {
+			return errors.New("access denied")
+		}
+		return nil
+	}))
+
+	code, body := do(t, http.MethodPut, hs.URL+"/accounts/a", "{}")
+	if code != http.StatusForbidden || body != `{"error":"access denied"}` {
+		t.Fatalf("unauthorized PUT = %d %s", code, body)
+	}
+	code, _ = do(t, http.MethodGet, hs.URL+"/accounts", "")
+	if code != http.StatusOK {
+		t.Fatalf("authorized GET = %d", code)
+	}
+	code, _ = do(t, http.MethodGet, hs.URL+"/counts/1", "")
+	if code != http.StatusForbidden {
+		t.Fatalf("GET of a forbidden DBRef = %d", code)
+	}
+	if len(writes) != 3 || !writes[0] || writes[1] || writes[2] {
+		t.Fatalf("auth saw writes %v", writes)
+	}
+}
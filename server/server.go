@@ -0,0 +1,464 @@
+// Package server exposes the DBRefs of an ezdb Client as a key-value service,
+// so processes in other languages and on other hosts can use it.
+//
+// The service is the gRPC service KV of package ezdbpb, defined in
+// ezdbpb/kv.proto, optionally with TLS, so clients for most languages can be
+// generated from the .proto file. Requests name the DBRef they address, and
+// keys and values are JSON, whatever codec the DBRef stores them with. The
+// methods are Get, Put, Delete, Scan and Txn; Txn applies puts and deletes to
+// any number of DBRefs atomically. Deleting a missing key is not an error;
+// Delete reports whether the key existed. Errors are reported with the gRPC
+// status codes matching them, such as NotFound for an unknown DBRef and
+// InvalidArgument for a malformed key, and those matching an ezdb error also
+// with a google.rpc.ErrorInfo detail naming it, see ezdbpb.Error.
+//
+// Server.Handler exposes the same DBRefs as REST resources over HTTP, for
+// clients that only speak HTTP, and for poking at the data with curl.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/ezdbpb"
+)
+
+const (
+	// defaultScanLimit and maxScanLimit bound the entries returned by Scan.
+	defaultScanLimit = 100
+	maxScanLimit     = 10000
+)
+
+type Option func(option *options) error
+
+type options struct {
+	tls  *tls.Config
+	auth func(r *http.Request, dbRef string, write bool) error
+}
+
+// WithTLS makes the Server accept only TLS connections, configured by config,
+// which must have a certificate. Set config.ClientAuth to require client
+// certificates.
+func WithTLS(config *tls.Config) Option {
+	return func(option *options) error {
+		if config == nil {
+			return errors.New("TLS config must not be nil")
+		}
+		option.tls = config
+		return nil
+	}
+}
+
+// WithAuth makes the handler returned by Server.Handler call auth before
+// every request, with the name of the DBRef it addresses and whether it
+// writes. If auth returns an error, the request is refused with 403
+// Forbidden and the error's message.
+func WithAuth(auth func(r *http.Request, dbRef string, write bool) error) Option {
+	return func(option *options) error {
+		option.auth = auth
+		return nil
+	}
+}
+
+// Server serves the DBRefs registered with Register.
+type Server struct {
+	db      *ezdb.Client
+	options *options
+	grpc    *grpc.Server
+
+	mu     sync.Mutex
+	routes map[string]route
+}
+
+var _ grpc.ServiceRegistrar = (*Server)(nil)
+
+// route serves the requests for one DBRef, with keys and values as JSON.
+type route struct {
+	// stringKey is set if the DBRef's keys are strings.
+	stringKey bool
+	get       func(ctx context.Context, key json.RawMessage) (val json.RawMessage, found bool, err error)
+	put       func(ctx context.Context, tx *ezdb.Tx, key, val json.RawMessage) error
+	del       func(ctx context.Context, tx *ezdb.Tx, key json.RawMessage) (found bool, err error)
+	scan      func(ctx context.Context, prefix string, after json.RawMessage, limit int) ([]Entry, error)
+}
+
+// Entry is a key/value pair returned by a scan, as JSON.
+type Entry struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// New returns a Server for the DBRefs of db.
+func New(db *ezdb.Client, opts ...Option) (*Server, error) {
+	o := &options{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set options: %w", err)
+		}
+	}
+
+	var serverOpts []grpc.ServerOption
+	if o.tls != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(o.tls)))
+	}
+
+	s := &Server{
+		db:      db,
+		options: o,
+		grpc:    grpc.NewServer(serverOpts...),
+		routes:  make(map[string]route),
+	}
+	ezdbpb.RegisterKVServer(s.grpc, &kvServer{s: s})
+
+	return s, nil
+}
+
+// RegisterService registers another gRPC service to be served alongside KV,
+// such as the replication service of ezdb.Client.ReplicationServer. It must
+// be called before Serve.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.grpc.RegisterService(desc, impl)
+}
+
+// Register serves ref, a DBRef of the Server's Client, under its name. K and
+// V must round-trip through encoding/json.
+func Register[K, V any](s *Server, ref *ezdb.DBRef[K, V]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := ref.Name()
+	if _, ok := s.routes[name]; ok {
+		return fmt.Errorf("DBRef %s is already registered", name)
+	}
+
+	s.routes[name] = route{
+		stringKey: reflect.TypeOf((*K)(nil)).Elem().Kind() == reflect.String,
+		get: func(ctx context.Context, key json.RawMessage) (json.RawMessage, bool, error) {
+			k, err := decode[K](key)
+			if err != nil {
+				return nil, false, &invalidError{what: "key", err: err}
+			}
+			val, ok, err := ref.WithContext(ctx).TryGet(k)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			data, err := json.Marshal(val)
+			return data, true, err
+		},
+		put: func(ctx context.Context, tx *ezdb.Tx, key, val json.RawMessage) error {
+			k, err := decode[K](key)
+			if err != nil {
+				return &invalidError{what: "key", err: err}
+			}
+			v, err := decode[V](val)
+			if err != nil {
+				return &invalidError{what: "value", err: err}
+			}
+			if tx == nil {
+				return ref.WithContext(ctx).Put(k, v)
+			}
+			return ref.WithContext(ctx).PutTx(tx, k, v)
+		},
+		del: func(ctx context.Context, tx *ezdb.Tx, key json.RawMessage) (bool, error) {
+			k, err := decode[K](key)
+			if err != nil {
+				return false, &invalidError{what: "key", err: err}
+			}
+			if tx == nil {
+				err = ref.WithContext(ctx).Delete(k)
+			} else {
+				err = ref.WithContext(ctx).DeleteTx(tx, k)
+			}
+			if errors.Is(err, ezdb.ErrNotFound) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		scan: func(ctx context.Context, prefix string, after json.RawMessage, limit int) ([]Entry, error) {
+			q := ref.WithContext(ctx).Query().Limit(limit)
+			if prefix != "" {
+				q = q.WherePrefix(prefix)
+			}
+			if len(after) > 0 {
+				k, err := decode[K](after)
+				if err != nil {
+					return nil, &invalidError{what: "after key", err: err}
+				}
+				q = q.After(k)
+			}
+			var entries []Entry
+			err := q.Each(func(key *K, val *V) error {
+				keyData, err := json.Marshal(key)
+				if err != nil {
+					return err
+				}
+				valData, err := json.Marshal(val)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, Entry{Key: keyData, Value: valData})
+				return nil
+			})
+			return entries, err
+		},
+	}
+
+	return nil
+}
+
+// errUnknownDBRef is matched by the errors for requests naming a DBRef that
+// isn't registered.
+var errUnknownDBRef = errors.New("unknown DBRef")
+
+// invalidError is a key or value of a request that doesn't decode.
+type invalidError struct {
+	what string
+	err  error
+}
+
+func (e *invalidError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.what, e.err)
+}
+
+func (e *invalidError) Unwrap() error {
+	return e.err
+}
+
+func decode[T any](data json.RawMessage) (*T, error) {
+	if len(data) == 0 {
+		return nil, errors.New("missing")
+	}
+
+	v := new(T)
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// route returns the route of the DBRef named name.
+func (s *Server) route(name string) (route, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.routes[name]
+	if !ok {
+		return route{}, fmt.Errorf("%w %q", errUnknownDBRef, name)
+	}
+
+	return r, nil
+}
+
+// Serve accepts connections on l and serves them until l fails or Close is
+// called, after which it returns ezdb.ErrClosed. With WithTLS, only TLS
+// connections are accepted.
+func (s *Server) Serve(l net.Listener) error {
+	err := s.grpc.Serve(l)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return ezdb.ErrClosed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+
+	// Serve returns nil once Close stopped it.
+	return ezdb.ErrClosed
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	return s.Serve(l)
+}
+
+// Close stops the listeners and closes all connections, failing the calls in
+// flight. It doesn't close the Client.
+func (s *Server) Close() error {
+	s.grpc.Stop()
+	return nil
+}
+
+// kvServer implements the gRPC service KV.
+type kvServer struct {
+	ezdbpb.UnimplementedKVServer
+	s *Server
+}
+
+func (kv *kvServer) Get(ctx context.Context, req *ezdbpb.GetRequest) (*ezdbpb.GetResponse, error) {
+	r, err := kv.s.route(req.Dbref)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	val, found, err := r.get(ctx, req.Key)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return &ezdbpb.GetResponse{Found: found, Value: val}, nil
+}
+
+func (kv *kvServer) Put(ctx context.Context, req *ezdbpb.PutRequest) (*ezdbpb.PutResponse, error) {
+	r, err := kv.s.route(req.Dbref)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	err = r.put(ctx, nil, req.Key, req.Value)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return &ezdbpb.PutResponse{}, nil
+}
+
+func (kv *kvServer) Delete(ctx context.Context, req *ezdbpb.DeleteRequest) (*ezdbpb.DeleteResponse, error) {
+	r, err := kv.s.route(req.Dbref)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	found, err := r.del(ctx, nil, req.Key)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return &ezdbpb.DeleteResponse{Found: found}, nil
+}
+
+func (kv *kvServer) Scan(ctx context.Context, req *ezdbpb.ScanRequest) (*ezdbpb.ScanResponse, error) {
+	r, err := kv.s.route(req.Dbref)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultScanLimit
+	}
+	if limit > maxScanLimit {
+		limit = maxScanLimit
+	}
+
+	entries, err := r.scan(ctx, req.Prefix, req.After, limit)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	resp := &ezdbpb.ScanResponse{Entries: make([]*ezdbpb.Entry, len(entries))}
+	for i, entry := range entries {
+		resp.Entries[i] = &ezdbpb.Entry{Key: entry.Key, Value: entry.Value}
+	}
+
+	return resp, nil
+}
+
+func (kv *kvServer) Txn(ctx context.Context, req *ezdbpb.TxnRequest) (*ezdbpb.TxnResponse, error) {
+	routes := make([]route, len(req.Ops))
+	for i, op := range req.Ops {
+		if op.Op != ezdbpb.TxnOp_OP_PUT && op.Op != ezdbpb.TxnOp_OP_DELETE {
+			return nil, status.Errorf(codes.InvalidArgument, "op %d: unknown op %v", i, op.Op)
+		}
+		var err error
+		routes[i], err = kv.s.route(op.Dbref)
+		if err != nil {
+			return nil, statusOf(fmt.Errorf("op %d: %w", i, err))
+		}
+	}
+
+	err := kv.s.db.Update(func(tx *ezdb.Tx) error {
+		for i, op := range req.Ops {
+			var err error
+			if op.Op == ezdbpb.TxnOp_OP_PUT {
+				err = routes[i].put(ctx, tx, op.Key, op.Value)
+			} else {
+				_, err = routes[i].del(ctx, tx, op.Key)
+			}
+			if err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	return &ezdbpb.TxnResponse{}, nil
+}
+
+// statusOf returns err as a gRPC status error with the code matching it and,
+// if it matches an ezdb error, an ErrorInfo detail identifying that error.
+func statusOf(err error) error {
+	var invalid *invalidError
+	code := codes.Unknown
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, ezdb.ErrKeyTooLarge), errors.Is(err, ezdb.ErrValueTooLarge):
+		code = codes.InvalidArgument
+	case errors.Is(err, errUnknownDBRef):
+		code = codes.NotFound
+	case errors.Is(err, ezdb.ErrDuplicate), errors.Is(err, ezdb.ErrKeyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, ezdb.ErrQuotaExceeded), errors.Is(err, ezdb.ErrMapFull):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ezdb.ErrClosed):
+		code = codes.Unavailable
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+
+	st := status.New(code, err.Error())
+	for _, r := range errorReasons {
+		if errors.Is(err, r.err) {
+			detailed, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: r.reason.String(), Domain: "ezdb"})
+			if derr == nil {
+				st = detailed
+			}
+			break
+		}
+	}
+
+	return st.Err()
+}
+
+// errorReasons are the ezdb errors identified in the statuses of failed
+// calls, see ezdbpb.Error.
+var errorReasons = []struct {
+	err    error
+	reason ezdbpb.Error
+}{
+	{ezdb.ErrNotFound, ezdbpb.Error_ERROR_NOT_FOUND},
+	{ezdb.ErrKeyExists, ezdbpb.Error_ERROR_KEY_EXISTS},
+	{ezdb.ErrDuplicate, ezdbpb.Error_ERROR_DUPLICATE},
+	{ezdb.ErrClosed, ezdbpb.Error_ERROR_CLOSED},
+	{ezdb.ErrMapFull, ezdbpb.Error_ERROR_MAP_FULL},
+	{ezdb.ErrTxnTooBig, ezdbpb.Error_ERROR_TXN_TOO_BIG},
+	{ezdb.ErrKeyTooLarge, ezdbpb.Error_ERROR_KEY_TOO_LARGE},
+	{ezdb.ErrValueTooLarge, ezdbpb.Error_ERROR_VALUE_TOO_LARGE},
+	{ezdb.ErrQuotaExceeded, ezdbpb.Error_ERROR_QUOTA_EXCEEDED},
+	{ezdb.ErrChecksum, ezdbpb.Error_ERROR_CHECKSUM},
+	{ezdb.ErrUnsupported, ezdbpb.Error_ERROR_UNSUPPORTED},
+}
@@ -0,0 +1,312 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/ezdbpb"
+	"github.com/bjornpagen/ezdb/server"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type account struct {
+	Owner   string `json:"owner"`
+	Balance int    `json:"balance"`
+}
+
+// serve serves s in memory and returns a KV client connected to it with
+// creds.
+func serve(t *testing.T, s *server.Server, creds credentials.TransportCredentials) ezdbpb.KVClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(lis) }()
+	t.Cleanup(func() {
+		s.Close()
+		err := <-done
+		if !errors.Is(err, ezdb.ErrClosed) {
+			t.Errorf("Serve returned %v, want ErrClosed", err)
+		}
+	})
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return ezdbpb.NewKVClient(conn)
+}
+
+// newKV returns a KV client of a Server for the DBRefs "accounts", with
+// string keys, and "counts", with integer keys.
+func newKV(t *testing.T) (ezdbpb.KVClient, *ezdb.DBRef[string, account]) {
+	t.Helper()
+
+	db := testutil.NewTempClient(t)
+	accounts, err := ezdb.NewDBRef[string, account](db, "accounts", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	counts, err := ezdb.NewDBRef[uint64, int](db, "counts", ezdb.WithKeyCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	s, err := server.New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = server.Register(s, accounts)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = server.Register(s, counts)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err = server.Register(s, accounts)
+	if err == nil {
+		t.Fatal("second Register of a DBRef succeeded")
+	}
+
+	return serve(t, s, insecure.NewCredentials()), accounts
+}
+
+// wantCode checks that err is a status error with code.
+func wantCode(t *testing.T, err error, code codes.Code) *status.Status {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code {
+		t.Fatalf("call returned %v, want code %v", err, code)
+	}
+	return st
+}
+
+func TestKV(t *testing.T) {
+	kv, accounts := newKV(t)
+	ctx := context.Background()
+
+	_, err := kv.Put(ctx, &ezdbpb.PutRequest{Dbref: "accounts", Key: []byte(`"ada"`), Value: []byte(`{"owner":"Ada","balance":10}`)})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	key := "ada"
+	val, err := accounts.Get(&key)
+	if err != nil || *val != (account{Owner: "Ada", Balance: 10}) {
+		t.Fatalf("Get of a value put over gRPC = %v, %v", val, err)
+	}
+
+	resp, err := kv.Get(ctx, &ezdbpb.GetRequest{Dbref: "accounts", Key: []byte(`"ada"`)})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !resp.Found || string(resp.Value) != `{"owner":"Ada","balance":10}` {
+		t.Fatalf("Get = %t, %s", resp.Found, resp.Value)
+	}
+	resp, err = kv.Get(ctx, &ezdbpb.GetRequest{Dbref: "accounts", Key: []byte(`"bob"`)})
+	if err != nil || resp.Found {
+		t.Fatalf("Get of a missing key = %v, %v", resp, err)
+	}
+
+	del, err := kv.Delete(ctx, &ezdbpb.DeleteRequest{Dbref: "accounts", Key: []byte(`"ada"`)})
+	if err != nil || !del.Found {
+		t.Fatalf("Delete = %v, %v", del, err)
+	}
+	del, err = kv.Delete(ctx, &ezdbpb.DeleteRequest{Dbref: "accounts", Key: []byte(`"ada"`)})
+	if err != nil || del.Found {
+		t.Fatalf("Delete of a missing key = %v, %v", del, err)
+	}
+}
+
+func TestKVScan(t *testing.T) {
+	kv, _ := newKV(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"ann", "bea", "bob", "cid"} {
+		key, _ := json.Marshal(name)
+		_, err := kv.Put(ctx, &ezdbpb.PutRequest{Dbref: "accounts", Key: key, Value: []byte(`{"owner":"x"}`)})
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	scanKeys := func(req *ezdbpb.ScanRequest) []string {
+		t.Helper()
+		resp, err := kv.Scan(ctx, req)
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		var keys []string
+		for _, entry := range resp.Entries {
+			keys = append(keys, string(entry.Key))
+		}
+		return keys
+	}
+
+	if got := scanKeys(&ezdbpb.ScanRequest{Dbref: "accounts"}); len(got) != 4 || got[0] != `"ann"` {
+		t.Fatalf("Scan = %v", got)
+	}
+	if got := scanKeys(&ezdbpb.ScanRequest{Dbref: "accounts", Prefix: "b"}); len(got) != 2 || got[1] != `"bob"` {
+		t.Fatalf("Scan of prefix b = %v", got)
+	}
+	// Pages continue after the last key returned.
+	got := scanKeys(&ezdbpb.ScanRequest{Dbref: "accounts", Limit: 2})
+	if len(got) != 2 || got[1] != `"bea"` {
+		t.Fatalf("first page = %v", got)
+	}
+	got = scanKeys(&ezdbpb.ScanRequest{Dbref: "accounts", Limit: 2, After: []byte(got[1])})
+	if len(got) != 2 || got[0] != `"bob"` || got[1] != `"cid"` {
+		t.Fatalf("second page = %v", got)
+	}
+
+	_, err := kv.Scan(ctx, &ezdbpb.ScanRequest{Dbref: "accounts", After: []byte(`{`)})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestKVTxn(t *testing.T) {
+	kv, accounts := newKV(t)
+	ctx := context.Background()
+
+	_, err := kv.Txn(ctx, &ezdbpb.TxnRequest{Ops: []*ezdbpb.TxnOp{
+		{Op: ezdbpb.TxnOp_OP_PUT, Dbref: "accounts", Key: []byte(`"ada"`), Value: []byte(`{"balance":1}`)},
+		{Op: ezdbpb.TxnOp_OP_PUT, Dbref: "counts", Key: []byte(`7`), Value: []byte(`1`)},
+		// Deleting a missing key doesn't fail the transaction.
+		{Op: ezdbpb.TxnOp_OP_DELETE, Dbref: "accounts", Key: []byte(`"bob"`)},
+	}})
+	if err != nil {
+		t.Fatalf("Txn: %v", err)
+	}
+	resp, err := kv.Get(ctx, &ezdbpb.GetRequest{Dbref: "counts", Key: []byte(`7`)})
+	if err != nil || !resp.Found || string(resp.Value) != "1" {
+		t.Fatalf("Get after Txn = %v, %v", resp, err)
+	}
+
+	// A failing op rolls back the ones before it.
+	_, err = kv.Txn(ctx, &ezdbpb.TxnRequest{Ops: []*ezdbpb.TxnOp{
+		{Op: ezdbpb.TxnOp_OP_DELETE, Dbref: "accounts", Key: []byte(`"ada"`)},
+		{Op: ezdbpb.TxnOp_OP_PUT, Dbref: "counts", Key: []byte(`"x"`), Value: []byte(`1`)},
+	}})
+	wantCode(t, err, codes.InvalidArgument)
+	key := "ada"
+	_, ok, err := accounts.TryGet(&key)
+	if err != nil || !ok {
+		t.Fatalf("TryGet after a failed Txn = %t, %v", ok, err)
+	}
+
+	_, err = kv.Txn(ctx, &ezdbpb.TxnRequest{Ops: []*ezdbpb.TxnOp{{Dbref: "accounts", Key: []byte(`"ada"`)}}})
+	wantCode(t, err, codes.InvalidArgument)
+	_, err = kv.Txn(ctx, &ezdbpb.TxnRequest{Ops: []*ezdbpb.TxnOp{{Op: ezdbpb.TxnOp_OP_DELETE, Dbref: "missing", Key: []byte(`"ada"`)}}})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestKVErrors(t *testing.T) {
+	kv, _ := newKV(t)
+	ctx := context.Background()
+
+	_, err := kv.Get(ctx, &ezdbpb.GetRequest{Dbref: "missing", Key: []byte(`"a"`)})
+	wantCode(t, err, codes.NotFound)
+	_, err = kv.Get(ctx, &ezdbpb.GetRequest{Dbref: "accounts"})
+	wantCode(t, err, codes.InvalidArgument)
+	_, err = kv.Put(ctx, &ezdbpb.PutRequest{Dbref: "accounts", Key: []byte(`"a"`), Value: []byte(`[]`)})
+	wantCode(t, err, codes.InvalidArgument)
+	_, err = kv.Delete(ctx, &ezdbpb.DeleteRequest{Dbref: "counts", Key: []byte(`-1`)})
+	wantCode(t, err, codes.InvalidArgument)
+
+	// Errors matching an ezdb error name it in an ErrorInfo detail.
+	key, _ := json.Marshal(string(make([]byte, 600)))
+	_, err = kv.Put(ctx, &ezdbpb.PutRequest{Dbref: "accounts", Key: key, Value: []byte(`{}`)})
+	st := wantCode(t, err, codes.InvalidArgument)
+	var reason string
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			reason = info.Reason
+		}
+	}
+	if reason != ezdbpb.Error_ERROR_KEY_TOO_LARGE.String() {
+		t.Fatalf("status of a Put of a long key has reason %q", reason)
+	}
+}
+
+func TestWithTLS(t *testing.T) {
+	_, err := server.New(testutil.NewTempClient(t), server.WithTLS(nil))
+	if err == nil {
+		t.Fatal("New with a nil TLS config succeeded")
+	}
+
+	cert, pool := selfSigned(t)
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	s, err := server.New(db, server.WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = server.Register(s, ref)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	kv := serve(t, s, credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "localhost"}))
+
+	_, err = kv.Put(context.Background(), &ezdbpb.PutRequest{Dbref: "ref", Key: []byte(`"a"`), Value: []byte(`"b"`)})
+	if err != nil {
+		t.Fatalf("Put over TLS: %v", err)
+	}
+}
+
+// selfSigned returns a self-signed certificate for localhost and a pool
+// trusting it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,220 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Set is a persistent set of members of type T, stored as the keys of a
+// named database with empty values.
+type Set[T any] struct {
+	ref *DBRef[T, struct{}]
+}
+
+// emptyCodec stores struct{} values as zero bytes.
+type emptyCodec struct{}
+
+func (emptyCodec) Marshal(v any) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (emptyCodec) Unmarshal(data []byte, v any) error {
+	if len(data) != 0 {
+		return errors.New("set member has a value")
+	}
+
+	return nil
+}
+
+// NewSet opens the set stored in the named database name of db, creating it
+// if it doesn't exist. opts are the same as for NewDBRef, though only those
+// concerning keys have an effect.
+func NewSet[T any](db *Client, name string, opts ...RefOption) (*Set[T], error) {
+	ref, err := NewDBRef[T, struct{}](db, name, append(opts, WithCodec(emptyCodec{}))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Set[T]{ref: ref}, nil
+}
+
+// Add adds member to the set.
+func (s *Set[T]) Add(member *T) error {
+	return s.ref.Put(member, &struct{}{})
+}
+
+// Remove removes member from the set. Removing a missing member is a no-op.
+func (s *Set[T]) Remove(member *T) error {
+	err := s.ref.Delete(member)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// Contains reports whether member is in the set.
+func (s *Set[T]) Contains(member *T) (bool, error) {
+	return s.ref.getRaw(member, func([]byte) error { return nil })
+}
+
+// Members returns every member of the set, in the order of their encoding.
+func (s *Set[T]) Members() (members []T, err error) {
+	err = s.ref.ForEach(func(member *T, _ *struct{}) error {
+		members = append(members, *member)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// Union replaces the members of dst with those in any of sets.
+func Union[T any](dst *Set[T], sets ...*Set[T]) error {
+	return combine(dst, sets, func(count int) bool { return count > 0 })
+}
+
+// Intersect replaces the members of dst with those in all of sets.
+func Intersect[T any](dst *Set[T], sets ...*Set[T]) error {
+	return combine(dst, sets, func(count int) bool { return count == len(sets) })
+}
+
+// combine replaces the members of dst with the encoded members of sets for
+// whose number of occurrences keep returns true, in one write transaction,
+// which is coalesced with other writes like a Put. Members are added and
+// removed like by Add and Remove, so dst's options and watchers see them.
+// dst may be one of sets.
+func combine[T any](dst *Set[T], sets []*Set[T], keep func(count int) bool) error {
+	for _, s := range sets {
+		if s.ref.ownerDB != dst.ref.ownerDB {
+			return errors.New("cannot combine sets of different clients")
+		}
+		if !reflect.DeepEqual(s.ref.options.keyCodec, dst.ref.options.keyCodec) {
+			return errors.New("cannot combine sets with different key codecs")
+		}
+		if !reflect.DeepEqual(s.ref.options.keyPrefixes, dst.ref.options.keyPrefixes) {
+			return errors.New("cannot combine sets with different key prefixes")
+		}
+		if !bytes.Equal(s.ref.prefix, dst.ref.prefix) {
+			return errors.New("cannot combine sets in different namespaces")
+		}
+	}
+
+	valBytes, err := dst.ref.encodeVal(&struct{}{})
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	err = dst.ref.ctxErr()
+	if err != nil {
+		return err
+	}
+	dst.ref.ownerDB.options.writeLimiter.wait()
+
+	return dst.ref.ownerDB.write(func(txn writeTxn) error {
+		counts := make(map[string]int)
+		var order []string
+		for _, s := range sets {
+			err := scanKeys(txn, s.ref.id, func(key []byte) error {
+				if counts[string(key)] == 0 {
+					order = append(order, string(key))
+				}
+				counts[string(key)]++
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		dbRef, err := txn.DBRef(dst.ref.id, dst.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		current := make(map[string]bool)
+		err = scanKeys(txn, dst.ref.id, func(key []byte) error {
+			current[string(key)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key := range current {
+			if keep(counts[key]) {
+				continue
+			}
+			err = dst.ref.deleteInTxn(txn, dbRef, []byte(key))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range order {
+			if !keep(counts[key]) || current[key] {
+				continue
+			}
+			err = dst.ref.putInTxn(txn, dbRef, []byte(key), valBytes, &struct{}{}, putFlag(0))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// scanKeys calls fn with every encoded key of the named database id. The
+// key is only valid until fn returns.
+func scanKeys(txn readTxn, id string, fn func(key []byte) error) error {
+	dbRef, err := txn.DBRef(id, dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	key, _, err := cursor.First()
+	for ; err == nil; key, _, err = cursor.Next() {
+		err = fn(key)
+		if err != nil {
+			return err
+		}
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return nil
+}
+
+// scanPrefix calls fn with every key of db starting with prefix. The key is
+// only valid until fn returns.
+func scanPrefix(txn readTxn, db dbi, prefix []byte, fn func(key []byte) error) error {
+	cursor, err := txn.NewCursor(db)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	key, _, err := cursor.SeekGreaterThanOrEqualKey(prefix)
+	for ; err == nil && bytes.HasPrefix(key, prefix); key, _, err = cursor.Next() {
+		err = fn(key)
+		if err != nil {
+			return err
+		}
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package ezdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// newSet opens the set name of db holding members.
+func newSet(t *testing.T, db *ezdb.Client, name string, members ...string) *ezdb.Set[string] {
+	t.Helper()
+
+	s, err := ezdb.NewSet[string](db, name, ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	for i := range members {
+		err = s.Add(&members[i])
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	return s
+}
+
+func members(t *testing.T, s *ezdb.Set[string]) string {
+	t.Helper()
+
+	m, err := s.Members()
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+
+	return fmt.Sprint(m)
+}
+
+func TestSet(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	s := newSet(t, db, "s", "b", "a", "c", "a")
+	if got := members(t, s); got != "[a b c]" {
+		t.Fatalf("Members = %s", got)
+	}
+
+	member := "b"
+	ok, err := s.Contains(&member)
+	if err != nil || !ok {
+		t.Fatalf("Contains(b) = %t, %v", ok, err)
+	}
+	err = s.Remove(&member)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	err = s.Remove(&member)
+	if err != nil {
+		t.Fatalf("Remove of a missing member: %v", err)
+	}
+	ok, err = s.Contains(&member)
+	if err != nil || ok {
+		t.Fatalf("Contains(b) after Remove = %t, %v", ok, err)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	a := newSet(t, db, "a", "1", "2", "3")
+	b := newSet(t, db, "b", "2", "3", "4")
+	c := newSet(t, db, "c", "3", "4", "5")
+	dst := newSet(t, db, "dst", "stale")
+
+	err := ezdb.Union(dst, a, b, c)
+	if err != nil {
+		t.Fatalf("Union: %v", err)
+	}
+	if got := members(t, dst); got != "[1 2 3 4 5]" {
+		t.Fatalf("Union = %s", got)
+	}
+	err = ezdb.Intersect(dst, a, b, c)
+	if err != nil {
+		t.Fatalf("Intersect: %v", err)
+	}
+	if got := members(t, dst); got != "[3]" {
+		t.Fatalf("Intersect = %s", got)
+	}
+
+	// The destination may be one of the sets.
+	err = ezdb.Intersect(a, a, b)
+	if err != nil {
+		t.Fatalf("Intersect: %v", err)
+	}
+	if got := members(t, a); got != "[2 3]" {
+		t.Fatalf("Intersect into a = %s", got)
+	}
+
+	other := newSet(t, testutil.NewTempClient(t), "other")
+	err = ezdb.Union(dst, a, other)
+	if err == nil {
+		t.Fatal("Union across Clients succeeded")
+	}
+	gobs, err := ezdb.NewSet[string](db, "gobs")
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	err = ezdb.Union(dst, a, gobs)
+	if err == nil {
+		t.Fatal("Union of sets with different key codecs succeeded")
+	}
+}
+
+func TestSetOperationsWrites(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithAuditLog())
+	a := newSet(t, db, "a", "1", "2")
+	dst, err := ezdb.NewSet[string](db, "dst", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithBloomFilter(100, 0.01))
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	member := "2"
+	err = dst.Add(&member)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	member = "3"
+	err = dst.Add(&member)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	after := uint64(len(auditLog(t, db, 0)))
+
+	// Members are added like by Add, so the bloom filter knows of them.
+	err = ezdb.Intersect(dst, a)
+	if err != nil {
+		t.Fatalf("Intersect: %v", err)
+	}
+	err = ezdb.Union(dst, a)
+	if err != nil {
+		t.Fatalf("Union: %v", err)
+	}
+	member = "1"
+	ok, err := dst.Contains(&member)
+	if err != nil || !ok {
+		t.Fatalf("Contains(1) after Union = %t, %v", ok, err)
+	}
+
+	// Only the members that changed are written.
+	var writes []string
+	for _, rec := range auditLog(t, db, after) {
+		writes = append(writes, rec.Op+" "+string(rec.Key))
+	}
+	if got := fmt.Sprint(writes); got != "[delete 3 put 1]" {
+		t.Fatalf("audit log = %s, want [delete 3 put 1]", got)
+	}
+
+	prefixed, err := ezdb.NewSet[string](db, "prefixed", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithKeyPrefixes("p/"))
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	err = ezdb.Union(dst, a, prefixed)
+	if err == nil {
+		t.Fatal("Union of sets with different key prefixes succeeded")
+	}
+}
@@ -0,0 +1,209 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// shardsFile records the number of shards in the directory of a
+// ShardedClient.
+const shardsFile = "shards"
+
+// ShardedClient partitions the keys of its DBRefs across several
+// environments, each in a directory of its own with its own writer, so that
+// write throughput scales past the single writer of one environment. A key
+// always lives in the shard picked by the hash of its encoding. Transactions,
+// and everything else spanning several keys, are per shard.
+type ShardedClient struct {
+	shards []*Client
+}
+
+// NewSharded returns a ShardedClient of n shards, stored in the numbered
+// subdirectories of dir, each opened with opts. The number of shards is
+// recorded in dir and can't change afterwards, since keys would move to other
+// shards.
+func NewSharded(dir string, n int, opts ...Option) (*ShardedClient, error) {
+	if n <= 0 {
+		return nil, errors.New("number of shards must be positive")
+	}
+
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sharded directory: %w", err)
+	}
+	err = checkShards(dir, n)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ShardedClient{shards: make([]*Client, n)}
+	for i := range s.shards {
+		s.shards[i], err = New(filepath.Join(dir, strconv.Itoa(i)), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create shard %d: %w", i, err)
+		}
+	}
+
+	return s, nil
+}
+
+// checkShards records n as the number of shards in dir, or checks that it
+// is the one recorded.
+func checkShards(dir string, n int) error {
+	path := filepath.Join(dir, shardsFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = os.WriteFile(path, []byte(strconv.Itoa(n)+"\n"), mode)
+		if err != nil {
+			return fmt.Errorf("failed to record number of shards: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read number of shards: %w", err)
+	}
+
+	recorded, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid number of shards in %s: %w", path, err)
+	}
+	if recorded != n {
+		return fmt.Errorf("%s has %d shards, not %d", dir, recorded, n)
+	}
+
+	return nil
+}
+
+// Shards returns the Clients of the shards, for per-shard operations such as
+// backups and statistics.
+func (s *ShardedClient) Shards() []*Client {
+	return append([]*Client(nil), s.shards...)
+}
+
+// Init opens the environments of all shards.
+func (s *ShardedClient) Init() error {
+	for i, shard := range s.shards {
+		err := shard.Init()
+		if err != nil {
+			return fmt.Errorf("failed to initialize shard %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes all shards, returning the first error.
+func (s *ShardedClient) Close() error {
+	var firstErr error
+	for i, shard := range s.shards {
+		err := shard.Close()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close shard %d: %w", i, err)
+		}
+	}
+
+	return firstErr
+}
+
+// ShardedRef is a DBRef partitioned across the shards of a ShardedClient,
+// with a DBRef of the same name in every shard.
+type ShardedRef[K, V any] struct {
+	refs []*DBRef[K, V]
+}
+
+var _ KV[string, string] = (*ShardedRef[string, string])(nil)
+
+// NewShardedRef opens the named database name in every shard of s, like
+// NewDBRef.
+func NewShardedRef[K, V any](s *ShardedClient, name string, opts ...RefOption) (*ShardedRef[K, V], error) {
+	ref := &ShardedRef[K, V]{refs: make([]*DBRef[K, V], len(s.shards))}
+	for i, shard := range s.shards {
+		var err error
+		ref.refs[i], err = NewDBRef[K, V](shard, name, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shard %d: %w", i, err)
+		}
+	}
+
+	return ref, nil
+}
+
+// Shard returns the DBRef of the shard key lives in.
+func (ref *ShardedRef[K, V]) Shard(key *K) (*DBRef[K, V], error) {
+	keyBytes, err := ref.refs[0].encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	h := fnv.New64a()
+	h.Write(keyBytes)
+	return ref.refs[h.Sum64()%uint64(len(ref.refs))], nil
+}
+
+// Name returns the name of the database.
+func (ref *ShardedRef[K, V]) Name() string {
+	return ref.refs[0].Name()
+}
+
+// Get returns the value stored under key. A missing key is reported as an
+// error matching ErrNotFound.
+func (ref *ShardedRef[K, V]) Get(key *K) (*V, error) {
+	shard, err := ref.Shard(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.Get(key)
+}
+
+// TryGet returns the value stored under key and whether it was found.
+func (ref *ShardedRef[K, V]) TryGet(key *K) (*V, bool, error) {
+	shard, err := ref.Shard(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return shard.TryGet(key)
+}
+
+// Put stores val under key in its shard.
+func (ref *ShardedRef[K, V]) Put(key *K, val *V) error {
+	shard, err := ref.Shard(key)
+	if err != nil {
+		return err
+	}
+
+	return shard.Put(key, val)
+}
+
+// Delete deletes key from its shard. A missing key is reported as an error
+// matching ErrNotFound.
+func (ref *ShardedRef[K, V]) Delete(key *K) error {
+	shard, err := ref.Shard(key)
+	if err != nil {
+		return err
+	}
+
+	return shard.Delete(key)
+}
+
+// ForEach calls fn with every entry until fn returns an error, which ForEach
+// returns. It visits the shards one after the other, each in the order of
+// its encoded keys and from a read transaction of its own, so the entries
+// are not in one order overall and not a snapshot across shards.
+func (ref *ShardedRef[K, V]) ForEach(fn func(key *K, val *V) error) error {
+	for _, shard := range ref.refs {
+		err := shard.ForEach(fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// openSharded opens a ShardedClient of n shards in dir and closes it after
+// the test.
+func openSharded(t *testing.T, dir string, n int) *ezdb.ShardedClient {
+	t.Helper()
+
+	s, err := ezdb.NewSharded(dir, n)
+	if err != nil {
+		t.Fatalf("NewSharded: %v", err)
+	}
+	err = s.Init()
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSharded(t *testing.T) {
+	dir := t.TempDir()
+	s := openSharded(t, dir, 4)
+	ref, err := ezdb.NewShardedRef[string, string](s, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewShardedRef: %v", err)
+	}
+	if ref.Name() != "ref" {
+		t.Fatalf("Name = %q", ref.Name())
+	}
+
+	var shards []*ezdb.DBRef[string, string]
+	for _, db := range s.Shards() {
+		shard, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		shards = append(shards, shard)
+	}
+
+	for i := 0; i < 200; i++ {
+		key, val := fmt.Sprintf("k%04d", i), fmt.Sprintf("v%d", i)
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		got, err := ref.Get(&key)
+		if err != nil || *got != fmt.Sprintf("v%d", i) {
+			t.Fatalf("Get(%s) = %v, %v", key, got, err)
+		}
+
+		// Each key is in the shard Shard picks and no other.
+		shard, err := ref.Shard(&key)
+		if err != nil {
+			t.Fatalf("Shard: %v", err)
+		}
+		_, ok, err := shard.TryGet(&key)
+		if err != nil || !ok {
+			t.Fatalf("TryGet(%s) in its shard = %t, %v", key, ok, err)
+		}
+		holding := 0
+		for _, shard := range shards {
+			_, ok, err := shard.TryGet(&key)
+			if err != nil {
+				t.Fatalf("TryGet: %v", err)
+			}
+			if ok {
+				holding++
+			}
+		}
+		if holding != 1 {
+			t.Fatalf("%s is in %d shards", key, holding)
+		}
+	}
+
+	// The keys are spread across all shards.
+	for i, shard := range shards {
+		n := 0
+		err = shard.ForEach(func(*string, *string) error { n++; return nil })
+		if err != nil || n == 0 {
+			t.Fatalf("shard %d holds %d keys: %v", i, n, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	err = ref.ForEach(func(key, val *string) error {
+		if seen[*key] {
+			return fmt.Errorf("ForEach visited %s twice", *key)
+		}
+		seen[*key] = true
+		return nil
+	})
+	if err != nil || len(seen) != 200 {
+		t.Fatalf("ForEach saw %d keys: %v", len(seen), err)
+	}
+	errStop := errors.New("stop")
+	err = ref.ForEach(func(*string, *string) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ForEach returned %v, want the error of fn", err)
+	}
+
+	key := "k0000"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a deleted key = %t, %v", ok, err)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of a deleted key returned %v, want ErrNotFound", err)
+	}
+	err = ref.Delete(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	// The keys are where they were after reopening.
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	ref, err = ezdb.NewShardedRef[string, string](openSharded(t, dir, 4), "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewShardedRef: %v", err)
+	}
+	key = "k0199"
+	got, err := ref.Get(&key)
+	if err != nil || *got != "v199" {
+		t.Fatalf("Get after reopening = %v, %v", got, err)
+	}
+}
+
+func TestNewShardedErrors(t *testing.T) {
+	_, err := ezdb.NewSharded(t.TempDir(), 0)
+	if err == nil {
+		t.Fatal("NewSharded of 0 shards succeeded")
+	}
+
+	// The number of shards can't change.
+	dir := t.TempDir()
+	openSharded(t, dir, 2).Close()
+	_, err = ezdb.NewSharded(dir, 3)
+	if err == nil {
+		t.Fatal("NewSharded with another number of shards succeeded")
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "shards"), []byte("two\n"), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, err = ezdb.NewSharded(dir, 2)
+	if err == nil {
+		t.Fatal("NewSharded with an invalid shards file succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package ezdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Shutdown closes the Client gracefully, for handling SIGTERM and the like:
+// it refuses new writes batched by WithFlushInterval or WithLatencyTarget,
+// commits the waiting ones and waits for those already being committed, then
+// refuses all new operations with ErrClosed, stops background tasks and waits
+// for in-flight operations, such as long read transactions, until ctx is
+// done. Once they have finished, the environment is synced to disk and
+// terminated as with Close.
+//
+// If ctx is done first, Shutdown returns its error right away, and the
+// environment is terminated in the background once the remaining operations
+// finish; the Client is closed either way and can be reopened with Reopen.
+func (db *Client) Shutdown(ctx context.Context) error {
+	db.lifecycle.Lock()
+	defer db.lifecycle.Unlock()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	db.draining = true
+	db.mu.Unlock()
+
+	var flushErr error
+	if db.coalescer != nil {
+		flushErr = db.coalescer.settle()
+	}
+	err := db.saveBlooms()
+	if err != nil {
+		db.options.log.Error().Err(err).Msg("failed to persist bloom filters")
+	}
+
+	db.mu.Lock()
+	db.closed = true
+	db.draining = false
+	env, inflight := db.db, db.inflight
+	db.db = nil
+	// The operations on env may outlive Shutdown, so a reopened Client counts
+	// its own.
+	db.inflight = new(sync.WaitGroup)
+	db.mu.Unlock()
+
+	if env == nil {
+		return nil
+	}
+
+	close(db.stopTasks)
+	db.taskWG.Wait()
+
+	drained := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		go func() {
+			<-drained
+			err := db.terminate(env)
+			if err != nil {
+				db.options.log.Error().Err(err).Msg("failed to terminate db")
+			}
+		}()
+		return fmt.Errorf("failed to wait for in-flight operations: %w", ctx.Err())
+	}
+
+	err = db.terminate(env)
+	if err != nil {
+		return err
+	}
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush writes: %w", flushErr)
+	}
+
+	return nil
+}
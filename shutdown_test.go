@@ -0,0 +1,147 @@
+package ezdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+func TestShutdown(t *testing.T) {
+	for _, opt := range []ezdb.Option{ezdb.WithFlushInterval(time.Hour), ezdb.WithLatencyTarget(time.Hour)} {
+		dir := t.TempDir()
+		db := openClient(t, dir, opt)
+		ref, err := ezdb.NewRef[string, string]("ref", db)
+		if err != nil {
+			t.Fatalf("NewRef: %v", err)
+		}
+
+		// Batched writes waiting for their transaction are committed.
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key, val := fmt.Sprintf("k%d", i), "v"
+				errs <- ref.Put(&key, &val)
+			}(i)
+		}
+		// Give the writes time to queue up; those that don't make it in
+		// are refused.
+		time.Sleep(10 * time.Millisecond)
+		err = db.Shutdown(context.Background())
+		if err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		wg.Wait()
+		close(errs)
+		written := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				written++
+			case !errors.Is(err, ezdb.ErrClosed):
+				t.Fatalf("Put during Shutdown: %v", err)
+			}
+		}
+
+		key, val := "after", "v"
+		err = ref.Put(&key, &val)
+		if !errors.Is(err, ezdb.ErrClosed) {
+			t.Fatalf("Put after Shutdown returned %v, want ErrClosed", err)
+		}
+		err = db.Shutdown(context.Background())
+		if !errors.Is(err, ezdb.ErrClosed) {
+			t.Fatalf("Shutdown after Shutdown returned %v, want ErrClosed", err)
+		}
+
+		ref, err = ezdb.NewRef[string, string]("ref", openClient(t, dir))
+		if err != nil {
+			t.Fatalf("NewRef: %v", err)
+		}
+		n := 0
+		err = ref.ForEach(func(*string, *string) error {
+			n++
+			return nil
+		})
+		if err != nil || n != written || written == 0 {
+			t.Fatalf("reopened DBRef has %d entries, %v, want the %d written", n, err, written)
+		}
+	}
+}
+
+func TestShutdownWaits(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 3)
+
+	// reading starts a read transaction that lasts until release is closed.
+	reading := func(release <-chan struct{}) <-chan error {
+		started, done := make(chan struct{}), make(chan error, 1)
+		go func() {
+			done <- ref.ForEach(func(*string, *string) error {
+				select {
+				case <-started:
+				default:
+					close(started)
+				}
+				<-release
+				return nil
+			})
+		}()
+		<-started
+		return done
+	}
+
+	// Shutdown waits for a read that ends in time.
+	release := make(chan struct{})
+	done := reading(release)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	err = db.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err = <-done; err != nil {
+		t.Fatalf("ForEach during Shutdown: %v", err)
+	}
+
+	// It gives up on one that doesn't, which still sees the environment.
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	release = make(chan struct{})
+	done = reading(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = db.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown with a read in flight returned %v, want DeadlineExceeded", err)
+	}
+	_, err = ref.Get(new(string))
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Get after Shutdown returned %v, want ErrClosed", err)
+	}
+	close(release)
+	if err = <-done; err != nil {
+		t.Fatalf("ForEach outliving Shutdown: %v", err)
+	}
+
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	wantN(t, ref, 3)
+}
@@ -0,0 +1,103 @@
+package ezdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// BackupSink stores named backups outside of the environment, e.g. in object
+// storage, for BackupTo and WithSnapshotSink.
+type BackupSink interface {
+	// Create starts a new backup named name.
+	Create(ctx context.Context, name string) (BackupUpload, error)
+	// List returns the names of the stored backups starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete deletes the backup named name.
+	Delete(ctx context.Context, name string) error
+}
+
+// BackupUpload is a backup being written to a BackupSink. The backup must
+// only become visible once Commit succeeds; after Abort, or a failed Commit,
+// nothing of it may remain.
+type BackupUpload interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+// BackupTo writes a backup, like Backup, to sink under name. The copy is
+// streamed into the upload as LMDB writes it, so nothing is staged on local
+// disk, and an upload cut short by ctx is aborted.
+func (db *Client) BackupTo(ctx context.Context, sink BackupSink, name string) error {
+	return db.copyToSink(ctx, sink, name, false)
+}
+
+// copyToSink writes a copy of the environment, compacted if compact is set,
+// to sink under name.
+func (db *Client) copyToSink(ctx context.Context, sink BackupSink, name string, compact bool) error {
+	upload, err := sink.Create(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to start upload: %w", err)
+	}
+
+	err = db.copyTo(ctx, upload, compact)
+	if err != nil {
+		return errors.Join(err, upload.Abort())
+	}
+
+	err = upload.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	return nil
+}
+
+// WithSnapshotSink is WithSnapshotSchedule for snapshots stored in sink
+// rather than in a local directory: every interval, a compacted backup named
+// "snapshot-" followed by its time is written to sink, and all but the
+// newest keep snapshots in sink are deleted.
+func WithSnapshotSink(interval time.Duration, sink BackupSink, keep uint) Option {
+	return func(option *options) error {
+		err := WithSnapshotSchedule(interval, "", keep)(option)
+		if err != nil {
+			return err
+		}
+		option.snapshots.sink = sink
+		return nil
+	}
+}
+
+// takeToSink takes a snapshot into the snapshotter's sink and deletes the ones
+// beyond the number to keep.
+func (s *snapshotter) takeToSink(status *SnapshotStatus) {
+	ctx := context.Background()
+	sink := s.options.sink
+
+	name := snapshotPrefix + status.Time.Format(snapshotLayout)
+	err := s.db.copyToSink(ctx, sink, name, true)
+	if err != nil {
+		status.Err = err
+		return
+	}
+	status.Path = name
+
+	names, err := sink.List(ctx, snapshotPrefix)
+	if err != nil {
+		status.Err = fmt.Errorf("failed to list snapshots: %w", err)
+		return
+	}
+	sort.Strings(names)
+	for len(names) > s.options.keep {
+		err = sink.Delete(ctx, names[0])
+		if err != nil {
+			status.Err = fmt.Errorf("failed to delete old snapshot: %w", err)
+			return
+		}
+		names = names[1:]
+	}
+}
@@ -0,0 +1,166 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// memSink is a BackupSink keeping the backups in memory.
+type memSink struct {
+	mu      sync.Mutex
+	backups map[string][]byte
+	// aborted counts the aborted uploads.
+	aborted int
+}
+
+func newMemSink() *memSink {
+	return &memSink{backups: make(map[string][]byte)}
+}
+
+func (s *memSink) Create(ctx context.Context, name string) (ezdb.BackupUpload, error) {
+	return &memUpload{sink: s, name: name}, nil
+}
+
+func (s *memSink) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name := range s.backups {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *memSink) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.backups, name)
+	return nil
+}
+
+// backup returns the backup named name, or nil.
+func (s *memSink) backup(name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backups[name]
+}
+
+type memUpload struct {
+	sink *memSink
+	name string
+	buf  bytes.Buffer
+}
+
+func (u *memUpload) Write(p []byte) (int, error) {
+	return u.buf.Write(p)
+}
+
+func (u *memUpload) Commit() error {
+	u.sink.mu.Lock()
+	defer u.sink.mu.Unlock()
+
+	u.sink.backups[u.name] = u.buf.Bytes()
+	return nil
+}
+
+func (u *memUpload) Abort() error {
+	u.sink.mu.Lock()
+	defer u.sink.mu.Unlock()
+
+	u.sink.aborted++
+	return nil
+}
+
+func TestBackupToSink(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+	sink := newMemSink()
+
+	err = db.BackupTo(context.Background(), sink, "backup")
+	if err != nil {
+		t.Fatalf("BackupTo: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(dir, bytes.NewReader(sink.backup("backup")))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := ezdb.NewRef[string, string]("ref", openClient(t, dir))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, restored, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.BackupTo(ctx, sink, "canceled")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("BackupTo with a canceled context returned %v", err)
+	}
+	if sink.backup("canceled") != nil || sink.aborted != 1 {
+		t.Fatalf("canceled upload was committed or not aborted")
+	}
+}
+
+func TestSnapshotSink(t *testing.T) {
+	sink := newMemSink()
+	db := testutil.NewTempClient(t, ezdb.WithSnapshotSink(10*time.Millisecond, sink, 2))
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	start := time.Now()
+	var last *ezdb.SnapshotStatus
+	for i := 0; i < 4; i++ {
+		last = waitSnapshot(t, db, start)
+		if last.Err != nil {
+			t.Fatalf("snapshot failed: %v", last.Err)
+		}
+		start = last.Time
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	names, err := sink.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	// Another snapshot may have been taken between the last one seen and Close.
+	if len(names) != 2 || (names[0] != last.Path && names[1] != last.Path) || !strings.HasPrefix(last.Path, "snapshot-") {
+		t.Fatalf("kept snapshots %v, last %s", names, last.Path)
+	}
+	dir := filepath.Join(t.TempDir(), "restored")
+	err = ezdb.Restore(dir, bytes.NewReader(sink.backup(names[1])))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := ezdb.NewRef[string, string]("ref", openClient(t, dir))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, restored, 100)
+}
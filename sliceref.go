@@ -0,0 +1,235 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// defaultChunkSize is the number of elements per chunk of a SliceRef unless
+// configured otherwise.
+const defaultChunkSize = 256
+
+// SliceRef stores a list of values of type V per key, for databases that
+// can't use DUPSORT (see MultiRef) or need duplicates and insertion order.
+// Each list is split into chunks of up to chunkSize elements, each stored in
+// its own record, so appending to a long list only rewrites its last chunk.
+type SliceRef[K, V any] struct {
+	ref       *DBRef[K, []V]
+	chunkSize int
+}
+
+// NewSliceRef opens the lists stored in the named database name of db,
+// creating it if it doesn't exist. A chunkSize of zero selects a default of
+// 256 elements. opts are the same as for NewDBRef; validators are not run.
+func NewSliceRef[K, V any](db *Client, name string, chunkSize int, opts ...RefOption) (*SliceRef[K, V], error) {
+	if chunkSize < 0 {
+		return nil, errors.New("chunk size must not be negative")
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	ref, err := NewDBRef[K, []V](db, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SliceRef[K, V]{ref: ref, chunkSize: chunkSize}, nil
+}
+
+// listPrefix returns the prefix the chunk keys of the list under key share:
+// the escaped encoded key, which no other list's prefix starts with.
+func (s *SliceRef[K, V]) listPrefix(key *K) ([]byte, error) {
+	keyBytes, err := s.ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	prefix := appendEscaped(nil, keyBytes)
+	if limit := s.ref.keyLimit(); len(prefix)+4 > limit {
+		return nil, &SizeError{Err: ErrKeyTooLarge, DB: s.ref.id, Size: len(prefix) + 4, Limit: limit}
+	}
+
+	return prefix, nil
+}
+
+// chunk is a decoded chunk of a list and the key it is stored under.
+type chunk[V any] struct {
+	key  []byte
+	vals []V
+}
+
+// chunksInTxn reads all chunks of the list whose chunk keys start with
+// prefix, in order.
+func (s *SliceRef[K, V]) chunksInTxn(txn readTxn, dbRef dbi, prefix []byte) ([]chunk[V], error) {
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	var chunks []chunk[V]
+	key, valBytes, err := cursor.SeekGreaterThanOrEqualKey(prefix)
+	for ; err == nil && bytes.HasPrefix(key, prefix); key, valBytes, err = cursor.Next() {
+		vals, err := s.ref.decodeVal(valBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value: %w", err)
+		}
+		chunks = append(chunks, chunk[V]{key: bytes.Clone(key), vals: *vals})
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// writeChunk stores vals under the chunk key, or deletes the chunk if vals
+// is empty. The audit log records the write under the chunk key.
+func (s *SliceRef[K, V]) writeChunk(txn writeTxn, dbRef dbi, key []byte, vals []V) error {
+	if len(vals) == 0 {
+		err := txn.Delete(dbRef, key, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete chunk: %w", err)
+		}
+		return s.ref.auditInTxn(txn, opDelete, key)
+	}
+
+	valBytes, err := s.ref.encodeVal(&vals)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	err = s.ref.checkSizes(len(key), len(valBytes))
+	if err != nil {
+		return err
+	}
+
+	err = txn.Put(dbRef, key, valBytes, putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to put chunk: %w", err)
+	}
+
+	return s.ref.auditInTxn(txn, opPut, key)
+}
+
+// Append appends v to the list under key.
+func (s *SliceRef[K, V]) Append(key *K, v V) error {
+	prefix, err := s.listPrefix(key)
+	if err != nil {
+		return err
+	}
+
+	return s.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(s.ref.id, s.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		// Find the last chunk: the one before the first key after the list.
+		cursor, err := txn.NewCursor(dbRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		var lastKey, lastVal []byte
+		_, _, err = cursor.SeekGreaterThanOrEqualKey(prefixEnd(prefix))
+		if err == nil {
+			lastKey, lastVal, err = cursor.Prev()
+		} else if errors.Is(err, ErrNotFound) {
+			lastKey, lastVal, err = cursor.Last()
+		}
+		if err == nil && bytes.HasPrefix(lastKey, prefix) {
+			lastKey, lastVal = bytes.Clone(lastKey), bytes.Clone(lastVal)
+		} else {
+			lastKey, lastVal = nil, nil
+		}
+		cursor.Close()
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		if lastKey != nil {
+			vals, err := s.ref.decodeVal(lastVal)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			if len(*vals) < s.chunkSize {
+				return s.writeChunk(txn, dbRef, lastKey, append(*vals, v))
+			}
+		}
+
+		var next uint32
+		if lastKey != nil {
+			next = binary.BigEndian.Uint32(lastKey[len(prefix):]) + 1
+			if next == 0 {
+				return errors.New("list has too many chunks")
+			}
+		}
+
+		return s.writeChunk(txn, dbRef, binary.BigEndian.AppendUint32(bytes.Clone(prefix), next), []V{v})
+	})
+}
+
+// RemoveAt removes the element at index i from the list under key. An index
+// out of range is reported as an error matching ErrNotFound.
+func (s *SliceRef[K, V]) RemoveAt(key *K, i int) error {
+	prefix, err := s.listPrefix(key)
+	if err != nil {
+		return err
+	}
+
+	return s.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(s.ref.id, s.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		chunks, err := s.chunksInTxn(txn, dbRef, prefix)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range chunks {
+			if i < 0 {
+				break
+			}
+			if i < len(c.vals) {
+				return s.writeChunk(txn, dbRef, c.key, append(c.vals[:i], c.vals[i+1:]...))
+			}
+			i -= len(c.vals)
+		}
+
+		return fmt.Errorf("index out of range: %w", ErrNotFound)
+	})
+}
+
+// Get returns the list under key, which is empty if there is none.
+func (s *SliceRef[K, V]) Get(key *K) (vals []V, err error) {
+	prefix, err := s.listPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ref.ownerDB.view(func(txn readTxn) error {
+		dbRef, err := txn.DBRef(s.ref.id, s.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		chunks, err := s.chunksInTxn(txn, dbRef, prefix)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			vals = append(vals, c.vals...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
@@ -0,0 +1,89 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSliceRef(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	s, err := ezdb.NewSliceRef[string, int](db, "lists", 3, ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewSliceRef: %v", err)
+	}
+
+	get := func(key string) string {
+		t.Helper()
+		vals, err := s.Get(&key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return fmt.Sprint(vals)
+	}
+
+	// Lists whose keys start alike don't see each other's chunks.
+	key, other := "a", "ab"
+	for i := 0; i < 8; i++ {
+		err = s.Append(&key, i)
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		err = s.Append(&other, -i)
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	err = s.Append(&key, 0)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if got := get("a"); got != "[0 1 2 3 4 5 6 7 0]" {
+		t.Fatalf("Get(a) = %s", got)
+	}
+	if got := get("ab"); got != "[0 -1 -2 -3 -4 -5 -6 -7]" {
+		t.Fatalf("Get(ab) = %s", got)
+	}
+	if got := get("missing"); got != "[]" {
+		t.Fatalf("Get of a missing list = %s", got)
+	}
+
+	// Emptying a chunk deletes it; the other chunks stay in order.
+	for _, i := range []int{4, 3, 3} {
+		err = s.RemoveAt(&key, i)
+		if err != nil {
+			t.Fatalf("RemoveAt(%d): %v", i, err)
+		}
+	}
+	if got := get("a"); got != "[0 1 2 6 7 0]" {
+		t.Fatalf("Get(a) after RemoveAt = %s", got)
+	}
+	err = s.Append(&key, 8)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if got := get("a"); got != "[0 1 2 6 7 0 8]" {
+		t.Fatalf("Get(a) after Append = %s", got)
+	}
+
+	for _, i := range []int{-1, 7} {
+		err = s.RemoveAt(&key, i)
+		if !errors.Is(err, ezdb.ErrNotFound) {
+			t.Errorf("RemoveAt(%d) returned %v, want ErrNotFound", i, err)
+		}
+	}
+
+	long := strings.Repeat("k", 510)
+	err = s.Append(&long, 1)
+	if !errors.Is(err, ezdb.ErrKeyTooLarge) {
+		t.Fatalf("Append under a long key returned %v, want ErrKeyTooLarge", err)
+	}
+	_, err = ezdb.NewSliceRef[string, int](db, "bad", -1)
+	if err == nil {
+		t.Fatal("NewSliceRef with a negative chunk size succeeded")
+	}
+}
@@ -0,0 +1,170 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithSnapshotSchedule makes the Client take a compacting copy of the
+// environment, see CompactTo, every interval while it is open, each in its
+// own subdirectory of dir, and delete all but the newest keep of them. The
+// outcome of the last snapshot is reported by Stats.
+func WithSnapshotSchedule(interval time.Duration, dir string, keep uint) Option {
+	return func(option *options) error {
+		if interval <= 0 {
+			return errors.New("snapshot interval must be positive")
+		}
+		if keep == 0 {
+			return errors.New("must keep at least one snapshot")
+		}
+		option.snapshots = &snapshotOptions{interval: interval, dir: dir, keep: int(keep)}
+		return nil
+	}
+}
+
+type snapshotOptions struct {
+	interval time.Duration
+	dir      string
+	keep     int
+	// sink replaces dir, if set.
+	sink BackupSink
+}
+
+// SnapshotStatus describes the last snapshot taken by WithSnapshotSchedule.
+type SnapshotStatus struct {
+	// Time is when the snapshot was started.
+	Time time.Time
+	// Duration is how long it took.
+	Duration time.Duration
+	// Path is the directory of the snapshot, or its name in the sink of
+	// WithSnapshotSink, if it succeeded.
+	Path string
+	// Err is why the snapshot failed, or nil.
+	Err error
+}
+
+// snapshotter takes the scheduled snapshots of a Client.
+type snapshotter struct {
+	db      *Client
+	options *snapshotOptions
+
+	mu   sync.Mutex
+	last *SnapshotStatus
+}
+
+// Snapshot directories are named snapshotPrefix followed by the start time in
+// snapshotLayout, so they sort by age, and are staged under a name starting
+// with snapshotTmpPrefix.
+const (
+	snapshotPrefix    = "snapshot-"
+	snapshotTmpPrefix = "tmp-snapshot-"
+	snapshotLayout    = "20060102T150405.000000000Z"
+)
+
+// run takes a snapshot every interval until stop is closed.
+func (s *snapshotter) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.options.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		status := s.take(time.Now().UTC())
+		if status.Err != nil {
+			s.db.options.log.Error().Err(status.Err).Msg("failed to take snapshot")
+		}
+
+		s.mu.Lock()
+		s.last = &status
+		s.mu.Unlock()
+	}
+}
+
+// take takes a snapshot and deletes the ones beyond the number to keep.
+func (s *snapshotter) take(now time.Time) (status SnapshotStatus) {
+	status.Time = now
+	defer func() { status.Duration = time.Since(now) }()
+
+	if s.options.sink != nil {
+		s.takeToSink(&status)
+		return status
+	}
+
+	name := now.Format(snapshotLayout)
+	tmp := filepath.Join(s.options.dir, snapshotTmpPrefix+name)
+	path := filepath.Join(s.options.dir, snapshotPrefix+name)
+
+	err := s.db.CompactTo(tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		status.Err = err
+		return status
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		os.RemoveAll(tmp)
+		status.Err = fmt.Errorf("failed to move snapshot into place: %w", err)
+		return status
+	}
+	status.Path = path
+
+	err = s.rotate()
+	if err != nil {
+		status.Err = err
+	}
+
+	return status
+}
+
+// rotate deletes all but the newest snapshots to keep.
+func (s *snapshotter) rotate() error {
+	entries, err := os.ReadDir(s.options.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), snapshotPrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for len(names) > s.options.keep {
+		err = os.RemoveAll(filepath.Join(s.options.dir, names[0]))
+		if err != nil {
+			return fmt.Errorf("failed to delete old snapshot: %w", err)
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
+
+// lastStatus returns the status of the last scheduled snapshot, or nil if
+// none has been taken.
+func (s *snapshotter) lastStatus() *SnapshotStatus {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		return nil
+	}
+	last := *s.last
+	return &last
+}
@@ -0,0 +1,111 @@
+package ezdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// waitSnapshot waits for db to finish a snapshot started after after and
+// returns its status.
+func waitSnapshot(t *testing.T, db *ezdb.Client, after time.Time) *ezdb.SnapshotStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		stats, err := db.Stats()
+		if err != nil {
+			t.Fatalf("Stats: %v", err)
+		}
+		if last := stats.LastSnapshot; last != nil && last.Time.After(after) {
+			return last
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no snapshot was taken")
+	return nil
+}
+
+func TestSnapshotSchedule(t *testing.T) {
+	dir := t.TempDir()
+	db := testutil.NewTempClient(t, ezdb.WithSnapshotSchedule(10*time.Millisecond, dir, 2))
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 100)
+
+	start := time.Now()
+	var last *ezdb.SnapshotStatus
+	for i := 0; i < 4; i++ {
+		last = waitSnapshot(t, db, start)
+		if last.Err != nil {
+			t.Fatalf("snapshot failed: %v", last.Err)
+		}
+		start = last.Time
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "snapshot-") {
+			t.Fatalf("snapshot directory holds %s", entry.Name())
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 2 {
+		t.Fatalf("kept snapshots %v, want 2", names)
+	}
+	// Another snapshot may have been taken between the last one seen and Close.
+	newest := filepath.Join(dir, names[1])
+	if last.Path != newest && last.Path != filepath.Join(dir, names[0]) {
+		t.Fatalf("last snapshot seen is %s, kept %v", last.Path, names)
+	}
+
+	snapshot, err := ezdb.NewRef[string, string]("ref", openClient(t, newest))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	wantN(t, snapshot, 100)
+}
+
+func TestSnapshotScheduleFailure(t *testing.T) {
+	// Snapshots can't be taken into a directory that is a file.
+	dir := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(dir, nil, 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	db := testutil.NewTempClient(t, ezdb.WithSnapshotSchedule(10*time.Millisecond, dir, 1))
+
+	last := waitSnapshot(t, db, time.Time{})
+	if last.Err == nil || last.Path != "" {
+		t.Fatalf("snapshot into a file = %+v, want an error", last)
+	}
+}
+
+func TestSnapshotScheduleRejectsInvalidOptions(t *testing.T) {
+	for _, opt := range []ezdb.Option{
+		ezdb.WithSnapshotSchedule(0, t.TempDir(), 1),
+		ezdb.WithSnapshotSchedule(time.Second, t.TempDir(), 0),
+	} {
+		_, err := ezdb.New(t.TempDir(), opt)
+		if err == nil {
+			t.Fatal("New with an invalid snapshot schedule succeeded")
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ezdb
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// snapshotPoolSize is the number of read transactions WithSnapshotReads keeps
+// open.
+const snapshotPoolSize = 4
+
+// WithSnapshotReads keeps a few read transactions open and runs the reads of
+// the Client in them instead of beginning and ending a transaction for each,
+// for read-heavy services where that overhead matters. Each transaction is
+// replaced by a fresh one once it is maxStaleness old, so reads may miss
+// writes of other Clients and processes for that long; writes through the
+// Client end the open transactions, so its own writes are always seen. Reads
+// that find every open transaction busy start one of their own as usual.
+//
+// The open transactions keep LMDB from reusing the pages freed since they
+// began, so a long maxStaleness under heavy writes grows the data file, and
+// with bbolt a write that grows the data file waits for them to be replaced.
+func WithSnapshotReads(maxStaleness time.Duration) Option {
+	return func(option *options) error {
+		if maxStaleness <= 0 {
+			return errors.New("snapshot staleness must be positive")
+		}
+		option.snapshotReads = maxStaleness
+		return nil
+	}
+}
+
+// snapshotPool serves reads from read transactions held open by background
+// tasks.
+type snapshotPool struct {
+	db           *Client
+	maxStaleness time.Duration
+	// reads are handed to whichever task is idle.
+	reads chan snapshotRead
+	// gen counts the commits of the Client's write transactions, and writing
+	// the ones in progress. A commit is visible to new transactions before
+	// gen counts it, so a task only serves a read from its transaction if gen
+	// is the same as when it began and no commit is in progress; otherwise it
+	// begins a new one, so reads never see an older state than one seen
+	// before.
+	gen     atomic.Uint64
+	writing atomic.Int64
+}
+
+// changing marks a commit, or another change of what new transactions see,
+// as in progress, and returns the function to call once it is done.
+func (p *snapshotPool) changing() (done func()) {
+	p.writing.Add(1)
+	return func() {
+		p.gen.Add(1)
+		p.writing.Add(-1)
+	}
+}
+
+// fresh reports whether a transaction begun at gen sees the latest commits.
+func (p *snapshotPool) fresh(gen uint64) bool {
+	return p.writing.Load() == 0 && p.gen.Load() == gen
+}
+
+// snapshotRead is a read waiting for a transaction.
+type snapshotRead struct {
+	fn   func(txn readTxn) error
+	done chan error
+}
+
+// view runs fn in an open read transaction and returns its error, if a task
+// is idle; otherwise it reports that it didn't run fn.
+func (p *snapshotPool) view(fn func(txn readTxn) error) (ran bool, err error) {
+	r := snapshotRead{fn: fn, done: make(chan error, 1)}
+	select {
+	case p.reads <- r:
+		return true, <-r.done
+	default:
+		return false, nil
+	}
+}
+
+// serve holds a read transaction open and runs reads in it, replacing it when
+// it gets stale, until stop is closed.
+func (p *snapshotPool) serve(stop <-chan struct{}) {
+	var pending *snapshotRead
+	for {
+		select {
+		case <-stop:
+			if pending != nil {
+				pending.done <- ErrClosed
+			}
+			return
+		default:
+		}
+
+		env, release, err := p.db.acquire()
+		if err != nil {
+			if pending != nil {
+				pending.done <- err
+				pending = nil
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(p.maxStaleness):
+			}
+			continue
+		}
+
+		gen := p.gen.Load()
+		stale := time.NewTimer(p.maxStaleness)
+		err = env.View(func(txn readTxn) error {
+			if pending != nil {
+				pending.done <- pending.fn(txn)
+				pending = nil
+			}
+			for {
+				select {
+				case <-stop:
+					return nil
+				case <-stale.C:
+					return nil
+				case r := <-p.reads:
+					if !p.fresh(gen) {
+						pending = &r
+						return nil
+					}
+					r.done <- r.fn(txn)
+				}
+			}
+		})
+		stale.Stop()
+		release()
+		if err != nil {
+			p.db.options.log.Error().Err(err).Msg("failed to open snapshot for reads")
+			if pending != nil {
+				pending.done <- err
+				pending = nil
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(p.maxStaleness):
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSnapshotReads(t *testing.T) {
+	faults := ezdb.NewFaults()
+	db := testutil.NewTempClient(t, ezdb.WithSnapshotReads(time.Hour), ezdb.WithFaults(faults))
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	putN(t, ref, 10)
+
+	// Reads share the open transactions, once they are open.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reads := faults.Count(ezdb.FaultRead)
+		for i := 0; i < 100; i++ {
+			wantN(t, ref, 10)
+		}
+		n := faults.Count(ezdb.FaultRead) - reads
+		if n <= 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("100 reads took %d transactions", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The Client's own writes are seen right away.
+	for i := 10; i < 20; i++ {
+		putN(t, ref, i+1)
+		wantN(t, ref, i+1)
+	}
+
+	// A panicking read fails on its own.
+	err = ref.ForEach(func(*string, *string) error { panic("read") })
+	var panicErr *ezdb.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("ForEach with a panicking fn returned %v, want a PanicError", err)
+	}
+	wantN(t, ref, 20)
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	key := "k0000"
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("Get after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestSnapshotReadsConcurrent(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithSnapshotReads(10*time.Millisecond))
+	ref, err := ezdb.NewDBRef[string, int](db, "ref", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key := "counter"
+	val := 0
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Readers never see the counter go back, however their reads are
+	// served.
+	stop := make(chan struct{})
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for {
+				select {
+				case <-stop:
+					errs <- nil
+					return
+				default:
+				}
+				got, err := ref.Get(&key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if *got < last {
+					errs <- fmt.Errorf("counter went from %d back to %d", last, *got)
+					return
+				}
+				last = *got
+			}
+		}()
+	}
+	for val = 1; val <= 200; val++ {
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, err := ref.Get(&key)
+		if err != nil || *got != val {
+			t.Fatalf("Get after Put of %d = %v, %v", val, got, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWithSnapshotReadsErrors(t *testing.T) {
+	_, err := ezdb.New(t.TempDir(), ezdb.WithSnapshotReads(0))
+	if err == nil {
+		t.Fatal("New with a zero snapshot staleness succeeded")
+	}
+}
@@ -0,0 +1,217 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The deleted database of a DBRef holds a tombstone for every soft-deleted
+// entry, under the entry's key: the big-endian Unix nanoseconds it was
+// deleted at, followed by the encoded value.
+
+// WithSoftDelete enables SoftDelete, Restore and ListDeleted on the DBRef.
+// Tombstones are kept in a database of their own, which counts towards
+// WithNumDBs.
+func WithSoftDelete() RefOption {
+	return func(option *refOptions) error {
+		option.softDelete = true
+		return nil
+	}
+}
+
+// Deleted is a soft-deleted entry, see SoftDelete.
+type Deleted[K, V any] struct {
+	Key   *K
+	Value *V
+	// Time is when the entry was deleted.
+	Time time.Time
+}
+
+func (ref *DBRef[K, V]) deletedDBName() string {
+	return ref.id + ".deleted"
+}
+
+// initSoftDelete opens the deleted database, creating it if it doesn't exist.
+func (ref *DBRef[K, V]) initSoftDelete() error {
+	if ref.ownerDB.readOnly() {
+		return ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(ref.deletedDBName(), dbFlag(0))
+			return err
+		})
+	}
+
+	return ref.ownerDB.update(func(txn writeTxn) error {
+		_, err := txn.DBRef(ref.deletedDBName(), dbCreate)
+		return err
+	})
+}
+
+// SoftDelete deletes the entry under key like Delete, but keeps it as a
+// tombstone that Restore can bring back and ListDeleted lists, so accidental
+// deletions are recoverable. Soft-deleting a key again replaces its earlier
+// tombstone. A missing key is reported as an error matching ErrNotFound. The
+// DBRef must have been created with WithSoftDelete.
+func (ref *DBRef[K, V]) SoftDelete(key *K) error {
+	if !ref.options.softDelete {
+		return errors.New("soft deletes are not enabled, see WithSoftDelete")
+	}
+
+	return ref.intercept(opDelete, key, nil, func() error {
+		return ref.writeKey(opDelete, key, ref.softDeleteInTxn)
+	})
+}
+
+// softDeleteInTxn replaces the entry under keyBytes with a tombstone.
+func (ref *DBRef[K, V]) softDeleteInTxn(txn writeTxn, dbRef dbi, keyBytes []byte) error {
+	now := time.Now()
+	if ref.options.ttl != nil {
+		expired, err := ref.expiredInTxn(txn, keyBytes, now)
+		if err != nil {
+			return err
+		}
+		if expired {
+			return fmt.Errorf("failed to get key: %w", ErrNotFound)
+		}
+	}
+	val, err := ref.getInTxn(txn, dbRef, keyBytes)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+	valBytes, err := ref.encodeVal(val)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	err = ref.deleteInTxn(txn, dbRef, keyBytes)
+	if err != nil {
+		return err
+	}
+
+	deletedRef, err := txn.DBRef(ref.deletedDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	tombstone := binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano()))
+	err = txn.Put(deletedRef, keyBytes, append(tombstone, valBytes...), putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to put tombstone: %w", err)
+	}
+	return nil
+}
+
+// Restore puts the soft-deleted entry under key back and removes its
+// tombstone. A key without a tombstone is reported as an error matching
+// ErrNotFound, and one that has been written since it was deleted as one
+// matching ErrKeyExists.
+func (ref *DBRef[K, V]) Restore(key *K) error {
+	if !ref.options.softDelete {
+		return errors.New("soft deletes are not enabled, see WithSoftDelete")
+	}
+
+	return ref.intercept(opPut, key, nil, func() error {
+		return ref.writeKey(opPut, key, ref.restoreInTxn)
+	})
+}
+
+// restoreInTxn puts the soft-deleted entry under keyBytes back.
+func (ref *DBRef[K, V]) restoreInTxn(txn writeTxn, dbRef dbi, keyBytes []byte) error {
+	deletedRef, err := txn.DBRef(ref.deletedDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+	tombstone, err := txn.Get(deletedRef, keyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to get tombstone: %w", err)
+	}
+	if len(tombstone) < 8 {
+		return errors.New("invalid tombstone")
+	}
+	valBytes := bytes.Clone(tombstone[8:])
+	val, err := ref.decodeVal(valBytes)
+	if err != nil {
+		return fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	_, err = txn.Get(dbRef, keyBytes)
+	if err == nil {
+		expired := false
+		if ref.options.ttl != nil {
+			expired, err = ref.expiredInTxn(txn, keyBytes, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+		if !expired {
+			return fmt.Errorf("failed to restore key: %w", ErrKeyExists)
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	err = txn.Delete(deletedRef, keyBytes, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete tombstone: %w", err)
+	}
+	return ref.putInTxn(txn, dbRef, keyBytes, valBytes, val, putFlag(0))
+}
+
+// ListDeleted returns the soft-deleted entries of ref, in the order of the
+// encoded keys.
+func (ref *DBRef[K, V]) ListDeleted() ([]Deleted[K, V], error) {
+	if !ref.options.softDelete {
+		return nil, errors.New("soft deletes are not enabled, see WithSoftDelete")
+	}
+
+	var deleted []Deleted[K, V]
+	err := ref.ownerDB.view(func(txn readTxn) error {
+		deletedRef, err := txn.DBRef(ref.deletedDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		cursor, err := txn.NewCursor(deletedRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		defer cursor.Close()
+
+		var keyBytes, tombstone []byte
+		if len(ref.prefix) == 0 {
+			keyBytes, tombstone, err = cursor.First()
+		} else {
+			keyBytes, tombstone, err = cursor.SeekGreaterThanOrEqualKey(ref.prefix)
+		}
+		for ; err == nil && bytes.HasPrefix(keyBytes, ref.prefix); keyBytes, tombstone, err = cursor.Next() {
+			if len(tombstone) < 8 {
+				return errors.New("invalid tombstone")
+			}
+			key, err := ref.decodeKey(keyBytes)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			val, err := ref.decodeVal(tombstone[8:])
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+			deleted = append(deleted, Deleted[K, V]{
+				Key:   key,
+				Value: val,
+				Time:  time.Unix(0, int64(binary.BigEndian.Uint64(tombstone))),
+			})
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read tombstones: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,164 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSoftDelete(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			ref, err := ezdb.NewDBRef[string, string](e.open(t), "ref", ezdb.WithSoftDelete())
+			if err != nil {
+				t.Fatalf("NewDBRef: %v", err)
+			}
+			putN(t, ref, 3)
+
+			before := time.Now()
+			for _, key := range []string{"k0002", "k0000"} {
+				err = ref.SoftDelete(&key)
+				if err != nil {
+					t.Fatalf("SoftDelete: %v", err)
+				}
+				if has(t, ref, key) {
+					t.Fatalf("soft-deleted entry %s is visible", key)
+				}
+			}
+			deleted, err := ref.ListDeleted()
+			if err != nil || len(deleted) != 2 {
+				t.Fatalf("ListDeleted = %v, %v", deleted, err)
+			}
+			for i, want := range []string{"k0000", "k0002"} {
+				d := deleted[i]
+				if *d.Key != want || *d.Value != "v"+want[4:] || d.Time.Before(before) || d.Time.After(time.Now()) {
+					t.Fatalf("deleted entry %d = %s: %s at %v, want %s", i, *d.Key, *d.Value, d.Time, want)
+				}
+			}
+
+			key := "k0000"
+			err = ref.Restore(&key)
+			if err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			got, err := ref.Get(&key)
+			if err != nil || *got != "v0" {
+				t.Fatalf("Get of a restored entry = %v, %v", got, err)
+			}
+			err = ref.Restore(&key)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("Restore without a tombstone returned %v, want ErrNotFound", err)
+			}
+
+			// A key written since it was deleted isn't overwritten, and keeps
+			// its tombstone.
+			key, val := "k0002", "new"
+			err = ref.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			err = ref.Restore(&key)
+			if !errors.Is(err, ezdb.ErrKeyExists) {
+				t.Fatalf("Restore of a written key returned %v, want ErrKeyExists", err)
+			}
+			got, err = ref.Get(&key)
+			if err != nil || *got != "new" {
+				t.Fatalf("Get after a failed Restore = %v, %v", got, err)
+			}
+
+			// Soft-deleting it again replaces the tombstone.
+			err = ref.SoftDelete(&key)
+			if err != nil {
+				t.Fatalf("SoftDelete: %v", err)
+			}
+			deleted, err = ref.ListDeleted()
+			if err != nil || len(deleted) != 1 || *deleted[0].Value != "new" {
+				t.Fatalf("ListDeleted = %v, %v", deleted, err)
+			}
+			err = ref.Restore(&key)
+			if err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			got, err = ref.Get(&key)
+			if err != nil || *got != "new" {
+				t.Fatalf("Get of a restored entry = %v, %v", got, err)
+			}
+
+			key = "missing"
+			err = ref.SoftDelete(&key)
+			if !errors.Is(err, ezdb.ErrNotFound) {
+				t.Fatalf("SoftDelete of a missing key returned %v, want ErrNotFound", err)
+			}
+			deleted, err = ref.ListDeleted()
+			if err != nil || len(deleted) != 0 {
+				t.Fatalf("ListDeleted = %v, %v", deleted, err)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteTTL(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithSoftDelete(), ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "a", "v"
+	err = ref.PutTTL(&key, &val, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	err = ref.SoftDelete(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("SoftDelete of an expired key returned %v, want ErrNotFound", err)
+	}
+
+	// Expired entries don't keep a deleted one from being restored.
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = ref.SoftDelete(&key)
+	if err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	other := "expired"
+	err = ref.PutTTL(&key, &other, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	err = ref.Restore(&key)
+	if err != nil {
+		t.Fatalf("Restore over an expired entry: %v", err)
+	}
+	got, err := ref.Get(&key)
+	if err != nil || *got != "v" {
+		t.Fatalf("Get of a restored entry = %v, %v", got, err)
+	}
+}
+
+func TestSoftDeleteErrors(t *testing.T) {
+	ref, err := ezdb.NewRef[string, string]("ref", testutil.NewTempClient(t))
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 1)
+	key := "k0000"
+	err = ref.SoftDelete(&key)
+	if err == nil {
+		t.Fatal("SoftDelete without WithSoftDelete succeeded")
+	}
+	err = ref.Restore(&key)
+	if err == nil {
+		t.Fatal("Restore without WithSoftDelete succeeded")
+	}
+	_, err = ref.ListDeleted()
+	if err == nil {
+		t.Fatal("ListDeleted without WithSoftDelete succeeded")
+	}
+	wantN(t, ref, 1)
+}
@@ -0,0 +1,171 @@
+package ezdb
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SortableID is a 128-bit ULID: a 48-bit millisecond Unix timestamp followed
+// by 80 random bits, both big-endian. Its bytes, and its string form, sort by
+// creation time, so records keyed by SortableIDs are stored oldest first and
+// the latest ones are at the end of the database.
+type SortableID [16]byte
+
+// crockford is the Crockford base32 alphabet ULIDs are written in.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	sortableIDMu   sync.Mutex
+	lastSortableID SortableID
+)
+
+// NewSortableID returns a new SortableID for the current time. IDs returned
+// by one process are strictly increasing, even within the same millisecond.
+// It panics if the system's secure random number generator fails.
+func NewSortableID() SortableID {
+	var id SortableID
+	ms := uint64(time.Now().UnixMilli())
+	putTimestamp(&id, ms)
+	_, err := rand.Read(id[6:])
+	if err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+
+	sortableIDMu.Lock()
+	defer sortableIDMu.Unlock()
+
+	// Within the same millisecond, or if the clock went backwards, increment
+	// the last ID instead so IDs keep increasing.
+	if lastSortableID.timestamp() >= ms {
+		id = lastSortableID
+		for i := len(id) - 1; i >= 0; i-- {
+			id[i]++
+			if id[i] != 0 {
+				break
+			}
+		}
+	}
+	lastSortableID = id
+
+	return id
+}
+
+func putTimestamp(id *SortableID, ms uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], ms)
+	copy(id[0:6], buf[2:])
+}
+
+func (id SortableID) timestamp() uint64 {
+	var buf [8]byte
+	copy(buf[2:], id[0:6])
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+// Time returns the time id was created at, to the millisecond.
+func (id SortableID) Time() time.Time {
+	return time.UnixMilli(int64(id.timestamp()))
+}
+
+// String returns the 26-character Crockford base32 form of id.
+func (id SortableID) String() string {
+	text, _ := id.MarshalText()
+	return string(text)
+}
+
+func (id SortableID) MarshalText() ([]byte, error) {
+	// 128 bits are written as 26 digits of 5 bits, the first holding only 3.
+	text := make([]byte, 26)
+	var acc uint64
+	var accBits, next int
+	for i := len(text) - 1; i >= 0; i-- {
+		for accBits < 5 && next < len(id) {
+			acc |= uint64(id[len(id)-1-next]) << accBits
+			accBits += 8
+			next++
+		}
+		text[i] = crockford[acc&0x1F]
+		acc >>= 5
+		accBits -= 5
+	}
+
+	return text, nil
+}
+
+func (id *SortableID) UnmarshalText(text []byte) error {
+	if len(text) != 26 {
+		return fmt.Errorf("invalid sortable ID length %d", len(text))
+	}
+
+	var parsed SortableID
+	var acc uint64
+	var accBits, next int
+	for i := len(text) - 1; i >= 0; i-- {
+		digit := crockfordDigit(text[i])
+		if digit < 0 || (i == 0 && digit > 7) {
+			return fmt.Errorf("invalid sortable ID %q", text)
+		}
+		acc |= uint64(digit) << accBits
+		accBits += 5
+		for accBits >= 8 && next < len(parsed) {
+			parsed[len(parsed)-1-next] = byte(acc)
+			acc >>= 8
+			accBits -= 8
+			next++
+		}
+	}
+	*id = parsed
+
+	return nil
+}
+
+// crockfordDigit returns the value of a Crockford base32 digit, accepting
+// lower case and the usual misreadings, or -1 if c isn't one.
+func crockfordDigit(c byte) int {
+	switch c {
+	case 'o', 'O':
+		return 0
+	case 'i', 'I', 'l', 'L':
+		return 1
+	}
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockford); i++ {
+		if crockford[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// SortableIDCodec stores SortableID keys as their 16 raw bytes, which sort by
+// creation time. Use it with WithKeyCodec for DBRefs keyed by SortableID.
+type SortableIDCodec struct{}
+
+func (SortableIDCodec) Marshal(v any) ([]byte, error) {
+	id, ok := v.(*SortableID)
+	if !ok {
+		return nil, fmt.Errorf("SortableIDCodec cannot encode %T", v)
+	}
+
+	return id[:], nil
+}
+
+func (SortableIDCodec) Unmarshal(data []byte, v any) error {
+	id, ok := v.(*SortableID)
+	if !ok {
+		return fmt.Errorf("SortableIDCodec cannot decode into %T", v)
+	}
+	if len(data) != len(id) {
+		return errors.New("invalid sortable ID encoding")
+	}
+	copy(id[:], data)
+
+	return nil
+}
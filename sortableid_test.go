@@ -0,0 +1,100 @@
+package ezdb_test
+
+import (
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSortableIDText(t *testing.T) {
+	// The timestamp of the reference implementation's decodeTime test.
+	const text = "01ARYZ6S410000000000000000"
+	var id ezdb.SortableID
+	err := id.UnmarshalText([]byte(text))
+	if err != nil {
+		t.Fatalf("UnmarshalText: %v", err)
+	}
+	if id.String() != text {
+		t.Fatalf("String = %q, want %q", id, text)
+	}
+	if ms := id.Time().UnixMilli(); ms != 1469918176385 {
+		t.Fatalf("Time = %d ms, want 1469918176385", ms)
+	}
+
+	var lower ezdb.SortableID
+	err = lower.UnmarshalText([]byte("01aryz6s410000000000000000"))
+	if err != nil || lower != id {
+		t.Fatalf("UnmarshalText of lower case = %v, %v", lower, err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"01ARYZ6S41000000000000000",
+		"01ARYZ6S41000000000000000U",
+		"81ARYZ6S410000000000000000",
+	} {
+		err = id.UnmarshalText([]byte(bad))
+		if err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestNewSortableIDIncreases(t *testing.T) {
+	last := ezdb.NewSortableID()
+	for i := 0; i < 1000; i++ {
+		id := ezdb.NewSortableID()
+		if id.String() <= last.String() {
+			t.Fatalf("%s after %s", id, last)
+		}
+		var parsed ezdb.SortableID
+		err := parsed.UnmarshalText([]byte(id.String()))
+		if err != nil || parsed != id {
+			t.Fatalf("round trip of %s = %s, %v", id, parsed, err)
+		}
+		last = id
+	}
+}
+
+func TestSortableIDCodec(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[ezdb.SortableID, int](db, "ids", ezdb.WithKeyCodec(ezdb.SortableIDCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	ids := make([]ezdb.SortableID, 10)
+	for i := range ids {
+		ids[i] = ezdb.NewSortableID()
+	}
+	// Store them out of order; they come back oldest first.
+	for i := len(ids) - 1; i >= 0; i-- {
+		n := i
+		err = ref.Put(&ids[i], &n)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	i := 0
+	err = ref.ForEach(func(key *ezdb.SortableID, val *int) error {
+		if *key != ids[i] || *val != i {
+			t.Errorf("entry %d = %s: %d", i, key, *val)
+		}
+		i++
+		return nil
+	})
+	if err != nil || i != len(ids) {
+		t.Fatalf("ForEach saw %d entries: %v", i, err)
+	}
+
+	var id ezdb.SortableID
+	err = ezdb.SortableIDCodec{}.Unmarshal([]byte{1, 2, 3}, &id)
+	if err == nil {
+		t.Fatal("Unmarshal of a short ID succeeded")
+	}
+	_, err = ezdb.SortableIDCodec{}.Marshal("id")
+	if err == nil {
+		t.Fatal("Marshal of a string succeeded")
+	}
+}
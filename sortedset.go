@@ -0,0 +1,158 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SortedSet is a persistent set of members of type M, each with a float64
+// score, that can be read in score order, e.g. as a priority queue or a
+// schedule of delayed jobs. Scores are stored in an index on the set, so a
+// sorted set takes up two named databases.
+type SortedSet[M any] struct {
+	ref    *DBRef[M, float64]
+	scores *Index[float64, M, float64]
+}
+
+// Scored is a member of a SortedSet together with its score.
+type Scored[M any] struct {
+	Member M
+	Score  float64
+}
+
+// NewSortedSet opens the sorted set stored in the named database name of db,
+// creating it if it doesn't exist. opts are the same as for NewDBRef, though
+// only those concerning keys have an effect.
+func NewSortedSet[M any](db *Client, name string, opts ...RefOption) (*SortedSet[M], error) {
+	ref, err := NewDBRef[M, float64](db, name, append(opts, WithCodec(OrderedCodec{}))...)
+	if err != nil {
+		return nil, err
+	}
+
+	scores, err := NewIndex(ref, "score", func(score *float64) float64 {
+		return *score
+	}, WithIndexCodec(OrderedCodec{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SortedSet[M]{ref: ref, scores: scores}, nil
+}
+
+// Add adds member with the given score, or changes its score if it is
+// already in the set.
+func (s *SortedSet[M]) Add(member *M, score float64) error {
+	if math.IsNaN(score) {
+		return errors.New("score is NaN")
+	}
+
+	return s.ref.Put(member, &score)
+}
+
+// Remove removes member from the set. Removing a missing member is a no-op.
+func (s *SortedSet[M]) Remove(member *M) error {
+	err := s.ref.Delete(member)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// Score returns the score of member, and whether it is in the set.
+func (s *SortedSet[M]) Score(member *M) (float64, bool, error) {
+	score, ok, err := s.ref.TryGet(member)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	return *score, true, nil
+}
+
+// PopMin removes and returns the member with the lowest score, and reports
+// whether the set had any members. Members with equal scores are popped in
+// the order of their encoding.
+func (s *SortedSet[M]) PopMin() (popped Scored[M], ok bool, err error) {
+	err = s.ref.ownerDB.update(func(txn writeTxn) error {
+		idxRef, err := txn.DBRef(s.scores.dbName, dbDupSort)
+		if err != nil {
+			return fmt.Errorf("failed to get index %q: %w", s.scores.name, err)
+		}
+		dbRef, err := txn.DBRef(s.ref.id, s.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		cursor, err := txn.NewCursor(idxRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		entry, pk, err := cursor.First()
+		cursor.Close()
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read index %q: %w", s.scores.name, err)
+		}
+
+		member, err := s.ref.decodeKey(pk)
+		if err != nil {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+		err = OrderedCodec{}.Unmarshal(entry, &popped.Score)
+		if err != nil {
+			return fmt.Errorf("failed to decode score: %w", err)
+		}
+		popped.Member = *member
+		ok = true
+
+		// Copy pk, which points into the index, before deleting.
+		return s.ref.deleteInTxn(txn, dbRef, bytes.Clone(pk))
+	})
+	if err != nil {
+		return Scored[M]{}, false, err
+	}
+
+	return popped, ok, nil
+}
+
+// RangeByScore returns the members with scores in [min, max], in score
+// order.
+func (s *SortedSet[M]) RangeByScore(min, max float64) (members []Scored[M], err error) {
+	from, err := OrderedCodec{}.Marshal(&min)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode score: %w", err)
+	}
+	to, err := OrderedCodec{}.Marshal(&max)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode score: %w", err)
+	}
+
+	err = s.ref.ownerDB.view(func(txn readTxn) error {
+		// Every encoded score is 8 bytes long, so the ones up to and including
+		// max are those before the end of its prefix.
+		return s.scores.scanEntries(txn, from, prefixEnd(to), func(entry, pk []byte) error {
+			member, err := s.ref.decodeKey(pk)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+
+			var score float64
+			err = OrderedCodec{}.Unmarshal(entry, &score)
+			if err != nil {
+				return fmt.Errorf("failed to decode score: %w", err)
+			}
+
+			members = append(members, Scored[M]{Member: *member, Score: score})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
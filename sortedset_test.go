@@ -0,0 +1,80 @@
+package ezdb_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestSortedSet(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	s, err := ezdb.NewSortedSet[string](db, "jobs")
+	if err != nil {
+		t.Fatalf("NewSortedSet: %v", err)
+	}
+
+	for member, score := range map[string]float64{"b": 2, "a": -1.5, "c": 2, "d": 10, "e": math.Inf(1)} {
+		member := member
+		err = s.Add(&member, score)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	member := "d"
+	err = s.Add(&member, 0)
+	if err != nil {
+		t.Fatalf("Add of an existing member: %v", err)
+	}
+	err = s.Add(&member, math.NaN())
+	if err == nil {
+		t.Fatal("Add with a NaN score succeeded")
+	}
+	score, ok, err := s.Score(&member)
+	if err != nil || !ok || score != 0 {
+		t.Fatalf("Score(d) = %g, %t, %v, want 0", score, ok, err)
+	}
+
+	members, err := s.RangeByScore(0, 2)
+	if err != nil {
+		t.Fatalf("RangeByScore: %v", err)
+	}
+	if got := fmt.Sprint(members); got != "[{d 0} {b 2} {c 2}]" {
+		t.Fatalf("RangeByScore(0, 2) = %s", got)
+	}
+	members, err = s.RangeByScore(math.Inf(-1), math.Inf(1))
+	if err != nil || len(members) != 5 {
+		t.Fatalf("RangeByScore of everything = %v, %v", members, err)
+	}
+
+	for _, want := range []string{"{a -1.5}", "{d 0}", "{b 2}"} {
+		popped, ok, err := s.PopMin()
+		if err != nil || !ok || fmt.Sprint(popped) != want {
+			t.Fatalf("PopMin = %v, %t, %v, want %s", popped, ok, err, want)
+		}
+	}
+	_, ok, err = s.Score(&member)
+	if err != nil || ok {
+		t.Fatalf("Score of a popped member = %t, %v", ok, err)
+	}
+
+	member = "c"
+	err = s.Remove(&member)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	err = s.Remove(&member)
+	if err != nil {
+		t.Fatalf("Remove of a missing member: %v", err)
+	}
+	popped, ok, err := s.PopMin()
+	if err != nil || !ok || popped.Member != "e" {
+		t.Fatalf("PopMin = %v, %t, %v, want e", popped, ok, err)
+	}
+	_, ok, err = s.PopMin()
+	if err != nil || ok {
+		t.Fatalf("PopMin of an empty set = %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,209 @@
+package ezdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// sqlColumn maps a column of an SQLite table to a key or value, or a field of
+// one.
+type sqlColumn struct {
+	name    string
+	sqlType string
+	// index is the field index path within the key or value, empty for the
+	// whole key or value.
+	index []int
+}
+
+// ExportSQLite writes every entry of ref as a row of table in sqlDB, an
+// SQLite database opened with any database/sql driver, creating the table if
+// it doesn't exist and replacing rows with the same key. The key is stored in
+// the column "key", the primary key. Values of DBRefs using JSONCodec whose
+// type is a struct are expanded into a column per field, named like JSONCodec
+// names them; other values are stored in the column "value". Strings,
+// numbers, booleans, []byte and time.Time, as RFC 3339 text, are stored as
+// their SQL counterparts, and everything else as JSON text. Entries are read
+// in a single read transaction and inserted in chunks, each in its own SQL
+// transaction.
+func (ref *DBRef[K, V]) ExportSQLite(ctx context.Context, sqlDB *sql.DB, table string) error {
+	valType := reflect.TypeOf((*V)(nil)).Elem()
+	keyCol := sqlColumn{name: "key", sqlType: sqlType(reflect.TypeOf((*K)(nil)).Elem())}
+	valCols := []sqlColumn{{name: "value", sqlType: sqlType(valType)}}
+	if _, ok := ref.options.valCodec.(JSONCodec); ok && valType.Kind() == reflect.Struct && valType != timeType {
+		valCols = expandedColumns(valType)
+	}
+
+	defs := []string{quoteIdent(keyCol.name) + " " + keyCol.sqlType + " PRIMARY KEY"}
+	names := []string{quoteIdent(keyCol.name)}
+	for _, col := range valCols {
+		if col.name == keyCol.name {
+			return fmt.Errorf("value field %q conflicts with the key column", col.name)
+		}
+		defs = append(defs, quoteIdent(col.name)+" "+col.sqlType)
+		names = append(names, quoteIdent(col.name))
+	}
+
+	_, err := sqlDB.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(table), strings.Join(defs, ", ")))
+	if err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	insert := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		quoteIdent(table), strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+
+	var rows [][]any
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		err := insertRows(ctx, sqlDB, insert, rows)
+		rows = rows[:0]
+		return err
+	}
+
+	err = ref.ForEach(func(key *K, val *V) error {
+		row := make([]any, 0, len(names))
+		arg, err := sqlValue(reflect.ValueOf(key).Elem())
+		if err != nil {
+			return fmt.Errorf("failed to convert key: %w", err)
+		}
+		row = append(row, arg)
+
+		valVal := reflect.ValueOf(val).Elem()
+		for _, col := range valCols {
+			v := valVal
+			if col.index != nil {
+				v = v.FieldByIndex(col.index)
+			}
+			arg, err = sqlValue(v)
+			if err != nil {
+				return fmt.Errorf("failed to convert %s: %w", col.name, err)
+			}
+			row = append(row, arg)
+		}
+
+		rows = append(rows, row)
+		if len(rows) < copyChunkSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// insertRows runs insert for every row in one SQL transaction.
+func insertRows(ctx context.Context, sqlDB *sql.DB, insert string, rows [][]any) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin SQL transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		_, err = stmt.ExecContext(ctx, row...)
+		if err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit SQL transaction: %w", err)
+	}
+
+	return nil
+}
+
+// expandedColumns maps the exported fields of the struct t to columns, named
+// like encoding/json names them.
+func expandedColumns(t reflect.Type) []sqlColumn {
+	var cols []sqlColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		cols = append(cols, sqlColumn{name: name, sqlType: sqlType(field.Type), index: field.Index})
+	}
+
+	return cols
+}
+
+// sqlType returns the SQLite type values of type t are stored as.
+func sqlType(t reflect.Type) string {
+	if t == timeType {
+		return "TEXT"
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BLOB"
+		}
+	}
+
+	return "TEXT"
+}
+
+// sqlValue converts v to the argument it is stored as, see sqlType.
+func sqlValue(v reflect.Value) (any, error) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows an SQLite integer", v.Uint())
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// quoteIdent quotes an SQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
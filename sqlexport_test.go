@@ -0,0 +1,214 @@
+package ezdb_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// sqlLog is a database/sql driver recording the statements executed through
+// it instead of running them.
+type sqlLog struct {
+	mu        sync.Mutex
+	execs     []string
+	args      [][]driver.Value
+	commits   int
+	rollbacks int
+	// failInserts fails every INSERT.
+	failInserts bool
+}
+
+// openSQLLog returns a *sql.DB whose statements are recorded in the returned
+// sqlLog.
+func openSQLLog(t *testing.T) (*sql.DB, *sqlLog) {
+	t.Helper()
+
+	log := &sqlLog{}
+	sqlDB := sql.OpenDB(log)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return sqlDB, log
+}
+
+func (l *sqlLog) Connect(context.Context) (driver.Conn, error) { return sqlConn{l}, nil }
+func (l *sqlLog) Driver() driver.Driver                        { return nil }
+
+// inserts returns the arguments of the recorded INSERTs.
+func (l *sqlLog) inserts() [][]driver.Value {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var rows [][]driver.Value
+	for i, query := range l.execs {
+		if strings.HasPrefix(query, "INSERT") {
+			rows = append(rows, l.args[i])
+		}
+	}
+	return rows
+}
+
+type sqlConn struct{ log *sqlLog }
+
+func (c sqlConn) Prepare(query string) (driver.Stmt, error) { return sqlStmt{c.log, query}, nil }
+func (c sqlConn) Close() error                              { return nil }
+func (c sqlConn) Begin() (driver.Tx, error)                 { return sqlTx{c.log}, nil }
+
+type sqlTx struct{ log *sqlLog }
+
+func (tx sqlTx) Commit() error {
+	tx.log.mu.Lock()
+	defer tx.log.mu.Unlock()
+	tx.log.commits++
+	return nil
+}
+
+func (tx sqlTx) Rollback() error {
+	tx.log.mu.Lock()
+	defer tx.log.mu.Unlock()
+	tx.log.rollbacks++
+	return nil
+}
+
+type sqlStmt struct {
+	log   *sqlLog
+	query string
+}
+
+func (s sqlStmt) Close() error  { return nil }
+func (s sqlStmt) NumInput() int { return -1 }
+
+func (s sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	if s.log.failInserts && strings.HasPrefix(s.query, "INSERT") {
+		return nil, errors.New("disk I/O error")
+	}
+	s.log.execs = append(s.log.execs, s.query)
+	s.log.args = append(s.log.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries aren't supported")
+}
+
+func TestExportSQLite(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, event](db, "events", ezdb.WithKeyCodec(ezdb.StringCodec{}), ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	key, val := "a", event{Name: "deploy", At: at, Count: 3, Ratio: 0.5, OK: true, Payload: []byte{1}}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	sqlDB, log := openSQLLog(t)
+
+	err = ref.ExportSQLite(context.Background(), sqlDB, `my "events"`)
+	if err != nil {
+		t.Fatalf("ExportSQLite: %v", err)
+	}
+	want := `CREATE TABLE IF NOT EXISTS "my ""events""" ("key" TEXT PRIMARY KEY, "name" TEXT, "At" TEXT, ` +
+		`"Count" INTEGER, "Ratio" REAL, "OK" INTEGER, "Payload" BLOB)`
+	if log.execs[0] != want {
+		t.Fatalf("created table with\n%s\nwant\n%s", log.execs[0], want)
+	}
+	want = `INSERT OR REPLACE INTO "my ""events""" ("key", "name", "At", "Count", "Ratio", "OK", "Payload") VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if log.execs[1] != want {
+		t.Fatalf("inserted with\n%s\nwant\n%s", log.execs[1], want)
+	}
+	if got := fmt.Sprint(log.inserts()); got != "[[a deploy 2024-01-02T03:04:05Z 3 0.5 true [1]]]" {
+		t.Fatalf("inserted %s", got)
+	}
+}
+
+func TestExportSQLiteUnexpandedValues(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	// Only values of JSONCodec are expanded into columns.
+	ref, err := ezdb.NewDBRef[uint64, user](db, "users", ezdb.WithKeyCodec(ezdb.Uint64Codec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := uint64(1), user{Name: "Ada"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	sqlDB, log := openSQLLog(t)
+
+	err = ref.ExportSQLite(context.Background(), sqlDB, "users")
+	if err != nil {
+		t.Fatalf("ExportSQLite: %v", err)
+	}
+	if want := `CREATE TABLE IF NOT EXISTS "users" ("key" INTEGER PRIMARY KEY, "value" TEXT)`; log.execs[0] != want {
+		t.Fatalf("created table with %s", log.execs[0])
+	}
+	if got := fmt.Sprint(log.inserts()); got != `[[1 {"Name":"Ada","Email":"","Bio":""}]]` {
+		t.Fatalf("inserted %s", got)
+	}
+
+	// SQLite integers are signed.
+	key = math.MaxUint64
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = ref.ExportSQLite(context.Background(), sqlDB, "users")
+	if err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Fatalf("ExportSQLite of a large key returned %v", err)
+	}
+}
+
+func TestExportSQLiteChunks(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 2500)
+	sqlDB, log := openSQLLog(t)
+
+	err = ref.ExportSQLite(context.Background(), sqlDB, "ref")
+	if err != nil {
+		t.Fatalf("ExportSQLite: %v", err)
+	}
+	if rows := log.inserts(); len(rows) != 2500 || log.commits != 3 {
+		t.Fatalf("inserted %d rows in %d transactions, want 2500 in 3", len(rows), log.commits)
+	}
+
+	log.failInserts = true
+	err = ref.ExportSQLite(context.Background(), sqlDB, "ref")
+	if err == nil || log.rollbacks != 1 {
+		t.Fatalf("ExportSQLite with failing inserts returned %v after %d rollbacks", err, log.rollbacks)
+	}
+}
+
+func TestExportSQLiteKeyColumnConflict(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	type clash struct {
+		Key string `json:"key"`
+	}
+	ref, err := ezdb.NewDBRef[string, clash](db, "clash", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	sqlDB, _ := openSQLLog(t)
+
+	err = ref.ExportSQLite(context.Background(), sqlDB, "clash")
+	if err == nil {
+		t.Fatal("ExportSQLite with a value field named key succeeded")
+	}
+}
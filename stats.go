@@ -0,0 +1,136 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stats describes the state of a Client's environment.
+type Stats struct {
+	// PageSize is the size of a database page in bytes.
+	PageSize uint64
+	// MapSize is the current size of the memory map in bytes.
+	MapSize uint64
+	// UsedPages is the number of pages the data file has grown to, including
+	// pages on the free list.
+	UsedPages uint64
+	// FreePages is the number of pages on the free list, available for reuse
+	// by future writes.
+	FreePages uint64
+	// LastTxnID is the ID of the last committed write transaction.
+	LastTxnID uint64
+	// NumReaders is the number of reader slots pinning a snapshot.
+	NumReaders int
+	// MaxReaders is the configured size of the reader table.
+	MaxReaders uint
+	// LastSnapshot is the outcome of the last snapshot taken by
+	// WithSnapshotSchedule, or nil if none has been taken.
+	LastSnapshot *SnapshotStatus
+}
+
+// envStat returns the state of the Client's environment, for engines that
+// report it.
+func (db *Client) envStat() (envStat, error) {
+	env, release, err := db.acquire()
+	if err != nil {
+		return envStat{}, err
+	}
+	defer release()
+
+	stater, ok := backendAs[envStater](env)
+	if !ok {
+		return envStat{}, fmt.Errorf("failed to read environment of %s engine: %w", db.options.engine, ErrUnsupported)
+	}
+
+	return stater.EnvStat()
+}
+
+// Stats reports map size and page usage of the environment, so callers can
+// monitor growth and alert before the map limit is hit.
+func (db *Client) Stats() (Stats, error) {
+	s, err := db.envStat()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		PageSize:     s.PageSize,
+		MapSize:      s.MapSize,
+		UsedPages:    s.LastPage + 1,
+		FreePages:    s.FreePages,
+		LastTxnID:    s.LastTxnID,
+		MaxReaders:   *db.options.numReaders,
+		LastSnapshot: db.snapshotter.lastStatus(),
+	}
+	for _, slot := range s.Readers {
+		if slot.active() {
+			stats.NumReaders++
+		}
+	}
+
+	return stats, nil
+}
+
+// ListDBs returns the names of all named databases in the environment, in
+// sorted order, including ones created by other programs.
+func (db *Client) ListDBs() (names []string, err error) {
+	err = db.view(func(txn readTxn) error {
+		dbs, err := txn.ListDBs()
+		if err != nil {
+			return fmt.Errorf("failed to list databases: %w", err)
+		}
+
+		names = make([]string, 0, len(dbs))
+		for _, d := range dbs {
+			names = append(names, d.name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// DBStat describes the B+tree of a single DBRef.
+type DBStat struct {
+	// Entries is the number of key/value pairs.
+	Entries uint64
+	// Depth is the height of the B+tree.
+	Depth uint
+	// BranchPages, LeafPages and OverflowPages count the pages of each kind
+	// used by the tree.
+	BranchPages   uint64
+	LeafPages     uint64
+	OverflowPages uint64
+	// Size is the estimated on-disk size in bytes of all of the above pages.
+	Size uint64
+}
+
+// Stat reports the size of the sub-database backing ref, for capacity
+// planning per logical table.
+func (ref *DBRef[K, V]) Stat() (stat DBStat, err error) {
+	err = ref.ownerDB.view(func(txn readTxn) error {
+		st, ok := txn.(statTxn)
+		if !ok {
+			return fmt.Errorf("failed to read database records of %s engine: %w", ref.ownerDB.options.engine, ErrUnsupported)
+		}
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if errors.Is(err, ErrNotFound) {
+			// An empty tree, the database isn't created yet.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open db ref: %w", err)
+		}
+
+		stat, err = st.Stat(dbRef)
+		return err
+	})
+	if err != nil {
+		return DBStat{}, err
+	}
+
+	return stat, nil
+}
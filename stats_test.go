@@ -0,0 +1,216 @@
+package ezdb_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestStats(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	before, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if before.PageSize != uint64(os.Getpagesize()) {
+		t.Errorf("PageSize = %d, want %d", before.PageSize, os.Getpagesize())
+	}
+	if before.MapSize == 0 || before.MaxReaders != 32 {
+		t.Errorf("Stats = %+v, want a map and 32 readers", before)
+	}
+
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key, val := fmt.Sprint(i), strings.Repeat("v", 1000)
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	after, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if after.UsedPages <= before.UsedPages || after.LastTxnID <= before.LastTxnID {
+		t.Errorf("Stats after writes = %+v, want more pages and transactions than %+v", after, before)
+	}
+	if after.FreePages >= after.UsedPages {
+		t.Errorf("FreePages = %d of %d used", after.FreePages, after.UsedPages)
+	}
+
+	// A scan in progress holds a reader slot.
+	err = ref.ForEach(func(key, val *string) error {
+		during, err := db.Stats()
+		if err != nil {
+			return err
+		}
+		if during.NumReaders < 1 {
+			t.Errorf("NumReaders during a scan = %d", during.NumReaders)
+		}
+		return errors.New("stop")
+	})
+	if err == nil {
+		t.Fatal("ForEach didn't stop")
+	}
+}
+
+func TestStatsUnsupported(t *testing.T) {
+	db := testutil.NewMemoryClient(t)
+
+	_, err := db.Stats()
+	if !errors.Is(err, ezdb.ErrUnsupported) {
+		t.Fatalf("Stats of a memory Client returned %v, want ErrUnsupported", err)
+	}
+}
+
+func TestDBRefStat(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	stat, err := ref.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Entries != 0 || stat.Size != 0 {
+		t.Errorf("Stat of an empty ref = %+v", stat)
+	}
+
+	for i := 0; i < 50; i++ {
+		key, val := fmt.Sprint(i), strings.Repeat("v", 10000)
+		err = ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	stat, err = ref.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Entries != 50 || stat.Depth != 1 || stat.LeafPages != 1 || stat.OverflowPages == 0 {
+		t.Errorf("Stat = %+v, want 50 entries on one leaf with overflow pages", stat)
+	}
+	pages := stat.BranchPages + stat.LeafPages + stat.OverflowPages
+	if stat.Size != pages*uint64(os.Getpagesize()) {
+		t.Errorf("Size = %d, want %d pages", stat.Size, pages)
+	}
+}
+
+func TestListDBs(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+
+			names, err := db.ListDBs()
+			if err != nil {
+				t.Fatalf("ListDBs: %v", err)
+			}
+			if len(names) != 0 {
+				t.Fatalf("ListDBs of a new environment = %v", names)
+			}
+
+			for _, name := range []string{"users", "orders", "accounts"} {
+				_, err = ezdb.NewRef[string, string](name, db)
+				if err != nil {
+					t.Fatalf("NewRef(%s): %v", name, err)
+				}
+			}
+			_, err = ezdb.NewMultiRef[string, string](db, "tags")
+			if err != nil {
+				t.Fatalf("NewMultiRef: %v", err)
+			}
+			names, err = db.ListDBs()
+			if err != nil {
+				t.Fatalf("ListDBs: %v", err)
+			}
+			if got, want := strings.Join(names, ","), "accounts,orders,tags,users"; got != want {
+				t.Fatalf("ListDBs = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestListDBsUnopened(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	for _, name := range []string{"b", "a"} {
+		_, err := ezdb.NewRef[string, string](name, db)
+		if err != nil {
+			t.Fatalf("NewRef(%s): %v", name, err)
+		}
+	}
+	_, err := ezdb.NewMultiRef[string, string](db, "c")
+	if err != nil {
+		t.Fatalf("NewMultiRef: %v", err)
+	}
+	db.Close()
+
+	// A new Client has no handles for the databases yet.
+	names, err := openClient(t, dir).ListDBs()
+	if err != nil {
+		t.Fatalf("ListDBs: %v", err)
+	}
+	if got := strings.Join(names, ","); got != "a,b,c" {
+		t.Fatalf("ListDBs = %s, want a,b,c", got)
+	}
+}
+
+func TestDropRemovesDatabaseAndBookkeeping(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	other, err := ezdb.NewRef[string, string]("other", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.PutTTL(&key, &val, time.Hour)
+	if err == nil {
+		err = other.Put(&key, &val)
+	}
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	err = ref.Drop()
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	names, err := db.ListDBs()
+	if err != nil {
+		t.Fatalf("ListDBs: %v", err)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "ref") {
+			t.Errorf("database %s survived Drop", name)
+		}
+	}
+	_, err = other.Get(&key)
+	if err != nil {
+		t.Fatalf("Get of another ref after Drop: %v", err)
+	}
+
+	ref, err = ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef after Drop: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get after Drop returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,197 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Table stores structs of type T keyed by one of their fields, with indexes
+// on others, all declared with struct tags:
+//
+//	type User struct {
+//		ID    int    `ezdb:"key"`
+//		Email string `ezdb:"unique"`
+//		Team  string `ezdb:"index"`
+//	}
+//
+// Exactly one exported field must be tagged "key". Fields tagged "index" get
+// an index, and fields tagged "unique" an index that rejects duplicates with
+// ErrDuplicate. Keys and indexed fields are stored with OrderedCodec, so
+// their types must be supported by it.
+type Table[T any] struct {
+	ref     *DBRef[[]byte, T]
+	key     reflect.StructField
+	indexes map[string]*tableIndex[T]
+}
+
+type tableIndex[T any] struct {
+	field reflect.StructField
+	index *Index[[]byte, []byte, T]
+}
+
+// NewTable opens the table stored in the named database name of db, along
+// with the index databases of its tagged fields, creating them if they don't
+// exist. opts configure the underlying DBRef; its key codec can't be changed.
+func NewTable[T any](db *Client, name string, opts ...RefOption) (*Table[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("table type %s is not a struct", typ)
+	}
+
+	t := &Table[T]{indexes: make(map[string]*tableIndex[T])}
+	var indexed []reflect.StructField
+	var hasKey bool
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("ezdb")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, fmt.Errorf("tagged field %s is not exported", field.Name)
+		}
+
+		_, err := appendOrdered(nil, reflect.Zero(field.Type))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		switch tag {
+		case "key":
+			if hasKey {
+				return nil, fmt.Errorf("table type %s has more than one key field", typ)
+			}
+			t.key, hasKey = field, true
+		case "index", "unique":
+			indexed = append(indexed, field)
+		default:
+			return nil, fmt.Errorf("field %s: unknown ezdb tag %q", field.Name, tag)
+		}
+	}
+	if !hasKey {
+		return nil, fmt.Errorf("table type %s has no field tagged ezdb:\"key\"", typ)
+	}
+
+	ref, err := NewDBRef[[]byte, T](db, name, append(opts, WithKeyCodec(BytesCodec{}))...)
+	if err != nil {
+		return nil, err
+	}
+	t.ref = ref
+
+	for _, field := range indexed {
+		field := field
+		idxOpts := []IndexOption{WithIndexCodec(BytesCodec{})}
+		if field.Tag.Get("ezdb") == "unique" {
+			idxOpts = append(idxOpts, WithUnique())
+		}
+
+		// Field types were checked above, so encoding can't fail.
+		idx, err := NewIndex(ref, field.Name, func(val *T) []byte {
+			enc, _ := appendOrdered(nil, reflect.ValueOf(val).Elem().FieldByIndex(field.Index))
+			return enc
+		}, idxOpts...)
+		if err != nil {
+			return nil, err
+		}
+		t.indexes[field.Name] = &tableIndex[T]{field: field, index: idx}
+	}
+
+	return t, nil
+}
+
+// Save stores v under the value of its key field, replacing the record with
+// the same key if there is one.
+func (t *Table[T]) Save(v *T) error {
+	key, err := appendOrdered(nil, reflect.ValueOf(v).Elem().FieldByIndex(t.key.Index))
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	return t.ref.Put(&key, v)
+}
+
+// Load returns the record whose key field equals key. A missing record is
+// reported as an error matching ErrNotFound.
+func (t *Table[T]) Load(key any) (*T, error) {
+	keyBytes, err := encodeField(t.key, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ref.Get(&keyBytes)
+}
+
+// Delete removes the record whose key field equals key. A missing record is
+// reported as an error matching ErrNotFound.
+func (t *Table[T]) Delete(key any) error {
+	keyBytes, err := encodeField(t.key, key)
+	if err != nil {
+		return err
+	}
+
+	return t.ref.Delete(&keyBytes)
+}
+
+// Query returns every record whose indexed field equals value, in key order.
+func (t *Table[T]) Query(field string, value any) ([]T, error) {
+	idx, ok := t.indexes[field]
+	if !ok {
+		return nil, fmt.Errorf("field %q is not indexed", field)
+	}
+
+	from, err := encodeField(idx.field, value)
+	if err != nil {
+		return nil, err
+	}
+	// The only encoded value in [from, from+"\x00") is from itself.
+	to := append(from[:len(from):len(from)], 0)
+
+	var records []T
+	err = idx.index.Range(&from, &to, func(_ *[]byte, val *T) error {
+		records = append(records, *val)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// encodeField encodes v as a value of field. v may be of any type whose
+// ordered encoding is that of the field's type, e.g. an int for an int64
+// field.
+func encodeField(field reflect.StructField, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, errors.New("cannot encode nil")
+	}
+	if kindClass(rv.Kind()) != kindClass(field.Type.Kind()) || (kindClass(rv.Kind()) == reflect.Invalid && rv.Type() != field.Type) {
+		return nil, fmt.Errorf("%T is not a valid value for field %s of type %s", v, field.Name, field.Type)
+	}
+
+	enc, err := appendOrdered(nil, rv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", field.Name, err)
+	}
+
+	return enc, nil
+}
+
+// kindClass maps kinds whose ordered encodings are interchangeable to the
+// same kind, and composite kinds, whose types must match exactly, to Invalid.
+func kindClass(kind reflect.Kind) reflect.Kind {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint64
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	case reflect.Array, reflect.Slice, reflect.Pointer, reflect.Struct:
+		return reflect.Invalid
+	}
+
+	return kind
+}
@@ -0,0 +1,121 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type member struct {
+	ID    int64  `ezdb:"key"`
+	Email string `ezdb:"unique"`
+	Team  string `ezdb:"index"`
+	Note  string
+}
+
+func TestTable(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	tbl, err := ezdb.NewTable[member](db, "members")
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+
+	for _, m := range []member{
+		{ID: 3, Email: "c@example.com", Team: "db"},
+		{ID: 1, Email: "a@example.com", Team: "db"},
+		{ID: 2, Email: "b@example.com", Team: "web"},
+	} {
+		m := m
+		err = tbl.Save(&m)
+		if err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// Keys may be given as any integer type.
+	got, err := tbl.Load(2)
+	if err != nil || got.Email != "b@example.com" {
+		t.Fatalf("Load(2) = %v, %v", got, err)
+	}
+	got, err = tbl.Load(uint8(3))
+	if err == nil {
+		t.Fatalf("Load of an unsigned key = %v, want an error", got)
+	}
+
+	team, err := tbl.Query("Team", "db")
+	if err != nil || len(team) != 2 || team[0].ID != 1 || team[1].ID != 3 {
+		t.Fatalf("Query(Team, db) = %v, %v", team, err)
+	}
+	byEmail, err := tbl.Query("Email", "b@example.com")
+	if err != nil || len(byEmail) != 1 || byEmail[0].ID != 2 {
+		t.Fatalf("Query(Email) = %v, %v", byEmail, err)
+	}
+	_, err = tbl.Query("Note", "x")
+	if err == nil {
+		t.Fatal("Query of an unindexed field succeeded")
+	}
+	_, err = tbl.Query("Team", 7)
+	if err == nil {
+		t.Fatal("Query with a value of the wrong type succeeded")
+	}
+
+	dup := member{ID: 4, Email: "a@example.com"}
+	err = tbl.Save(&dup)
+	if !errors.Is(err, ezdb.ErrDuplicate) {
+		t.Fatalf("Save of a duplicate unique field returned %v, want ErrDuplicate", err)
+	}
+
+	err = tbl.Delete(1)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err = tbl.Load(1)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Load after Delete returned %v, want ErrNotFound", err)
+	}
+	err = tbl.Delete(1)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("second Delete returned %v, want ErrNotFound", err)
+	}
+	team, err = tbl.Query("Team", "db")
+	if err != nil || len(team) != 1 || team[0].ID != 3 {
+		t.Fatalf("Query(Team, db) after Delete = %v, %v", team, err)
+	}
+}
+
+func TestNewTableRejectsInvalidTypes(t *testing.T) {
+	db := testutil.NewTempClient(t)
+
+	type noKey struct {
+		Name string `ezdb:"index"`
+	}
+	type twoKeys struct {
+		A int `ezdb:"key"`
+		B int `ezdb:"key"`
+	}
+	type unknownTag struct {
+		A int `ezdb:"key"`
+		B int `ezdb:"primary"`
+	}
+	type unexported struct {
+		A int `ezdb:"key"`
+		b int `ezdb:"index"`
+	}
+	type unsupported struct {
+		A map[string]int `ezdb:"key"`
+	}
+	for name, open := range map[string]func() error{
+		"not a struct": func() error { _, err := ezdb.NewTable[int](db, "t"); return err },
+		"no key":       func() error { _, err := ezdb.NewTable[noKey](db, "t"); return err },
+		"two keys":     func() error { _, err := ezdb.NewTable[twoKeys](db, "t"); return err },
+		"unknown tag":  func() error { _, err := ezdb.NewTable[unknownTag](db, "t"); return err },
+		"unexported":   func() error { _, err := ezdb.NewTable[unexported](db, "t"); return err },
+		"unsupported":  func() error { _, err := ezdb.NewTable[unsupported](db, "t"); return err },
+	} {
+		if open() == nil {
+			t.Errorf("NewTable of a type with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package testutil helps unit-test code that uses ezdb.
+package testutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// Fake is an ezdb.KV that keeps its entries in a map, for unit tests of code
+// that depends on an ezdb.KV, without an environment. ForEach visits the
+// entries in the order their keys were first put, rather than in the order of
+// their encoding as a DBRef does. Values are copied in and out, so callers
+// can't change stored values through the pointers they pass or get back. It
+// is safe for concurrent use.
+type Fake[K comparable, V any] struct {
+	// Err, if set, is called before every operation with the name of the
+	// method ("Get", "TryGet", "Put", "Delete" or "ForEach") and its key, nil
+	// for ForEach. If it returns an error, the operation fails with it, which
+	// lets tests exercise error paths.
+	Err func(op string, key *K) error
+
+	name string
+	mu   sync.Mutex
+	keys []K
+	vals map[K]V
+}
+
+var _ ezdb.KV[string, string] = (*Fake[string, string])(nil)
+
+// NewFake returns an empty Fake named name.
+func NewFake[K comparable, V any](name string) *Fake[K, V] {
+	return &Fake[K, V]{name: name, vals: make(map[K]V)}
+}
+
+func (f *Fake[K, V]) fail(op string, key *K) error {
+	if f.Err == nil {
+		return nil
+	}
+
+	return f.Err(op, key)
+}
+
+// Name returns the name of f.
+func (f *Fake[K, V]) Name() string {
+	return f.name
+}
+
+// Get returns the value stored under key. A missing key is reported as an
+// error matching ezdb.ErrNotFound.
+func (f *Fake[K, V]) Get(key *K) (*V, error) {
+	err := f.fail("Get", key)
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := f.get(key)
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ezdb.ErrNotFound)
+	}
+
+	return val, nil
+}
+
+// TryGet returns the value stored under key and whether it was found.
+func (f *Fake[K, V]) TryGet(key *K) (*V, bool, error) {
+	err := f.fail("TryGet", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	val, ok := f.get(key)
+	return val, ok, nil
+}
+
+func (f *Fake[K, V]) get(key *K) (*V, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	val, ok := f.vals[*key]
+	if !ok {
+		return nil, false
+	}
+
+	return &val, true
+}
+
+// Put stores val under key, replacing any existing value.
+func (f *Fake[K, V]) Put(key *K, val *V) error {
+	err := f.fail("Put", key)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.vals[*key]; !ok {
+		f.keys = append(f.keys, *key)
+	}
+	f.vals[*key] = *val
+	return nil
+}
+
+// Delete deletes key. A missing key is reported as an error matching
+// ezdb.ErrNotFound.
+func (f *Fake[K, V]) Delete(key *K) error {
+	err := f.fail("Delete", key)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.vals[*key]; !ok {
+		return fmt.Errorf("failed to delete key: %w", ezdb.ErrNotFound)
+	}
+	delete(f.vals, *key)
+	for i, k := range f.keys {
+		if k == *key {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// ForEach calls fn with every entry of f until fn returns an error, which
+// ForEach returns. It iterates over a copy of the entries taken when it is
+// called, so fn may write to f.
+func (f *Fake[K, V]) ForEach(fn func(key *K, val *V) error) error {
+	err := f.fail("ForEach", nil)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	keys := append([]K(nil), f.keys...)
+	vals := make([]V, len(keys))
+	for i, k := range keys {
+		vals[i] = f.vals[k]
+	}
+	f.mu.Unlock()
+
+	for i := range keys {
+		err = fn(&keys[i], &vals[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of entries in f.
+func (f *Fake[K, V]) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.keys)
+}
@@ -0,0 +1,186 @@
+package testutil_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// exercise runs the same calls on kv whether it is a DBRef or a Fake.
+func exercise(t *testing.T, kv ezdb.KV[string, []int]) {
+	t.Helper()
+
+	if kv.Name() != "kv" {
+		t.Fatalf("Name = %q", kv.Name())
+	}
+	for i, key := range []string{"b", "a", "c"} {
+		val := []int{i}
+		err := kv.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	key := "a"
+	got, err := kv.Get(&key)
+	if err != nil || fmt.Sprint(*got) != "[1]" {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+
+	key = "d"
+	_, err = kv.Get(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Get of a missing key returned %v, want ErrNotFound", err)
+	}
+	_, ok, err := kv.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a missing key = %t, %v", ok, err)
+	}
+	err = kv.Delete(&key)
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("Delete of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	key = "b"
+	err = kv.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	n := 0
+	err = kv.ForEach(func(key *string, val *[]int) error {
+		if *key == "b" {
+			return fmt.Errorf("ForEach visited deleted key %s", *key)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("ForEach visited %d entries: %v", n, err)
+	}
+
+	errStop := errors.New("stop")
+	err = kv.ForEach(func(*string, *[]int) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ForEach returned %v, want the error of fn", err)
+	}
+}
+
+func TestFake(t *testing.T) {
+	t.Run("DBRef", func(t *testing.T) {
+		ref, err := ezdb.NewDBRef[string, []int](testutil.NewMemoryClient(t), "kv", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+		if err != nil {
+			t.Fatalf("NewDBRef: %v", err)
+		}
+		exercise(t, ref)
+	})
+	t.Run("Fake", func(t *testing.T) {
+		f := testutil.NewFake[string, []int]("kv")
+		exercise(t, f)
+		if f.Len() != 2 {
+			t.Fatalf("Len = %d, want 2", f.Len())
+		}
+	})
+}
+
+func TestFakeOrder(t *testing.T) {
+	f := testutil.NewFake[string, int]("kv")
+	for i, key := range []string{"c", "a", "b", "a"} {
+		err := f.Put(&key, &i)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	key := "c"
+	err := f.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	err = f.Put(&key, new(int))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Keys are visited in the order they were first put since they were
+	// last deleted, and fn may write to f.
+	var order []string
+	err = f.ForEach(func(key *string, val *int) error {
+		order = append(order, fmt.Sprintf("%s=%d", *key, *val))
+		return f.Delete(key)
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	if fmt.Sprint(order) != "[a=3 b=2 c=0]" {
+		t.Fatalf("ForEach visited %v", order)
+	}
+	if f.Len() != 0 {
+		t.Fatalf("Len after deleting everything = %d", f.Len())
+	}
+}
+
+func TestFakeCopies(t *testing.T) {
+	f := testutil.NewFake[string, int]("kv")
+	key, val := "a", 1
+	err := f.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	val = 2
+	got, err := f.Get(&key)
+	if err != nil || *got != 1 {
+		t.Fatalf("Get after changing the value put = %v, %v", got, err)
+	}
+	*got = 3
+	got, _, err = f.TryGet(&key)
+	if err != nil || *got != 1 {
+		t.Fatalf("TryGet after changing the value got = %v, %v", got, err)
+	}
+}
+
+func TestFakeErr(t *testing.T) {
+	f := testutil.NewFake[string, int]("kv")
+	errInjected := errors.New("injected")
+	var ops []string
+	f.Err = func(op string, key *string) error {
+		k := "<nil>"
+		if key != nil {
+			k = *key
+		}
+		ops = append(ops, op+" "+k)
+		if op == "Put" && *key == "bad" {
+			return errInjected
+		}
+		if op == "ForEach" {
+			return errInjected
+		}
+		return nil
+	}
+
+	key, val := "bad", 1
+	err := f.Put(&key, &val)
+	if !errors.Is(err, errInjected) || f.Len() != 0 {
+		t.Fatalf("Put of a failing key returned %v and stored %d entries", err, f.Len())
+	}
+	key = "good"
+	err = f.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.Get(&key)
+	f.TryGet(&key)
+	f.Delete(&key)
+	err = f.ForEach(func(*string, *int) error {
+		t.Fatal("ForEach called fn although it failed")
+		return nil
+	})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("ForEach returned %v, want the injected error", err)
+	}
+
+	want := "[Put bad Put good Get good TryGet good Delete good ForEach <nil>]"
+	if fmt.Sprint(ops) != want {
+		t.Fatalf("Err saw %v, want %s", ops, want)
+	}
+}
@@ -0,0 +1,69 @@
+package testutil
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+var updateGolden = flag.Bool("ezdb.update", false, "rewrite the golden files of testutil.Golden")
+
+// Golden compares the full contents of ref with the golden file at path,
+// failing t with the first differing entry if they don't match. The contents
+// are written in ezdb.FormatJSONLines, in the order of the encoded keys, so
+// they only change when the data does. If the file doesn't exist, or the
+// test runs with -ezdb.update, Golden writes it instead, creating its
+// directory as needed. It makes code that migrates or transforms data
+// regression-testable: run it on fixed input and compare the result.
+func Golden[K, V any](t testing.TB, ref *ezdb.DBRef[K, V], path string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := ref.Export(&buf, ezdb.FormatJSONLines)
+	if err != nil {
+		t.Fatalf("failed to export %s: %v", ref.Name(), err)
+	}
+	got := buf.Bytes()
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) || *updateGolden {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, got, 0o644)
+		}
+		if err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		t.Logf("wrote golden file %s", path)
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Fatalf("%s differs from golden file %s at line %d:\n got: %s\nwant: %s\nrun with -ezdb.update to accept the change",
+				ref.Name(), path, i+1, g, w)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package testutil_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestGolden(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, int](testutil.NewMemoryClient(t), "counts", ezdb.WithKeyCodec(ezdb.StringCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	put := func(key string, val int) {
+		t.Helper()
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	put("b", 2)
+	put("a", 1)
+
+	// A missing file is written, along with its directory.
+	path := filepath.Join(t.TempDir(), "testdata", "counts.jsonl")
+	testutil.Golden(t, ref, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 || !strings.Contains(string(data), `"a"`) {
+		t.Fatalf("golden file holds %q", data)
+	}
+	testutil.Golden(t, ref, path)
+
+	// Changes fail the test at the first line that differs.
+	put("a", 10)
+	put("c", 3)
+	tb := &fatalTB{TB: t}
+	tb.run(func(tb testing.TB) { testutil.Golden(tb, ref, path) })
+	if !strings.Contains(tb.failure, "at line 1:") || !strings.Contains(tb.failure, "-ezdb.update") {
+		t.Fatalf("failure %q", tb.failure)
+	}
+
+	// Entries added at the end are differences too.
+	put("a", 1)
+	tb = &fatalTB{TB: t}
+	tb.run(func(tb testing.TB) { testutil.Golden(tb, ref, path) })
+	if !strings.Contains(tb.failure, "at line 3:") {
+		t.Fatalf("failure %q", tb.failure)
+	}
+
+	// -ezdb.update accepts them.
+	err = flag.Set("ezdb.update", "true")
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	testutil.Golden(t, ref, path)
+	err = flag.Set("ezdb.update", "false")
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	testutil.Golden(t, ref, path)
+
+	// Golden files that can't be read fail the test.
+	tb = &fatalTB{TB: t}
+	tb.run(func(tb testing.TB) { testutil.Golden(tb, ref, t.TempDir()) })
+	if !strings.HasPrefix(tb.failure, "failed to read golden file") {
+		t.Fatalf("failure %q", tb.failure)
+	}
+}
@@ -0,0 +1,68 @@
+package testutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+)
+
+// tempNumDBs is the number of named databases of a Client from
+// NewTempClient, enough for DBRefs with indexes, TTLs and the other features
+// that keep bookkeeping in databases of their own.
+const tempNumDBs = 128
+
+// NewTempClient returns an initialized Client with an environment of its own
+// in t.TempDir(), closed when the test and its subtests finish, after which
+// the testing package removes the directory. It allows tempNumDBs named
+// databases and 32 concurrent readers; opts are applied afterwards and may
+// override them. LMDB grows the map as it fills, so tests don't need to size
+// it. Failures to open the environment fail the test with t.Fatal.
+func NewTempClient(t testing.TB, opts ...ezdb.Option) *ezdb.Client {
+	t.Helper()
+
+	opts = append([]ezdb.Option{ezdb.WithNumDBs(tempNumDBs), ezdb.WithNumReaders(32)}, opts...)
+	db, err := ezdb.New(t.TempDir(), opts...)
+	return open(t, db, err)
+}
+
+// NewMemoryClient is NewTempClient for a Client from ezdb.NewMemory, for
+// tests that don't need LMDB's files.
+func NewMemoryClient(t testing.TB, opts ...ezdb.Option) *ezdb.Client {
+	t.Helper()
+
+	db, err := ezdb.NewMemory(opts...)
+	return open(t, db, err)
+}
+
+// NewPebbleClient is NewTempClient for a Client from ezdb.NewPebble, for
+// tests of code that has to run on Pebble as well as on LMDB.
+func NewPebbleClient(t testing.TB, opts ...ezdb.Option) *ezdb.Client {
+	t.Helper()
+
+	db, err := ezdb.NewPebble(t.TempDir(), opts...)
+	return open(t, db, err)
+}
+
+// open initializes db, as returned with err by a constructor, and closes it
+// when t finishes.
+func open(t testing.TB, db *ezdb.Client, err error) *ezdb.Client {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatalf("failed to open environment: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err := db.Close()
+		if err != nil && !errors.Is(err, ezdb.ErrClosed) {
+			t.Errorf("failed to close client: %v", err)
+		}
+	})
+
+	return db
+}
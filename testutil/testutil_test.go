@@ -0,0 +1,111 @@
+package testutil_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// fatalTB is a testing.TB that records its first fatal failure instead of
+// failing the test, and runs its cleanups when told to.
+type fatalTB struct {
+	testing.TB
+	failure  string
+	cleanups []func()
+}
+
+func (tb *fatalTB) Helper() {}
+
+func (tb *fatalTB) Cleanup(fn func()) {
+	tb.cleanups = append(tb.cleanups, fn)
+}
+
+func (tb *fatalTB) Fatalf(format string, args ...any) {
+	tb.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls fn with tb in a goroutine of its own, as t.Fatalf ends the
+// goroutine that calls it, and then runs the cleanups fn registered.
+func (tb *fatalTB) run(fn func(tb testing.TB)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(tb)
+	}()
+	<-done
+
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func TestNewTempClient(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		open func(tb testing.TB, opts ...ezdb.Option) *ezdb.Client
+	}{
+		{"temp", testutil.NewTempClient},
+		{"memory", testutil.NewMemoryClient},
+		{"pebble", testutil.NewPebbleClient},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var db *ezdb.Client
+			t.Run("open", func(t *testing.T) {
+				db = test.open(t)
+
+				// There is room for the databases of many DBRefs.
+				for i := 0; i < 100; i++ {
+					_, err := ezdb.NewDBRef[string, string](db, fmt.Sprint("ref", i))
+					if err != nil {
+						t.Fatalf("NewDBRef: %v", err)
+					}
+				}
+			})
+
+			// The Client is closed once the test that opened it finishes.
+			err := db.Ping(context.Background())
+			if !errors.Is(err, ezdb.ErrClosed) {
+				t.Fatalf("Ping after the test returned %v, want ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestNewTempClientFailures(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		open func(tb testing.TB)
+		want string
+	}{
+		{"New", func(tb testing.TB) {
+			testutil.NewTempClient(tb, ezdb.WithFlushInterval(time.Second), ezdb.WithLatencyTarget(time.Second))
+		}, "failed to create client"},
+		{"Init", func(tb testing.TB) {
+			testutil.NewTempClient(tb, ezdb.WithNoCreate())
+		}, "failed to open environment"},
+	} {
+		tb := &fatalTB{TB: t}
+		tb.run(test.open)
+		if !strings.HasPrefix(tb.failure, test.want) {
+			t.Errorf("%s: failure %q, want %q", test.name, tb.failure, test.want)
+		}
+	}
+
+	// Closing a Client in the test doesn't make its cleanup fail the test,
+	// which would report it through the embedded t.
+	tb := &fatalTB{TB: t}
+	tb.run(func(tb testing.TB) {
+		err := testutil.NewMemoryClient(tb).Close()
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
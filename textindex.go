@@ -0,0 +1,69 @@
+package ezdb
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TextIndex is an inverted index of the words of a string derived from the
+// values of a DBRef, supporting exact and prefix word lookups. Words are
+// maximal runs of letters and digits, and are matched case-insensitively.
+type TextIndex[K, V any] struct {
+	index *Index[string, K, V]
+}
+
+// NewTextIndex adds a text index named name to ref over the string text
+// returns for every value. Like NewIndex, it builds the index from the
+// entries already in ref if it doesn't exist yet.
+func NewTextIndex[K, V any](ref *DBRef[K, V], name string, text func(val *V) string) (*TextIndex[K, V], error) {
+	idx, err := NewMultiIndex(ref, name, func(val *V) []string {
+		return tokenize(text(val))
+	}, WithIndexCodec(OrderedCodec{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TextIndex[K, V]{index: idx}, nil
+}
+
+// tokenize splits s into lower-cased words.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Search returns the primary keys of all values containing the word term.
+func (t *TextIndex[K, V]) Search(term string) ([]K, error) {
+	term = strings.ToLower(term)
+	return t.index.Keys(&term)
+}
+
+// SearchPrefix returns the primary keys of all values containing a word that
+// starts with prefix, each key once, ordered by the first such word.
+func (t *TextIndex[K, V]) SearchPrefix(prefix string) (keys []K, err error) {
+	from := orderedPrefix(strings.ToLower(prefix))
+
+	err = t.index.ref.ownerDB.view(func(txn readTxn) error {
+		seen := make(map[string]bool)
+		return t.index.scanEntries(txn, from, prefixEnd(from), func(_, pk []byte) error {
+			if seen[string(pk)] {
+				return nil
+			}
+			seen[string(pk)] = true
+
+			key, err := t.index.ref.decodeKey(pk)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			keys = append(keys, *key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
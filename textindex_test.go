@@ -0,0 +1,77 @@
+package ezdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestTextIndex(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("docs", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	put := func(key, val string) {
+		t.Helper()
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	// Entries from before the index was added are indexed too.
+	put("a", "The quick brown fox")
+	idx, err := ezdb.NewTextIndex(ref, "words", func(val *string) string { return *val })
+	if err != nil {
+		t.Fatalf("NewTextIndex: %v", err)
+	}
+	put("b", "Quicker, quicker: the fox-hunt!")
+	put("c", "A lazy dog, a brown dog")
+
+	search := func(term string) string {
+		t.Helper()
+		keys, err := idx.Search(term)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		return fmt.Sprint(keys)
+	}
+	searchPrefix := func(prefix string) string {
+		t.Helper()
+		keys, err := idx.SearchPrefix(prefix)
+		if err != nil {
+			t.Fatalf("SearchPrefix: %v", err)
+		}
+		return fmt.Sprint(keys)
+	}
+
+	for term, want := range map[string]string{"fox": "[a b]", "FOX": "[a b]", "dog": "[c]", "hunt": "[b]", "qu": "[]"} {
+		if got := search(term); got != want {
+			t.Errorf("Search(%q) = %s, want %s", term, got, want)
+		}
+	}
+	for prefix, want := range map[string]string{"qu": "[a b]", "Quicker": "[b]", "b": "[a c]", "x": "[]"} {
+		if got := searchPrefix(prefix); got != want {
+			t.Errorf("SearchPrefix(%q) = %s, want %s", prefix, got, want)
+		}
+	}
+
+	// Updates and deletes keep the index in step.
+	put("a", "A slow red fox")
+	key := "b"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := search("fox"); got != "[a]" {
+		t.Errorf("Search(fox) after Delete = %s", got)
+	}
+	if got := search("quick"); got != "[]" {
+		t.Errorf("Search(quick) after the update = %s", got)
+	}
+	if got := searchPrefix("s"); got != "[a]" {
+		t.Errorf("SearchPrefix(s) after the update = %s", got)
+	}
+}
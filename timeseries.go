@@ -0,0 +1,201 @@
+package ezdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimeSeries stores the points of one named series in fixed-width time
+// buckets, one record per bucket, keyed by series name and bucket start with
+// OrderedCodec. Several series may share one named database.
+type TimeSeries[V any] struct {
+	ref    *DBRef[seriesKey, []Point[V]]
+	series string
+	bucket time.Duration
+}
+
+// seriesKey is the key of a bucket of a series.
+type seriesKey struct {
+	Series string
+	Start  time.Time
+}
+
+// Point is a value of a TimeSeries at a point in time.
+type Point[V any] struct {
+	Time  time.Time
+	Value V
+}
+
+// NewTimeSeries opens the series named series stored in the named database
+// name of db, creating the database if it doesn't exist. Points are grouped
+// into buckets of width bucket, which should stay the same for the lifetime
+// of the series. opts are the same as for NewDBRef; the key codec can't be
+// changed.
+func NewTimeSeries[V any](db *Client, name, series string, bucket time.Duration, opts ...RefOption) (*TimeSeries[V], error) {
+	if bucket <= 0 {
+		return nil, errors.New("bucket width must be positive")
+	}
+
+	ref, err := NewDBRef[seriesKey, []Point[V]](db, name, append(opts, WithKeyCodec(OrderedCodec{}))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimeSeries[V]{ref: ref, series: series, bucket: bucket}, nil
+}
+
+func (ts *TimeSeries[V]) key(t time.Time) seriesKey {
+	return seriesKey{Series: ts.series, Start: t.Truncate(ts.bucket).UTC()}
+}
+
+// Append adds a point with value v at time t to the series. Points of a
+// bucket are kept in time order, so t may be earlier than the last point.
+func (ts *TimeSeries[V]) Append(t time.Time, v V) error {
+	key := ts.key(t)
+	keyBytes, err := ts.ref.encodeKey(&key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	return ts.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ts.ref.id, ts.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		points, err := ts.ref.getInTxn(txn, dbRef, keyBytes)
+		if err != nil {
+			return err
+		}
+		if points == nil {
+			points = new([]Point[V])
+		}
+
+		i := sort.Search(len(*points), func(i int) bool { return (*points)[i].Time.After(t) })
+		*points = append(*points, Point[V]{})
+		copy((*points)[i+1:], (*points)[i:])
+		(*points)[i] = Point[V]{Time: t, Value: v}
+
+		return ts.writeInTxn(txn, dbRef, &key, keyBytes, points)
+	})
+}
+
+// writeInTxn validates, encodes and writes the bucket under key.
+func (ts *TimeSeries[V]) writeInTxn(txn writeTxn, dbRef dbi, key *seriesKey, keyBytes []byte, points *[]Point[V]) error {
+	err := ts.ref.validate(key, points)
+	if err != nil {
+		return err
+	}
+
+	valBytes, err := ts.ref.encodeVal(points)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	err = ts.ref.checkSizes(len(keyBytes), len(valBytes))
+	if err != nil {
+		return err
+	}
+
+	return ts.ref.putInTxn(txn, dbRef, keyBytes, valBytes, points, putFlag(0))
+}
+
+// Range returns the points of the series in [from, to), in time order.
+func (ts *TimeSeries[V]) Range(from, to time.Time) (points []Point[V], err error) {
+	start, end := ts.key(from), ts.key(to)
+	end.Start = end.Start.Add(ts.bucket)
+
+	err = ts.ref.Query().WhereRange(&start, &end).Each(func(_ *seriesKey, bucket *[]Point[V]) error {
+		for _, p := range *bucket {
+			if !p.Time.Before(from) && p.Time.Before(to) {
+				points = append(points, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// Downsample replaces the points of every bucket that ends by before with
+// the points fn returns for them, e.g. a single point holding their average,
+// in one write transaction. Buckets fn returns no points for are deleted.
+func (ts *TimeSeries[V]) Downsample(before time.Time, fn func(points []Point[V]) []Point[V]) error {
+	return ts.rewrite(before, fn)
+}
+
+// DeleteBefore deletes every bucket of the series that ends by before,
+// to enforce a retention period.
+func (ts *TimeSeries[V]) DeleteBefore(before time.Time) error {
+	return ts.rewrite(before, func([]Point[V]) []Point[V] { return nil })
+}
+
+// rewrite passes every bucket ending by before through fn in one write
+// transaction.
+func (ts *TimeSeries[V]) rewrite(before time.Time, fn func(points []Point[V]) []Point[V]) error {
+	// The last bucket to rewrite is the one before the bucket before falls
+	// into, and the first is the first bucket of the series.
+	last := ts.key(before)
+	last.Start = last.Start.Add(-ts.bucket)
+	to, err := OrderedCodec{}.Marshal(&last)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	from := appendEscaped(nil, []byte(ts.series))
+
+	return ts.ref.ownerDB.update(func(txn writeTxn) error {
+		dbRef, err := txn.DBRef(ts.ref.id, ts.ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		var buckets []record
+		err = func() error {
+			cursor, err := txn.NewCursor(dbRef)
+			if err != nil {
+				return fmt.Errorf("failed to open cursor: %w", err)
+			}
+			defer cursor.Close()
+
+			keyBytes, valBytes, err := cursor.SeekGreaterThanOrEqualKey(from)
+			for ; err == nil && bytes.Compare(keyBytes, to) <= 0; keyBytes, valBytes, err = cursor.Next() {
+				buckets = append(buckets, record{key: bytes.Clone(keyBytes), val: bytes.Clone(valBytes)})
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("failed to read entry: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range buckets {
+			key, err := ts.ref.decodeKey(rec.key)
+			if err != nil {
+				return fmt.Errorf("failed to decode key: %w", err)
+			}
+			points, err := ts.ref.decodeVal(rec.val)
+			if err != nil {
+				return fmt.Errorf("failed to decode value: %w", err)
+			}
+
+			replaced := fn(*points)
+			if len(replaced) == 0 {
+				err = ts.ref.deleteInTxn(txn, dbRef, rec.key)
+			} else {
+				err = ts.writeInTxn(txn, dbRef, key, rec.key, &replaced)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
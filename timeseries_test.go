@@ -0,0 +1,95 @@
+package ezdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestTimeSeries(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	temp, err := ezdb.NewTimeSeries[float64](db, "metrics", "temp", time.Hour)
+	if err != nil {
+		t.Fatalf("NewTimeSeries: %v", err)
+	}
+	// Another series in the same database, whose name starts like temp's.
+	tempo, err := ezdb.NewTimeSeries[float64](db, "metrics", "tempo", time.Hour)
+	if err != nil {
+		t.Fatalf("NewTimeSeries: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	for _, m := range []int{0, 30, 20, 70, 150, 10} {
+		err = temp.Append(at(m), float64(m))
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		err = tempo.Append(at(m), -1)
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	check := func(desc string, points []ezdb.Point[float64], want ...int) {
+		t.Helper()
+		if len(points) != len(want) {
+			t.Fatalf("%s = %v, want the points at %v", desc, points, want)
+		}
+		for i, m := range want {
+			if !points[i].Time.Equal(at(m)) || points[i].Value != float64(m) {
+				t.Fatalf("%s = %v, want the points at %v", desc, points, want)
+			}
+		}
+	}
+	points, err := temp.Range(at(0), at(180))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	check("Range of everything", points, 0, 10, 20, 30, 70, 150)
+	points, err = temp.Range(at(20), at(70))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	check("Range(20, 70)", points, 20, 30)
+
+	// Average the first hour into one point.
+	err = temp.Downsample(at(60), func(points []ezdb.Point[float64]) []ezdb.Point[float64] {
+		var sum float64
+		for _, p := range points {
+			sum += p.Value
+		}
+		return []ezdb.Point[float64]{{Time: at(15), Value: sum / float64(len(points))}}
+	})
+	if err != nil {
+		t.Fatalf("Downsample: %v", err)
+	}
+	points, err = temp.Range(at(0), at(180))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	check("Range after Downsample", points, 15, 70, 150)
+
+	// Buckets that don't end by before are kept.
+	err = temp.DeleteBefore(at(130))
+	if err != nil {
+		t.Fatalf("DeleteBefore: %v", err)
+	}
+	points, err = temp.Range(at(0), at(180))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	check("Range after DeleteBefore", points, 150)
+
+	points, err = tempo.Range(at(0), at(180))
+	if err != nil || len(points) != 6 {
+		t.Fatalf("Range of the other series = %v, %v", points, err)
+	}
+
+	_, err = ezdb.NewTimeSeries[float64](db, "metrics", "bad", 0)
+	if err == nil {
+		t.Fatal("NewTimeSeries with a zero bucket width succeeded")
+	}
+}
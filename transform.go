@@ -0,0 +1,82 @@
+package ezdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Transform rewrites the encodings of the values of a DBRef between its
+// codec and storage, see WithTransform.
+type Transform struct {
+	// Encode rewrites the codec's encoding of a value before it is stored, if
+	// set.
+	Encode func(data []byte) ([]byte, error)
+	// Decode rewrites the encoding of a stored value before the codec decodes
+	// it, if set.
+	Decode func(data []byte) ([]byte, error)
+}
+
+// WithTransform adds t to the transforms of the DBRef's values: Encode runs
+// on the codec's encoding before compression and checksums, and Decode on the
+// stored encoding, once those are undone, before the codec decodes it. Later
+// transforms encode after and decode before earlier ones. A transform with
+// only Decode suits a DBRef reading a database written by another, e.g. to
+// redact or tokenise the PII fields of its values for a specific consumer, or
+// to map legacy field names to current ones; one that also encodes changes
+// what is stored, so all DBRefs of the database need it. Values aren't
+// encoded in place, see SizedCodec, while a transform encodes.
+func WithTransform(t Transform) RefOption {
+	return func(option *refOptions) error {
+		if t.Encode == nil && t.Decode == nil {
+			return errors.New("transform must encode or decode")
+		}
+		option.transforms = append(option.transforms, t)
+		return nil
+	}
+}
+
+// encodes reports whether a transform of the DBRef changes what is stored.
+func (o *refOptions) encodes() bool {
+	for _, t := range o.transforms {
+		if t.Encode != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodeTransforms runs the Encode functions of the DBRef's transforms on
+// data, in order.
+func (o *refOptions) encodeTransforms(data []byte) ([]byte, error) {
+	for i, t := range o.transforms {
+		if t.Encode == nil {
+			continue
+		}
+		var err error
+		data, err = t.Encode(data)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d failed: %w", i, err)
+		}
+	}
+
+	return data, nil
+}
+
+// decodeTransforms runs the Decode functions of the DBRef's transforms on
+// data, in reverse order.
+func (o *refOptions) decodeTransforms(data []byte) ([]byte, error) {
+	for i := len(o.transforms) - 1; i >= 0; i-- {
+		t := o.transforms[i]
+		if t.Decode == nil {
+			continue
+		}
+		var err error
+		data, err = t.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d failed: %w", i, err)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,125 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// suffix is a transform appending s to the stored values.
+func suffix(s string) ezdb.Transform {
+	return ezdb.Transform{
+		Encode: func(data []byte) ([]byte, error) {
+			return append(bytes.Clone(data), s...), nil
+		},
+		Decode: func(data []byte) ([]byte, error) {
+			trimmed, ok := bytes.CutSuffix(data, []byte(s))
+			if !ok {
+				return nil, fmt.Errorf("%q lacks the suffix %q", data, s)
+			}
+			return trimmed, nil
+		},
+	}
+}
+
+// upper is a transform changing the values read to upper case.
+var upper = ezdb.Transform{Decode: func(data []byte) ([]byte, error) {
+	return bytes.ToUpper(data), nil
+}}
+
+func TestTransform(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			ref := bytesRef(t, db, "ref", ezdb.WithTransform(suffix("1")), ezdb.WithTransform(suffix("2")))
+			key, val := "a", []byte("abc")
+			err := ref.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			got, err := ref.Get(&key)
+			if err != nil || string(*got) != "abc" {
+				t.Fatalf("Get = %q, %v", got, err)
+			}
+
+			// Later transforms encode last and decode first.
+			got, err = bytesRef(t, db, "ref").Get(&key)
+			if err != nil || string(*got) != "abc12" {
+				t.Fatalf("Get of the stored value = %q, %v", got, err)
+			}
+
+			// DBRefs that only decode read what others wrote, and write it
+			// unchanged.
+			reader := bytesRef(t, db, "ref", ezdb.WithTransform(upper))
+			got, err = reader.Get(&key)
+			if err != nil || string(*got) != "ABC12" {
+				t.Fatalf("Get through a decoding transform = %q, %v", got, err)
+			}
+			key, val = "b", []byte("def")
+			err = reader.Put(&key, &val)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			got, err = bytesRef(t, db, "ref").Get(&key)
+			if err != nil || string(*got) != "def" {
+				t.Fatalf("Get of the stored value = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestTransformReadCache(t *testing.T) {
+	db := testutil.NewTempClient(t, ezdb.WithReadCache(1<<20))
+	ref := bytesRef(t, db, "ref")
+	key, val := "a", []byte("abc")
+	err := ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err = ref.Get(&key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// The value cached for ref isn't what the transform reads.
+	got, err := bytesRef(t, db, "ref", ezdb.WithTransform(upper)).Get(&key)
+	if err != nil || string(*got) != "ABC" {
+		t.Fatalf("Get through a transform = %q, %v", got, err)
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	_, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithTransform(ezdb.Transform{}))
+	if err == nil {
+		t.Fatal("NewDBRef with an empty transform succeeded")
+	}
+
+	errTransform := errors.New("transform failed")
+	failing := bytesRef(t, db, "ref", ezdb.WithTransform(ezdb.Transform{
+		Encode: func([]byte) ([]byte, error) { return nil, errTransform },
+		Decode: func([]byte) ([]byte, error) { return nil, errTransform },
+	}))
+	key, val := "a", []byte("abc")
+	err = failing.Put(&key, &val)
+	if !errors.Is(err, errTransform) {
+		t.Fatalf("Put with a failing transform returned %v, want its error", err)
+	}
+	_, ok, err := bytesRef(t, db, "ref").TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet after a failed Put = %t, %v", ok, err)
+	}
+
+	err = bytesRef(t, db, "ref").Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err = failing.Get(&key)
+	if !errors.Is(err, errTransform) {
+		t.Fatalf("Get with a failing transform returned %v, want its error", err)
+	}
+}
@@ -0,0 +1,122 @@
+package ezdb
+
+import "fmt"
+
+// Tx is a write transaction, passed to triggers so they can read and write
+// DBRefs of the same Client atomically with the write that triggered them,
+// and to the functions run by Client.Update. It is only valid until the
+// function it is passed to returns.
+type Tx struct {
+	txn writeTxn
+}
+
+// OnPut registers fn to run inside the transaction of every Put to ref (and
+// to every DBRef made from it, such as by Sub), after the value is written.
+// old is the previous value, or nil if there was none. An error from fn aborts
+// the transaction, so the Put fails and nothing it or fn wrote is kept.
+// Triggers must be registered before ref is used concurrently.
+func (ref *DBRef[K, V]) OnPut(fn func(tx *Tx, key *K, old, val *V) error) {
+	ref.options.onPut = append(ref.options.onPut, fn)
+}
+
+// OnDelete registers fn to run inside the transaction of every Delete from
+// ref, after the value is deleted, including deletions by TTL sweeps and LRU
+// evictions. old is the deleted value. See OnPut.
+func (ref *DBRef[K, V]) OnDelete(fn func(tx *Tx, key *K, old *V) error) {
+	ref.options.onDelete = append(ref.options.onDelete, fn)
+}
+
+func (ref *DBRef[K, V]) hasTriggers() bool {
+	return len(ref.options.onPut) > 0 || len(ref.options.onDelete) > 0
+}
+
+// triggerInTxn runs the triggers for a write of keyBytes. val is nil for a
+// delete.
+func (ref *DBRef[K, V]) triggerInTxn(txn writeTxn, keyBytes []byte, old, val *V) error {
+	triggers := ref.options.onPut
+	if val == nil {
+		triggers = ref.options.onDelete
+	}
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	key, err := ref.decodeKey(keyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to decode key: %w", err)
+	}
+
+	tx := &Tx{txn: txn}
+	for _, trigger := range triggers {
+		if val == nil {
+			err = trigger.(func(*Tx, *K, *V) error)(tx, key, old)
+		} else {
+			err = trigger.(func(*Tx, *K, *V, *V) error)(tx, key, old, val)
+		}
+		if err != nil {
+			return fmt.Errorf("trigger failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetTx is Get within tx.
+func (ref *DBRef[K, V]) GetTx(tx *Tx, key *K) (*V, error) {
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	val, err := ref.getLiveInTxn(tx.txn, keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return val, nil
+}
+
+// PutTx is Put within tx. The triggers of ref run as well.
+func (ref *DBRef[K, V]) PutTx(tx *Tx, key *K, val *V) error {
+	keyBytes, valBytes, err := ref.encodePair(key, val)
+	if err != nil {
+		return err
+	}
+
+	dbRef, err := tx.txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	return ref.putInTxn(tx.txn, dbRef, keyBytes, valBytes, val, putFlag(0))
+}
+
+// DeleteTx is Delete within tx. The triggers of ref run as well.
+func (ref *DBRef[K, V]) DeleteTx(tx *Tx, key *K) error {
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	dbRef, err := tx.txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	return ref.deleteInTxn(tx.txn, dbRef, keyBytes)
+}
+
+// Update runs fn in a write transaction, so the reads and writes it makes
+// through tx, with methods such as GetTx, PutTx and DeleteTx on DBRefs of db,
+// are applied atomically, or not at all if fn returns an error, which Update
+// returns; if fn panics, the transaction is aborted the same way and Update
+// returns a PanicError. fn must not call the methods of DBRefs that don't take
+// tx, which would wait for the transaction and deadlock.
+func (db *Client) Update(fn func(tx *Tx) error) error {
+	return db.update(func(txn writeTxn) error {
+		return fn(&Tx{txn: txn})
+	})
+}
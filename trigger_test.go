@@ -0,0 +1,168 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestTriggers(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, int]("balances", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	totals, err := ezdb.NewRef[string, int]("totals", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	// Keep the sum of all balances in totals, atomically with every write.
+	total := "total"
+	add := func(tx *ezdb.Tx, delta int) error {
+		sum, err := totals.GetTx(tx, &total)
+		if errors.Is(err, ezdb.ErrNotFound) {
+			sum = new(int)
+		} else if err != nil {
+			return err
+		}
+		*sum += delta
+		if *sum < 0 {
+			return errors.New("negative total")
+		}
+		return totals.PutTx(tx, &total, sum)
+	}
+	ref.OnPut(func(tx *ezdb.Tx, key *string, old, val *int) error {
+		delta := *val
+		if old != nil {
+			delta -= *old
+		}
+		return add(tx, delta)
+	})
+	var deleted []string
+	ref.OnDelete(func(tx *ezdb.Tx, key *string, old *int) error {
+		deleted = append(deleted, *key)
+		return add(tx, -*old)
+	})
+
+	wantTotal := func(want int) {
+		t.Helper()
+		sum, err := totals.Get(&total)
+		if err != nil || *sum != want {
+			t.Fatalf("total = %v, %v, want %d", sum, err, want)
+		}
+	}
+	put := func(ref *ezdb.DBRef[string, int], key string, val int) error {
+		return ref.Put(&key, &val)
+	}
+
+	err = put(ref, "a", 10)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = put(ref, "b", 5)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = put(ref, "a", 3)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	wantTotal(8)
+
+	// Triggers apply to namespaces too.
+	err = put(ref.Sub("tenant"), "c", 2)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	wantTotal(10)
+
+	key := "b"
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "b" {
+		t.Fatalf("OnDelete saw %v", deleted)
+	}
+	wantTotal(5)
+
+	// Writes made through a Tx run the triggers of their DBRef as well.
+	err = db.Update(func(tx *ezdb.Tx) error {
+		key, val := "e", 7
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		key = "a"
+		return ref.DeleteTx(tx, &key)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	wantTotal(9)
+
+	// A failing trigger aborts the write.
+	err = put(ref, "d", -100)
+	if err == nil {
+		t.Fatal("Put rejected by a trigger succeeded")
+	}
+	key = "d"
+	_, ok, err := ref.TryGet(&key)
+	if err != nil || ok {
+		t.Fatalf("TryGet of a rejected Put = %t, %v", ok, err)
+	}
+	wantTotal(9)
+}
+
+func TestUpdate(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 2)
+
+	// The writes of a failed Update are rolled back together.
+	errAbort := errors.New("abort")
+	err = db.Update(func(tx *ezdb.Tx) error {
+		key, val := "k0002", "v2"
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		key = "k0000"
+		err = ref.DeleteTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want the error of fn", err)
+	}
+	wantN(t, ref, 2)
+
+	// Reads in the transaction see its writes.
+	err = db.Update(func(tx *ezdb.Tx) error {
+		key, val := "k0002", "v2"
+		err := ref.PutTx(tx, &key, &val)
+		if err != nil {
+			return err
+		}
+		got, err := ref.GetTx(tx, &key)
+		if err != nil {
+			return err
+		}
+		if *got != val {
+			t.Errorf("GetTx in the transaction = %q, want %q", *got, val)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	wantN(t, ref, 3)
+}
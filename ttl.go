@@ -0,0 +1,296 @@
+package ezdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultSweepBatch is the number of expired entries a sweeper deletes per
+// transaction unless configured otherwise.
+const defaultSweepBatch = 1024
+
+type ttlOptions struct {
+	sweepInterval time.Duration
+	sweepBatch    uint
+}
+
+// WithTTL enables PutTTL on the DBRef. Expiry times are kept in a companion
+// named database, which counts towards WithNumDBs. Expired entries are
+// invisible to Get, TryGet and ForEach right away, and are deleted by a
+// background sweeper every sweepInterval, sweepBatch entries per transaction
+// (1024 if zero). A zero sweepInterval disables the sweeper; expired entries
+// are then only deleted when overwritten.
+func WithTTL(sweepInterval time.Duration, sweepBatch uint) RefOption {
+	return func(option *refOptions) error {
+		if sweepInterval < 0 {
+			return errors.New("sweep interval must not be negative")
+		}
+		if sweepBatch == 0 {
+			sweepBatch = defaultSweepBatch
+		}
+		option.ttl = &ttlOptions{sweepInterval: sweepInterval, sweepBatch: sweepBatch}
+		return nil
+	}
+}
+
+// The expiry database holds, for every entry with a TTL, a key
+// ttlKeyPrefix+key mapping it to its expiry time and an empty key
+// ttlExpiryPrefix+expiry+key ordering the entries by expiry time. Expiry
+// times are big-endian Unix nanoseconds.
+var (
+	ttlKeyPrefix    = []byte("k")
+	ttlExpiryPrefix = []byte("e")
+)
+
+func (ref *DBRef[K, V]) ttlDBName() string {
+	return ref.id + ".ttl"
+}
+
+// initTTL opens the expiry database, creating it if it doesn't exist, and
+// starts the sweeper.
+func (ref *DBRef[K, V]) initTTL() error {
+	var err error
+	if ref.ownerDB.readOnly() {
+		err = ref.ownerDB.view(func(txn readTxn) error {
+			_, err := txn.DBRef(ref.ttlDBName(), dbFlag(0))
+			return err
+		})
+	} else {
+		err = ref.ownerDB.update(func(txn writeTxn) error {
+			_, err := txn.DBRef(ref.ttlDBName(), dbCreate)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if ref.options.ttl.sweepInterval > 0 && !ref.ownerDB.readOnly() {
+		ref.ownerDB.startBackground(ref.sweep)
+	}
+
+	return nil
+}
+
+// PutTTL stores val under key like Put, and makes it expire after ttl. The
+// DBRef must have been created with WithTTL. A later Put of the same key
+// removes the expiry again.
+func (ref *DBRef[K, V]) PutTTL(key *K, val *V, ttl time.Duration) error {
+	if ref.options.ttl == nil {
+		return errors.New("TTLs are not enabled, see WithTTL")
+	}
+	if ttl <= 0 {
+		return errors.New("ttl must be positive")
+	}
+
+	return ref.intercept(opPut, key, val, func() error {
+		expiry := time.Now().Add(ttl)
+		return ref.putWith(key, val, putFlag(0), func(txn writeTxn, keyBytes []byte) error {
+			return ref.setExpiryInTxn(txn, keyBytes, expiry)
+		})
+	})
+}
+
+// Expire makes the entry under key expire after ttl, replacing any expiry it
+// has, without rewriting its value, and reports whether there is an entry
+// under key. The DBRef must have been created with WithTTL.
+func (ref *DBRef[K, V]) Expire(key *K, ttl time.Duration) (ok bool, err error) {
+	if ref.options.ttl == nil {
+		return false, errors.New("TTLs are not enabled, see WithTTL")
+	}
+	if ttl <= 0 {
+		return false, errors.New("ttl must be positive")
+	}
+
+	err = ref.intercept(opExpire, key, nil, func() error {
+		return ref.writeKey(opExpire, key, func(txn writeTxn, dbRef dbi, keyBytes []byte) error {
+			// The transaction may run again as part of another batch.
+			ok = false
+			now := time.Now()
+
+			_, err := txn.Get(dbRef, keyBytes)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get key: %w", err)
+			}
+			expired, err := ref.expiredInTxn(txn, keyBytes, now)
+			if err != nil || expired {
+				return err
+			}
+			ok = true
+
+			err = ref.clearExpiryInTxn(txn, keyBytes)
+			if err != nil {
+				return err
+			}
+			return ref.setExpiryInTxn(txn, keyBytes, now.Add(ttl))
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// setExpiryInTxn makes the entry under keyBytes expire at expiry.
+func (ref *DBRef[K, V]) setExpiryInTxn(txn writeTxn, keyBytes []byte, expiry time.Time) error {
+	ttlRef, err := txn.DBRef(ref.ttlDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	stamp := binary.BigEndian.AppendUint64(nil, uint64(expiry.UnixNano()))
+	err = txn.Put(ttlRef, prefixed(ttlKeyPrefix, keyBytes), stamp, putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to write expiry: %w", err)
+	}
+	err = txn.Put(ttlRef, append(prefixed(ttlExpiryPrefix, stamp), keyBytes...), []byte{}, putFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to write expiry: %w", err)
+	}
+
+	return nil
+}
+
+// clearExpiryInTxn removes the expiry of the entry under keyBytes, if it has
+// one.
+func (ref *DBRef[K, V]) clearExpiryInTxn(txn writeTxn, keyBytes []byte) error {
+	ttlRef, err := txn.DBRef(ref.ttlDBName(), dbFlag(0))
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	entryKey := prefixed(ttlKeyPrefix, keyBytes)
+	stamp, err := txn.Get(ttlRef, entryKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read expiry: %w", err)
+	}
+	expiryKey := append(prefixed(ttlExpiryPrefix, stamp), keyBytes...)
+
+	for _, key := range [][]byte{entryKey, expiryKey} {
+		err = txn.Delete(ttlRef, key, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete expiry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// expiredInTxn reports whether the entry under keyBytes has expired.
+func (ref *DBRef[K, V]) expiredInTxn(txn readTxn, keyBytes []byte, now time.Time) (bool, error) {
+	expiry, ok, err := ref.expiryInTxn(txn, keyBytes)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return !expiry.After(now), nil
+}
+
+// expiryInTxn returns the expiry of the entry under keyBytes and whether it
+// has one.
+func (ref *DBRef[K, V]) expiryInTxn(txn readTxn, keyBytes []byte) (time.Time, bool, error) {
+	ttlRef, err := txn.DBRef(ref.ttlDBName(), dbFlag(0))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	stamp, err := txn.Get(ttlRef, prefixed(ttlKeyPrefix, keyBytes))
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read expiry: %w", err)
+	}
+	if len(stamp) != 8 {
+		return time.Time{}, false, errors.New("corrupt expiry")
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(stamp))), true, nil
+}
+
+// sweep deletes expired entries every sweep interval until stop is closed.
+func (ref *DBRef[K, V]) sweep(stop <-chan struct{}) {
+	ticker := time.NewTicker(ref.options.ttl.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := ref.sweepOnce(time.Now())
+			if err != nil {
+				ref.ownerDB.options.log.Error().Err(err).Str("db", ref.id).Msg("failed to sweep expired entries")
+				break
+			}
+			if n < int(ref.options.ttl.sweepBatch) {
+				break
+			}
+		}
+	}
+}
+
+// sweepOnce deletes up to one batch of entries expired at now in one write
+// transaction, and returns how many it deleted.
+func (ref *DBRef[K, V]) sweepOnce(now time.Time) (n int, err error) {
+	limit := append(bytes.Clone(ttlExpiryPrefix), binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano()))...)
+
+	err = ref.ownerDB.update(func(txn writeTxn) error {
+		ttlRef, err := txn.DBRef(ref.ttlDBName(), dbFlag(0))
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+		dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+		if err != nil {
+			return fmt.Errorf("failed to get db ref: %w", err)
+		}
+
+		var expired [][]byte
+		cursor, err := txn.NewCursor(ttlRef)
+		if err != nil {
+			return fmt.Errorf("failed to open cursor: %w", err)
+		}
+		key, _, err := cursor.SeekGreaterThanOrEqualKey(ttlExpiryPrefix)
+		for ; err == nil && len(expired) < int(ref.options.ttl.sweepBatch); key, _, err = cursor.Next() {
+			// Expiry keys are the prefix, 8 bytes of expiry time and the key,
+			// so the expired ones are those whose first 9 bytes are at most
+			// limit.
+			if !bytes.HasPrefix(key, ttlExpiryPrefix) || bytes.Compare(key[:len(limit)], limit) > 0 {
+				break
+			}
+			expired = append(expired, bytes.Clone(key[len(limit):]))
+		}
+		cursor.Close()
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to read expiry: %w", err)
+		}
+
+		for _, keyBytes := range expired {
+			err = ref.removeInTxn(txn, dbRef, keyBytes, opExpire)
+			if err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,204 @@
+package ezdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestPutTTL(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "sessions", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	key, val := "short", "v"
+	err = ref.PutTTL(&key, &val, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	key = "long"
+	err = ref.PutTTL(&key, &val, time.Hour)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	key = "renewed"
+	err = ref.PutTTL(&key, &val, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	// A plain Put removes the expiry.
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	for key, want := range map[string]bool{"short": false, "long": true, "renewed": true} {
+		if has(t, ref, key) != want {
+			t.Errorf("entry %s visible = %t, want %t", key, !want, want)
+		}
+	}
+	n := 0
+	err = ref.ForEach(func(key, val *string) error {
+		n++
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("ForEach saw %d entries, %v, want 2", n, err)
+	}
+
+	err = ref.PutTTL(&key, &val, 0)
+	if err == nil {
+		t.Fatal("PutTTL with a zero ttl succeeded")
+	}
+	plain, err := ezdb.NewRef[string, string]("plain", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	err = plain.PutTTL(&key, &val, time.Hour)
+	if err == nil {
+		t.Fatal("PutTTL without WithTTL succeeded")
+	}
+	_, err = ezdb.NewDBRef[string, string](db, "negative", ezdb.WithTTL(-time.Second, 0))
+	if err == nil {
+		t.Fatal("NewDBRef with a negative sweep interval succeeded")
+	}
+}
+
+func TestTTLSweeper(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	// One entry per transaction makes the sweeper loop over batches.
+	ref, err := ezdb.NewDBRef[string, string](db, "sessions", ezdb.WithTTL(5*time.Millisecond, 1))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	sweptAll := func() {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+			stat, err := ref.Stat()
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if stat.Entries == 1 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d entries left after sweeping", stat.Entries)
+			}
+		}
+	}
+	putExpiring := func() {
+		t.Helper()
+		for _, key := range []string{"a", "b", "c"} {
+			key, val := key, "v"
+			err := ref.PutTTL(&key, &val, time.Millisecond)
+			if err != nil {
+				t.Fatalf("PutTTL: %v", err)
+			}
+		}
+	}
+
+	key, val := "kept", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	putExpiring()
+	sweptAll()
+
+	// The sweeper runs again once the Client is reopened.
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = db.Reopen()
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	putExpiring()
+	sweptAll()
+}
+
+func TestExpire(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "sessions", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "plain", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	key = "long"
+	err = ref.PutTTL(&key, &val, time.Hour)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+
+	// Expire sets an expiry on entries without one, and replaces an
+	// existing one.
+	for _, key := range []string{"plain", "long"} {
+		key := key
+		ok, err := ref.Expire(&key, time.Millisecond)
+		if err != nil || !ok {
+			t.Fatalf("Expire of %s = %t, %v", key, ok, err)
+		}
+	}
+	key = "missing"
+	ok, err := ref.Expire(&key, time.Hour)
+	if err != nil || ok {
+		t.Fatalf("Expire of a missing key = %t, %v", ok, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	for _, key := range []string{"plain", "long"} {
+		if has(t, ref, key) {
+			t.Errorf("entry %s is visible after expiring", key)
+		}
+	}
+
+	// Expired entries can't be given a new expiry.
+	key = "plain"
+	ok, err = ref.Expire(&key, time.Hour)
+	if err != nil || ok {
+		t.Fatalf("Expire of an expired key = %t, %v", ok, err)
+	}
+
+	_, err = ref.Expire(&key, 0)
+	if err == nil {
+		t.Fatal("Expire with a zero ttl succeeded")
+	}
+	plain, err := ezdb.NewRef[string, string]("plain", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	_, err = plain.Expire(&key, time.Hour)
+	if err == nil {
+		t.Fatal("Expire without WithTTL succeeded")
+	}
+}
+
+func TestExpireFreshEnvironment(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "sessions", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "k", "v"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// No expiry has been set on the DBRef before.
+	ok, err := ref.Expire(&key, time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("Expire = %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,45 @@
+package ezdb
+
+// PutValue is like Put, but takes the key and value by value, so simple key
+// and value types don't need their addresses taken.
+func (ref *DBRef[K, V]) PutValue(key K, val V) error {
+	return ref.Put(&key, &val)
+}
+
+// GetValue is like Get, but takes the key by value and returns the value
+// itself. It returns the zero value of V on error.
+func (ref *DBRef[K, V]) GetValue(key K) (V, error) {
+	val, err := ref.Get(&key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	return *val, nil
+}
+
+// TryGetValue is like TryGet, but takes the key by value and returns the
+// value itself. It returns the zero value of V if the key is missing.
+func (ref *DBRef[K, V]) TryGetValue(key K) (V, bool, error) {
+	val, ok, err := ref.TryGet(&key)
+	if err != nil || !ok {
+		var zero V
+		return zero, ok, err
+	}
+
+	return *val, true, nil
+}
+
+// GetOrDefault returns the value stored under key, or def if the key is
+// missing. Storage errors are still returned as errors.
+func (ref *DBRef[K, V]) GetOrDefault(key *K, def V) (V, error) {
+	val, ok, err := ref.TryGet(key)
+	if err != nil {
+		return def, err
+	}
+	if !ok {
+		return def, nil
+	}
+
+	return *val, nil
+}
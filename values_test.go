@@ -0,0 +1,81 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestValueVariants(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, point]("points", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	_, err = ref.GetValue("a")
+	if !errors.Is(err, ezdb.ErrNotFound) {
+		t.Fatalf("GetValue of a missing key returned %v, want ErrNotFound", err)
+	}
+	got, ok, err := ref.TryGetValue("a")
+	if err != nil || ok || got != (point{}) {
+		t.Fatalf("TryGetValue of a missing key = %v, %v, %v", got, ok, err)
+	}
+
+	err = ref.PutValue("a", point{1, 2})
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	got, err = ref.GetValue("a")
+	if err != nil || got != (point{1, 2}) {
+		t.Fatalf("GetValue = %v, %v", got, err)
+	}
+	got, ok, err = ref.TryGetValue("a")
+	if err != nil || !ok || got != (point{1, 2}) {
+		t.Fatalf("TryGetValue = %v, %v, %v", got, ok, err)
+	}
+
+	// The pointer API sees the same entries.
+	key := "a"
+	ptr, err := ref.Get(&key)
+	if err != nil || *ptr != (point{1, 2}) {
+		t.Fatalf("Get = %v, %v", ptr, err)
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, int]("counts", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+
+	key := "a"
+	got, err := ref.GetOrDefault(&key, 7)
+	if err != nil || got != 7 {
+		t.Fatalf("GetOrDefault of a missing key = %d, %v, want 7", got, err)
+	}
+	err = ref.PutValue(key, 3)
+	if err != nil {
+		t.Fatalf("PutValue: %v", err)
+	}
+	got, err = ref.GetOrDefault(&key, 7)
+	if err != nil || got != 3 {
+		t.Fatalf("GetOrDefault = %d, %v, want 3", got, err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err = ref.GetOrDefault(&key, 7)
+	if !errors.Is(err, ezdb.ErrClosed) || got != 7 {
+		t.Fatalf("GetOrDefault after Close = %d, %v, want 7 and ErrClosed", got, err)
+	}
+}
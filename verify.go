@@ -0,0 +1,175 @@
+package ezdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// VerifyOptions configure Verify.
+type VerifyOptions struct {
+	// DBRefs limits the check to the named databases with these names. The
+	// default is every named database of the environment.
+	DBRefs []string
+	// MaxCorrupt stops the check once this many corrupt entries have been
+	// found. Zero means no limit.
+	MaxCorrupt int
+}
+
+// Report is the outcome of Verify.
+type Report struct {
+	// Checked is the number of entries checked.
+	Checked uint64
+	// Corrupt are the entries that failed to decode.
+	Corrupt []CorruptEntry
+	// Unverified are the named databases that no DBRef was opened for on the
+	// Client, such as those of indexes, so their contents couldn't be
+	// checked.
+	Unverified []string
+	// Truncated reports whether the check stopped at VerifyOptions.MaxCorrupt.
+	Truncated bool
+}
+
+// OK reports whether no corrupt entries were found.
+func (r *Report) OK() bool {
+	return len(r.Corrupt) == 0
+}
+
+// CorruptEntry is an entry that failed verification.
+type CorruptEntry struct {
+	DBRef string
+	// Key is the key as stored, i.e. encoded.
+	Key []byte
+	Err error
+}
+
+// verifier checks every entry of a DBRef in txn and adds the corrupt ones to
+// report, until it holds limit of them, if limit is positive.
+type verifier func(ctx context.Context, txn readTxn, report *Report, limit int) error
+
+// Verify walks the named databases of the environment and, for those a DBRef
+// has been opened for on the Client, checks that every key and value decodes
+// with the DBRef's codecs and, for DBRefs opened WithChecksums, that values
+// match their checksums, to detect bit rot or partial writes. Corrupt
+// entries are reported, not returned as errors: the error is only non-nil if
+// the check itself failed, e.g. because ctx is done. Each database is checked
+// in its own read transaction.
+func (db *Client) Verify(ctx context.Context, opts VerifyOptions) (*Report, error) {
+	refs := db.registeredRefs()
+
+	if len(opts.DBRefs) == 0 {
+		names, err := db.ListDBs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list databases: %w", err)
+		}
+		opts.DBRefs = names
+	}
+
+	report := &Report{}
+	for _, name := range opts.DBRefs {
+		v := refs[name].verify
+		if v == nil {
+			report.Unverified = append(report.Unverified, name)
+			continue
+		}
+
+		err := db.view(func(txn readTxn) error {
+			return v(ctx, txn, report, opts.MaxCorrupt)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify %s: %w", name, err)
+		}
+		if report.Truncated {
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// verifyInTxn is ref's verifier.
+func (ref *DBRef[K, V]) verifyInTxn(ctx context.Context, txn readTxn, report *Report, limit int) error {
+	dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	cursor, err := txn.NewCursor(dbRef)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	defer cursor.Close()
+
+	keyBytes, valBytes, err := cursor.First()
+	for ; err == nil; keyBytes, valBytes, err = cursor.Next() {
+		if report.Checked%1024 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.Checked++
+
+		entryErr := ref.verifyKey(keyBytes)
+		if entryErr == nil {
+			_, entryErr = ref.decodeValInTxn(txn, valBytes)
+			if entryErr != nil {
+				entryErr = fmt.Errorf("failed to decode value: %w", entryErr)
+			}
+		}
+		if entryErr == nil {
+			continue
+		}
+
+		report.Corrupt = append(report.Corrupt, CorruptEntry{DBRef: ref.id, Key: bytes.Clone(keyBytes), Err: entryErr})
+		if limit > 0 && len(report.Corrupt) >= limit {
+			report.Truncated = true
+			return nil
+		}
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+
+	return nil
+}
+
+// verifyKey checks that keyBytes decodes with ref's key codec, either as it
+// is or, if it belongs to a namespace made by Sub, once the namespace
+// prefixes are stripped.
+func (ref *DBRef[K, V]) verifyKey(keyBytes []byte) error {
+	key := new(K)
+	err := ref.options.keyCodec.Unmarshal(keyBytes, key)
+	if err == nil {
+		return nil
+	}
+
+	for rest := keyBytes; ; {
+		var ok bool
+		rest, ok = skipEscaped(rest)
+		if !ok {
+			return fmt.Errorf("failed to decode key: %w", err)
+		}
+		if ref.options.keyCodec.Unmarshal(rest, key) == nil {
+			return nil
+		}
+	}
+}
+
+// skipEscaped returns what follows the escaped string b starts with, as
+// written by appendEscaped, and whether there is one.
+func skipEscaped(b []byte) ([]byte, bool) {
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] != escByte {
+			continue
+		}
+		switch b[i+1] {
+		case escTerm:
+			return b[i+2:], true
+		case escZero:
+			i++
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
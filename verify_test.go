@@ -0,0 +1,116 @@
+package ezdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestVerify(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	// Only the DBRef opened last on a name is verified, so values that
+	// aren't JSON are written before the users DBRef is opened.
+	raw := rawRef(t, db, "users")
+	for _, key := range []string{"bad1", "bad2"} {
+		val := []byte("{not json")
+		err := raw.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	ref, err := ezdb.NewDBRef[string, user](db, "users", ezdb.WithCodec(ezdb.JSONCodec{}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "ada", user{Name: "Ada"}
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Keys in a namespace decode once its prefix is stripped.
+	err = ref.Sub("tenant").Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	report, err := db.Verify(context.Background(), ezdb.VerifyOptions{DBRefs: []string{"users"}})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.OK() || report.Checked != 4 || len(report.Corrupt) != 2 || report.Truncated {
+		t.Fatalf("Verify = %+v, want 2 of 4 entries corrupt", report)
+	}
+	for i, want := range []string{"bad1", "bad2"} {
+		corrupt := report.Corrupt[i]
+		if corrupt.DBRef != "users" || !bytes.Contains(corrupt.Key, []byte(want)) || corrupt.Err == nil {
+			t.Fatalf("corrupt entry %d = %+v, want %s", i, corrupt, want)
+		}
+	}
+
+	report, err = db.Verify(context.Background(), ezdb.VerifyOptions{DBRefs: []string{"users"}, MaxCorrupt: 1})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(report.Corrupt) != 1 || !report.Truncated {
+		t.Fatalf("Verify with MaxCorrupt 1 = %+v", report)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.Verify(ctx, ezdb.VerifyOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Verify with a canceled context returned %v", err)
+	}
+}
+
+func TestVerifyReportsUnverifiedDBs(t *testing.T) {
+	dir := t.TempDir()
+	db := openClient(t, dir)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Without a DBRef of its own, the database of the earlier Client can't be
+	// checked.
+	db = openClient(t, dir)
+	checked, err := ezdb.NewRef[string, string]("checked", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, checked, 5)
+	report, err := db.Verify(context.Background(), ezdb.VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.OK() || report.Checked != 5 || len(report.Unverified) != 1 || report.Unverified[0] != "ref" {
+		t.Fatalf("Verify = %+v, want ref unverified", report)
+	}
+}
+
+func TestVerifyMemoryClient(t *testing.T) {
+	db := testutil.NewMemoryClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 10)
+
+	for _, opts := range []ezdb.VerifyOptions{{}, {DBRefs: []string{"ref"}}} {
+		report, err := db.Verify(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Verify(%+v): %v", opts, err)
+		}
+		if !report.OK() || report.Checked != 10 {
+			t.Fatalf("Verify(%+v) = %+v", opts, report)
+		}
+	}
+}
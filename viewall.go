@@ -0,0 +1,82 @@
+package ezdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot is a read transaction shared by the reads of several DBRefs of a
+// Client, passed to the functions run by Client.ViewAll. It is only valid
+// until the function it is passed to returns.
+type Snapshot struct {
+	txn readTxn
+}
+
+// ViewAll runs fn in a read transaction, so the reads it makes through s,
+// with methods such as GetIn, TryGetIn and ForEachIn on DBRefs of db, all see
+// the database as of one point in time, e.g. an order along with exactly its
+// line items. The error fn returns is returned by ViewAll. On NewBolt, fn
+// must not wait for a write, which may wait for fn to return.
+func (db *Client) ViewAll(fn func(s *Snapshot) error) error {
+	return db.view(func(txn readTxn) error {
+		return fn(&Snapshot{txn: txn})
+	})
+}
+
+// GetIn is Get within s.
+func (ref *DBRef[K, V]) GetIn(s *Snapshot, key *K) (*V, error) {
+	val, ok, err := ref.TryGetIn(s, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("failed to get key: %w", ErrNotFound)
+	}
+
+	return val, nil
+}
+
+// TryGetIn is TryGet within s.
+func (ref *DBRef[K, V]) TryGetIn(s *Snapshot, key *K) (*V, bool, error) {
+	keyBytes, err := ref.encodeKey(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	val, err := ref.getLiveInTxn(s.txn, keyBytes)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, val != nil, nil
+}
+
+// ForEachIn is ForEach within s.
+func (ref *DBRef[K, V]) ForEachIn(s *Snapshot, fn func(key *K, val *V) error) error {
+	return ref.scanInTxn(s.txn, fn)
+}
+
+// getLiveInTxn returns the decoded value stored under keyBytes, or nil if
+// there is none or it has expired.
+func (ref *DBRef[K, V]) getLiveInTxn(txn readTxn, keyBytes []byte) (*V, error) {
+	dbRef, err := txn.DBRef(ref.id, ref.options.dbFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db ref: %w", err)
+	}
+
+	val, err := ref.getInTxn(txn, dbRef, keyBytes)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	if ref.options.ttl != nil {
+		expired, err := ref.expiredInTxn(txn, keyBytes, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			return nil, nil
+		}
+	}
+
+	return val, nil
+}
@@ -0,0 +1,161 @@
+package ezdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+func TestViewAll(t *testing.T) {
+	for _, e := range engines {
+		t.Run(string(e.engine), func(t *testing.T) {
+			db := e.open(t)
+			orders, err := ezdb.NewRef[string, string]("orders", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			items, err := ezdb.NewRef[string, string]("items", db)
+			if err != nil {
+				t.Fatalf("NewRef: %v", err)
+			}
+			putN(t, orders, 1)
+			putN(t, items, 3)
+
+			write := func() error {
+				key, val := "k0000", "changed"
+				err := items.Put(&key, &val)
+				if err == nil {
+					err = items.Delete(&key)
+				}
+				if err == nil {
+					key = "k0001"
+					err = items.Put(&key, &val)
+				}
+				return err
+			}
+
+			// Writes made while fn runs aren't seen by it. bbolt writes may
+			// wait for fn, so there they are made before and not checked.
+			if e.engine == ezdb.EngineBolt {
+				err = write()
+				if err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			err = db.ViewAll(func(s *ezdb.Snapshot) error {
+				key := "k0000"
+				got, err := orders.GetIn(s, &key)
+				if err != nil || *got != "v0" {
+					t.Fatalf("GetIn = %v, %v", got, err)
+				}
+				if e.engine == ezdb.EngineBolt {
+					return nil
+				}
+
+				done := make(chan error)
+				go func() { done <- write() }()
+				err = <-done
+				if err != nil {
+					t.Fatalf("write during ViewAll: %v", err)
+				}
+
+				got, ok, err := items.TryGetIn(s, &key)
+				if err != nil || !ok || *got != "v0" {
+					t.Fatalf("TryGetIn = %v, %t, %v", got, ok, err)
+				}
+				n := 0
+				err = items.ForEachIn(s, func(key, val *string) error {
+					if *val != "v"+(*key)[4:] {
+						t.Errorf("ForEachIn saw %s: %s", *key, *val)
+					}
+					n++
+					return nil
+				})
+				if err != nil || n != 3 {
+					t.Fatalf("ForEachIn saw %d entries, %v", n, err)
+				}
+
+				key = "missing"
+				_, err = orders.GetIn(s, &key)
+				if !errors.Is(err, ezdb.ErrNotFound) {
+					t.Fatalf("GetIn of a missing key returned %v, want ErrNotFound", err)
+				}
+				_, ok, err = orders.TryGetIn(s, &key)
+				if err != nil || ok {
+					t.Fatalf("TryGetIn of a missing key = %t, %v", ok, err)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ViewAll: %v", err)
+			}
+
+			key := "k0000"
+			_, ok, err := items.TryGet(&key)
+			if err != nil || ok {
+				t.Fatalf("TryGet after ViewAll = %t, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestViewAllTTL(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	key, val := "a", "v"
+	err = ref.PutTTL(&key, &val, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	err = db.ViewAll(func(s *ezdb.Snapshot) error {
+		_, ok, err := ref.TryGetIn(s, &key)
+		if err != nil || ok {
+			t.Fatalf("TryGetIn of an expired key = %t, %v", ok, err)
+		}
+		_, err = ref.GetIn(s, &key)
+		if !errors.Is(err, ezdb.ErrNotFound) {
+			t.Fatalf("GetIn of an expired key returned %v, want ErrNotFound", err)
+		}
+		return ref.ForEachIn(s, func(key, val *string) error {
+			t.Errorf("ForEachIn saw the expired key %s", *key)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("ViewAll: %v", err)
+	}
+}
+
+func TestViewAllErrors(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	putN(t, ref, 2)
+
+	errStop := errors.New("stop")
+	err = db.ViewAll(func(s *ezdb.Snapshot) error {
+		return ref.ForEachIn(s, func(*string, *string) error { return errStop })
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ViewAll returned %v, want the error of fn", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err = db.ViewAll(func(*ezdb.Snapshot) error { return nil })
+	if !errors.Is(err, ezdb.ErrClosed) {
+		t.Fatalf("ViewAll after Close returned %v, want ErrClosed", err)
+	}
+}
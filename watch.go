@@ -0,0 +1,165 @@
+package ezdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Event is a change to a DBRef delivered by Watch.
+type Event[K, V any] struct {
+	// Op is "put", "delete" or, for an entry whose TTL ran out, "expire".
+	Op  string
+	Key *K
+	// Value is the value written by a put, and nil otherwise.
+	Value *V
+	// Dropped is the number of events dropped right before this one because
+	// the subscriber fell behind and its buffer was full.
+	Dropped uint64
+}
+
+// WatchOptions configure Watch.
+type WatchOptions struct {
+	// Prefix limits the events to keys starting with it. It can only be used
+	// with DBRefs whose keys are strings.
+	Prefix string
+	// Buffer is the number of events buffered for the subscriber before new
+	// ones are dropped. The default is 64.
+	Buffer int
+}
+
+// change is a committed write, as delivered to watchers.
+type change struct {
+	ref      string
+	op       string
+	keyBytes []byte
+	// val is the *V of the DBRef written by a put, and nil otherwise.
+	val any
+}
+
+type watcher struct {
+	mu     sync.Mutex
+	closed bool
+	// deliver converts a change to an Event and sends it without blocking.
+	// It is called with mu held.
+	deliver func(c change)
+}
+
+// Watch returns a channel receiving an Event for every Put and Delete on ref
+// in this process, once the write has committed, until ctx is done, when the
+// channel is closed. This includes the writes of helpers such as LRU
+// evictions. An entry whose TTL ran out gets one "expire" event instead of a
+// "delete", when the sweeper or GC removes it, or when it is deleted or
+// overwritten first, right before the "put". Events are never blocked on: a
+// subscriber that falls behind by more than opts.Buffer events misses events,
+// and the next one it receives reports how many in Dropped. The events of
+// concurrent writes may arrive in either order. Watch fails if opts.Prefix is
+// set and the keys of ref aren't strings.
+func (ref *DBRef[K, V]) Watch(ctx context.Context, opts WatchOptions) (<-chan Event[K, V], error) {
+	if opts.Prefix != "" && reflect.TypeOf((*K)(nil)).Elem().Kind() != reflect.String {
+		return nil, fmt.Errorf("failed to watch: prefix given for keys of type %T, which aren't strings", *new(K))
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+
+	ch := make(chan Event[K, V], opts.Buffer)
+	var dropped uint64
+	w := &watcher{}
+	w.deliver = func(c change) {
+		key, err := ref.decodeKey(c.keyBytes)
+		if err != nil {
+			return
+		}
+		if opts.Prefix != "" && !strings.HasPrefix(reflect.ValueOf(key).Elem().String(), opts.Prefix) {
+			return
+		}
+
+		event := Event[K, V]{Op: c.op, Key: key, Dropped: dropped}
+		if v, ok := c.val.(*V); ok && v != nil {
+			val := *v
+			event.Value = &val
+		}
+		select {
+		case ch <- event:
+			dropped = 0
+		default:
+			dropped++
+		}
+	}
+
+	db := ref.ownerDB
+	db.addWatcher(ref.id, w)
+	go func() {
+		<-ctx.Done()
+		db.removeWatcher(ref.id, w)
+
+		w.mu.Lock()
+		w.closed = true
+		close(ch)
+		w.mu.Unlock()
+	}()
+
+	return ch, nil
+}
+
+func (db *Client) addWatcher(ref string, w *watcher) {
+	db.watchMu.Lock()
+	defer db.watchMu.Unlock()
+
+	if db.watchers == nil {
+		db.watchers = make(map[string]map[*watcher]struct{})
+	}
+	if db.watchers[ref] == nil {
+		db.watchers[ref] = make(map[*watcher]struct{})
+	}
+	db.watchers[ref][w] = struct{}{}
+	db.watching.Add(1)
+}
+
+func (db *Client) removeWatcher(ref string, w *watcher) {
+	db.watchMu.Lock()
+	defer db.watchMu.Unlock()
+
+	delete(db.watchers[ref], w)
+	if len(db.watchers[ref]) == 0 {
+		delete(db.watchers, ref)
+	}
+	db.watching.Add(-1)
+}
+
+// recordChange remembers a write made in txn, to be delivered to watchers
+// once txn commits. It does nothing if nobody is watching.
+func (db *Client) recordChange(txn writeTxn, c change) {
+	if db.watching.Load() == 0 {
+		return
+	}
+	pending, ok := db.pendingChanges.Load(txn)
+	if !ok {
+		return
+	}
+
+	// keyBytes may point into LMDB's memory map, e.g. for TTL sweeps.
+	c.keyBytes = bytes.Clone(c.keyBytes)
+	changes := pending.(*[]change)
+	*changes = append(*changes, c)
+}
+
+// publish delivers committed changes to the watchers of their DBRefs.
+func (db *Client) publish(changes []change) {
+	db.watchMu.Lock()
+	defer db.watchMu.Unlock()
+
+	for _, c := range changes {
+		for w := range db.watchers[c.ref] {
+			w.mu.Lock()
+			if !w.closed {
+				w.deliver(c)
+			}
+			w.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package ezdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjornpagen/ezdb"
+	"github.com/bjornpagen/ezdb/testutil"
+)
+
+// nextEvent receives the next event from ch, failing the test if none
+// arrives in time.
+func nextEvent[K, V any](t *testing.T, ch <-chan ezdb.Event[K, V]) ezdb.Event[K, V] {
+	t.Helper()
+
+	select {
+	case event, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+
+	panic("unreachable")
+}
+
+// noEvent checks that ch has no event waiting.
+func noEvent[K, V any](t *testing.T, ch <-chan ezdb.Event[K, V]) {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithValidator(func(key, val *string) error {
+		if *val == "" {
+			return errors.New("empty value")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	all, err := ref.Watch(ctx, ezdb.WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	users, err := ref.Watch(ctx, ezdb.WatchOptions{Prefix: "user/"})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	key, val := "user/1", "ann"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Events hold copies of the values.
+	val = "changed"
+	for _, ch := range []<-chan ezdb.Event[string, string]{all, users} {
+		event := nextEvent(t, ch)
+		if event.Op != "put" || *event.Key != "user/1" || event.Value == nil || *event.Value != "ann" {
+			t.Fatalf("event = %+v", event)
+		}
+	}
+
+	key = "other"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = ref.Delete(&key)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// Failed writes aren't reported.
+	empty := ""
+	err = ref.Put(&key, &empty)
+	if err == nil {
+		t.Fatal("Put of an invalid value succeeded")
+	}
+	if event := nextEvent(t, all); event.Op != "put" || *event.Key != "other" {
+		t.Fatalf("event = %+v", event)
+	}
+	if event := nextEvent(t, all); event.Op != "delete" || *event.Key != "other" || event.Value != nil {
+		t.Fatalf("event = %+v", event)
+	}
+	noEvent(t, all)
+	noEvent(t, users)
+
+	cancel()
+	for _, ch := range []<-chan ezdb.Event[string, string]{all, users} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Fatal("event after the context was canceled")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("channel wasn't closed")
+		}
+	}
+
+	ints, err := ezdb.NewRef[int, string]("ints", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	_, err = ints.Watch(context.Background(), ezdb.WatchOptions{Prefix: "1"})
+	if err == nil {
+		t.Fatal("Watch with a prefix of integer keys succeeded")
+	}
+}
+
+func TestWatchDropsEvents(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewRef[string, string]("ref", db)
+	if err != nil {
+		t.Fatalf("NewRef: %v", err)
+	}
+	ch, err := ref.Watch(context.Background(), ezdb.WatchOptions{Buffer: 1})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	put := func(key string) {
+		t.Helper()
+		val := "v"
+		err := ref.Put(&key, &val)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	put("a")
+	put("b")
+	put("c")
+	if event := nextEvent(t, ch); *event.Key != "a" || event.Dropped != 0 {
+		t.Fatalf("first event = %+v", event)
+	}
+	put("d")
+	if event := nextEvent(t, ch); *event.Key != "d" || event.Dropped != 2 {
+		t.Fatalf("event after falling behind = %+v, want 2 dropped", event)
+	}
+}
+
+func TestWatchExpire(t *testing.T) {
+	db := testutil.NewTempClient(t)
+	ref, err := ezdb.NewDBRef[string, string](db, "ref", ezdb.WithTTL(0, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	val := "v"
+	for _, key := range []string{"a", "b", "c", "d"} {
+		ttl := time.Millisecond
+		if key == "d" {
+			ttl = time.Hour
+		}
+		err = ref.PutTTL(&key, &val, ttl)
+		if err != nil {
+			t.Fatalf("PutTTL: %v", err)
+		}
+	}
+	ch, err := ref.Watch(context.Background(), ezdb.WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	key := "a"
+	err = ref.Put(&key, &val)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for _, key := range []string{"b", "d"} {
+		err = ref.Delete(&key)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	_, err = ref.GC(context.Background())
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+
+	for _, want := range []struct{ op, key string }{
+		{"expire", "a"},
+		{"put", "a"},
+		{"expire", "b"},
+		{"delete", "d"},
+		{"expire", "c"},
+	} {
+		event := nextEvent(t, ch)
+		if event.Op != want.op || *event.Key != want.key || (event.Op == "expire" && event.Value != nil) {
+			t.Fatalf("event = %+v, want %s of %s", event, want.op, want.key)
+		}
+	}
+	noEvent(t, ch)
+}
+
+func TestWatchExpireSweep(t *testing.T) {
+	ref, err := ezdb.NewDBRef[string, string](testutil.NewTempClient(t), "ref", ezdb.WithTTL(time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("NewDBRef: %v", err)
+	}
+	ch, err := ref.Watch(context.Background(), ezdb.WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	key, val := "a", "v"
+	err = ref.PutTTL(&key, &val, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PutTTL: %v", err)
+	}
+
+	if event := nextEvent(t, ch); event.Op != "put" {
+		t.Fatalf("event = %+v", event)
+	}
+	if event := nextEvent(t, ch); event.Op != "expire" || *event.Key != "a" || event.Value != nil {
+		t.Fatalf("event = %+v", event)
+	}
+}